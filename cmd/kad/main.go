@@ -0,0 +1,86 @@
+// Command kad runs a node of the IPFS DHT built with the library, issues lookups, puts and gets
+// from the command line, and runs simulations of networks of nodes printing their statistics.
+//
+// Usage:
+//
+//	kad node [flags]                 run a node until interrupted
+//	kad find-peer [flags] <peer id>  look up the addresses of a peer
+//	kad closest [flags] <key>        look up the closest peers to a key
+//	kad get [flags] <key>            look up the value stored under a key
+//	kad put [flags] <key> <value>    store a value under a key
+//	kad sim [flags]                  run a simulation and print its statistics
+//
+// Run a command with -h for its flags.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+// command is a subcommand of kad, run with the arguments following its name.
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{"node", "run a node until interrupted", runNode},
+	{"find-peer", "look up the addresses of a peer", runFindPeer},
+	{"closest", "look up the closest peers to a key", runClosest},
+	{"get", "look up the value stored under a key", runGet},
+	{"put", "store a value under a key", runPut},
+	{"sim", "run a simulation and print its statistics", runSim},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: kad <command> [flags] [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.usage)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	for _, c := range commands {
+		if c.name != os.Args[1] {
+			continue
+		}
+		err := c.run(ctx, os.Args[2:])
+		switch {
+		case err == nil:
+			return
+		case errors.Is(err, flag.ErrHelp):
+			os.Exit(2)
+		default:
+			fmt.Fprintf(os.Stderr, "kad %s: %v\n", c.name, err)
+			os.Exit(1)
+		}
+	}
+	usage()
+	os.Exit(2)
+}
+
+// newFlagSet returns the flag set of the command name, taking the arguments described by args.
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: kad %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
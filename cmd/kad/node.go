@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	golibp2p "github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	mh "github.com/multiformats/go-multihash"
+	"golang.org/x/exp/slog"
+
+	"github.com/plprobelab/go-kademlia/dht"
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/libp2p"
+	"github.com/plprobelab/go-kademlia/logging"
+	"github.com/plprobelab/go-kademlia/records"
+	"github.com/plprobelab/go-kademlia/routing/simplert"
+	"github.com/plprobelab/go-kademlia/server/basicserver"
+)
+
+// defaultBootstrapPeers are the bootstrap peers of the public IPFS DHT.
+var defaultBootstrapPeers = []string{
+	"/dnsaddr/bootstrap.libp2p.io/p2p/QmNnooDu7bfjPFoTZYxMNLWUQJyrVwtbZg5gBMjTezGAJN",
+	"/dnsaddr/bootstrap.libp2p.io/p2p/QmQCU2EcMqAqQPR2i9bChDtGNJchTbq5TbXJJ16u19uLTa",
+	"/dnsaddr/bootstrap.libp2p.io/p2p/QmbLHAnMoJPWSCR5Zhtx6BHJX9KiKNN6tpvbUcqanj75Nb",
+	"/dnsaddr/bootstrap.libp2p.io/p2p/QmcZf59bWwK5XFi76CZX8cbJ4BhTzzA3gU1ZjYZcYW3dwt",
+}
+
+// nodeFlags are the flags of the commands running a node.
+type nodeFlags struct {
+	listen    string
+	bootstrap string
+	client    bool
+	timeout   time.Duration
+	verbose   bool
+}
+
+func (f *nodeFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.listen, "listen", "/ip4/0.0.0.0/tcp/0", "comma separated multiaddrs the node listens on")
+	fs.StringVar(&f.bootstrap, "bootstrap", strings.Join(defaultBootstrapPeers, ","), "comma separated multiaddrs of the peers the node bootstraps from, ending with their /p2p/ peer id, empty for the first node of a network")
+	fs.BoolVar(&f.client, "client", false, "run the node as a client, which does not serve the DHT")
+	fs.BoolVar(&f.verbose, "v", false, "log the requests of the node and the progress of its queries to stderr")
+	fs.DurationVar(&f.timeout, "timeout", time.Minute, "the time after which connecting, bootstrapping and the lookup of the command give up")
+}
+
+// runningNode is a dht.Node over a libp2p host, driven by a goroutine until its context is done.
+type runningNode struct {
+	host host.Host
+	node *dht.Node[key.Key256, multiaddr.Multiaddr]
+	rt   *simplert.SimpleRT[key.Key256, kad.NodeID[key.Key256]] // only used by the goroutine driving the node
+}
+
+// tableSize returns the number of nodes in the routing table, read by the goroutine driving the
+// node, which writes it.
+func (n *runningNode) tableSize(ctx context.Context) (int, error) {
+	size := make(chan int, 1)
+	n.node.Scheduler().EnqueueAction(ctx, event.BasicAction(func(context.Context) {
+		size <- n.rt.Size()
+	}))
+	select {
+	case s := <-size:
+		return s, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// startNode starts a node configured by f and bootstraps it, returning once the bootstrap is
+// done or timed out. The node runs until ctx is done.
+func startNode(ctx context.Context, f *nodeFlags) (*runningNode, error) {
+	h, err := golibp2p.New(golibp2p.ListenAddrStrings(strings.Split(f.listen, ",")...))
+	if err != nil {
+		return nil, fmt.Errorf("create host: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		_ = h.Close()
+	}()
+
+	self := libp2p.NewAddrInfo(peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()})
+	var log logging.Logger
+	if f.verbose {
+		log = logging.NewSlog(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	}
+
+	sched := event.NewSimpleScheduler(clock.New())
+	epcfg := libp2p.DefaultEndpointConfig()
+	epcfg.Logger = log
+	ep, err := libp2p.NewLibp2pEndpointWithConfig(ctx, h, sched, epcfg)
+	if err != nil {
+		return nil, err
+	}
+	rt := simplert.New[key.Key256, kad.NodeID[key.Key256]](self.PeerID(), 20)
+
+	cfg := dht.ConfigFrom[key.Key256, multiaddr.Multiaddr](kad.DefaultConfig())
+	cfg.Coordinator.Logger = log
+	if f.client {
+		cfg.Mode = dht.ModeClient
+	}
+	n, err := dht.NewNode[key.Key256, multiaddr.Multiaddr](self, ep, rt, libp2p.IPFSProtocol{}, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if !f.client {
+		values, err := records.NewMemoryStore(nil)
+		if err != nil {
+			return nil, err
+		}
+		providers, err := records.NewProviderStore[key.Key256, multiaddr.Multiaddr](nil)
+		if err != nil {
+			return nil, err
+		}
+		srv := basicserver.NewBasicServer[multiaddr.Multiaddr](rt, ep,
+			basicserver.WithValueStore(records.ValueStore(values)),
+			basicserver.WithProviderStore(providers))
+		if err := n.Serve(srv, &libp2p.Message{}); err != nil {
+			return nil, err
+		}
+	}
+
+	// the endpoint and the node run their actions on their schedulers, polled by a single goroutine
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+				event.RunAll(ctx, sched)
+				event.RunAll(ctx, n.Scheduler())
+			}
+		}
+	}()
+	n.Start(ctx)
+
+	var seeds []kad.NodeInfo[key.Key256, multiaddr.Multiaddr]
+	for _, s := range strings.Split(f.bootstrap, ",") {
+		if s == "" {
+			continue
+		}
+		ai, err := peer.AddrInfoFromString(s)
+		if err != nil {
+			return nil, fmt.Errorf("bootstrap peer %q: %w", s, err)
+		}
+		// connecting resolves the dnsaddr addresses of the peer
+		cctx, cancel := context.WithTimeout(ctx, f.timeout)
+		err = h.Connect(cctx, *ai)
+		cancel()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "connect to %s: %v\n", ai.ID, err)
+			continue
+		}
+		seeds = append(seeds, libp2p.NewAddrInfo(h.Peerstore().PeerInfo(ai.ID)))
+	}
+	if f.bootstrap == "" {
+		// the first node of a network has no peer to bootstrap from
+		return &runningNode{host: h, node: n, rt: rt}, nil
+	}
+	if len(seeds) == 0 {
+		return nil, fmt.Errorf("no bootstrap peer reachable")
+	}
+	bctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+	if err := n.Bootstrap(bctx, seeds); err != nil {
+		return nil, fmt.Errorf("bootstrap: %w", err)
+	}
+	return &runningNode{host: h, node: n, rt: rt}, nil
+}
+
+// dhtKey returns the key of the DHT a name given on the command line is stored under, its SHA256
+// multihash as for the content keys of the IPFS DHT. Servers of the library only answer requests
+// for keys that are multihashes.
+func dhtKey(name string) ([]byte, error) {
+	return mh.Sum([]byte(name), mh.SHA2_256, -1)
+}
+
+// parseNodeCommand parses the flags of a command running a node and returns its nargs arguments.
+func parseNodeCommand(name, usage string, nargs int, args []string) (*nodeFlags, []string, error) {
+	var f nodeFlags
+	fs := newFlagSet(name, usage)
+	f.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	if fs.NArg() != nargs {
+		fs.Usage()
+		return nil, nil, flag.ErrHelp
+	}
+	return &f, fs.Args(), nil
+}
+
+func runNode(ctx context.Context, args []string) error {
+	f, _, err := parseNodeCommand("node", "", 0, args)
+	if err != nil {
+		return err
+	}
+	n, err := startNode(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("peer id:", n.host.ID())
+	for _, a := range n.host.Addrs() {
+		fmt.Println("listening on", a)
+	}
+	for {
+		size, err := n.tableSize(ctx)
+		if err != nil {
+			return nil
+		}
+		fmt.Println("routing table size:", size)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Minute):
+		}
+	}
+}
+
+func runFindPeer(ctx context.Context, args []string) error {
+	f, args, err := parseNodeCommand("find-peer", "<peer id>", 1, args)
+	if err != nil {
+		return err
+	}
+	target, err := peer.Decode(args[0])
+	if err != nil {
+		return err
+	}
+	n, err := startNode(ctx, f)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	info, err := n.node.FindPeer(ctx, libp2p.NewPeerID(target))
+	if err != nil {
+		return err
+	}
+	fmt.Println(info.ID())
+	for _, a := range info.Addresses() {
+		fmt.Println(a)
+	}
+	return nil
+}
+
+func runClosest(ctx context.Context, args []string) error {
+	f, args, err := parseNodeCommand("closest", "<key>", 1, args)
+	if err != nil {
+		return err
+	}
+	n, err := startNode(ctx, f)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	k, err := dhtKey(args[0])
+	if err != nil {
+		return err
+	}
+	ids, err := n.node.GetClosestPeers(ctx, k)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		fmt.Println(id)
+	}
+	return nil
+}
+
+func runGet(ctx context.Context, args []string) error {
+	f, args, err := parseNodeCommand("get", "<key>", 1, args)
+	if err != nil {
+		return err
+	}
+	n, err := startNode(ctx, f)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	k, err := dhtKey(args[0])
+	if err != nil {
+		return err
+	}
+	value, err := n.node.GetValue(ctx, k)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(append(value, '\n'))
+	return err
+}
+
+func runPut(ctx context.Context, args []string) error {
+	f, args, err := parseNodeCommand("put", "<key> <value>", 2, args)
+	if err != nil {
+		return err
+	}
+	n, err := startNode(ctx, f)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	k, err := dhtKey(args[0])
+	if err != nil {
+		return err
+	}
+	return n.node.PutValue(ctx, k, []byte(args[1]))
+}
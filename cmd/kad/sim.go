@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	sq "github.com/plprobelab/go-kademlia/query/simplequery"
+	"github.com/plprobelab/go-kademlia/sim"
+)
+
+// simConfig holds the parameters of a simulation.
+type simConfig struct {
+	nodes    int           // the number of nodes online at any time
+	degree   int           // the number of nodes each node knows when it joins
+	lookups  int           // the number of lookups run
+	interval time.Duration // the virtual time between the start of two lookups
+	session  time.Duration // the mean time nodes stay online, zero disables churn
+	latency  string        // the latency model, as parsed by parseLatency
+	seed     int64         // seeds the random choices of the simulation
+}
+
+// simResult holds the statistics of a simulation.
+type simResult struct {
+	summary  sim.Summary
+	duration time.Duration // the mean duration of the lookups done
+	churn    sim.ChurnStats
+	run      sim.RunResult
+	stats    *sim.Collector[key.Key32, net.IP]
+}
+
+// parseLatency parses a latency model: none, constant:<d>, uniform:<min>-<max> or
+// distance:<min>-<max>, where the distance model grows the latency with the XOR distance between
+// nodes.
+func parseLatency(s string, seed int64) (sim.LatencyModel[key.Key32], error) {
+	name, arg, _ := strings.Cut(s, ":")
+	bounds := func() (time.Duration, time.Duration, error) {
+		lo, hi, ok := strings.Cut(arg, "-")
+		if !ok {
+			return 0, 0, fmt.Errorf("latency %q: want <min>-<max>", s)
+		}
+		min, err := time.ParseDuration(lo)
+		if err != nil {
+			return 0, 0, fmt.Errorf("latency %q: %w", s, err)
+		}
+		max, err := time.ParseDuration(hi)
+		if err != nil {
+			return 0, 0, fmt.Errorf("latency %q: %w", s, err)
+		}
+		if min < 0 || max < min {
+			return 0, 0, fmt.Errorf("latency %q: want 0 <= min <= max", s)
+		}
+		return min, max, nil
+	}
+	switch name {
+	case "none":
+		return nil, nil
+	case "constant":
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			return nil, fmt.Errorf("latency %q: %w", s, err)
+		}
+		return sim.ConstantLatency[key.Key32](d), nil
+	case "uniform":
+		min, max, err := bounds()
+		if err != nil {
+			return nil, err
+		}
+		return sim.NewUniformLatency[key.Key32](min, max, seed), nil
+	case "distance":
+		min, max, err := bounds()
+		if err != nil {
+			return nil, err
+		}
+		return &sim.DistanceLatency[key.Key32]{Min: min, Max: max}, nil
+	default:
+		return nil, fmt.Errorf("unknown latency model %q", s)
+	}
+}
+
+func runSim(ctx context.Context, args []string) error {
+	var cfg simConfig
+	var asJSON bool
+	fs := newFlagSet("sim", "")
+	fs.IntVar(&cfg.nodes, "nodes", 100, "the number of nodes online at any time")
+	fs.IntVar(&cfg.degree, "degree", 8, "the number of nodes each node knows when it joins")
+	fs.IntVar(&cfg.lookups, "lookups", 100, "the number of lookups of random nodes")
+	fs.DurationVar(&cfg.interval, "interval", time.Second, "the virtual time between the start of two lookups")
+	fs.DurationVar(&cfg.session, "churn", 0, "the mean time nodes stay online before being replaced, zero disables churn")
+	fs.StringVar(&cfg.latency, "latency", "constant:50ms", "the latency model: none, constant:<d>, uniform:<min>-<max> or distance:<min>-<max>")
+	fs.Int64Var(&cfg.seed, "seed", 1, "seeds the random choices of the simulation")
+	fs.BoolVar(&asJSON, "json", false, "print the statistics of every lookup and node in JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	res, err := simulate(ctx, &cfg)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return res.stats.WriteJSON(os.Stdout)
+	}
+	s := res.summary
+	fmt.Printf("nodes: %d, lookups: %d done of %d\n", cfg.nodes, s.Queries, cfg.lookups)
+	fmt.Printf("virtual time: %s, stopped on %s after %d steps\n", res.run.Elapsed, res.run.Reason, res.run.Steps)
+	fmt.Printf("success rate: %.1f%% (%d/%d)\n", 100*s.SuccessRate, s.Succeeded, s.Queries)
+	fmt.Printf("mean hops: %.2f\n", s.MeanHops)
+	fmt.Printf("mean lookup duration: %s\n", res.duration)
+	fmt.Printf("messages: %d\n", s.Messages)
+	if cfg.session > 0 {
+		fmt.Printf("churn: %d killed, %d spawned, %d failed to spawn\n", res.churn.Killed, res.churn.Spawned, res.churn.Failed)
+	}
+	return nil
+}
+
+// simulate runs a simulation of a network of cfg.nodes nodes in which lookups of random nodes
+// are started from random nodes every cfg.interval, until all lookups are done.
+func simulate(ctx context.Context, cfg *simConfig) (*simResult, error) {
+	if cfg.nodes < 2 {
+		return nil, fmt.Errorf("nodes must be at least two")
+	}
+	if cfg.degree < 1 || cfg.lookups < 1 || cfg.interval < 0 || cfg.session < 0 {
+		return nil, fmt.Errorf("degree and lookups must be greater than zero, interval and churn must not be negative")
+	}
+	latency, err := parseLatency(cfg.latency, cfg.seed)
+	if err != nil {
+		return nil, err
+	}
+	rng := rand.New(rand.NewSource(cfg.seed))
+
+	// newInfo returns the info of a node with a random key no other node has
+	used := make(map[key.Key32]bool)
+	newInfo := func() kad.NodeInfo[key.Key32, net.IP] {
+		for {
+			k := key.Key32(rng.Uint32())
+			if !used[k] {
+				used[k] = true
+				return kadtest.NewInfo[key.Key32, net.IP](kadtest.NewID(k), nil)
+			}
+		}
+	}
+
+	infos := make([]kad.NodeInfo[key.Key32, net.IP], cfg.nodes)
+	for i := range infos {
+		infos[i] = newInfo()
+	}
+	ncfg := sim.DefaultNetworkConfig[key.Key32]()
+	ncfg.Topology = sim.RandomTopology(cfg.degree)
+	ncfg.Seed = cfg.seed
+	clk := clock.NewMock()
+	n, err := sim.NewNetwork(ctx, clk, infos, ncfg)
+	if err != nil {
+		return nil, err
+	}
+	if latency != nil {
+		n.Router.SetLatencyModel(latency)
+	}
+	c := sim.NewCollector[key.Key32, net.IP](clk)
+	n.Router.AddObserver(c)
+
+	// the nodes online, and the index of each in n.Nodes
+	online := append([]*sim.Node[key.Key32, net.IP](nil), n.Nodes...)
+	index := make(map[string]int, len(n.Nodes))
+	for i, node := range n.Nodes {
+		index[node.Info.ID().String()] = i
+	}
+
+	driver := event.NewSimpleScheduler(clk)
+	n.Simulator.Add(driver)
+
+	var churn *sim.Churn[key.Key32, net.IP]
+	if cfg.session > 0 {
+		spawn := func(ctx context.Context) (kad.NodeID[key.Key32], error) {
+			info := newInfo()
+			node := &sim.Node[key.Key32, net.IP]{
+				Info:         info,
+				Scheduler:    event.NewSliceScheduler(clk),
+				RoutingTable: ncfg.RoutingTable(info.ID()),
+			}
+			node.Endpoint = sim.NewEndpoint[key.Key32, net.IP](info.ID(), node.Scheduler, n.Router)
+			node.Server = sim.NewServer[key.Key32, net.IP](node.RoutingTable, node.Endpoint, ncfg.Server)
+			if err := node.Endpoint.AddRequestHandler(ncfg.ProtocolID, nil, node.Server.HandleRequest); err != nil {
+				return nil, err
+			}
+			n.Simulator.Add(node.Scheduler)
+			n.Nodes = append(n.Nodes, node)
+			i := len(n.Nodes) - 1
+			index[info.ID().String()] = i
+
+			// the node joins knowing some of the nodes online, which learn about it
+			known := rng.Perm(len(online))
+			if len(known) > cfg.degree {
+				known = known[:cfg.degree]
+			}
+			for _, j := range known {
+				other := index[online[j].Info.ID().String()]
+				if err := n.Connect(ctx, i, other, ncfg.PeerstoreTTL); err != nil {
+					return nil, err
+				}
+				if err := n.Connect(ctx, other, i, ncfg.PeerstoreTTL); err != nil {
+					return nil, err
+				}
+			}
+			online = append(online, node)
+			return info.ID(), nil
+		}
+		ccfg := sim.DefaultChurnConfig[key.Key32]()
+		ccfg.Session = sim.ExponentialSession(cfg.session)
+		ccfg.Seed = cfg.seed
+		ccfg.OnKill = func(ctx context.Context, id kad.NodeID[key.Key32]) {
+			node := n.Nodes[index[id.String()]]
+			n.Simulator.Remove(node.Scheduler)
+			for i, o := range online {
+				if o == node {
+					online = append(online[:i], online[i+1:]...)
+					break
+				}
+			}
+		}
+		churn, err = sim.NewChurn[key.Key32, net.IP](n.Router, driver, spawn, ccfg)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range n.Nodes {
+			churn.Track(ctx, node.Info.ID())
+		}
+	}
+
+	// lookup looks up a random node from another random node
+	launched := 0
+	lookup := func(ctx context.Context) {
+		name := fmt.Sprintf("lookup-%d", launched)
+		launched++
+		rec := c.TrackQuery(name)
+		if len(online) < 2 {
+			rec.NotifyFailure(nil)(ctx)
+			return
+		}
+		perm := rng.Perm(len(online))
+		from, target := online[perm[0]], online[perm[1]].Info.ID().Key()
+		handle := func(ctx context.Context, id kad.NodeID[key.Key32], resp kad.Response[key.Key32, net.IP]) (bool, []kad.NodeID[key.Key32]) {
+			ids := make([]kad.NodeID[key.Key32], 0, len(resp.CloserNodes()))
+			for _, p := range resp.CloserNodes() {
+				if key.Equal(p.ID().Key(), target) {
+					return true, nil
+				}
+				ids = append(ids, p.ID())
+			}
+			return false, ids
+		}
+		_, err := sq.NewSimpleQuery[key.Key32, net.IP](ctx, from.Info.ID(), sim.NewRequest[key.Key32, net.IP](target),
+			sq.WithProtocolID[key.Key32, net.IP](ncfg.ProtocolID),
+			sq.WithRequestTimeout[key.Key32, net.IP](10*time.Second),
+			sq.WithHandleResultsFunc(sq.HandleResultFn[key.Key32, net.IP](rec.HandleResults(handle))),
+			sq.WithNotifyFailureFunc[key.Key32, net.IP](rec.NotifyFailure(nil)),
+			sq.WithRoutingTable[key.Key32, net.IP](from.RoutingTable),
+			sq.WithEndpoint[key.Key32, net.IP](from.Endpoint),
+			sq.WithScheduler[key.Key32, net.IP](from.Scheduler))
+		if err != nil {
+			rec.NotifyFailure(nil)(ctx)
+		}
+	}
+	for i := 0; i < cfg.lookups; i++ {
+		event.ScheduleActionIn(ctx, driver, time.Duration(i)*cfg.interval, event.BasicAction(lookup))
+	}
+
+	rcfg := sim.DefaultRunnerConfig()
+	// lookups started from nodes killed before they are done never end
+	rcfg.TimeLimit = time.Duration(cfg.lookups)*cfg.interval + 10*time.Minute
+	rcfg.Stop = func(context.Context) bool {
+		return launched == cfg.lookups && c.Summary().Queries == launched
+	}
+	runner, err := sim.NewRunner(n.Simulator, rcfg)
+	if err != nil {
+		return nil, err
+	}
+	run, err := runner.Run(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &simResult{summary: c.Summary(), run: run, stats: c}
+	var total time.Duration
+	done := 0
+	for _, q := range c.Queries() {
+		if q.Done {
+			total += q.Duration
+			done++
+		}
+	}
+	if done > 0 {
+		res.duration = total / time.Duration(done)
+	}
+	if churn != nil {
+		res.churn = churn.Stats()
+	}
+	return res, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/sim"
+)
+
+func TestParseLatency(t *testing.T) {
+	l, err := parseLatency("none", 1)
+	require.NoError(t, err)
+	require.Nil(t, l)
+
+	l, err = parseLatency("constant:20ms", 1)
+	require.NoError(t, err)
+	require.Equal(t, sim.ConstantLatency[key.Key32](20*time.Millisecond), l)
+
+	l, err = parseLatency("uniform:10ms-20ms", 1)
+	require.NoError(t, err)
+	require.IsType(t, &sim.UniformLatency[key.Key32]{}, l)
+
+	l, err = parseLatency("distance:10ms-20ms", 1)
+	require.NoError(t, err)
+	require.Equal(t, &sim.DistanceLatency[key.Key32]{Min: 10 * time.Millisecond, Max: 20 * time.Millisecond}, l)
+
+	for _, s := range []string{"", "constant", "constant:x", "uniform:10ms", "uniform:20ms-10ms", "gaussian:1ms"} {
+		_, err := parseLatency(s, 1)
+		require.Error(t, err, s)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	ctx := context.Background()
+	cfg := &simConfig{
+		nodes:    50,
+		degree:   8,
+		lookups:  20,
+		interval: time.Second,
+		latency:  "constant:10ms",
+		seed:     1,
+	}
+	res, err := simulate(ctx, cfg)
+	require.NoError(t, err)
+	require.Equal(t, sim.StopCondition, res.run.Reason)
+	require.Equal(t, 20, res.summary.Queries)
+	require.Greater(t, res.summary.Succeeded, 0)
+	require.Greater(t, res.duration, time.Duration(0))
+
+	// with churn, nodes are replaced while the lookups run
+	cfg.session = 10 * time.Second
+	res, err = simulate(ctx, cfg)
+	require.NoError(t, err)
+	require.Greater(t, res.churn.Killed, uint64(0))
+	require.Equal(t, res.churn.Killed, res.churn.Spawned)
+
+	cfg.nodes = 1
+	_, err = simulate(ctx, cfg)
+	require.Error(t, err)
+}
@@ -4,18 +4,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/benbjohnson/clock"
+	"go.opentelemetry.io/otel/metric"
 
 	"github.com/plprobelab/go-kademlia/event"
 	"github.com/plprobelab/go-kademlia/kad"
 	"github.com/plprobelab/go-kademlia/kaderr"
 	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/logging"
 	"github.com/plprobelab/go-kademlia/network/address"
 	"github.com/plprobelab/go-kademlia/network/endpoint"
 	"github.com/plprobelab/go-kademlia/query"
 	"github.com/plprobelab/go-kademlia/routing"
+	"github.com/plprobelab/go-kademlia/routing/denylist"
 	"github.com/plprobelab/go-kademlia/util"
 )
 
@@ -80,16 +85,41 @@ type Coordinator[K kad.Key[K], A kad.Address[A]] struct {
 	// rt is the routing table used to look up nodes by distance
 	rt kad.RoutingTable[K, kad.NodeID[K]]
 
+	// sources are the node sources the candidates of accelerated queries are drawn from along
+	// with the routing table
+	sources []query.NodeSource[K]
+
 	// ep is the message endpoint used to send requests
 	ep endpoint.Endpoint[K, A]
 
-	// queue not used
+	// queue holds the actions enqueued with EnqueueAction and the overdue actions scheduled with ScheduleAction
 	queue event.EventQueue
 
-	// planner not used
+	// planner holds the actions scheduled with ScheduleAction until they are due
 	planner event.AwareActionPlanner
 
 	outboundEvents chan KademliaEvent
+
+	// dialFailures counts the consecutive failed dials to each node, keyed by node id
+	dialFailuresMu sync.Mutex
+	dialFailures   map[string]int
+
+	// pauses holds the queries to pause, true, or resume, false, requested with PauseQuery and
+	// ResumeQuery and not yet passed to the pool, keyed by query id
+	pausesMu sync.Mutex
+	pauses   map[query.QueryID]bool
+
+	// metrics records the queries once they finish
+	metrics *queryMetrics
+
+	// closerNodes optionally validates the closer nodes of responses before they are used
+	closerNodes *endpoint.CloserNodesPolicy[K, A]
+
+	log logging.Logger
+
+	// rtSize is the number of nodes in rt, updated when the coordinator adds or removes nodes and
+	// after each action it runs, or -1 if rt does not report its size
+	rtSize atomic.Int64
 }
 
 const DefaultChanqueueCapacity = 1024
@@ -104,6 +134,27 @@ type Config struct {
 
 	RequestConcurrency int           // the maximum number of concurrent requests that each query may have in flight
 	RequestTimeout     time.Duration // the timeout queries should use for contacting a single node
+
+	Quarantine *query.Quarantine      // an optional quarantine of unreachable nodes shared by all queries, nil disables quarantining
+	DenyList   *denylist.PeerDenyList // an optional list of banned nodes that queries must not contact, it should also be given to the routing table
+
+	// RequestTimeoutFunc optionally gives the timeout queries should use for contacting each node,
+	// such as endpoint.AdaptiveTimeout, overriding RequestTimeout.
+	RequestTimeoutFunc func(fmt.Stringer) time.Duration
+
+	// MaxDialFailures is the number of consecutive failed dials after which a node is removed
+	// from the routing table. Other failures, such as timeouts, do not remove nodes. Zero
+	// never removes nodes.
+	MaxDialFailures int
+
+	// Acceleration optionally resolves queries from the closest nodes of the routing table and of
+	// the sources set with SetAccelerationSources when they are close enough to the target, only
+	// contacting them to check that they are live instead of looking up the network, unless too
+	// few of them respond. See query.AccelerationConfig.
+	Acceleration *query.AccelerationConfig
+
+	MeterProvider metric.MeterProvider // an optional provider of the meter recording the metrics of the queries, nil records nothing
+	Logger        logging.Logger       // an optional logger the coordinator, its queries and its bootstrap emit events to, nil logs nothing
 }
 
 // Validate checks the configuration options and returns an error if any have invalid values.
@@ -141,17 +192,41 @@ func (cfg *Config) Validate() error {
 			Err:       fmt.Errorf("request timeout must be greater than zero"),
 		}
 	}
+
+	if cfg.MaxDialFailures < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "CoordinatorConfig",
+			Err:       fmt.Errorf("max dial failures must not be negative"),
+		}
+	}
+
+	if cfg.Acceleration != nil {
+		if err := cfg.Acceleration.Validate(); err != nil {
+			return &kaderr.ConfigurationError{
+				Component: "CoordinatorConfig",
+				Err:       err,
+			}
+		}
+	}
 	return nil
 }
 
 func DefaultConfig() *Config {
+	return ConfigFrom(kad.DefaultConfig())
+}
+
+// ConfigFrom returns the default configuration options for a Coordinator whose queries use the
+// concurrency and timeouts of kcfg. The PeerstoreTTL of kcfg is the time servers keep the nodes
+// they learn: the coordinator keeps the closer nodes of responses for 10 minutes regardless.
+func ConfigFrom(kcfg *kad.Config) *Config {
 	return &Config{
 		Clock:              clock.New(), // use standard time
 		PeerstoreTTL:       10 * time.Minute,
 		QueryConcurrency:   3,
-		QueryTimeout:       5 * time.Minute,
-		RequestConcurrency: 3,
-		RequestTimeout:     time.Minute,
+		QueryTimeout:       kcfg.QueryTimeout,
+		RequestConcurrency: kcfg.Concurrency,
+		RequestTimeout:     kcfg.RequestTimeout,
+		MaxDialFailures:    3,
 	}
 }
 
@@ -168,6 +243,11 @@ func NewCoordinator[K kad.Key[K], A kad.Address[A]](self kad.NodeID[K], ep endpo
 	qpCfg.Timeout = cfg.QueryTimeout
 	qpCfg.QueryConcurrency = cfg.RequestConcurrency
 	qpCfg.RequestTimeout = cfg.RequestTimeout
+	qpCfg.RequestTimeoutFunc = cfg.RequestTimeoutFunc
+	qpCfg.Quarantine = cfg.Quarantine
+	qpCfg.DenyList = cfg.DenyList
+	qpCfg.Logger = cfg.Logger
+	qpCfg.Acceleration = cfg.Acceleration
 
 	qp, err := query.NewPool[K, A](self, qpCfg)
 	if err != nil {
@@ -179,12 +259,21 @@ func NewCoordinator[K kad.Key[K], A kad.Address[A]](self kad.NodeID[K], ep endpo
 	bootstrapCfg.Timeout = cfg.QueryTimeout
 	bootstrapCfg.RequestConcurrency = cfg.RequestConcurrency
 	bootstrapCfg.RequestTimeout = cfg.RequestTimeout
+	bootstrapCfg.RequestTimeoutFunc = cfg.RequestTimeoutFunc
+	bootstrapCfg.Quarantine = cfg.Quarantine
+	bootstrapCfg.DenyList = cfg.DenyList
+	bootstrapCfg.Logger = cfg.Logger
 
 	bootstrap, err := routing.NewBootstrap(self, bootstrapCfg)
 	if err != nil {
 		return nil, fmt.Errorf("query pool: %w", err)
 	}
-	return &Coordinator[K, A]{
+
+	metrics, err := newQueryMetrics(cfg.MeterProvider)
+	if err != nil {
+		return nil, fmt.Errorf("metrics: %w", err)
+	}
+	c := &Coordinator[K, A]{
 		self:            self,
 		cfg:             *cfg,
 		ep:              ep,
@@ -196,7 +285,31 @@ func NewCoordinator[K kad.Key[K], A kad.Address[A]](self kad.NodeID[K], ep endpo
 		outboundEvents:  make(chan KademliaEvent, 20),
 		queue:           event.NewChanQueue(DefaultChanqueueCapacity),
 		planner:         event.NewSimplePlanner(cfg.Clock),
-	}, nil
+		dialFailures:    make(map[string]int),
+		pauses:          make(map[query.QueryID]bool),
+		metrics:         metrics,
+		log:             logging.OrNop(cfg.Logger),
+	}
+	c.rtSize.Store(-1)
+	c.updateRTSize()
+	return c, nil
+}
+
+// SetCloserNodesPolicy sets the policy validating the closer nodes of the responses received by
+// queries and bootstraps. Rejected closer nodes are neither added to the routing table nor
+// contacted, but the responses reported by KademliaOutboundQueryProgressedEvent are left
+// unchanged. A nil policy accepts all closer nodes. It must be set before the coordinator runs.
+func (c *Coordinator[K, A]) SetCloserNodesPolicy(p *endpoint.CloserNodesPolicy[K, A]) {
+	c.closerNodes = p
+}
+
+// SetAccelerationSources sets the node sources, besides the routing table, that the candidates of
+// accelerated queries are drawn from, such as the peerstore or a cache of recent lookups. They
+// are read from the goroutine driving the coordinator, so they must be safe for concurrent use
+// if they are also used elsewhere. They are only consulted when Config.Acceleration is set. It
+// must be called before the coordinator runs.
+func (c *Coordinator[K, A]) SetAccelerationSources(sources ...query.NodeSource[K]) {
+	c.sources = sources
 }
 
 func (c *Coordinator[K, A]) Events() <-chan KademliaEvent {
@@ -207,6 +320,16 @@ func (c *Coordinator[K, A]) RunOne(ctx context.Context) bool {
 	ctx, span := util.StartSpan(ctx, "Coordinator.RunOne")
 	defer span.End()
 
+	// Run the actions given to the coordinator before advancing the state machines, on the same
+	// goroutine, so that actions may safely use the endpoint the state machines send requests with
+	event.EnqueueMany(ctx, c.queue, c.planner.PopOverdueActions(ctx))
+	if a := c.queue.Dequeue(ctx); a != nil {
+		a.Run(ctx)
+		// the action may have changed the routing table, for example by sweeping it
+		c.updateRTSize()
+		return true
+	}
+
 	// Give the bootstrap state machine priority
 	// No queries can be run while a bootstrap is in progress
 	bev, ok := c.bootstrapEvents.Dequeue(ctx)
@@ -225,6 +348,14 @@ func (c *Coordinator[K, A]) RunOne(ctx context.Context) bool {
 		return false
 
 	case *routing.StateBootstrapFinished:
+		c.metrics.finished(ctx, kindBootstrap, st.Stats)
+		c.outboundEvents <- &KademliaBootstrapFinishedEvent{
+			Stats: st.Stats,
+		}
+		return true
+
+	case *routing.StateBootstrapTimeout:
+		c.metrics.finished(ctx, kindBootstrap, st.Stats)
 		c.outboundEvents <- &KademliaBootstrapFinishedEvent{
 			Stats: st.Stats,
 		}
@@ -237,8 +368,11 @@ func (c *Coordinator[K, A]) RunOne(ctx context.Context) bool {
 		panic(fmt.Sprintf("unexpected bootstrap state: %T", st))
 	}
 
-	// Attempt to advance an outbound query
-	pev, ok := c.poolEvents.Dequeue(ctx)
+	// Attempt to advance an outbound query, pausing or resuming queries first
+	pev, ok := c.nextPauseEvent()
+	if !ok {
+		pev, ok = c.poolEvents.Dequeue(ctx)
+	}
 	if !ok {
 		pev = &query.EventPoolPoll{}
 	}
@@ -253,6 +387,7 @@ func (c *Coordinator[K, A]) RunOne(ctx context.Context) bool {
 	case *query.StatePoolWaitingWithCapacity:
 		// TODO
 	case *query.StatePoolQueryFinished:
+		c.metrics.finished(ctx, kindQuery, st.Stats)
 		c.outboundEvents <- &KademliaOutboundQueryFinishedEvent{
 			QueryID: st.QueryID,
 			Stats:   st.Stats,
@@ -276,11 +411,8 @@ func (c *Coordinator[K, A]) sendQueryMessage(ctx context.Context, protoID addres
 	defer span.End()
 
 	onSendError := func(ctx context.Context, err error) {
-		if errors.Is(err, endpoint.ErrCannotConnect) {
-			// here we can notify that the peer is unroutable, which would feed into peerstore and routing table
-			// TODO: remove from routing table
-			return
-		}
+		c.recordFailure(to)
+		c.onDialResult(to, err)
 
 		qev := &query.EventPoolMessageFailure[K]{
 			NodeID:  to,
@@ -295,14 +427,22 @@ func (c *Coordinator[K, A]) sendQueryMessage(ctx context.Context, protoID addres
 			onSendError(ctx, err)
 			return
 		}
+		c.onDialResult(to, nil)
 
-		if resp != nil {
-			candidates := resp.CloserNodes()
+		closer := 0
+		filtered := resp
+		if c.closerNodes != nil {
+			filtered = c.closerNodes.FilterResponse(to, msg.Target(), resp)
+		}
+		if filtered != nil {
+			candidates := filtered.CloserNodes()
 			if len(candidates) > 0 {
 				// ignore error here
 				c.AddNodes(ctx, candidates)
 			}
+			closer = len(candidates)
 		}
+		c.recordResponse(to, closer)
 
 		// notify caller so they have chance to stop query
 		c.outboundEvents <- &KademliaOutboundQueryProgressedEvent[K, A]{
@@ -315,7 +455,7 @@ func (c *Coordinator[K, A]) sendQueryMessage(ctx context.Context, protoID addres
 		qev := &query.EventPoolMessageResponse[K, A]{
 			NodeID:   to,
 			QueryID:  queryID,
-			Response: resp,
+			Response: filtered,
 		}
 		c.poolEvents.Enqueue(ctx, qev)
 	}
@@ -331,11 +471,8 @@ func (c *Coordinator[K, A]) sendBootstrapMessage(ctx context.Context, protoID ad
 	defer span.End()
 
 	onSendError := func(ctx context.Context, err error) {
-		if errors.Is(err, endpoint.ErrCannotConnect) {
-			// here we can notify that the peer is unroutable, which would feed into peerstore and routing table
-			// TODO: remove from routing table
-			return
-		}
+		c.recordFailure(to)
+		c.onDialResult(to, err)
 
 		bev := &routing.EventBootstrapMessageFailure[K]{
 			NodeID: to,
@@ -349,14 +486,22 @@ func (c *Coordinator[K, A]) sendBootstrapMessage(ctx context.Context, protoID ad
 			onSendError(ctx, err)
 			return
 		}
+		c.onDialResult(to, nil)
 
-		if resp != nil {
-			candidates := resp.CloserNodes()
+		closer := 0
+		filtered := resp
+		if c.closerNodes != nil {
+			filtered = c.closerNodes.FilterResponse(to, msg.Target(), resp)
+		}
+		if filtered != nil {
+			candidates := filtered.CloserNodes()
 			if len(candidates) > 0 {
 				// ignore error here
 				c.AddNodes(ctx, candidates)
 			}
+			closer = len(candidates)
 		}
+		c.recordResponse(to, closer)
 
 		// notify caller so they have chance to stop query
 		c.outboundEvents <- &KademliaOutboundQueryProgressedEvent[K, A]{
@@ -368,7 +513,7 @@ func (c *Coordinator[K, A]) sendBootstrapMessage(ctx context.Context, protoID ad
 
 		bev := &routing.EventBootstrapMessageResponse[K, A]{
 			NodeID:   to,
-			Response: resp,
+			Response: filtered,
 		}
 		c.bootstrapEvents.Enqueue(ctx, bev)
 	}
@@ -379,8 +524,71 @@ func (c *Coordinator[K, A]) sendBootstrapMessage(ctx context.Context, protoID ad
 	}
 }
 
+// recordResponse records with the routing table, if it scores its nodes, that node answered a
+// request with the given number of closer nodes.
+func (c *Coordinator[K, A]) recordResponse(node kad.NodeID[K], closer int) {
+	if sc, ok := c.rt.(routing.Scorer[K]); ok {
+		sc.RecordResponse(node.Key(), closer)
+	}
+}
+
+// recordFailure records with the routing table, if it scores its nodes, that a request to node
+// failed.
+func (c *Coordinator[K, A]) recordFailure(node kad.NodeID[K]) {
+	if sc, ok := c.rt.(routing.Scorer[K]); ok {
+		sc.RecordFailure(node.Key())
+	}
+}
+
+// onDialResult counts the consecutive dial failures of a node after a request to it completed
+// with err, and removes the node from the routing table once it reaches cfg.MaxDialFailures.
+func (c *Coordinator[K, A]) onDialResult(node kad.NodeID[K], err error) {
+	c.dialFailuresMu.Lock()
+	if !errors.Is(err, endpoint.ErrDialFailure) {
+		// the node could be dialled
+		delete(c.dialFailures, node.String())
+		c.dialFailuresMu.Unlock()
+		return
+	}
+	c.dialFailures[node.String()]++
+	evict := c.cfg.MaxDialFailures > 0 && c.dialFailures[node.String()] >= c.cfg.MaxDialFailures
+	if evict {
+		delete(c.dialFailures, node.String())
+	}
+	c.dialFailuresMu.Unlock()
+
+	if evict {
+		c.rt.RemoveKey(node.Key())
+		c.updateRTSize()
+		c.log.Info("node removed after dial failures", logging.Stringer("node", node), logging.Int("failures", c.cfg.MaxDialFailures))
+	}
+}
+
+// updateRTSize updates the size of the routing table reported by RegisterStats, if the routing
+// table reports its size.
+func (c *Coordinator[K, A]) updateRTSize() {
+	if s, ok := c.rt.(interface{ Size() int }); ok {
+		c.rtSize.Store(int64(s.Size()))
+	}
+}
+
+// StartQuery starts a query for msg from the nodes of the routing table closest to its target,
+// or of the acceleration sources too if the query may be accelerated.
+// It reads the routing table, so it must be called from the goroutine driving the coordinator,
+// for example from an action given to EnqueueAction, unless the routing table is synchronized.
 func (c *Coordinator[K, A]) StartQuery(ctx context.Context, queryID query.QueryID, protocolID address.ProtocolID, msg kad.Request[K, A]) error {
-	knownClosestPeers := c.rt.NearestNodes(msg.Target(), 20)
+	n := 20
+	if c.cfg.Acceleration != nil && c.cfg.Acceleration.Candidates > n {
+		// enough nodes to accelerate the query
+		n = c.cfg.Acceleration.Candidates
+	}
+	var knownClosestPeers []kad.NodeID[K]
+	if c.cfg.Acceleration != nil && len(c.sources) > 0 {
+		// the closest nodes may be known by other sources than the routing table
+		knownClosestPeers = query.NearestFrom(msg.Target(), n, append([]query.NodeSource[K]{c.rt}, c.sources...)...)
+	} else {
+		knownClosestPeers = c.rt.NearestNodes(msg.Target(), n)
+	}
 
 	qev := &query.EventPoolAddQuery[K, A]{
 		QueryID:           queryID,
@@ -401,8 +609,42 @@ func (c *Coordinator[K, A]) StopQuery(ctx context.Context, queryID query.QueryID
 	return nil
 }
 
+// PauseQuery pauses the query queryID, which sends no more requests until ResumeQuery is called,
+// for example while the consumer of its events is behind. The responses to the requests in flight
+// are still reported by KademliaOutboundQueryProgressedEvent, but the query is only advanced with
+// them once resumed. Unlike StopQuery, it never blocks, so it may be called while receiving the
+// events of the coordinator.
+func (c *Coordinator[K, A]) PauseQuery(queryID query.QueryID) {
+	c.pausesMu.Lock()
+	defer c.pausesMu.Unlock()
+	c.pauses[queryID] = true
+}
+
+// ResumeQuery resumes the query queryID paused by PauseQuery. Like PauseQuery, it never blocks.
+func (c *Coordinator[K, A]) ResumeQuery(queryID query.QueryID) {
+	c.pausesMu.Lock()
+	defer c.pausesMu.Unlock()
+	c.pauses[queryID] = false
+}
+
+// nextPauseEvent returns the pool event pausing or resuming one of the queries given to
+// PauseQuery or ResumeQuery, or false if there are none.
+func (c *Coordinator[K, A]) nextPauseEvent() (query.PoolEvent, bool) {
+	c.pausesMu.Lock()
+	defer c.pausesMu.Unlock()
+	for id, pause := range c.pauses {
+		delete(c.pauses, id)
+		if pause {
+			return &query.EventPoolPauseQuery{QueryID: id}, true
+		}
+		return &query.EventPoolResumeQuery{QueryID: id}, true
+	}
+	return nil, false
+}
+
 // AddNodes suggests new DHT nodes and their associated addresses to be added to the routing table.
 // If the routing table is been updated as a result of this operation a KademliaRoutingUpdatedEvent event is emitted.
+// Like StartQuery, it must be called from the goroutine driving the coordinator.
 func (c *Coordinator[K, A]) AddNodes(ctx context.Context, infos []kad.NodeInfo[K, A]) error {
 	for _, info := range infos {
 		if key.Equal(info.ID().Key(), c.self.Key()) {
@@ -412,6 +654,8 @@ func (c *Coordinator[K, A]) AddNodes(ctx context.Context, infos []kad.NodeInfo[K
 		c.ep.MaybeAddToPeerstore(ctx, info, c.cfg.PeerstoreTTL)
 
 		if isNew {
+			c.updateRTSize()
+			c.log.Debug("node added", logging.Stringer("node", info.ID()))
 			c.outboundEvents <- &KademliaRoutingUpdatedEvent[K, A]{
 				NodeInfo: info,
 			}
@@ -421,6 +665,17 @@ func (c *Coordinator[K, A]) AddNodes(ctx context.Context, infos []kad.NodeInfo[K
 	return nil
 }
 
+// RemoveNode removes the node id from the routing table, returning false if the table did not
+// hold it.
+func (c *Coordinator[K, A]) RemoveNode(ctx context.Context, id kad.NodeID[K]) bool {
+	if !c.rt.RemoveKey(id.Key()) {
+		return false
+	}
+	c.updateRTSize()
+	c.log.Debug("node removed", logging.Stringer("node", id))
+	return true
+}
+
 // FindNodeRequestFunc is a function that creates a request to find the supplied node id
 // TODO: consider this being a first class method of the Endpoint
 type FindNodeRequestFunc[K kad.Key[K], A kad.Address[A]] func(kad.NodeID[K]) (address.ProtocolID, kad.Request[K, A])
@@ -491,10 +746,14 @@ func (c *Coordinator[K, A]) Clock() clock.Clock {
 	return c.cfg.Clock
 }
 
-func (c *Coordinator[K, A]) EnqueueAction(ctx context.Context, a event.Action) {
-	c.queue.Enqueue(ctx, a)
+// EnqueueAction adds a to the actions run by RunOne, before the state machines are advanced.
+func (c *Coordinator[K, A]) EnqueueAction(ctx context.Context, a event.Action) *event.CancellableAction {
+	ca := event.NewCancellableAction(a)
+	c.queue.Enqueue(ctx, ca)
+	return ca
 }
 
+// ScheduleAction schedules a to be run by RunOne once t has passed.
 func (c *Coordinator[K, A]) ScheduleAction(ctx context.Context, t time.Time, a event.Action) event.PlannedAction {
 	if c.cfg.Clock.Now().After(t) {
 		c.EnqueueAction(ctx, a)
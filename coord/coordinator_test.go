@@ -10,16 +10,19 @@ import (
 
 	"github.com/benbjohnson/clock"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/plprobelab/go-kademlia/event"
-	"github.com/plprobelab/go-kademlia/internal/kadtest"
 	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
 	"github.com/plprobelab/go-kademlia/key"
 	"github.com/plprobelab/go-kademlia/network/address"
 	"github.com/plprobelab/go-kademlia/network/endpoint"
 	"github.com/plprobelab/go-kademlia/query"
 	"github.com/plprobelab/go-kademlia/routing/simplert"
+	"github.com/plprobelab/go-kademlia/routing/triert"
 	"github.com/plprobelab/go-kademlia/sim"
+	"github.com/plprobelab/go-kademlia/util"
 )
 
 func setupSimulation(t *testing.T, ctx context.Context) ([]kad.NodeInfo[key.Key8, kadtest.StrAddr], []*sim.Endpoint[key.Key8, kadtest.StrAddr], []kad.RoutingTable[key.Key8, kad.NodeID[key.Key8]], *sim.LiteSimulator) {
@@ -123,6 +126,16 @@ func expectEventType(t *testing.T, ctx context.Context, events <-chan KademliaEv
 	}
 }
 
+func TestConfigFrom(t *testing.T) {
+	kcfg := kad.DefaultConfig()
+	kcfg.PeerstoreTTL = time.Hour
+
+	// the peerstore ttl of kcfg is that of servers, the coordinator keeps its default
+	cfg := ConfigFrom(kcfg)
+	require.Equal(t, 10*time.Minute, cfg.PeerstoreTTL)
+	require.Equal(t, 10*time.Minute, DefaultConfig().PeerstoreTTL)
+}
+
 func TestConfigValidate(t *testing.T) {
 	t.Run("default is valid", func(t *testing.T) {
 		cfg := DefaultConfig()
@@ -166,6 +179,48 @@ func TestConfigValidate(t *testing.T) {
 		cfg.RequestTimeout = -1
 		require.Error(t, cfg.Validate())
 	})
+
+	t.Run("max dial failures not negative", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.MaxDialFailures = 0
+		require.NoError(t, cfg.Validate())
+		cfg.MaxDialFailures = -1
+		require.Error(t, cfg.Validate())
+	})
+}
+
+func TestRemoveNodeAfterDialFailures(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	nodes, eps, rts, siml := setupSimulation(t, ctx)
+
+	ccfg := DefaultConfig()
+	ccfg.Clock = siml.Clock()
+	ccfg.MaxDialFailures = 2
+	logger := kadtest.NewLogger()
+	ccfg.Logger = logger
+
+	c, err := NewCoordinator[key.Key8, kadtest.StrAddr](nodes[0].ID(), eps[0], rts[0], ccfg)
+	require.NoError(t, err)
+
+	b := nodes[1].ID()
+	require.Contains(t, rts[0].NearestNodes(b.Key(), 10), b)
+
+	// other failures and successful dials do not count towards removal
+	c.onDialResult(b, endpoint.ErrDialBackoff)
+	c.onDialResult(b, endpoint.ErrTimeout)
+	c.onDialResult(b, endpoint.ErrDialBackoff)
+	c.onDialResult(b, nil)
+	c.onDialResult(b, endpoint.ErrCannotConnect)
+	require.Contains(t, rts[0].NearestNodes(b.Key(), 10), b)
+
+	// the node is removed after consecutive dial failures
+	c.onDialResult(b, endpoint.ErrDialBackoff)
+	require.NotContains(t, rts[0].NearestNodes(b.Key(), 10), b)
+	removed := logger.Entries("node removed after dial failures")
+	require.Len(t, removed, 1)
+	require.Equal(t, b.String(), removed[0].Fields["node"])
 }
 
 func TestExhaustiveQuery(t *testing.T) {
@@ -309,6 +364,120 @@ func TestRoutingUpdatedEventEmittedForCloserNodes(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestCloserNodesPolicy(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	nodes, eps, rts, siml := setupSimulation(t, ctx)
+
+	clk := siml.Clock()
+
+	ccfg := DefaultConfig()
+	ccfg.Clock = clk
+	ccfg.PeerstoreTTL = peerstoreTTL
+
+	go func(ctx context.Context) {
+		for {
+			select {
+			case <-time.After(10 * time.Millisecond):
+				siml.Run(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}(ctx)
+
+	self := nodes[0].ID()
+	c, err := NewCoordinator[key.Key8, kadtest.StrAddr](self, eps[0], rts[0], ccfg)
+	require.NoError(t, err)
+
+	// the simulated nodes have no address, so all the closer nodes are unroutable
+	reported := make(chan kad.NodeID[key.Key8], 1)
+	policy := endpoint.NewCloserNodesPolicy[key.Key8, kadtest.StrAddr](self, &endpoint.CloserNodesConfig[key.Key8, kadtest.StrAddr]{
+		Routable: func(kadtest.StrAddr) bool { return true },
+		Report:   func(id kad.NodeID[key.Key8]) { reported <- id },
+	})
+	c.SetCloserNodesPolicy(policy)
+	siml.Add(c)
+	events := c.Events()
+
+	queryID := query.QueryID("query1")
+
+	// A (ids[0]) is looking for D (ids[3]), B replies with C's address
+	err = c.StartQuery(ctx, queryID, protoID, sim.NewRequest[key.Key8, kadtest.StrAddr](nodes[3].ID().Key()))
+	require.NoError(t, err)
+
+	// the progressed event holds the original response
+	ev, err := expectEventType(t, ctx, events, &KademliaOutboundQueryProgressedEvent[key.Key8, kadtest.StrAddr]{})
+	require.NoError(t, err)
+	tev := ev.(*KademliaOutboundQueryProgressedEvent[key.Key8, kadtest.StrAddr])
+	require.Equal(t, nodes[1].ID(), tev.NodeID)
+	require.Len(t, tev.Response.CloserNodes(), 1)
+
+	// the query ends without asking C, since B's response has no valid closer node
+	ev, err = expectEventType(t, ctx, events, &KademliaOutboundQueryFinishedEvent{})
+	require.NoError(t, err)
+	tevf := ev.(*KademliaOutboundQueryFinishedEvent)
+	require.Equal(t, queryID, tevf.QueryID)
+	require.Equal(t, 1, tevf.Stats.Requests)
+
+	require.Equal(t, nodes[1].ID(), <-reported)
+	require.Equal(t, endpoint.CloserNodesStats{Unroutable: 1}, policy.Rejected(nodes[1].ID()))
+}
+
+func TestQueryRecordsNodeStats(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	nodes, eps, _, siml := setupSimulation(t, ctx)
+
+	ccfg := DefaultConfig()
+	ccfg.Clock = siml.Clock()
+	ccfg.PeerstoreTTL = peerstoreTTL
+
+	go func(ctx context.Context) {
+		for {
+			select {
+			case <-time.After(10 * time.Millisecond):
+				siml.Run(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}(ctx)
+
+	// the coordinator of A uses a table scoring its nodes, which knows B and an unreachable node
+	rt, err := triert.New[key.Key8, kad.NodeID[key.Key8]](nodes[0].ID(), nil)
+	require.NoError(t, err)
+	unreachable := kadtest.NewID(key.Key8(0x04))
+	require.True(t, rt.AddNode(nodes[1].ID()))
+	require.True(t, rt.AddNode(unreachable))
+
+	c, err := NewCoordinator[key.Key8, kadtest.StrAddr](nodes[0].ID(), eps[0], rt, ccfg)
+	require.NoError(t, err)
+	siml.Add(c)
+
+	err = c.StartQuery(ctx, "query1", protoID, sim.NewRequest[key.Key8, kadtest.StrAddr](nodes[3].ID().Key()))
+	require.NoError(t, err)
+	_, err = expectEventType(t, ctx, c.Events(), &KademliaOutboundQueryFinishedEvent{})
+	require.NoError(t, err)
+
+	// B, C and D answered, B with C as closer node
+	for _, n := range nodes[1:] {
+		st, found := rt.NodeStats(n.ID().Key())
+		require.True(t, found)
+		require.Equal(t, 1, st.Answered)
+		require.Zero(t, st.Failures)
+	}
+	st, _ := rt.NodeStats(nodes[1].ID().Key())
+	require.Equal(t, 1, st.Closer)
+
+	st, found := rt.NodeStats(unreachable.Key())
+	require.True(t, found)
+	require.Equal(t, 1, st.Failures)
+	require.Zero(t, st.Answered)
+}
+
 var findNodeFn = func(n kad.NodeID[key.Key8]) (address.ProtocolID, kad.Request[key.Key8, kadtest.StrAddr]) {
 	return protoID, sim.NewRequest[key.Key8, kadtest.StrAddr](n.Key())
 }
@@ -387,4 +556,112 @@ func TestBootstrap(t *testing.T) {
 	require.Equal(t, 3, tevf.Stats.Requests)
 	require.Equal(t, 3, tevf.Stats.Success)
 	require.Equal(t, 0, tevf.Stats.Failure)
+
+	// queries run once the bootstrap has finished
+	err = c.StartQuery(ctx, "query1", protoID, sim.NewRequest[key.Key8, kadtest.StrAddr](nodes[3].ID().Key()))
+	require.NoError(t, err)
+
+	ev, err = expectEventType(t, ctx, events, &KademliaOutboundQueryFinishedEvent{})
+	require.NoError(t, err)
+	require.Equal(t, query.QueryID("query1"), ev.(*KademliaOutboundQueryFinishedEvent).QueryID)
+}
+
+func TestCoordinatorRunsActions(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	nodes, eps, rts, siml := setupSimulation(t, ctx)
+
+	clk := siml.Clock()
+	ccfg := DefaultConfig()
+	ccfg.Clock = clk
+
+	c, err := NewCoordinator[key.Key8, kadtest.StrAddr](nodes[0].ID(), eps[0], rts[0], ccfg)
+	require.NoError(t, err)
+
+	var ran []int
+	c.EnqueueAction(ctx, event.BasicAction(func(context.Context) { ran = append(ran, 1) }))
+	c.ScheduleAction(ctx, clk.Now().Add(time.Minute), event.BasicAction(func(context.Context) { ran = append(ran, 2) }))
+
+	// the enqueued action runs first, the scheduled one only once it is due
+	require.True(t, c.RunOne(ctx))
+	require.Equal(t, []int{1}, ran)
+	c.RunOne(ctx)
+	require.Equal(t, []int{1}, ran)
+
+	clk.Add(time.Minute)
+	require.True(t, c.RunOne(ctx))
+	require.Equal(t, []int{1, 2}, ran)
+}
+
+func TestQueryMetrics(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	nodes, eps, rts, siml := setupSimulation(t, ctx)
+
+	mp := kadtest.NewMeterProvider()
+	ccfg := DefaultConfig()
+	ccfg.Clock = siml.Clock()
+	ccfg.PeerstoreTTL = peerstoreTTL
+	ccfg.MeterProvider = mp
+
+	go func(ctx context.Context) {
+		for {
+			select {
+			case <-time.After(10 * time.Millisecond):
+				siml.Run(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}(ctx)
+
+	c, err := NewCoordinator[key.Key8, kadtest.StrAddr](nodes[0].ID(), eps[0], rts[0], ccfg)
+	require.NoError(t, err)
+	siml.Add(c)
+
+	reg, err := RegisterStats(util.Meter(mp), c, attribute.String("node", "a"))
+	require.NoError(t, err)
+	defer reg.Unregister()
+
+	// the routing table of A only holds B
+	require.NoError(t, mp.Collect(ctx))
+	require.Equal(t, 1.0, mp.Sum("coordinator.rt.size", attribute.String("node", "a")))
+	require.Equal(t, 1, mp.Count("coordinator.queue.depth"))
+
+	err = c.StartQuery(ctx, "query1", protoID, sim.NewRequest[key.Key8, kadtest.StrAddr](nodes[3].ID().Key()))
+	require.NoError(t, err)
+	_, err = expectEventType(t, ctx, c.Events(), &KademliaOutboundQueryFinishedEvent{})
+	require.NoError(t, err)
+
+	kind := attribute.String("kind", "query")
+	require.Equal(t, 1, mp.Count("query.hops", kind))
+	require.Equal(t, 3.0, mp.Sum("query.hops", kind))
+	require.Equal(t, 1, mp.Count("query.duration", kind))
+	require.Zero(t, mp.Count("query.failures"))
+
+	// the query added the closer nodes it found to the routing table of A
+	size := rts[0].(*simplert.SimpleRT[key.Key8, kad.NodeID[key.Key8]]).Size()
+	require.Greater(t, size, 1)
+	require.NoError(t, mp.Collect(ctx))
+	require.Equal(t, 2, mp.Count("coordinator.rt.size"))
+	require.Equal(t, float64(1+size), mp.Sum("coordinator.rt.size"))
+
+	// the size is also updated when the routing table is changed by an action, such as a sweep
+	done := make(chan struct{})
+	c.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
+		rts[0].RemoveKey(nodes[1].ID().Key())
+	}))
+	c.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
+		// the size is updated once the previous action ran
+		close(done)
+	}))
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal("action did not run")
+	}
+	require.NoError(t, mp.Collect(ctx))
+	require.Equal(t, float64(1+size+size-1), mp.Sum("coordinator.rt.size"))
 }
@@ -0,0 +1,94 @@
+package coord
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/query"
+	"github.com/plprobelab/go-kademlia/util"
+)
+
+const (
+	kindQuery     = "query"
+	kindBootstrap = "bootstrap"
+)
+
+// queryMetrics records the queries and bootstraps run by a Coordinator once they finish.
+type queryMetrics struct {
+	duration metric.Float64Histogram
+	hops     metric.Int64Histogram
+	failures metric.Int64Counter
+}
+
+func newQueryMetrics(mp metric.MeterProvider) (*queryMetrics, error) {
+	meter := util.Meter(mp)
+	duration, err := meter.Float64Histogram("query.duration",
+		metric.WithDescription("Time queries took to finish"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+
+	hops, err := meter.Int64Histogram("query.hops",
+		metric.WithDescription("Number of hops of the longest chain of responses followed by each query"))
+	if err != nil {
+		return nil, err
+	}
+
+	failures, err := meter.Int64Counter("query.failures",
+		metric.WithDescription("Number of requests of queries that failed"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &queryMetrics{
+		duration: duration,
+		hops:     hops,
+		failures: failures,
+	}, nil
+}
+
+// finished records a query of the given kind, query or bootstrap, that finished with stats.
+func (m *queryMetrics) finished(ctx context.Context, kind string, stats query.QueryStats) {
+	set := metric.WithAttributes(attribute.String("kind", kind))
+	if !stats.Start.IsZero() && !stats.End.IsZero() {
+		m.duration.Record(ctx, stats.End.Sub(stats.Start).Seconds(), set)
+	}
+	m.hops.Record(ctx, int64(stats.Hops), set)
+	if stats.Failure > 0 {
+		m.failures.Add(ctx, int64(stats.Failure), set)
+	}
+}
+
+// RegisterStats creates observable instruments on meter that report the number of actions queued
+// on c and the number of nodes in its routing table each time metrics are collected. Since
+// routing tables may not be read concurrently, the size of the routing table is the one last seen
+// by c, which reads it each time it changes the table or runs an action, and it is not reported
+// for routing tables without a Size method. The attributes are added to every observation, for
+// example to distinguish several coordinators. Unregister the returned registration to stop
+// reporting.
+func RegisterStats[K kad.Key[K], A kad.Address[A]](meter metric.Meter, c *Coordinator[K, A], attrs ...attribute.KeyValue) (metric.Registration, error) {
+	depth, err := meter.Int64ObservableGauge("coordinator.queue.depth",
+		metric.WithDescription("Number of actions waiting to run on the coordinator"))
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := meter.Int64ObservableGauge("coordinator.rt.size",
+		metric.WithDescription("Number of nodes in the routing table of the coordinator"))
+	if err != nil {
+		return nil, err
+	}
+
+	set := metric.WithAttributes(attrs...)
+	return meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		o.ObserveInt64(depth, int64(c.queue.Size()), set)
+		if n := c.rtSize.Load(); n >= 0 {
+			o.ObserveInt64(size, n, set)
+		}
+		return nil
+	}, depth, size)
+}
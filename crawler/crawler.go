@@ -0,0 +1,331 @@
+// Package crawler maps a network by asking each of its nodes for the content of its routing
+// table, for use by measurement tools built on the library.
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+// ErrStarted is returned by Crawler.Start and Crawler.Crawl when the crawler was already started.
+var ErrStarted = errors.New("crawl already started")
+
+// Config specifies optional configuration for a Crawler
+type Config struct {
+	Concurrency     int           // the maximum number of nodes crawled at once
+	MaxCpl          int           // the largest common prefix length of the buckets each node is asked for, cpls beyond the key length are skipped
+	MaxPeers        int           // the maximum number of nodes crawled, zero crawls every node found
+	PeerInterval    time.Duration // the minimum time between two requests to the same node
+	RequestTimeout  time.Duration // the timeout of each request
+	ThrottleBackoff time.Duration // the time after which a request a node throttled is sent again
+	MaxThrottled    int           // the number of times a request may be throttled before the node is given up
+	PeerstoreTTL    time.Duration // the time the addresses of the nodes found are kept in the peerstore
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *Config) Validate() error {
+	if cfg.Concurrency < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "CrawlerConfig",
+			Err:       fmt.Errorf("concurrency must be greater than zero"),
+		}
+	}
+	if cfg.MaxCpl < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "CrawlerConfig",
+			Err:       fmt.Errorf("max cpl must not be negative"),
+		}
+	}
+	if cfg.MaxPeers < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "CrawlerConfig",
+			Err:       fmt.Errorf("max peers must not be negative"),
+		}
+	}
+	if cfg.PeerInterval < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "CrawlerConfig",
+			Err:       fmt.Errorf("peer interval must not be negative"),
+		}
+	}
+	if cfg.RequestTimeout < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "CrawlerConfig",
+			Err:       fmt.Errorf("request timeout must be greater than zero"),
+		}
+	}
+	if cfg.ThrottleBackoff < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "CrawlerConfig",
+			Err:       fmt.Errorf("throttle backoff must be greater than zero"),
+		}
+	}
+	if cfg.MaxThrottled < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "CrawlerConfig",
+			Err:       fmt.Errorf("max throttled must not be negative"),
+		}
+	}
+	if cfg.PeerstoreTTL < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "CrawlerConfig",
+			Err:       fmt.Errorf("peerstore ttl must be greater than zero"),
+		}
+	}
+	return nil
+}
+
+// DefaultConfig returns the default configuration options for a Crawler.
+// Options may be overridden before passing to New
+func DefaultConfig() *Config {
+	return ConfigFrom(kad.DefaultConfig())
+}
+
+// ConfigFrom returns the default configuration options for a Crawler using the request timeout
+// and peerstore ttl of kcfg.
+func ConfigFrom(kcfg *kad.Config) *Config {
+	return &Config{
+		Concurrency:     100,
+		MaxCpl:          15,
+		PeerInterval:    100 * time.Millisecond,
+		RequestTimeout:  kcfg.RequestTimeout,
+		ThrottleBackoff: 5 * time.Second,
+		MaxThrottled:    3,
+		PeerstoreTTL:    kcfg.PeerstoreTTL,
+	}
+}
+
+// RequestFunc returns the request asking the node id for the nodes of its bucket cpl, the nodes
+// whose keys share cpl leading bits with the key of id.
+type RequestFunc[K kad.Key[K], A kad.Address[A]] func(id kad.NodeID[K], cpl int) (kad.Request[K, A], error)
+
+// TargetRequest returns a RequestFunc asking for the nodes closest to a random key of the bucket,
+// created by newRequest. It suits protocols whose requests carry the target key itself, rather
+// than a preimage of it. rng must not be used concurrently by other code.
+func TargetRequest[K kad.Key[K], A kad.Address[A]](newRequest func(target K) kad.Request[K, A], rng *rand.Rand) RequestFunc[K, A] {
+	return func(id kad.NodeID[K], cpl int) (kad.Request[K, A], error) {
+		target, err := key.RandomWithCommonPrefix(id.Key(), cpl, rng)
+		if err != nil {
+			return nil, err
+		}
+		return newRequest(target), nil
+	}
+}
+
+// A Crawler performs a breadth-first crawl of a network. Starting from seed nodes, such as the
+// nodes of a routing table, it asks each node for the nodes of each of its buckets up to
+// Config.MaxCpl, one bucket after the other, and crawls in turn the nodes it had not found
+// before. The crawl ends once every node found was crawled, or Config.MaxPeers nodes were, and
+// results in a Graph of the nodes and the neighbours they returned.
+//
+// The requests to a node are spaced by Config.PeerInterval. A request throttled by the node is
+// sent again after Config.ThrottleBackoff, and a node failing a request for another reason is not
+// asked for its remaining buckets. The crawler sends its requests with an endpoint and runs its
+// actions on a scheduler, which the caller must keep running until the crawl is done.
+type Crawler[K kad.Key[K], A kad.Address[A]] struct {
+	self    kad.NodeID[K]
+	ep      endpoint.Endpoint[K, A]
+	sched   event.Scheduler
+	protoID address.ProtocolID
+	request RequestFunc[K, A]
+	cfg     Config
+	started atomic.Bool
+	ctx     context.Context // the context of the crawl, cancelling it stops the crawl
+	done    chan struct{}
+
+	// the following fields are only accessed by the actions of the crawler, run by the scheduler
+	graph   *Graph[K, A]
+	queue   []*Peer[K, A] // the nodes found and waiting to be crawled, in the order they were found
+	crawled int           // the number of nodes crawled or being crawled
+	active  int           // the number of nodes being crawled
+}
+
+// New creates a new Crawler sending requests for the protocol protoID, built by request, with ep
+// on behalf of the node self. Its actions are run by sched. If cfg is nil, the default config is
+// used.
+func New[K kad.Key[K], A kad.Address[A]](self kad.NodeID[K], ep endpoint.Endpoint[K, A], sched event.Scheduler,
+	protoID address.ProtocolID, request RequestFunc[K, A], cfg *Config,
+) (*Crawler[K, A], error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if request == nil {
+		return nil, fmt.Errorf("request function must not be nil")
+	}
+
+	return &Crawler[K, A]{
+		self:    self,
+		ep:      ep,
+		sched:   sched,
+		protoID: protoID,
+		request: request,
+		cfg:     *cfg,
+		graph:   newGraph[K, A](),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// Start starts crawling the network from seeds, enqueuing the first requests on the scheduler of
+// the crawler. The crawl is stopped early when ctx is cancelled. It returns ErrStarted if the
+// crawler was already started.
+func (c *Crawler[K, A]) Start(ctx context.Context, seeds []kad.NodeID[K]) error {
+	if !c.started.CompareAndSwap(false, true) {
+		return ErrStarted
+	}
+	c.ctx = ctx
+	c.sched.EnqueueAction(ctx, event.BasicAction(func(context.Context) {
+		for _, id := range seeds {
+			c.found(id, nil, 0)
+		}
+		c.dispatch()
+	}))
+	return nil
+}
+
+// Done returns a channel that is closed once the crawl is done.
+func (c *Crawler[K, A]) Done() <-chan struct{} {
+	return c.done
+}
+
+// Graph returns the graph of the network found by the crawl. It must not be called before the
+// channel returned by Done is closed.
+func (c *Crawler[K, A]) Graph() *Graph[K, A] {
+	return c.graph
+}
+
+// Crawl crawls the network from seeds and returns its graph once the crawl is done, which
+// requires the scheduler to be run by another goroutine. If ctx is cancelled first, it returns
+// the error of ctx. The crawl then stops once the requests in flight end, and Graph returns the
+// nodes crawled so far once the channel returned by Done is closed.
+func (c *Crawler[K, A]) Crawl(ctx context.Context, seeds []kad.NodeID[K]) (*Graph[K, A], error) {
+	if err := c.Start(ctx, seeds); err != nil {
+		return nil, err
+	}
+	select {
+	case <-c.done:
+		return c.graph, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// found records the node id, found at the given depth with the addresses of info, or a seed if
+// info is nil, and queues it for crawling unless it is already known. It returns nil for the
+// node of the crawler itself.
+func (c *Crawler[K, A]) found(id kad.NodeID[K], info kad.NodeInfo[K, A], depth int) *Peer[K, A] {
+	if key.Equal(id.Key(), c.self.Key()) {
+		return nil
+	}
+	if p := c.graph.Peer(id); p != nil {
+		if p.Addrs == nil && info != nil {
+			p.Addrs = info.Addresses()
+		}
+		return p
+	}
+	p := &Peer[K, A]{ID: id, Depth: depth}
+	if info != nil {
+		p.Addrs = info.Addresses()
+	} else if info, err := c.ep.NetworkAddress(id); err == nil {
+		p.Addrs = info.Addresses()
+	}
+	c.graph.add(p)
+	c.queue = append(c.queue, p)
+	return p
+}
+
+// dispatch starts crawling the queued nodes as long as the concurrency allows it, and ends the
+// crawl once no node is left to crawl.
+func (c *Crawler[K, A]) dispatch() {
+	if c.ctx.Err() != nil {
+		// the crawl was cancelled, crawl no more nodes
+		c.queue = nil
+	}
+	for len(c.queue) > 0 && c.active < c.cfg.Concurrency && (c.cfg.MaxPeers == 0 || c.crawled < c.cfg.MaxPeers) {
+		p := c.queue[0]
+		c.queue = c.queue[1:]
+		c.crawled++
+		c.active++
+		c.send(p, 0, 0)
+	}
+	if c.active == 0 && (len(c.queue) == 0 || c.crawled == c.cfg.MaxPeers) {
+		select {
+		case <-c.done:
+		default:
+			close(c.done)
+		}
+	}
+}
+
+// send sends p the request for its bucket cpl, throttled being the number of times the request
+// was throttled.
+func (c *Crawler[K, A]) send(p *Peer[K, A], cpl, throttled int) {
+	if err := c.ctx.Err(); err != nil {
+		c.finish(p, err)
+		return
+	}
+	req, err := c.request(p.ID, cpl)
+	if err != nil {
+		c.finish(p, err)
+		return
+	}
+	p.Requests++
+	err = c.ep.SendRequestHandleResponse(c.ctx, c.protoID, p.ID, req, req.EmptyResponse(), c.cfg.RequestTimeout,
+		func(_ context.Context, resp kad.Response[K, A], err error) {
+			c.handle(p, cpl, throttled, resp, err)
+		})
+	if err != nil {
+		c.finish(p, err)
+	}
+}
+
+// handle handles the response of p to the request for its bucket cpl.
+func (c *Crawler[K, A]) handle(p *Peer[K, A], cpl, throttled int, resp kad.Response[K, A], err error) {
+	if errors.Is(err, endpoint.ErrThrottled) && throttled < c.cfg.MaxThrottled {
+		event.ScheduleActionIn(c.ctx, c.sched, c.cfg.ThrottleBackoff, event.BasicAction(func(context.Context) {
+			c.send(p, cpl, throttled+1)
+		}))
+		return
+	}
+	if err != nil {
+		c.finish(p, err)
+		return
+	}
+
+	p.Responses++
+	for _, info := range resp.CloserNodes() {
+		// a node whose addresses the peerstore rejects is still part of the graph
+		_ = c.ep.MaybeAddToPeerstore(c.ctx, info, c.cfg.PeerstoreTTL)
+		c.found(info.ID(), info, p.Depth+1)
+		p.addNeighbour(info.ID())
+	}
+	c.dispatch()
+
+	if cpl >= c.cfg.MaxCpl || cpl+1 >= p.ID.Key().BitLen() {
+		c.finish(p, nil)
+		return
+	}
+	event.ScheduleActionIn(c.ctx, c.sched, c.cfg.PeerInterval, event.BasicAction(func(context.Context) {
+		c.send(p, cpl+1, 0)
+	}))
+}
+
+// finish ends the crawl of p, err being the error of the request that ended it if any.
+func (c *Crawler[K, A]) finish(p *Peer[K, A], err error) {
+	p.Crawled = true
+	p.Err = err
+	c.active--
+	c.dispatch()
+}
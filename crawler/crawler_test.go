@@ -0,0 +1,211 @@
+package crawler
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+	"github.com/plprobelab/go-kademlia/sim"
+)
+
+func TestConfigValidate(t *testing.T) {
+	require.NoError(t, DefaultConfig().Validate())
+
+	kcfg := kad.DefaultConfig()
+	kcfg.RequestTimeout = time.Second
+	require.Equal(t, time.Second, ConfigFrom(kcfg).RequestTimeout)
+
+	for name, mutate := range map[string]func(*Config){
+		"concurrency":      func(c *Config) { c.Concurrency = 0 },
+		"max cpl":          func(c *Config) { c.MaxCpl = -1 },
+		"max peers":        func(c *Config) { c.MaxPeers = -1 },
+		"peer interval":    func(c *Config) { c.PeerInterval = -1 },
+		"request timeout":  func(c *Config) { c.RequestTimeout = 0 },
+		"throttle backoff": func(c *Config) { c.ThrottleBackoff = 0 },
+		"max throttled":    func(c *Config) { c.MaxThrottled = -1 },
+		"peerstore ttl":    func(c *Config) { c.PeerstoreTTL = 0 },
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			mutate(cfg)
+			require.Error(t, cfg.Validate())
+		})
+	}
+}
+
+// newTestNetwork returns a simulated network of 16 nodes with keys i*0x10 knowing a few random
+// others, and a crawler on the first node with cfg.
+func newTestNetwork(t *testing.T, cfg *Config) (*sim.Network[key.Key8, net.IP], *Crawler[key.Key8, net.IP]) {
+	t.Helper()
+	ctx := context.Background()
+
+	infos := make([]kad.NodeInfo[key.Key8, net.IP], 16)
+	for i := range infos {
+		infos[i] = kadtest.NewInfo[key.Key8, net.IP](kadtest.NewID(key.Key8(i*0x10)), nil)
+	}
+	ncfg := sim.DefaultNetworkConfig[key.Key8]()
+	ncfg.Topology = sim.RandomTopology(3)
+	ncfg.Seed = 1
+	ncfg.Server = sim.DefaultServerConfig()
+	ncfg.Server.NumberUsefulCloserPeers = 20
+	n, err := sim.NewNetwork(ctx, clock.NewMock(), infos, ncfg)
+	require.NoError(t, err)
+
+	self := n.Nodes[0]
+	c, err := New[key.Key8, net.IP](self.Info.ID(), self.Endpoint, self.Scheduler, ncfg.ProtocolID,
+		TargetRequest[key.Key8, net.IP](func(target key.Key8) kad.Request[key.Key8, net.IP] {
+			return sim.NewRequest[key.Key8, net.IP](target)
+		}, rand.New(rand.NewSource(1))), cfg)
+	require.NoError(t, err)
+	return n, c
+}
+
+// crawl runs the crawl of c from the nodes known by the first node of n to its end.
+func crawl(t *testing.T, n *sim.Network[key.Key8, net.IP], c *Crawler[key.Key8, net.IP]) *Graph[key.Key8, net.IP] {
+	t.Helper()
+	ctx := context.Background()
+	require.NoError(t, c.Start(ctx, n.Nodes[0].RoutingTable.NearestNodes(key.Key8(0), 100)))
+	n.Simulator.Run(ctx)
+	select {
+	case <-c.Done():
+	default:
+		t.Fatal("crawl not done")
+	}
+	return c.Graph()
+}
+
+func sortedKeys(ids []kad.NodeID[key.Key8]) []key.Key8 {
+	ks := make([]key.Key8, len(ids))
+	for i, id := range ids {
+		ks[i] = id.Key()
+	}
+	sort.Slice(ks, func(i, j int) bool { return ks[i] < ks[j] })
+	return ks
+}
+
+func TestCrawler(t *testing.T) {
+	n, c := newTestNetwork(t, nil)
+	g := crawl(t, n, c)
+
+	// every node but the crawling one is found once, and returned all the nodes it knows but the
+	// crawling one, which the servers leave out of their responses
+	require.Len(t, g.Peers, 15)
+	require.Equal(t, 15, g.Reachable())
+	seeds := n.Nodes[0].RoutingTable.NearestNodes(key.Key8(0), 100)
+	for _, node := range n.Nodes[1:] {
+		p := g.Peer(node.Info.ID())
+		require.NotNil(t, p)
+		require.True(t, p.Crawled)
+		require.NoError(t, p.Err)
+		// one request for each bucket of a key of 8 bits
+		require.Equal(t, 8, p.Requests)
+		require.Equal(t, 8, p.Responses)
+		var known []kad.NodeID[key.Key8]
+		for _, id := range node.RoutingTable.NearestNodes(key.Key8(0), 100) {
+			if id.Key() != 0 {
+				known = append(known, id)
+			}
+		}
+		require.Equal(t, sortedKeys(known), sortedKeys(p.Neighbours))
+	}
+	for _, id := range seeds {
+		require.Equal(t, 0, g.Peer(id).Depth)
+	}
+
+	s := g.Snapshot()
+	require.Len(t, s.Peers, 15)
+	require.Equal(t, g.Peers[0].ID.String(), s.Peers[0].ID)
+	require.Len(t, s.Peers[0].Neighbors, len(g.Peers[0].Neighbours))
+
+	require.ErrorIs(t, c.Start(context.Background(), nil), ErrStarted)
+}
+
+func TestCrawlerUnreachable(t *testing.T) {
+	n, c := newTestNetwork(t, nil)
+	down := n.Nodes[3].Info.ID()
+	n.Router.RemovePeer(down)
+	g := crawl(t, n, c)
+
+	p := g.Peer(down)
+	require.NotNil(t, p)
+	require.True(t, p.Crawled)
+	require.False(t, p.Reachable())
+	require.Error(t, p.Err)
+	require.Equal(t, 1, p.Requests)
+	require.Equal(t, 14, g.Reachable())
+	require.Len(t, g.Snapshot().Peers, 14)
+}
+
+func TestCrawlerMaxPeers(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxPeers = 3
+	n, c := newTestNetwork(t, cfg)
+	g := crawl(t, n, c)
+
+	crawled := 0
+	for _, p := range g.Peers {
+		if p.Crawled {
+			crawled++
+		} else {
+			require.Equal(t, 0, p.Requests)
+		}
+	}
+	require.Equal(t, 3, crawled)
+	require.Greater(t, len(g.Peers), 3)
+}
+
+func TestCrawlerPeerInterval(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.PeerInterval = time.Second
+	n, c := newTestNetwork(t, cfg)
+	start := n.Clock.Now()
+	crawl(t, n, c)
+
+	// the 8 requests to each node are a second apart
+	require.GreaterOrEqual(t, n.Clock.Since(start), 7*time.Second)
+}
+
+func TestCrawlerThrottled(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.PeerInterval = 0
+	n, c := newTestNetwork(t, cfg)
+
+	// the throttling node answers a request a second
+	throttle := func(n *sim.Network[key.Key8, net.IP]) {
+		rcfg := endpoint.DefaultRateLimitConfig()
+		rcfg.Clock = n.Clock
+		rcfg.PeerRate = 1
+		rcfg.PeerBurst = 1
+		l, err := endpoint.NewRateLimiter(rcfg)
+		require.NoError(t, err)
+		n.Nodes[5].Endpoint.SetRateLimiter(l)
+	}
+	throttle(n)
+	throttling := n.Nodes[5]
+
+	g := crawl(t, n, c)
+	p := g.Peer(throttling.Info.ID())
+	require.NotNil(t, p)
+	require.NoError(t, p.Err)
+	require.Equal(t, 8, p.Responses)
+	require.Greater(t, p.Requests, 8)
+
+	// a node throttling more often than allowed is given up
+	cfg.MaxThrottled = 0
+	n, c = newTestNetwork(t, cfg)
+	throttle(n)
+	g = crawl(t, n, c)
+	p = g.Peer(n.Nodes[5].Info.ID())
+	require.ErrorIs(t, p.Err, endpoint.ErrThrottled)
+	require.Equal(t, 1, p.Responses)
+}
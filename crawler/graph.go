@@ -0,0 +1,89 @@
+package crawler
+
+import (
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/sim"
+)
+
+// Peer is a node found by a crawl.
+type Peer[K kad.Key[K], A kad.Address[A]] struct {
+	ID         kad.NodeID[K]
+	Addrs      []A             // the addresses the node was found with, or known by the endpoint for the seeds
+	Depth      int             // the number of hops from the seeds, zero for the seeds themselves
+	Crawled    bool            // whether the node was crawled, false for the nodes left once Config.MaxPeers were crawled
+	Requests   int             // the number of requests sent to the node
+	Responses  int             // the number of responses the node sent
+	Neighbours []kad.NodeID[K] // the nodes the node returned, in the order they were first returned
+	Err        error           // the error that ended the crawl of the node, nil if all requests succeeded
+
+	neighbours map[string]struct{}
+}
+
+// Reachable reports whether the node responded to at least one request.
+func (p *Peer[K, A]) Reachable() bool {
+	return p.Responses > 0
+}
+
+func (p *Peer[K, A]) addNeighbour(id kad.NodeID[K]) {
+	hex := key.FormatHex(id.Key())
+	if _, ok := p.neighbours[hex]; ok {
+		return
+	}
+	if p.neighbours == nil {
+		p.neighbours = make(map[string]struct{})
+	}
+	p.neighbours[hex] = struct{}{}
+	p.Neighbours = append(p.Neighbours, id)
+}
+
+// Graph is the network found by a crawl, each node of which is listed once with the neighbours it
+// returned.
+type Graph[K kad.Key[K], A kad.Address[A]] struct {
+	Peers []*Peer[K, A] // the nodes found, in the order they were found
+
+	index map[string]*Peer[K, A]
+}
+
+func newGraph[K kad.Key[K], A kad.Address[A]]() *Graph[K, A] {
+	return &Graph[K, A]{index: make(map[string]*Peer[K, A])}
+}
+
+func (g *Graph[K, A]) add(p *Peer[K, A]) {
+	g.index[key.FormatHex(p.ID.Key())] = p
+	g.Peers = append(g.Peers, p)
+}
+
+// Peer returns the node id of the graph, or nil if the crawl did not find it.
+func (g *Graph[K, A]) Peer(id kad.NodeID[K]) *Peer[K, A] {
+	return g.index[key.FormatHex(id.Key())]
+}
+
+// Reachable returns the number of nodes that responded to the crawl.
+func (g *Graph[K, A]) Reachable() int {
+	n := 0
+	for _, p := range g.Peers {
+		if p.Reachable() {
+			n++
+		}
+	}
+	return n
+}
+
+// Snapshot returns the graph as a sim.CrawlSnapshot listing the nodes that responded, so that
+// sim.CrawlTopology can simulate the network crawled. Nodes are identified by the string form of
+// their ids.
+func (g *Graph[K, A]) Snapshot() *sim.CrawlSnapshot {
+	s := &sim.CrawlSnapshot{Peers: make([]sim.CrawlPeer, 0, len(g.Peers))}
+	for _, p := range g.Peers {
+		if !p.Reachable() {
+			continue
+		}
+		nbs := make([]string, len(p.Neighbours))
+		for i, id := range p.Neighbours {
+			nbs[i] = id.String()
+		}
+		s.Peers = append(s.Peers, sim.CrawlPeer{ID: p.ID.String(), Neighbors: nbs})
+	}
+	return s
+}
@@ -0,0 +1,81 @@
+// Package datastore defines the minimal key-value storage that the persistent stores of the module,
+// such as records.DatastoreStore and peerstore.DatastorePeerstore, keep their entries in.
+package datastore
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned by Get when no value is stored under a key.
+var ErrNotFound = errors.New("datastore: key not found")
+
+// Datastore is a key-value storage, which may be persistent. Its methods mirror those of
+// go-datastore, so that a thin wrapper adapts any of its implementations.
+type Datastore interface {
+	// Get returns the value stored under key, or ErrNotFound if there is none.
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Put stores value under key.
+	Put(ctx context.Context, key string, value []byte) error
+
+	// Delete removes the value stored under key, if any.
+	Delete(ctx context.Context, key string) error
+
+	// Keys returns the keys of all values stored with the given prefix.
+	Keys(ctx context.Context, prefix string) ([]string, error)
+}
+
+// MapDatastore is a Datastore keeping its values in memory. It is safe for concurrent use.
+type MapDatastore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+var _ Datastore = (*MapDatastore)(nil)
+
+// NewMapDatastore creates an empty MapDatastore.
+func NewMapDatastore() *MapDatastore {
+	return &MapDatastore{values: make(map[string][]byte)}
+}
+
+func (d *MapDatastore) Get(ctx context.Context, key string) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	v, ok := d.values[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return v, nil
+}
+
+func (d *MapDatastore) Put(ctx context.Context, key string, value []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.values[key] = value
+	return nil
+}
+
+func (d *MapDatastore) Delete(ctx context.Context, key string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.values, key)
+	return nil
+}
+
+// Keys returns the keys with the given prefix in lexicographic order.
+func (d *MapDatastore) Keys(ctx context.Context, prefix string) ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var keys []string
+	for k := range d.values {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,33 @@
+package datastore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapDatastore(t *testing.T) {
+	ctx := context.Background()
+	d := NewMapDatastore()
+
+	_, err := d.Get(ctx, "/a/1")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, d.Put(ctx, "/a/2", []byte("2")))
+	require.NoError(t, d.Put(ctx, "/a/1", []byte("1")))
+	require.NoError(t, d.Put(ctx, "/b/1", []byte("3")))
+
+	v, err := d.Get(ctx, "/a/1")
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), v)
+
+	keys, err := d.Keys(ctx, "/a/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"/a/1", "/a/2"}, keys)
+
+	require.NoError(t, d.Delete(ctx, "/a/1"))
+	require.NoError(t, d.Delete(ctx, "/a/1"))
+	_, err = d.Get(ctx, "/a/1")
+	require.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,264 @@
+package dht
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/util"
+)
+
+// BootstrapperConfig specifies optional configuration for a Bootstrapper
+type BootstrapperConfig[K kad.Key[K], A kad.Address[A]] struct {
+	Peers         []kad.NodeInfo[K, A] // the bootstrap peers the self lookup of each attempt starts from
+	MinTableSize  int                  // the number of nodes the routing table must hold for the bootstrap to succeed
+	MaxRefreshCpl int                  // the longest common prefix length of the buckets refreshed after the self lookup
+	BackoffMin    time.Duration        // the delay before the first retry, doubled at each retry
+	BackoffMax    time.Duration        // the maximum delay between two attempts
+	Clock         clock.Clock          // a clock that may replaced by a mock when testing
+	Seed          int64                // seeds the generator drawing the keys of the refreshed buckets
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *BootstrapperConfig[K, A]) Validate() error {
+	if cfg.MinTableSize < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "BootstrapperConfig",
+			Err:       fmt.Errorf("minimum table size must be greater than zero"),
+		}
+	}
+	if cfg.MaxRefreshCpl < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "BootstrapperConfig",
+			Err:       fmt.Errorf("maximum refresh cpl must not be negative"),
+		}
+	}
+	if cfg.BackoffMin < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "BootstrapperConfig",
+			Err:       fmt.Errorf("minimum backoff must be greater than zero"),
+		}
+	}
+	if cfg.BackoffMax < cfg.BackoffMin {
+		return &kaderr.ConfigurationError{
+			Component: "BootstrapperConfig",
+			Err:       fmt.Errorf("maximum backoff must not be less than the minimum backoff"),
+		}
+	}
+	if cfg.Clock == nil {
+		return &kaderr.ConfigurationError{
+			Component: "BootstrapperConfig",
+			Err:       fmt.Errorf("clock must not be nil"),
+		}
+	}
+	return nil
+}
+
+// DefaultBootstrapperConfig returns the default configuration options for a Bootstrapper.
+// Options may be overridden before passing to NewBootstrapper
+func DefaultBootstrapperConfig[K kad.Key[K], A kad.Address[A]]() *BootstrapperConfig[K, A] {
+	return &BootstrapperConfig[K, A]{
+		Peers:         nil,
+		MinTableSize:  4,
+		MaxRefreshCpl: 15, // as in the public IPFS DHT, deeper buckets are filled by the self lookup
+		BackoffMin:    time.Second,
+		BackoffMax:    5 * time.Minute,
+		Clock:         clock.New(), // use standard time
+	}
+}
+
+// A Bootstrapper fills the routing table of a Node. Each attempt looks the node up starting from
+// the bootstrap peers, then refreshes the buckets of the routing table up to the common prefix
+// length of the closest node found, by looking up a random key of each bucket. Attempts are
+// retried with an exponential backoff until the routing table holds at least MinTableSize nodes.
+//
+// The random keys are looked up with requests built by FindPeerRequest for a node id carrying
+// only the key. Protocols that cannot build them, for example because their node ids are not
+// derived from keys, report the error in the EventBucketRefreshed of each bucket.
+//
+// The progress of the bootstrap is reported by the events returned by Events, which are dropped
+// if they are not received in time.
+type Bootstrapper[K kad.Key[K], A kad.Address[A]] struct {
+	node   *Node[K, A]
+	cfg    BootstrapperConfig[K, A]
+	rng    *rand.Rand
+	events chan BootstrapEvent
+}
+
+// NewBootstrapper creates a new Bootstrapper filling the routing table of n. If cfg is nil, the
+// default config is used.
+func NewBootstrapper[K kad.Key[K], A kad.Address[A]](n *Node[K, A], cfg *BootstrapperConfig[K, A]) (*Bootstrapper[K, A], error) {
+	if cfg == nil {
+		cfg = DefaultBootstrapperConfig[K, A]()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Bootstrapper[K, A]{
+		node:   n,
+		cfg:    *cfg,
+		rng:    rand.New(rand.NewSource(cfg.Seed)),
+		events: make(chan BootstrapEvent, 20), // 20 is arbitrary, move to config
+	}, nil
+}
+
+// Events returns the channel of the events reporting the progress of the bootstrap.
+func (b *Bootstrapper[K, A]) Events() <-chan BootstrapEvent {
+	return b.events
+}
+
+// Run runs bootstrap attempts until the routing table holds at least MinTableSize nodes, waiting
+// for the backoff delay between two attempts. It returns nil once the table is large enough, or
+// the error of ctx if it is done first. The node must have been started.
+func (b *Bootstrapper[K, A]) Run(ctx context.Context) error {
+	ctx, span := util.StartSpan(ctx, "Bootstrapper.Run")
+	defer span.End()
+
+	delay := b.cfg.BackoffMin
+	for attempt := 1; ; attempt++ {
+		b.emit(&EventBootstrapAttempt{Attempt: attempt})
+		b.attempt(ctx, attempt)
+
+		size, err := b.tableSize(ctx)
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+		if size >= b.cfg.MinTableSize {
+			b.emit(&EventBootstrapSucceeded{Attempts: attempt, TableSize: size})
+			return nil
+		}
+
+		// the timer is started before the event is emitted so that a consumer advancing a mock
+		// clock when receiving it triggers the retry
+		t := b.cfg.Clock.Timer(delay)
+		b.emit(&EventBootstrapRetry{Attempt: attempt, TableSize: size, Delay: delay})
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			span.RecordError(ctx.Err())
+			return ctx.Err()
+		}
+
+		delay *= 2
+		if delay > b.cfg.BackoffMax {
+			delay = b.cfg.BackoffMax
+		}
+	}
+}
+
+// attempt runs the self lookup and the bucket refreshes of an attempt.
+func (b *Bootstrapper[K, A]) attempt(ctx context.Context, attempt int) {
+	err := b.node.Bootstrap(ctx, b.cfg.Peers)
+	b.emit(&EventSelfLookupFinished{Attempt: attempt, Error: err})
+
+	self := b.node.self.ID().Key()
+	closest, err := b.node.nearestNodes(ctx, self, 1)
+	if err != nil || len(closest) == 0 {
+		return
+	}
+	maxCpl := self.CommonPrefixLength(closest[0].Key())
+	if maxCpl > b.cfg.MaxRefreshCpl {
+		maxCpl = b.cfg.MaxRefreshCpl
+	}
+	for cpl := 0; cpl <= maxCpl; cpl++ {
+		if ctx.Err() != nil {
+			return
+		}
+		b.emit(&EventBucketRefreshed{Attempt: attempt, Cpl: cpl, Error: b.refresh(ctx, self, cpl)})
+	}
+}
+
+// refresh looks up a random key of the bucket cpl, adding the nodes found to the routing table.
+func (b *Bootstrapper[K, A]) refresh(ctx context.Context, self K, cpl int) error {
+	return b.node.refreshBucket(ctx, self, cpl, b.rng)
+}
+
+// tableSize returns the number of nodes in the routing table, counted up to MinTableSize, or the
+// error of ctx if it is done before the table is read.
+func (b *Bootstrapper[K, A]) tableSize(ctx context.Context) (int, error) {
+	nodes, err := b.node.nearestNodes(ctx, b.node.self.ID().Key(), b.cfg.MinTableSize)
+	return len(nodes), err
+}
+
+func (b *Bootstrapper[K, A]) emit(ev BootstrapEvent) {
+	select {
+	case b.events <- ev:
+	default:
+		// the consumer is not keeping up
+	}
+}
+
+// refreshBucket looks up a random key of the bucket cpl of the routing table of the node, adding
+// the nodes found to the routing table.
+func (n *Node[K, A]) refreshBucket(ctx context.Context, self K, cpl int, rng *rand.Rand) error {
+	kk, err := key.RandomWithCommonPrefix(self, cpl, rng)
+	if err != nil {
+		return err
+	}
+	req, err := n.proto.FindPeerRequest(keyID[K]{key: kk})
+	if err != nil {
+		return err
+	}
+	return n.lookup(ctx, req, func(kad.NodeID[K], kad.Response[K, A]) bool {
+		return false
+	})
+}
+
+// keyID is a node id made of a Kademlia key only, to look up the nodes closest to a key.
+type keyID[K kad.Key[K]] struct {
+	key K
+}
+
+func (id keyID[K]) Key() K         { return id.key }
+func (id keyID[K]) String() string { return key.FormatHex(id.key) }
+
+// BootstrapEvent is an event reporting the progress of a Bootstrapper.
+type BootstrapEvent interface {
+	bootstrapEvent()
+}
+
+// EventBootstrapAttempt is emitted when a bootstrap attempt starts.
+type EventBootstrapAttempt struct {
+	Attempt int // the number of the attempt, starting at 1
+}
+
+// EventSelfLookupFinished is emitted when the self lookup of an attempt finished.
+type EventSelfLookupFinished struct {
+	Attempt int   // the number of the attempt
+	Error   error // the error of the lookup, nil if a node responded
+}
+
+// EventBucketRefreshed is emitted when the lookup refreshing a bucket of the routing table finished.
+type EventBucketRefreshed struct {
+	Attempt int   // the number of the attempt
+	Cpl     int   // the common prefix length of the bucket with the local key
+	Error   error // the error of the lookup, if any
+}
+
+// EventBootstrapRetry is emitted when an attempt left the routing table too small.
+type EventBootstrapRetry struct {
+	Attempt   int           // the number of the attempt that left the table too small
+	TableSize int           // the number of nodes in the routing table, counted up to MinTableSize
+	Delay     time.Duration // the delay before the next attempt
+}
+
+// EventBootstrapSucceeded is emitted when the routing table holds enough nodes.
+type EventBootstrapSucceeded struct {
+	Attempts  int // the number of attempts run
+	TableSize int // the number of nodes in the routing table, counted up to MinTableSize
+}
+
+// bootstrapEvent() ensures that only Bootstrapper events can be assigned to a BootstrapEvent.
+func (*EventBootstrapAttempt) bootstrapEvent()   {}
+func (*EventSelfLookupFinished) bootstrapEvent() {}
+func (*EventBucketRefreshed) bootstrapEvent()    {}
+func (*EventBootstrapRetry) bootstrapEvent()     {}
+func (*EventBootstrapSucceeded) bootstrapEvent() {}
@@ -0,0 +1,115 @@
+package dht
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kadtest"
+)
+
+func TestBootstrapperConfigValidate(t *testing.T) {
+	cfg := DefaultBootstrapperConfig[testKey, kadtest.StrAddr]()
+	require.NoError(t, cfg.Validate())
+
+	cfg.MinTableSize = 0
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultBootstrapperConfig[testKey, kadtest.StrAddr]()
+	cfg.MaxRefreshCpl = -1
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultBootstrapperConfig[testKey, kadtest.StrAddr]()
+	cfg.BackoffMin = 0
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultBootstrapperConfig[testKey, kadtest.StrAddr]()
+	cfg.BackoffMax = cfg.BackoffMin - 1
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultBootstrapperConfig[testKey, kadtest.StrAddr]()
+	cfg.Clock = nil
+	require.Error(t, cfg.Validate())
+}
+
+// nextBootstrapEvent returns the next event of b, failing the test if ctx is done first.
+func nextBootstrapEvent(t *testing.T, ctx context.Context, b *Bootstrapper[testKey, kadtest.StrAddr]) BootstrapEvent {
+	t.Helper()
+	select {
+	case ev := <-b.Events():
+		return ev
+	case <-ctx.Done():
+		t.Fatal("no bootstrap event")
+		return nil
+	}
+}
+
+func TestBootstrapper(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	net := newTestNetwork(t, 8)
+	// node 7 is not yet connected to the others
+	net.rts[6].RemoveKey(net.infos[7].ID().Key())
+	net.rts[7].RemoveKey(net.infos[6].ID().Key())
+
+	n := net.newNode(t, 7, nil)
+	net.run(ctx, n)
+
+	cfg := DefaultBootstrapperConfig[testKey, kadtest.StrAddr]()
+	cfg.Peers = []testInfo{net.infos[0]}
+	cfg.MinTableSize = 3
+	cfg.Clock = net.clk
+	b, err := NewBootstrapper(n, cfg)
+	require.NoError(t, err)
+	require.NoError(t, b.Run(ctx))
+
+	require.Equal(t, &EventBootstrapAttempt{Attempt: 1}, nextBootstrapEvent(t, ctx, b))
+	require.Equal(t, &EventSelfLookupFinished{Attempt: 1}, nextBootstrapEvent(t, ctx, b))
+
+	// the buckets are refreshed up to the bucket of the closest node, 0xc0 for 0xe0
+	for cpl := 0; cpl <= 2; cpl++ {
+		require.Equal(t, &EventBucketRefreshed{Attempt: 1, Cpl: cpl}, nextBootstrapEvent(t, ctx, b))
+	}
+	require.Equal(t, &EventBootstrapSucceeded{Attempts: 1, TableSize: 3}, nextBootstrapEvent(t, ctx, b))
+}
+
+func TestBootstrapperRetries(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	net := newTestNetwork(t, 4)
+	n := net.newNode(t, 0, nil)
+	net.run(ctx, n)
+
+	// the network is too small to ever fill the table
+	cfg := DefaultBootstrapperConfig[testKey, kadtest.StrAddr]()
+	cfg.Peers = []testInfo{net.infos[1]}
+	cfg.MinTableSize = 8
+	cfg.BackoffMin = time.Second
+	cfg.BackoffMax = 3 * time.Second
+	cfg.Clock = net.clk
+	b, err := NewBootstrapper(n, cfg)
+	require.NoError(t, err)
+
+	runCtx, stop := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- b.Run(runCtx) }()
+
+	// the delay doubles up to the maximum backoff
+	for attempt, delay := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		var retry *EventBootstrapRetry
+		for retry == nil {
+			retry, _ = nextBootstrapEvent(t, ctx, b).(*EventBootstrapRetry)
+		}
+		require.Equal(t, attempt+1, retry.Attempt)
+		require.Equal(t, 3, retry.TableSize)
+		require.Equal(t, delay, retry.Delay)
+		net.clk.Add(retry.Delay)
+	}
+
+	stop()
+	require.ErrorIs(t, <-done, context.Canceled)
+}
@@ -0,0 +1,206 @@
+package dht
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/query"
+)
+
+// LookupCacheConfig specifies optional configuration for a LookupCache
+type LookupCacheConfig struct {
+	Clock    clock.Clock   // a clock that may replaced by a mock when testing
+	Capacity int           // the maximum number of closest node sets and of values each kept, the least recently used are evicted first
+	TTL      time.Duration // the time after which a cached result is looked up again
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *LookupCacheConfig) Validate() error {
+	if cfg.Clock == nil {
+		return &kaderr.ConfigurationError{
+			Component: "LookupCacheConfig",
+			Err:       fmt.Errorf("clock must not be nil"),
+		}
+	}
+	if cfg.Capacity < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "LookupCacheConfig",
+			Err:       fmt.Errorf("capacity must be greater than zero"),
+		}
+	}
+	if cfg.TTL < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "LookupCacheConfig",
+			Err:       fmt.Errorf("ttl must be greater than zero"),
+		}
+	}
+	return nil
+}
+
+// DefaultLookupCacheConfig returns the default configuration options for a LookupCache.
+// Options may be overridden before passing to NewLookupCache
+func DefaultLookupCacheConfig() *LookupCacheConfig {
+	return &LookupCacheConfig{
+		Clock:    clock.New(), // use standard time
+		Capacity: 1024,
+		TTL:      time.Minute,
+	}
+}
+
+// LookupCache keeps the results of recent lookups, keyed by their target: the closest nodes
+// found by GetClosestPeers and the values found by GetValue. A Node given a cache returns the
+// results it holds instead of running the same lookup again until they expire, which saves the
+// duplicate lookups of bursty workloads at the cost of results up to TTL old. It is safe for
+// concurrent use.
+type LookupCache[K kad.Key[K]] struct {
+	cfg LookupCacheConfig
+
+	mu     sync.Mutex
+	peers  *lru
+	values *lru
+}
+
+// NewLookupCache creates an empty LookupCache. If cfg is nil, the default config is used.
+func NewLookupCache[K kad.Key[K]](cfg *LookupCacheConfig) (*LookupCache[K], error) {
+	if cfg == nil {
+		cfg = DefaultLookupCacheConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &LookupCache[K]{
+		cfg:    *cfg,
+		peers:  newLRU(cfg.Capacity),
+		values: newLRU(cfg.Capacity),
+	}, nil
+}
+
+// ClosestPeers returns a copy of the closest nodes to target that were cached, and false if
+// there are none or they expired.
+func (c *LookupCache[K]) ClosestPeers(target K) ([]kad.NodeID[K], bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.peers.get(key.FormatHex(target), c.cfg.Clock.Now())
+	if !ok {
+		return nil, false
+	}
+	peers := v.([]kad.NodeID[K])
+	return append([]kad.NodeID[K](nil), peers...), true
+}
+
+// SetClosestPeers caches the closest nodes to target found by a lookup.
+func (c *LookupCache[K]) SetClosestPeers(target K, peers []kad.NodeID[K]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.peers.set(key.FormatHex(target), append([]kad.NodeID[K](nil), peers...), c.cfg.Clock.Now().Add(c.cfg.TTL))
+}
+
+// NearestNodes returns up to n of the nodes of the unexpired closest node sets cached for any
+// target that are closest to target, so that queries for other targets may be accelerated from
+// them. See query.NodeSource.
+func (c *LookupCache[K]) NearestNodes(target K, n int) []kad.NodeID[K] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.cfg.Clock.Now()
+	var sets []query.NodeSource[K]
+	for e := c.peers.order.Front(); e != nil; e = e.Next() {
+		entry := e.Value.(*lruEntry)
+		if !now.Before(entry.expires) {
+			continue
+		}
+		sets = append(sets, nodeSet[K](entry.value.([]kad.NodeID[K])))
+	}
+	return query.NearestFrom(target, n, sets...)
+}
+
+// nodeSet is a query.NodeSource of a set of nodes.
+type nodeSet[K kad.Key[K]] []kad.NodeID[K]
+
+func (s nodeSet[K]) NearestNodes(target K, n int) []kad.NodeID[K] {
+	return s
+}
+
+// Value returns a copy of the value that was cached for target, and false if there is none or it
+// expired.
+func (c *LookupCache[K]) Value(target K) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.values.get(key.FormatHex(target), c.cfg.Clock.Now())
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), v.([]byte)...), true
+}
+
+// SetValue caches the value found or stored for target.
+func (c *LookupCache[K]) SetValue(target K, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values.set(key.FormatHex(target), append([]byte(nil), value...), c.cfg.Clock.Now().Add(c.cfg.TTL))
+}
+
+// Purge removes all the cached results.
+func (c *LookupCache[K]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.peers = newLRU(c.cfg.Capacity)
+	c.values = newLRU(c.cfg.Capacity)
+}
+
+// lru holds up to capacity entries, evicting the least recently used one when full.
+type lru struct {
+	capacity int
+	order    *list.List // of *lruEntry, the most recently used first
+	entries  map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   any
+	expires time.Time
+}
+
+func newLRU(capacity int) *lru {
+	return &lru{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// get returns the value of k if it has not expired at now, marking it as the most recently used.
+func (l *lru) get(k string, now time.Time) (any, bool) {
+	el, ok := l.entries[k]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*lruEntry)
+	if !now.Before(e.expires) {
+		l.order.Remove(el)
+		delete(l.entries, k)
+		return nil, false
+	}
+	l.order.MoveToFront(el)
+	return e.value, true
+}
+
+func (l *lru) set(k string, v any, expires time.Time) {
+	if el, ok := l.entries[k]; ok {
+		e := el.Value.(*lruEntry)
+		e.value, e.expires = v, expires
+		l.order.MoveToFront(el)
+		return
+	}
+	l.entries[k] = l.order.PushFront(&lruEntry{key: k, value: v, expires: expires})
+	if l.order.Len() > l.capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruEntry).key)
+	}
+}
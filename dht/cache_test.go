@@ -0,0 +1,111 @@
+package dht
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+)
+
+func TestLookupCacheConfigValidate(t *testing.T) {
+	cfg := DefaultLookupCacheConfig()
+	require.NoError(t, cfg.Validate())
+
+	cfg.Clock = nil
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultLookupCacheConfig()
+	cfg.Capacity = 0
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultLookupCacheConfig()
+	cfg.TTL = 0
+	require.Error(t, cfg.Validate())
+}
+
+func TestLookupCache(t *testing.T) {
+	clk := clock.NewMock()
+	cfg := DefaultLookupCacheConfig()
+	cfg.Clock = clk
+	cfg.Capacity = 2
+	cfg.TTL = time.Minute
+	c, err := NewLookupCache[testKey](cfg)
+	require.NoError(t, err)
+
+	peers := []kad.NodeID[testKey]{kadtest.NewID(testKey(1)), kadtest.NewID(testKey(2))}
+	c.SetClosestPeers(0x10, peers)
+	got, ok := c.ClosestPeers(0x10)
+	require.True(t, ok)
+	require.Equal(t, peers, got)
+
+	// the cached set is not affected by changes to the returned one
+	got[0] = nil
+	got, _ = c.ClosestPeers(0x10)
+	require.Equal(t, peers, got)
+
+	_, ok = c.Value(0x10)
+	require.False(t, ok)
+	c.SetValue(0x10, []byte("v"))
+	v, ok := c.Value(0x10)
+	require.True(t, ok)
+	require.Equal(t, []byte("v"), v)
+
+	// the cached value is not affected by changes to the returned one
+	v[0] = 'x'
+	v, _ = c.Value(0x10)
+	require.Equal(t, []byte("v"), v)
+
+	t.Run("least recently used are evicted", func(t *testing.T) {
+		c.SetValue(0x20, []byte("w"))
+		_, _ = c.Value(0x10)
+		c.SetValue(0x30, []byte("x"))
+
+		_, ok := c.Value(0x20)
+		require.False(t, ok)
+		_, ok = c.Value(0x10)
+		require.True(t, ok)
+		_, ok = c.Value(0x30)
+		require.True(t, ok)
+	})
+
+	t.Run("results expire", func(t *testing.T) {
+		clk.Add(time.Minute)
+		_, ok := c.ClosestPeers(0x10)
+		require.False(t, ok)
+		_, ok = c.Value(0x30)
+		require.False(t, ok)
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		c.SetValue(0x10, []byte("v"))
+		c.Purge()
+		_, ok := c.Value(0x10)
+		require.False(t, ok)
+	})
+}
+
+func TestLookupCacheNearestNodes(t *testing.T) {
+	clk := clock.NewMock()
+	cfg := DefaultLookupCacheConfig()
+	cfg.Clock = clk
+	c, err := NewLookupCache[testKey](cfg)
+	require.NoError(t, err)
+
+	a := kadtest.NewID(testKey(0x11))
+	b := kadtest.NewID(testKey(0x14))
+	d := kadtest.NewID(testKey(0x40))
+	c.SetClosestPeers(0x10, []kad.NodeID[testKey]{a, b})
+	clk.Add(30 * time.Second)
+	c.SetClosestPeers(0x50, []kad.NodeID[testKey]{d, a})
+
+	// the cached sets are merged, whatever their target
+	require.Equal(t, []kad.NodeID[testKey]{b, a}, c.NearestNodes(0x15, 2))
+
+	// expired sets are left out
+	clk.Add(30 * time.Second)
+	require.Equal(t, []kad.NodeID[testKey]{a, d}, c.NearestNodes(0x15, 2))
+}
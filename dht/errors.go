@@ -0,0 +1,17 @@
+package dht
+
+import "errors"
+
+var (
+	// ErrNotFound is returned when a lookup ended without finding the peer or value it looked for.
+	ErrNotFound = errors.New("not found")
+	// ErrNoPeers is returned when the routing table holds no nodes to start a lookup with, or no
+	// node could be reached.
+	ErrNoPeers = errors.New("no peers")
+	// ErrBootstrapping is returned by Bootstrap while another bootstrap is in progress.
+	ErrBootstrapping = errors.New("bootstrap in progress")
+	// ErrClientMode is returned by Serve if the node is in client mode.
+	ErrClientMode = errors.New("node is in client mode")
+	// ErrNotServerEndpoint is returned by Serve if the endpoint of the node cannot serve requests.
+	ErrNotServerEndpoint = errors.New("endpoint is not a ServerEndpoint")
+)
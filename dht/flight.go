@@ -0,0 +1,94 @@
+package dht
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+// flightGroup runs at most one call at a time for each key. Callers asking for a key whose call
+// is running attach to it and share its result instead of starting another call. A call runs
+// until it returns or all its callers stopped waiting for it, so that a caller giving up does not
+// fail the others.
+type flightGroup[T any] struct {
+	mu      sync.Mutex
+	flights map[string]*flight[T]
+}
+
+// flight is a call running for a key.
+type flight[T any] struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiting int // the number of callers waiting for the call
+	val     T
+	err     error
+}
+
+// do returns the result of fn for key, calling it unless a call for key is already running, in
+// which case its result is returned once it is done. fn is passed a context that is cancelled
+// once no caller waits for the result, carrying the span of the caller that started the call.
+// The returned bool reports whether the result was shared with another caller.
+func (g *flightGroup[T]) do(ctx context.Context, key string, fn func(context.Context) (T, error)) (T, bool, error) {
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = make(map[string]*flight[T])
+	}
+	f, shared := g.flights[key]
+	if !shared {
+		fctx, cancel := context.WithCancel(trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx)))
+		f = &flight[T]{done: make(chan struct{}), cancel: cancel}
+		g.flights[key] = f
+		go g.run(fctx, key, f, fn)
+	}
+	f.waiting++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.val, shared, f.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiting--
+		if f.waiting == 0 {
+			// nobody is interested in the result anymore
+			f.cancel()
+			if g.flights[key] == f {
+				delete(g.flights, key)
+			}
+		}
+		g.mu.Unlock()
+		var zero T
+		return zero, shared, ctx.Err()
+	}
+}
+
+func (g *flightGroup[T]) run(ctx context.Context, key string, f *flight[T], fn func(context.Context) (T, error)) {
+	defer f.cancel()
+	f.val, f.err = fn(ctx)
+
+	g.mu.Lock()
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+	g.mu.Unlock()
+	close(f.done)
+}
+
+// waiting returns the number of callers waiting for the call running for key.
+func (g *flightGroup[T]) waiting(key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if f, ok := g.flights[key]; ok {
+		return f.waiting
+	}
+	return 0
+}
+
+// flightKey returns the key of the calls for target.
+func flightKey[K kad.Key[K]](target K) string {
+	return key.FormatHex(target)
+}
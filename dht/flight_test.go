@@ -0,0 +1,118 @@
+package dht
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kadtest"
+)
+
+func TestFlightGroupShares(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	var g flightGroup[int]
+	release := make(chan struct{})
+	calls := 0
+	fn := func(ctx context.Context) (int, error) {
+		calls++
+		<-release
+		return 42, nil
+	}
+
+	type result struct {
+		val    int
+		shared bool
+		err    error
+	}
+	results := make(chan result, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			val, shared, err := g.do(ctx, "k", fn)
+			results <- result{val, shared, err}
+		}()
+	}
+	require.Eventually(t, func() bool { return g.waiting("k") == 3 }, time.Second, time.Millisecond)
+	close(release)
+
+	shared := 0
+	for i := 0; i < 3; i++ {
+		r := <-results
+		require.NoError(t, r.err)
+		require.Equal(t, 42, r.val)
+		if r.shared {
+			shared++
+		}
+	}
+	require.Equal(t, 1, calls)
+	require.Equal(t, 2, shared)
+	require.Zero(t, g.waiting("k"))
+
+	// a later call runs again
+	release = make(chan struct{})
+	close(release)
+	_, shared2, err := g.do(ctx, "k", fn)
+	require.NoError(t, err)
+	require.False(t, shared2)
+	require.Equal(t, 2, calls)
+}
+
+func TestFlightGroupError(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	var g flightGroup[int]
+	errFailed := errors.New("failed")
+	_, _, err := g.do(ctx, "k", func(ctx context.Context) (int, error) {
+		return 0, errFailed
+	})
+	require.ErrorIs(t, err, errFailed)
+}
+
+func TestFlightGroupCancel(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	var g flightGroup[int]
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	fn := func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		close(stopped)
+		return 0, ctx.Err()
+	}
+
+	// the call goes on while a caller waits for it
+	ctx1, cancel1 := context.WithCancel(ctx)
+	ctx2, cancel2 := context.WithCancel(ctx)
+	errs := make(chan error, 2)
+	go func() {
+		_, _, err := g.do(ctx1, "k", fn)
+		errs <- err
+	}()
+	<-started
+	go func() {
+		_, _, err := g.do(ctx2, "k", fn)
+		errs <- err
+	}()
+	require.Eventually(t, func() bool { return g.waiting("k") == 2 }, time.Second, time.Millisecond)
+
+	cancel1()
+	require.ErrorIs(t, <-errs, context.Canceled)
+	select {
+	case <-stopped:
+		t.Fatal("call cancelled while a caller waits for it")
+	default:
+	}
+
+	// and is cancelled once no caller waits for it
+	cancel2()
+	require.ErrorIs(t, <-errs, context.Canceled)
+	<-stopped
+	require.Zero(t, g.waiting("k"))
+}
@@ -0,0 +1,358 @@
+package dht
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/routing"
+	"github.com/plprobelab/go-kademlia/util"
+)
+
+// HealthCheckerConfig specifies optional configuration for a HealthChecker
+type HealthCheckerConfig struct {
+	Interval       time.Duration // the interval between two rounds of probes
+	Jitter         time.Duration // the maximum random delay added to each interval, spreading the rounds of nodes started together
+	RequestTimeout time.Duration // the timeout of each probe
+	MaxFailures    int           // the number of consecutive failed probes after which a node is removed from the routing table
+	MinBucketSize  int           // the number of nodes below which a bucket is refreshed after a round
+	MaxRefreshCpl  int           // the longest common prefix length of the buckets that may be refreshed
+	Seed           int64         // seeds the generator drawing the jitter and the keys of the refreshed buckets
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *HealthCheckerConfig) Validate() error {
+	if cfg.Interval < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "HealthCheckerConfig",
+			Err:       fmt.Errorf("interval must be greater than zero"),
+		}
+	}
+	if cfg.Jitter < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "HealthCheckerConfig",
+			Err:       fmt.Errorf("jitter must not be negative"),
+		}
+	}
+	if cfg.RequestTimeout < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "HealthCheckerConfig",
+			Err:       fmt.Errorf("request timeout must be greater than zero"),
+		}
+	}
+	if cfg.MaxFailures < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "HealthCheckerConfig",
+			Err:       fmt.Errorf("max failures must be greater than zero"),
+		}
+	}
+	if cfg.MinBucketSize < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "HealthCheckerConfig",
+			Err:       fmt.Errorf("minimum bucket size must not be negative"),
+		}
+	}
+	if cfg.MaxRefreshCpl < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "HealthCheckerConfig",
+			Err:       fmt.Errorf("maximum refresh cpl must not be negative"),
+		}
+	}
+	return nil
+}
+
+// DefaultHealthCheckerConfig returns the default configuration options for a HealthChecker.
+// Options may be overridden before passing to NewHealthChecker
+func DefaultHealthCheckerConfig() *HealthCheckerConfig {
+	return &HealthCheckerConfig{
+		Interval:       10 * time.Minute, // as the routing table refresh of the public IPFS DHT
+		Jitter:         time.Minute,
+		RequestTimeout: 10 * time.Second,
+		MaxFailures:    3,
+		MinBucketSize:  4,
+		MaxRefreshCpl:  15, // as in the public IPFS DHT, deeper buckets are filled by lookups of nearby keys
+	}
+}
+
+// A HealthChecker keeps the routing table of a Node healthy. Each round probes every node of the
+// table, removes the nodes that failed MaxFailures consecutive probes, then refreshes the buckets
+// holding fewer than MinBucketSize nodes, up to the common prefix length of the closest node, by
+// looking up a random key of each of them.
+//
+// Nodes are probed with the request built by PingRequest if the protocol of the node is a
+// PingProtocol, or else with a request for the nodes closest to themselves built by
+// FindPeerRequest. The rounds are planned on the scheduler of the node, one Interval after the
+// end of the previous round delayed by up to Jitter, and the table is read and the probes sent by
+// actions of the coordinator, so that the checker only runs while the scheduler is driven.
+//
+// The outcome of each round is reported by the events returned by Events, which are dropped if
+// they are not received in time.
+type HealthChecker[K kad.Key[K], A kad.Address[A]] struct {
+	node   *Node[K, A]
+	cfg    HealthCheckerConfig
+	rng    *rand.Rand
+	lister routing.NodeLister[K, kad.NodeID[K]]
+	events chan HealthEvent
+
+	mu       sync.Mutex
+	failures map[string]int // the consecutive failed probes of each node, by node id
+}
+
+// NewHealthChecker creates a new HealthChecker of the routing table of n, which must be a
+// routing.NodeLister such as TrieRT or SimpleRT. If cfg is nil, the default config is used.
+func NewHealthChecker[K kad.Key[K], A kad.Address[A]](n *Node[K, A], cfg *HealthCheckerConfig) (*HealthChecker[K, A], error) {
+	if cfg == nil {
+		cfg = DefaultHealthCheckerConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	lister, ok := n.rt.(routing.NodeLister[K, kad.NodeID[K]])
+	if !ok {
+		return nil, fmt.Errorf("routing table %T cannot list its nodes", n.rt)
+	}
+
+	return &HealthChecker[K, A]{
+		node:     n,
+		cfg:      *cfg,
+		rng:      rand.New(rand.NewSource(cfg.Seed)),
+		lister:   lister,
+		events:   make(chan HealthEvent, 20), // 20 is arbitrary, move to config
+		failures: make(map[string]int),
+	}, nil
+}
+
+// Events returns the channel of the events reporting the outcome of each round.
+func (h *HealthChecker[K, A]) Events() <-chan HealthEvent {
+	return h.events
+}
+
+// Failures returns the number of consecutive probes the node id failed.
+func (h *HealthChecker[K, A]) Failures(id kad.NodeID[K]) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.failures[id.String()]
+}
+
+// Start runs rounds of probes until ctx is done, the first one Interval from now. The node must
+// have been started.
+func (h *HealthChecker[K, A]) Start(ctx context.Context) {
+	go h.run(ctx)
+}
+
+func (h *HealthChecker[K, A]) run(ctx context.Context) {
+	for round := 1; ; round++ {
+		if !h.wait(ctx) {
+			return
+		}
+		h.check(ctx, round)
+	}
+}
+
+// wait plans the next round on the scheduler of the node and waits until it is due, returning
+// false if ctx is done first.
+func (h *HealthChecker[K, A]) wait(ctx context.Context) bool {
+	d := h.cfg.Interval
+	if h.cfg.Jitter > 0 {
+		d += time.Duration(h.rng.Int63n(int64(h.cfg.Jitter) + 1))
+	}
+	due := make(chan struct{})
+	planned := h.node.coord.ScheduleAction(ctx, h.node.coord.Clock().Now().Add(d), event.BasicAction(func(context.Context) {
+		close(due)
+	}))
+	select {
+	case <-due:
+		return true
+	case <-ctx.Done():
+		h.node.coord.RemovePlannedAction(context.Background(), planned)
+		return false
+	}
+}
+
+// probeResult is the outcome of the probe of a node.
+type probeResult[K kad.Key[K]] struct {
+	id  kad.NodeID[K]
+	err error
+}
+
+// check runs a round: probes the nodes of the routing table, removes the unresponsive ones and
+// refreshes the underfilled buckets.
+func (h *HealthChecker[K, A]) check(ctx context.Context, round int) {
+	ctx, span := util.StartSpan(ctx, "HealthChecker.check")
+	defer span.End()
+
+	results, probed, ok := h.probe(ctx)
+	if !ok {
+		return
+	}
+
+	var failed int
+	var unresponsive []kad.NodeID[K]
+	for i := 0; i < probed; i++ {
+		var r probeResult[K]
+		select {
+		case r = <-results:
+		case <-ctx.Done():
+			return
+		}
+		h.mu.Lock()
+		if r.err == nil {
+			delete(h.failures, r.id.String())
+		} else {
+			failed++
+			h.failures[r.id.String()]++
+			if h.failures[r.id.String()] >= h.cfg.MaxFailures {
+				delete(h.failures, r.id.String())
+				unresponsive = append(unresponsive, r.id)
+			}
+		}
+		h.mu.Unlock()
+	}
+
+	buckets, maxCpl, ok := h.remove(ctx, unresponsive)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int("probed", probed), attribute.Int("failed", failed), attribute.Int("removed", len(unresponsive)))
+	h.emit(&EventProbesFinished[K]{Round: round, Probed: probed, Failed: failed, Removed: unresponsive})
+
+	if maxCpl > h.cfg.MaxRefreshCpl {
+		maxCpl = h.cfg.MaxRefreshCpl
+	}
+	self := h.node.self.ID().Key()
+	for cpl := 0; cpl <= maxCpl; cpl++ {
+		size := 0
+		if cpl < len(buckets) {
+			size = buckets[cpl]
+		}
+		if size >= h.cfg.MinBucketSize {
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		err := h.node.refreshBucket(ctx, self, cpl, h.rng)
+		h.emit(&EventBucketRefilled{Round: round, Cpl: cpl, Size: size, Error: err})
+	}
+}
+
+// probe sends a probe to each node of the routing table from the coordinator and returns the
+// channel their results are sent to with the number of nodes probed, or false if ctx is done
+// before the probes are sent.
+func (h *HealthChecker[K, A]) probe(ctx context.Context) (<-chan probeResult[K], int, bool) {
+	type probes struct {
+		results <-chan probeResult[K]
+		n       int
+	}
+	sent := make(chan probes, 1)
+	h.node.coord.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
+		nodes := h.lister.AllNodes()
+		results := make(chan probeResult[K], len(nodes))
+		for _, id := range nodes {
+			id := id
+			req, err := h.probeRequest(id)
+			if err != nil {
+				results <- probeResult[K]{id: id, err: err}
+				continue
+			}
+			err = h.node.ep.SendRequestHandleResponse(ctx, h.node.proto.ID(), id, req, req.EmptyResponse(), h.cfg.RequestTimeout,
+				func(ctx context.Context, resp kad.Response[K, A], err error) {
+					results <- probeResult[K]{id: id, err: err}
+				})
+			if err != nil {
+				results <- probeResult[K]{id: id, err: err}
+			}
+		}
+		sent <- probes{results: results, n: len(nodes)}
+	}))
+	select {
+	case p := <-sent:
+		return p.results, p.n, true
+	case <-ctx.Done():
+		return nil, 0, false
+	}
+}
+
+// probeRequest returns the request probing the node id.
+func (h *HealthChecker[K, A]) probeRequest(id kad.NodeID[K]) (kad.Request[K, A], error) {
+	if pp, ok := h.node.proto.(PingProtocol[K, A]); ok {
+		return pp.PingRequest(), nil
+	}
+	return h.node.proto.FindPeerRequest(id)
+}
+
+// remove removes the nodes from the routing table from the coordinator, then returns the number of
+// nodes left in each bucket, indexed by common prefix length with the key of the node, and the
+// common prefix length of the closest node, -1 if the table is empty. It returns false if ctx is
+// done first.
+func (h *HealthChecker[K, A]) remove(ctx context.Context, nodes []kad.NodeID[K]) ([]int, int, bool) {
+	type sizes struct {
+		buckets []int
+		maxCpl  int
+	}
+	done := make(chan sizes, 1)
+	h.node.coord.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
+		for _, id := range nodes {
+			h.node.coord.RemoveNode(ctx, id)
+		}
+		self := h.node.self.ID().Key()
+		s := sizes{maxCpl: -1}
+		for _, id := range h.lister.AllNodes() {
+			cpl := self.CommonPrefixLength(id.Key())
+			for len(s.buckets) <= cpl {
+				s.buckets = append(s.buckets, 0)
+			}
+			s.buckets[cpl]++
+			if cpl > s.maxCpl {
+				s.maxCpl = cpl
+			}
+		}
+		done <- s
+	}))
+	select {
+	case s := <-done:
+		return s.buckets, s.maxCpl, true
+	case <-ctx.Done():
+		return nil, 0, false
+	}
+}
+
+func (h *HealthChecker[K, A]) emit(ev HealthEvent) {
+	select {
+	case h.events <- ev:
+	default:
+		// the consumer is not keeping up
+	}
+}
+
+// HealthEvent is an event reporting the outcome of a round of a HealthChecker.
+type HealthEvent interface {
+	healthEvent()
+}
+
+// EventProbesFinished is emitted when the probes of a round completed and the unresponsive nodes
+// were removed from the routing table.
+type EventProbesFinished[K kad.Key[K]] struct {
+	Round   int             // the number of the round, starting at 1
+	Probed  int             // the number of nodes probed
+	Failed  int             // the number of nodes that failed their probe
+	Removed []kad.NodeID[K] // the nodes removed after MaxFailures consecutive failed probes
+}
+
+// EventBucketRefilled is emitted when the lookup refreshing an underfilled bucket finished.
+type EventBucketRefilled struct {
+	Round int   // the number of the round
+	Cpl   int   // the common prefix length of the bucket with the local key
+	Size  int   // the number of nodes the bucket held before the refresh
+	Error error // the error of the lookup, if any
+}
+
+// healthEvent() ensures that only HealthChecker events can be assigned to a HealthEvent.
+func (*EventProbesFinished[K]) healthEvent() {}
+func (*EventBucketRefilled) healthEvent()    {}
@@ -0,0 +1,102 @@
+package dht
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+)
+
+func TestHealthCheckerConfigValidate(t *testing.T) {
+	cfg := DefaultHealthCheckerConfig()
+	require.NoError(t, cfg.Validate())
+
+	cfg.Interval = 0
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultHealthCheckerConfig()
+	cfg.Jitter = -1
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultHealthCheckerConfig()
+	cfg.RequestTimeout = 0
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultHealthCheckerConfig()
+	cfg.MaxFailures = 0
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultHealthCheckerConfig()
+	cfg.MinBucketSize = -1
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultHealthCheckerConfig()
+	cfg.MaxRefreshCpl = -1
+	require.Error(t, cfg.Validate())
+}
+
+// nextHealthEvent returns the next event of h, advancing the clock of net by a step at a time
+// until the next round is due, and failing the test if ctx is done first.
+func nextHealthEvent(t *testing.T, ctx context.Context, net *testNetwork, h *HealthChecker[testKey, kadtest.StrAddr], step time.Duration) HealthEvent {
+	t.Helper()
+	for {
+		select {
+		case ev := <-h.Events():
+			return ev
+		case <-ctx.Done():
+			t.Fatal("no health event")
+			return nil
+		case <-time.After(10 * time.Millisecond):
+			net.clk.Add(step)
+		}
+	}
+}
+
+func TestHealthChecker(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	net := newTestNetwork(t, 4)
+	// node 0x00 knows 0x20 and 0x40, which is down, leaving the bucket of 0x80 empty
+	net.connect(0, 2)
+	net.router.SetNodeDown(net.infos[2].ID())
+
+	n := net.newNode(t, 0, nil)
+	cfg := DefaultHealthCheckerConfig()
+	cfg.Interval = time.Minute
+	cfg.Jitter = 0
+	cfg.MaxFailures = 2
+	cfg.MinBucketSize = 1
+	h, err := NewHealthChecker(n, cfg)
+	require.NoError(t, err)
+	net.run(ctx, n)
+	h.Start(ctx)
+	next := func() HealthEvent { return nextHealthEvent(t, ctx, net, h, cfg.Interval) }
+
+	// the first failure is tolerated
+	require.Equal(t, &EventProbesFinished[testKey]{Round: 1, Probed: 2, Failed: 1}, next())
+	require.Equal(t, 1, h.Failures(net.infos[2].ID()))
+	ev, ok := next().(*EventBucketRefilled)
+	require.True(t, ok)
+	require.Equal(t, &EventBucketRefilled{Round: 1, Cpl: 0, Size: 0, Error: ev.Error}, ev)
+
+	// the bucket of 0x40 is empty too once it is removed, both are refreshed
+	require.Equal(t, &EventProbesFinished[testKey]{
+		Round:   2,
+		Probed:  2,
+		Failed:  1,
+		Removed: []kad.NodeID[testKey]{net.infos[2].ID()},
+	}, next())
+	require.Zero(t, h.Failures(net.infos[2].ID()))
+	for _, cpl := range []int{0, 1} {
+		ev, ok := next().(*EventBucketRefilled)
+		require.True(t, ok)
+		require.Equal(t, 2, ev.Round)
+		require.Equal(t, cpl, ev.Cpl)
+		require.Zero(t, ev.Size)
+	}
+}
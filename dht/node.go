@@ -0,0 +1,853 @@
+// Package dht provides a DHT node composing the building blocks of the module: an endpoint, a
+// routing table, a coordinator running queries, and optional record and provider stores.
+package dht
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/plprobelab/go-kademlia/coord"
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+	"github.com/plprobelab/go-kademlia/query"
+	"github.com/plprobelab/go-kademlia/records"
+	"github.com/plprobelab/go-kademlia/server"
+	"github.com/plprobelab/go-kademlia/server/token"
+	"github.com/plprobelab/go-kademlia/util"
+)
+
+// Mode is the mode a Node runs in, either serving the DHT or only using it.
+type Mode int
+
+const (
+	// ModeServer is the mode of the nodes serving the DHT, answering the requests of other nodes.
+	ModeServer Mode = iota
+	// ModeClient is the mode of the nodes running queries without serving the DHT, such as
+	// resource constrained clients. They register no request handler, so they do not advertise
+	// the protocol of the DHT, and other nodes can leave them out of their responses.
+	ModeClient
+)
+
+// Config specifies optional configuration for a Node
+type Config[K kad.Key[K], A kad.Address[A]] struct {
+	Mode           Mode                              // whether the node serves the DHT or is a client only
+	Coordinator    *coord.Config                     // the configuration of the coordinator running the queries, nil for the default
+	Replication    int                               // the number of closest nodes values and provider records are stored at
+	RequestTimeout time.Duration                     // the timeout of the requests storing values and provider records
+	ResultCapacity int                               // the number of results a lookup buffers until they are received
+	Records        records.RecordStore               // an optional store of the values put by the node, consulted before looking values up
+	Providers      server.ProviderStore[K, A]        // an optional store of the content provided by the node, consulted before looking providers up
+	Tokens         *token.Tokens[K]                  // an optional holder of the write tokens issued by servers, used if the protocol is a TokenProtocol
+	MeterProvider  metric.MeterProvider              // an optional provider of the meters recording the metrics of the node and of its coordinator, unless the coordinator config sets its own
+	Cache          *LookupCache[K]                   // an optional cache of the closest nodes and values found by recent lookups, consulted before looking them up again
+	CloserNodes    *endpoint.CloserNodesPolicy[K, A] // an optional policy rejecting closer nodes of responses, which lookups neither contact nor add to the routing table
+	Sources        []query.NodeSource[K]             // optional sources of known nodes, such as a peerstore.NodeSource, accelerated lookups draw candidates from besides the routing table and the cache
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *Config[K, A]) Validate() error {
+	if cfg.Mode != ModeServer && cfg.Mode != ModeClient {
+		return &kaderr.ConfigurationError{
+			Component: "DHTConfig",
+			Err:       fmt.Errorf("unknown mode %d", cfg.Mode),
+		}
+	}
+	if cfg.Coordinator != nil {
+		if err := cfg.Coordinator.Validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Replication < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "DHTConfig",
+			Err:       fmt.Errorf("replication must be greater than zero"),
+		}
+	}
+	if cfg.RequestTimeout < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "DHTConfig",
+			Err:       fmt.Errorf("request timeout must be greater than zero"),
+		}
+	}
+	if cfg.ResultCapacity < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "DHTConfig",
+			Err:       fmt.Errorf("result capacity must be greater than zero"),
+		}
+	}
+	return nil
+}
+
+// DefaultConfig returns the default configuration options for a Node.
+// Options may be overridden before passing to NewNode
+func DefaultConfig[K kad.Key[K], A kad.Address[A]]() *Config[K, A] {
+	cfg := ConfigFrom[K, A](kad.DefaultConfig())
+	cfg.Coordinator = nil // the coordinator defaults to the same parameters
+	return cfg
+}
+
+// ConfigFrom returns the default configuration options for a Node storing values at the
+// BucketSize closest nodes, whose coordinator is configured with coord.ConfigFrom.
+func ConfigFrom[K kad.Key[K], A kad.Address[A]](kcfg *kad.Config) *Config[K, A] {
+	return &Config[K, A]{
+		Mode:           ModeServer,
+		Coordinator:    coord.ConfigFrom(kcfg),
+		Replication:    kcfg.BucketSize,
+		RequestTimeout: kcfg.RequestTimeout,
+		ResultCapacity: 20,
+		Records:        nil,
+		Providers:      nil,
+		Tokens:         nil,
+		MeterProvider:  nil,
+		Cache:          nil,
+	}
+}
+
+// A Node is a DHT node, looking up peers, values and providers and storing values and provider
+// records in the network through a Protocol. The queries run on the coordinator of the node,
+// which must be driven like any other scheduler, for example by adding Scheduler to a
+// simulator, and whose events are dispatched to the lookups once Start is called. The requests
+// storing values and provider records are sent from the coordinator too, and the routing table is
+// read from it, so that the endpoint and the routing table are only used by the goroutine driving
+// it.
+//
+// Concurrent lookups of the same peer, closest nodes or value share a single query: callers
+// asking for a target whose lookup is running wait for its result instead of sending the same
+// requests again.
+//
+// Serving the requests of other nodes is left to the server of the protocol, which may share the
+// record and provider stores of the node.
+type Node[K kad.Key[K], A kad.Address[A]] struct {
+	self  kad.NodeInfo[K, A]
+	cfg   Config[K, A]
+	ep    endpoint.Endpoint[K, A]
+	rt    kad.RoutingTable[K, kad.NodeID[K]]
+	proto Protocol[K, A]
+	coord *coord.Coordinator[K, A]
+
+	mu        sync.Mutex
+	nextQuery uint64
+	queries   map[query.QueryID]*queryEvents[K, A] // the events of each running lookup
+	bootstrap *util.Stream[coord.KademliaEvent]    // the events of the running bootstrap, if any
+
+	peerFlights    flightGroup[kad.NodeInfo[K, A]] // the running FindPeer lookups, by target
+	closestFlights flightGroup[[]kad.NodeID[K]]    // the running closest nodes lookups, by target
+	valueFlights   flightGroup[[]byte]             // the running GetValue lookups, by key
+}
+
+// bootstrapQueryID is the id of the query run by the bootstrap state machine of the coordinator.
+const bootstrapQueryID = query.QueryID("bootstrap")
+
+// NewNode creates a new Node whose information is self, sending requests through ep and looking
+// up nodes in rt. If cfg is nil, the default config is used.
+func NewNode[K kad.Key[K], A kad.Address[A]](self kad.NodeInfo[K, A], ep endpoint.Endpoint[K, A],
+	rt kad.RoutingTable[K, kad.NodeID[K]], proto Protocol[K, A], cfg *Config[K, A],
+) (*Node[K, A], error) {
+	if cfg == nil {
+		cfg = DefaultConfig[K, A]()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	ccfg := cfg.Coordinator
+	if cfg.MeterProvider != nil && (ccfg == nil || ccfg.MeterProvider == nil) {
+		if ccfg == nil {
+			ccfg = coord.DefaultConfig()
+		} else {
+			c := *ccfg
+			ccfg = &c
+		}
+		ccfg.MeterProvider = cfg.MeterProvider
+	}
+	c, err := coord.NewCoordinator[K, A](self.ID(), ep, rt, ccfg)
+	if err != nil {
+		return nil, fmt.Errorf("coordinator: %w", err)
+	}
+	c.SetCloserNodesPolicy(cfg.CloserNodes)
+	sources := cfg.Sources
+	if cfg.Cache != nil {
+		sources = append([]query.NodeSource[K]{cfg.Cache}, sources...)
+	}
+	c.SetAccelerationSources(sources...)
+	return &Node[K, A]{
+		self:    self,
+		cfg:     *cfg,
+		ep:      ep,
+		rt:      rt,
+		proto:   proto,
+		coord:   c,
+		queries: make(map[query.QueryID]*queryEvents[K, A]),
+	}, nil
+}
+
+// Scheduler returns the scheduler running the queries of the node, to be driven by the caller.
+func (n *Node[K, A]) Scheduler() event.AwareScheduler {
+	return n.coord
+}
+
+// Mode returns the mode the node runs in.
+func (n *Node[K, A]) Mode() Mode {
+	return n.cfg.Mode
+}
+
+// Serve registers s on the endpoint of the node to answer the requests of its protocol, decoded
+// into messages of the same type as req. It returns ErrClientMode if the node is a client, which
+// must not serve the DHT, and ErrNotServerEndpoint if the endpoint cannot serve requests.
+func (n *Node[K, A]) Serve(s server.Server[K], req kad.Message) error {
+	if n.cfg.Mode == ModeClient {
+		return ErrClientMode
+	}
+	sep, ok := n.ep.(endpoint.ServerEndpoint[K, A])
+	if !ok {
+		return ErrNotServerEndpoint
+	}
+	return server.Register[K, A](sep, n.proto.ID(), req, s)
+}
+
+// Start dispatches the events of the coordinator to the lookups of the node until ctx is done. If
+// the node has a meter provider, the queue depth and routing table size of the coordinator are
+// reported until then too.
+func (n *Node[K, A]) Start(ctx context.Context) {
+	if n.cfg.MeterProvider != nil {
+		// the failure to register gauges is not worth failing the node for
+		if reg, err := coord.RegisterStats(util.Meter(n.cfg.MeterProvider), n.coord); err == nil {
+			go func() {
+				<-ctx.Done()
+				_ = reg.Unregister()
+			}()
+		}
+	}
+	go n.dispatch(ctx)
+}
+
+func (n *Node[K, A]) dispatch(ctx context.Context) {
+	events := n.coord.Events()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-events:
+			var id query.QueryID
+			switch ev := ev.(type) {
+			case *coord.KademliaOutboundQueryProgressedEvent[K, A]:
+				id = ev.QueryID
+			case *coord.KademliaOutboundQueryFinishedEvent:
+				id = ev.QueryID
+			case *coord.KademliaBootstrapFinishedEvent:
+				id = bootstrapQueryID
+			default:
+				continue
+			}
+
+			// the events are never sent blocking, so that a lookup that is behind does not hold
+			// back the coordinator and all the other lookups
+			n.mu.Lock()
+			if id == bootstrapQueryID {
+				if n.bootstrap != nil {
+					// a bootstrap only waits for the event finishing it
+					_ = n.bootstrap.TrySend(ev)
+				}
+			} else if q, ok := n.queries[id]; ok {
+				q.push(ev)
+			}
+			n.mu.Unlock()
+		}
+	}
+}
+
+// queryEvents delivers the events of a running query to the lookup receiving them. The events that
+// do not fit in the stream are held back, and the query is paused until the lookup caught up, so
+// that a lookup that is behind only holds back its own query. A paused query still reports the
+// responses to the requests it sent before it was paused, which bounds the events held back.
+type queryEvents[K kad.Key[K], A kad.Address[A]] struct {
+	id     query.QueryID
+	coord  *coord.Coordinator[K, A]
+	stream *util.Stream[coord.KademliaEvent]
+
+	mu     sync.Mutex // guards held and paused
+	held   []coord.KademliaEvent
+	paused bool
+}
+
+func newQueryEvents[K kad.Key[K], A kad.Address[A]](id query.QueryID, c *coord.Coordinator[K, A], capacity int) *queryEvents[K, A] {
+	return &queryEvents[K, A]{
+		id:     id,
+		coord:  c,
+		stream: util.NewStream[coord.KademliaEvent](capacity),
+	}
+}
+
+// push sends ev to the lookup without blocking, holding it back and pausing the query if the
+// stream is full.
+func (q *queryEvents[K, A]) push(ev coord.KademliaEvent) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.held) == 0 && q.stream.TrySend(ev) {
+		return
+	}
+	q.held = append(q.held, ev)
+	if !q.paused {
+		q.paused = true
+		q.coord.PauseQuery(q.id)
+	}
+}
+
+// recv returns the next event of the query, then moves the events held back to the stream,
+// resuming the query once they all fit.
+func (q *queryEvents[K, A]) recv(ctx context.Context) (coord.KademliaEvent, error) {
+	ev, err := q.stream.Recv(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.held) > 0 && q.stream.TrySend(q.held[0]) {
+		q.held[0] = nil
+		q.held = q.held[1:]
+	}
+	if q.paused && len(q.held) == 0 {
+		q.paused = false
+		q.coord.ResumeQuery(q.id)
+	}
+	return ev, nil
+}
+
+// onCoordinator runs fn on the goroutine driving the coordinator, which owns the routing table and
+// the endpoint, and waits for it to return. It returns the error of ctx if ctx is done before fn
+// starts, in which case fn never runs.
+func (n *Node[K, A]) onCoordinator(ctx context.Context, fn func(context.Context)) error {
+	done := make(chan struct{})
+	a := n.coord.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
+		defer close(done)
+		fn(ctx)
+	}))
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		if a.Cancel() {
+			return ctx.Err()
+		}
+		// fn is already running
+		<-done
+		return nil
+	}
+}
+
+// nearestNodes returns the count nodes of the routing table closest to kk, read from the coordinator.
+// It returns the error of ctx if ctx is done first.
+func (n *Node[K, A]) nearestNodes(ctx context.Context, kk K, count int) ([]kad.NodeID[K], error) {
+	var nodes []kad.NodeID[K]
+	err := n.onCoordinator(ctx, func(context.Context) {
+		nodes = n.rt.NearestNodes(kk, count)
+	})
+	return nodes, err
+}
+
+// lookup runs a query for req, passing each response to fn until fn returns true or the query
+// ends. It returns ErrNoPeers if the routing table holds no nodes to start the query with.
+func (n *Node[K, A]) lookup(ctx context.Context, req kad.Request[K, A], fn func(kad.NodeID[K], kad.Response[K, A]) bool) error {
+	n.mu.Lock()
+	n.nextQuery++
+	id := query.QueryID("dht-" + strconv.FormatUint(n.nextQuery, 10))
+	events := newQueryEvents[K, A](id, n.coord, n.cfg.ResultCapacity)
+	n.queries[id] = events
+	n.mu.Unlock()
+
+	defer func() {
+		n.mu.Lock()
+		delete(n.queries, id)
+		n.mu.Unlock()
+		events.stream.Cancel()
+	}()
+
+	var started error
+	if err := n.onCoordinator(ctx, func(ctx context.Context) {
+		if len(n.rt.NearestNodes(req.Target(), 1)) == 0 {
+			started = ErrNoPeers
+			return
+		}
+		started = n.coord.StartQuery(ctx, id, n.proto.ID(), req)
+	}); err != nil {
+		return err
+	}
+	if started != nil {
+		return started
+	}
+	for {
+		ev, err := events.recv(ctx)
+		if err != nil {
+			_ = n.coord.StopQuery(context.Background(), id)
+			return err
+		}
+		switch ev := ev.(type) {
+		case *coord.KademliaOutboundQueryProgressedEvent[K, A]:
+			if fn(ev.NodeID, ev.Response) {
+				return n.coord.StopQuery(ctx, id)
+			}
+		case *coord.KademliaOutboundQueryFinishedEvent:
+			return nil
+		}
+	}
+}
+
+// FindPeer looks up the information of the node id, returning ErrNotFound if no node knows it.
+func (n *Node[K, A]) FindPeer(ctx context.Context, id kad.NodeID[K]) (kad.NodeInfo[K, A], error) {
+	ctx, span := util.StartSpan(ctx, "Node.FindPeer", trace.WithAttributes(attribute.Stringer("id", id)))
+	defer span.End()
+
+	req, err := n.proto.FindPeerRequest(id)
+	if err != nil {
+		return nil, err
+	}
+	found, shared, err := n.peerFlights.do(ctx, flightKey(id.Key()), func(ctx context.Context) (kad.NodeInfo[K, A], error) {
+		var found kad.NodeInfo[K, A]
+		err := n.lookup(ctx, req, func(from kad.NodeID[K], resp kad.Response[K, A]) bool {
+			for _, info := range resp.CloserNodes() {
+				if key.Equal(info.ID().Key(), id.Key()) {
+					found = info
+					return true
+				}
+			}
+			return false
+		})
+		return found, err
+	})
+	span.SetAttributes(attribute.Bool("shared", shared))
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	return found, nil
+}
+
+// GetClosestPeers looks up the nodes closest to the target of key and returns the Replication
+// closest nodes that responded, ordered by increasing distance to the target. If the node has a
+// cache holding the closest nodes to the target, they are returned without a lookup.
+func (n *Node[K, A]) GetClosestPeers(ctx context.Context, key []byte) ([]kad.NodeID[K], error) {
+	ctx, span := util.StartSpan(ctx, "Node.GetClosestPeers")
+	defer span.End()
+
+	peers, err := n.findClosestPeers(ctx, key)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return peers, nil
+}
+
+// findClosestPeers returns the closest nodes to the target of key held by the cache of the node,
+// if any, or looks them up and caches them, sharing the lookup running for the same target, if any.
+func (n *Node[K, A]) findClosestPeers(ctx context.Context, key []byte) ([]kad.NodeID[K], error) {
+	req := n.proto.FindNodeRequest(key)
+	target := req.Target()
+	if n.cfg.Cache != nil {
+		if peers, ok := n.cfg.Cache.ClosestPeers(target); ok {
+			return peers, nil
+		}
+	}
+	peers, shared, err := n.closestFlights.do(ctx, flightKey(target), func(ctx context.Context) ([]kad.NodeID[K], error) {
+		peers, err := n.closestPeers(ctx, req, nil)
+		if err != nil {
+			return nil, err
+		}
+		if n.cfg.Cache != nil && len(peers) > 0 {
+			n.cfg.Cache.SetClosestPeers(target, peers)
+		}
+		return peers, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if shared {
+		// each caller owns the slice it is returned
+		peers = append([]kad.NodeID[K](nil), peers...)
+	}
+	return peers, nil
+}
+
+// closestPeers returns the Replication closest nodes to the target of req that responded to a
+// query for req. If fn is not nil, it is passed each response.
+func (n *Node[K, A]) closestPeers(ctx context.Context, req kad.Request[K, A], fn func(kad.NodeID[K], kad.Response[K, A])) ([]kad.NodeID[K], error) {
+	var peers []kad.NodeID[K]
+	err := n.lookup(ctx, req, func(from kad.NodeID[K], resp kad.Response[K, A]) bool {
+		if fn != nil {
+			fn(from, resp)
+		}
+		peers = append(peers, from)
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	target := req.Target()
+	sort.SliceStable(peers, func(i, j int) bool {
+		return key.DistanceCmp(target, peers[i].Key(), peers[j].Key()) < 0
+	})
+	if len(peers) > n.cfg.Replication {
+		peers = peers[:n.cfg.Replication]
+	}
+	return peers, nil
+}
+
+// PutValue stores value under key in the local record store, if any, and at the closest nodes to
+// the target of key. It returns an error if no node stored the value. The value replaces the one
+// cached for key, if the node has a cache.
+func (n *Node[K, A]) PutValue(ctx context.Context, key, value []byte) error {
+	ctx, span := util.StartSpan(ctx, "Node.PutValue")
+	defer span.End()
+
+	if n.cfg.Records != nil {
+		if err := n.cfg.Records.Put(ctx, &records.Record{Key: key, Value: value}); err != nil {
+			span.RecordError(err)
+			return fmt.Errorf("store locally: %w", err)
+		}
+	}
+	get := n.proto.GetValueRequest(key)
+	if err := n.store(ctx, key, get, n.proto.PutValueRequest(key, value)); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if n.cfg.Cache != nil {
+		n.cfg.Cache.SetValue(get.Target(), value)
+	}
+	return nil
+}
+
+// GetValue returns the value stored under key in the local record store, if any, then the value
+// cached for key, if the node has a cache, or else the first value found by looking key up, which
+// is cached. Concurrent calls for the same key share the lookup. It returns ErrNotFound if no node
+// holds a value for key.
+func (n *Node[K, A]) GetValue(ctx context.Context, key []byte) ([]byte, error) {
+	ctx, span := util.StartSpan(ctx, "Node.GetValue")
+	defer span.End()
+
+	if n.cfg.Records != nil {
+		if rec, err := n.cfg.Records.Get(ctx, key); err == nil {
+			return rec.Value, nil
+		}
+	}
+	req := n.proto.GetValueRequest(key)
+	if n.cfg.Cache != nil {
+		if value, ok := n.cfg.Cache.Value(req.Target()); ok {
+			return value, nil
+		}
+	}
+	value, shared, err := n.valueFlights.do(ctx, string(key), func(ctx context.Context) ([]byte, error) {
+		var value []byte
+		found := false
+		err := n.lookup(ctx, req, func(from kad.NodeID[K], resp kad.Response[K, A]) bool {
+			value, found = n.proto.Value(resp)
+			return found
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, ErrNotFound
+		}
+		if n.cfg.Cache != nil {
+			n.cfg.Cache.SetValue(req.Target(), value)
+		}
+		return value, nil
+	})
+	span.SetAttributes(attribute.Bool("shared", shared))
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	if shared {
+		// each caller owns the value it is returned
+		value = append([]byte(nil), value...)
+	}
+	return value, nil
+}
+
+// Provide announces that the node provides the content of key, recording it in the local provider
+// store, if any, and at the closest nodes to the target of key. It returns an error if no node
+// recorded the announcement.
+func (n *Node[K, A]) Provide(ctx context.Context, key []byte) error {
+	ctx, span := util.StartSpan(ctx, "Node.Provide")
+	defer span.End()
+
+	if err := n.ProvideLocally(ctx, key); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	req, err := n.proto.AddProviderRequest(key, n.self)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if err := n.store(ctx, key, n.proto.GetProvidersRequest(key), req); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return nil
+}
+
+// ProvideLocally records that the node provides the content of key in the local provider store,
+// if any, without announcing it to the network. The node then serves itself as a provider of key
+// to the nodes asking it, and returns itself from FindProviders.
+func (n *Node[K, A]) ProvideLocally(ctx context.Context, key []byte) error {
+	if n.cfg.Providers == nil {
+		return nil
+	}
+	if err := n.cfg.Providers.AddProvider(ctx, key, n.self); err != nil {
+		return fmt.Errorf("store locally: %w", err)
+	}
+	return nil
+}
+
+// store sends req to the closest nodes to the target of key, returning an error if all of them
+// failed. The closest nodes are looked up with a request to find the nodes closest to key, so that
+// only they receive req, unless the cache of the node holds them. If the node holds tokens and the protocol is a TokenProtocol, they are
+// looked up with get instead, and req carries the token each of them issued in its response to
+// get. The token of a node rejecting req is forgotten.
+func (n *Node[K, A]) store(ctx context.Context, key []byte, get, req kad.Request[K, A]) error {
+	tp, ok := n.proto.(TokenProtocol[K, A])
+	useTokens := ok && n.cfg.Tokens != nil
+
+	var peers []kad.NodeID[K]
+	var err error
+	if useTokens {
+		peers, err = n.closestPeers(ctx, get, func(from kad.NodeID[K], resp kad.Response[K, A]) {
+			if tok, ok := tp.Token(resp); ok {
+				n.cfg.Tokens.Set(from, tok)
+			}
+		})
+	} else {
+		peers, err = n.findClosestPeers(ctx, key)
+	}
+	if err != nil {
+		return err
+	}
+	if len(peers) == 0 {
+		return ErrNoPeers
+	}
+
+	// the requests are sent from the coordinator, which also runs the queries using the endpoint
+	errs := make(chan error, len(peers))
+	protoID := n.proto.ID()
+	for _, p := range peers {
+		p := p
+		preq := req
+		if useTokens {
+			if tok, ok := n.cfg.Tokens.Get(p); ok {
+				preq = tp.WithToken(req, tok)
+			}
+		}
+		n.coord.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
+			err := n.ep.SendRequestHandleResponse(ctx, protoID, p, preq, preq.EmptyResponse(), n.cfg.RequestTimeout,
+				func(ctx context.Context, resp kad.Response[K, A], err error) {
+					if err != nil && useTokens {
+						n.cfg.Tokens.Delete(p)
+					}
+					errs <- err
+				})
+			if err != nil {
+				errs <- err
+			}
+		}))
+	}
+
+	var last error
+	stored := 0
+	for range peers {
+		select {
+		case err := <-errs:
+			if err != nil {
+				last = err
+			} else {
+				stored++
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if stored == 0 {
+		return fmt.Errorf("%w: no node accepted the request: %v", ErrNoPeers, last)
+	}
+	return nil
+}
+
+// StreamClosestPeers looks up the nodes closest to the target of key and streams the nodes as
+// they respond, unlike GetClosestPeers which returns the closest of them once the lookup ended. A
+// consumer slower than the lookup pauses it once the buffers of the stream are full, without
+// holding back the other lookups of the node. The stream is closed when the lookup ends, with the error of the lookup if it failed, and
+// cancelling it stops the lookup.
+func (n *Node[K, A]) StreamClosestPeers(ctx context.Context, key []byte) *util.Stream[kad.NodeID[K]] {
+	s := util.NewStream[kad.NodeID[K]](n.cfg.ResultCapacity)
+	go streamLookup(ctx, "Node.StreamClosestPeers", s, func(ctx context.Context) error {
+		var sendErr error
+		err := n.lookup(ctx, n.proto.FindNodeRequest(key), func(from kad.NodeID[K], resp kad.Response[K, A]) bool {
+			sendErr = s.Send(ctx, from)
+			return sendErr != nil
+		})
+		if err != nil {
+			return err
+		}
+		return sendErr
+	})
+	return s
+}
+
+// FindProviders looks up the providers of key and streams them, starting with the providers
+// known to the local provider store, if any, each provider being sent once. The stream is closed
+// when the lookup ends, with the error of the lookup if it failed, and cancelling it stops the
+// lookup.
+func (n *Node[K, A]) FindProviders(ctx context.Context, key []byte) *util.Stream[kad.NodeInfo[K, A]] {
+	s := util.NewStream[kad.NodeInfo[K, A]](n.cfg.ResultCapacity)
+	go streamLookup(ctx, "Node.FindProviders", s, func(ctx context.Context) error {
+		seen := make(map[string]bool)
+		send := func(provs []kad.NodeInfo[K, A]) error {
+			for _, p := range provs {
+				if seen[p.ID().String()] {
+					continue
+				}
+				seen[p.ID().String()] = true
+				if err := s.Send(ctx, p); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if n.cfg.Providers != nil {
+			if provs, err := n.cfg.Providers.GetProviders(ctx, key); err == nil {
+				if err := send(provs); err != nil {
+					return err
+				}
+			}
+		}
+		var sendErr error
+		err := n.lookup(ctx, n.proto.GetProvidersRequest(key), func(from kad.NodeID[K], resp kad.Response[K, A]) bool {
+			sendErr = send(n.proto.Providers(resp))
+			return sendErr != nil
+		})
+		if err != nil {
+			return err
+		}
+		return sendErr
+	})
+	return s
+}
+
+// streamLookup runs a lookup producing the results of s, stopping it when the consumer cancels s,
+// and closes s with the error of the lookup.
+func streamLookup[T any](ctx context.Context, name string, s *util.Stream[T], run func(ctx context.Context) error) {
+	ctx, span := util.StartSpan(ctx, name)
+	defer span.End()
+
+	// the lookup stops when the consumer cancels the stream
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := run(ctx)
+	select {
+	case <-s.Done():
+		// the consumer is no longer interested in the outcome
+		err = nil
+	default:
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	s.Close(err)
+}
+
+// Bootstrap adds seeds to the routing table and runs the bootstrap of the coordinator, looking up
+// the node itself from the seeds and the closest nodes of the routing table to fill the routing
+// table with the nodes close to it. It returns once the bootstrap is finished, with ErrNoPeers if
+// no node responded, and ErrBootstrapping if another bootstrap is in progress.
+func (n *Node[K, A]) Bootstrap(ctx context.Context, seeds []kad.NodeInfo[K, A]) error {
+	ctx, span := util.StartSpan(ctx, "Node.Bootstrap")
+	defer span.End()
+
+	req, err := n.proto.FindPeerRequest(n.self.ID())
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	n.mu.Lock()
+	if n.bootstrap != nil {
+		n.mu.Unlock()
+		return ErrBootstrapping
+	}
+	events := util.NewStream[coord.KademliaEvent](n.cfg.ResultCapacity)
+	n.bootstrap = events
+	n.mu.Unlock()
+
+	defer func() {
+		n.mu.Lock()
+		n.bootstrap = nil
+		n.mu.Unlock()
+		events.Cancel()
+	}()
+
+	var started error
+	err = n.onCoordinator(ctx, func(ctx context.Context) {
+		if started = n.coord.AddNodes(ctx, seeds); started != nil {
+			return
+		}
+		ids := make([]kad.NodeID[K], 0, len(seeds)+n.cfg.Replication)
+		seen := make(map[string]bool)
+		for _, s := range seeds {
+			ids = append(ids, s.ID())
+			seen[s.ID().String()] = true
+		}
+		for _, id := range n.rt.NearestNodes(n.self.ID().Key(), n.cfg.Replication) {
+			if !seen[id.String()] {
+				ids = append(ids, id)
+			}
+		}
+		started = n.coord.Bootstrap(ctx, ids, func(kad.NodeID[K]) (address.ProtocolID, kad.Request[K, A]) {
+			return n.proto.ID(), req
+		})
+	})
+	if err == nil {
+		err = started
+	}
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	for {
+		ev, err := events.Recv(ctx)
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+		if ev, ok := ev.(*coord.KademliaBootstrapFinishedEvent); ok {
+			if ev.Stats.Success == 0 {
+				return ErrNoPeers
+			}
+			return nil
+		}
+	}
+}
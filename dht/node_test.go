@@ -0,0 +1,768 @@
+package dht
+
+import (
+	"context"
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/plprobelab/go-kademlia/coord"
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/peerstore"
+	"github.com/plprobelab/go-kademlia/records"
+	"github.com/plprobelab/go-kademlia/routing/simplert"
+	"github.com/plprobelab/go-kademlia/server"
+	"github.com/plprobelab/go-kademlia/server/token"
+	"github.com/plprobelab/go-kademlia/sim"
+	"github.com/plprobelab/go-kademlia/util"
+)
+
+type (
+	testKey  = key.Key8
+	testInfo = kad.NodeInfo[testKey, kadtest.StrAddr]
+)
+
+const protoID = address.ProtocolID("/test/dht/1.0.0")
+
+type requestKind int
+
+const (
+	findNode requestKind = iota
+	getValue
+	putValue
+	getProviders
+	addProvider
+)
+
+// testRequest is a request of the test protocol, whose keys have as target the first byte of
+// their SHA-256 hash.
+type testRequest struct {
+	kind     requestKind
+	target   testKey
+	key      []byte
+	value    []byte
+	provider testInfo
+	token    []byte
+}
+
+func (r *testRequest) Target() testKey { return r.target }
+
+func (r *testRequest) EmptyResponse() kad.Response[testKey, kadtest.StrAddr] {
+	return &testResponse{}
+}
+
+type testResponse struct {
+	closer    []testInfo
+	value     []byte
+	providers []testInfo
+	token     []byte
+}
+
+func (r *testResponse) CloserNodes() []testInfo { return r.closer }
+
+type testProtocol struct{}
+
+var _ TokenProtocol[testKey, kadtest.StrAddr] = testProtocol{}
+
+func targetOf(k []byte) testKey {
+	h := sha256.Sum256(k)
+	return testKey(h[0])
+}
+
+func (testProtocol) ID() address.ProtocolID { return protoID }
+
+func (testProtocol) FindPeerRequest(id kad.NodeID[testKey]) (kad.Request[testKey, kadtest.StrAddr], error) {
+	return &testRequest{kind: findNode, target: id.Key()}, nil
+}
+
+func (testProtocol) FindNodeRequest(k []byte) kad.Request[testKey, kadtest.StrAddr] {
+	return &testRequest{kind: findNode, target: targetOf(k), key: k}
+}
+
+func (testProtocol) GetValueRequest(k []byte) kad.Request[testKey, kadtest.StrAddr] {
+	return &testRequest{kind: getValue, target: targetOf(k), key: k}
+}
+
+func (testProtocol) PutValueRequest(k, v []byte) kad.Request[testKey, kadtest.StrAddr] {
+	return &testRequest{kind: putValue, target: targetOf(k), key: k, value: v}
+}
+
+func (testProtocol) GetProvidersRequest(k []byte) kad.Request[testKey, kadtest.StrAddr] {
+	return &testRequest{kind: getProviders, target: targetOf(k), key: k}
+}
+
+func (testProtocol) AddProviderRequest(k []byte, prov testInfo) (kad.Request[testKey, kadtest.StrAddr], error) {
+	return &testRequest{kind: addProvider, target: targetOf(k), key: k, provider: prov}, nil
+}
+
+func (testProtocol) Value(resp kad.Response[testKey, kadtest.StrAddr]) ([]byte, bool) {
+	r := resp.(*testResponse)
+	return r.value, r.value != nil
+}
+
+func (testProtocol) Providers(resp kad.Response[testKey, kadtest.StrAddr]) []testInfo {
+	return resp.(*testResponse).providers
+}
+
+func (testProtocol) Token(resp kad.Response[testKey, kadtest.StrAddr]) ([]byte, bool) {
+	r := resp.(*testResponse)
+	return r.token, r.token != nil
+}
+
+func (testProtocol) WithToken(req kad.Request[testKey, kadtest.StrAddr], tok []byte) kad.Request[testKey, kadtest.StrAddr] {
+	r := *req.(*testRequest)
+	r.token = tok
+	return &r
+}
+
+// testServer serves the requests of the test protocol from the routing table of a node and
+// stores the values and providers it is sent. If tokens is set, it requires write tokens.
+type testServer struct {
+	info      testInfo
+	rt        kad.RoutingTable[testKey, kad.NodeID[testKey]]
+	ep        *sim.Endpoint[testKey, kadtest.StrAddr]
+	values    map[string][]byte
+	providers map[string][]testInfo
+	tokens    *token.Manager[testKey]
+}
+
+func (s *testServer) handle(ctx context.Context, from kad.NodeID[testKey], msg kad.Message) (kad.Message, error) {
+	req := msg.(*testRequest)
+	resp := &testResponse{}
+	for _, id := range s.rt.NearestNodes(req.target, 4) {
+		if info, err := s.ep.NetworkAddress(id); err == nil {
+			resp.closer = append(resp.closer, info)
+		}
+	}
+	if s.tokens != nil {
+		switch req.kind {
+		case getValue, getProviders:
+			tok, err := s.tokens.Issue(from)
+			if err != nil {
+				return nil, err
+			}
+			resp.token = tok
+		case putValue, addProvider:
+			if err := s.tokens.Check(from, req.token); err != nil {
+				return nil, err
+			}
+		}
+	}
+	switch req.kind {
+	case getValue:
+		resp.value = s.values[string(req.key)]
+	case putValue:
+		s.values[string(req.key)] = req.value
+	case getProviders:
+		resp.providers = s.providers[string(req.key)]
+	case addProvider:
+		s.providers[string(req.key)] = append(s.providers[string(req.key)], req.provider)
+	}
+	return resp, nil
+}
+
+type testNetwork struct {
+	infos   []testInfo
+	eps     []*sim.Endpoint[testKey, kadtest.StrAddr]
+	rts     []kad.RoutingTable[testKey, kad.NodeID[testKey]]
+	servers []*testServer
+	scheds  []event.AwareScheduler
+	clk     *clock.Mock
+	router  *sim.Router[testKey, kadtest.StrAddr]
+}
+
+// newTestNetwork creates n nodes with keys 0x00, 0x20, 0x40... each knowing the nodes before and
+// after it.
+func newTestNetwork(t *testing.T, n int) *testNetwork {
+	router := sim.NewRouter[testKey, kadtest.StrAddr]()
+	net := &testNetwork{clk: clock.NewMock(), router: router}
+	for i := 0; i < n; i++ {
+		info := kadtest.NewInfo[testKey, kadtest.StrAddr](kadtest.NewID(testKey(i*0x20)), nil)
+		sched := event.NewSimpleScheduler(net.clk)
+		ep := sim.NewEndpoint[testKey, kadtest.StrAddr](info.ID(), sched, router)
+		rt := simplert.New[testKey, kad.NodeID[testKey]](info.ID(), 4)
+		srv := &testServer{info: info, rt: rt, ep: ep, values: make(map[string][]byte), providers: make(map[string][]testInfo)}
+		require.NoError(t, ep.AddRequestHandler(protoID, &testRequest{}, srv.handle))
+
+		net.infos = append(net.infos, info)
+		net.eps = append(net.eps, ep)
+		net.rts = append(net.rts, rt)
+		net.servers = append(net.servers, srv)
+		net.scheds = append(net.scheds, sched)
+	}
+	for i := 1; i < n; i++ {
+		net.connect(i-1, i)
+	}
+	return net
+}
+
+func (net *testNetwork) connect(i, j int) {
+	ctx := context.Background()
+	net.eps[i].MaybeAddToPeerstore(ctx, net.infos[j], peerstore.PermanentTTL)
+	net.rts[i].AddNode(net.infos[j].ID())
+	net.eps[j].MaybeAddToPeerstore(ctx, net.infos[i], peerstore.PermanentTTL)
+	net.rts[j].AddNode(net.infos[i].ID())
+}
+
+// newNode creates a dht.Node for node i.
+func (net *testNetwork) newNode(t *testing.T, i int, cfg *Config[testKey, kadtest.StrAddr]) *Node[testKey, kadtest.StrAddr] {
+	if cfg == nil {
+		cfg = DefaultConfig[testKey, kadtest.StrAddr]()
+	}
+	ccfg := *cfg
+	ccfg.Coordinator = defaultCoordinatorConfig(net.clk)
+	ccfg.Replication = 3
+	n, err := NewNode[testKey, kadtest.StrAddr](net.infos[i], net.eps[i], net.rts[i], testProtocol{}, &ccfg)
+	require.NoError(t, err)
+	net.scheds = append(net.scheds, n.Scheduler())
+	return n
+}
+
+// run starts the nodes and drives the simulation of the network until ctx is done.
+func (net *testNetwork) run(ctx context.Context, nodes ...*Node[testKey, kadtest.StrAddr]) {
+	siml := sim.NewLiteSimulator(net.clk)
+	sim.AddSchedulers(siml, net.scheds...)
+	for _, n := range nodes {
+		n.Start(ctx)
+	}
+	go func() {
+		for {
+			select {
+			case <-time.After(time.Millisecond):
+				siml.Run(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := DefaultConfig[testKey, kadtest.StrAddr]()
+	require.NoError(t, cfg.Validate())
+
+	cfg.Replication = 0
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultConfig[testKey, kadtest.StrAddr]()
+	cfg.RequestTimeout = 0
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultConfig[testKey, kadtest.StrAddr]()
+	cfg.ResultCapacity = 0
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultConfig[testKey, kadtest.StrAddr]()
+	cfg.Mode = ModeClient + 1
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultConfig[testKey, kadtest.StrAddr]()
+	cfg.Coordinator = defaultCoordinatorConfig(nil)
+	require.Error(t, cfg.Validate())
+}
+
+func TestConfigFrom(t *testing.T) {
+	kcfg := kad.DefaultConfig()
+	kcfg.BucketSize = 8
+	kcfg.Concurrency = 5
+	kcfg.RequestTimeout = 10 * time.Second
+	kcfg.QueryTimeout = time.Minute
+
+	cfg := ConfigFrom[testKey, kadtest.StrAddr](kcfg)
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, 8, cfg.Replication)
+	require.Equal(t, 10*time.Second, cfg.RequestTimeout)
+
+	// the coordinator, its queries and the servers share the parameters
+	require.Equal(t, 5, cfg.Coordinator.RequestConcurrency)
+	require.Equal(t, 10*time.Second, cfg.Coordinator.RequestTimeout)
+	require.Equal(t, time.Minute, cfg.Coordinator.QueryTimeout)
+
+	// the coordinator keeps its own peerstore ttl
+	require.Equal(t, 10*time.Minute, cfg.Coordinator.PeerstoreTTL)
+}
+
+func TestNodeLookups(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	net := newTestNetwork(t, 8)
+	first := net.newNode(t, 0, nil)
+	last := net.newNode(t, 7, nil)
+	net.run(ctx, first, last)
+
+	t.Run("find peer", func(t *testing.T) {
+		info, err := first.FindPeer(ctx, net.infos[7].ID())
+		require.NoError(t, err)
+		require.Equal(t, net.infos[7].ID(), info.ID())
+
+		_, err = first.FindPeer(ctx, kadtest.NewID(testKey(0x01)))
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("closest peers", func(t *testing.T) {
+		k := []byte("closest")
+		peers, err := first.GetClosestPeers(ctx, k)
+		require.NoError(t, err)
+		require.Len(t, peers, 3)
+		target := targetOf(k)
+		for i := 1; i < len(peers); i++ {
+			require.Negative(t, key.DistanceCmp(target, peers[i-1].Key(), peers[i].Key()))
+		}
+		// the closest of all the nodes but the one running the lookup
+		closest := net.infos[1].ID()
+		for _, info := range net.infos[1:] {
+			if key.DistanceCmp(target, info.ID().Key(), closest.Key()) < 0 {
+				closest = info.ID()
+			}
+		}
+		require.Equal(t, closest, peers[0])
+	})
+
+	t.Run("values", func(t *testing.T) {
+		require.NoError(t, first.PutValue(ctx, []byte("k"), []byte("v")))
+		stored := 0
+		for _, s := range net.servers {
+			if string(s.values["k"]) == "v" {
+				stored++
+			}
+		}
+		require.Equal(t, 3, stored)
+
+		v, err := last.GetValue(ctx, []byte("k"))
+		require.NoError(t, err)
+		require.Equal(t, []byte("v"), v)
+
+		_, err = last.GetValue(ctx, []byte("missing"))
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("providers", func(t *testing.T) {
+		require.NoError(t, first.Provide(ctx, []byte("content")))
+
+		s := last.FindProviders(ctx, []byte("content"))
+		prov, err := s.Recv(ctx)
+		require.NoError(t, err)
+		require.Equal(t, net.infos[0].ID(), prov.ID())
+		s.Cancel()
+	})
+}
+
+func TestNodeStreamClosestPeers(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	net := newTestNetwork(t, 8)
+	cfg := DefaultConfig[testKey, kadtest.StrAddr]()
+	cfg.ResultCapacity = 1
+	n := net.newNode(t, 0, cfg)
+	net.run(ctx, n)
+
+	// all the nodes respond to the lookup, the slow consumer does not lose any of them
+	s := n.StreamClosestPeers(ctx, []byte("closest"))
+	seen := make(map[string]bool)
+	for {
+		id, err := s.Recv(ctx)
+		if err != nil {
+			require.ErrorIs(t, err, util.ErrStreamClosed)
+			break
+		}
+		require.False(t, seen[id.String()])
+		seen[id.String()] = true
+		time.Sleep(time.Millisecond)
+	}
+	require.Len(t, seen, 7)
+
+	// cancelling the stream stops the lookup
+	s = n.StreamClosestPeers(ctx, []byte("closest"))
+	_, err := s.Recv(ctx)
+	require.NoError(t, err)
+	s.Cancel()
+	for err == nil {
+		_, err = s.Recv(ctx)
+	}
+	require.ErrorIs(t, err, util.ErrStreamClosed)
+}
+
+func TestNodeStalledConsumer(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	net := newTestNetwork(t, 8)
+	cfg := DefaultConfig[testKey, kadtest.StrAddr]()
+	cfg.ResultCapacity = 1
+	n := net.newNode(t, 0, cfg)
+	net.run(ctx, n)
+
+	// the consumer of the stream does not read it, which pauses its lookup only
+	stalled := n.StreamClosestPeers(ctx, []byte("stalled"))
+	for i := 0; i < 3; i++ {
+		peers, err := n.GetClosestPeers(ctx, []byte{byte(i)})
+		require.NoError(t, err)
+		require.NotEmpty(t, peers)
+	}
+	_, err := n.FindPeer(ctx, net.infos[7].ID())
+	require.NoError(t, err)
+
+	// the paused lookup resumes once the consumer reads the stream, without losing any node
+	seen := make(map[string]bool)
+	for {
+		id, err := stalled.Recv(ctx)
+		if err != nil {
+			require.ErrorIs(t, err, util.ErrStreamClosed)
+			break
+		}
+		seen[id.String()] = true
+	}
+	require.Len(t, seen, 7)
+}
+
+func TestNodeLocalStores(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	net := newTestNetwork(t, 3)
+
+	cfg := DefaultConfig[testKey, kadtest.StrAddr]()
+	rcfg := records.DefaultConfig()
+	rcfg.Clock = net.clk
+	recs, err := records.NewMemoryStore(rcfg)
+	require.NoError(t, err)
+	cfg.Records = recs
+	pcfg := records.DefaultProviderConfig()
+	pcfg.Clock = net.clk
+	provs, err := records.NewProviderStore[testKey, kadtest.StrAddr](pcfg)
+	require.NoError(t, err)
+	cfg.Providers = provs
+
+	n := net.newNode(t, 0, cfg)
+	net.run(ctx, n)
+
+	require.NoError(t, n.PutValue(ctx, []byte("k"), []byte("v")))
+	rec, err := recs.Get(ctx, []byte("k"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("v"), rec.Value)
+
+	// values are found locally without a lookup
+	for _, s := range net.servers {
+		delete(s.values, "k")
+	}
+	v, err := n.GetValue(ctx, []byte("k"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("v"), v)
+
+	// the local providers come first and are not repeated
+	require.NoError(t, n.Provide(ctx, []byte("content")))
+	s := n.FindProviders(ctx, []byte("content"))
+	var found []testInfo
+	for {
+		p, err := s.Recv(ctx)
+		if err != nil {
+			require.ErrorIs(t, err, util.ErrStreamClosed)
+			break
+		}
+		found = append(found, p)
+	}
+	require.Len(t, found, 1)
+	require.Equal(t, net.infos[0].ID(), found[0].ID())
+}
+
+func TestNodeBootstrap(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	net := newTestNetwork(t, 6)
+	// node 5 is not yet connected to the others
+	net.rts[4].RemoveKey(net.infos[5].ID().Key())
+	net.rts[5].RemoveKey(net.infos[4].ID().Key())
+
+	n := net.newNode(t, 5, nil)
+	net.run(ctx, n)
+
+	_, err := n.GetClosestPeers(ctx, []byte("k"))
+	require.ErrorIs(t, err, ErrNoPeers)
+
+	require.NoError(t, n.Bootstrap(ctx, []testInfo{net.infos[0]}))
+	require.Greater(t, len(net.rts[5].NearestNodes(net.infos[5].ID().Key(), 10)), 1)
+
+	info, err := n.FindPeer(ctx, net.infos[4].ID())
+	require.NoError(t, err)
+	require.Equal(t, net.infos[4].ID(), info.ID())
+}
+
+func TestNodeNoPeers(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	net := newTestNetwork(t, 1)
+	n := net.newNode(t, 0, nil)
+	net.run(ctx, n)
+
+	require.ErrorIs(t, n.PutValue(ctx, []byte("k"), []byte("v")), ErrNoPeers)
+	_, err := n.GetValue(ctx, []byte("k"))
+	require.ErrorIs(t, err, ErrNoPeers)
+	_, err = n.FindProviders(ctx, []byte("k")).Recv(ctx)
+	require.ErrorIs(t, err, ErrNoPeers)
+}
+
+func defaultCoordinatorConfig(clk clock.Clock) *coord.Config {
+	cfg := coord.DefaultConfig()
+	cfg.Clock = clk
+	return cfg
+}
+
+func TestNodeTokens(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	net := newTestNetwork(t, 8)
+	for _, s := range net.servers {
+		cfg := token.DefaultConfig()
+		cfg.Clock = net.clk
+		m, err := token.NewManager[testKey](cfg)
+		require.NoError(t, err)
+		s.tokens = m
+	}
+	cfg := DefaultConfig[testKey, kadtest.StrAddr]()
+	cfg.Tokens = token.NewTokens[testKey]()
+	n := net.newNode(t, 0, cfg)
+	net.run(ctx, n)
+
+	// the node presents the tokens issued in the responses to its lookup
+	require.NoError(t, n.PutValue(ctx, []byte("k"), []byte("v")))
+	stored := 0
+	for _, s := range net.servers {
+		if string(s.values["k"]) == "v" {
+			stored++
+			_, ok := cfg.Tokens.Get(s.info.ID())
+			require.True(t, ok)
+		}
+	}
+	require.Equal(t, 3, stored)
+
+	require.NoError(t, n.Provide(ctx, []byte("content")))
+	announced := 0
+	for _, s := range net.servers {
+		announced += len(s.providers["content"])
+	}
+	require.Equal(t, 3, announced)
+}
+
+func TestNodeClientMode(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	net := newTestNetwork(t, 4)
+	require.Equal(t, ModeServer, net.newNode(t, 0, nil).Mode())
+
+	// the client joins the network through node 0, without serving the DHT
+	info := kadtest.NewInfo[testKey, kadtest.StrAddr](kadtest.NewID(testKey(0x90)), nil)
+	sched := event.NewSimpleScheduler(net.clk)
+	ep := sim.NewEndpoint[testKey, kadtest.StrAddr](info.ID(), sched, net.router)
+	rt := simplert.New[testKey, kad.NodeID[testKey]](info.ID(), 4)
+	ep.MaybeAddToPeerstore(ctx, net.infos[0], time.Hour)
+	rt.AddNode(net.infos[0].ID())
+	net.scheds = append(net.scheds, sched)
+
+	cfg := DefaultConfig[testKey, kadtest.StrAddr]()
+	cfg.Mode = ModeClient
+	cfg.Coordinator = defaultCoordinatorConfig(net.clk)
+	cfg.Replication = 3
+	client, err := NewNode[testKey, kadtest.StrAddr](info, ep, rt, testProtocol{}, cfg)
+	require.NoError(t, err)
+	net.scheds = append(net.scheds, client.Scheduler())
+	net.run(ctx, client)
+
+	s := &testServer{info: info, rt: rt, ep: ep, values: make(map[string][]byte), providers: make(map[string][]testInfo)}
+	require.ErrorIs(t, client.Serve(server.HandlerFunc[testKey](s.handle), &testRequest{}), ErrClientMode)
+
+	// the client runs queries
+	peers, err := client.GetClosestPeers(ctx, []byte("k"))
+	require.NoError(t, err)
+	require.Len(t, peers, 3)
+
+	// the servers can tell the client apart
+	require.True(t, net.eps[0].PeerSupportsProtocol(net.infos[1].ID(), protoID))
+	require.False(t, net.eps[0].PeerSupportsProtocol(info.ID(), protoID))
+
+	// a node in server mode advertises the protocol once it serves it
+	info = kadtest.NewInfo[testKey, kadtest.StrAddr](kadtest.NewID(testKey(0xa0)), nil)
+	ep = sim.NewEndpoint[testKey, kadtest.StrAddr](info.ID(), sched, net.router)
+	rt = simplert.New[testKey, kad.NodeID[testKey]](info.ID(), 4)
+	cfg.Mode = ModeServer
+	n, err := NewNode[testKey, kadtest.StrAddr](info, ep, rt, testProtocol{}, cfg)
+	require.NoError(t, err)
+	require.False(t, net.eps[0].PeerSupportsProtocol(info.ID(), protoID))
+	require.NoError(t, n.Serve(server.HandlerFunc[testKey](s.handle), &testRequest{}))
+	require.True(t, net.eps[0].PeerSupportsProtocol(info.ID(), protoID))
+}
+
+func TestNodeMetrics(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	mp := kadtest.NewMeterProvider()
+	net := newTestNetwork(t, 4)
+	cfg := DefaultConfig[testKey, kadtest.StrAddr]()
+	cfg.MeterProvider = mp
+	n := net.newNode(t, 0, cfg)
+	net.run(ctx, n)
+
+	_, err := n.GetClosestPeers(ctx, []byte("k"))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return mp.Count("query.hops", attribute.String("kind", "query")) == 1
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, mp.Collect(ctx))
+	require.Equal(t, 1, mp.Count("coordinator.rt.size"))
+	require.NotZero(t, mp.Sum("coordinator.rt.size"))
+}
+
+func TestNodeCache(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	net := newTestNetwork(t, 4)
+	ccfg := DefaultLookupCacheConfig()
+	ccfg.Clock = net.clk
+	ccfg.TTL = time.Hour
+	cache, err := NewLookupCache[testKey](ccfg)
+	require.NoError(t, err)
+
+	cfg := DefaultConfig[testKey, kadtest.StrAddr]()
+	cfg.Cache = cache
+	n := net.newNode(t, 0, cfg)
+	net.run(ctx, n)
+
+	peers, err := n.GetClosestPeers(ctx, []byte("k"))
+	require.NoError(t, err)
+	require.NoError(t, n.PutValue(ctx, []byte("k"), []byte("v")))
+
+	// the results are served from the cache once the network forgot them
+	for _, s := range net.servers {
+		delete(s.values, "k")
+	}
+	for _, info := range net.infos[1:] {
+		net.rts[0].RemoveKey(info.ID().Key())
+	}
+	cached, err := n.GetClosestPeers(ctx, []byte("k"))
+	require.NoError(t, err)
+	require.Equal(t, peers, cached)
+	v, err := n.GetValue(ctx, []byte("k"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("v"), v)
+
+	// and looked up again once they expired
+	net.clk.Add(2 * time.Hour)
+	_, err = n.GetClosestPeers(ctx, []byte("k"))
+	require.ErrorIs(t, err, ErrNoPeers)
+	_, err = n.GetValue(ctx, []byte("k"))
+	require.ErrorIs(t, err, ErrNoPeers)
+}
+
+func TestNodeSharedLookups(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	mp := kadtest.NewMeterProvider()
+	net := newTestNetwork(t, 4)
+	cfg := DefaultConfig[testKey, kadtest.StrAddr]()
+	cfg.MeterProvider = mp
+	n := net.newNode(t, 0, cfg)
+
+	// the lookups are all started before the network runs, so that they overlap
+	const callers = 3
+	results := make(chan []kad.NodeID[testKey], callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			peers, err := n.GetClosestPeers(ctx, []byte("k"))
+			require.NoError(t, err)
+			results <- peers
+		}()
+	}
+	flight := flightKey(targetOf([]byte("k")))
+	require.Eventually(t, func() bool {
+		return n.closestFlights.waiting(flight) == callers
+	}, time.Second, time.Millisecond)
+	net.run(ctx, n)
+
+	first := <-results
+	require.NotEmpty(t, first)
+	for i := 1; i < callers; i++ {
+		require.Equal(t, first, <-results)
+	}
+	require.Eventually(t, func() bool {
+		return mp.Count("query.hops", attribute.String("kind", "query")) == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestNodeSharedGetValue(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	net := newTestNetwork(t, 4)
+	for _, s := range net.servers {
+		s.values["k"] = []byte("v")
+	}
+	n := net.newNode(t, 0, nil)
+
+	const callers = 3
+	results := make(chan []byte, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			v, err := n.GetValue(ctx, []byte("k"))
+			require.NoError(t, err)
+			results <- v
+		}()
+	}
+	require.Eventually(t, func() bool {
+		return n.valueFlights.waiting("k") == callers
+	}, time.Second, time.Millisecond)
+	net.run(ctx, n)
+
+	// each caller owns the value it is returned
+	values := make([][]byte, callers)
+	for i := range values {
+		values[i] = <-results
+	}
+	values[0][0] = 'x'
+	for _, v := range values[1:] {
+		require.Equal(t, []byte("v"), v)
+	}
+}
+
+func TestNodeConcurrentLookups(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	// the lookups read the routing table while the coordinator adds the nodes it learns about,
+	// which go test -race checks
+	net := newTestNetwork(t, 8)
+	n := net.newNode(t, 0, nil)
+	net.run(ctx, n)
+
+	const callers = 8
+	errs := make(chan error, callers+1)
+	for i := 0; i < callers; i++ {
+		k := []byte{byte(i)}
+		go func() {
+			_, err := n.GetClosestPeers(ctx, k)
+			errs <- err
+		}()
+	}
+	go func() {
+		errs <- n.Bootstrap(ctx, nil)
+	}()
+	for i := 0; i < callers+1; i++ {
+		require.NoError(t, <-errs)
+	}
+}
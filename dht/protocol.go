@@ -0,0 +1,66 @@
+package dht
+
+import (
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/network/address"
+)
+
+// Protocol adapts a Node to the messages of a DHT protocol. It builds the requests the node sends
+// and reads the values and providers carried by their responses. The target of each request is
+// the Kademlia key the protocol derives from the key of the request.
+type Protocol[K kad.Key[K], A kad.Address[A]] interface {
+	// ID returns the protocol ID the requests are sent with.
+	ID() address.ProtocolID
+
+	// FindPeerRequest returns a request for the nodes closest to the node id. It returns an error
+	// if the protocol cannot identify the node, for example because id is not of the node
+	// identifier type of the protocol.
+	FindPeerRequest(id kad.NodeID[K]) (kad.Request[K, A], error)
+
+	// FindNodeRequest returns a request for the nodes closest to the target of key.
+	FindNodeRequest(key []byte) kad.Request[K, A]
+
+	// GetValueRequest returns a request for the value stored under key.
+	GetValueRequest(key []byte) kad.Request[K, A]
+
+	// PutValueRequest returns a request storing value under key.
+	PutValueRequest(key, value []byte) kad.Request[K, A]
+
+	// GetProvidersRequest returns a request for the providers of key.
+	GetProvidersRequest(key []byte) kad.Request[K, A]
+
+	// AddProviderRequest returns a request announcing that provider provides key. It returns an
+	// error if the protocol cannot describe the provider.
+	AddProviderRequest(key []byte, provider kad.NodeInfo[K, A]) (kad.Request[K, A], error)
+
+	// Value returns the value carried by a response to a request built by GetValueRequest, and
+	// false if the response carries none.
+	Value(resp kad.Response[K, A]) ([]byte, bool)
+
+	// Providers returns the providers carried by a response to a request built by
+	// GetProvidersRequest.
+	Providers(resp kad.Response[K, A]) []kad.NodeInfo[K, A]
+}
+
+// TokenProtocol is a Protocol whose servers may require write tokens, issued in the responses to
+// the requests built by GetValueRequest and GetProvidersRequest and presented back in the
+// requests storing values and provider records.
+type TokenProtocol[K kad.Key[K], A kad.Address[A]] interface {
+	Protocol[K, A]
+
+	// Token returns the write token carried by a response, and false if the response carries none.
+	Token(resp kad.Response[K, A]) ([]byte, bool)
+
+	// WithToken returns a copy of req, built by PutValueRequest or AddProviderRequest, carrying tok.
+	WithToken(req kad.Request[K, A], tok []byte) kad.Request[K, A]
+}
+
+// PingProtocol is a Protocol with a request checking that a node is alive, cheaper to serve than
+// a request for closer nodes. A HealthChecker probes nodes with it if the protocol of the node
+// implements it.
+type PingProtocol[K kad.Key[K], A kad.Address[A]] interface {
+	Protocol[K, A]
+
+	// PingRequest returns a request checking that the node it is sent to responds.
+	PingRequest() kad.Request[K, A]
+}
@@ -0,0 +1,290 @@
+package dht
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/util"
+)
+
+// RepublisherConfig specifies optional configuration for a Republisher
+type RepublisherConfig struct {
+	ValueInterval   time.Duration // the interval at which the tracked values are stored again at the closest nodes
+	ProvideInterval time.Duration // the interval at which the tracked provides are announced again
+	Jitter          time.Duration // the maximum random delay added to each interval, spreading the republishes
+	Clock           clock.Clock   // a clock that may replaced by a mock when testing
+	Seed            int64         // seeds the generator drawing the jitter
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *RepublisherConfig) Validate() error {
+	if cfg.ValueInterval < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "RepublisherConfig",
+			Err:       fmt.Errorf("value interval must be greater than zero"),
+		}
+	}
+	if cfg.ProvideInterval < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "RepublisherConfig",
+			Err:       fmt.Errorf("provide interval must be greater than zero"),
+		}
+	}
+	if cfg.Jitter < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "RepublisherConfig",
+			Err:       fmt.Errorf("jitter must not be negative"),
+		}
+	}
+	if cfg.Clock == nil {
+		return &kaderr.ConfigurationError{
+			Component: "RepublisherConfig",
+			Err:       fmt.Errorf("clock must not be nil"),
+		}
+	}
+	return nil
+}
+
+// DefaultRepublisherConfig returns the default configuration options for a Republisher.
+// Options may be overridden before passing to NewRepublisher
+func DefaultRepublisherConfig() *RepublisherConfig {
+	return &RepublisherConfig{
+		ValueInterval:   time.Hour,      // as in the public IPFS DHT, well within the record TTL of remote stores
+		ProvideInterval: 22 * time.Hour, // as in the public IPFS DHT, within the 48h provider TTL
+		Jitter:          5 * time.Minute,
+		Clock:           clock.New(), // use standard time
+	}
+}
+
+// A Republisher keeps the values and provides originated by a Node alive in the network. Remote
+// nodes forget them after their own TTL, so the Republisher stores each tracked value again at
+// the closest nodes to its key every ValueInterval, and announces each tracked provide again
+// every ProvideInterval, after a random delay of up to Jitter so that the republishes of the
+// entries tracked together do not happen at once.
+//
+// A value may be tracked with an expiry, after which it is no longer republished and forgotten.
+// Republishing a value does not put it in the local record store again, which keeps its own
+// expiry, while announcing a provide again also renews it in the local provider store.
+type Republisher[K kad.Key[K], A kad.Address[A]] struct {
+	node *Node[K, A]
+	cfg  RepublisherConfig
+	wake chan struct{}
+
+	mu       sync.Mutex
+	rng      *rand.Rand
+	values   map[string]*republished
+	provides map[string]*republished
+}
+
+// republished is a value or provide tracked by a Republisher.
+type republished struct {
+	key     []byte
+	value   []byte
+	expires time.Time // the time after which the value is forgotten, zero for never
+	due     time.Time // the time of the next republish
+}
+
+// NewRepublisher creates a new Republisher republishing through n. If cfg is nil, the default
+// config is used.
+func NewRepublisher[K kad.Key[K], A kad.Address[A]](n *Node[K, A], cfg *RepublisherConfig) (*Republisher[K, A], error) {
+	if cfg == nil {
+		cfg = DefaultRepublisherConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Republisher[K, A]{
+		node:     n,
+		cfg:      *cfg,
+		wake:     make(chan struct{}, 1),
+		rng:      rand.New(rand.NewSource(cfg.Seed)),
+		values:   make(map[string]*republished),
+		provides: make(map[string]*republished),
+	}, nil
+}
+
+// TrackValue republishes value under key until expires, or forever if expires is zero, replacing
+// the value tracked under key, if any. The first republish is one ValueInterval from now, the
+// value is expected to have just been put.
+func (r *Republisher[K, A]) TrackValue(key, value []byte, expires time.Time) {
+	r.mu.Lock()
+	r.values[string(key)] = &republished{
+		key:     append([]byte(nil), key...),
+		value:   append([]byte(nil), value...),
+		expires: expires,
+		due:     r.next(r.cfg.ValueInterval),
+	}
+	r.mu.Unlock()
+	r.notify()
+}
+
+// UntrackValue stops republishing the value under key.
+func (r *Republisher[K, A]) UntrackValue(key []byte) {
+	r.mu.Lock()
+	delete(r.values, string(key))
+	r.mu.Unlock()
+	r.notify()
+}
+
+// TrackProvide announces again that the node provides key every ProvideInterval, starting one
+// interval from now.
+func (r *Republisher[K, A]) TrackProvide(key []byte) {
+	r.mu.Lock()
+	r.provides[string(key)] = &republished{
+		key: append([]byte(nil), key...),
+		due: r.next(r.cfg.ProvideInterval),
+	}
+	r.mu.Unlock()
+	r.notify()
+}
+
+// UntrackProvide stops announcing that the node provides key.
+func (r *Republisher[K, A]) UntrackProvide(key []byte) {
+	r.mu.Lock()
+	delete(r.provides, string(key))
+	r.mu.Unlock()
+	r.notify()
+}
+
+// Len returns the number of values and provides tracked.
+func (r *Republisher[K, A]) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.values) + len(r.provides)
+}
+
+// Start republishes the tracked values and provides until ctx is done. The republishes are run
+// one at a time, each an operation of the node, which must have been started.
+func (r *Republisher[K, A]) Start(ctx context.Context) {
+	go r.run(ctx)
+}
+
+func (r *Republisher[K, A]) run(ctx context.Context) {
+	for {
+		r.republishDue(ctx)
+
+		var t *clock.Timer
+		var fired <-chan time.Time
+		if due, ok := r.nextDue(); ok {
+			t = r.cfg.Clock.Timer(due.Sub(r.cfg.Clock.Now()))
+			fired = t.C
+		}
+		select {
+		case <-fired:
+		case <-r.wake:
+		case <-ctx.Done():
+		}
+		if t != nil {
+			t.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// republishDue republishes the entries whose republish is due and forgets the expired values.
+func (r *Republisher[K, A]) republishDue(ctx context.Context) {
+	now := r.cfg.Clock.Now()
+
+	r.mu.Lock()
+	var values, provides []*republished
+	for k, v := range r.values {
+		switch {
+		case !v.expires.IsZero() && !now.Before(v.expires):
+			delete(r.values, k)
+		case !now.Before(v.due):
+			v.due = r.next(r.cfg.ValueInterval)
+			values = append(values, v)
+		}
+	}
+	for _, p := range r.provides {
+		if !now.Before(p.due) {
+			p.due = r.next(r.cfg.ProvideInterval)
+			provides = append(provides, p)
+		}
+	}
+	r.mu.Unlock()
+
+	// failed republishes are retried at the next interval
+	for _, v := range values {
+		if ctx.Err() != nil {
+			return
+		}
+		r.republishValue(ctx, v)
+	}
+	for _, p := range provides {
+		if ctx.Err() != nil {
+			return
+		}
+		r.republishProvide(ctx, p)
+	}
+}
+
+func (r *Republisher[K, A]) republishValue(ctx context.Context, v *republished) {
+	ctx, span := util.StartSpan(ctx, "Republisher.republishValue")
+	defer span.End()
+
+	n := r.node
+	if err := n.store(ctx, v.key, n.proto.GetValueRequest(v.key), n.proto.PutValueRequest(v.key, v.value)); err != nil {
+		span.RecordError(err)
+	}
+}
+
+func (r *Republisher[K, A]) republishProvide(ctx context.Context, p *republished) {
+	ctx, span := util.StartSpan(ctx, "Republisher.republishProvide")
+	defer span.End()
+
+	if err := r.node.Provide(ctx, p.key); err != nil {
+		span.RecordError(err)
+	}
+}
+
+// nextDue returns the time of the earliest republish or expiry, and false if nothing is tracked.
+func (r *Republisher[K, A]) nextDue() (time.Time, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var next time.Time
+	found := false
+	earliest := func(t time.Time) {
+		if !found || t.Before(next) {
+			next, found = t, true
+		}
+	}
+	for _, v := range r.values {
+		earliest(v.due)
+		if !v.expires.IsZero() {
+			earliest(v.expires)
+		}
+	}
+	for _, p := range r.provides {
+		earliest(p.due)
+	}
+	return next, found
+}
+
+// next returns the time of the republish one interval from now, delayed by the jitter. It must be
+// called with mu held.
+func (r *Republisher[K, A]) next(interval time.Duration) time.Time {
+	d := interval
+	if r.cfg.Jitter > 0 {
+		d += time.Duration(r.rng.Int63n(int64(r.cfg.Jitter) + 1))
+	}
+	return r.cfg.Clock.Now().Add(d)
+}
+
+// notify wakes the republishing goroutine up to account for a change of the tracked entries.
+func (r *Republisher[K, A]) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,100 @@
+package dht
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kadtest"
+)
+
+func TestRepublisherConfigValidate(t *testing.T) {
+	cfg := DefaultRepublisherConfig()
+	require.NoError(t, cfg.Validate())
+
+	cfg.ValueInterval = 0
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultRepublisherConfig()
+	cfg.ProvideInterval = 0
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultRepublisherConfig()
+	cfg.Jitter = -1
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultRepublisherConfig()
+	cfg.Clock = nil
+	require.Error(t, cfg.Validate())
+}
+
+func TestRepublisher(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	net := newTestNetwork(t, 8)
+	n := net.newNode(t, 0, nil)
+	net.run(ctx, n)
+
+	cfg := DefaultRepublisherConfig()
+	cfg.ValueInterval = time.Hour
+	cfg.ProvideInterval = 2 * time.Hour
+	cfg.Jitter = time.Minute
+	cfg.Clock = net.clk
+	r, err := NewRepublisher(n, cfg)
+	require.NoError(t, err)
+	r.Start(ctx)
+
+	start := net.clk.Now()
+	r.TrackValue([]byte("k"), []byte("v"), start.Add(90*time.Minute))
+	r.TrackProvide([]byte("content"))
+	require.Equal(t, 2, r.Len())
+
+	stored := func(k string) int {
+		count := 0
+		for _, s := range net.servers {
+			if _, ok := s.values[k]; ok {
+				count++
+			}
+		}
+		return count
+	}
+	// advance returns a condition advancing the clock by a minute until cond holds
+	advance := func(cond func() bool) func() bool {
+		return func() bool {
+			if cond() {
+				return true
+			}
+			net.clk.Add(time.Minute)
+			return false
+		}
+	}
+
+	// the value is not republished before the interval elapsed
+	net.clk.Add(59 * time.Minute)
+	time.Sleep(10 * time.Millisecond)
+	require.Zero(t, stored("k"))
+
+	// it is once the interval and jitter elapsed
+	require.Eventually(t, advance(func() bool { return stored("k") == 3 }), 5*time.Second, time.Millisecond)
+
+	// the value expires before its next republish and is forgotten
+	for _, s := range net.servers {
+		delete(s.values, "k")
+	}
+	require.Eventually(t, advance(func() bool { return r.Len() == 1 }), 5*time.Second, time.Millisecond)
+	require.Zero(t, stored("k"))
+
+	// the provide is announced again
+	require.Eventually(t, advance(func() bool {
+		announced := 0
+		for _, s := range net.servers {
+			announced += len(s.providers["content"])
+		}
+		return announced == 3
+	}), 5*time.Second, time.Millisecond)
+
+	r.UntrackProvide([]byte("content"))
+	require.Zero(t, r.Len())
+}
@@ -7,3 +7,8 @@ import "context"
 type Action interface {
 	Run(context.Context)
 }
+
+// PanicHandler is called by a scheduler when the action a panicked, with the value recovered
+// from the panic. Schedulers recover from panicking actions so that a faulty action doesn't
+// stop the event loop, and carry on with the next action once the handler returns.
+type PanicHandler func(ctx context.Context, a Action, recovered any)
@@ -0,0 +1,87 @@
+package event
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+const (
+	actionPending int32 = iota
+	actionStarted
+	actionCancelled
+)
+
+// CancellableAction is a handle on an action enqueued on a scheduler, that can be cancelled
+// until the action starts running. A cancelled action is dropped instead of run.
+type CancellableAction struct {
+	action Action
+	state  atomic.Int32
+}
+
+var _ Action = (*CancellableAction)(nil)
+
+// NewCancellableAction wraps an action so that it can be cancelled. Schedulers return the
+// actions they enqueue wrapped this way.
+func NewCancellableAction(a Action) *CancellableAction {
+	return &CancellableAction{action: a}
+}
+
+// cancelledAction returns the handle of an action that was never enqueued.
+func cancelledAction(a Action) *CancellableAction {
+	c := NewCancellableAction(a)
+	c.Cancel()
+	return c
+}
+
+// Run runs the action unless it was cancelled or already started.
+func (c *CancellableAction) Run(ctx context.Context) {
+	if c.start() {
+		c.action.Run(ctx)
+	}
+}
+
+// Action returns the action that was enqueued.
+func (c *CancellableAction) Action() Action {
+	return c.action
+}
+
+// Cancel prevents the action from running, returning false if it already started or was
+// cancelled.
+func (c *CancellableAction) Cancel() bool {
+	return c.state.CompareAndSwap(actionPending, actionCancelled)
+}
+
+// Pending returns true if the action has not started and was not cancelled.
+func (c *CancellableAction) Pending() bool {
+	return c.state.Load() == actionPending
+}
+
+// start marks the action as started, returning false if it was cancelled or already started.
+func (c *CancellableAction) start() bool {
+	return c.state.CompareAndSwap(actionPending, actionStarted)
+}
+
+func (c *CancellableAction) unwrap() Action {
+	return c.action
+}
+
+// wrapperAction is an action wrapping another action, such as a queued or prioritised action.
+type wrapperAction interface {
+	unwrap() Action
+}
+
+// findAction returns the first action of type T in a and the actions it wraps.
+func findAction[T Action](a Action) (T, bool) {
+	for a != nil {
+		if t, ok := a.(T); ok {
+			return t, true
+		}
+		w, ok := a.(wrapperAction)
+		if !ok {
+			break
+		}
+		a = w.unwrap()
+	}
+	var zero T
+	return zero, false
+}
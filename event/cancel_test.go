@@ -0,0 +1,71 @@
+package event
+
+import (
+	"context"
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancellableAction(t *testing.T) {
+	ctx := context.Background()
+
+	var runs int
+	a := BasicAction(func(context.Context) { runs++ })
+
+	ca := NewCancellableAction(a)
+	require.True(t, ca.Pending())
+	ca.Run(ctx)
+	require.False(t, ca.Pending())
+	require.False(t, ca.Cancel())
+	ca.Run(ctx)
+	require.Equal(t, 1, runs)
+
+	ca = NewCancellableAction(a)
+	require.True(t, ca.Cancel())
+	require.False(t, ca.Cancel())
+	require.False(t, ca.Pending())
+	ca.Run(ctx)
+	require.Equal(t, 1, runs)
+}
+
+func TestSchedulerCancelQueuedAction(t *testing.T) {
+	ctx := context.Background()
+	sched := NewSimpleScheduler(clock.NewMock())
+
+	var ran []int
+	record := func(i int) Action {
+		return BasicAction(func(context.Context) { ran = append(ran, i) })
+	}
+
+	sched.EnqueueAction(ctx, record(0))
+	cancelled := sched.EnqueueAction(ctx, record(1))
+	handles := EnqueueActions(ctx, sched, []Action{record(2), record(3)})
+	require.Len(t, handles, 2)
+	require.True(t, cancelled.Cancel())
+	require.True(t, handles[1].Cancel())
+
+	RunAll(ctx, sched)
+	require.Equal(t, []int{0, 2}, ran)
+	require.False(t, handles[0].Cancel())
+
+	// cancelled actions are not counted as run
+	st := sched.Stats()
+	require.EqualValues(t, 2, st.ActionsRun)
+	require.Zero(t, st.QueueDepth)
+}
+
+func TestPoolSchedulerEnqueueAfterClose(t *testing.T) {
+	ctx := context.Background()
+	sched, err := NewPoolScheduler(nil)
+	require.NoError(t, err)
+	require.NoError(t, sched.Close(ctx))
+
+	// actions enqueued after Close never run
+	ca := sched.EnqueueAction(ctx, IntAction(0))
+	require.False(t, ca.Pending())
+	for _, ca := range sched.EnqueueActions(ctx, []Action{IntAction(1), IntAction(2)}) {
+		require.False(t, ca.Pending())
+	}
+}
@@ -0,0 +1,56 @@
+package event
+
+import (
+	"context"
+	"time"
+)
+
+// DeadlineAction is an action that must run before a deadline, such as a timeout or a retry
+// that is pointless once its window has passed. An action that is still waiting in a planner
+// or a queue when its deadline passes is dropped instead of run, and OnExpired is called if it
+// is not nil.
+type DeadlineAction struct {
+	Action
+	Deadline  time.Time                 // the time after which the action must not run
+	OnExpired func(ctx context.Context) // called instead of the action once it is dropped, may be nil
+}
+
+var _ Action = (*DeadlineAction)(nil)
+
+func (a *DeadlineAction) unwrap() Action {
+	return a.Action
+}
+
+// Expired returns true if the deadline of the action has passed at now.
+func (a *DeadlineAction) Expired(now time.Time) bool {
+	return now.After(a.Deadline)
+}
+
+// ScheduleActionWithDeadline schedules an action to run at a time t, and to be dropped instead
+// if it cannot run before deadline. onExpired is called when the action is dropped and may be
+// nil.
+func ScheduleActionWithDeadline(ctx context.Context, s Scheduler, t, deadline time.Time, a Action,
+	onExpired func(context.Context),
+) PlannedAction {
+	return s.ScheduleAction(ctx, t, &DeadlineAction{Action: a, Deadline: deadline, OnExpired: onExpired})
+}
+
+// deadlineOf returns the DeadlineAction of a, unwrapping the actions wrapping it, or nil if a
+// has no deadline.
+func deadlineOf(a Action) *DeadlineAction {
+	da, _ := findAction[*DeadlineAction](a)
+	return da
+}
+
+// expire returns true if a has a deadline that passed at now, after calling its OnExpired
+// callback.
+func expire(ctx context.Context, now time.Time, a Action) bool {
+	da := deadlineOf(a)
+	if da == nil || !da.Expired(now) {
+		return false
+	}
+	if da.OnExpired != nil {
+		da.OnExpired(ctx)
+	}
+	return true
+}
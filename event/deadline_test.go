@@ -0,0 +1,70 @@
+package event
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlannerDropsExpiredActions(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	p := NewSimplePlanner(clk)
+
+	var expired []int
+	onExpired := func(i int) func(context.Context) {
+		return func(context.Context) { expired = append(expired, i) }
+	}
+
+	inTime := &DeadlineAction{Action: IntAction(0), Deadline: clk.Now().Add(2 * time.Second), OnExpired: onExpired(0)}
+	late := &DeadlineAction{Action: IntAction(1), Deadline: clk.Now().Add(time.Second), OnExpired: onExpired(1)}
+	p.ScheduleAction(ctx, clk.Now().Add(time.Second), inTime)
+	p.ScheduleAction(ctx, clk.Now().Add(time.Second), late)
+	p.ScheduleAction(ctx, clk.Now().Add(time.Second), IntAction(2))
+
+	// the planner is only polled after the deadline of late has passed
+	clk.Add(1500 * time.Millisecond)
+	require.Equal(t, []Action{inTime, IntAction(2)}, p.PopOverdueActions(ctx))
+	require.Equal(t, []int{1}, expired)
+}
+
+func TestSchedulerDropsExpiredActions(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	sched := NewSimpleScheduler(clk)
+
+	var ran, expired []int
+	record := func(i int) Action {
+		return BasicAction(func(context.Context) { ran = append(ran, i) })
+	}
+
+	ScheduleActionWithDeadline(ctx, sched, clk.Now().Add(time.Second), clk.Now().Add(2*time.Second), record(0),
+		func(context.Context) { expired = append(expired, 0) })
+	ScheduleActionWithDeadline(ctx, sched, clk.Now().Add(time.Second), clk.Now().Add(3*time.Second), record(1),
+		func(context.Context) { expired = append(expired, 1) })
+	// a deadline without callback
+	sched.EnqueueAction(ctx, &DeadlineAction{Action: record(2), Deadline: clk.Now().Add(time.Second)})
+
+	// both planned actions are queued in time, but the queue is only run after the first
+	// deadline has passed
+	clk.Add(time.Second)
+	require.Equal(t, clk.Now(), sched.NextActionTime(ctx))
+	clk.Add(1500 * time.Millisecond)
+	RunAll(ctx, sched)
+	require.Equal(t, []int{1}, ran)
+	require.Equal(t, []int{0}, expired)
+
+	// expired actions are not counted as run
+	require.EqualValues(t, 1, sched.Stats().ActionsRun)
+	require.Zero(t, sched.Stats().QueueDepth)
+}
+
+func TestDeadlineOfWrappedAction(t *testing.T) {
+	da := &DeadlineAction{Action: IntAction(0)}
+	require.Equal(t, da, deadlineOf(&PriorityAction{Action: da, Priority: PriorityHigh}))
+	require.Equal(t, da, deadlineOf(&queuedAction{handle: NewCancellableAction(da)}))
+	require.Nil(t, deadlineOf(IntAction(0)))
+}
@@ -1,4 +1,13 @@
 // Package event provides an abstraction for single worker multi threaded applications. Some applications are multi
 // threaded by design (e.g Kademlia lookup), but having a sequential execution brings many benefits such as
 // deterministic testing, easier debugging, sequential tracing, and sometimes even increased performance.
+//
+// Nodes handling many concurrent queries may instead use a PoolScheduler, which runs actions concurrently on a
+// bounded pool of go routines.
+//
+// Schedulers and planners never read the time of the system directly but take a clock.Clock from
+// github.com/benbjohnson/clock, which provides Now, After and Timer. Production code uses the real
+// clock returned by clock.New, while tests and simulations pass the mock returned by clock.NewMock
+// and advance it explicitly, so that time-based behaviour such as timeouts and periodic actions is
+// deterministic and never requires sleeping.
 package event
@@ -0,0 +1,150 @@
+package event
+
+import (
+	"context"
+	"sync"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/util"
+)
+
+// OwnedAction is an action enqueued on behalf of an owner, such as a query or a subsystem of a
+// node. Actions that are not OwnedActions belong to the owner with an empty name.
+type OwnedAction struct {
+	Action
+	Owner string
+}
+
+var _ Action = (*OwnedAction)(nil)
+
+func (a *OwnedAction) unwrap() Action {
+	return a.Action
+}
+
+// OwnerOf returns the owner of an action.
+func OwnerOf(a Action) string {
+	if oa, ok := findAction[*OwnedAction](a); ok {
+		return oa.Owner
+	}
+	return ""
+}
+
+// EnqueueActionFor enqueues an action to run as soon as possible on behalf of owner, taking
+// turns with the actions of other owners if the scheduler has a FairQueue.
+func EnqueueActionFor(ctx context.Context, s Scheduler, owner string, a Action) *CancellableAction {
+	return s.EnqueueAction(ctx, &OwnedAction{Action: a, Owner: owner})
+}
+
+// FairQueue is a queue that dequeues the actions of each owner in the order they were enqueued,
+// taking one action from each owner with queued actions in turn. An owner that enqueues many
+// actions delays only its own actions, and cannot starve the actions of other owners.
+type FairQueue struct {
+	mu     sync.Mutex
+	queues map[string][]Action
+	owners []string // the owners with queued actions, in turn order
+	next   int      // the index in owners of the owner whose turn is next
+	size   uint
+}
+
+var (
+	_ EventQueueWithEmpty   = (*FairQueue)(nil)
+	_ EventQueueEnqueueMany = (*FairQueue)(nil)
+)
+
+// NewFairQueue creates a new queue.
+func NewFairQueue() *FairQueue {
+	return &FairQueue{
+		queues: make(map[string][]Action),
+	}
+}
+
+// Enqueue adds an element to the queue
+func (q *FairQueue) Enqueue(ctx context.Context, a Action) {
+	_, span := util.StartSpan(ctx, "FairQueue.Enqueue")
+	defer span.End()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.enqueue(a)
+}
+
+// EnqueueMany adds elements to the queue at once, in order
+func (q *FairQueue) EnqueueMany(ctx context.Context, actions []Action) {
+	_, span := util.StartSpan(ctx, "FairQueue.EnqueueMany")
+	defer span.End()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, a := range actions {
+		q.enqueue(a)
+	}
+}
+
+// enqueue adds an element to the queue. q.mu must be held.
+func (q *FairQueue) enqueue(a Action) {
+	owner := OwnerOf(a)
+	if len(q.queues[owner]) == 0 {
+		// a new owner takes its turn after all the others
+		q.owners = append(q.owners, "")
+		copy(q.owners[q.next+1:], q.owners[q.next:])
+		q.owners[q.next] = owner
+		q.next++
+		if q.next == len(q.owners) {
+			q.next = 0
+		}
+	}
+	q.queues[owner] = append(q.queues[owner], a)
+	q.size++
+}
+
+// Dequeue removes and returns the next element of the queue, or nil if the queue is empty.
+func (q *FairQueue) Dequeue(ctx context.Context) Action {
+	_, span := util.StartSpan(ctx, "FairQueue.Dequeue")
+	defer span.End()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.size == 0 {
+		span.AddEvent("empty queue")
+		return nil
+	}
+
+	owner := q.owners[q.next]
+	queue := q.queues[owner]
+	a := queue[0]
+	queue[0] = nil
+	if len(queue) == 1 {
+		delete(q.queues, owner)
+		q.owners = append(q.owners[:q.next], q.owners[q.next+1:]...)
+	} else {
+		q.queues[owner] = queue[1:]
+		q.next++
+	}
+	if q.next >= len(q.owners) {
+		q.next = 0
+	}
+	q.size--
+	return a
+}
+
+// Empty returns true if the queue is empty
+func (q *FairQueue) Empty() bool {
+	return q.Size() == 0
+}
+
+func (q *FairQueue) Size() uint {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// Close does nothing, the queue holds no resources.
+func (q *FairQueue) Close() {}
+
+// NewFairScheduler creates a new SimpleScheduler whose queue takes turns between the owners of
+// actions, see FairQueue.
+func NewFairScheduler(clk clock.Clock) *SimpleScheduler {
+	return newSimpleScheduler(clk, NewFairQueue())
+}
@@ -0,0 +1,67 @@
+package event
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFairQueue(t *testing.T) {
+	ctx := context.Background()
+	q := NewFairQueue()
+	require.True(t, q.Empty())
+	require.Nil(t, q.Dequeue(ctx))
+
+	owned := func(owner string, i int) Action {
+		return &OwnedAction{Action: IntAction(i), Owner: owner}
+	}
+
+	// a floods the queue before b and c enqueue their actions
+	for i := 0; i < 5; i++ {
+		q.Enqueue(ctx, owned("a", i))
+	}
+	q.EnqueueMany(ctx, []Action{owned("b", 10), owned("b", 11)})
+	q.Enqueue(ctx, IntAction(20))
+	require.Equal(t, uint(8), q.Size())
+
+	// owners take turns, each dequeuing its actions in order
+	var got []Action
+	for a := q.Dequeue(ctx); a != nil; a = q.Dequeue(ctx) {
+		if oa, ok := a.(*OwnedAction); ok {
+			a = oa.Action
+		}
+		got = append(got, a)
+	}
+	require.Equal(t, []Action{
+		IntAction(0), IntAction(10), IntAction(20),
+		IntAction(1), IntAction(11),
+		IntAction(2), IntAction(3), IntAction(4),
+	}, got)
+	require.True(t, q.Empty())
+}
+
+func TestFairScheduler(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	sched := NewFairScheduler(clk)
+
+	var ran []string
+	record := func(s string) Action {
+		return BasicAction(func(context.Context) { ran = append(ran, s) })
+	}
+
+	for i := 0; i < 3; i++ {
+		EnqueueActionFor(ctx, sched, "query", record("query"))
+	}
+	ScheduleActionIn(ctx, sched, time.Second, &OwnedAction{Action: record("server"), Owner: "server"})
+	clk.Add(time.Second)
+
+	// the planned action keeps its owner once it is due
+	RunAll(ctx, sched)
+	require.Equal(t, []string{"query", "server", "query", "query"}, ran)
+	require.Equal(t, "query", OwnerOf(&PriorityAction{Action: &OwnedAction{Owner: "query"}}))
+	require.Empty(t, OwnerOf(IntAction(0)))
+}
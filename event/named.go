@@ -0,0 +1,121 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// NamedAction is an action with a human readable name and the location of the code that created
+// it, so that traces and scheduler dumps identify actions that would otherwise be anonymous
+// closures.
+type NamedAction struct {
+	Action
+	Name   string // a description of the action, such as "SimpleQuery.newRequest(target=00ff)"
+	Origin string // the file and line where the action was created
+}
+
+var _ Action = (*NamedAction)(nil)
+
+// NewNamedAction names an action, recording the location of the caller as its origin.
+func NewNamedAction(name string, a Action) *NamedAction {
+	na := &NamedAction{Action: a, Name: name}
+	if _, file, line, ok := runtime.Caller(1); ok {
+		na.Origin = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+	}
+	return na
+}
+
+func (a *NamedAction) unwrap() Action {
+	return a.Action
+}
+
+// NameOf returns the name of an action, or its type if it is not a NamedAction.
+func NameOf(a Action) string {
+	if na, ok := findAction[*NamedAction](a); ok {
+		return na.Name
+	}
+	for {
+		w, ok := a.(wrapperAction)
+		if !ok {
+			break
+		}
+		a = w.unwrap()
+	}
+	return fmt.Sprintf("%T", a)
+}
+
+// OriginOf returns the origin of an action, or an empty string if it is not a NamedAction.
+func OriginOf(a Action) string {
+	if na, ok := findAction[*NamedAction](a); ok {
+		return na.Origin
+	}
+	return ""
+}
+
+// ActionInfo describes an action waiting in a scheduler.
+type ActionInfo struct {
+	Name   string    // the name of the action, see NameOf
+	Origin string    // the origin of the action, see OriginOf
+	Time   time.Time // the time the action was enqueued, or the time it is planned to run at
+}
+
+func newActionInfo(a Action, t time.Time) ActionInfo {
+	return ActionInfo{Name: NameOf(a), Origin: OriginOf(a), Time: t}
+}
+
+// SchedulerDump is a snapshot of the actions waiting in a scheduler, to debug stuck or
+// saturated event loops.
+type SchedulerDump struct {
+	Now     time.Time    // the time of the scheduler clock when the dump was taken
+	Queued  []ActionInfo // the actions waiting to run, in the order they were enqueued
+	Planned []ActionInfo // the actions planned to run later, in the order they are due
+}
+
+// String formats the dump with one action per line.
+func (d SchedulerDump) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d queued:\n", len(d.Queued))
+	for _, a := range d.Queued {
+		fmt.Fprintf(&b, "  %s waiting %s", a.Name, d.Now.Sub(a.Time))
+		writeOrigin(&b, a.Origin)
+	}
+	fmt.Fprintf(&b, "%d planned:\n", len(d.Planned))
+	for _, a := range d.Planned {
+		fmt.Fprintf(&b, "  %s due in %s", a.Name, a.Time.Sub(d.Now))
+		writeOrigin(&b, a.Origin)
+	}
+	return b.String()
+}
+
+func writeOrigin(b *strings.Builder, origin string) {
+	if origin != "" {
+		fmt.Fprintf(b, " (%s)", origin)
+	}
+	b.WriteByte('\n')
+}
+
+// DumpScheduler is a scheduler that can list the actions waiting in it.
+type DumpScheduler interface {
+	Scheduler
+
+	// Dump returns a snapshot of the queued and planned actions of the scheduler.
+	Dump(context.Context) SchedulerDump
+}
+
+// dumpPlanned describes the actions of a planner, if it can list them.
+func dumpPlanned(ctx context.Context, p ActionPlanner) []ActionInfo {
+	lp, ok := p.(ListActionPlanner)
+	if !ok {
+		return nil
+	}
+	planned := lp.PlannedActions(ctx)
+	infos := make([]ActionInfo, len(planned))
+	for i, pa := range planned {
+		infos[i] = newActionInfo(pa.Action(), pa.Time())
+	}
+	return infos
+}
@@ -0,0 +1,88 @@
+package event
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamedAction(t *testing.T) {
+	na := NewNamedAction("test", IntAction(0))
+	require.Equal(t, "test", NameOf(na))
+	require.True(t, strings.HasPrefix(OriginOf(na), "named_test.go:"), OriginOf(na))
+
+	// names are found through the actions wrapping them
+	wrapped := &queuedAction{handle: NewCancellableAction(&PriorityAction{Action: na})}
+	require.Equal(t, "test", NameOf(wrapped))
+	require.Equal(t, na.Origin, OriginOf(wrapped))
+
+	// unnamed actions are described by their type
+	require.Equal(t, "event.IntAction", NameOf(&queuedAction{handle: NewCancellableAction(IntAction(0))}))
+	require.Empty(t, OriginOf(IntAction(0)))
+}
+
+func TestSchedulerDump(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	sched := NewSimpleScheduler(clk)
+
+	sched.EnqueueAction(ctx, NewNamedAction("first", IntAction(0)))
+	sched.EnqueueAction(ctx, IntAction(1)).Cancel()
+	clk.Add(time.Second)
+	sched.EnqueueAction(ctx, IntAction(2))
+	ScheduleActionIn(ctx, sched, time.Hour, NewNamedAction("later", IntAction(3)))
+	ScheduleActionIn(ctx, sched, time.Minute, IntAction(4))
+
+	d := sched.Dump(ctx)
+	require.Equal(t, clk.Now(), d.Now)
+	require.Len(t, d.Queued, 2)
+	require.Equal(t, "first", d.Queued[0].Name)
+	require.Equal(t, clk.Now().Add(-time.Second), d.Queued[0].Time)
+	require.Equal(t, "event.IntAction", d.Queued[1].Name)
+	require.Len(t, d.Planned, 2)
+	require.Equal(t, "event.IntAction", d.Planned[0].Name)
+	require.Equal(t, "later", d.Planned[1].Name)
+	require.Equal(t, clk.Now().Add(time.Hour), d.Planned[1].Time)
+
+	out := d.String()
+	require.Contains(t, out, "2 queued:\n  first waiting 1s (named_test.go:")
+	require.Contains(t, out, "2 planned:\n  event.IntAction due in 1m0s\n  later due in 1h0m0s (named_test.go:")
+
+	// actions that ran are not listed
+	sched.RunOne(ctx)
+	d = sched.Dump(ctx)
+	require.Len(t, d.Queued, 1)
+	require.Equal(t, "event.IntAction", d.Queued[0].Name)
+}
+
+func TestPoolSchedulerDump(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultPoolSchedulerConfig()
+	cfg.Clock = clock.NewMock()
+	cfg.Workers = 1
+	sched, err := NewPoolScheduler(cfg)
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	sched.EnqueueAction(ctx, BasicAction(func(context.Context) {
+		close(started)
+		<-release
+	}))
+	<-started
+	sched.EnqueueAction(ctx, NewNamedAction("blocked", IntAction(0)))
+	ScheduleActionIn(ctx, sched, time.Hour, NewNamedAction("later", IntAction(1)))
+
+	d := sched.Dump(ctx)
+	require.Len(t, d.Queued, 1)
+	require.Equal(t, "blocked", d.Queued[0].Name)
+	require.Len(t, d.Planned, 1)
+	require.Equal(t, "later", d.Planned[0].Name)
+
+	close(release)
+	require.NoError(t, sched.Close(ctx))
+}
@@ -0,0 +1,72 @@
+package event
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// RegisterStats creates observable instruments on meter that report the statistics of s each
+// time metrics are collected. The attributes are added to every observation, for example to
+// distinguish several schedulers. Unregister the returned registration to stop reporting.
+func RegisterStats(meter metric.Meter, s StatsScheduler, attrs ...attribute.KeyValue) (metric.Registration, error) {
+	depth, err := meter.Int64ObservableGauge("scheduler.queue.depth",
+		metric.WithDescription("Number of actions waiting to run"))
+	if err != nil {
+		return nil, err
+	}
+
+	longestWait, err := meter.Float64ObservableGauge("scheduler.queue.longest_wait",
+		metric.WithDescription("Time the longest waiting action has been queued"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+
+	actions, err := meter.Int64ObservableCounter("scheduler.actions",
+		metric.WithDescription("Number of actions run"))
+	if err != nil {
+		return nil, err
+	}
+
+	rate, err := meter.Float64ObservableGauge("scheduler.actions.rate",
+		metric.WithDescription("Number of actions run per second"),
+		metric.WithUnit("1/s"))
+	if err != nil {
+		return nil, err
+	}
+
+	meanRunTime, err := meter.Float64ObservableGauge("scheduler.action.mean_run_time",
+		metric.WithDescription("Mean time actions took to run"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+
+	maxRunTime, err := meter.Float64ObservableGauge("scheduler.action.max_run_time",
+		metric.WithDescription("Longest time an action took to run"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+
+	panics, err := meter.Int64ObservableCounter("scheduler.action.panics",
+		metric.WithDescription("Number of actions that panicked"))
+	if err != nil {
+		return nil, err
+	}
+
+	set := metric.WithAttributes(attrs...)
+	return meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		st := s.Stats()
+		o.ObserveInt64(depth, int64(st.QueueDepth), set)
+		o.ObserveFloat64(longestWait, st.LongestWait.Seconds(), set)
+		o.ObserveInt64(actions, int64(st.ActionsRun), set)
+		o.ObserveFloat64(rate, st.ActionsPerSecond, set)
+		o.ObserveFloat64(meanRunTime, st.MeanRunTime.Seconds(), set)
+		o.ObserveFloat64(maxRunTime, st.MaxRunTime.Seconds(), set)
+		o.ObserveInt64(panics, int64(st.ActionsPanicked), set)
+		return nil
+	}, depth, longestWait, actions, rate, meanRunTime, maxRunTime, panics)
+}
@@ -0,0 +1,71 @@
+package event
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimpleSchedulerRecoversPanic(t *testing.T) {
+	ctx := context.Background()
+	sched := NewSimpleScheduler(clock.NewMock())
+
+	var recovered []any
+	var panicked []Action
+	sched.SetOnActionPanic(func(_ context.Context, a Action, v any) {
+		panicked = append(panicked, a)
+		recovered = append(recovered, v)
+	})
+
+	faulty := BasicAction(func(context.Context) { panic("boom") })
+	var ran bool
+	sched.EnqueueAction(ctx, faulty)
+	sched.EnqueueAction(ctx, BasicAction(func(context.Context) { ran = true }))
+
+	// the action after the faulty one still runs
+	require.NotPanics(t, func() { RunAll(ctx, sched) })
+	require.True(t, ran)
+	require.Equal(t, []any{"boom"}, recovered)
+	require.Len(t, panicked, 1)
+
+	st := sched.Stats()
+	require.EqualValues(t, 2, st.ActionsRun)
+	require.EqualValues(t, 1, st.ActionsPanicked)
+}
+
+func TestSimpleSchedulerRecoversPanicWithoutHandler(t *testing.T) {
+	ctx := context.Background()
+	sched := NewSimpleScheduler(clock.NewMock())
+	sched.EnqueueAction(ctx, BasicAction(func(context.Context) { panic("boom") }))
+	require.NotPanics(t, func() { RunAll(ctx, sched) })
+	require.EqualValues(t, 1, sched.Stats().ActionsPanicked)
+}
+
+func TestPoolSchedulerRecoversPanic(t *testing.T) {
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var recovered []any
+	cfg := DefaultPoolSchedulerConfig()
+	cfg.Workers = 1
+	cfg.OnActionPanic = func(_ context.Context, _ Action, v any) {
+		mu.Lock()
+		defer mu.Unlock()
+		recovered = append(recovered, v)
+	}
+	sched, err := NewPoolScheduler(cfg)
+	require.NoError(t, err)
+
+	ran := make(chan struct{})
+	sched.EnqueueAction(ctx, BasicAction(func(context.Context) { panic("boom") }))
+	sched.EnqueueAction(ctx, BasicAction(func(context.Context) { close(ran) }))
+
+	// the only worker survives the panic
+	<-ran
+	require.NoError(t, sched.Close(ctx))
+	require.Equal(t, []any{"boom"}, recovered)
+	require.EqualValues(t, 1, sched.Stats().ActionsPanicked)
+}
@@ -72,6 +72,15 @@ func RemoveActions(ctx context.Context, p ActionPlanner, actions []PlannedAction
 	}
 }
 
+// ListActionPlanner is an interface for planners that can list the actions
+// they hold.
+type ListActionPlanner interface {
+	ActionPlanner
+
+	// PlannedActions returns the planned actions in the order they are due
+	PlannedActions(context.Context) []PlannedAction
+}
+
 // AwareActionPlanner is an interface for scheduling actions at a specific time
 // and knowing when the next action will be scheduled.
 type AwareActionPlanner interface {
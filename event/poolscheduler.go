@@ -0,0 +1,310 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/kaderr"
+)
+
+// PoolSchedulerConfig specifies optional configuration for a PoolScheduler.
+type PoolSchedulerConfig struct {
+	Clock         clock.Clock  // a clock that may replaced by a mock when testing
+	Workers       int          // the number of go routines running actions concurrently
+	OnActionPanic PanicHandler // called when an action panics, may be nil as panicking actions are always recovered from
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *PoolSchedulerConfig) Validate() error {
+	if cfg.Clock == nil {
+		return &kaderr.ConfigurationError{
+			Component: "PoolSchedulerConfig",
+			Err:       fmt.Errorf("clock must not be nil"),
+		}
+	}
+
+	if cfg.Workers < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "PoolSchedulerConfig",
+			Err:       fmt.Errorf("workers must be greater than zero"),
+		}
+	}
+	return nil
+}
+
+// DefaultPoolSchedulerConfig returns the default configuration options for a PoolScheduler.
+// Options may be overridden before passing to NewPoolScheduler.
+func DefaultPoolSchedulerConfig() *PoolSchedulerConfig {
+	return &PoolSchedulerConfig{
+		Clock:   clock.New(), // use standard time
+		Workers: 16,
+	}
+}
+
+// PoolScheduler is an implementation of the Scheduler interface that runs actions on a bounded
+// pool of go routines as soon as they are due, instead of waiting for RunOne to be called. Its
+// methods are safe for concurrent use, so that actions may be enqueued from any go routine.
+// Actions may run concurrently and must synchronise their access to shared state.
+//
+// Close stops the scheduler: actions that are queued when it is called are run before it
+// returns, planned actions that are not due yet are discarded, and actions enqueued or
+// scheduled after Close are ignored.
+type PoolScheduler struct {
+	clk     clock.Clock
+	planner AwareActionPlanner
+	stats   *statsRecorder
+
+	mu      sync.Mutex
+	cond    *sync.Cond // signalled when actions are queued or the scheduler is closed
+	queue   []Action   // the actions waiting for a worker
+	closed  bool
+	replan  chan struct{} // notifies the planning go routine that the next planned action changed
+	done    chan struct{} // closed when the scheduler is closed
+	workers sync.WaitGroup
+}
+
+var (
+	_ AwareScheduler = (*PoolScheduler)(nil)
+	_ BatchScheduler = (*PoolScheduler)(nil)
+	_ DumpScheduler  = (*PoolScheduler)(nil)
+	_ StatsScheduler = (*PoolScheduler)(nil)
+)
+
+// NewPoolScheduler creates a new PoolScheduler and starts its go routines.
+func NewPoolScheduler(cfg *PoolSchedulerConfig) (*PoolScheduler, error) {
+	if cfg == nil {
+		cfg = DefaultPoolSchedulerConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := &PoolScheduler{
+		clk:     cfg.Clock,
+		planner: NewSimplePlanner(cfg.Clock),
+		stats:   newStatsRecorder(cfg.Clock),
+		replan:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	s.stats.setPanicHandler(cfg.OnActionPanic)
+
+	s.workers.Add(cfg.Workers)
+	for i := 0; i < cfg.Workers; i++ {
+		go s.work()
+	}
+	go s.plan()
+	return s, nil
+}
+
+// Clock returns the clock of the scheduler.
+func (s *PoolScheduler) Clock() clock.Clock {
+	return s.clk
+}
+
+// EnqueueAction enqueues an action to be run by the next idle worker. It never blocks. The
+// handle of an action enqueued after Close is already cancelled.
+func (s *PoolScheduler) EnqueueAction(ctx context.Context, a Action) *CancellableAction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return cancelledAction(a)
+	}
+	qa := s.stats.enqueue(a)
+	s.queue = append(s.queue, qa)
+	s.cond.Signal()
+	return qa.handle
+}
+
+// EnqueueActions enqueues actions to be run by the next idle workers, in order and without
+// interleaving actions enqueued concurrently. It never blocks.
+func (s *PoolScheduler) EnqueueActions(ctx context.Context, actions []Action) []*CancellableAction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		handles := make([]*CancellableAction, len(actions))
+		for i, a := range actions {
+			handles[i] = cancelledAction(a)
+		}
+		return handles
+	}
+	wrapped, handles := s.stats.enqueueMany(actions)
+	s.queue = append(s.queue, wrapped...)
+	s.cond.Broadcast()
+	return handles
+}
+
+// ScheduleAction schedules an action to run at a specific time.
+func (s *PoolScheduler) ScheduleAction(ctx context.Context, t time.Time, a Action) PlannedAction {
+	if s.clk.Now().After(t) {
+		s.EnqueueAction(ctx, a)
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	pa := s.planner.ScheduleAction(ctx, t, a)
+	select {
+	case s.replan <- struct{}{}:
+	default:
+		// the planning go routine is already due to replan
+	}
+	return pa
+}
+
+// RemovePlannedAction removes an action from the scheduler planned actions
+// (not from the queue), does nothing if the action is not in the planner
+func (s *PoolScheduler) RemovePlannedAction(ctx context.Context, a PlannedAction) bool {
+	return s.planner.RemoveAction(ctx, a)
+}
+
+// RunOne runs one queued action on the calling go routine, returning true if an action was
+// run, false if the queue was empty. Actions are run by the workers of the scheduler without
+// calling RunOne, which may be used to help draining the queue.
+func (s *PoolScheduler) RunOne(ctx context.Context) bool {
+	s.moveOverdueActions(ctx)
+
+	s.mu.Lock()
+	if len(s.queue) == 0 {
+		s.mu.Unlock()
+		return false
+	}
+	a := s.dequeue()
+	s.mu.Unlock()
+
+	s.stats.runAction(ctx, a)
+	return true
+}
+
+// Stats returns a snapshot of the statistics of the actions run by the scheduler.
+func (s *PoolScheduler) Stats() SchedulerStats {
+	return s.stats.Stats()
+}
+
+// Dump returns a snapshot of the queued and planned actions of the scheduler.
+func (s *PoolScheduler) Dump(ctx context.Context) SchedulerDump {
+	return SchedulerDump{
+		Now:     s.clk.Now(),
+		Queued:  s.stats.queued(),
+		Planned: dumpPlanned(ctx, s.planner),
+	}
+}
+
+// NextActionTime returns the time of the next action to run, or the current
+// time if there are actions waiting for a worker, or MaxTime if there are no
+// actions scheduled to run.
+func (s *PoolScheduler) NextActionTime(ctx context.Context) time.Time {
+	s.mu.Lock()
+	queued := len(s.queue) > 0
+	s.mu.Unlock()
+	if queued {
+		return s.clk.Now()
+	}
+	return s.planner.NextActionTime(ctx)
+}
+
+// Close stops the scheduler, returning once the actions queued when it was called have run
+// and its go routines have exited, or with the error of ctx if it is done first.
+func (s *PoolScheduler) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+		s.cond.Broadcast()
+	}
+	s.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// dequeue removes and returns the first queued action. s.mu must be held and the queue must
+// not be empty.
+func (s *PoolScheduler) dequeue() Action {
+	a := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+	return a
+}
+
+// work runs queued actions until the scheduler is closed and its queue is drained.
+func (s *PoolScheduler) work() {
+	defer s.workers.Done()
+	ctx := context.Background()
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if len(s.queue) == 0 {
+			// closed and drained
+			s.mu.Unlock()
+			return
+		}
+		a := s.dequeue()
+		s.mu.Unlock()
+
+		s.stats.runAction(ctx, a)
+	}
+}
+
+// plan moves planned actions to the queue when they are due, until the scheduler is closed.
+func (s *PoolScheduler) plan() {
+	ctx := context.Background()
+	for {
+		s.moveOverdueActions(ctx)
+
+		var timer *clock.Timer
+		var due <-chan time.Time
+		if next := s.planner.NextActionTime(ctx); next != MaxTime {
+			timer = s.clk.Timer(next.Sub(s.clk.Now()))
+			due = timer.C
+		}
+
+		var closed bool
+		select {
+		case <-due:
+		case <-s.replan:
+		case <-s.done:
+			closed = true
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+// moveOverdueActions moves all overdue actions from the planner to the queue.
+func (s *PoolScheduler) moveOverdueActions(ctx context.Context) {
+	overdue := s.planner.PopOverdueActions(ctx)
+	if len(overdue) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	wrapped, _ := s.stats.enqueueMany(overdue)
+	s.queue = append(s.queue, wrapped...)
+	s.cond.Broadcast()
+}
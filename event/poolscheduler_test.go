@@ -0,0 +1,188 @@
+package event
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolSchedulerConfigValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		cfg := DefaultPoolSchedulerConfig()
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("clock is not nil", func(t *testing.T) {
+		cfg := DefaultPoolSchedulerConfig()
+		cfg.Clock = nil
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("workers positive", func(t *testing.T) {
+		cfg := DefaultPoolSchedulerConfig()
+		cfg.Workers = 0
+		require.Error(t, cfg.Validate())
+		cfg.Workers = -1
+		require.Error(t, cfg.Validate())
+	})
+}
+
+func TestPoolSchedulerRunsConcurrently(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultPoolSchedulerConfig()
+	cfg.Workers = 4
+	sched, err := NewPoolScheduler(cfg)
+	require.NoError(t, err)
+
+	// each action waits for all the others to start, which only succeeds if they all run at once
+	var started sync.WaitGroup
+	started.Add(cfg.Workers)
+	var ran sync.WaitGroup
+	ran.Add(cfg.Workers)
+	for i := 0; i < cfg.Workers; i++ {
+		go sched.EnqueueAction(ctx, BasicAction(func(context.Context) {
+			started.Done()
+			started.Wait()
+			ran.Done()
+		}))
+	}
+	ran.Wait()
+	require.NoError(t, sched.Close(ctx))
+}
+
+func TestPoolSchedulerPlannedActions(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultPoolSchedulerConfig()
+	cfg.Clock = clk
+	sched, err := NewPoolScheduler(cfg)
+	require.NoError(t, err)
+	defer sched.Close(ctx)
+
+	var ran, removed atomic.Bool
+	ScheduleActionIn(ctx, sched, time.Second, BasicAction(func(context.Context) { ran.Store(true) }))
+	pa := ScheduleActionIn(ctx, sched, time.Second, BasicAction(func(context.Context) { removed.Store(true) }))
+	require.Equal(t, clk.Now().Add(time.Second), sched.NextActionTime(ctx))
+	require.True(t, sched.RemovePlannedAction(ctx, pa))
+
+	// the action runs once it is due, without calling RunOne
+	clk.Add(500 * time.Millisecond)
+	require.False(t, ran.Load())
+	require.Eventually(t, func() bool {
+		clk.Add(100 * time.Millisecond)
+		return ran.Load()
+	}, time.Second, time.Millisecond)
+	require.False(t, removed.Load())
+	require.Equal(t, MaxTime, sched.NextActionTime(ctx))
+}
+
+func TestPoolSchedulerCloseDrains(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultPoolSchedulerConfig()
+	cfg.Clock = clk
+	cfg.Workers = 1
+	sched, err := NewPoolScheduler(cfg)
+	require.NoError(t, err)
+
+	// block the only worker so that further actions stay queued
+	release := make(chan struct{})
+	sched.EnqueueAction(ctx, BasicAction(func(context.Context) { <-release }))
+
+	var queued, planned, late atomic.Int32
+	for i := 0; i < 10; i++ {
+		sched.EnqueueAction(ctx, BasicAction(func(context.Context) { queued.Add(1) }))
+	}
+	ScheduleActionIn(ctx, sched, time.Hour, BasicAction(func(context.Context) { planned.Add(1) }))
+
+	closed := make(chan error)
+	go func() { closed <- sched.Close(ctx) }()
+
+	// Close waits for the queued actions
+	require.Eventually(t, func() bool {
+		sched.mu.Lock()
+		defer sched.mu.Unlock()
+		return sched.closed
+	}, time.Second, time.Millisecond)
+	sched.EnqueueAction(ctx, BasicAction(func(context.Context) { late.Add(1) }))
+	require.Nil(t, ScheduleActionIn(ctx, sched, time.Second, BasicAction(func(context.Context) { late.Add(1) })))
+	select {
+	case <-closed:
+		t.Fatal("closed before draining the queue")
+	default:
+	}
+	close(release)
+	require.NoError(t, <-closed)
+
+	// queued actions ran, actions not due yet or enqueued after Close did not
+	require.EqualValues(t, 10, queued.Load())
+	clk.Add(2 * time.Hour)
+	require.EqualValues(t, 0, planned.Load())
+	require.EqualValues(t, 0, late.Load())
+	require.False(t, sched.RunOne(ctx))
+
+	st := sched.Stats()
+	require.EqualValues(t, 11, st.ActionsRun)
+	require.Zero(t, st.QueueDepth)
+}
+
+func TestPoolSchedulerCloseContext(t *testing.T) {
+	cfg := DefaultPoolSchedulerConfig()
+	cfg.Workers = 1
+	sched, err := NewPoolScheduler(cfg)
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	defer close(release)
+	sched.EnqueueAction(context.Background(), BasicAction(func(context.Context) { <-release }))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, sched.Close(ctx), context.DeadlineExceeded)
+}
+
+func TestPoolSchedulerEnqueueActions(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultPoolSchedulerConfig()
+	cfg.Workers = 1
+	sched, err := NewPoolScheduler(cfg)
+	require.NoError(t, err)
+
+	// block the only worker while batches are enqueued concurrently
+	release := make(chan struct{})
+	sched.EnqueueAction(ctx, BasicAction(func(context.Context) { <-release }))
+
+	var mu sync.Mutex
+	var ran []int
+	batch := func(first int) []Action {
+		actions := make([]Action, 10)
+		for i := range actions {
+			i := first + i
+			actions[i-first] = BasicAction(func(context.Context) {
+				mu.Lock()
+				defer mu.Unlock()
+				ran = append(ran, i)
+			})
+		}
+		return actions
+	}
+	var enqueued sync.WaitGroup
+	enqueued.Add(2)
+	go func() { defer enqueued.Done(); EnqueueActions(ctx, sched, batch(0)) }()
+	go func() { defer enqueued.Done(); EnqueueActions(ctx, sched, batch(10)) }()
+	enqueued.Wait()
+	close(release)
+	require.NoError(t, sched.Close(ctx))
+
+	// the actions of each batch ran in order, one batch after the other
+	require.Len(t, ran, 20)
+	first := ran[0]
+	for i, r := range ran {
+		require.Equal(t, (first+i)%20, r)
+	}
+}
@@ -0,0 +1,174 @@
+package event
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/util"
+)
+
+// Priority is the priority of an action in a PriorityQueue. Actions with a higher priority run
+// first.
+type Priority int
+
+const (
+	PriorityLow    Priority = -1 // for background work such as telemetry
+	PriorityNormal Priority = 0  // for routine work such as routing table maintenance
+	PriorityHigh   Priority = 1  // for work a user is waiting for, such as queries
+)
+
+// PriorityAction is an action with a priority. Actions that are not PriorityActions have
+// PriorityNormal.
+type PriorityAction struct {
+	Action
+	Priority Priority
+}
+
+var _ Action = (*PriorityAction)(nil)
+
+func (a *PriorityAction) unwrap() Action {
+	return a.Action
+}
+
+// PriorityOf returns the priority of an action.
+func PriorityOf(a Action) Priority {
+	if pa, ok := findAction[*PriorityAction](a); ok {
+		return pa.Priority
+	}
+	return PriorityNormal
+}
+
+// EnqueueActionWithPriority enqueues an action to run as soon as possible, before the actions
+// with a lower priority if the scheduler has a PriorityQueue.
+func EnqueueActionWithPriority(ctx context.Context, s Scheduler, p Priority, a Action) *CancellableAction {
+	return s.EnqueueAction(ctx, &PriorityAction{Action: a, Priority: p})
+}
+
+// PriorityQueue is a queue that dequeues actions in order of priority, and in the order they
+// were enqueued for actions of the same priority. To protect actions of low priority from
+// starvation, an action that was passed over maxSkips times in favour of actions of higher
+// priority is dequeued next.
+type PriorityQueue struct {
+	maxSkips int
+
+	mu      sync.Mutex
+	levels  map[Priority][]Action
+	order   []Priority       // the priorities of non-empty levels, highest first
+	skipped map[Priority]int // the number of times the head of each level was passed over
+	size    uint
+}
+
+var (
+	_ EventQueueWithEmpty   = (*PriorityQueue)(nil)
+	_ EventQueueEnqueueMany = (*PriorityQueue)(nil)
+)
+
+// NewPriorityQueue creates a new queue. A maxSkips of zero or less disables starvation
+// protection.
+func NewPriorityQueue(maxSkips int) *PriorityQueue {
+	return &PriorityQueue{
+		maxSkips: maxSkips,
+		levels:   make(map[Priority][]Action),
+		skipped:  make(map[Priority]int),
+	}
+}
+
+// Enqueue adds an element to the queue
+func (q *PriorityQueue) Enqueue(ctx context.Context, a Action) {
+	_, span := util.StartSpan(ctx, "PriorityQueue.Enqueue")
+	defer span.End()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.enqueue(a)
+}
+
+// EnqueueMany adds elements to the queue at once, in order
+func (q *PriorityQueue) EnqueueMany(ctx context.Context, actions []Action) {
+	_, span := util.StartSpan(ctx, "PriorityQueue.EnqueueMany")
+	defer span.End()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, a := range actions {
+		q.enqueue(a)
+	}
+}
+
+// enqueue adds an element to the queue. q.mu must be held.
+func (q *PriorityQueue) enqueue(a Action) {
+	p := PriorityOf(a)
+	if len(q.levels[p]) == 0 {
+		i := sort.Search(len(q.order), func(i int) bool { return q.order[i] < p })
+		q.order = append(q.order, 0)
+		copy(q.order[i+1:], q.order[i:])
+		q.order[i] = p
+	}
+	q.levels[p] = append(q.levels[p], a)
+	q.size++
+}
+
+// Dequeue removes and returns the next element of the queue, or nil if the queue is empty.
+func (q *PriorityQueue) Dequeue(ctx context.Context) Action {
+	_, span := util.StartSpan(ctx, "PriorityQueue.Dequeue")
+	defer span.End()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.size == 0 {
+		span.AddEvent("empty queue")
+		return nil
+	}
+
+	next := 0 // the index in order of the level to dequeue from
+	if q.maxSkips > 0 {
+		for i, p := range q.order {
+			if q.skipped[p] >= q.maxSkips {
+				next = i
+				break
+			}
+		}
+	}
+	for _, p := range q.order[next+1:] {
+		q.skipped[p]++
+	}
+
+	p := q.order[next]
+	level := q.levels[p]
+	a := level[0]
+	level[0] = nil
+	q.skipped[p] = 0
+	if len(level) == 1 {
+		delete(q.levels, p)
+		delete(q.skipped, p)
+		q.order = append(q.order[:next], q.order[next+1:]...)
+	} else {
+		q.levels[p] = level[1:]
+	}
+	q.size--
+	return a
+}
+
+// Empty returns true if the queue is empty
+func (q *PriorityQueue) Empty() bool {
+	return q.Size() == 0
+}
+
+func (q *PriorityQueue) Size() uint {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// Close does nothing, the queue holds no resources.
+func (q *PriorityQueue) Close() {}
+
+// NewPriorityScheduler creates a new SimpleScheduler whose queue runs actions in order of
+// priority, see PriorityQueue.
+func NewPriorityScheduler(clk clock.Clock, maxSkips int) *SimpleScheduler {
+	return newSimpleScheduler(clk, NewPriorityQueue(maxSkips))
+}
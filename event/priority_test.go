@@ -0,0 +1,78 @@
+package event
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	ctx := context.Background()
+	q := NewPriorityQueue(0)
+	require.True(t, q.Empty())
+	require.Nil(t, q.Dequeue(ctx))
+
+	q.Enqueue(ctx, IntAction(0))
+	q.Enqueue(ctx, &PriorityAction{Action: IntAction(1), Priority: PriorityLow})
+	q.Enqueue(ctx, &PriorityAction{Action: IntAction(2), Priority: PriorityHigh})
+	q.Enqueue(ctx, IntAction(3))
+	q.Enqueue(ctx, &PriorityAction{Action: IntAction(4), Priority: PriorityHigh})
+	require.Equal(t, uint(5), q.Size())
+
+	// actions are dequeued by priority, then in order
+	var got []Action
+	for a := q.Dequeue(ctx); a != nil; a = q.Dequeue(ctx) {
+		if pa, ok := a.(*PriorityAction); ok {
+			a = pa.Action
+		}
+		got = append(got, a)
+	}
+	require.Equal(t, []Action{IntAction(2), IntAction(4), IntAction(0), IntAction(3), IntAction(1)}, got)
+	require.True(t, q.Empty())
+
+	q.EnqueueMany(ctx, []Action{IntAction(5), &PriorityAction{Action: IntAction(6), Priority: PriorityHigh}, IntAction(7)})
+	require.Equal(t, uint(3), q.Size())
+	require.Equal(t, PriorityHigh, PriorityOf(q.Dequeue(ctx)))
+	require.Equal(t, IntAction(5), q.Dequeue(ctx))
+	require.Equal(t, IntAction(7), q.Dequeue(ctx))
+}
+
+func TestPriorityQueueStarvation(t *testing.T) {
+	ctx := context.Background()
+	q := NewPriorityQueue(2)
+
+	low := &PriorityAction{Action: IntAction(-1), Priority: PriorityLow}
+	q.Enqueue(ctx, low)
+	for i := 0; i < 5; i++ {
+		q.Enqueue(ctx, &PriorityAction{Action: IntAction(i), Priority: PriorityHigh})
+	}
+
+	// the low priority action runs after being passed over twice
+	require.Equal(t, PriorityHigh, PriorityOf(q.Dequeue(ctx)))
+	require.Equal(t, PriorityHigh, PriorityOf(q.Dequeue(ctx)))
+	require.Equal(t, low, q.Dequeue(ctx))
+	require.Equal(t, uint(3), q.Size())
+}
+
+func TestPriorityScheduler(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	sched := NewPriorityScheduler(clk, 0)
+
+	var ran []int
+	record := func(i int) Action {
+		return BasicAction(func(context.Context) { ran = append(ran, i) })
+	}
+
+	EnqueueActionWithPriority(ctx, sched, PriorityLow, record(0))
+	ScheduleActionIn(ctx, sched, time.Second, &PriorityAction{Action: record(1), Priority: PriorityHigh})
+	sched.EnqueueAction(ctx, record(2))
+	clk.Add(time.Second)
+
+	// the planned action keeps its priority once it is due
+	RunAll(ctx, sched)
+	require.Equal(t, []int{1, 2, 0}, ran)
+}
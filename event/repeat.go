@@ -0,0 +1,95 @@
+package event
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RepeatedAction is a handle on an action that runs periodically, returned by
+// ScheduleRepeatedAction.
+type RepeatedAction struct {
+	ctx      context.Context // stops the action once done
+	sched    Scheduler
+	interval time.Duration
+	action   Action
+
+	mu        sync.Mutex
+	planned   PlannedAction // the next run of the action, nil while the action runs
+	cancelled bool
+}
+
+var _ Action = (*RepeatedAction)(nil)
+
+// ScheduleRepeatedAction schedules an action to run every interval, the first time one interval
+// from now, until ctx is done or the returned handle is cancelled. The next run is planned once
+// the action returns, one interval after its planned time, or one interval after it returned if
+// it overran, so that runs of the action never overlap and late runs are not followed by a
+// burst. It returns nil if interval is not positive.
+func ScheduleRepeatedAction(ctx context.Context, s Scheduler, interval time.Duration, a Action) *RepeatedAction {
+	if interval <= 0 {
+		return nil
+	}
+	ra := &RepeatedAction{
+		ctx:      ctx,
+		sched:    s,
+		interval: interval,
+		action:   a,
+	}
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	ra.planned = s.ScheduleAction(ctx, s.Clock().Now().Add(interval), ra)
+	return ra
+}
+
+// Run runs the action and plans its next run, unless the handle was cancelled or the context
+// it was scheduled with is done.
+func (ra *RepeatedAction) Run(ctx context.Context) {
+	ra.mu.Lock()
+	if ra.ctx.Err() != nil {
+		ra.cancelled = true
+	}
+	if ra.cancelled {
+		ra.mu.Unlock()
+		return
+	}
+	next := ra.sched.Clock().Now().Add(ra.interval)
+	if ra.planned != nil {
+		next = ra.planned.Time().Add(ra.interval)
+	}
+	ra.planned = nil
+	ra.mu.Unlock()
+
+	ra.action.Run(ctx)
+
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	if ra.cancelled {
+		return
+	}
+	if now := ra.sched.Clock().Now(); next.Before(now) {
+		next = now.Add(ra.interval)
+	}
+	ra.planned = ra.sched.ScheduleAction(ctx, next, ra)
+}
+
+// Cancel stops the action from running again, returning false if it was already cancelled. A run
+// that is in progress completes.
+func (ra *RepeatedAction) Cancel(ctx context.Context) bool {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	if ra.cancelled {
+		return false
+	}
+	ra.cancelled = true
+	if ra.planned != nil {
+		ra.sched.RemovePlannedAction(ctx, ra.planned)
+		ra.planned = nil
+	}
+	return true
+}
+
+// Interval returns the time between two runs of the action.
+func (ra *RepeatedAction) Interval() time.Duration {
+	return ra.interval
+}
@@ -0,0 +1,78 @@
+package event
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleRepeatedAction(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	sched := NewSimpleScheduler(clk)
+
+	var runs int
+	ra := ScheduleRepeatedAction(ctx, sched, time.Second, BasicAction(func(context.Context) { runs++ }))
+	require.Equal(t, time.Second, ra.Interval())
+	require.Equal(t, clk.Now().Add(time.Second), sched.NextActionTime(ctx))
+
+	for i := 1; i <= 3; i++ {
+		clk.Add(time.Second)
+		RunAll(ctx, sched)
+		require.Equal(t, i, runs)
+	}
+
+	// a late run is not followed by a burst of runs
+	clk.Add(5 * time.Second)
+	RunAll(ctx, sched)
+	require.Equal(t, 4, runs)
+	require.Equal(t, clk.Now().Add(time.Second), sched.NextActionTime(ctx))
+
+	require.True(t, ra.Cancel(ctx))
+	require.False(t, ra.Cancel(ctx))
+	require.Equal(t, MaxTime, sched.NextActionTime(ctx))
+	clk.Add(time.Minute)
+	RunAll(ctx, sched)
+	require.Equal(t, 4, runs)
+}
+
+func TestScheduleRepeatedActionCancelWhileRunning(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	sched := NewSimpleScheduler(clk)
+
+	var ra *RepeatedAction
+	var runs int
+	ra = ScheduleRepeatedAction(ctx, sched, time.Second, BasicAction(func(actx context.Context) {
+		runs++
+		ra.Cancel(actx)
+	}))
+
+	clk.Add(time.Second)
+	RunAll(ctx, sched)
+	require.Equal(t, 1, runs)
+	require.Equal(t, MaxTime, sched.NextActionTime(ctx))
+}
+
+func TestScheduleRepeatedActionContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	clk := clock.NewMock()
+	sched := NewSimpleScheduler(clk)
+
+	var runs int
+	ScheduleRepeatedAction(ctx, sched, time.Second, BasicAction(func(context.Context) { runs++ }))
+	clk.Add(time.Second)
+	RunAll(ctx, sched)
+	require.Equal(t, 1, runs)
+
+	cancel()
+	clk.Add(time.Second)
+	RunAll(ctx, sched)
+	require.Equal(t, 1, runs)
+	require.Equal(t, MaxTime, sched.NextActionTime(ctx))
+
+	require.Nil(t, ScheduleRepeatedAction(context.Background(), sched, 0, BasicAction(func(context.Context) {})))
+}
@@ -13,8 +13,9 @@ type Scheduler interface {
 	// Now returns the time of the scheduler's clock
 	Clock() clock.Clock
 
-	// EnqueueAction enqueues an action to run as soon as possible
-	EnqueueAction(context.Context, Action)
+	// EnqueueAction enqueues an action to run as soon as possible, and
+	// returns a handle that cancels it if it has not started yet
+	EnqueueAction(context.Context, Action) *CancellableAction
 	// ScheduleAction schedules an action to run at a specific time
 	ScheduleAction(context.Context, time.Time, Action) PlannedAction
 	// RemovePlannedAction removes an action from the scheduler planned actions
@@ -36,6 +37,31 @@ func ScheduleActionIn(ctx context.Context, s Scheduler, d time.Duration, a Actio
 	}
 }
 
+// BatchScheduler is a scheduler that can enqueue multiple actions at once
+type BatchScheduler interface {
+	Scheduler
+
+	// EnqueueActions enqueues actions to run as soon as possible, in order,
+	// and returns their handles. Either all actions are enqueued or none is,
+	// and actions enqueued concurrently are not interleaved with them.
+	EnqueueActions(context.Context, []Action) []*CancellableAction
+}
+
+// EnqueueActions enqueues actions to run as soon as possible, in order, at
+// once if the scheduler is a BatchScheduler, and returns their handles
+func EnqueueActions(ctx context.Context, s Scheduler, actions []Action) []*CancellableAction {
+	switch s := s.(type) {
+	case BatchScheduler:
+		return s.EnqueueActions(ctx, actions)
+	default:
+		handles := make([]*CancellableAction, len(actions))
+		for i, a := range actions {
+			handles[i] = s.EnqueueAction(ctx, a)
+		}
+		return handles
+	}
+}
+
 // RunManyScheduler is a scheduler that can run multiple actions at once
 type RunManyScheduler interface {
 	Scheduler
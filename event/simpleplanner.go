@@ -15,7 +15,10 @@ type SimplePlanner struct {
 	lock       sync.Mutex
 }
 
-var _ AwareActionPlanner = (*SimplePlanner)(nil)
+var (
+	_ AwareActionPlanner = (*SimplePlanner)(nil)
+	_ ListActionPlanner  = (*SimplePlanner)(nil)
+)
 
 type simpleTimedAction struct {
 	action Action
@@ -88,21 +91,44 @@ func (p *SimplePlanner) RemoveAction(ctx context.Context, pa PlannedAction) bool
 	return false
 }
 
+// PopOverdueActions returns all actions that are overdue and removes them from the planner.
+// Overdue actions whose deadline has passed are dropped, see DeadlineAction.
 func (p *SimplePlanner) PopOverdueActions(ctx context.Context) []Action {
 	p.lock.Lock()
-	defer p.lock.Unlock()
 
-	var overdue []Action
+	var overdue, expired []Action
 	now := p.Clock.Now()
 	curr := p.NextAction
 	for curr != nil && (curr.time.Before(now) || curr.time == now) {
-		overdue = append(overdue, curr.action)
+		if da := deadlineOf(curr.action); da != nil && da.Expired(now) {
+			expired = append(expired, curr.action)
+		} else {
+			overdue = append(overdue, curr.action)
+		}
 		curr = curr.next
 	}
 	p.NextAction = curr
+	p.lock.Unlock()
+
+	// callbacks run without the lock so that they may schedule actions
+	for _, a := range expired {
+		expire(ctx, now, a)
+	}
 	return overdue
 }
 
+// PlannedActions returns the planned actions in the order they are due.
+func (p *SimplePlanner) PlannedActions(context.Context) []PlannedAction {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var planned []PlannedAction
+	for curr := p.NextAction; curr != nil; curr = curr.next {
+		planned = append(planned, curr)
+	}
+	return planned
+}
+
 func (p *SimplePlanner) NextActionTime(context.Context) time.Time {
 	p.lock.Lock()
 	defer p.lock.Unlock()
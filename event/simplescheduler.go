@@ -16,17 +16,28 @@ type SimpleScheduler struct {
 
 	queue   EventQueue
 	planner AwareActionPlanner
+	stats   *statsRecorder
 }
 
-var _ AwareScheduler = (*SimpleScheduler)(nil)
+var (
+	_ AwareScheduler = (*SimpleScheduler)(nil)
+	_ BatchScheduler = (*SimpleScheduler)(nil)
+	_ DumpScheduler  = (*SimpleScheduler)(nil)
+	_ StatsScheduler = (*SimpleScheduler)(nil)
+)
 
 // NewSimpleScheduler creates a new SimpleScheduler.
 func NewSimpleScheduler(clk clock.Clock) *SimpleScheduler {
+	return newSimpleScheduler(clk, NewChanQueue(DefaultChanqueueCapacity))
+}
+
+func newSimpleScheduler(clk clock.Clock, queue EventQueue) *SimpleScheduler {
 	return &SimpleScheduler{
 		clk: clk,
 
-		queue:   NewChanQueue(DefaultChanqueueCapacity),
+		queue:   queue,
 		planner: NewSimplePlanner(clk),
+		stats:   newStatsRecorder(clk),
 	}
 }
 
@@ -36,8 +47,17 @@ func (s *SimpleScheduler) Clock() clock.Clock {
 }
 
 // EnqueueAction enqueues an action to be run as soon as possible.
-func (s *SimpleScheduler) EnqueueAction(ctx context.Context, a Action) {
-	s.queue.Enqueue(ctx, a)
+func (s *SimpleScheduler) EnqueueAction(ctx context.Context, a Action) *CancellableAction {
+	qa := s.stats.enqueue(a)
+	s.queue.Enqueue(ctx, qa)
+	return qa.handle
+}
+
+// EnqueueActions enqueues actions to be run as soon as possible, in order.
+func (s *SimpleScheduler) EnqueueActions(ctx context.Context, actions []Action) []*CancellableAction {
+	wrapped, handles := s.stats.enqueueMany(actions)
+	EnqueueMany(ctx, s.queue, wrapped)
+	return handles
 }
 
 // ScheduleAction schedules an action to run at a specific time.
@@ -61,7 +81,8 @@ func (s *SimpleScheduler) RemovePlannedAction(ctx context.Context, a PlannedActi
 func (s *SimpleScheduler) moveOverdueActions(ctx context.Context) {
 	overdue := s.planner.PopOverdueActions(ctx)
 
-	EnqueueMany(ctx, s.queue, overdue)
+	wrapped, _ := s.stats.enqueueMany(overdue)
+	EnqueueMany(ctx, s.queue, wrapped)
 }
 
 // RunOne runs one action from the scheduler's queue, returning true if an
@@ -70,12 +91,32 @@ func (s *SimpleScheduler) RunOne(ctx context.Context) bool {
 	s.moveOverdueActions(ctx)
 
 	if a := s.queue.Dequeue(ctx); a != nil {
-		a.Run(ctx)
+		s.stats.runAction(ctx, a)
 		return true
 	}
 	return false
 }
 
+// SetOnActionPanic sets the function called when an action panics. Panicking actions are
+// recovered from whether a function is set or not.
+func (s *SimpleScheduler) SetOnActionPanic(h PanicHandler) {
+	s.stats.setPanicHandler(h)
+}
+
+// Stats returns a snapshot of the statistics of the actions run by the scheduler.
+func (s *SimpleScheduler) Stats() SchedulerStats {
+	return s.stats.Stats()
+}
+
+// Dump returns a snapshot of the queued and planned actions of the scheduler.
+func (s *SimpleScheduler) Dump(ctx context.Context) SchedulerDump {
+	return SchedulerDump{
+		Now:     s.clk.Now(),
+		Queued:  s.stats.queued(),
+		Planned: dumpPlanned(ctx, s.planner),
+	}
+}
+
 // NextActionTime returns the time of the next action to run, or the current
 // time if there are actions to be run in the queue, or util.MaxTime if there
 // are no scheduled to run.
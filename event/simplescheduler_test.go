@@ -69,3 +69,20 @@ func TestSimpleScheduler(t *testing.T) {
 	// empty queue
 	require.Equal(t, MaxTime, sched.NextActionTime(ctx))
 }
+
+func TestSimpleSchedulerEnqueueActions(t *testing.T) {
+	ctx := context.Background()
+	sched := NewSimpleScheduler(clock.NewMock())
+
+	var ran []int
+	record := func(i int) Action {
+		return BasicAction(func(context.Context) { ran = append(ran, i) })
+	}
+
+	sched.EnqueueAction(ctx, record(0))
+	EnqueueActions(ctx, sched, []Action{record(1), record(2), record(3)})
+	EnqueueActions(ctx, sched, nil)
+	require.Equal(t, 4, sched.Stats().QueueDepth)
+	RunAll(ctx, sched)
+	require.Equal(t, []int{0, 1, 2, 3}, ran)
+}
@@ -0,0 +1,89 @@
+package event
+
+import (
+	"context"
+	"sync"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/util"
+)
+
+// SliceQueue is an unbounded FIFO queue backed by a slice. Unlike a ChanQueue, it allocates no
+// memory while empty and never blocks on Enqueue, which suits simulations running many mostly
+// idle schedulers.
+type SliceQueue struct {
+	mu      sync.Mutex
+	actions []Action
+}
+
+var (
+	_ EventQueueWithEmpty   = (*SliceQueue)(nil)
+	_ EventQueueEnqueueMany = (*SliceQueue)(nil)
+)
+
+// NewSliceQueue creates a new queue.
+func NewSliceQueue() *SliceQueue {
+	return &SliceQueue{}
+}
+
+// Enqueue adds an element to the queue
+func (q *SliceQueue) Enqueue(ctx context.Context, a Action) {
+	_, span := util.StartSpan(ctx, "SliceQueue.Enqueue")
+	defer span.End()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.actions = append(q.actions, a)
+}
+
+// EnqueueMany adds elements to the queue at once, in order
+func (q *SliceQueue) EnqueueMany(ctx context.Context, actions []Action) {
+	_, span := util.StartSpan(ctx, "SliceQueue.EnqueueMany")
+	defer span.End()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.actions = append(q.actions, actions...)
+}
+
+// Dequeue removes and returns the next element of the queue, or nil if the queue is empty.
+func (q *SliceQueue) Dequeue(ctx context.Context) Action {
+	_, span := util.StartSpan(ctx, "SliceQueue.Dequeue")
+	defer span.End()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.actions) == 0 {
+		span.AddEvent("empty queue")
+		return nil
+	}
+	a := q.actions[0]
+	q.actions[0] = nil
+	q.actions = q.actions[1:]
+	if len(q.actions) == 0 {
+		// release the backing array so that idle queues hold no memory
+		q.actions = nil
+	}
+	return a
+}
+
+// Empty returns true if the queue is empty
+func (q *SliceQueue) Empty() bool {
+	return q.Size() == 0
+}
+
+func (q *SliceQueue) Size() uint {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return uint(len(q.actions))
+}
+
+// Close does nothing, the queue holds no resources.
+func (q *SliceQueue) Close() {}
+
+// NewSliceScheduler creates a new SimpleScheduler with an unbounded queue, see SliceQueue.
+func NewSliceScheduler(clk clock.Clock) *SimpleScheduler {
+	return newSimpleScheduler(clk, NewSliceQueue())
+}
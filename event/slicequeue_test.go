@@ -0,0 +1,48 @@
+package event
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSliceQueue(t *testing.T) {
+	ctx := context.Background()
+	q := NewSliceQueue()
+	require.True(t, q.Empty())
+	require.Nil(t, q.Dequeue(ctx))
+
+	// the queue is not bounded by a capacity
+	n := 2 * DefaultChanqueueCapacity
+	for i := 0; i < n; i++ {
+		q.Enqueue(ctx, IntAction(i))
+	}
+	q.EnqueueMany(ctx, []Action{IntAction(n), IntAction(n + 1)})
+	require.Equal(t, uint(n+2), q.Size())
+
+	for i := 0; i < n+2; i++ {
+		require.Equal(t, IntAction(i), q.Dequeue(ctx))
+	}
+	require.True(t, q.Empty())
+	require.Nil(t, q.actions)
+	q.Close()
+}
+
+func TestSliceScheduler(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	s := NewSliceScheduler(clk)
+
+	var order []int
+	s.EnqueueAction(ctx, BasicAction(func(context.Context) { order = append(order, 1) }))
+	s.ScheduleAction(ctx, clk.Now().Add(time.Second), BasicAction(func(context.Context) { order = append(order, 2) }))
+	require.True(t, s.RunOne(ctx))
+	require.False(t, s.RunOne(ctx))
+
+	clk.Add(time.Second)
+	require.True(t, s.RunOne(ctx))
+	require.Equal(t, []int{1, 2}, order)
+}
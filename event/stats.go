@@ -0,0 +1,243 @@
+package event
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/plprobelab/go-kademlia/util"
+)
+
+// SchedulerStats is a snapshot of the activity of a scheduler, to detect a saturated event loop.
+type SchedulerStats struct {
+	QueueDepth       int           // the number of actions waiting to run
+	ActionsRun       uint64        // the number of actions run since the scheduler was created
+	ActionsPerSecond float64       // the rate at which actions ran over the last complete measurement window of at least a second
+	MeanRunTime      time.Duration // the mean time actions took to run
+	MaxRunTime       time.Duration // the longest time an action took to run
+	LongestWait      time.Duration // the time the longest waiting action has been queued, zero if the queue is empty
+	ActionsPanicked  uint64        // the number of actions that panicked, included in ActionsRun
+}
+
+// StatsScheduler is a scheduler that reports statistics about the actions it runs.
+type StatsScheduler interface {
+	Scheduler
+
+	// Stats returns a snapshot of the statistics of the scheduler.
+	Stats() SchedulerStats
+}
+
+// queuedAction is an action waiting in the queue of a scheduler, numbered in the order it was
+// enqueued.
+type queuedAction struct {
+	handle *CancellableAction
+	seq    uint64
+}
+
+func (qa *queuedAction) Run(ctx context.Context) {
+	qa.handle.Run(ctx)
+}
+
+func (qa *queuedAction) unwrap() Action {
+	return qa.handle
+}
+
+type waitingAction struct {
+	action   *queuedAction
+	enqueued time.Time
+}
+
+// statsRecorder records the statistics of a scheduler. It is safe for concurrent use.
+type statsRecorder struct {
+	clk clock.Clock
+
+	mu      sync.Mutex
+	onPanic PanicHandler
+	panics  uint64
+	seq     uint64
+	waiting []waitingAction     // the actions enqueued and not yet known to have run, in order
+	ran     map[uint64]struct{} // the actions of waiting that have run
+	run     uint64
+	total   time.Duration
+	max     time.Duration
+
+	windowStart time.Time // the start of the current one second window
+	windowRuns  uint64    // the number of actions run in the current window
+	rate        float64   // the rate of the last complete window
+}
+
+func newStatsRecorder(clk clock.Clock) *statsRecorder {
+	return &statsRecorder{
+		clk:         clk,
+		ran:         make(map[uint64]struct{}),
+		windowStart: clk.Now(),
+	}
+}
+
+// enqueue records that a is queued and returns it wrapped for dequeue.
+func (r *statsRecorder) enqueue(a Action) *queuedAction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	qa := &queuedAction{handle: NewCancellableAction(a), seq: r.seq}
+	r.waiting = append(r.waiting, waitingAction{action: qa, enqueued: r.clk.Now()})
+	return qa
+}
+
+// enqueueMany records that each of actions is queued and returns them wrapped for dequeue,
+// with their handles.
+func (r *statsRecorder) enqueueMany(actions []Action) ([]Action, []*CancellableAction) {
+	wrapped := make([]Action, len(actions))
+	handles := make([]*CancellableAction, len(actions))
+	for i, a := range actions {
+		qa := r.enqueue(a)
+		wrapped[i] = qa
+		handles[i] = qa.handle
+	}
+	return wrapped, handles
+}
+
+// runAction runs an action that was dequeued, recording the time it took. An action that was
+// cancelled, or whose deadline passed while it was queued, is dropped instead.
+func (r *statsRecorder) runAction(ctx context.Context, a Action) {
+	qa, ok := a.(*queuedAction)
+	if !ok {
+		r.protect(ctx, a, func() {
+			if !expire(ctx, r.clk.Now(), a) {
+				a.Run(ctx)
+			}
+		})
+		return
+	}
+
+	r.mu.Lock()
+	r.ran[qa.seq] = struct{}{}
+	for len(r.waiting) > 0 {
+		if _, ok := r.ran[r.waiting[0].action.seq]; !ok {
+			break
+		}
+		delete(r.ran, r.waiting[0].action.seq)
+		r.waiting[0] = waitingAction{}
+		r.waiting = r.waiting[1:]
+	}
+	r.mu.Unlock()
+
+	if !qa.handle.start() {
+		// cancelled
+		return
+	}
+
+	ctx, span := util.StartSpan(ctx, "Scheduler.RunAction")
+	defer span.End()
+	if span.IsRecording() {
+		span.SetName(NameOf(qa))
+		if origin := OriginOf(qa); origin != "" {
+			span.SetAttributes(attribute.String("Origin", origin))
+		}
+	}
+
+	start := r.clk.Now()
+	var expired bool
+	r.protect(ctx, qa.handle.action, func() {
+		if expired = expire(ctx, start, qa.handle.action); !expired {
+			qa.handle.action.Run(ctx)
+		}
+	})
+	if expired {
+		return
+	}
+	end := r.clk.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d := end.Sub(start)
+	r.run++
+	r.total += d
+	if d > r.max {
+		r.max = d
+	}
+	r.advanceWindow(end)
+	r.windowRuns++
+}
+
+// protect calls f, recovering from a panic of the action a so that it does not stop the
+// scheduler, and reporting it to the panic handler.
+func (r *statsRecorder) protect(ctx context.Context, a Action, f func()) {
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+		r.mu.Lock()
+		r.panics++
+		onPanic := r.onPanic
+		r.mu.Unlock()
+		if onPanic != nil {
+			onPanic(ctx, a, v)
+		}
+	}()
+	f()
+}
+
+// setPanicHandler sets the function called when an action panics.
+func (r *statsRecorder) setPanicHandler(h PanicHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onPanic = h
+}
+
+// advanceWindow starts a new measurement window if the current one is complete at now.
+func (r *statsRecorder) advanceWindow(now time.Time) {
+	elapsed := now.Sub(r.windowStart)
+	if elapsed < time.Second {
+		return
+	}
+	r.rate = float64(r.windowRuns) / elapsed.Seconds()
+	r.windowStart = now
+	r.windowRuns = 0
+}
+
+// queued describes the actions waiting to run, in the order they were enqueued.
+func (r *statsRecorder) queued() []ActionInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	infos := make([]ActionInfo, 0, len(r.waiting)-len(r.ran))
+	for _, w := range r.waiting {
+		if _, ok := r.ran[w.action.seq]; ok {
+			continue
+		}
+		if !w.action.handle.Pending() {
+			// cancelled
+			continue
+		}
+		infos = append(infos, newActionInfo(w.action, w.enqueued))
+	}
+	return infos
+}
+
+// Stats returns a snapshot of the statistics recorded.
+func (r *statsRecorder) Stats() SchedulerStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.clk.Now()
+	r.advanceWindow(now)
+	s := SchedulerStats{
+		QueueDepth:       len(r.waiting) - len(r.ran),
+		ActionsRun:       r.run,
+		ActionsPerSecond: r.rate,
+		MaxRunTime:       r.max,
+		ActionsPanicked:  r.panics,
+	}
+	if r.run > 0 {
+		s.MeanRunTime = r.total / time.Duration(r.run)
+	}
+	if len(r.waiting) > 0 {
+		s.LongestWait = now.Sub(r.waiting[0].enqueued)
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package event
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+func TestSchedulerStats(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	sched := NewSimpleScheduler(clk)
+	require.Equal(t, SchedulerStats{}, sched.Stats())
+
+	// an action that takes a second to run
+	slow := BasicAction(func(context.Context) { clk.Add(time.Second) })
+
+	sched.EnqueueAction(ctx, slow)
+	clk.Add(time.Second)
+	sched.EnqueueAction(ctx, IntAction(1))
+	ScheduleActionIn(ctx, sched, time.Hour, IntAction(2))
+	clk.Add(time.Second)
+
+	// planned actions are only queued once they are due
+	st := sched.Stats()
+	require.Equal(t, 2, st.QueueDepth)
+	require.Equal(t, 2*time.Second, st.LongestWait)
+
+	require.True(t, sched.RunOne(ctx))
+	st = sched.Stats()
+	require.Equal(t, 1, st.QueueDepth)
+	require.Equal(t, 2*time.Second, st.LongestWait)
+	require.EqualValues(t, 1, st.ActionsRun)
+	require.Equal(t, time.Second, st.MaxRunTime)
+
+	require.True(t, sched.RunOne(ctx))
+	st = sched.Stats()
+	require.Zero(t, st.QueueDepth)
+	require.Zero(t, st.LongestWait)
+	require.EqualValues(t, 2, st.ActionsRun)
+	require.Equal(t, 500*time.Millisecond, st.MeanRunTime)
+	require.Equal(t, time.Second, st.MaxRunTime)
+
+	// the rate is measured once a window of a second is complete
+	require.Zero(t, st.ActionsPerSecond)
+	clk.Add(time.Second)
+	require.Equal(t, 2.0, sched.Stats().ActionsPerSecond)
+}
+
+func TestSchedulerStatsPriorityQueue(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	sched := NewPriorityScheduler(clk, 0)
+
+	sched.EnqueueAction(ctx, IntAction(0))
+	clk.Add(time.Second)
+	EnqueueActionWithPriority(ctx, sched, PriorityHigh, IntAction(1))
+	clk.Add(time.Second)
+
+	// the high priority action runs first, the oldest one keeps waiting
+	require.True(t, sched.RunOne(ctx))
+	st := sched.Stats()
+	require.Equal(t, 1, st.QueueDepth)
+	require.Equal(t, 2*time.Second, st.LongestWait)
+
+	require.True(t, sched.RunOne(ctx))
+	require.Zero(t, sched.Stats().QueueDepth)
+}
+
+func TestRegisterStats(t *testing.T) {
+	sched := NewSimpleScheduler(clock.NewMock())
+	reg, err := RegisterStats(noop.NewMeterProvider().Meter("test"), sched, attribute.String("scheduler", "main"))
+	require.NoError(t, err)
+	require.NoError(t, reg.Unregister())
+}
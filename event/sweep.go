@@ -0,0 +1,29 @@
+package event
+
+import (
+	"context"
+	"time"
+)
+
+// A Sweeper is a store, table or cache that can remove its expired entries, such as a peerstore
+// or a record store.
+type Sweeper interface {
+	// Sweep removes the entries that have expired and returns the number of entries removed.
+	Sweep(ctx context.Context) (int, error)
+}
+
+// SweepFunc is a function that removes expired entries, as a Sweeper.
+type SweepFunc func(ctx context.Context) (int, error)
+
+// Sweep calls f.
+func (f SweepFunc) Sweep(ctx context.Context) (int, error) {
+	return f(ctx)
+}
+
+// ScheduleSweep schedules s to be swept on sched every interval, until ctx is done or the
+// returned action is cancelled. A failed sweep is retried at the next interval.
+func ScheduleSweep(ctx context.Context, sched Scheduler, s Sweeper, interval time.Duration) *RepeatedAction {
+	return ScheduleRepeatedAction(ctx, sched, interval, BasicAction(func(actx context.Context) {
+		_, _ = s.Sweep(actx)
+	}))
+}
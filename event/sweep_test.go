@@ -0,0 +1,40 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleSweep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clk := clock.NewMock()
+	sched := NewSimpleScheduler(clk)
+
+	var sweeps int
+	ScheduleSweep(ctx, sched, SweepFunc(func(context.Context) (int, error) {
+		sweeps++
+		return 0, fmt.Errorf("failed")
+	}), time.Minute)
+
+	RunAll(ctx, sched)
+	require.Equal(t, 0, sweeps)
+
+	// a failed sweep is retried at the next interval
+	for i := 1; i <= 2; i++ {
+		clk.Add(time.Minute)
+		RunAll(ctx, sched)
+		require.Equal(t, i, sweeps)
+	}
+
+	// sweeps stop once ctx is done
+	cancel()
+	clk.Add(time.Minute)
+	RunAll(ctx, sched)
+	require.Equal(t, 2, sweeps)
+}
@@ -0,0 +1,93 @@
+package kad
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/plprobelab/go-kademlia/kaderr"
+)
+
+// Config holds the Kademlia parameters shared by the components of a DHT. The routing tables,
+// queries, coordinator, servers, stores and maintenance components each derive the default
+// values of their own configuration from it with a function named after their configuration and
+// suffixed with From, such as query.QueryConfigFrom, so that a DHT tuned with a Config uses the
+// same parameters throughout. The DefaultConfig functions of the components use DefaultConfig.
+type Config struct {
+	BucketSize     int           // k, the number of nodes held by each bucket of a routing table, also the number of closest nodes values are stored at and servers respond with
+	Concurrency    int           // alpha, the maximum number of requests each query may have in flight
+	Resiliency     int           // beta, the number of the closest nodes a query must have received a response from before it ends
+	RequestTimeout time.Duration // the timeout of a request to a single node
+	QueryTimeout   time.Duration // the time after which a query that is not making progress is stopped
+	PeerstoreTTL   time.Duration // the time servers keep the addresses of the nodes they learn, coordinators keep those of responses for coord.Config.PeerstoreTTL
+	RecordTTL      time.Duration // the lifetime of the values stored without an expiry
+	ProviderTTL    time.Duration // the lifetime of provider records
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *Config) Validate() error {
+	if cfg.BucketSize < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "KadConfig",
+			Err:       fmt.Errorf("bucket size must be greater than zero"),
+		}
+	}
+	if cfg.Concurrency < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "KadConfig",
+			Err:       fmt.Errorf("concurrency must be greater than zero"),
+		}
+	}
+	if cfg.Resiliency < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "KadConfig",
+			Err:       fmt.Errorf("resiliency must be greater than zero"),
+		}
+	}
+	if cfg.RequestTimeout < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "KadConfig",
+			Err:       fmt.Errorf("request timeout must be greater than zero"),
+		}
+	}
+	if cfg.QueryTimeout < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "KadConfig",
+			Err:       fmt.Errorf("query timeout must be greater than zero"),
+		}
+	}
+	if cfg.PeerstoreTTL < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "KadConfig",
+			Err:       fmt.Errorf("peerstore ttl must be greater than zero"),
+		}
+	}
+	if cfg.RecordTTL < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "KadConfig",
+			Err:       fmt.Errorf("record ttl must be greater than zero"),
+		}
+	}
+	if cfg.ProviderTTL < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "KadConfig",
+			Err:       fmt.Errorf("provider ttl must be greater than zero"),
+		}
+	}
+	return nil
+}
+
+// DefaultConfig returns the default Kademlia parameters, those of the public IPFS DHT except for
+// the resiliency: queries end once the k closest nodes responded rather than the beta closest.
+// Options may be overridden before passing to the From functions of the components.
+func DefaultConfig() *Config {
+	return &Config{
+		BucketSize:     20,
+		Concurrency:    3,
+		Resiliency:     20,
+		RequestTimeout: time.Minute,
+		QueryTimeout:   5 * time.Minute,
+		PeerstoreTTL:   30 * time.Minute,
+		RecordTTL:      36 * time.Hour,
+		ProviderTTL:    48 * time.Hour,
+	}
+}
@@ -0,0 +1,28 @@
+package kad
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	require.NoError(t, DefaultConfig().Validate())
+
+	for name, invalidate := range map[string]func(*Config){
+		"bucket size":     func(cfg *Config) { cfg.BucketSize = 0 },
+		"concurrency":     func(cfg *Config) { cfg.Concurrency = 0 },
+		"resiliency":      func(cfg *Config) { cfg.Resiliency = 0 },
+		"request timeout": func(cfg *Config) { cfg.RequestTimeout = 0 },
+		"query timeout":   func(cfg *Config) { cfg.QueryTimeout = 0 },
+		"peerstore ttl":   func(cfg *Config) { cfg.PeerstoreTTL = 0 },
+		"record ttl":      func(cfg *Config) { cfg.RecordTTL = 0 },
+		"provider ttl":    func(cfg *Config) { cfg.ProviderTTL = 0 },
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			invalidate(cfg)
+			require.Error(t, cfg.Validate())
+		})
+	}
+}
@@ -64,6 +64,13 @@ type RoutingTable[K Key[K], N NodeID[K]] interface {
 	// contain at maximum the given number of entries, but also possibly less
 	// if the number exceeds the number of nodes in the routing table.
 	NearestNodes(K, int) []N
+
+	// NearestNodesFiltered behaves like NearestNodes but only returns nodes
+	// for which the supplied predicate returns true. Nodes rejected by the
+	// predicate do not count towards the given number of entries, so callers
+	// can exclude specific nodes (e.g. the requester of a FIND_NODE) without
+	// over-fetching. A nil predicate accepts all nodes.
+	NearestNodesFiltered(K, int, func(N) bool) []N
 }
 
 // NodeID is a generic node identifier and not equal to a Kademlia key. Some
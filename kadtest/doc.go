@@ -0,0 +1,5 @@
+// Package kadtest provides test doubles for code written against the interfaces of the kad
+// package: node identifiers with keys chosen by the test, nodes with addresses, request and
+// response messages, and deterministic key generators, all generic over the key type. It also
+// provides helpers for tests and benchmarks.
+package kadtest
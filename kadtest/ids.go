@@ -40,6 +40,7 @@ func (i ID[K]) String() string {
 	return key.HexString(i.key)
 }
 
+// StringID is a NodeID whose Key256 is the SHA-256 hash of a string.
 type StringID string
 
 var _ kad.NodeID[key.Key256] = (*StringID)(nil)
@@ -66,6 +67,7 @@ func (s StringID) String() string {
 	return string(s)
 }
 
+// Info is a concrete implementation of the NodeInfo interface, holding an ID and addresses.
 type Info[K kad.Key[K], A kad.Address[A]] struct {
 	id    *ID[K]
 	addrs []A
@@ -80,6 +82,16 @@ func NewInfo[K kad.Key[K], A kad.Address[A]](id *ID[K], addrs []A) *Info[K, A] {
 	}
 }
 
+// NewInfos returns the infos of nodes with the given keys, each reachable at a StrAddr holding its
+// key in hexadecimal.
+func NewInfos[K kad.Key[K]](keys ...K) []*Info[K, StrAddr] {
+	infos := make([]*Info[K, StrAddr], len(keys))
+	for i, k := range keys {
+		infos[i] = NewInfo[K, StrAddr](NewID(k), []StrAddr{StrAddr(key.FormatHex(k))})
+	}
+	return infos
+}
+
 func (a *Info[K, A]) AddAddr(addr A) {
 	a.addrs = append(a.addrs, addr)
 }
@@ -0,0 +1,96 @@
+package kadtest
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+// KeyGen generates random keys from a seed, so that tests using it see the same keys every run.
+// A KeyGen is not safe for concurrent use.
+type KeyGen struct {
+	rng *rand.Rand
+}
+
+// NewKeyGen creates a KeyGen generating the sequence of keys of seed.
+func NewKeyGen(seed int64) *KeyGen {
+	return &KeyGen{rng: rand.New(rand.NewSource(seed))}
+}
+
+// Rand returns the source of randomness of the generator, for example to pass it to
+// key.RandomWithCommonPrefix.
+func (g *KeyGen) Rand() *rand.Rand {
+	return g.rng
+}
+
+// Key8 returns a random 8-bit key.
+func (g *KeyGen) Key8() key.Key8 {
+	return key.Key8(g.rng.Uint32())
+}
+
+// Key32 returns a random 32-bit key.
+func (g *KeyGen) Key32() key.Key32 {
+	return key.Key32(g.rng.Uint32())
+}
+
+// Key64 returns a random 64-bit key.
+func (g *KeyGen) Key64() key.Key64 {
+	return key.Key64(g.rng.Uint64())
+}
+
+// Key256 returns a random 256-bit key.
+func (g *KeyGen) Key256() key.Key256 {
+	b := make([]byte, 32)
+	g.rng.Read(b)
+	return key.NewKey256(b)
+}
+
+// BitStrKey returns a random key of n bits.
+func (g *KeyGen) BitStrKey(n int) key.BitStrKey {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte('0' + g.rng.Intn(2))
+	}
+	return key.BitStrKey(b)
+}
+
+// NewKey returns a random key of type K, which must be one of the fixed-length keys of the key
+// package. It panics for other key types.
+func NewKey[K kad.Key[K]](g *KeyGen) K {
+	var k any
+	var zero K
+	switch any(zero).(type) {
+	case key.Key8:
+		k = g.Key8()
+	case key.Key32:
+		k = g.Key32()
+	case key.Key64:
+		k = g.Key64()
+	case key.Key256:
+		k = g.Key256()
+	default:
+		panic(fmt.Sprintf("kadtest: cannot generate keys of type %T", zero))
+	}
+	return k.(K)
+}
+
+// NewKeys returns n random keys of type K, as generated by NewKey.
+func NewKeys[K kad.Key[K]](g *KeyGen, n int) []K {
+	ks := make([]K, n)
+	for i := range ks {
+		ks[i] = NewKey[K](g)
+	}
+	return ks
+}
+
+// KeyWithCommonPrefix returns a random key sharing exactly cpl leading bits with base, as
+// generated by key.RandomWithCommonPrefix. It panics if the key cannot be generated.
+func KeyWithCommonPrefix[K kad.Key[K]](g *KeyGen, base K, cpl int) K {
+	k, err := key.RandomWithCommonPrefix(base, cpl, g.rng)
+	if err != nil {
+		panic("kadtest: " + err.Error())
+	}
+	return k
+}
@@ -0,0 +1,36 @@
+package kadtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+func TestKeyGen(t *testing.T) {
+	// generators with the same seed generate the same keys
+	a, b := NewKeyGen(1), NewKeyGen(1)
+	require.Equal(t, NewKeys[key.Key256](a, 5), NewKeys[key.Key256](b, 5))
+	require.Equal(t, a.BitStrKey(7), b.BitStrKey(7))
+	require.Equal(t, a.Key8(), b.Key8())
+
+	ks := NewKeys[key.Key64](NewKeyGen(2), 5)
+	require.NotEqual(t, ks, NewKeys[key.Key64](NewKeyGen(3), 5))
+	for i := 1; i < len(ks); i++ {
+		require.NotEqual(t, ks[0], ks[i])
+	}
+
+	g := NewKeyGen(4)
+	require.Len(t, g.BitStrKey(7), 7)
+	require.Equal(t, 3, key.Key32(0).CommonPrefixLength(KeyWithCommonPrefix(g, key.Key32(0), 3)))
+	require.Panics(t, func() { NewKey[key.BitStrKey](g) })
+	require.Panics(t, func() { KeyWithCommonPrefix(g, key.Key8(0), 9) })
+}
+
+func TestNewInfos(t *testing.T) {
+	infos := NewInfos(key.Key8(1), key.Key8(2))
+	require.Len(t, infos, 2)
+	require.Equal(t, key.Key8(2), infos[1].ID().Key())
+	require.Equal(t, []StrAddr{"0x02"}, infos[1].Addresses())
+}
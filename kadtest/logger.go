@@ -0,0 +1,61 @@
+package kadtest
+
+import (
+	"sync"
+
+	"github.com/plprobelab/go-kademlia/logging"
+)
+
+// LogEntry is an event recorded by a Logger.
+type LogEntry struct {
+	Level  string // "debug" or "info"
+	Msg    string
+	Fields map[string]any
+}
+
+// Logger is a logging.Logger recording the events it receives, so that tests can check the events
+// emitted by a component. It is safe for concurrent use.
+type Logger struct {
+	mu      sync.Mutex
+	entries []LogEntry
+}
+
+var _ logging.Logger = (*Logger)(nil)
+
+// NewLogger creates a Logger that has recorded nothing.
+func NewLogger() *Logger {
+	return &Logger{}
+}
+
+// Debug records a debug event.
+func (l *Logger) Debug(msg string, fields ...logging.Field) {
+	l.record("debug", msg, fields)
+}
+
+// Info records an info event.
+func (l *Logger) Info(msg string, fields ...logging.Field) {
+	l.record("info", msg, fields)
+}
+
+// Entries returns the events recorded with the message msg, in the order they were received.
+func (l *Logger) Entries(msg string) []LogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var entries []LogEntry
+	for _, e := range l.entries {
+		if e.Msg == msg {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func (l *Logger) record(level, msg string, fields []logging.Field) {
+	e := LogEntry{Level: level, Msg: msg, Fields: make(map[string]any, len(fields))}
+	for _, f := range fields {
+		e.Fields[f.Key] = f.Resolved()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, e)
+}
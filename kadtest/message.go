@@ -12,6 +12,7 @@ var _ kad.Address[StrAddr] = StrAddr("")
 
 func (a StrAddr) Equal(b StrAddr) bool { return a == b }
 
+// Request is a request for the nodes closest to a target key, identified by a string.
 type Request[K kad.Key[K]] struct {
 	target K
 	id     string
@@ -36,6 +37,8 @@ func (r *Request[K]) EmptyResponse() kad.Response[K, StrAddr] {
 	return &Response[K]{}
 }
 
+// Response is a response holding the nodes closest to the target of a request, identified by a
+// string.
 type Response[K kad.Key[K]] struct {
 	id     string
 	closer []kad.NodeInfo[K, StrAddr]
@@ -63,7 +66,7 @@ type (
 	// Response8 is a Response message that uses key.Key8
 	Response8 = Response[key.Key8]
 
-	// Request8 is a Request message that uses key.Key256
+	// Request256 is a Request message that uses key.Key256
 	Request256 = Request[key.Key256]
 
 	// Response256 is a Response message that uses key.Key256
@@ -0,0 +1,235 @@
+package kadtest
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// MeterProvider is a metric.MeterProvider recording the measurements of the counters and
+// histograms of its meters, so that tests can check the metrics recorded by a component. The
+// observable counters and gauges are observed by Collect. It is safe for concurrent use.
+type MeterProvider struct {
+	noop.MeterProvider
+
+	mu           sync.Mutex
+	measurements []measurement
+	callbacks    map[int]metric.Callback
+	nextCallback int
+}
+
+type measurement struct {
+	name  string
+	value float64
+	attrs attribute.Set
+}
+
+var _ metric.MeterProvider = (*MeterProvider)(nil)
+
+// NewMeterProvider creates a MeterProvider that has recorded nothing.
+func NewMeterProvider() *MeterProvider {
+	return &MeterProvider{callbacks: make(map[int]metric.Callback)}
+}
+
+// Meter returns a meter recording on p.
+func (p *MeterProvider) Meter(string, ...metric.MeterOption) metric.Meter {
+	return &meter{p: p}
+}
+
+// Sum returns the sum of the measurements of the instrument name carrying all of attrs.
+func (p *MeterProvider) Sum(name string, attrs ...attribute.KeyValue) float64 {
+	sum := 0.0
+	p.each(name, attrs, func(m measurement) { sum += m.value })
+	return sum
+}
+
+// Count returns the number of measurements of the instrument name carrying all of attrs.
+func (p *MeterProvider) Count(name string, attrs ...attribute.KeyValue) int {
+	n := 0
+	p.each(name, attrs, func(measurement) { n++ })
+	return n
+}
+
+// Collect runs the callbacks registered on the meters of p, recording the observations they make
+// as measurements.
+func (p *MeterProvider) Collect(ctx context.Context) error {
+	p.mu.Lock()
+	callbacks := make([]metric.Callback, 0, len(p.callbacks))
+	for _, cb := range p.callbacks {
+		callbacks = append(callbacks, cb)
+	}
+	p.mu.Unlock()
+
+	for _, cb := range callbacks {
+		if err := cb(ctx, &observer{p: p}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *MeterProvider) each(name string, attrs []attribute.KeyValue, fn func(measurement)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, m := range p.measurements {
+		if m.name == name && hasAttributes(m.attrs, attrs) {
+			fn(m)
+		}
+	}
+}
+
+func hasAttributes(set attribute.Set, attrs []attribute.KeyValue) bool {
+	for _, kv := range attrs {
+		if v, ok := set.Value(kv.Key); !ok || v != kv.Value {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *MeterProvider) record(name string, value float64, attrs attribute.Set) {
+	p.mu.Lock()
+	p.measurements = append(p.measurements, measurement{name: name, value: value, attrs: attrs})
+	p.mu.Unlock()
+}
+
+type meter struct {
+	noop.Meter
+	p *MeterProvider
+}
+
+func (m *meter) Int64Counter(name string, _ ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	return &int64Counter{p: m.p, name: name}, nil
+}
+
+func (m *meter) Float64Counter(name string, _ ...metric.Float64CounterOption) (metric.Float64Counter, error) {
+	return &float64Counter{p: m.p, name: name}, nil
+}
+
+func (m *meter) Int64Histogram(name string, _ ...metric.Int64HistogramOption) (metric.Int64Histogram, error) {
+	return &int64Histogram{p: m.p, name: name}, nil
+}
+
+func (m *meter) Float64Histogram(name string, _ ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	return &float64Histogram{p: m.p, name: name}, nil
+}
+
+func (m *meter) Int64ObservableCounter(name string, _ ...metric.Int64ObservableCounterOption) (metric.Int64ObservableCounter, error) {
+	return &int64ObservableCounter{name: name}, nil
+}
+
+func (m *meter) Int64ObservableGauge(name string, _ ...metric.Int64ObservableGaugeOption) (metric.Int64ObservableGauge, error) {
+	return &int64ObservableGauge{name: name}, nil
+}
+
+func (m *meter) Float64ObservableGauge(name string, _ ...metric.Float64ObservableGaugeOption) (metric.Float64ObservableGauge, error) {
+	return &float64ObservableGauge{name: name}, nil
+}
+
+func (m *meter) RegisterCallback(cb metric.Callback, _ ...metric.Observable) (metric.Registration, error) {
+	m.p.mu.Lock()
+	defer m.p.mu.Unlock()
+	id := m.p.nextCallback
+	m.p.nextCallback++
+	m.p.callbacks[id] = cb
+	return &registration{p: m.p, id: id}, nil
+}
+
+type registration struct {
+	noop.Registration
+	p  *MeterProvider
+	id int
+}
+
+func (r *registration) Unregister() error {
+	r.p.mu.Lock()
+	delete(r.p.callbacks, r.id)
+	r.p.mu.Unlock()
+	return nil
+}
+
+// named is an observable instrument of a meter of MeterProvider.
+type named interface {
+	instrumentName() string
+}
+
+type observer struct {
+	noop.Observer
+	p *MeterProvider
+}
+
+func (o *observer) ObserveInt64(obsrv metric.Int64Observable, v int64, opts ...metric.ObserveOption) {
+	if n, ok := obsrv.(named); ok {
+		o.p.record(n.instrumentName(), float64(v), metric.NewObserveConfig(opts).Attributes())
+	}
+}
+
+func (o *observer) ObserveFloat64(obsrv metric.Float64Observable, v float64, opts ...metric.ObserveOption) {
+	if n, ok := obsrv.(named); ok {
+		o.p.record(n.instrumentName(), v, metric.NewObserveConfig(opts).Attributes())
+	}
+}
+
+type int64ObservableCounter struct {
+	noop.Int64ObservableCounter
+	name string
+}
+
+func (c *int64ObservableCounter) instrumentName() string { return c.name }
+
+type int64ObservableGauge struct {
+	noop.Int64ObservableGauge
+	name string
+}
+
+func (g *int64ObservableGauge) instrumentName() string { return g.name }
+
+type float64ObservableGauge struct {
+	noop.Float64ObservableGauge
+	name string
+}
+
+func (g *float64ObservableGauge) instrumentName() string { return g.name }
+
+type int64Counter struct {
+	noop.Int64Counter
+	p    *MeterProvider
+	name string
+}
+
+func (c *int64Counter) Add(_ context.Context, v int64, opts ...metric.AddOption) {
+	c.p.record(c.name, float64(v), metric.NewAddConfig(opts).Attributes())
+}
+
+type float64Counter struct {
+	noop.Float64Counter
+	p    *MeterProvider
+	name string
+}
+
+func (c *float64Counter) Add(_ context.Context, v float64, opts ...metric.AddOption) {
+	c.p.record(c.name, v, metric.NewAddConfig(opts).Attributes())
+}
+
+type int64Histogram struct {
+	noop.Int64Histogram
+	p    *MeterProvider
+	name string
+}
+
+func (h *int64Histogram) Record(_ context.Context, v int64, opts ...metric.RecordOption) {
+	h.p.record(h.name, float64(v), metric.NewRecordConfig(opts).Attributes())
+}
+
+type float64Histogram struct {
+	noop.Float64Histogram
+	p    *MeterProvider
+	name string
+}
+
+func (h *float64Histogram) Record(_ context.Context, v float64, opts ...metric.RecordOption) {
+	h.p.record(h.name, v, metric.NewRecordConfig(opts).Attributes())
+}
@@ -0,0 +1,141 @@
+package key
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/plprobelab/go-kademlia/kad"
+)
+
+// BitStrKey is a key of arbitrary length represented by a string of 1's and 0's, for protocols
+// and research using keyspaces whose size is not a multiple of 8 bits. Keys of different lengths
+// cannot be compared nor combined.
+type BitStrKey string
+
+var _ kad.Key[BitStrKey] = BitStrKey("1010")
+
+// NewBitStrKey returns the key whose bits are given by s, or an error if s holds characters
+// other than 1's and 0's.
+func NewBitStrKey(s string) (BitStrKey, error) {
+	if i := strings.IndexFunc(s, func(r rune) bool { return r != '0' && r != '1' }); i >= 0 {
+		return "", fmt.Errorf("BitStrKey: invalid character %q at position %d", s[i], i)
+	}
+	return BitStrKey(s), nil
+}
+
+// BitStrKeyOf returns the BitStrKey holding the bits of k.
+func BitStrKeyOf[K kad.Key[K]](k K) BitStrKey {
+	return BitStrKey(BitString(k))
+}
+
+// BitLen returns the length of the key in bits.
+func (k BitStrKey) BitLen() int {
+	return len(k)
+}
+
+// Bit returns the value of the i'th bit of the key from most significant to least.
+func (k BitStrKey) Bit(i int) uint {
+	if i < 0 || i >= len(k) {
+		panic(bitPanicMsg)
+	}
+	if k[i] == '1' {
+		return 1
+	} else if k[i] == '0' {
+		return 0
+	}
+	panic("BitStrKey: not a binary string")
+}
+
+// Xor returns the result of the eXclusive OR operation between the key and another key of the same length.
+func (k BitStrKey) Xor(o BitStrKey) BitStrKey {
+	if len(k) != len(o) {
+		panic("BitStrKey: other key has different length")
+	}
+	buf := make([]byte, len(k))
+	for i := range buf {
+		if k[i] != o[i] {
+			buf[i] = '1'
+		} else {
+			buf[i] = '0'
+		}
+	}
+	return BitStrKey(string(buf))
+}
+
+// CommonPrefixLength returns the number of leading bits the key shares with another key of the same length.
+func (k BitStrKey) CommonPrefixLength(o BitStrKey) int {
+	if len(k) != len(o) {
+		panic("BitStrKey: other key has different length")
+	}
+	for i := 0; i < len(k); i++ {
+		if k[i] != o[i] {
+			return i
+		}
+	}
+	return len(k)
+}
+
+// Compare compares the numeric value of the key with another key of the same length.
+func (k BitStrKey) Compare(o BitStrKey) int {
+	if len(k) != len(o) {
+		panic("BitStrKey: other key has different length")
+	}
+	return strings.Compare(string(k), string(o))
+}
+
+// BitString returns a string containing the binary representation of the key.
+func (k BitStrKey) BitString() string {
+	return string(k)
+}
+
+func (k BitStrKey) String() string {
+	return string(k)
+}
+
+// Key8 returns the Key8 holding the bits of the key, or an error if the key is not 8 bits long.
+func (k BitStrKey) Key8() (Key8, error) {
+	v, err := k.uint(8)
+	return Key8(v), err
+}
+
+// Key32 returns the Key32 holding the bits of the key, or an error if the key is not 32 bits long.
+func (k BitStrKey) Key32() (Key32, error) {
+	v, err := k.uint(32)
+	return Key32(v), err
+}
+
+// Key64 returns the Key64 holding the bits of the key, or an error if the key is not 64 bits long.
+func (k BitStrKey) Key64() (Key64, error) {
+	v, err := k.uint(64)
+	return Key64(v), err
+}
+
+// Key256 returns the Key256 holding the bits of the key, or an error if the key is not 256 bits
+// long.
+func (k BitStrKey) Key256() (Key256, error) {
+	if len(k) != 256 {
+		return Key256{}, fmt.Errorf("BitStrKey: cannot convert a %d-bit key to a 256-bit key", len(k))
+	}
+	var w [4]uint64
+	for i := range w {
+		v, err := strconv.ParseUint(string(k[i*64:i*64+64]), 2, 64)
+		if err != nil {
+			return Key256{}, fmt.Errorf("BitStrKey: %w", err)
+		}
+		w[i] = v
+	}
+	return Key256{w: &w}, nil
+}
+
+// uint returns the value of a key of size bits.
+func (k BitStrKey) uint(size int) (uint64, error) {
+	if len(k) != size {
+		return 0, fmt.Errorf("BitStrKey: cannot convert a %d-bit key to a %d-bit key", len(k), size)
+	}
+	v, err := strconv.ParseUint(string(k), 2, size)
+	if err != nil {
+		return 0, fmt.Errorf("BitStrKey: %w", err)
+	}
+	return v, nil
+}
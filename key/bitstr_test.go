@@ -0,0 +1,49 @@
+package key
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBitStrKey(t *testing.T) {
+	k, err := NewBitStrKey("0110")
+	require.NoError(t, err)
+	require.Equal(t, BitStrKey("0110"), k)
+	require.Equal(t, 4, k.BitLen())
+
+	_, err = NewBitStrKey("0120")
+	require.Error(t, err)
+
+	require.Panics(t, func() { k.Bit(4) })
+	require.Panics(t, func() { k.Compare(BitStrKey("011")) })
+}
+
+func TestBitStrKeyConversions(t *testing.T) {
+	k8 := Key8(0x23)
+	require.Equal(t, BitStrKey("00100011"), BitStrKeyOf(k8))
+	got8, err := BitStrKeyOf(k8).Key8()
+	require.NoError(t, err)
+	require.Equal(t, k8, got8)
+
+	k32 := Key32(0x23e4dd03)
+	got32, err := BitStrKeyOf(k32).Key32()
+	require.NoError(t, err)
+	require.Equal(t, k32, got32)
+
+	k64 := Key64(0x23e4dd0300ff00ff)
+	got64, err := BitStrKeyOf(k64).Key64()
+	require.NoError(t, err)
+	require.Equal(t, k64, got64)
+
+	k256 := NewKey256(append([]byte{0x23, 0xe4, 0xdd, 0x03}, make([]byte, 28)...))
+	got256, err := BitStrKeyOf(k256).Key256()
+	require.NoError(t, err)
+	require.Equal(t, k256, got256)
+
+	// the key must have the size of the type it is converted to
+	_, err = BitStrKeyOf(k8).Key32()
+	require.Error(t, err)
+	_, err = BitStrKeyOf(k32).Key256()
+	require.Error(t, err)
+}
@@ -0,0 +1,57 @@
+package key
+
+import (
+	"sort"
+
+	"github.com/plprobelab/go-kademlia/kad"
+)
+
+// Distance returns the XOR distance between a and b as a big-endian unsigned integer, in as
+// many bytes as needed to hold the bits of the keys. For keys whose length is not a multiple of 8,
+// the first byte is padded with leading zero bits, so that distances between keys of the same
+// length compare with bytes.Compare as they do numerically.
+func Distance[K kad.Key[K]](a, b K) []byte {
+	d := a.Xor(b)
+	if k, ok := any(d).(Key256); ok {
+		return k.Bytes()
+	}
+	bits := d.BitLen()
+	buf := make([]byte, (bits+7)/8)
+	pad := len(buf)*8 - bits
+	for i := 0; i < bits; i++ {
+		j := i + pad
+		buf[j/8] |= byte(d.Bit(i)) << (7 - j%8)
+	}
+	return buf
+}
+
+// DistanceCmp compares the distances of a and b to target. It returns -1 if a is closer to target
+// than b, 0 if they are equally close, which only happens when a and b are equal, and +1 if b is
+// closer.
+func DistanceCmp[K kad.Key[K]](target, a, b K) int {
+	return target.Xor(a).Compare(target.Xor(b))
+}
+
+// SortByDistance sorts ks by increasing distance to target.
+func SortByDistance[K kad.Key[K]](target K, ks []K) {
+	sort.Slice(ks, func(i, j int) bool { return DistanceCmp(target, ks[i], ks[j]) < 0 })
+}
+
+// LogDistance returns the logarithmic distance between a and b, the position of the highest bit
+// set in their XOR distance counting from 1 for the least significant bit. Equal keys are at
+// distance 0, keys differing in their first bit at distance BitLen.
+func LogDistance[K kad.Key[K]](a, b K) int {
+	return a.BitLen() - a.CommonPrefixLength(b)
+}
+
+// BucketIndex returns the index of the bucket of k in a routing table of the given number of
+// buckets whose local key is self: the length of the prefix the keys share, the last bucket
+// holding the keys sharing longer prefixes. A number of buckets less than 1 does not limit the
+// index, which is BitLen for k equal to self.
+func BucketIndex[K kad.Key[K]](self, k K, buckets int) int {
+	cpl := self.CommonPrefixLength(k)
+	if buckets > 0 && cpl >= buckets {
+		return buckets - 1
+	}
+	return cpl
+}
@@ -0,0 +1,50 @@
+package key
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDistance(t *testing.T) {
+	require.Equal(t, []byte{0x0f}, Distance(Key8(0xf0), Key8(0xff)))
+	require.Equal(t, []byte{0, 0, 0x01, 0x01}, Distance(Key32(0x0100), Key32(0x01)))
+	require.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 0x03}, Distance(Key64(1), Key64(2)))
+	require.Equal(t, make([]byte, 32), Distance(Key256{}, Key256{}))
+	require.Equal(t, HashKey256([]byte("a")).Bytes(), Distance(HashKey256([]byte("a")), Key256{}))
+
+	// the leading bits of the first byte are padded with zeros
+	require.Equal(t, []byte{0x01, 0x01}, Distance(BitStrKey("0000000001"), BitStrKey("0100000000")))
+
+	// distances compare as bytes as they do numerically
+	a, b := BitStrKey("1010110"), BitStrKey("0111001")
+	target := BitStrKey("1100001")
+	require.Equal(t, DistanceCmp(target, a, b), bytes.Compare(Distance(target, a), Distance(target, b)))
+}
+
+func TestDistanceCmp(t *testing.T) {
+	require.Equal(t, -1, DistanceCmp(Key8(0x10), Key8(0x11), Key8(0x00)))
+	require.Equal(t, 1, DistanceCmp(Key8(0x10), Key8(0x00), Key8(0x11)))
+	require.Equal(t, 0, DistanceCmp(Key8(0x10), Key8(0x11), Key8(0x11)))
+
+	ks := []Key8{0x00, 0xff, 0x11, 0x10, 0x80}
+	SortByDistance(Key8(0x10), ks)
+	require.Equal(t, []Key8{0x10, 0x11, 0x00, 0x80, 0xff}, ks)
+}
+
+func TestLogDistance(t *testing.T) {
+	require.Equal(t, 0, LogDistance(Key8(0x10), Key8(0x10)))
+	require.Equal(t, 1, LogDistance(Key8(0x10), Key8(0x11)))
+	require.Equal(t, 5, LogDistance(Key8(0x10), Key8(0x00)))
+	require.Equal(t, 8, LogDistance(Key8(0x10), Key8(0x80)))
+	require.Equal(t, 256, LogDistance(Key256{}, NewKey256(append([]byte{0x80}, make([]byte, 31)...))))
+}
+
+func TestBucketIndex(t *testing.T) {
+	require.Equal(t, 0, BucketIndex(Key8(0x10), Key8(0x80), 4))
+	require.Equal(t, 3, BucketIndex(Key8(0x10), Key8(0x11), 4))
+	require.Equal(t, 3, BucketIndex(Key8(0x10), Key8(0x10), 4))
+	require.Equal(t, 7, BucketIndex(Key8(0x10), Key8(0x11), 0))
+	require.Equal(t, 8, BucketIndex(Key8(0x10), Key8(0x10), 0))
+}
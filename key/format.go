@@ -0,0 +1,194 @@
+package key
+
+import (
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/plprobelab/go-kademlia/kad"
+)
+
+// ErrMalformedKey is returned by Parse for strings that are not keys in one of the supported
+// notations.
+var ErrMalformedKey = errors.New("malformed key")
+
+// The prefixes telling the notation of a formatted key, the base32 prefix matching the multibase
+// code of lowercase unpadded base32.
+const (
+	hexPrefix    = "0x"
+	binaryPrefix = "0b"
+	base32Prefix = "b"
+)
+
+// Ellipsis ends keys formatted by FormatPrefix that were shortened.
+const Ellipsis = "…"
+
+var base32Encoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// FormatHex returns the hexadecimal representation of k prefixed by 0x. For keys whose length is
+// not a multiple of 4, the first digit holds the leading bits.
+func FormatHex[K kad.Key[K]](k K) string {
+	h := HexString(k)
+	if n := (k.BitLen() + 3) / 4; len(h) < n {
+		// the zero value of a Key256 has no hexadecimal representation of its own
+		h = strings.Repeat("0", n-len(h)) + h
+	}
+	return hexPrefix + h
+}
+
+// FormatBinary returns the binary representation of k prefixed by 0b.
+func FormatBinary[K kad.Key[K]](k K) string {
+	return binaryPrefix + BitString(k)
+}
+
+// FormatBase32 returns the lowercase unpadded base32 representation of k prefixed by b, as a
+// multibase string. For keys whose length is not a multiple of 8, the key is padded with zero bits
+// at the end.
+func FormatBase32[K kad.Key[K]](k K) string {
+	buf := make([]byte, (k.BitLen()+7)/8)
+	for i := 0; i < k.BitLen(); i++ {
+		buf[i/8] |= byte(k.Bit(i)) << (7 - i%8)
+	}
+	return base32Prefix + base32Encoding.EncodeToString(buf)
+}
+
+// FormatPrefix returns the first n bits of k, followed by an ellipsis if k is longer, as in
+// 000100…. It is the notation used to describe the region of the keyspace a key falls in.
+func FormatPrefix[K kad.Key[K]](k K, n int) string {
+	s := BitString(k)
+	if n >= len(s) {
+		return s
+	}
+	if n < 0 {
+		n = 0
+	}
+	return s[:n] + Ellipsis
+}
+
+// Parse parses the key formatted by FormatHex, FormatBinary or FormatBase32 in s. The notation is
+// told by the prefix of s. The keys of the package that have a fixed length must be written in
+// full, and digits beyond their length must be zero. A BitStrKey takes the length of the bits held
+// by s. Other key types return ErrUnsupportedKey.
+func Parse[K kad.Key[K]](s string) (K, error) {
+	var zero K
+	size := zero.BitLen() // zero for a BitStrKey, whose length comes from s
+
+	var bits BitStrKey
+	var err error
+	switch {
+	case strings.HasPrefix(s, hexPrefix):
+		bits, err = parseHex(s[len(hexPrefix):], size)
+	case strings.HasPrefix(s, binaryPrefix):
+		bits, err = NewBitStrKey(s[len(binaryPrefix):])
+	case strings.HasPrefix(s, base32Prefix):
+		bits, err = parseBase32(s[len(base32Prefix):], size)
+	default:
+		err = fmt.Errorf("unknown notation")
+	}
+	if err == nil && len(bits) == 0 {
+		err = fmt.Errorf("no digits")
+	}
+	if err != nil {
+		return zero, fmt.Errorf("%w %q: %v", ErrMalformedKey, s, err)
+	}
+
+	var k any
+	switch any(zero).(type) {
+	case Key8:
+		k, err = bits.Key8()
+	case Key32:
+		k, err = bits.Key32()
+	case Key64:
+		k, err = bits.Key64()
+	case Key256:
+		k, err = bits.Key256()
+	case BitStrKey:
+		k = bits
+	default:
+		return zero, fmt.Errorf("%w: %T", ErrUnsupportedKey, zero)
+	}
+	if err != nil {
+		return zero, fmt.Errorf("%w %q: %v", ErrMalformedKey, s, err)
+	}
+	return k.(K), nil
+}
+
+// ParsePrefix parses the bits formatted by FormatPrefix in s, with or without the ellipsis, and
+// returns them as a key as long as the prefix.
+func ParsePrefix(s string) (BitStrKey, error) {
+	bits, err := NewBitStrKey(strings.TrimSuffix(strings.TrimSuffix(s, Ellipsis), "..."))
+	if err != nil {
+		return "", fmt.Errorf("%w %q: %v", ErrMalformedKey, s, err)
+	}
+	return bits, nil
+}
+
+// parseHex returns the bits of the hexadecimal digits in s, trimmed to size bits if size is not
+// zero.
+func parseHex(s string, size int) (BitStrKey, error) {
+	var sb strings.Builder
+	sb.Grow(4 * len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		var v byte
+		switch {
+		case '0' <= c && c <= '9':
+			v = c - '0'
+		case 'a' <= c && c <= 'f':
+			v = c - 'a' + 10
+		case 'A' <= c && c <= 'F':
+			v = c - 'A' + 10
+		default:
+			return "", fmt.Errorf("invalid hexadecimal digit %q at position %d", c, i)
+		}
+		for b := 3; b >= 0; b-- {
+			sb.WriteByte('0' + v>>b&1)
+		}
+	}
+	bits := sb.String()
+	if size == 0 {
+		return BitStrKey(bits), nil
+	}
+	if len(s) != (size+3)/4 {
+		return "", fmt.Errorf("%d hexadecimal digits for a %d-bit key", len(s), size)
+	}
+	// the leading digit of a key whose length is not a multiple of 4 holds fewer bits
+	extra := len(bits) - size
+	if strings.ContainsRune(bits[:extra], '1') {
+		return "", fmt.Errorf("value overflows a %d-bit key", size)
+	}
+	return BitStrKey(bits[extra:]), nil
+}
+
+// parseBase32 returns the bits of the base32 digits in s, trimmed to size bits if size is not
+// zero.
+func parseBase32(s string, size int) (BitStrKey, error) {
+	buf, err := base32Encoding.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+	if base32Encoding.EncodeToString(buf) != s {
+		// the decoder ignores the bits of the last digit beyond the last byte
+		return "", fmt.Errorf("non-canonical base32")
+	}
+	var sb strings.Builder
+	sb.Grow(8 * len(buf))
+	for _, c := range buf {
+		for b := 7; b >= 0; b-- {
+			sb.WriteByte('0' + c>>b&1)
+		}
+	}
+	bits := sb.String()
+	if size == 0 {
+		return BitStrKey(bits), nil
+	}
+	if len(buf) != (size+7)/8 {
+		return "", fmt.Errorf("%d bytes for a %d-bit key", len(buf), size)
+	}
+	// the key is padded with zero bits at the end to a whole number of bytes
+	if strings.ContainsRune(bits[size:], '1') {
+		return "", fmt.Errorf("nonzero padding bits")
+	}
+	return BitStrKey(bits[:size]), nil
+}
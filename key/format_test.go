@@ -0,0 +1,80 @@
+package key
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+)
+
+func TestFormat(t *testing.T) {
+	require.Equal(t, "0x2e", FormatHex(Key8(0x2e)))
+	require.Equal(t, "0b00101110", FormatBinary(Key8(0x2e)))
+	require.Equal(t, "bfy", FormatBase32(Key8(0x2e)))
+	require.Equal(t, "0x0000002e", FormatHex(Key32(0x2e)))
+	require.Equal(t, "0x"+zeroHex256, FormatHex(Key256{}))
+	require.Equal(t, "0x5a", FormatHex(BitStrKey("1011010")))
+
+	require.Equal(t, "0010…", FormatPrefix(Key8(0x2e), 4))
+	require.Equal(t, "…", FormatPrefix(Key8(0x2e), -1))
+	require.Equal(t, "00101110", FormatPrefix(Key8(0x2e), 8))
+	require.Equal(t, "00101110", FormatPrefix(Key8(0x2e), 20))
+}
+
+const zeroHex256 = "0000000000000000000000000000000000000000000000000000000000000000"
+
+func TestParse(t *testing.T) {
+	t.Run("Key8", testParse(Key8(0x2e)))
+	t.Run("Key32", testParse(Key32(0x23e4dd03)))
+	t.Run("Key64", testParse(Key64(0x23e4dd0300ff00ff)))
+	t.Run("Key256", testParse(HashKey256([]byte("key"))))
+	t.Run("Key256 zero value", testParse(Key256{}))
+
+	// a BitStrKey takes the length of the digits
+	k, err := Parse[BitStrKey]("0x5a")
+	require.NoError(t, err)
+	require.Equal(t, BitStrKey("01011010"), k)
+	k, err = Parse[BitStrKey]("0b1011010")
+	require.NoError(t, err)
+	require.Equal(t, BitStrKey("1011010"), k)
+	k, err = Parse[BitStrKey]("bfy")
+	require.NoError(t, err)
+	require.Equal(t, BitStrKey("00101110"), k)
+
+	k8, err := Parse[Key8]("0x2E")
+	require.NoError(t, err)
+	require.Equal(t, Key8(0x2e), k8)
+
+	for _, s := range []string{"", "2e", "0x", "0x2", "0x02e", "0x2g", "0b0010111", "0b0010111x", "bfz", "b!!"} {
+		_, err := Parse[Key8](s)
+		require.ErrorIs(t, err, ErrMalformedKey, s)
+	}
+	_, err = Parse[otherKey]("0x2e")
+	require.ErrorIs(t, err, ErrUnsupportedKey)
+}
+
+// testParse checks that k is parsed back in all the notations it is formatted in.
+func testParse[K kad.Key[K]](k K) func(t *testing.T) {
+	return func(t *testing.T) {
+		for _, s := range []string{FormatHex(k), FormatBinary(k), FormatBase32(k)} {
+			p, err := Parse[K](s)
+			require.NoError(t, err, s)
+			require.True(t, Equal(k, p), s)
+		}
+	}
+}
+
+func TestParsePrefix(t *testing.T) {
+	for _, s := range []string{"000100…", "000100...", "000100"} {
+		k, err := ParsePrefix(s)
+		require.NoError(t, err)
+		require.Equal(t, BitStrKey("000100"), k)
+	}
+	k, err := ParsePrefix(FormatPrefix(Key8(0x2e), 3))
+	require.NoError(t, err)
+	require.Equal(t, BitStrKey("001"), k)
+
+	_, err = ParsePrefix("0001x…")
+	require.ErrorIs(t, err, ErrMalformedKey)
+}
@@ -0,0 +1,11 @@
+package key
+
+import "crypto/sha256"
+
+// HashKey256 returns the Kademlia key of an identifier, the SHA-256 of its bytes. This is the
+// convention of the public IPFS DHT and go-libp2p-kad-dht, which derive the keys of peers and
+// records by hashing their identifiers.
+func HashKey256(data []byte) Key256 {
+	h := sha256.Sum256(data)
+	return NewKey256(h[:])
+}
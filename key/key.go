@@ -1,37 +1,54 @@
 package key
 
 import (
-	"bytes"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
-	"math"
+	"math/bits"
 
 	"github.com/plprobelab/go-kademlia/kad"
 )
 
 const bitPanicMsg = "bit index out of range"
 
-// Key256 is a 256-bit Kademlia key.
+// Key256 is a 256-bit Kademlia key, the size of the SHA-256 identifier space used by real DHTs.
+// The zero value of Key256 is the zero key.
 type Key256 struct {
-	b *[32]byte // this is a pointer to keep the size of Key256 small since it is often passed as argument
+	// w holds the bits of the key in big-endian 64-bit words, so that key operations work a word
+	// at a time. It is a pointer to keep the size of Key256 small since it is often passed as
+	// argument, nil for the zero key.
+	w *[4]uint64
 }
 
 var _ kad.Key[Key256] = Key256{}
 
+// zero256 holds the words of the zero key.
+var zero256 [4]uint64
+
 // NewKey256 returns a 256-bit Kademlia key whose bits are set from the supplied bytes.
 func NewKey256(data []byte) Key256 {
 	if len(data) != 32 {
 		panic("invalid data length for key")
 	}
-	var b [32]byte
-	copy(b[:], data)
-	return Key256{b: &b}
+	var w [4]uint64
+	for i := range w {
+		w[i] = binary.BigEndian.Uint64(data[i*8:])
+	}
+	return Key256{w: &w}
 }
 
 // ZeroKey256 returns a 256-bit Kademlia key with all bits zeroed.
 func ZeroKey256() Key256 {
-	var b [32]byte
-	return Key256{b: &b}
+	var w [4]uint64
+	return Key256{w: &w}
+}
+
+// words returns the words of the key.
+func (k Key256) words() *[4]uint64 {
+	if k.w == nil {
+		return &zero256
+	}
+	return k.w
 }
 
 // Bit returns the value of the i'th bit of the key from most significant to least.
@@ -39,14 +56,7 @@ func (k Key256) Bit(i int) uint {
 	if i < 0 || i > 255 {
 		panic(bitPanicMsg)
 	}
-	if k.b == nil {
-		return 0
-	}
-	if k.b[i/8]&(byte(1)<<(7-i%8)) == 0 {
-		return 0
-	} else {
-		return 1
-	}
+	return uint(k.words()[i/64]>>(63-i%64)) & 1
 }
 
 // BitLen returns the length of the key in bits, which is always 256.
@@ -56,29 +66,16 @@ func (Key256) BitLen() int {
 
 // Xor returns the result of the eXclusive OR operation between the key and another key of the same type.
 func (k Key256) Xor(o Key256) Key256 {
-	var xored [32]byte
-	if k.b != nil && o.b != nil {
-		for i := 0; i < 32; i++ {
-			xored[i] = k.b[i] ^ o.b[i]
-		}
-	} else if k.b != nil && o.b == nil {
-		copy(xored[:], k.b[:])
-	} else if k.b == nil && o.b != nil {
-		copy(xored[:], o.b[:])
-	}
-	return Key256{b: &xored}
+	a, b := k.words(), o.words()
+	return Key256{w: &[4]uint64{a[0] ^ b[0], a[1] ^ b[1], a[2] ^ b[2], a[3] ^ b[3]}}
 }
 
 // CommonPrefixLength returns the number of leading bits the key shares with another key of the same type.
 func (k Key256) CommonPrefixLength(o Key256) int {
-	if k.b == nil || o.b == nil {
-		return 256
-	}
-	var x byte
-	for i := 0; i < 32; i++ {
-		x = k.b[i] ^ o.b[i]
-		if x != 0 {
-			return i*8 + 7 - int(math.Log2(float64(x))) // TODO: make this more efficient
+	a, b := k.words(), o.words()
+	for i := 0; i < 4; i++ {
+		if x := a[i] ^ b[i]; x != 0 {
+			return i*64 + bits.LeadingZeros64(x)
 		}
 	}
 	return 256
@@ -86,15 +83,86 @@ func (k Key256) CommonPrefixLength(o Key256) int {
 
 // Compare compares the numeric value of the key with another key of the same type.
 func (k Key256) Compare(o Key256) int {
-	return bytes.Compare(k.b[:], o.b[:])
+	a, b := k.words(), o.words()
+	for i := 0; i < 4; i++ {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// Bytes returns the bits of the key as 32 bytes, most significant first.
+func (k Key256) Bytes() []byte {
+	b := make([]byte, 32)
+	for i, w := range k.words() {
+		binary.BigEndian.PutUint64(b[i*8:], w)
+	}
+	return b
 }
 
 // HexString returns a string containing the hexadecimal representation of the key.
 func (k Key256) HexString() string {
-	if k.b == nil {
+	if k.w == nil {
 		return ""
 	}
-	return hex.EncodeToString(k.b[:])
+	return hex.EncodeToString(k.Bytes())
+}
+
+// Key64 is a 64-bit Kademlia key, suitable for simulations of large networks using keys that fit
+// in a machine word.
+type Key64 uint64
+
+var _ kad.Key[Key64] = Key64(0)
+
+// BitLen returns the length of the key in bits, which is always 64.
+func (Key64) BitLen() int {
+	return 64
+}
+
+// Bit returns the value of the i'th bit of the key from most significant to least.
+func (k Key64) Bit(i int) uint {
+	if i < 0 || i > 63 {
+		panic(bitPanicMsg)
+	}
+	return uint((k >> (63 - i)) & 1)
+}
+
+// Xor returns the result of the eXclusive OR operation between the key and another key of the same type.
+func (k Key64) Xor(o Key64) Key64 {
+	return k ^ o
+}
+
+// CommonPrefixLength returns the number of leading bits the key shares with another key of the same type.
+func (k Key64) CommonPrefixLength(o Key64) int {
+	return bits.LeadingZeros64(uint64(k ^ o))
+}
+
+// Compare compares the numeric value of the key with another key of the same type.
+func (k Key64) Compare(o Key64) int {
+	if k < o {
+		return -1
+	} else if k > o {
+		return 1
+	}
+	return 0
+}
+
+// HexString returns a string containing the hexadecimal representation of the key.
+func (k Key64) HexString() string {
+	return fmt.Sprintf("%016x", uint64(k))
+}
+
+// BitString returns a string containing the binary representation of the key.
+func (k Key64) BitString() string {
+	return fmt.Sprintf("%064b", uint64(k))
+}
+
+func (k Key64) String() string {
+	return k.HexString()
 }
 
 // Key32 is a 32-bit Kademlia key, suitable for testing and simulation of small networks.
@@ -122,16 +190,7 @@ func (k Key32) Xor(o Key32) Key32 {
 
 // CommonPrefixLength returns the number of leading bits the key shares with another key of the same type.
 func (k Key32) CommonPrefixLength(o Key32) int {
-	a := uint32(k)
-	b := uint32(o)
-	for i := 32; i > 0; i-- {
-		if a == b {
-			return i
-		}
-		a >>= 1
-		b >>= 1
-	}
-	return 0
+	return bits.LeadingZeros32(uint32(k ^ o))
 }
 
 // Compare compares the numeric value of the key with another key of the same type.
@@ -183,16 +242,7 @@ func (k Key8) Xor(o Key8) Key8 {
 
 // CommonPrefixLength returns the number of leading bits the key shares with another key of the same type.
 func (k Key8) CommonPrefixLength(o Key8) int {
-	a := uint8(k)
-	b := uint8(o)
-	for i := 8; i > 0; i-- {
-		if a == b {
-			return i
-		}
-		a >>= 1
-		b >>= 1
-	}
-	return 0
+	return bits.LeadingZeros8(uint8(k ^ o))
 }
 
 // Compare compares the numeric value of the key with another key of the same type.
@@ -2,6 +2,8 @@ package key
 
 import (
 	"fmt"
+	"math/rand"
+	"sort"
 	"strconv"
 	"testing"
 
@@ -36,6 +38,41 @@ func TestKey256(t *testing.T) {
 	tester.RunTests(t)
 }
 
+func TestKey64(t *testing.T) {
+	tester := &KeyTester[Key64]{
+		Key0:     Key64(0),
+		Key1:     Key64(1),
+		Key2:     Key64(2),
+		Key1xor2: Key64(3),
+		Key100:   Key64(0x8000000000000000),
+		Key010:   Key64(0x4000000000000000),
+		KeyX:     Key64(0x23e4dd0300000000),
+	}
+
+	tester.RunTests(t)
+}
+
+func TestKey256Nil(t *testing.T) {
+	// the zero value of Key256 behaves as the zero key
+	var k Key256
+	require.Equal(t, 0, k.Compare(ZeroKey256()))
+	require.Equal(t, 255, k.CommonPrefixLength(NewKey256(append(make([]byte, 31), 1))))
+	require.Equal(t, uint(0), k.Bit(3))
+	require.Equal(t, -1, k.Compare(NewKey256(append(make([]byte, 31), 1))))
+	require.Equal(t, 1, NewKey256(append(make([]byte, 31), 1)).Compare(k))
+}
+
+func TestKey256Bytes(t *testing.T) {
+	data := make([]byte, 32)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	k := NewKey256(data)
+	require.Equal(t, data, k.Bytes())
+	require.Equal(t, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f", k.HexString())
+	require.Equal(t, make([]byte, 32), Key256{}.Bytes())
+}
+
 func TestKey32(t *testing.T) {
 	tester := &KeyTester[Key32]{
 		Key0:     Key32(0),
@@ -230,65 +267,65 @@ func (kt *KeyTester[K]) TestHexString(t *testing.T) {
 	}
 }
 
-// BitStrKey is a key represented by a string of 1's and 0's
-type BitStrKey string
-
-var _ kad.Key[BitStrKey] = BitStrKey("1010")
-
-func (k BitStrKey) BitLen() int {
-	return len(k)
-}
-
-func (k BitStrKey) Bit(i int) uint {
-	if i < 0 || i > len(k) {
-		panic(bitPanicMsg)
-	}
-	if k[i] == '1' {
-		return 1
-	} else if k[i] == '0' {
-		return 0
-	}
-	panic("BitStrKey: not a binary string")
-}
+var (
+	benchKey  Key256
+	benchInt  int
+	benchKeys = func() []Key256 {
+		rng := rand.New(rand.NewSource(1))
+		ks := make([]Key256, 1024)
+		for i := range ks {
+			b := make([]byte, 32)
+			rng.Read(b)
+			ks[i] = NewKey256(b)
+		}
+		return ks
+	}()
+)
 
-func (k BitStrKey) Xor(o BitStrKey) BitStrKey {
-	if len(k) != len(o) {
-		panic("BitStrKey: other key has different length")
-	}
-	buf := make([]byte, len(k))
-	for i := range buf {
-		if k[i] != o[i] {
-			buf[i] = '1'
-		} else {
-			buf[i] = '0'
+func BenchmarkKey256(b *testing.B) {
+	b.Run("Xor", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			benchKey = benchKeys[i%1024].Xor(benchKeys[(i+1)%1024])
 		}
-	}
-	return BitStrKey(string(buf))
+	})
+	b.Run("Compare", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			benchInt = benchKeys[i%1024].Compare(benchKeys[(i+1)%1024])
+		}
+	})
+	b.Run("CommonPrefixLength", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			benchInt = benchKeys[i%1024].CommonPrefixLength(benchKeys[(i+1)%1024])
+		}
+	})
+	b.Run("SortByDistance", func(b *testing.B) {
+		// sorting keys by their distance to a target, as peer lists and routing tables do
+		target := benchKeys[0]
+		ks := make([]Key256, len(benchKeys))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			copy(ks, benchKeys)
+			sort.Slice(ks, func(i, j int) bool {
+				return ks[i].Xor(target).Compare(ks[j].Xor(target)) < 0
+			})
+		}
+	})
 }
 
-func (k BitStrKey) CommonPrefixLength(o BitStrKey) int {
-	if len(k) != len(o) {
-		panic("BitStrKey: other key has different length")
+func BenchmarkKey64(b *testing.B) {
+	ks := make([]Key64, 1024)
+	for i := range ks {
+		ks[i] = Key64(benchKeys[i].Bytes()[0])<<56 | Key64(i)
 	}
-	for i := 0; i < len(k); i++ {
-		if k[i] != o[i] {
-			return i
+	b.Run("CommonPrefixLength", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			benchInt = ks[i%1024].CommonPrefixLength(ks[(i+1)%1024])
 		}
-	}
-	return len(k)
+	})
 }
 
-func (k BitStrKey) Compare(o BitStrKey) int {
-	if len(k) != len(o) {
-		panic("BitStrKey: other key has different length")
-	}
-	for i := 0; i < len(k); i++ {
-		if k[i] != o[i] {
-			if k[i] < o[i] {
-				return -1
-			}
-			return 1
-		}
-	}
-	return 0
+func TestHashKey256(t *testing.T) {
+	// the SHA-256 of "hello"
+	require.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", HashKey256([]byte("hello")).HexString())
 }
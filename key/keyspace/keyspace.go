@@ -0,0 +1,93 @@
+// Package keyspace supports nodes that route with one key type while storing records under keys
+// of another, derived keyspace. For example a node may route using the Key256 of its peer ID while an
+// application layered on top, such as IPNS over the DHT, identifies its records by names that are
+// converted to routing keys only when records are looked up or placed.
+package keyspace
+
+import (
+	"crypto/sha256"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+// A Converter derives the routing key used to place and look up a record from the record's key.
+// Conversions must be deterministic so that all nodes agree on where a record belongs.
+type Converter[R any, K kad.Key[K]] interface {
+	// RoutingKey returns the key in the routing keyspace for the record key r.
+	RoutingKey(r R) K
+}
+
+// ConverterFunc adapts a function to a Converter.
+type ConverterFunc[R any, K kad.Key[K]] func(R) K
+
+var _ Converter[string, key.Key256] = ConverterFunc[string, key.Key256](nil)
+
+func (f ConverterFunc[R, K]) RoutingKey(r R) K {
+	return f(r)
+}
+
+// Identity returns a Converter for records that are stored in the routing keyspace.
+func Identity[K kad.Key[K]]() Converter[K, K] {
+	return ConverterFunc[K, K](func(k K) K { return k })
+}
+
+// SHA256 returns a Converter that derives a 256-bit routing key by hashing the record key,
+// as IPFS does for record keys and peer IDs.
+func SHA256[R ~string | ~[]byte]() Converter[R, key.Key256] {
+	return ConverterFunc[R, key.Key256](func(r R) key.Key256 {
+		h := sha256.Sum256([]byte(r))
+		return key.NewKey256(h[:])
+	})
+}
+
+// A RecordRequest is a request addressed to a record key. Its Target is the routing key derived
+// from the record key so that queries route it like any other request, while handlers receiving it
+// can recover the record key to look up their store.
+type RecordRequest[R any, K kad.Key[K], A kad.Address[A]] interface {
+	kad.Request[K, A]
+
+	// RecordKey returns the key of the record the request is about.
+	RecordKey() R
+}
+
+// Layer routes operations on records identified by keys of type R through a routing table
+// keyed by K.
+type Layer[R any, K kad.Key[K], N kad.NodeID[K]] struct {
+	rt   kad.RoutingTable[K, N]
+	conv Converter[R, K]
+}
+
+// NewLayer creates a Layer that converts record keys with conv before consulting rt.
+func NewLayer[R any, K kad.Key[K], N kad.NodeID[K]](rt kad.RoutingTable[K, N], conv Converter[R, K]) *Layer[R, K, N] {
+	return &Layer[R, K, N]{
+		rt:   rt,
+		conv: conv,
+	}
+}
+
+// RoutingKey returns the routing key of the record key r.
+func (l *Layer[R, K, N]) RoutingKey(r R) K {
+	return l.conv.RoutingKey(r)
+}
+
+// NearestNodes returns the n nodes of the routing table closest to the routing key of r.
+func (l *Layer[R, K, N]) NearestNodes(r R, n int) []N {
+	return l.rt.NearestNodes(l.conv.RoutingKey(r), n)
+}
+
+// Responsible reports whether the local node, identified by self, is one of the replication nodes
+// closest to the routing key of r given the nodes known to the routing table. Record stores can use
+// it to decide whether to accept or keep a record.
+func (l *Layer[R, K, N]) Responsible(self K, r R, replication int) bool {
+	if replication < 1 {
+		return false
+	}
+	target := l.conv.RoutingKey(r)
+	nodes := l.rt.NearestNodes(target, replication)
+	if len(nodes) < replication {
+		return true
+	}
+	farthest := nodes[len(nodes)-1].Key()
+	return key.DistanceCmp(target, self, farthest) <= 0
+}
@@ -0,0 +1,58 @@
+package keyspace
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/routing/triert"
+)
+
+func TestIdentity(t *testing.T) {
+	conv := Identity[key.Key8]()
+	require.Equal(t, key.Key8(0b00000100), conv.RoutingKey(key.Key8(0b00000100)))
+}
+
+func TestSHA256(t *testing.T) {
+	h := sha256.Sum256([]byte("/ipns/example"))
+	want := key.NewKey256(h[:])
+
+	require.True(t, key.Equal(want, SHA256[string]().RoutingKey("/ipns/example")))
+	require.True(t, key.Equal(want, SHA256[[]byte]().RoutingKey([]byte("/ipns/example"))))
+}
+
+// nameKeys maps record names to fixed routing keys so tests can reason about distances.
+var nameKeys = map[string]key.Key8{
+	"a": key.Key8(0b00000001),
+	"b": key.Key8(0b10000000),
+}
+
+func TestLayer(t *testing.T) {
+	self := kadtest.NewID(key.Key8(0))
+	rt, err := triert.New[key.Key8](self, nil)
+	require.NoError(t, err)
+
+	n1 := kadtest.NewID(key.Key8(0b00000010)) // 2
+	n2 := kadtest.NewID(key.Key8(0b10000001)) // 129
+	n3 := kadtest.NewID(key.Key8(0b11000000)) // 192
+	rt.AddNode(n1)
+	rt.AddNode(n2)
+	rt.AddNode(n3)
+
+	conv := ConverterFunc[string, key.Key8](func(name string) key.Key8 { return nameKeys[name] })
+	l := NewLayer[string, key.Key8, *kadtest.ID[key.Key8]](rt, conv)
+
+	require.Equal(t, nameKeys["b"], l.RoutingKey("b"))
+	require.Equal(t, []*kadtest.ID[key.Key8]{n2, n3}, l.NearestNodes("b", 2))
+
+	// self is within the closest nodes of "a" but not of "b"
+	require.True(t, l.Responsible(self.Key(), "a", 2))
+	require.False(t, l.Responsible(self.Key(), "b", 2))
+
+	// with fewer known nodes than the replication factor every node is responsible
+	require.True(t, l.Responsible(self.Key(), "b", 4))
+	require.False(t, l.Responsible(self.Key(), "b", 0))
+}
@@ -0,0 +1,75 @@
+package key
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/plprobelab/go-kademlia/kad"
+)
+
+// ErrUnsupportedKey is returned by the functions of the package that construct keys, for key
+// types they cannot construct.
+var ErrUnsupportedKey = errors.New("unsupported key type")
+
+// RandomWithCommonPrefix returns a uniformly random key sharing exactly cpl leading bits with
+// base, that is a random key of the bucket cpl of a routing table whose local key is base, as
+// needed to refresh the bucket. A cpl equal to the length of base returns base. The keys of this
+// package are supported, other key types return ErrUnsupportedKey.
+func RandomWithCommonPrefix[K kad.Key[K]](base K, cpl int, rng *rand.Rand) (K, error) {
+	var zero K
+	if cpl < 0 || cpl > base.BitLen() {
+		return zero, fmt.Errorf("common prefix length %d out of range for a %d-bit key", cpl, base.BitLen())
+	}
+	if cpl == base.BitLen() {
+		return base, nil
+	}
+
+	var k any
+	switch b := any(base).(type) {
+	case Key8:
+		k = b ^ Key8(randomDistance(8, cpl, rng))
+	case Key32:
+		k = b ^ Key32(randomDistance(32, cpl, rng))
+	case Key64:
+		k = b ^ Key64(randomDistance(64, cpl, rng))
+	case Key256:
+		var d [4]uint64
+		for i := range d {
+			first := i * 64 // the index of the first bit of the word
+			switch {
+			case cpl >= first+64:
+				// the word is in the common prefix
+			case cpl < first:
+				d[i] = rng.Uint64()
+			default:
+				d[i] = randomDistance(64, cpl-first, rng)
+			}
+		}
+		k = b.Xor(Key256{w: &d})
+	case BitStrKey:
+		var sb strings.Builder
+		sb.Grow(len(b))
+		sb.WriteString(string(b[:cpl]))
+		if b[cpl] == '0' {
+			sb.WriteByte('1')
+		} else {
+			sb.WriteByte('0')
+		}
+		for i := cpl + 1; i < len(b); i++ {
+			sb.WriteByte(byte('0' + rng.Intn(2)))
+		}
+		k = BitStrKey(sb.String())
+	default:
+		return zero, fmt.Errorf("%w: %T", ErrUnsupportedKey, base)
+	}
+	return k.(K), nil
+}
+
+// randomDistance returns the distance between a bits-bit key and a random key sharing exactly
+// cpl leading bits with it: cpl zero bits, a one bit, and random bits.
+func randomDistance(bits, cpl int, rng *rand.Rand) uint64 {
+	one := uint64(1) << (bits - cpl - 1)
+	return one | rng.Uint64()&(one-1)
+}
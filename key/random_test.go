@@ -0,0 +1,62 @@
+package key
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+)
+
+func TestRandomWithCommonPrefix(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	t.Run("Key8", testRandomWithCommonPrefix(Key8(0x23), rng))
+	t.Run("Key32", testRandomWithCommonPrefix(Key32(0x23e4dd03), rng))
+	t.Run("Key64", testRandomWithCommonPrefix(Key64(0x23e4dd0300ff00ff), rng))
+	t.Run("Key256", testRandomWithCommonPrefix(HashKey256([]byte("base")), rng))
+	t.Run("Key256 zero value", testRandomWithCommonPrefix(Key256{}, rng))
+	t.Run("BitStrKey", testRandomWithCommonPrefix(BitStrKey("1010110"), rng))
+
+	_, err := RandomWithCommonPrefix(Key8(0), 9, rng)
+	require.Error(t, err)
+	_, err = RandomWithCommonPrefix(Key8(0), -1, rng)
+	require.Error(t, err)
+	_, err = RandomWithCommonPrefix(otherKey{Key8(0)}, 1, rng)
+	require.ErrorIs(t, err, ErrUnsupportedKey)
+}
+
+// testRandomWithCommonPrefix draws keys sharing each possible prefix length with base, checking
+// that they share exactly the prefix and that the bits after the prefix take both values.
+func testRandomWithCommonPrefix[K kad.Key[K]](base K, rng *rand.Rand) func(t *testing.T) {
+	return func(t *testing.T) {
+		for cpl := 0; cpl <= base.BitLen(); cpl++ {
+			var ones, zeros int
+			for i := 0; i < 20; i++ {
+				k, err := RandomWithCommonPrefix(base, cpl, rng)
+				require.NoError(t, err)
+				require.Equal(t, cpl, base.CommonPrefixLength(k))
+				if cpl < base.BitLen()-1 {
+					if k.Bit(base.BitLen()-1) == 1 {
+						ones++
+					} else {
+						zeros++
+					}
+				}
+			}
+			if cpl < base.BitLen()-1 {
+				require.NotZero(t, ones)
+				require.NotZero(t, zeros)
+			}
+		}
+	}
+}
+
+// otherKey is a key type unknown to the package.
+type otherKey struct {
+	Key8
+}
+
+func (k otherKey) Xor(o otherKey) otherKey           { return otherKey{k.Key8.Xor(o.Key8)} }
+func (k otherKey) CommonPrefixLength(o otherKey) int { return k.Key8.CommonPrefixLength(o.Key8) }
+func (k otherKey) Compare(o otherKey) int            { return k.Key8.Compare(o.Key8) }
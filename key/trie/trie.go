@@ -90,9 +90,11 @@ func (tr *Trie[K, D]) shrink() {
 		tr.branch[0], tr.branch[1] = nil, nil
 	case b0.IsEmptyLeaf() && b1.IsNonEmptyLeaf():
 		tr.key = b1.key
+		tr.data = b1.data
 		tr.branch[0], tr.branch[1] = nil, nil
 	case b0.IsNonEmptyLeaf() && b1.IsEmptyLeaf():
 		tr.key = b0.key
+		tr.data = b0.data
 		tr.branch[0], tr.branch[1] = nil, nil
 	}
 }
@@ -284,7 +286,14 @@ func findFromDepth[K kad.Key[K], D any](tr *Trie[K, D], depth int, target K) (*T
 }
 
 func Closest[K kad.Key[K], D any](tr *Trie[K, D], target K, n int) []Entry[K, D] {
-	closestEntries := closestAtDepth(tr, target, n, 0)
+	return ClosestFiltered(tr, target, n, nil)
+}
+
+// ClosestFiltered returns the n closest entries to target whose key and data satisfy the supplied
+// predicate. Entries for which keep returns false are skipped without counting towards n.
+// A nil predicate keeps every entry.
+func ClosestFiltered[K kad.Key[K], D any](tr *Trie[K, D], target K, n int, keep func(K, D) bool) []Entry[K, D] {
+	closestEntries := closestAtDepth(tr, target, n, 0, keep)
 	if len(closestEntries) == 0 {
 		return []Entry[K, D]{}
 	}
@@ -296,9 +305,67 @@ type Entry[K kad.Key[K], D any] struct {
 	Data D
 }
 
-func closestAtDepth[K kad.Key[K], D any](t *Trie[K, D], target K, n int, depth int) []Entry[K, D] {
+// ClosestIterator lazily yields the entries of a trie in order of increasing distance from a target key,
+// allowing callers that stop once a condition is met to avoid materializing the closest entries up front.
+// The iterator must not be used after the trie has been modified.
+type ClosestIterator[K kad.Key[K], D any] struct {
+	target K
+	stack  []closestFrame[K, D] // subtries still to visit, the closest on top
+}
+
+type closestFrame[K kad.Key[K], D any] struct {
+	t     *Trie[K, D]
+	depth int
+}
+
+// NewClosestIterator returns an iterator over the entries of tr ordered by increasing distance from target.
+func NewClosestIterator[K kad.Key[K], D any](tr *Trie[K, D], target K) *ClosestIterator[K, D] {
+	return &ClosestIterator[K, D]{
+		target: target,
+		stack:  []closestFrame[K, D]{{t: tr, depth: 0}},
+	}
+}
+
+// Next returns the next closest entry and true, or false if all entries have been visited.
+func (it *ClosestIterator[K, D]) Next() (Entry[K, D], bool) {
+	for len(it.stack) > 0 {
+		f := it.stack[len(it.stack)-1]
+		it.stack = it.stack[:len(it.stack)-1]
+
+		if f.t == nil {
+			continue
+		}
+		if f.t.IsLeaf() {
+			if f.t.HasKey() {
+				return Entry[K, D]{Key: *f.t.Key(), Data: f.t.Data()}, true
+			}
+			continue
+		}
+		if f.depth > it.target.BitLen() {
+			// should not be possible
+			continue
+		}
+
+		// push the farther branch first so that the closer branch is visited next
+		dir := int(it.target.Bit(f.depth))
+		it.stack = append(it.stack,
+			closestFrame[K, D]{t: f.t.Branch(1 - dir), depth: f.depth + 1},
+			closestFrame[K, D]{t: f.t.Branch(dir), depth: f.depth + 1},
+		)
+	}
+	return Entry[K, D]{}, false
+}
+
+func closestAtDepth[K kad.Key[K], D any](t *Trie[K, D], target K, n int, depth int, keep func(K, D) bool) []Entry[K, D] {
+	if n <= 0 {
+		return nil
+	}
 	if t.IsLeaf() {
 		if t.HasKey() {
+			if keep != nil && !keep(*t.Key(), t.Data()) {
+				// leaf rejected by the filter
+				return nil
+			}
 			// We've found a leaf
 			return []Entry[K, D]{
 				{Key: *t.Key(), Data: t.Data()},
@@ -316,10 +383,10 @@ func closestAtDepth[K kad.Key[K], D any](t *Trie[K, D], target K, n int, depth i
 	// Find the closest direction.
 	dir := int(target.Bit(depth))
 	// Add peers from the closest direction first
-	found := closestAtDepth(t.Branch(dir), target, n, depth+1)
+	found := closestAtDepth(t.Branch(dir), target, n, depth+1, keep)
 	if len(found) == n {
 		return found
 	}
 	// Didn't find enough peers in the closest direction, try the other direction.
-	return append(found, closestAtDepth(t.Branch(1-dir), target, n-len(found), depth+1)...)
+	return append(found, closestAtDepth(t.Branch(1-dir), target, n-len(found), depth+1, keep)...)
 }
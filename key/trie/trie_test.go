@@ -4,7 +4,7 @@ import (
 	"math/rand"
 	"testing"
 
-	"github.com/plprobelab/go-kademlia/internal/kadtest"
+	"github.com/plprobelab/go-kademlia/kadtest"
 
 	"github.com/plprobelab/go-kademlia/kad"
 	"github.com/plprobelab/go-kademlia/key"
@@ -269,6 +269,25 @@ func TestImmutableRemove(t *testing.T) {
 	}
 }
 
+func TestRemoveKeepsSiblingData(t *testing.T) {
+	trie := New[key.Key32, int]()
+	trie.Add(sampleKeySet.Keys[0], 1)
+	trie.Add(sampleKeySet.Keys[1], 2)
+
+	// removing a key shrinks the trie, the remaining key must keep its data
+	require.True(t, trie.Remove(sampleKeySet.Keys[0]))
+	found, data := Find(trie, sampleKeySet.Keys[1])
+	require.True(t, found)
+	require.Equal(t, 2, data)
+
+	trie.Add(sampleKeySet.Keys[0], 1)
+	trie2, err := Remove(trie, sampleKeySet.Keys[1])
+	require.NoError(t, err)
+	found, data = Find(trie2, sampleKeySet.Keys[0])
+	require.True(t, found)
+	require.Equal(t, 1, data)
+}
+
 func TestRemoveFromEmpty(t *testing.T) {
 	tr := New[key.Key32, any]()
 	removed := tr.Remove(sampleKeySet.Keys[0])
@@ -462,6 +481,44 @@ func TestClosest(t *testing.T) {
 	})
 }
 
+func TestClosestFiltered(t *testing.T) {
+	key0 := key.Key8(0)
+
+	keys := []key.Key8{
+		key.Key8(0b00010000),
+		key.Key8(0b00100000),
+		key.Key8(0b00000110),
+		key.Key8(0b00000101),
+		key.Key8(0b00000100),
+		key.Key8(0b00001000),
+	}
+
+	tr, err := trieFromKeys[key.Key8, int](keys)
+	require.NoError(t, err)
+
+	t.Run("nil filter", func(t *testing.T) {
+		found := ClosestFiltered(tr, key0, 3, nil)
+		require.Equal(t, Closest(tr, key0, 3), found)
+	})
+
+	t.Run("excluded keys do not count", func(t *testing.T) {
+		// exclude the two keys closest to the zero key
+		exclude := func(k key.Key8, _ int) bool {
+			return k != key.Key8(0b00000100) && k != key.Key8(0b00000101)
+		}
+		found := ClosestFiltered(tr, key0, 3, exclude)
+		require.Equal(t, 3, len(found))
+		require.Equal(t, key.Key8(0b00000110), found[0].Key)
+		require.Equal(t, key.Key8(0b00001000), found[1].Key)
+		require.Equal(t, key.Key8(0b00010000), found[2].Key)
+	})
+
+	t.Run("reject all", func(t *testing.T) {
+		found := ClosestFiltered(tr, key0, 3, func(key.Key8, int) bool { return false })
+		require.Equal(t, 0, len(found))
+	})
+}
+
 func BenchmarkBuildTrieMutable(b *testing.B) {
 	b.Run("1000", benchmarkBuildTrieMutable(1000))
 	b.Run("10000", benchmarkBuildTrieMutable(10000))
@@ -867,3 +924,36 @@ func (p *triePath[K]) bitString(depthToLeaf int) string {
 		}
 	}
 }
+
+func TestClosestIterator(t *testing.T) {
+	keys := []key.Key8{
+		key.Key8(0b00010000),
+		key.Key8(0b00100000),
+		key.Key8(0b00110000),
+		key.Key8(0b00000110),
+		key.Key8(0b00000101),
+		key.Key8(0b00000100),
+		key.Key8(0b00001000),
+	}
+
+	tr, err := trieFromKeys[key.Key8, int](keys)
+	require.NoError(t, err)
+
+	for _, target := range append(keys, key.Key8(0), key.Key8(0b11111111)) {
+		it := NewClosestIterator(tr, target)
+		var found []Entry[key.Key8, int]
+		for e, ok := it.Next(); ok; e, ok = it.Next() {
+			found = append(found, e)
+		}
+		require.Equal(t, Closest(tr, target, len(keys)), found)
+
+		// an exhausted iterator stays exhausted
+		_, ok := it.Next()
+		require.False(t, ok)
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		_, ok := NewClosestIterator(New[key.Key8, int](), key.Key8(0)).Next()
+		require.False(t, ok)
+	})
+}
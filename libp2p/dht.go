@@ -0,0 +1,129 @@
+package libp2p
+
+import (
+	"github.com/multiformats/go-multiaddr"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+// ProtocolIPFSDHT is the protocol ID of the public IPFS DHT. Messages of this protocol are
+// the Message protobuf, framed with their varint length, which is the default for Libp2pEndpoint.
+const ProtocolIPFSDHT address.ProtocolID = "/ipfs/kad/1.0.0"
+
+// GetValueRequest returns a GET_VALUE request for the record stored under k.
+func GetValueRequest(k []byte) *Message {
+	return &Message{
+		Type: Message_GET_VALUE,
+		Key:  k,
+	}
+}
+
+// GetValueResponse returns a response to a GET_VALUE request for k with the record if it is
+// known, and the nodes closer to k.
+func GetValueResponse(k []byte, rec *Record, peers []kad.NodeID[key.Key256], e endpoint.NetworkedEndpoint[key.Key256, multiaddr.Multiaddr]) *Message {
+	return &Message{
+		Type:        Message_GET_VALUE,
+		Key:         k,
+		Record:      rec,
+		CloserPeers: NodeIDsToPbPeers(peers, e),
+	}
+}
+
+// PutValueRequest returns a PUT_VALUE request storing value under k.
+func PutValueRequest(k, value []byte) *Message {
+	return &Message{
+		Type: Message_PUT_VALUE,
+		Key:  k,
+		Record: &Record{
+			Key:   k,
+			Value: value,
+		},
+	}
+}
+
+// PutValueResponse returns the response to a PUT_VALUE request, which echoes the request.
+func PutValueResponse(req *Message) *Message {
+	return &Message{
+		Type:   Message_PUT_VALUE,
+		Key:    req.GetKey(),
+		Record: req.GetRecord(),
+	}
+}
+
+// GetProvidersRequest returns a GET_PROVIDERS request for the providers of k.
+func GetProvidersRequest(k []byte) *Message {
+	return &Message{
+		Type: Message_GET_PROVIDERS,
+		Key:  k,
+	}
+}
+
+// GetProvidersResponse returns a response to a GET_PROVIDERS request for k with the known
+// providers, and the nodes closer to k.
+func GetProvidersResponse(k []byte, providers []*AddrInfo, peers []kad.NodeID[key.Key256], e endpoint.NetworkedEndpoint[key.Key256, multiaddr.Multiaddr]) *Message {
+	pbProviders := make([]*Message_Peer, 0, len(providers))
+	for _, p := range providers {
+		pbProviders = append(pbProviders, AddrInfoToPbPeer(p))
+	}
+	return &Message{
+		Type:          Message_GET_PROVIDERS,
+		Key:           k,
+		ProviderPeers: pbProviders,
+		CloserPeers:   NodeIDsToPbPeers(peers, e),
+	}
+}
+
+// AddProviderRequest returns an ADD_PROVIDER request announcing that provider provides k.
+// The public IPFS DHT ignores announcements for another peer than the sender.
+func AddProviderRequest(k []byte, provider *AddrInfo) *Message {
+	return &Message{
+		Type:          Message_ADD_PROVIDER,
+		Key:           k,
+		ProviderPeers: []*Message_Peer{AddrInfoToPbPeer(provider)},
+	}
+}
+
+// PingRequest returns a PING request.
+func PingRequest() *Message {
+	return &Message{Type: Message_PING}
+}
+
+// PingResponse returns the response to a PING request.
+func PingResponse() *Message {
+	return &Message{Type: Message_PING}
+}
+
+// ProviderNodes returns the providers carried by a GET_PROVIDERS response or an ADD_PROVIDER request.
+func (msg *Message) ProviderNodes() []kad.NodeInfo[key.Key256, multiaddr.Multiaddr] {
+	providerPeers := msg.GetProviderPeers()
+	if providerPeers == nil {
+		return []kad.NodeInfo[key.Key256, multiaddr.Multiaddr]{}
+	}
+	return ParsePeers(providerPeers)
+}
+
+// MessageType returns the name of the message type, such as GET_VALUE.
+func (msg *Message) MessageType() string {
+	return msg.GetType().String()
+}
+
+// AddrInfoToPbPeer converts the given AddrInfo to a Message_Peer.
+func AddrInfoToPbPeer(ai *AddrInfo) *Message_Peer {
+	pbAddrs := make([][]byte, len(ai.Addrs))
+	for i, a := range ai.Addrs {
+		pbAddrs[i] = a.Bytes()
+	}
+	return &Message_Peer{
+		Id:    []byte(ai.AddrInfo.ID),
+		Addrs: pbAddrs,
+	}
+}
+
+// keyTarget returns the Kademlia key of a message key. As in the public IPFS DHT, it is the
+// SHA256 of the key, which for FIND_NODE requests is the Kademlia key of the peer.
+func keyTarget(k []byte) key.Key256 {
+	return key.HashKey256(k)
+}
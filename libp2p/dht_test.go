@@ -0,0 +1,130 @@
+package libp2p
+
+import (
+	"context"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/sim"
+)
+
+// TestDHTWireFormat checks the encoding of messages against the bytes produced by
+// the go-libp2p-kad-dht protobuf schema.
+func TestDHTWireFormat(t *testing.T) {
+	tests := map[string]struct {
+		msg      *Message
+		expected []byte
+	}{
+		"ping": {
+			msg:      PingRequest(),
+			expected: []byte{0x08, 0x05},
+		},
+		"get value": {
+			msg:      GetValueRequest([]byte("abc")),
+			expected: []byte{0x08, 0x01, 0x12, 0x03, 'a', 'b', 'c'},
+		},
+		"get providers": {
+			msg:      GetProvidersRequest([]byte("abc")),
+			expected: []byte{0x08, 0x03, 0x12, 0x03, 'a', 'b', 'c'},
+		},
+		"put value": {
+			// PUT_VALUE is the zero value of the message type and is not encoded
+			msg:      PutValueRequest([]byte("k"), []byte("v")),
+			expected: []byte{0x12, 0x01, 'k', 0x1a, 0x06, 0x0a, 0x01, 'k', 0x12, 0x01, 'v'},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			b, err := proto.Marshal(tc.msg)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, b)
+
+			decoded := &Message{}
+			require.NoError(t, proto.Unmarshal(b, decoded))
+			require.True(t, proto.Equal(tc.msg, decoded))
+		})
+	}
+}
+
+func TestMessageTarget(t *testing.T) {
+	msg := GetValueRequest([]byte("/v/hello"))
+	h := sha256.Sum256([]byte("/v/hello"))
+	require.True(t, key.Equal(key.NewKey256(h[:]), msg.Target()))
+}
+
+func TestPutValueResponse(t *testing.T) {
+	req := PutValueRequest([]byte("k"), []byte("v"))
+	resp := PutValueResponse(req)
+	require.True(t, proto.Equal(req, resp))
+}
+
+func TestProviders(t *testing.T) {
+	ctx := context.Background()
+	provider, err := createDummyPeerInfo("12BooooPEER7", "/ip4/1.1.1.1/tcp/4001")
+	require.NoError(t, err)
+
+	req := AddProviderRequest([]byte("cid"), provider)
+	require.Equal(t, Message_ADD_PROVIDER, req.GetType())
+	require.Equal(t, []kad.NodeInfo[key.Key256, multiaddr.Multiaddr]{provider}, req.ProviderNodes())
+
+	selfAddr, err := createDummyPeerInfo("12BoooooSELF", "/ip4/1.1.1.1")
+	require.NoError(t, err)
+	fakeEndpoint := sim.NewEndpoint[key.Key256, multiaddr.Multiaddr](selfAddr, nil, nil)
+	closer, err := createDummyPeerInfo("12BooooPEER2", "/ip4/2.2.2.2")
+	require.NoError(t, err)
+	require.NoError(t, fakeEndpoint.MaybeAddToPeerstore(ctx, closer, testPeerstoreTTL))
+
+	resp := GetProvidersResponse([]byte("cid"), []*AddrInfo{provider},
+		[]kad.NodeID[key.Key256]{closer.PeerID()}, fakeEndpoint)
+	require.Equal(t, Message_GET_PROVIDERS, resp.GetType())
+	require.Equal(t, []kad.NodeInfo[key.Key256, multiaddr.Multiaddr]{provider}, resp.ProviderNodes())
+	require.Equal(t, []kad.NodeInfo[key.Key256, multiaddr.Multiaddr]{closer}, resp.CloserNodes())
+
+	require.Empty(t, GetProvidersRequest([]byte("cid")).ProviderNodes())
+}
+
+func TestGetValueResponse(t *testing.T) {
+	rec := &Record{Key: []byte("k"), Value: []byte("v")}
+	resp := GetValueResponse([]byte("k"), rec, nil, nil)
+	require.Equal(t, Message_GET_VALUE, resp.GetType())
+	require.True(t, proto.Equal(rec, resp.GetRecord()))
+	require.Empty(t, resp.CloserNodes())
+}
+
+func TestMessageToken(t *testing.T) {
+	msg := GetValueRequest([]byte("k"))
+	require.Nil(t, msg.GetToken())
+
+	msg.SetToken([]byte("tok"))
+	require.Equal(t, []byte("tok"), msg.GetToken())
+
+	// the token survives the wire and is replaced by the next one
+	b, err := proto.Marshal(msg)
+	require.NoError(t, err)
+	var got Message
+	require.NoError(t, proto.Unmarshal(b, &got))
+	require.Equal(t, []byte("k"), got.GetKey())
+	require.Equal(t, []byte("tok"), got.GetToken())
+
+	got.SetToken([]byte("next"))
+	require.Equal(t, []byte("next"), got.GetToken())
+	got.SetToken(nil)
+	require.Nil(t, got.GetToken())
+	require.Empty(t, got.ProtoReflect().GetUnknown())
+
+	// peers unaware of tokens only see the fields they know
+	b, err = proto.Marshal(GetValueRequest([]byte("k")))
+	require.NoError(t, err)
+	msg = GetValueRequest([]byte("k"))
+	msg.SetToken([]byte("tok"))
+	withToken, err := proto.Marshal(msg)
+	require.NoError(t, err)
+	require.Equal(t, b, withToken[:len(b)])
+}
@@ -1,10 +1,18 @@
 package libp2p
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
 
 var (
 	ErrNotPeerAddrInfo         = errors.New("not peer.AddrInfo")
 	ErrRequirePeerID           = errors.New("Libp2pEndpoint requires peer.ID")
 	ErrRequireProtoKadMessage  = errors.New("Libp2pEndpoint requires ProtoKadMessage")
 	ErrRequireProtoKadResponse = errors.New("Libp2pEndpoint requires ProtoKadResponseMessage")
+	ErrRequireKadResponse      = fmt.Errorf("%w: Libp2pEndpoint requires kad.Response", endpoint.ErrGarbageResponse)
+	ErrDialBackoff             = endpoint.ErrDialBackoff
+	ErrRoutingClosed           = errors.New("routing closed")
 )
@@ -28,12 +28,9 @@ func FindPeerResponse(peers []kad.NodeID[key.Key256], e endpoint.NetworkedEndpoi
 	}
 }
 
+// Target returns the Kademlia key of the message key.
 func (msg *Message) Target() key.Key256 {
-	p, err := peer.IDFromBytes(msg.GetKey())
-	if err != nil {
-		return key.ZeroKey256()
-	}
-	return PeerID{ID: p}.Key()
+	return keyTarget(msg.GetKey())
 }
 
 func (msg *Message) EmptyResponse() kad.Response[key.Key256, multiaddr.Multiaddr] {
@@ -0,0 +1,136 @@
+//go:build interop
+
+package libp2p
+
+// The tests of this file check that Libp2pEndpoint and the IPFS DHT Message interoperate with
+// go-libp2p-kad-dht, the reference implementation of the IPFS DHT. They are only built with the
+// interop tag, so that go-libp2p-kad-dht is not a requirement of the module, and need it to be
+// added, for example with:
+//
+//	go get github.com/libp2p/go-libp2p-kad-dht@v0.24.2
+//	go test -tags interop -run TestInterop ./libp2p/
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-libp2p"
+	kaddht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+// interopValidator accepts any record of the namespace it is registered for.
+type interopValidator struct{}
+
+func (interopValidator) Validate(string, []byte) error        { return nil }
+func (interopValidator) Select(string, [][]byte) (int, error) { return 0, nil }
+
+// newKadDHT starts a go-libp2p-kad-dht server accepting the records of the "v" namespace.
+func newKadDHT(t *testing.T, ctx context.Context) (host.Host, *kaddht.IpfsDHT) {
+	h, err := libp2p.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { h.Close() })
+
+	d, err := kaddht.New(ctx, h, kaddht.Mode(kaddht.ModeServer), kaddht.NamespacedValidator("v", interopValidator{}))
+	require.NoError(t, err)
+	t.Cleanup(func() { d.Close() })
+	return h, d
+}
+
+// interopRequest sends req to p with ep and drives sched until the response is handled.
+func interopRequest(t *testing.T, ctx context.Context, ep *Libp2pEndpoint, sched event.AwareScheduler, p peer.ID, req *Message) *Message {
+	var resp *Message
+	var respErr error
+	done := make(chan struct{})
+	err := ep.SendRequestHandleResponse(ctx, ProtocolIPFSDHT, NewPeerID(p), req, &Message{}, 5*time.Second,
+		func(ctx context.Context, r kad.Response[key.Key256, ma.Multiaddr], err error) {
+			if err == nil {
+				resp = r.(*Message)
+			}
+			respErr = err
+			close(done)
+		})
+	require.NoError(t, err)
+	for {
+		select {
+		case <-done:
+			require.NoError(t, respErr)
+			return resp
+		case <-ctx.Done():
+			t.Fatalf("no response to %s request: %v", req.GetType(), ctx.Err())
+		default:
+			if !sched.RunOne(ctx) {
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}
+}
+
+func TestInterop(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	// two go-libp2p-kad-dht servers that know each other
+	h1, d1 := newKadDHT(t, ctx)
+	h2, _ := newKadDHT(t, ctx)
+	require.NoError(t, h2.Connect(ctx, peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	require.Eventually(t, func() bool {
+		return d1.RoutingTable().Find(h2.ID()) != ""
+	}, 5*time.Second, 10*time.Millisecond)
+
+	h, err := libp2p.New()
+	require.NoError(t, err)
+	defer h.Close()
+	sched := event.NewSimpleScheduler(clock.New())
+	ep := NewLibp2pEndpoint(ctx, h, sched)
+	require.NoError(t, ep.MaybeAddToPeerstore(ctx, NewAddrInfo(peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}), time.Hour))
+
+	t.Run("find node", func(t *testing.T) {
+		resp := interopRequest(t, ctx, ep, sched, h1.ID(), FindPeerRequest(NewPeerID(h2.ID())))
+		require.Equal(t, Message_FIND_NODE, resp.GetType())
+
+		var found bool
+		for _, info := range resp.CloserNodes() {
+			ai, ok := info.(*AddrInfo)
+			require.True(t, ok)
+			if ai.AddrInfo.ID == h2.ID() {
+				found = true
+				require.NotEmpty(t, ai.Addrs)
+			}
+		}
+		require.True(t, found, "the closer peers of the response do not include the target")
+	})
+
+	t.Run("get value", func(t *testing.T) {
+		k := []byte("/v/hello")
+
+		// nothing is stored yet
+		resp := interopRequest(t, ctx, ep, sched, h1.ID(), GetValueRequest(k))
+		require.Equal(t, Message_GET_VALUE, resp.GetType())
+		require.Nil(t, resp.GetRecord())
+
+		resp = interopRequest(t, ctx, ep, sched, h1.ID(), PutValueRequest(k, []byte("world")))
+		require.Equal(t, Message_PUT_VALUE, resp.GetType())
+		require.Equal(t, k, resp.GetKey())
+
+		resp = interopRequest(t, ctx, ep, sched, h1.ID(), GetValueRequest(k))
+		require.NotNil(t, resp.GetRecord())
+		require.Equal(t, k, resp.GetRecord().GetKey())
+		require.Equal(t, []byte("world"), resp.GetRecord().GetValue())
+	})
+
+	t.Run("ping", func(t *testing.T) {
+		resp := interopRequest(t, ctx, ep, sched, h1.ID(), PingRequest())
+		require.Equal(t, Message_PING, resp.GetType())
+	})
+}
@@ -0,0 +1,28 @@
+package libp2p
+
+import (
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mh "github.com/multiformats/go-multihash"
+
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+// KeyFromPeerID returns the Kademlia key of a peer, the SHA-256 of its peer id, as in
+// go-libp2p-kad-dht.
+func KeyFromPeerID(p peer.ID) key.Key256 {
+	return key.HashKey256([]byte(p))
+}
+
+// KeyFromMultihash returns the Kademlia key under which the providers of the content with hash h
+// are stored, the SHA-256 of the multihash, as in go-libp2p-kad-dht.
+func KeyFromMultihash(h mh.Multihash) key.Key256 {
+	return key.HashKey256(h)
+}
+
+// KeyFromCID returns the Kademlia key under which the providers of the content identified by c
+// are stored. Providers are keyed by the multihash of the content, so that CIDs of different
+// versions or codecs of the same content share a key.
+func KeyFromCID(c cid.Cid) key.Key256 {
+	return KeyFromMultihash(c.Hash())
+}
@@ -0,0 +1,35 @@
+package libp2p
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+func TestKeyDerivation(t *testing.T) {
+	p, err := peer.Decode("12D3KooWH6Qd1EW75ANiCtYfD51D6M7MiZwLQ4g8wEBpoEUnVYNz")
+	require.NoError(t, err)
+	h := sha256.Sum256([]byte(p))
+	require.Equal(t, key.NewKey256(h[:]), KeyFromPeerID(p))
+	require.Equal(t, KeyFromPeerID(p), NewPeerID(p).Key())
+	// FIND_NODE requests for the peer target the key of the peer
+	require.True(t, key.Equal(KeyFromPeerID(p), FindPeerRequest(NewPeerID(p)).Target()))
+
+	hash, err := mh.Sum([]byte("hello"), mh.SHA2_256, -1)
+	require.NoError(t, err)
+	h = sha256.Sum256(hash)
+	require.Equal(t, key.NewKey256(h[:]), KeyFromMultihash(hash))
+
+	// the CIDs of the same content share the key of its multihash
+	v0 := cid.NewCidV0(hash)
+	v1 := cid.NewCidV1(cid.Raw, hash)
+	require.NotEqual(t, v0, v1)
+	require.Equal(t, KeyFromMultihash(hash), KeyFromCID(v0))
+	require.Equal(t, KeyFromMultihash(hash), KeyFromCID(v1))
+}
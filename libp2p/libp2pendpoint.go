@@ -12,49 +12,212 @@ import (
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
-	"github.com/libp2p/go-msgio/pbio"
+	"github.com/libp2p/go-msgio"
 	"github.com/multiformats/go-multiaddr"
+	msmux "github.com/multiformats/go-multistream"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/plprobelab/go-kademlia/event"
 	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
 	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/logging"
 	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/codec"
 	"github.com/plprobelab/go-kademlia/network/endpoint"
 	"github.com/plprobelab/go-kademlia/util"
 )
 
 type DialReportFn func(context.Context, bool)
 
-// TODO: Use sync.Pool to reuse buffers https://pkg.go.dev/sync#Pool
+// EndpointConfig specifies optional configuration for a Libp2pEndpoint
+type EndpointConfig struct {
+	Limits          endpoint.MessageLimits // the limits on the size of messages and the number of closer nodes in responses
+	DialBackoffBase time.Duration          // the delay before a peer may be dialled again after a failed dial, doubled after each further failure, zero disables backoff
+	DialBackoffMax  time.Duration          // the maximum delay before a peer may be dialled again after failed dials
+	MaxDials        int                    // the maximum number of dials in progress at once
+	MaxIdleStreams  int                    // the maximum number of idle streams kept open for reuse per peer and protocol, zero disables reuse
+	PeerstoreTTL    time.Duration          // the duration for which the address of a peer that sent a request is kept in the peerstore
+	RateLimiter     *endpoint.RateLimiter  // an optional limiter of inbound requests, the stream of a request it rejects is reset
+	MeterProvider   metric.MeterProvider   // an optional provider of the meter recording the messages sent and received, nil records nothing
+	Logger          logging.Logger         // an optional logger the endpoint emits events about requests to, nil logs nothing
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *EndpointConfig) Validate() error {
+	if err := cfg.Limits.Validate(); err != nil {
+		return err
+	}
+	if cfg.DialBackoffBase < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "EndpointConfig",
+			Err:       fmt.Errorf("dial backoff base must not be negative"),
+		}
+	}
+	if cfg.DialBackoffMax < cfg.DialBackoffBase {
+		return &kaderr.ConfigurationError{
+			Component: "EndpointConfig",
+			Err:       fmt.Errorf("dial backoff max must not be less than dial backoff base"),
+		}
+	}
+	if cfg.MaxDials < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "EndpointConfig",
+			Err:       fmt.Errorf("max dials must be greater than zero"),
+		}
+	}
+	if cfg.MaxIdleStreams < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "EndpointConfig",
+			Err:       fmt.Errorf("max idle streams must not be negative"),
+		}
+	}
+	if cfg.PeerstoreTTL < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "EndpointConfig",
+			Err:       fmt.Errorf("peerstore ttl must not be negative"),
+		}
+	}
+	return nil
+}
+
+// DefaultEndpointConfig returns the default configuration options for a Libp2pEndpoint.
+// Options may be overridden before passing to NewLibp2pEndpointWithConfig
+func DefaultEndpointConfig() *EndpointConfig {
+	return &EndpointConfig{
+		Limits:          endpoint.DefaultMessageLimits(network.MessageSizeMax),
+		DialBackoffBase: 5 * time.Second,
+		DialBackoffMax:  5 * time.Minute,
+		MaxDials:        16,
+		MaxIdleStreams:  2,
+		PeerstoreTTL:    30 * time.Minute,
+	}
+}
 
 type Libp2pEndpoint struct {
 	ctx   context.Context
 	host  host.Host
 	sched event.Scheduler
+	cfg   EndpointConfig
+
+	// peerstore adapts the peerstore of the host
+	peerstore *Peerstore
+
+	// dialer deduplicates and limits dials, and delays dials to peers that could not be reached recently
+	dialer *endpoint.Dialer
+
+	// streams holds idle outbound streams for reuse
+	streams *streamPool
 
-	// peer filters to be applied before adding peer to peerstore
+	// codecs selects how the messages of each protocol are encoded, protobuf by default
+	codecs *codec.Registry
 
-	writers sync.Pool
-	readers sync.Pool
+	// counters counts the messages rejected by the configured limits
+	counters endpoint.MessageCounters
+
+	// events delivers the connection events of the host
+	events *endpoint.ConnEventBus[key.Key256]
+
+	// metrics records the latency and reliability of the peers requests are sent to
+	metrics *endpoint.PeerMetricsRecorder
+
+	// traffic records the messages sent and received
+	traffic *endpoint.Traffic
+
+	log logging.Logger
 }
 
 var (
-	_ endpoint.NetworkedEndpoint[key.Key256, multiaddr.Multiaddr] = (*Libp2pEndpoint)(nil)
-	_ endpoint.ServerEndpoint[key.Key256, multiaddr.Multiaddr]    = (*Libp2pEndpoint)(nil)
+	_ endpoint.NetworkedEndpoint[key.Key256, multiaddr.Multiaddr]        = (*Libp2pEndpoint)(nil)
+	_ endpoint.ServerEndpoint[key.Key256, multiaddr.Multiaddr]           = (*Libp2pEndpoint)(nil)
+	_ endpoint.ConnEventEndpoint[key.Key256, multiaddr.Multiaddr]        = (*Libp2pEndpoint)(nil)
+	_ endpoint.NegotiatingEndpoint[key.Key256, multiaddr.Multiaddr]      = (*Libp2pEndpoint)(nil)
+	_ endpoint.MetricsEndpoint[key.Key256, multiaddr.Multiaddr]          = (*Libp2pEndpoint)(nil)
+	_ endpoint.ProtocolCheckingEndpoint[key.Key256, multiaddr.Multiaddr] = (*Libp2pEndpoint)(nil)
 )
 
+// NewLibp2pEndpoint creates a Libp2pEndpoint using the default config.
 func NewLibp2pEndpoint(ctx context.Context, host host.Host,
 	sched event.Scheduler,
 ) *Libp2pEndpoint {
-	return &Libp2pEndpoint{
-		ctx:     ctx,
-		host:    host,
-		sched:   sched,
-		writers: sync.Pool{},
-		readers: sync.Pool{},
+	e, _ := NewLibp2pEndpointWithConfig(ctx, host, sched, nil)
+	return e
+}
+
+// NewLibp2pEndpointWithConfig creates a Libp2pEndpoint. If cfg is nil, the default config is used.
+func NewLibp2pEndpointWithConfig(ctx context.Context, host host.Host,
+	sched event.Scheduler, cfg *EndpointConfig,
+) (*Libp2pEndpoint, error) {
+	if cfg == nil {
+		cfg = DefaultEndpointConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	dialer, err := endpoint.NewDialer(&endpoint.DialerConfig{
+		Clock:            sched.Clock(),
+		MaxParallelDials: cfg.MaxDials,
+		BackoffBase:      cfg.DialBackoffBase,
+		BackoffMax:       cfg.DialBackoffMax,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	traffic, err := endpoint.NewTraffic(cfg.MeterProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Libp2pEndpoint{
+		ctx:       ctx,
+		host:      host,
+		sched:     sched,
+		cfg:       *cfg,
+		peerstore: NewPeerstore(host),
+		dialer:    dialer,
+		streams:   newStreamPool(cfg.MaxIdleStreams),
+		codecs:    codec.NewRegistry(codec.Protobuf{}),
+		events:    endpoint.NewConnEventBus[key.Key256](),
+		metrics:   endpoint.NewPeerMetricsRecorder(sched.Clock()),
+		traffic:   traffic,
+		log:       logging.OrNop(cfg.Logger),
+	}
+
+	// connected holds the peers reported as connected, so that only the first connection to a
+	// peer and the closing of its last connection are reported
+	var mu sync.Mutex
+	connected := make(map[peer.ID]struct{})
+	notifee := &network.NotifyBundle{
+		ConnectedF: func(n network.Network, c network.Conn) {
+			mu.Lock()
+			defer mu.Unlock()
+			p := c.RemotePeer()
+			if _, ok := connected[p]; !ok {
+				connected[p] = struct{}{}
+				e.events.Emit(&endpoint.EventConnected[key.Key256]{NodeID: NewPeerID(p)})
+			}
+		},
+		DisconnectedF: func(n network.Network, c network.Conn) {
+			mu.Lock()
+			defer mu.Unlock()
+			p := c.RemotePeer()
+			if _, ok := connected[p]; ok && n.Connectedness(p) != network.Connected {
+				delete(connected, p)
+				e.events.Emit(&endpoint.EventDisconnected[key.Key256]{NodeID: NewPeerID(p)})
+			}
+		},
+	}
+	host.Network().Notify(notifee)
+	if done := ctx.Done(); done != nil {
+		go func() {
+			<-done
+			host.Network().StopNotify(notifee)
+		}()
 	}
+	return e, nil
 }
 
 func getPeerID(id kad.NodeID[key.Key256]) (*PeerID, error) {
@@ -119,8 +282,7 @@ func (e *Libp2pEndpoint) DialPeer(ctx context.Context, id kad.NodeID[key.Key256]
 		return nil
 	}
 
-	pi := peer.AddrInfo{ID: p.ID}
-	if err := e.host.Connect(ctx, pi); err != nil {
+	if err := e.connect(ctx, p.ID); err != nil {
 		span.AddEvent("Connection failed", trace.WithAttributes(
 			attribute.String("Error", err.Error()),
 		))
@@ -130,6 +292,72 @@ func (e *Libp2pEndpoint) DialPeer(ctx context.Context, id kad.NodeID[key.Key256]
 	return nil
 }
 
+// connect dials p through the dialer of the endpoint.
+func (e *Libp2pEndpoint) connect(ctx context.Context, p peer.ID) error {
+	err := e.dialer.Dial(ctx, p.String(), func(ctx context.Context) error {
+		return e.host.Connect(ctx, peer.AddrInfo{ID: p})
+	})
+	if err != nil {
+		if !errors.Is(err, endpoint.ErrDialFailure) {
+			err = fmt.Errorf("%w: %w", endpoint.ErrCannotConnect, err)
+		}
+		e.events.Emit(&endpoint.EventDialFailed[key.Key256]{NodeID: NewPeerID(p), Error: err})
+	}
+	return err
+}
+
+// SubscribeConnEvents returns a subscription to the connection events of the host, buffering
+// up to size events. Disconnections are reported when the last connection to a peer is
+// closed. Events are emitted on the go routines of libp2p and of the dialling callers.
+func (e *Libp2pEndpoint) SubscribeConnEvents(size int) *endpoint.ConnSubscription[key.Key256] {
+	return e.events.Subscribe(size)
+}
+
+// DialStats returns the counters of the dials made by the endpoint.
+// Metrics returns the latency and reliability recorded for the requests sent to id.
+func (e *Libp2pEndpoint) Metrics(id kad.NodeID[key.Key256]) (endpoint.PeerMetrics, bool) {
+	return e.metrics.Metrics(id.String())
+}
+
+func (e *Libp2pEndpoint) DialStats() endpoint.DialStats {
+	return e.dialer.Stats()
+}
+
+// openStream returns a stream to p for the first of protoIDs that p supports, reusing an idle
+// stream if one is available. It reports whether the stream was reused.
+func (e *Libp2pEndpoint) openStream(ctx context.Context, p peer.ID, protoIDs ...protocol.ID) (*pooledStream, bool, error) {
+	for _, protoID := range protoIDs {
+		if ps := e.streams.Get(p, protoID); ps != nil {
+			return ps, true, nil
+		}
+	}
+
+	if e.host.Network().Connectedness(p) != network.Connected {
+		if err := e.connect(ctx, p); err != nil {
+			return nil, false, err
+		}
+	}
+
+	s, err := e.host.NewStream(ctx, p, protoIDs...)
+	if err != nil {
+		if errors.As(err, &msmux.ErrNotSupported[protocol.ID]{}) {
+			err = fmt.Errorf("%w: %v", endpoint.ErrProtocolNotSupported, err)
+		}
+		return nil, false, err
+	}
+	return newPooledStream(s, e.cfg.Limits.MaxResponseSize), false, nil
+}
+
+// releaseStream returns a stream that completed a request and response exchange to the pool
+// of idle streams, or closes it if streams are not reused.
+func (e *Libp2pEndpoint) releaseStream(ps *pooledStream) {
+	if e.cfg.MaxIdleStreams > 0 {
+		e.streams.Put(ps)
+		return
+	}
+	ps.s.Close()
+}
+
 func (e *Libp2pEndpoint) MaybeAddToPeerstore(ctx context.Context,
 	id kad.NodeInfo[key.Key256, multiaddr.Multiaddr], ttl time.Duration,
 ) error {
@@ -147,8 +375,7 @@ func (e *Libp2pEndpoint) MaybeAddToPeerstore(ctx context.Context,
 		e.host.Network().Connectedness(ai.PeerID().ID) == network.Connected {
 		return nil
 	}
-	e.host.Peerstore().AddAddrs(ai.PeerID().ID, ai.Addrs, ttl)
-	return nil
+	return e.peerstore.Add(ctx, ai, ttl)
 }
 
 func (e *Libp2pEndpoint) SendRequestHandleResponse(ctx context.Context,
@@ -162,40 +389,89 @@ func (e *Libp2pEndpoint) SendRequestHandleResponse(ctx context.Context,
 		))
 	defer span.End()
 
-	protoResp, ok := resp.(ProtoKadResponseMessage[key.Key256, multiaddr.Multiaddr])
-	if !ok {
-		span.RecordError(ErrRequireProtoKadResponse)
-		return ErrRequireProtoKadResponse
+	var handleResp endpoint.NegotiatedResponseHandlerFn[key.Key256, multiaddr.Multiaddr]
+	if responseHandlerFn != nil {
+		handleResp = func(ctx context.Context, _ address.ProtocolID, resp kad.Response[key.Key256, multiaddr.Multiaddr], err error) {
+			responseHandlerFn(ctx, resp, err)
+		}
 	}
+	err := e.sendRequest(ctx, []address.ProtocolID{protoID}, n, req, resp, timeout, handleResp)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
 
-	protoReq, ok := req.(ProtoKadMessage)
-	if !ok {
-		span.RecordError(ErrRequireProtoKadMessage)
-		return ErrRequireProtoKadMessage
+// SendRequestNegotiate sends a request to the given peer with the first of protoIDs that the
+// peer supports, negotiated with multistream-select when a new stream is opened.
+func (e *Libp2pEndpoint) SendRequestNegotiate(ctx context.Context,
+	protoIDs []address.ProtocolID, n kad.NodeID[key.Key256], req kad.Message,
+	resp kad.Message, timeout time.Duration,
+	responseHandlerFn endpoint.NegotiatedResponseHandlerFn[key.Key256, multiaddr.Multiaddr],
+) error {
+	_, span := util.StartSpan(ctx,
+		"Libp2pEndpoint.SendRequestNegotiate", trace.WithAttributes(
+			attribute.String("PeerID", n.String()),
+		))
+	defer span.End()
+
+	if len(protoIDs) == 0 {
+		span.RecordError(endpoint.ErrProtocolNotSupported)
+		return endpoint.ErrProtocolNotSupported
+	}
+	err := e.sendRequest(ctx, protoIDs, n, req, resp, timeout, responseHandlerFn)
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+// sendRequest sends a request with the first of protoIDs that the remote peer supports and
+// handles the response asynchronously.
+func (e *Libp2pEndpoint) sendRequest(ctx context.Context,
+	protoIDs []address.ProtocolID, n kad.NodeID[key.Key256], req kad.Message,
+	resp kad.Message, timeout time.Duration,
+	handleResp endpoint.NegotiatedResponseHandlerFn[key.Key256, multiaddr.Multiaddr],
+) error {
+	pids := make([]protocol.ID, len(protoIDs))
+	for i, protoID := range protoIDs {
+		pids[i] = protocol.ID(protoID)
+		if !isProtobuf(e.codecs.Get(protoID)) {
+			continue
+		}
+		if _, ok := resp.(ProtoKadResponseMessage[key.Key256, multiaddr.Multiaddr]); !ok {
+			return ErrRequireProtoKadResponse
+		}
+		if _, ok := req.(ProtoKadMessage); !ok {
+			return ErrRequireProtoKadMessage
+		}
 	}
 
 	p, ok := n.(*PeerID)
 	if !ok {
-		span.RecordError(ErrRequirePeerID)
 		return ErrRequirePeerID
 	}
 
-	if len(e.host.Peerstore().Addrs(p.ID)) == 0 {
-		span.RecordError(endpoint.ErrUnknownPeer)
+	// an existing connection can be reused even if the peerstore holds no address for the peer
+	if e.host.Network().Connectedness(p.ID) != network.Connected &&
+		len(e.host.Peerstore().Addrs(p.ID)) == 0 {
 		return endpoint.ErrUnknownPeer
 	}
 
-	if responseHandlerFn == nil {
-		span.RecordError(endpoint.ErrNilResponseHandler)
+	if handleResp == nil {
 		return endpoint.ErrNilResponseHandler
 	}
+	track := endpoint.Track[key.Key256, multiaddr.Multiaddr](e.metrics, n.String(), nil)
+	handleNegotiated := handleResp
+	handleResp = func(ctx context.Context, protoID address.ProtocolID, resp kad.Response[key.Key256, multiaddr.Multiaddr], err error) {
+		track(ctx, resp, err)
+		handleNegotiated(ctx, protoID, resp, err)
+	}
 
 	go func() {
-		ctx, span := util.StartSpan(e.ctx,
-			"Libp2pEndpoint.SendRequestHandleResponse libp2p go routine",
-			trace.WithAttributes(
-				attribute.String("PeerID", n.String()),
-			))
+		ctx, span := util.StartMessageSpan(e.ctx, "Libp2pEndpoint.sendRequest libp2p go routine", func() []attribute.KeyValue {
+			return []attribute.KeyValue{attribute.String("PeerID", n.String())}
+		})
 		defer span.End()
 		var cancel context.CancelFunc
 		if timeout > 0 {
@@ -205,27 +481,52 @@ func (e *Libp2pEndpoint) SendRequestHandleResponse(ctx context.Context,
 		}
 		defer cancel()
 
-		var err error
-
-		var s network.Stream
-		s, err = e.host.NewStream(ctx, p.ID, protocol.ID(protoID))
+		ps, reused, err := e.openStream(ctx, p.ID, pids...)
 		if err != nil {
 			span.RecordError(err, trace.WithAttributes(attribute.String("where", "stream creation")))
+			e.log.Debug("request failed", logging.Stringer("peer", n), logging.String("where", "stream creation"), logging.Error(err))
 			e.sched.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
-				responseHandlerFn(ctx, nil, err)
+				handleResp(ctx, "", nil, err)
 			}))
 			return
 		}
-		defer s.Close()
-
-		err = WriteMsg(s, protoReq)
+		protoID := address.ProtocolID(ps.s.Protocol())
+		c := e.codecs.Get(protoID)
+
+		size, err := writeMsg(ps.w, c, req, e.cfg.Limits.MaxRequestSize)
+		if errors.Is(err, endpoint.ErrMessageTooLarge) {
+			// nothing was written, the stream may still be reused
+			e.counters.CountOversizedRequest()
+			e.releaseStream(ps)
+			span.RecordError(err, trace.WithAttributes(attribute.String("where", "write message")))
+			e.log.Debug("request failed", logging.Stringer("peer", n), logging.String("protocol", string(protoID)), logging.String("where", "write message"), logging.Error(err))
+			e.sched.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
+				handleResp(ctx, protoID, nil, err)
+			}))
+			return
+		}
+		if err != nil && reused {
+			// the remote peer may have closed the idle stream, retry once on a new stream
+			ps.s.Reset()
+			ps, _, err = e.openStream(ctx, p.ID, pids...)
+			if err == nil {
+				protoID = address.ProtocolID(ps.s.Protocol())
+				c = e.codecs.Get(protoID)
+				size, err = writeMsg(ps.w, c, req, e.cfg.Limits.MaxRequestSize)
+			}
+		}
 		if err != nil {
+			if ps != nil {
+				ps.s.Reset()
+			}
 			span.RecordError(err, trace.WithAttributes(attribute.String("where", "write message")))
+			e.log.Debug("request failed", logging.Stringer("peer", n), logging.String("protocol", string(protoID)), logging.String("where", "write message"), logging.Error(err))
 			e.sched.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
-				responseHandlerFn(ctx, nil, err)
+				handleResp(ctx, protoID, nil, err)
 			}))
 			return
 		}
+		e.traffic.Record(ctx, protoID, endpoint.Outbound, req, size)
 
 		var timeoutEvent event.PlannedAction
 		// handle timeout
@@ -234,54 +535,93 @@ func (e *Libp2pEndpoint) SendRequestHandleResponse(ctx context.Context,
 			timeoutEvent = event.ScheduleActionIn(ctx, e.sched, timeout,
 				event.BasicAction(func(ctx context.Context) {
 					cancel()
-					responseHandlerFn(ctx, nil, endpoint.ErrTimeout)
+					handleResp(ctx, protoID, nil, endpoint.ErrTimeout)
 				}))
 		}
 
-		err = ReadMsg(s, protoResp)
+		msg, size, err := readMsg(ps.r, c, resp)
 		if timeout != 0 {
 			// remove timeout if not too late
 			if !e.sched.RemovePlannedAction(ctx, timeoutEvent) {
 				span.RecordError(endpoint.ErrResponseReceivedAfterTimeout)
-				// don't run responseHandlerFn if timeout was already triggered
+				ps.s.Reset()
+				// don't run handleResp if timeout was already triggered
 				return
 			}
 		}
 		if err != nil {
+			e.countReadError(err, e.counters.CountOversizedResponse)
+			ps.s.Reset()
 			span.RecordError(err, trace.WithAttributes(attribute.String("where", "read message")))
+			e.log.Debug("request failed", logging.Stringer("peer", n), logging.String("protocol", string(protoID)), logging.String("where", "read message"), logging.Error(err))
+			e.sched.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
+				handleResp(ctx, protoID, nil, err)
+			}))
+			return
+		}
+		e.traffic.Record(ctx, protoID, endpoint.Inbound, msg, size)
+
+		protoResp, ok := msg.(kad.Response[key.Key256, multiaddr.Multiaddr])
+		if !ok {
+			e.counters.CountMalformed()
+			ps.s.Reset()
+			span.RecordError(ErrRequireKadResponse)
+			e.sched.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
+				handleResp(ctx, protoID, nil, ErrRequireKadResponse)
+			}))
+			return
+		}
+
+		if err := endpoint.CheckCloserNodes(e.cfg.Limits, protoResp); err != nil {
+			// the response was read entirely, the stream may still be reused
+			e.counters.CountTooManyCloserNodes()
+			e.releaseStream(ps)
+			span.RecordError(err)
 			e.sched.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
-				responseHandlerFn(ctx, protoResp, err)
+				handleResp(ctx, protoID, nil, err)
 			}))
 			return
 		}
 
-		span.AddEvent("response received")
+		span.AddEvent("response received", trace.WithAttributes(attribute.String("protocol", string(protoID))))
+		e.log.Debug("response received", logging.Stringer("peer", n), logging.String("protocol", string(protoID)), logging.String("type", endpoint.MessageType(protoResp)))
+		e.releaseStream(ps)
 		e.sched.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
-			responseHandlerFn(ctx, protoResp, err)
+			handleResp(ctx, protoID, protoResp, nil)
 		}))
 	}()
 	return nil
 }
 
 func (e *Libp2pEndpoint) Connectedness(id kad.NodeID[key.Key256]) (endpoint.Connectedness, error) {
-	p, err := getPeerID(id)
-	if err != nil {
+	if _, err := getPeerID(id); err != nil {
 		return endpoint.NotConnected, err
 	}
+	return e.peerstore.Connectedness(id), nil
+}
 
-	c := e.host.Network().Connectedness(p.ID)
-	switch c {
-	case network.NotConnected:
-		return endpoint.NotConnected, nil
-	case network.Connected:
-		return endpoint.Connected, nil
-	case network.CanConnect:
-		return endpoint.CanConnect, nil
-	case network.CannotConnect:
-		return endpoint.CannotConnect, nil
-	default:
-		panic(fmt.Sprintf("unexpected libp2p connectedness value: %v", c))
+// PeerSupportsProtocol reports whether the peer id advertised protoID through identify. Peers that
+// advertised no protocols yet, such as peers the host did not connect to, are assumed to support it.
+func (e *Libp2pEndpoint) PeerSupportsProtocol(id kad.NodeID[key.Key256], protoID address.ProtocolID) bool {
+	p, err := getPeerID(id)
+	if err != nil {
+		return false
 	}
+	protos, err := e.host.Peerstore().GetProtocols(p.ID)
+	if err != nil || len(protos) == 0 {
+		return true
+	}
+	for _, proto := range protos {
+		if proto == protocol.ID(protoID) {
+			return true
+		}
+	}
+	return false
+}
+
+// Peerstore returns the address book of the endpoint, backed by the peerstore of its host.
+func (e *Libp2pEndpoint) Peerstore() *Peerstore {
+	return e.peerstore
 }
 
 func (e *Libp2pEndpoint) PeerInfo(id kad.NodeID[key.Key256]) (peer.AddrInfo, error) {
@@ -304,66 +644,50 @@ func (e *Libp2pEndpoint) NetworkAddress(n kad.NodeID[key.Key256]) (kad.NodeInfo[
 	return NewAddrInfo(ai), nil
 }
 
+// MessageStats returns the numbers of messages the endpoint rejected because of its limits.
+func (e *Libp2pEndpoint) MessageStats() endpoint.MessageStats {
+	return e.counters.Stats()
+}
+
+// countReadError counts a message that could not be read because it was malformed, or with
+// countOversized if it exceeded its maximum size.
+func (e *Libp2pEndpoint) countReadError(err error, countOversized func()) {
+	switch {
+	case errors.Is(err, endpoint.ErrMessageTooLarge):
+		countOversized()
+	case errors.Is(err, endpoint.ErrMalformedMessage):
+		e.counters.CountMalformed()
+	}
+}
+
+// SetCodec sets the codec used to encode and decode the messages of a protocol. Messages
+// are encoded with protobuf unless another codec is set. A nil codec restores protobuf.
+// The codec must be set before adding a request handler for the protocol.
+func (e *Libp2pEndpoint) SetCodec(protoID address.ProtocolID, c codec.Codec) {
+	e.codecs.Set(protoID, c)
+}
+
+// isProtobuf reports whether c is the protobuf codec, which requires protobuf messages.
+func isProtobuf(c codec.Codec) bool {
+	_, ok := c.(codec.Protobuf)
+	return ok
+}
+
 func (e *Libp2pEndpoint) AddRequestHandler(protoID address.ProtocolID,
 	req kad.Message, reqHandler endpoint.RequestHandlerFn[key.Key256],
 ) error {
-	protoReq, ok := req.(ProtoKadMessage)
-	if !ok {
-		return ErrRequireProtoKadMessage
+	if isProtobuf(e.codecs.Get(protoID)) {
+		if _, ok := req.(ProtoKadMessage); !ok {
+			return ErrRequireProtoKadMessage
+		}
 	}
 	if reqHandler == nil {
 		return endpoint.ErrNilRequestHandler
 	}
-	// when a new request comes in, we need to queue it
+	// each inbound stream is read on its own goroutine, every request read is queued on the
+	// scheduler to be handled and the response is written back once the handler has run
 	streamHandler := func(s network.Stream) {
-		e.sched.EnqueueAction(e.ctx, event.BasicAction(func(ctx context.Context) {
-			ctx, span := util.StartSpan(ctx, "Libp2pEndpoint.AddRequestHandler",
-				trace.WithAttributes(
-					attribute.String("PeerID", s.Conn().RemotePeer().String()),
-				))
-			defer span.End()
-			defer s.Close()
-
-			// create a protobuf reader and writer
-			r := pbio.NewDelimitedReader(s, network.MessageSizeMax)
-			w := pbio.NewDelimitedWriter(s)
-
-			for {
-				// read a message from the stream
-				err := r.ReadMsg(protoReq)
-				if err != nil {
-					if err == io.EOF {
-						// stream EOF, all done
-						return
-					}
-					span.RecordError(err)
-					return
-				}
-
-				requester := NewAddrInfo(
-					e.host.Peerstore().PeerInfo(s.Conn().RemotePeer()),
-				)
-				resp, err := reqHandler(ctx, requester, req)
-				if err != nil {
-					span.RecordError(err)
-					return
-				}
-
-				protoResp, ok := resp.(ProtoKadMessage)
-				if !ok {
-					err = errors.New("Libp2pEndpoint requires ProtoKadMessage")
-					span.RecordError(err)
-					return
-				}
-
-				// write the response to the stream
-				err = w.WriteMsg(protoResp)
-				if err != nil {
-					span.RecordError(err)
-					return
-				}
-			}
-		}))
+		go e.handleStream(s, protoID, req, reqHandler)
 	}
 	e.host.SetStreamHandler(protocol.ID(protoID), streamHandler)
 	return nil
@@ -372,3 +696,99 @@ func (e *Libp2pEndpoint) AddRequestHandler(protoID address.ProtocolID,
 func (e *Libp2pEndpoint) RemoveRequestHandler(protoID address.ProtocolID) {
 	e.host.RemoveStreamHandler(protocol.ID(protoID))
 }
+
+// handleStream reads requests from an inbound stream until it is closed, handling each request
+// on the scheduler and writing the response back to the stream.
+func (e *Libp2pEndpoint) handleStream(s network.Stream, protoID address.ProtocolID, protoReq kad.Message,
+	reqHandler endpoint.RequestHandlerFn[key.Key256],
+) {
+	ctx, span := util.StartSpan(e.ctx, "Libp2pEndpoint.handleStream",
+		trace.WithAttributes(
+			attribute.String("PeerID", s.Conn().RemotePeer().String()),
+		))
+	defer span.End()
+
+	remote := s.Conn().RemotePeer()
+	if e.cfg.PeerstoreTTL > 0 {
+		// remember how to reach the requester so that it can be returned as a closer node
+		e.host.Peerstore().AddAddr(remote, s.Conn().RemoteMultiaddr(), e.cfg.PeerstoreTTL)
+	}
+
+	// create a length delimited reader and writer
+	r := msgio.NewVarintReaderSize(s, e.cfg.Limits.MaxRequestSize)
+	w := msgio.NewVarintWriter(s)
+
+	type result struct {
+		resp kad.Message
+		err  error
+	}
+
+	for {
+		// the codec may be changed with SetCodec while the stream is open
+		c := e.codecs.Get(protoID)
+
+		// read a message from the stream into a new message so that handlers may keep it
+		req, size, err := readMsg(r, c, protoReq)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				// stream EOF, all done
+				s.Close()
+				return
+			}
+			e.countReadError(err, e.counters.CountOversizedRequest)
+			span.RecordError(err)
+			e.log.Debug("inbound stream failed", logging.Stringer("peer", remote), logging.String("protocol", string(protoID)), logging.Error(err))
+			s.Reset()
+			return
+		}
+
+		e.traffic.Record(ctx, protoID, endpoint.Inbound, req, size)
+		e.log.Debug("request received", logging.Stringer("peer", remote), logging.String("protocol", string(protoID)), logging.String("type", endpoint.MessageType(req)))
+
+		if e.cfg.RateLimiter != nil && !e.cfg.RateLimiter.Allow(remote.String()) {
+			// the IPFS DHT protocol has no throttled response, so the requester sees a reset stream
+			span.RecordError(endpoint.ErrThrottled)
+			e.log.Info("request throttled", logging.Stringer("peer", remote), logging.String("protocol", string(protoID)))
+			s.Reset()
+			return
+		}
+
+		done := make(chan result, 1)
+		e.sched.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
+			requester := NewAddrInfo(e.host.Peerstore().PeerInfo(remote))
+			resp, err := reqHandler(ctx, requester, req)
+			done <- result{resp: resp, err: err}
+		}))
+
+		var res result
+		select {
+		case res = <-done:
+		case <-ctx.Done():
+			s.Reset()
+			return
+		}
+		if res.err != nil {
+			span.RecordError(res.err)
+			e.log.Debug("request handler failed", logging.Stringer("peer", remote), logging.String("protocol", string(protoID)), logging.Error(res.err))
+			s.Reset()
+			return
+		}
+
+		if res.resp == nil {
+			// the request has no response, such as ADD_PROVIDER
+			continue
+		}
+
+		// write the response to the stream
+		size, err = writeMsg(w, c, res.resp, e.cfg.Limits.MaxResponseSize)
+		if err != nil {
+			if errors.Is(err, endpoint.ErrMessageTooLarge) {
+				e.counters.CountOversizedResponse()
+			}
+			span.RecordError(err)
+			s.Reset()
+			return
+		}
+		e.traffic.Record(ctx, protoID, endpoint.Outbound, res.resp, size)
+	}
+}
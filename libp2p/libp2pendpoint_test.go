@@ -10,15 +10,18 @@ import (
 	"github.com/benbjohnson/clock"
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/p2p/net/swarm"
 	ma "github.com/multiformats/go-multiaddr"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/plprobelab/go-kademlia/event"
-	"github.com/plprobelab/go-kademlia/internal/kadtest"
 	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
 	"github.com/plprobelab/go-kademlia/key"
 	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/codec"
 	"github.com/plprobelab/go-kademlia/network/endpoint"
 	"github.com/plprobelab/go-kademlia/sim"
 )
@@ -372,7 +375,8 @@ func TestReqUnknownPeer(t *testing.T) {
 	wg := sync.WaitGroup{}
 	responseHandler := func(_ context.Context, _ kad.Response[key.Key256, ma.Multiaddr], err error) {
 		wg.Done()
-		require.Equal(t, swarm.ErrNoGoodAddresses, err)
+		require.ErrorIs(t, err, swarm.ErrNoGoodAddresses)
+		require.ErrorIs(t, err, endpoint.ErrDialFailure)
 	}
 
 	// unknown valid peerid (address not stored in peerstore)
@@ -547,3 +551,529 @@ func TestReqHandlerReturnsWrongType(t *testing.T) {
 		require.Equal(t, event.MaxTime, s.NextActionTime(ctx))
 	}
 }
+
+func TestEndpointConfigValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		cfg := DefaultEndpointConfig()
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("limits valid", func(t *testing.T) {
+		cfg := DefaultEndpointConfig()
+		cfg.Limits.MaxRequestSize = 0
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("dial backoff not negative", func(t *testing.T) {
+		cfg := DefaultEndpointConfig()
+		cfg.DialBackoffBase = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("dial backoff max not less than base", func(t *testing.T) {
+		cfg := DefaultEndpointConfig()
+		cfg.DialBackoffMax = cfg.DialBackoffBase - 1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("max dials positive", func(t *testing.T) {
+		cfg := DefaultEndpointConfig()
+		cfg.MaxDials = 0
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("max idle streams not negative", func(t *testing.T) {
+		cfg := DefaultEndpointConfig()
+		cfg.MaxIdleStreams = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("peerstore ttl not negative", func(t *testing.T) {
+		cfg := DefaultEndpointConfig()
+		cfg.PeerstoreTTL = -1
+		require.Error(t, cfg.Validate())
+	})
+}
+
+func TestStreamReuse(t *testing.T) {
+	ctx := context.Background()
+
+	endpoints, addrs, ids, scheds := createEndpoints(t, ctx, 2)
+	connectEndpoints(t, ctx, endpoints, addrs)
+
+	requestHandler := func(ctx context.Context, id kad.NodeID[key.Key256],
+		req kad.Message,
+	) (kad.Message, error) {
+		return req, nil
+	}
+	err := endpoints[1].AddRequestHandler(protoID, &Message{}, requestHandler)
+	require.NoError(t, err)
+
+	sendRequest := func() {
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		responseHandler := func(ctx context.Context,
+			resp kad.Response[key.Key256, ma.Multiaddr], err error,
+		) {
+			require.NoError(t, err)
+			wg.Done()
+		}
+		err := endpoints[0].SendRequestHandleResponse(ctx, protoID, ids[1],
+			FindPeerRequest(ids[1]), &Message{}, time.Second, responseHandler)
+		require.NoError(t, err)
+
+		wg.Add(2)
+		for _, s := range scheds {
+			go func(s event.AwareScheduler) {
+				for !s.RunOne(ctx) {
+					time.Sleep(time.Millisecond)
+				}
+				wg.Done()
+			}(s)
+		}
+		wg.Wait()
+	}
+
+	sendRequest()
+	// the stream used for the first request is kept for reuse
+	ps := endpoints[0].streams.Get(ids[1].ID, protocol.ID(protoID))
+	require.NotNil(t, ps)
+	endpoints[0].streams.Put(ps)
+
+	// the second request is sent over the same stream
+	sendRequest()
+	ps2 := endpoints[0].streams.Get(ids[1].ID, protocol.ID(protoID))
+	require.NotNil(t, ps2)
+	require.Equal(t, ps.s.ID(), ps2.s.ID())
+	require.Nil(t, endpoints[0].streams.Get(ids[1].ID, protocol.ID(protoID)))
+}
+
+func TestDialBackoffError(t *testing.T) {
+	ctx := context.Background()
+
+	endpoints, addrs, ids, _ := createEndpoints(t, ctx, 2)
+	// replace address of peer 1 with an invalid address
+	addrs[1] = NewAddrInfo(peer.AddrInfo{
+		ID:    addrs[1].PeerID().ID,
+		Addrs: []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/1")},
+	})
+	connectEndpoints(t, ctx, endpoints, addrs)
+
+	dialCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	require.Error(t, endpoints[0].DialPeer(dialCtx, ids[1]))
+
+	// peer 1 is not dialled again until the backoff has elapsed
+	require.Equal(t, ErrDialBackoff, endpoints[0].DialPeer(ctx, ids[1]))
+
+	stats := endpoints[0].DialStats()
+	require.Equal(t, int64(1), stats.Failed)
+	require.Equal(t, int64(1), stats.BackedOff)
+}
+
+type jsonMessage struct {
+	Text string
+}
+
+func (m *jsonMessage) CloserNodes() []kad.NodeInfo[key.Key256, ma.Multiaddr] {
+	return nil
+}
+
+func TestSetCodec(t *testing.T) {
+	ctx := context.Background()
+
+	endpoints, addrs, ids, scheds := createEndpoints(t, ctx, 2)
+	connectEndpoints(t, ctx, endpoints, addrs)
+
+	jsonProtoID := address.ProtocolID("/test/json/1.0.0")
+	for _, e := range endpoints {
+		e.SetCodec(jsonProtoID, codec.JSON{})
+	}
+
+	requestHandler := func(ctx context.Context, id kad.NodeID[key.Key256],
+		req kad.Message,
+	) (kad.Message, error) {
+		return &jsonMessage{Text: req.(*jsonMessage).Text + " world"}, nil
+	}
+	// non protobuf messages are accepted for the protocol
+	err := endpoints[1].AddRequestHandler(jsonProtoID, &jsonMessage{}, requestHandler)
+	require.NoError(t, err)
+
+	wg := sync.WaitGroup{}
+	var got kad.Response[key.Key256, ma.Multiaddr]
+	responseHandler := func(ctx context.Context,
+		resp kad.Response[key.Key256, ma.Multiaddr], err error,
+	) {
+		require.NoError(t, err)
+		got = resp
+		wg.Done()
+	}
+	wg.Add(1)
+	err = endpoints[0].SendRequestHandleResponse(ctx, jsonProtoID, ids[1],
+		&jsonMessage{Text: "hello"}, &jsonMessage{}, time.Second, responseHandler)
+	require.NoError(t, err)
+
+	wg.Add(2)
+	for _, s := range scheds {
+		go func(s event.AwareScheduler) {
+			for !s.RunOne(ctx) {
+				time.Sleep(time.Millisecond)
+			}
+			wg.Done()
+		}(s)
+	}
+	wg.Wait()
+	require.Equal(t, &jsonMessage{Text: "hello world"}, got)
+
+	// other protocols still require protobuf messages
+	err = endpoints[1].AddRequestHandler(protoID, &jsonMessage{}, requestHandler)
+	require.Equal(t, ErrRequireProtoKadMessage, err)
+}
+
+func TestRateLimit(t *testing.T) {
+	ctx := context.Background()
+
+	endpoints, addrs, ids, scheds := createEndpoints(t, ctx, 2)
+	connectEndpoints(t, ctx, endpoints, addrs)
+
+	cfg := endpoint.DefaultRateLimitConfig()
+	cfg.PeerRate = 0.001
+	cfg.PeerBurst = 1
+	limiter, err := endpoint.NewRateLimiter(cfg)
+	require.NoError(t, err)
+	endpoints[1].cfg.RateLimiter = limiter
+
+	requestHandler := func(ctx context.Context, id kad.NodeID[key.Key256],
+		req kad.Message,
+	) (kad.Message, error) {
+		return req, nil
+	}
+	err = endpoints[1].AddRequestHandler(protoID, &Message{}, requestHandler)
+	require.NoError(t, err)
+
+	// sendRequest sends a request to endpoints[1] and returns the error passed to the
+	// response handler. The server scheduler is only run when the request is handled.
+	sendRequest := func(handled bool) error {
+		var respErr error
+		done := make(chan struct{})
+		responseHandler := func(ctx context.Context,
+			resp kad.Response[key.Key256, ma.Multiaddr], err error,
+		) {
+			respErr = err
+			close(done)
+		}
+		err := endpoints[0].SendRequestHandleResponse(ctx, protoID, ids[1],
+			FindPeerRequest(ids[1]), &Message{}, time.Second, responseHandler)
+		require.NoError(t, err)
+
+		if handled {
+			for !scheds[1].RunOne(ctx) {
+				time.Sleep(time.Millisecond)
+			}
+		}
+		for !scheds[0].RunOne(ctx) {
+			time.Sleep(time.Millisecond)
+		}
+		<-done
+		return respErr
+	}
+
+	require.NoError(t, sendRequest(true))
+
+	// the second request exceeds the rate limit and its stream is reset
+	require.ErrorIs(t, sendRequest(false), endpoint.ErrPeerReset)
+	require.False(t, scheds[1].RunOne(ctx))
+}
+
+func TestMessageLimits(t *testing.T) {
+	ctx := context.Background()
+
+	endpoints, addrs, ids, scheds := createEndpoints(t, ctx, 2)
+	connectEndpoints(t, ctx, endpoints, addrs)
+
+	// the response carries two closer nodes
+	requestHandler := func(ctx context.Context, id kad.NodeID[key.Key256],
+		req kad.Message,
+	) (kad.Message, error) {
+		return &Message{
+			Type:        Message_FIND_NODE,
+			CloserPeers: []*Message_Peer{AddrInfoToPbPeer(addrs[0]), AddrInfoToPbPeer(addrs[1])},
+		}, nil
+	}
+	err := endpoints[1].AddRequestHandler(protoID, &Message{}, requestHandler)
+	require.NoError(t, err)
+
+	// sendRequest sends a request to endpoints[1] and returns the error passed to the
+	// response handler. The server scheduler is only run when the request is handled.
+	sendRequest := func(handled bool) error {
+		var respErr error
+		done := make(chan struct{})
+		responseHandler := func(ctx context.Context,
+			resp kad.Response[key.Key256, ma.Multiaddr], err error,
+		) {
+			respErr = err
+			close(done)
+		}
+		err := endpoints[0].SendRequestHandleResponse(ctx, protoID, ids[1],
+			FindPeerRequest(ids[1]), &Message{}, time.Second, responseHandler)
+		require.NoError(t, err)
+
+		if handled {
+			for !scheds[1].RunOne(ctx) {
+				time.Sleep(time.Millisecond)
+			}
+		}
+		for !scheds[0].RunOne(ctx) {
+			time.Sleep(time.Millisecond)
+		}
+		<-done
+		return respErr
+	}
+
+	// the response exceeds the size limit, its stream is reset
+	endpoints[0].cfg.Limits.MaxResponseSize = 4
+	require.ErrorIs(t, sendRequest(true), endpoint.ErrMessageTooLarge)
+	require.Equal(t, int64(1), endpoints[0].MessageStats().OversizedResponses)
+
+	endpoints[0].cfg.Limits = DefaultEndpointConfig().Limits
+	require.NoError(t, sendRequest(true))
+
+	endpoints[0].cfg.Limits.MaxCloserNodes = 1
+	require.ErrorIs(t, sendRequest(true), endpoint.ErrTooManyCloserNodes)
+	require.Equal(t, int64(1), endpoints[0].MessageStats().TooManyCloserNodes)
+
+	endpoints[0].cfg.Limits.MaxRequestSize = 1
+	require.ErrorIs(t, sendRequest(false), endpoint.ErrMessageTooLarge)
+	require.Equal(t, int64(1), endpoints[0].MessageStats().OversizedRequests)
+
+	require.Equal(t, endpoint.MessageStats{}, endpoints[1].MessageStats())
+}
+
+func TestSubscribeConnEvents(t *testing.T) {
+	ctx := context.Background()
+
+	endpoints, addrs, ids, _ := createEndpoints(t, ctx, 3)
+	// replace address of peer 2 with an invalid address
+	addrs[2] = NewAddrInfo(peer.AddrInfo{
+		ID:    addrs[2].PeerID().ID,
+		Addrs: []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/1")},
+	})
+	connectEndpoints(t, ctx, endpoints, addrs)
+
+	sub := endpoints[0].SubscribeConnEvents(10)
+	defer sub.Close()
+
+	nextEvent := func() endpoint.ConnEvent[key.Key256] {
+		select {
+		case ev := <-sub.Events():
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("no connection event")
+			return nil
+		}
+	}
+
+	require.NoError(t, endpoints[0].DialPeer(ctx, ids[1]))
+	ev := nextEvent()
+	require.IsType(t, &endpoint.EventConnected[key.Key256]{}, ev)
+	require.Equal(t, ids[1].String(), ev.Peer().String())
+
+	require.NoError(t, endpoints[0].host.Network().ClosePeer(ids[1].ID))
+	ev = nextEvent()
+	require.IsType(t, &endpoint.EventDisconnected[key.Key256]{}, ev)
+	require.Equal(t, ids[1].String(), ev.Peer().String())
+
+	dialCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	require.Error(t, endpoints[0].DialPeer(dialCtx, ids[2]))
+	ev = nextEvent()
+	require.IsType(t, &endpoint.EventDialFailed[key.Key256]{}, ev)
+	require.Equal(t, ids[2].String(), ev.Peer().String())
+	require.Error(t, ev.(*endpoint.EventDialFailed[key.Key256]).Error)
+}
+
+func TestSendRequestNegotiate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	endpoints, addrs, ids, scheds := createEndpoints(t, ctx, 2)
+	connectEndpoints(t, ctx, endpoints, addrs)
+
+	v2 := address.ProtocolID("/test/2.0.0")
+	// the server only supports the older protocol
+	err := endpoints[1].AddRequestHandler(protoID, &Message{}, func(ctx context.Context,
+		id kad.NodeID[key.Key256], req kad.Message,
+	) (kad.Message, error) {
+		return req, nil
+	})
+	require.NoError(t, err)
+	go func() {
+		for ctx.Err() == nil {
+			if !scheds[1].RunOne(ctx) {
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+
+	type result struct {
+		protoID address.ProtocolID
+		err     error
+	}
+	send := func(protoIDs ...address.ProtocolID) result {
+		results := make(chan result, 1)
+		err := endpoints[0].SendRequestNegotiate(ctx, protoIDs, ids[1], FindPeerRequest(ids[1]),
+			&Message{}, time.Second, func(ctx context.Context, protoID address.ProtocolID,
+				resp kad.Response[key.Key256, ma.Multiaddr], err error,
+			) {
+				results <- result{protoID, err}
+			})
+		require.NoError(t, err)
+		for {
+			select {
+			case res := <-results:
+				return res
+			default:
+				if !scheds[0].RunOne(ctx) {
+					time.Sleep(time.Millisecond)
+				}
+			}
+		}
+	}
+
+	res := send(v2, protoID)
+	require.NoError(t, res.err)
+	require.Equal(t, protoID, res.protoID)
+
+	res = send(v2)
+	require.ErrorIs(t, res.err, endpoint.ErrProtocolNotSupported)
+	require.Equal(t, address.ProtocolID(""), res.protoID)
+
+	// both requests were recorded in the metrics of the peer
+	m, ok := endpoints[0].Metrics(ids[1])
+	require.True(t, ok)
+	require.Equal(t, 1, m.Successes)
+	require.Equal(t, 1, m.Failures)
+
+	err = endpoints[0].SendRequestNegotiate(ctx, nil, ids[1], FindPeerRequest(ids[1]),
+		&Message{}, time.Second, func(context.Context, address.ProtocolID,
+			kad.Response[key.Key256, ma.Multiaddr], error,
+		) {
+		})
+	require.ErrorIs(t, err, endpoint.ErrProtocolNotSupported)
+}
+
+func TestTrafficMetrics(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.New()
+
+	mps := make([]*kadtest.MeterProvider, 2)
+	endpoints := make([]*Libp2pEndpoint, 2)
+	addrs := make([]*AddrInfo, 2)
+	scheds := make([]event.AwareScheduler, 2)
+	for i := range endpoints {
+		host, err := libp2p.New()
+		require.NoError(t, err)
+		scheds[i] = event.NewSimpleScheduler(clk)
+		mps[i] = kadtest.NewMeterProvider()
+		cfg := DefaultEndpointConfig()
+		cfg.MeterProvider = mps[i]
+		endpoints[i], err = NewLibp2pEndpointWithConfig(ctx, host, scheds[i], cfg)
+		require.NoError(t, err)
+		addrs[i] = NewAddrInfo(peer.AddrInfo{ID: host.ID(), Addrs: host.Addrs()})
+	}
+	connectEndpoints(t, ctx, endpoints, addrs)
+
+	err := endpoints[1].AddRequestHandler(ProtocolIPFSDHT, &Message{}, func(ctx context.Context, id kad.NodeID[key.Key256], req kad.Message) (kad.Message, error) {
+		return PingResponse(), nil
+	})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	err = endpoints[0].SendRequestHandleResponse(ctx, ProtocolIPFSDHT, addrs[1].PeerID(), PingRequest(), &Message{}, time.Second,
+		func(ctx context.Context, resp kad.Response[key.Key256, ma.Multiaddr], err error) {
+			require.NoError(t, err)
+			wg.Done()
+		})
+	require.NoError(t, err)
+	wg.Add(2)
+	for _, s := range scheds {
+		go func(s event.AwareScheduler) {
+			for !s.RunOne(ctx) {
+				time.Sleep(time.Millisecond)
+			}
+			wg.Done()
+		}(s)
+	}
+	wg.Wait()
+
+	ping := attribute.String("type", "PING")
+	out := attribute.String("direction", "out")
+	in := attribute.String("direction", "in")
+	require.Equal(t, 1.0, mps[0].Sum("endpoint.messages", ping, out))
+	require.Equal(t, 1.0, mps[0].Sum("endpoint.messages", ping, in))
+	require.Equal(t, 1.0, mps[1].Sum("endpoint.messages", ping, in))
+	require.Eventually(t, func() bool {
+		return mps[1].Sum("endpoint.messages", ping, out) == 1
+	}, time.Second, time.Millisecond)
+
+	// a PING message is encoded in two bytes
+	require.Equal(t, 2.0, mps[0].Sum("endpoint.bytes", out, attribute.String("protocol", string(ProtocolIPFSDHT))))
+}
+
+func TestEndpointLogger(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.New()
+
+	loggers := make([]*kadtest.Logger, 2)
+	endpoints := make([]*Libp2pEndpoint, 2)
+	addrs := make([]*AddrInfo, 2)
+	scheds := make([]event.AwareScheduler, 2)
+	for i := range endpoints {
+		host, err := libp2p.New()
+		require.NoError(t, err)
+		scheds[i] = event.NewSimpleScheduler(clk)
+		loggers[i] = kadtest.NewLogger()
+		cfg := DefaultEndpointConfig()
+		cfg.Logger = loggers[i]
+		endpoints[i], err = NewLibp2pEndpointWithConfig(ctx, host, scheds[i], cfg)
+		require.NoError(t, err)
+		addrs[i] = NewAddrInfo(peer.AddrInfo{ID: host.ID(), Addrs: host.Addrs()})
+	}
+	connectEndpoints(t, ctx, endpoints, addrs)
+
+	// the server fails to handle the request, resetting the stream
+	err := endpoints[1].AddRequestHandler(ProtocolIPFSDHT, &Message{}, func(ctx context.Context, id kad.NodeID[key.Key256], req kad.Message) (kad.Message, error) {
+		return nil, errors.New("handler failure")
+	})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	err = endpoints[0].SendRequestHandleResponse(ctx, ProtocolIPFSDHT, addrs[1].PeerID(), PingRequest(), &Message{}, time.Second,
+		func(ctx context.Context, resp kad.Response[key.Key256, ma.Multiaddr], err error) {
+			require.Error(t, err)
+			wg.Done()
+		})
+	require.NoError(t, err)
+	wg.Add(2)
+	for _, s := range scheds {
+		go func(s event.AwareScheduler) {
+			for !s.RunOne(ctx) {
+				time.Sleep(time.Millisecond)
+			}
+			wg.Done()
+		}(s)
+	}
+	wg.Wait()
+
+	received := loggers[1].Entries("request received")
+	require.Len(t, received, 1)
+	require.Equal(t, "PING", received[0].Fields["type"])
+	require.Equal(t, addrs[0].PeerID().String(), received[0].Fields["peer"])
+	require.Len(t, loggers[1].Entries("request handler failed"), 1)
+
+	failed := loggers[0].Entries("request failed")
+	require.Len(t, failed, 1)
+	require.Equal(t, "read message", failed[0].Fields["where"])
+}
@@ -2,8 +2,6 @@ package libp2p
 
 import (
 	"github.com/libp2p/go-libp2p/core/peer"
-	mh "github.com/multiformats/go-multihash"
-	mhreg "github.com/multiformats/go-multihash/core"
 
 	"github.com/plprobelab/go-kademlia/kad"
 	"github.com/plprobelab/go-kademlia/key"
@@ -20,9 +18,7 @@ func NewPeerID(p peer.ID) *PeerID {
 }
 
 func (id PeerID) Key() key.Key256 {
-	hasher, _ := mhreg.GetHasher(mh.SHA2_256)
-	hasher.Write([]byte(id.ID))
-	return key.NewKey256(hasher.Sum(nil))
+	return KeyFromPeerID(id.ID)
 }
 
 func (id PeerID) NodeID() kad.NodeID[key.Key256] {
@@ -0,0 +1,133 @@
+package libp2p
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/multiformats/go-multiaddr"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+	"github.com/plprobelab/go-kademlia/network/peerstore"
+)
+
+// Peerstore adapts the peerstore and the network of a libp2p host to the peerstore.Peerstore
+// interface. Addresses expire as managed by the libp2p peerstore, which removes them itself,
+// and connectedness is reported by the libp2p network.
+type Peerstore struct {
+	host host.Host
+}
+
+var _ peerstore.Peerstore[key.Key256, multiaddr.Multiaddr] = (*Peerstore)(nil)
+
+// NewPeerstore returns a Peerstore backed by the peerstore and network of h.
+func NewPeerstore(h host.Host) *Peerstore {
+	return &Peerstore{host: h}
+}
+
+// Add adds the addresses of ni to the libp2p peerstore, where they are kept for ttl. The
+// addresses previously added for the node are kept.
+func (p *Peerstore) Add(ctx context.Context, ni kad.NodeInfo[key.Key256, multiaddr.Multiaddr], ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	pid, err := getPeerID(ni.ID())
+	if err != nil {
+		return err
+	}
+	p.host.Peerstore().AddAddrs(pid.ID, ni.Addresses(), ttl)
+	return nil
+}
+
+// Get returns the addresses of id known by the libp2p peerstore, or peerstore.ErrNotFound if
+// there are none.
+func (p *Peerstore) Get(ctx context.Context, id kad.NodeID[key.Key256]) (kad.NodeInfo[key.Key256, multiaddr.Multiaddr], error) {
+	pid, err := getPeerID(id)
+	if err != nil {
+		return nil, err
+	}
+	ai := p.host.Peerstore().PeerInfo(pid.ID)
+	if len(ai.Addrs) == 0 {
+		return nil, peerstore.ErrNotFound
+	}
+	return NewAddrInfo(ai), nil
+}
+
+// UpdateTTL sets the ttl of the addresses of id in the libp2p peerstore, which removes them if
+// ttl is not positive.
+func (p *Peerstore) UpdateTTL(ctx context.Context, id kad.NodeID[key.Key256], ttl time.Duration) error {
+	pid, err := getPeerID(id)
+	if err != nil {
+		return err
+	}
+	addrs := p.host.Peerstore().Addrs(pid.ID)
+	if len(addrs) == 0 {
+		return peerstore.ErrNotFound
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	p.host.Peerstore().SetAddrs(pid.ID, addrs, ttl)
+	return nil
+}
+
+// RemovePeer removes the addresses and the other data of id from the libp2p peerstore. The
+// connectedness of id is left to the libp2p network, which may still be connected to it.
+func (p *Peerstore) RemovePeer(ctx context.Context, id kad.NodeID[key.Key256]) error {
+	pid, err := getPeerID(id)
+	if err != nil {
+		return err
+	}
+	p.host.Peerstore().ClearAddrs(pid.ID)
+	p.host.Peerstore().RemovePeer(pid.ID)
+	return nil
+}
+
+func (p *Peerstore) Peers(ctx context.Context) ([]kad.NodeInfo[key.Key256, multiaddr.Multiaddr], error) {
+	var nis []kad.NodeInfo[key.Key256, multiaddr.Multiaddr]
+	for _, pid := range p.host.Peerstore().PeersWithAddrs() {
+		ai := p.host.Peerstore().PeerInfo(pid)
+		if len(ai.Addrs) > 0 {
+			nis = append(nis, NewAddrInfo(ai))
+		}
+	}
+	sort.Slice(nis, func(i, j int) bool {
+		return nis[i].ID().String() < nis[j].ID().String()
+	})
+	return nis, nil
+}
+
+// Connectedness returns the connectedness of the host with id reported by the libp2p network.
+func (p *Peerstore) Connectedness(id kad.NodeID[key.Key256]) endpoint.Connectedness {
+	pid, err := getPeerID(id)
+	if err != nil {
+		return endpoint.NotConnected
+	}
+
+	c := p.host.Network().Connectedness(pid.ID)
+	switch c {
+	case network.NotConnected:
+		return endpoint.NotConnected
+	case network.Connected:
+		return endpoint.Connected
+	case network.CanConnect:
+		return endpoint.CanConnect
+	case network.CannotConnect:
+		return endpoint.CannotConnect
+	default:
+		panic(fmt.Sprintf("unexpected libp2p connectedness value: %v", c))
+	}
+}
+
+// SetConnectedness does nothing, the connectedness is tracked by the libp2p network.
+func (p *Peerstore) SetConnectedness(id kad.NodeID[key.Key256], c endpoint.Connectedness) {}
+
+// Sweep does nothing and returns zero, the libp2p peerstore removes expired addresses itself.
+func (p *Peerstore) Sweep(ctx context.Context) (int, error) {
+	return 0, nil
+}
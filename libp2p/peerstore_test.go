@@ -0,0 +1,59 @@
+package libp2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+	"github.com/plprobelab/go-kademlia/network/peerstore"
+)
+
+func TestPeerstore(t *testing.T) {
+	ctx := context.Background()
+	endpoints, addrs, ids, _ := createEndpoints(t, ctx, 2)
+	ps := endpoints[0].Peerstore()
+
+	_, err := ps.Get(ctx, ids[1])
+	require.ErrorIs(t, err, peerstore.ErrNotFound)
+	require.Equal(t, endpoint.NotConnected, ps.Connectedness(ids[1]))
+
+	// a non-positive ttl leaves the peerstore unchanged
+	require.NoError(t, ps.Add(ctx, addrs[1], 0))
+	_, err = ps.Get(ctx, ids[1])
+	require.ErrorIs(t, err, peerstore.ErrNotFound)
+
+	require.NoError(t, ps.Add(ctx, addrs[1], peerstoreTTL))
+	ni, err := ps.Get(ctx, ids[1])
+	require.NoError(t, err)
+	require.ElementsMatch(t, addrs[1].Addrs, ni.Addresses())
+
+	peers, err := ps.Peers(ctx)
+	require.NoError(t, err)
+	var found bool
+	for _, p := range peers {
+		found = found || p.ID().String() == ids[1].String()
+	}
+	require.True(t, found)
+
+	require.NoError(t, ps.UpdateTTL(ctx, ids[1], time.Hour))
+	_, err = ps.Get(ctx, ids[1])
+	require.NoError(t, err)
+
+	require.NoError(t, ps.RemovePeer(ctx, ids[1]))
+	_, err = ps.Get(ctx, ids[1])
+	require.ErrorIs(t, err, peerstore.ErrNotFound)
+	require.ErrorIs(t, ps.UpdateTTL(ctx, ids[1], time.Hour), peerstore.ErrNotFound)
+	require.NoError(t, ps.Add(ctx, addrs[1], peerstoreTTL))
+
+	// node ids that aren't peer ids are rejected
+	invalid := kadtest.NewID(kadtest.NewStringID("invalid").Key())
+	_, err = ps.Get(ctx, invalid)
+	require.ErrorIs(t, err, endpoint.ErrInvalidPeer)
+
+	require.NoError(t, endpoints[0].DialPeer(ctx, ids[1]))
+	require.Equal(t, endpoint.Connected, ps.Connectedness(ids[1]))
+}
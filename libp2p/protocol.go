@@ -0,0 +1,111 @@
+package libp2p
+
+import (
+	"github.com/multiformats/go-multiaddr"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/plprobelab/go-kademlia/dht"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+)
+
+// IPFSProtocol adapts a dht.Node to the messages of the public IPFS DHT. Node ids must be PeerIDs
+// and node infos AddrInfos, as used by Libp2pEndpoint. Write tokens travel in the TokenField of
+// the messages.
+type IPFSProtocol struct{}
+
+var _ dht.TokenProtocol[key.Key256, multiaddr.Multiaddr] = IPFSProtocol{}
+
+var _ dht.PingProtocol[key.Key256, multiaddr.Multiaddr] = IPFSProtocol{}
+
+// ID returns ProtocolIPFSDHT.
+func (IPFSProtocol) ID() address.ProtocolID {
+	return ProtocolIPFSDHT
+}
+
+// FindPeerRequest returns a FIND_NODE request for the peer id, which must be a PeerID.
+func (IPFSProtocol) FindPeerRequest(id kad.NodeID[key.Key256]) (kad.Request[key.Key256, multiaddr.Multiaddr], error) {
+	p, err := getPeerID(id)
+	if err != nil {
+		return nil, err
+	}
+	return FindPeerRequest(p), nil
+}
+
+// PingRequest returns a PING request.
+func (IPFSProtocol) PingRequest() kad.Request[key.Key256, multiaddr.Multiaddr] {
+	return PingRequest()
+}
+
+// FindNodeRequest returns a FIND_NODE request for the peers closest to the target of k.
+func (IPFSProtocol) FindNodeRequest(k []byte) kad.Request[key.Key256, multiaddr.Multiaddr] {
+	return &Message{
+		Type: Message_FIND_NODE,
+		Key:  k,
+	}
+}
+
+// GetValueRequest returns a GET_VALUE request for the record stored under k.
+func (IPFSProtocol) GetValueRequest(k []byte) kad.Request[key.Key256, multiaddr.Multiaddr] {
+	return GetValueRequest(k)
+}
+
+// PutValueRequest returns a PUT_VALUE request storing value under k.
+func (IPFSProtocol) PutValueRequest(k, value []byte) kad.Request[key.Key256, multiaddr.Multiaddr] {
+	return PutValueRequest(k, value)
+}
+
+// GetProvidersRequest returns a GET_PROVIDERS request for the providers of k.
+func (IPFSProtocol) GetProvidersRequest(k []byte) kad.Request[key.Key256, multiaddr.Multiaddr] {
+	return GetProvidersRequest(k)
+}
+
+// AddProviderRequest returns an ADD_PROVIDER request announcing that provider, which must be an
+// AddrInfo, provides k.
+func (IPFSProtocol) AddProviderRequest(k []byte, provider kad.NodeInfo[key.Key256, multiaddr.Multiaddr]) (kad.Request[key.Key256, multiaddr.Multiaddr], error) {
+	ai, ok := provider.(*AddrInfo)
+	if !ok {
+		return nil, ErrNotPeerAddrInfo
+	}
+	return AddProviderRequest(k, ai), nil
+}
+
+// Value returns the value of the record carried by a GET_VALUE response.
+func (IPFSProtocol) Value(resp kad.Response[key.Key256, multiaddr.Multiaddr]) ([]byte, bool) {
+	msg, ok := resp.(*Message)
+	if !ok || msg.GetRecord() == nil {
+		return nil, false
+	}
+	return msg.GetRecord().GetValue(), true
+}
+
+// Providers returns the providers carried by a GET_PROVIDERS response.
+func (IPFSProtocol) Providers(resp kad.Response[key.Key256, multiaddr.Multiaddr]) []kad.NodeInfo[key.Key256, multiaddr.Multiaddr] {
+	msg, ok := resp.(*Message)
+	if !ok {
+		return nil
+	}
+	return msg.ProviderNodes()
+}
+
+// Token returns the write token carried by a response.
+func (IPFSProtocol) Token(resp kad.Response[key.Key256, multiaddr.Multiaddr]) ([]byte, bool) {
+	msg, ok := resp.(*Message)
+	if !ok {
+		return nil, false
+	}
+	tok := msg.GetToken()
+	return tok, len(tok) > 0
+}
+
+// WithToken returns a copy of req carrying tok. Requests that are not Messages are returned as is.
+func (IPFSProtocol) WithToken(req kad.Request[key.Key256, multiaddr.Multiaddr], tok []byte) kad.Request[key.Key256, multiaddr.Multiaddr] {
+	msg, ok := req.(*Message)
+	if !ok {
+		return req
+	}
+	c := proto.Clone(msg).(*Message)
+	c.SetToken(tok)
+	return c
+}
@@ -0,0 +1,243 @@
+package libp2p
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/multiformats/go-multiaddr"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/plprobelab/go-kademlia/dht"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/logging"
+)
+
+// RoutingConfig specifies optional configuration for a Routing
+type RoutingConfig struct {
+	Bootstrapper *dht.Bootstrapper[key.Key256, multiaddr.Multiaddr] // an optional bootstrapper run by Bootstrap
+	Republisher  *dht.Republisher[key.Key256, multiaddr.Multiaddr]  // an optional republisher tracking the values put and the content provided
+	Logger       logging.Logger                                     // an optional logger the failures of the bootstrapper are emitted to, nil logs nothing
+}
+
+// Routing exposes a dht.Node as a go-libp2p routing.Routing, so that libp2p applications can use
+// it in place of go-libp2p-kad-dht. The node is expected to use IPFSProtocol, or another protocol
+// with AddrInfo node infos, and to have been started.
+//
+// Content is provided under the multihash of its CID, as in the public IPFS DHT. The routing
+// options of the value store methods are checked but otherwise ignored, the node has no offline
+// mode.
+type Routing struct {
+	node *dht.Node[key.Key256, multiaddr.Multiaddr]
+	cfg  RoutingConfig
+	log  logging.Logger
+
+	// ctx is cancelled by Close, stopping the bootstrap running in the background
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu            sync.Mutex
+	closed        bool
+	bootstrapping bool
+	bootstraps    sync.WaitGroup
+}
+
+var _ routing.Routing = (*Routing)(nil)
+
+// NewRouting creates a new Routing over n. If cfg is nil, neither a bootstrapper nor a republisher
+// is used.
+func NewRouting(n *dht.Node[key.Key256, multiaddr.Multiaddr], cfg *RoutingConfig) *Routing {
+	if cfg == nil {
+		cfg = &RoutingConfig{}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Routing{
+		node:   n,
+		cfg:    *cfg,
+		log:    logging.OrNop(cfg.Logger),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Close stops the bootstrapper started by Bootstrap, if it is running, and waits for it to return.
+// It does not stop the node.
+func (r *Routing) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	r.cancel()
+	r.bootstraps.Wait()
+	return nil
+}
+
+// Provide records that the node provides the content identified by c in its provider store, if
+// any, and announces it to the network if announce is true.
+func (r *Routing) Provide(ctx context.Context, c cid.Cid, announce bool) error {
+	if !c.Defined() {
+		return errors.New("invalid cid: undefined")
+	}
+	if !announce {
+		return r.node.ProvideLocally(ctx, c.Hash())
+	}
+	if err := r.node.Provide(ctx, c.Hash()); err != nil {
+		return err
+	}
+	if r.cfg.Republisher != nil {
+		r.cfg.Republisher.TrackProvide(c.Hash())
+	}
+	return nil
+}
+
+// FindProvidersAsync streams up to count providers of the content identified by c, or all of
+// them if count is zero. The channel is closed when the lookup ends or ctx is done.
+func (r *Routing) FindProvidersAsync(ctx context.Context, c cid.Cid, count int) <-chan peer.AddrInfo {
+	out := make(chan peer.AddrInfo, count)
+	if !c.Defined() {
+		close(out)
+		return out
+	}
+
+	s := r.node.FindProviders(ctx, c.Hash())
+	go func() {
+		defer close(out)
+		defer s.Cancel()
+
+		found := 0
+		for {
+			info, err := s.Recv(ctx)
+			if err != nil {
+				return
+			}
+			ai, ok := toAddrInfo(info)
+			if !ok {
+				continue
+			}
+			select {
+			case out <- ai:
+			case <-ctx.Done():
+				return
+			}
+			found++
+			if count > 0 && found >= count {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// FindPeer looks up the addresses of the peer p, returning routing.ErrNotFound if no peer knows it.
+func (r *Routing) FindPeer(ctx context.Context, p peer.ID) (peer.AddrInfo, error) {
+	info, err := r.node.FindPeer(ctx, NewPeerID(p))
+	if err != nil {
+		return peer.AddrInfo{}, routingError(err)
+	}
+	ai, ok := toAddrInfo(info)
+	if !ok {
+		return peer.AddrInfo{}, ErrNotPeerAddrInfo
+	}
+	return ai, nil
+}
+
+// PutValue stores value under k in the network. If the Routing has a republisher, the value is
+// tracked until another value is put under k.
+func (r *Routing) PutValue(ctx context.Context, k string, value []byte, opts ...routing.Option) error {
+	var options routing.Options
+	if err := options.Apply(opts...); err != nil {
+		return err
+	}
+	if err := r.node.PutValue(ctx, []byte(k), value); err != nil {
+		return err
+	}
+	if r.cfg.Republisher != nil {
+		r.cfg.Republisher.TrackValue([]byte(k), value, time.Time{})
+	}
+	return nil
+}
+
+// GetValue returns the first value found under k, or routing.ErrNotFound if no peer holds one.
+func (r *Routing) GetValue(ctx context.Context, k string, opts ...routing.Option) ([]byte, error) {
+	var options routing.Options
+	if err := options.Apply(opts...); err != nil {
+		return nil, err
+	}
+	value, err := r.node.GetValue(ctx, []byte(k))
+	if err != nil {
+		return nil, routingError(err)
+	}
+	return value, nil
+}
+
+// SearchValue looks k up in the background and sends the value found, if any, on the returned
+// channel, which is closed once the lookup ended. Values are not compared, so at most one value
+// is sent.
+func (r *Routing) SearchValue(ctx context.Context, k string, opts ...routing.Option) (<-chan []byte, error) {
+	var options routing.Options
+	if err := options.Apply(opts...); err != nil {
+		return nil, err
+	}
+	out := make(chan []byte, 1)
+	go func() {
+		defer close(out)
+		if value, err := r.node.GetValue(ctx, []byte(k)); err == nil {
+			out <- value
+		}
+	}()
+	return out, nil
+}
+
+// Bootstrap runs the bootstrapper of the Routing in the background until the routing table is
+// large enough or the Routing is closed. The bootstrap outlives ctx, which only carries the span
+// it is traced under, and its failure is logged. It does nothing if the Routing has no
+// bootstrapper or if the bootstrapper is already running, and returns ErrRoutingClosed once the
+// Routing is closed.
+func (r *Routing) Bootstrap(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrRoutingClosed
+	}
+	if r.cfg.Bootstrapper == nil || r.bootstrapping {
+		return nil
+	}
+	r.bootstrapping = true
+	r.bootstraps.Add(1)
+	bctx := trace.ContextWithSpan(r.ctx, trace.SpanFromContext(ctx))
+	go func() {
+		defer r.bootstraps.Done()
+		err := r.cfg.Bootstrapper.Run(bctx)
+		if err != nil && r.ctx.Err() == nil {
+			r.log.Info("bootstrap failed", logging.Error(err))
+		}
+		r.mu.Lock()
+		r.bootstrapping = false
+		r.mu.Unlock()
+	}()
+	return nil
+}
+
+// routingError maps the errors of dht.Node to the errors of the routing package.
+func routingError(err error) error {
+	if errors.Is(err, dht.ErrNotFound) {
+		return routing.ErrNotFound
+	}
+	return err
+}
+
+// toAddrInfo returns the peer.AddrInfo of a node, and false if its id is not a PeerID.
+func toAddrInfo(info kad.NodeInfo[key.Key256, multiaddr.Multiaddr]) (peer.AddrInfo, bool) {
+	if ai, ok := info.(*AddrInfo); ok {
+		return ai.AddrInfo, true
+	}
+	p, err := getPeerID(info.ID())
+	if err != nil {
+		return peer.AddrInfo{}, false
+	}
+	return peer.AddrInfo{ID: p.ID, Addrs: info.Addresses()}, true
+}
@@ -0,0 +1,262 @@
+package libp2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/multiformats/go-multiaddr"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/coord"
+	"github.com/plprobelab/go-kademlia/dht"
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/records"
+	"github.com/plprobelab/go-kademlia/routing/simplert"
+	"github.com/plprobelab/go-kademlia/sim"
+)
+
+// ipfsServer serves the requests of the IPFS DHT from the routing table of a simulated peer.
+type ipfsServer struct {
+	rt        kad.RoutingTable[key.Key256, kad.NodeID[key.Key256]]
+	ep        *sim.Endpoint[key.Key256, multiaddr.Multiaddr]
+	values    map[string]*Record
+	providers map[string][]*AddrInfo
+}
+
+func (s *ipfsServer) handle(ctx context.Context, from kad.NodeID[key.Key256], msg kad.Message) (kad.Message, error) {
+	req := msg.(*Message)
+	closer := s.rt.NearestNodes(req.Target(), 4)
+	switch req.GetType() {
+	case Message_GET_VALUE:
+		return GetValueResponse(req.GetKey(), s.values[string(req.GetKey())], closer, s.ep), nil
+	case Message_PUT_VALUE:
+		s.values[string(req.GetKey())] = req.GetRecord()
+		return PutValueResponse(req), nil
+	case Message_GET_PROVIDERS:
+		return GetProvidersResponse(req.GetKey(), s.providers[string(req.GetKey())], closer, s.ep), nil
+	case Message_ADD_PROVIDER:
+		for _, p := range req.ProviderNodes() {
+			s.providers[string(req.GetKey())] = append(s.providers[string(req.GetKey())], p.(*AddrInfo))
+		}
+		return &Message{Type: Message_ADD_PROVIDER}, nil
+	default:
+		return FindPeerResponse(closer, s.ep), nil
+	}
+}
+
+// newRoutingNetwork creates a chain of simulated IPFS DHT peers and a Routing over a dht.Node of
+// the first one, driven until ctx is done.
+func newRoutingNetwork(t *testing.T, ctx context.Context, ids ...string) (*Routing, []*AddrInfo, []*ipfsServer) {
+	clk := clock.NewMock()
+	router := sim.NewRouter[key.Key256, multiaddr.Multiaddr]()
+	var infos []*AddrInfo
+	var eps []*sim.Endpoint[key.Key256, multiaddr.Multiaddr]
+	var rts []kad.RoutingTable[key.Key256, kad.NodeID[key.Key256]]
+	var servers []*ipfsServer
+	var scheds []event.AwareScheduler
+	for i, id := range ids {
+		info, err := createDummyPeerInfo(id, "/ip4/1.1.1."+string(rune('1'+i))+"/tcp/4001")
+		require.NoError(t, err)
+		sched := event.NewSimpleScheduler(clk)
+		ep := sim.NewEndpoint[key.Key256, multiaddr.Multiaddr](info.PeerID(), sched, router)
+		rt := simplert.New[key.Key256, kad.NodeID[key.Key256]](info.PeerID(), 4)
+		srv := &ipfsServer{rt: rt, ep: ep, values: make(map[string]*Record), providers: make(map[string][]*AddrInfo)}
+		require.NoError(t, ep.AddRequestHandler(ProtocolIPFSDHT, &Message{}, srv.handle))
+
+		infos = append(infos, info)
+		eps = append(eps, ep)
+		rts = append(rts, rt)
+		servers = append(servers, srv)
+		scheds = append(scheds, sched)
+	}
+	for i := 1; i < len(ids); i++ {
+		require.NoError(t, eps[i-1].MaybeAddToPeerstore(ctx, infos[i], time.Hour))
+		rts[i-1].AddNode(infos[i].PeerID())
+		require.NoError(t, eps[i].MaybeAddToPeerstore(ctx, infos[i-1], time.Hour))
+		rts[i].AddNode(infos[i-1].PeerID())
+	}
+
+	cfg := dht.DefaultConfig[key.Key256, multiaddr.Multiaddr]()
+	cfg.Coordinator = coord.DefaultConfig()
+	cfg.Coordinator.Clock = clk
+	provs, err := records.NewProviderStore[key.Key256, multiaddr.Multiaddr](nil)
+	require.NoError(t, err)
+	cfg.Providers = provs
+	n, err := dht.NewNode[key.Key256, multiaddr.Multiaddr](infos[0], eps[0], rts[0], IPFSProtocol{}, cfg)
+	require.NoError(t, err)
+	scheds = append(scheds, n.Scheduler())
+
+	siml := sim.NewLiteSimulator(clk)
+	sim.AddSchedulers(siml, scheds...)
+	n.Start(ctx)
+	go func() {
+		for {
+			select {
+			case <-time.After(time.Millisecond):
+				siml.Run(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	r := NewRouting(n, nil)
+	t.Cleanup(func() { require.NoError(t, r.Close()) })
+	return r, infos, servers
+}
+
+func TestIPFSProtocol(t *testing.T) {
+	p := IPFSProtocol{}
+	require.Equal(t, ProtocolIPFSDHT, p.ID())
+
+	info, err := createDummyPeerInfo("12BooooPEER1", "/ip4/1.1.1.1/tcp/4001")
+	require.NoError(t, err)
+	req, err := p.FindPeerRequest(info.PeerID())
+	require.NoError(t, err)
+	require.True(t, key.Equal(info.Key(), req.Target()))
+	_, err = p.FindPeerRequest(kadtest.NewStringID("other"))
+	require.Error(t, err)
+
+	_, err = p.AddProviderRequest([]byte("k"), info)
+	require.NoError(t, err)
+	_, err = p.AddProviderRequest([]byte("k"), kadtest.NewInfo[key.Key256, multiaddr.Multiaddr](kadtest.NewID(key.ZeroKey256()), nil))
+	require.ErrorIs(t, err, ErrNotPeerAddrInfo)
+
+	_, ok := p.Value(GetValueResponse([]byte("k"), nil, nil, nil))
+	require.False(t, ok)
+	v, ok := p.Value(GetValueResponse([]byte("k"), &Record{Key: []byte("k"), Value: []byte("v")}, nil, nil))
+	require.True(t, ok)
+	require.Equal(t, []byte("v"), v)
+
+	// the token is carried by a copy of the request
+	put := PutValueRequest([]byte("k"), []byte("v"))
+	withToken := p.WithToken(put, []byte("tok"))
+	require.Nil(t, put.GetToken())
+	tok, ok := p.Token(withToken.(*Message))
+	require.True(t, ok)
+	require.Equal(t, []byte("tok"), tok)
+	_, ok = p.Token(put)
+	require.False(t, ok)
+}
+
+func TestRouting(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	r, infos, servers := newRoutingNetwork(t, ctx, "12BooooPEER1", "12BooooPEER2", "12BooooPEER3", "12BooooPEER4")
+
+	t.Run("find peer", func(t *testing.T) {
+		ai, err := r.FindPeer(ctx, infos[3].AddrInfo.ID)
+		require.NoError(t, err)
+		require.Equal(t, infos[3].AddrInfo, ai)
+
+		other, err := createDummyPeerInfo("12BooooPEER5", "/ip4/1.1.1.5/tcp/4001")
+		require.NoError(t, err)
+		_, err = r.FindPeer(ctx, other.AddrInfo.ID)
+		require.ErrorIs(t, err, routing.ErrNotFound)
+	})
+
+	t.Run("values", func(t *testing.T) {
+		require.NoError(t, r.PutValue(ctx, "/v/hello", []byte("world")))
+		stored := 0
+		for _, s := range servers {
+			if rec, ok := s.values["/v/hello"]; ok && string(rec.GetValue()) == "world" {
+				stored++
+			}
+		}
+		require.NotZero(t, stored)
+
+		v, err := r.GetValue(ctx, "/v/hello")
+		require.NoError(t, err)
+		require.Equal(t, []byte("world"), v)
+
+		_, err = r.GetValue(ctx, "/v/missing")
+		require.ErrorIs(t, err, routing.ErrNotFound)
+
+		ch, err := r.SearchValue(ctx, "/v/hello")
+		require.NoError(t, err)
+		require.Equal(t, []byte("world"), <-ch)
+		_, open := <-ch
+		require.False(t, open)
+
+		ch, err = r.SearchValue(ctx, "/v/missing")
+		require.NoError(t, err)
+		_, open = <-ch
+		require.False(t, open)
+	})
+
+	t.Run("providers", func(t *testing.T) {
+		h, err := mh.Sum([]byte("content"), mh.SHA2_256, -1)
+		require.NoError(t, err)
+		c := cid.NewCidV1(cid.Raw, h)
+
+		// nothing is announced without announce, but the node records that it provides c
+		require.NoError(t, r.Provide(ctx, c, false))
+		for _, s := range servers {
+			require.Empty(t, s.providers[string(c.Hash())])
+		}
+		local, err := r.node.FindProviders(ctx, c.Hash()).Recv(ctx)
+		require.NoError(t, err)
+		require.Equal(t, infos[0].PeerID().String(), local.ID().String())
+
+		require.NoError(t, r.Provide(ctx, c, true))
+		var provs []string
+		for ai := range r.FindProvidersAsync(ctx, c, 1) {
+			provs = append(provs, ai.ID.String())
+		}
+		require.Equal(t, []string{infos[0].AddrInfo.ID.String()}, provs)
+
+		require.Error(t, r.Provide(ctx, cid.Undef, true))
+		_, open := <-r.FindProvidersAsync(ctx, cid.Undef, 0)
+		require.False(t, open)
+	})
+
+	require.NoError(t, r.Bootstrap(ctx))
+}
+
+func TestRoutingBootstrap(t *testing.T) {
+	ctx, cancel := kadtest.Ctx(t)
+	defer cancel()
+
+	r, infos, _ := newRoutingNetwork(t, ctx, "12BooooPEER1", "12BooooPEER2")
+
+	// the routing table never holds enough nodes, so the bootstrapper retries until it is stopped
+	bcfg := dht.DefaultBootstrapperConfig[key.Key256, multiaddr.Multiaddr]()
+	bcfg.Peers = []kad.NodeInfo[key.Key256, multiaddr.Multiaddr]{infos[1]}
+	bcfg.MinTableSize = 10
+	bcfg.BackoffMin = time.Hour
+	bcfg.BackoffMax = time.Hour
+	b, err := dht.NewBootstrapper(r.node, bcfg)
+	require.NoError(t, err)
+	br := NewRouting(r.node, &RoutingConfig{Bootstrapper: b})
+
+	// the bootstrap outlives the context of the call
+	bctx, bcancel := context.WithCancel(ctx)
+	require.NoError(t, br.Bootstrap(bctx))
+	bcancel()
+	for retried := false; !retried; {
+		select {
+		case ev := <-b.Events():
+			_, retried = ev.(*dht.EventBootstrapRetry)
+		case <-ctx.Done():
+			t.Fatal("bootstrap stopped")
+		}
+	}
+	br.mu.Lock()
+	require.True(t, br.bootstrapping)
+	br.mu.Unlock()
+
+	// until the routing is closed
+	require.NoError(t, br.Close())
+	br.mu.Lock()
+	require.False(t, br.bootstrapping)
+	br.mu.Unlock()
+	require.ErrorIs(t, br.Bootstrap(ctx), ErrRoutingClosed)
+}
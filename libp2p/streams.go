@@ -0,0 +1,137 @@
+package libp2p
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-msgio"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/network/codec"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+// pooledStream is an outbound stream together with the delimited reader and writer framing its
+// messages. The reader buffers data so it must be kept with the stream for as long as it is reused.
+type pooledStream struct {
+	s network.Stream
+	r msgio.ReadCloser
+	w msgio.WriteCloser
+}
+
+func newPooledStream(s network.Stream, maxMessageSize int) *pooledStream {
+	return &pooledStream{
+		s: s,
+		r: msgio.NewVarintReaderSize(s, maxMessageSize),
+		w: msgio.NewVarintWriter(s),
+	}
+}
+
+// writeMsg encodes m with c and writes it prefixed with its varint length, returning the size
+// of the encoded message. With the protobuf codec this is the same framing as a delimited
+// protobuf writer. Nothing is written if the encoded message exceeds limit bytes.
+func writeMsg(w msgio.Writer, c codec.Codec, m kad.Message, limit int) (int, error) {
+	b, err := c.Marshal(m)
+	if err != nil {
+		return 0, err
+	}
+	if err := endpoint.CheckSize(len(b), limit); err != nil {
+		return 0, err
+	}
+	return len(b), streamError(w.WriteMsg(b))
+}
+
+// readMsg reads a varint length prefixed message and decodes it with c into a new message of
+// the same type as proto, returning the size of the encoded message.
+func readMsg(r msgio.Reader, c codec.Codec, proto kad.Message) (kad.Message, int, error) {
+	b, err := r.ReadMsg()
+	if err != nil {
+		if err == msgio.ErrMsgTooLarge {
+			return nil, 0, fmt.Errorf("%w: %v", endpoint.ErrMessageTooLarge, err)
+		}
+		return nil, 0, streamError(err)
+	}
+	defer r.ReleaseMsg(b)
+	m, err := c.Unmarshal(b, proto)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", endpoint.ErrMalformedMessage, err)
+	}
+	return m, len(b), nil
+}
+
+// streamError wraps the errors of a stream that was reset or closed by the remote peer with
+// endpoint.ErrPeerReset.
+func streamError(err error) error {
+	if errors.Is(err, network.ErrReset) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: %v", endpoint.ErrPeerReset, err)
+	}
+	return err
+}
+
+type streamKey struct {
+	p     peer.ID
+	proto protocol.ID
+}
+
+// streamPool keeps idle outbound streams open so that subsequent requests to the same peer and
+// protocol reuse them instead of negotiating a new stream. It is safe for concurrent use.
+type streamPool struct {
+	max int // the maximum number of idle streams per peer and protocol
+
+	mu   sync.Mutex
+	idle map[streamKey][]*pooledStream
+}
+
+func newStreamPool(max int) *streamPool {
+	return &streamPool{
+		max:  max,
+		idle: make(map[streamKey][]*pooledStream),
+	}
+}
+
+// Get removes and returns an idle stream to p for proto, or nil if there is none.
+func (sp *streamPool) Get(p peer.ID, proto protocol.ID) *pooledStream {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	k := streamKey{p: p, proto: proto}
+	streams := sp.idle[k]
+	for len(streams) > 0 {
+		ps := streams[len(streams)-1]
+		streams = streams[:len(streams)-1]
+		if ps.s.Conn().IsClosed() {
+			ps.s.Reset()
+			continue
+		}
+		sp.setIdle(k, streams)
+		return ps
+	}
+	sp.setIdle(k, streams)
+	return nil
+}
+
+// Put returns an idle stream to the pool, closing it if the pool is full.
+func (sp *streamPool) Put(ps *pooledStream) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	k := streamKey{p: ps.s.Conn().RemotePeer(), proto: ps.s.Protocol()}
+	if len(sp.idle[k]) >= sp.max {
+		ps.s.Close()
+		return
+	}
+	sp.idle[k] = append(sp.idle[k], ps)
+}
+
+func (sp *streamPool) setIdle(k streamKey, streams []*pooledStream) {
+	if len(streams) == 0 {
+		delete(sp.idle, k)
+		return
+	}
+	sp.idle[k] = streams
+}
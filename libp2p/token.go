@@ -0,0 +1,60 @@
+package libp2p
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// TokenField is the number of the Message field carrying a write token. The field is not part of
+// the message definition of the public IPFS DHT, so the token travels as an unknown field that
+// peers unaware of tokens skip and drop.
+const TokenField protowire.Number = 1000
+
+// GetToken returns the write token carried by the message, or nil if it carries none.
+func (msg *Message) GetToken() []byte {
+	if msg == nil {
+		return nil
+	}
+	var tok []byte
+	b := msg.ProtoReflect().GetUnknown()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil
+		}
+		m := protowire.ConsumeFieldValue(num, typ, b[n:])
+		if m < 0 {
+			return nil
+		}
+		if num == TokenField && typ == protowire.BytesType {
+			tok, _ = protowire.ConsumeBytes(b[n:])
+		}
+		b = b[n+m:]
+	}
+	return tok
+}
+
+// SetToken sets the write token carried by the message, replacing any previous token. An empty
+// token removes it.
+func (msg *Message) SetToken(tok []byte) {
+	var unknown []byte
+	b := msg.ProtoReflect().GetUnknown()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			break
+		}
+		m := protowire.ConsumeFieldValue(num, typ, b[n:])
+		if m < 0 {
+			break
+		}
+		if num != TokenField {
+			unknown = append(unknown, b[:n+m]...)
+		}
+		b = b[n+m:]
+	}
+	if len(tok) > 0 {
+		unknown = protowire.AppendTag(unknown, TokenField, protowire.BytesType)
+		unknown = protowire.AppendBytes(unknown, tok)
+	}
+	msg.ProtoReflect().SetUnknown(unknown)
+}
@@ -0,0 +1,93 @@
+// Package logging defines the Logger the components of the module emit structured events to, as
+// a complement to the spans they record with OpenTelemetry. Adapters are provided for slog and
+// zap loggers, and Nop discards all events.
+package logging
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// Logger receives the structured events emitted by the components of the module. Events about
+// each request and response are logged at debug level, events about the outcome of longer
+// running work such as queries at info level.
+type Logger interface {
+	Debug(msg string, fields ...Field)
+	Info(msg string, fields ...Field)
+}
+
+// Field is a key and value attached to an event.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// Any returns a field with an arbitrary value.
+func Any(key string, value any) Field {
+	return Field{Key: key, Value: value}
+}
+
+// String returns a field with a string value.
+func String(key, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Int returns a field with an integer value.
+func Int(key string, value int) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Duration returns a field with a duration value.
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Stringer returns a field with the string representation of value, such as a node id. The
+// string is only formatted once the event is emitted, not for events of disabled levels.
+func Stringer(key string, value fmt.Stringer) Field {
+	return Field{Key: key, Value: deferredString{value}}
+}
+
+// Resolved returns the value of f, formatting the values deferred by Stringer. Loggers that do
+// not emit events to slog or zap, which format them lazily, use it to read the value of a field.
+func (f Field) Resolved() any {
+	if v, ok := f.Value.(deferredString); ok {
+		return v.String()
+	}
+	return f.Value
+}
+
+// deferredString is a value formatted by String only when it is logged: zap formats fmt.Stringer
+// values lazily, and slog resolves slog.LogValuer values.
+type deferredString struct {
+	fmt.Stringer
+}
+
+func (v deferredString) LogValue() slog.Value {
+	return slog.StringValue(v.String())
+}
+
+// Error returns a field holding err under the key "error".
+func Error(err error) Field {
+	return Field{Key: "error", Value: err}
+}
+
+// Nop returns a Logger discarding all events.
+func Nop() Logger {
+	return nopLogger{}
+}
+
+// OrNop returns l, or a Logger discarding all events if l is nil.
+func OrNop(l Logger) Logger {
+	if l == nil {
+		return nopLogger{}
+	}
+	return l
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...Field) {}
+func (nopLogger) Info(string, ...Field)  {}
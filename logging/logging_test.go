@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"golang.org/x/exp/slog"
+)
+
+func TestNop(t *testing.T) {
+	require.Equal(t, Nop(), OrNop(nil))
+	l := NewSlog(slog.Default())
+	require.Equal(t, Logger(l), OrNop(l))
+
+	// discards everything
+	Nop().Info("event", String("key", "value"))
+}
+
+func TestSlog(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlog(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	l.Debug("dropped", Int("n", 1))
+	require.Empty(t, buf.String())
+
+	l.Info("query finished", String("query", "q1"), Int("requests", 3), Duration("duration", time.Second), Error(errors.New("boom")))
+	out := buf.String()
+	require.Contains(t, out, "level=INFO")
+	require.Contains(t, out, `msg="query finished"`)
+	require.Contains(t, out, "query=q1")
+	require.Contains(t, out, "requests=3")
+	require.Contains(t, out, "duration=1s")
+	require.Contains(t, out, "error=boom")
+}
+
+func TestZap(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	l := NewZap(zap.New(core))
+
+	l.Debug("dropped", Int("n", 1))
+	require.Zero(t, logs.Len())
+
+	l.Info("query finished", String("query", "q1"), Int("requests", 3))
+	entries := logs.All()
+	require.Len(t, entries, 1)
+	require.Equal(t, "query finished", entries[0].Message)
+	require.Equal(t, map[string]any{"query": "q1", "requests": int64(3)}, entries[0].ContextMap())
+}
+
+// countingStringer counts the times it is formatted.
+type countingStringer struct {
+	calls int
+}
+
+func (s *countingStringer) String() string {
+	s.calls++
+	return "node"
+}
+
+func TestStringerDeferred(t *testing.T) {
+	s := &countingStringer{}
+	f := Stringer("node", s)
+	require.Zero(t, s.calls)
+	require.Equal(t, "node", f.Resolved())
+	require.Equal(t, 1, s.calls)
+
+	t.Run("slog", func(t *testing.T) {
+		s := &countingStringer{}
+		var buf bytes.Buffer
+		l := NewSlog(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+		l.Debug("dropped", Stringer("node", s))
+		require.Zero(t, s.calls)
+		l.Info("kept", Stringer("node", s))
+		require.Equal(t, 1, s.calls)
+		require.Contains(t, buf.String(), "node=node")
+	})
+
+	t.Run("zap", func(t *testing.T) {
+		s := &countingStringer{}
+		core, logs := observer.New(zapcore.InfoLevel)
+		l := NewZap(zap.New(core))
+		l.Debug("dropped", Stringer("node", s))
+		require.Zero(t, s.calls)
+		l.Info("kept", Stringer("node", s))
+		require.Equal(t, map[string]any{"node": "node"}, logs.All()[0].ContextMap())
+		require.Equal(t, 1, s.calls)
+	})
+}
@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"context"
+
+	"golang.org/x/exp/slog"
+)
+
+// Slog adapts a slog.Logger to a Logger.
+type Slog struct {
+	l *slog.Logger
+}
+
+var _ Logger = (*Slog)(nil)
+
+// NewSlog returns a Logger emitting events to l.
+func NewSlog(l *slog.Logger) *Slog {
+	return &Slog{l: l}
+}
+
+// Debug emits an event at slog.LevelDebug.
+func (s *Slog) Debug(msg string, fields ...Field) {
+	s.log(slog.LevelDebug, msg, fields)
+}
+
+// Info emits an event at slog.LevelInfo.
+func (s *Slog) Info(msg string, fields ...Field) {
+	s.log(slog.LevelInfo, msg, fields)
+}
+
+func (s *Slog) log(level slog.Level, msg string, fields []Field) {
+	ctx := context.Background()
+	if !s.l.Enabled(ctx, level) {
+		return
+	}
+	attrs := make([]slog.Attr, len(fields))
+	for i, f := range fields {
+		attrs[i] = slog.Any(f.Key, f.Value)
+	}
+	s.l.LogAttrs(ctx, level, msg, attrs...)
+}
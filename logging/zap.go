@@ -0,0 +1,40 @@
+package logging
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Zap adapts a zap.Logger to a Logger.
+type Zap struct {
+	l *zap.Logger
+}
+
+var _ Logger = (*Zap)(nil)
+
+// NewZap returns a Logger emitting events to l.
+func NewZap(l *zap.Logger) *Zap {
+	return &Zap{l: l}
+}
+
+// Debug emits an event at zap.DebugLevel.
+func (z *Zap) Debug(msg string, fields ...Field) {
+	z.log(zapcore.DebugLevel, msg, fields)
+}
+
+// Info emits an event at zap.InfoLevel.
+func (z *Zap) Info(msg string, fields ...Field) {
+	z.log(zapcore.InfoLevel, msg, fields)
+}
+
+func (z *Zap) log(level zapcore.Level, msg string, fields []Field) {
+	ce := z.l.Check(level, msg)
+	if ce == nil {
+		return
+	}
+	zfs := make([]zap.Field, len(fields))
+	for i, f := range fields {
+		zfs[i] = zap.Any(f.Key, f.Value)
+	}
+	ce.Write(zfs...)
+}
@@ -0,0 +1,27 @@
+package codec
+
+import (
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/plprobelab/go-kademlia/kad"
+)
+
+// CBOR is a Codec encoding messages as CBOR (RFC 8949). Message prototypes must be pointers.
+type CBOR struct{}
+
+var _ Codec = CBOR{}
+
+func (CBOR) Marshal(m kad.Message) ([]byte, error) {
+	return cbor.Marshal(m)
+}
+
+func (CBOR) Unmarshal(data []byte, proto kad.Message) (kad.Message, error) {
+	m, err := newMessage(proto)
+	if err != nil {
+		return nil, err
+	}
+	if err := cbor.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,68 @@
+// Package codec defines how Kademlia messages are encoded on the wire and provides
+// protobuf, CBOR and JSON implementations.
+package codec
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/network/address"
+)
+
+// Codec encodes and decodes the messages exchanged by endpoints.
+type Codec interface {
+	// Marshal returns the encoding of the message.
+	Marshal(kad.Message) ([]byte, error)
+
+	// Unmarshal decodes data into a new message of the same type as the given prototype.
+	// The prototype itself is not modified.
+	Unmarshal(data []byte, proto kad.Message) (kad.Message, error)
+}
+
+// Registry selects the Codec used for each protocol, falling back to a default Codec for
+// protocols that have not been assigned one. It is safe for concurrent use.
+type Registry struct {
+	def Codec
+
+	mu     sync.RWMutex
+	codecs map[address.ProtocolID]Codec
+}
+
+// NewRegistry creates a Registry using def for protocols that have not been assigned a Codec.
+func NewRegistry(def Codec) *Registry {
+	return &Registry{
+		def:    def,
+		codecs: make(map[address.ProtocolID]Codec),
+	}
+}
+
+// Set assigns a Codec to a protocol. A nil Codec restores the default for the protocol.
+func (r *Registry) Set(protoID address.ProtocolID, c Codec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c == nil {
+		delete(r.codecs, protoID)
+		return
+	}
+	r.codecs[protoID] = c
+}
+
+// Get returns the Codec used for a protocol.
+func (r *Registry) Get(protoID address.ProtocolID) Codec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if c, ok := r.codecs[protoID]; ok {
+		return c
+	}
+	return r.def
+}
+
+// newMessage returns a pointer to a new zero value of the type proto points to.
+func newMessage(proto kad.Message) (any, error) {
+	t := reflect.TypeOf(proto)
+	if t == nil || t.Kind() != reflect.Pointer {
+		return nil, ErrInvalidPrototype
+	}
+	return reflect.New(t.Elem()).Interface(), nil
+}
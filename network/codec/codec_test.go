@@ -0,0 +1,77 @@
+package codec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/plprobelab/go-kademlia/network/address"
+)
+
+type testMessage struct {
+	Text  string
+	Count int
+	Data  []byte
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(JSON{})
+	protoID := address.ProtocolID("/test/1.0.0")
+
+	require.Equal(t, JSON{}, r.Get(protoID))
+
+	r.Set(protoID, CBOR{})
+	require.Equal(t, CBOR{}, r.Get(protoID))
+	require.Equal(t, JSON{}, r.Get("/other/1.0.0"))
+
+	// nil restores the default
+	r.Set(protoID, nil)
+	require.Equal(t, JSON{}, r.Get(protoID))
+}
+
+func TestRoundTrip(t *testing.T) {
+	for name, c := range map[string]Codec{"json": JSON{}, "cbor": CBOR{}} {
+		t.Run(name, func(t *testing.T) {
+			msg := &testMessage{Text: "hello", Count: 3, Data: []byte{1, 2, 3}}
+			data, err := c.Marshal(msg)
+			require.NoError(t, err)
+
+			proto := &testMessage{}
+			got, err := c.Unmarshal(data, proto)
+			require.NoError(t, err)
+			require.Equal(t, msg, got)
+			// the prototype is not modified
+			require.Equal(t, &testMessage{}, proto)
+
+			_, err = c.Unmarshal(data, testMessage{})
+			require.ErrorIs(t, err, ErrInvalidPrototype)
+			_, err = c.Unmarshal(data, nil)
+			require.ErrorIs(t, err, ErrInvalidPrototype)
+		})
+	}
+}
+
+func TestProtobuf(t *testing.T) {
+	c := Protobuf{}
+	msg := wrapperspb.String("hello")
+	data, err := c.Marshal(msg)
+	require.NoError(t, err)
+
+	// the encoding matches the protobuf wire format
+	expected, err := proto.Marshal(msg)
+	require.NoError(t, err)
+	require.Equal(t, expected, data)
+
+	p := &wrapperspb.StringValue{}
+	got, err := c.Unmarshal(data, p)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(msg, got.(proto.Message)))
+	require.Empty(t, p.GetValue())
+
+	_, err = c.Marshal(&testMessage{})
+	require.ErrorIs(t, err, ErrNotProtoMessage)
+	_, err = c.Unmarshal(data, &testMessage{})
+	require.ErrorIs(t, err, ErrNotProtoMessage)
+}
@@ -0,0 +1,8 @@
+package codec
+
+import "errors"
+
+var (
+	ErrInvalidPrototype = errors.New("message prototype must be a non-nil pointer")
+	ErrNotProtoMessage  = errors.New("message is not a protobuf message")
+)
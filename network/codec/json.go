@@ -0,0 +1,27 @@
+package codec
+
+import (
+	"encoding/json"
+
+	"github.com/plprobelab/go-kademlia/kad"
+)
+
+// JSON is a Codec encoding messages as JSON. Message prototypes must be pointers.
+type JSON struct{}
+
+var _ Codec = JSON{}
+
+func (JSON) Marshal(m kad.Message) ([]byte, error) {
+	return json.Marshal(m)
+}
+
+func (JSON) Unmarshal(data []byte, proto kad.Message) (kad.Message, error) {
+	m, err := newMessage(proto)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,33 @@
+package codec
+
+import (
+	"google.golang.org/protobuf/proto"
+
+	"github.com/plprobelab/go-kademlia/kad"
+)
+
+// Protobuf is a Codec encoding messages with protocol buffers. Messages and prototypes
+// must implement proto.Message.
+type Protobuf struct{}
+
+var _ Codec = Protobuf{}
+
+func (Protobuf) Marshal(m kad.Message) ([]byte, error) {
+	pm, ok := m.(proto.Message)
+	if !ok {
+		return nil, ErrNotProtoMessage
+	}
+	return proto.Marshal(pm)
+}
+
+func (Protobuf) Unmarshal(data []byte, p kad.Message) (kad.Message, error) {
+	pm, ok := p.(proto.Message)
+	if !ok {
+		return nil, ErrNotProtoMessage
+	}
+	m := pm.ProtoReflect().New().Interface()
+	if err := proto.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,167 @@
+package endpoint
+
+import (
+	"net"
+	"sync"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+// CloserNodesConfig specifies optional configuration for a CloserNodesPolicy
+type CloserNodesConfig[K kad.Key[K], A kad.Address[A]] struct {
+	// Routable optionally reports whether remote nodes can reach a node at an address, such as
+	// PublicIP. Closer nodes without a routable address are rejected. Nil accepts all addresses,
+	// including closer nodes without address.
+	Routable func(A) bool
+
+	// Strict rejects the closer nodes farther from the target than the responder. A responder
+	// close to the target may honestly return nodes farther than itself, which strict mode
+	// rejects too.
+	Strict bool
+
+	// Report is optionally called with the responder of each response with rejected closer
+	// nodes, for instance to feed the RecordInvalid method of a server.Monitor.
+	Report func(kad.NodeID[K])
+}
+
+// CloserNodesStats holds the numbers of closer nodes a CloserNodesPolicy rejected from the
+// responses of a peer, by reason.
+type CloserNodesStats struct {
+	Self       int64 // closer nodes that were the local node
+	Responder  int64 // closer nodes that were the responder itself
+	Unroutable int64 // closer nodes without a routable address
+	Farther    int64 // closer nodes farther from the target than the responder, in strict mode
+}
+
+// Total returns the number of rejected closer nodes.
+func (s CloserNodesStats) Total() int64 {
+	return s.Self + s.Responder + s.Unroutable + s.Farther
+}
+
+// CloserNodesPolicy validates the closer nodes of the responses received from remote peers,
+// rejecting the entries that are the local node, the responder itself, without a routable
+// address or, in strict mode, farther from the target than the responder. It counts the
+// rejected entries per responder. It is safe for concurrent use.
+type CloserNodesPolicy[K kad.Key[K], A kad.Address[A]] struct {
+	self kad.NodeID[K]
+	cfg  CloserNodesConfig[K, A]
+
+	mu       sync.Mutex // guards rejected
+	rejected map[string]*CloserNodesStats
+}
+
+// NewCloserNodesPolicy returns a policy validating closer nodes for the local node self. If cfg
+// is nil, only the local node and the responders themselves are rejected.
+func NewCloserNodesPolicy[K kad.Key[K], A kad.Address[A]](self kad.NodeID[K], cfg *CloserNodesConfig[K, A]) *CloserNodesPolicy[K, A] {
+	if cfg == nil {
+		cfg = &CloserNodesConfig[K, A]{}
+	}
+	return &CloserNodesPolicy[K, A]{
+		self:     self,
+		cfg:      *cfg,
+		rejected: make(map[string]*CloserNodesStats),
+	}
+}
+
+// Filter returns the closer nodes sent by responder for target that the policy accepts, in
+// their original order.
+func (p *CloserNodesPolicy[K, A]) Filter(responder kad.NodeID[K], target K, nodes []kad.NodeInfo[K, A]) []kad.NodeInfo[K, A] {
+	var stats CloserNodesStats
+	responderDist := responder.Key().Xor(target)
+	accepted := make([]kad.NodeInfo[K, A], 0, len(nodes))
+	for _, n := range nodes {
+		k := n.ID().Key()
+		switch {
+		case key.Equal(k, p.self.Key()):
+			stats.Self++
+		case key.Equal(k, responder.Key()):
+			stats.Responder++
+		case p.cfg.Routable != nil && !p.routable(n):
+			stats.Unroutable++
+		case p.cfg.Strict && k.Xor(target).Compare(responderDist) > 0:
+			stats.Farther++
+		default:
+			accepted = append(accepted, n)
+		}
+	}
+	if stats.Total() == 0 {
+		return accepted
+	}
+
+	p.mu.Lock()
+	s, ok := p.rejected[responder.String()]
+	if !ok {
+		s = &CloserNodesStats{}
+		p.rejected[responder.String()] = s
+	}
+	s.Self += stats.Self
+	s.Responder += stats.Responder
+	s.Unroutable += stats.Unroutable
+	s.Farther += stats.Farther
+	p.mu.Unlock()
+
+	if p.cfg.Report != nil {
+		p.cfg.Report(responder)
+	}
+	return accepted
+}
+
+// FilterResponse returns resp if the policy accepts all its closer nodes, or a response whose
+// CloserNodes returns the accepted closer nodes only otherwise. The returned response can't be
+// converted back to the type of resp, which callers reading other fields must keep.
+func (p *CloserNodesPolicy[K, A]) FilterResponse(responder kad.NodeID[K], target K, resp kad.Response[K, A]) kad.Response[K, A] {
+	if resp == nil {
+		return nil
+	}
+	nodes := resp.CloserNodes()
+	accepted := p.Filter(responder, target, nodes)
+	if len(accepted) == len(nodes) {
+		return resp
+	}
+	return &filteredResponse[K, A]{Response: resp, closer: accepted}
+}
+
+// Rejected returns the numbers of closer nodes rejected from the responses of id.
+func (p *CloserNodesPolicy[K, A]) Rejected(id kad.NodeID[K]) CloserNodesStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, ok := p.rejected[id.String()]; ok {
+		return *s
+	}
+	return CloserNodesStats{}
+}
+
+// Forget discards the counts of the closer nodes rejected from the responses of id, for
+// instance once it is removed from the routing table.
+func (p *CloserNodesPolicy[K, A]) Forget(id kad.NodeID[K]) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.rejected, id.String())
+}
+
+// routable reports whether n has at least one routable address.
+func (p *CloserNodesPolicy[K, A]) routable(n kad.NodeInfo[K, A]) bool {
+	for _, a := range n.Addresses() {
+		if p.cfg.Routable(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// filteredResponse is a response whose closer nodes were filtered by a CloserNodesPolicy.
+type filteredResponse[K kad.Key[K], A kad.Address[A]] struct {
+	kad.Response[K, A]
+	closer []kad.NodeInfo[K, A]
+}
+
+func (r *filteredResponse[K, A]) CloserNodes() []kad.NodeInfo[K, A] {
+	return r.closer
+}
+
+// PublicIP reports whether ip is a public unicast address, which remote nodes can reach, as
+// opposed to unspecified, loopback, link-local, multicast and private addresses.
+func PublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
@@ -0,0 +1,113 @@
+package endpoint
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+func closerInfos(infos ...*kadtest.Info[key.Key8, kadtest.StrAddr]) []kad.NodeInfo[key.Key8, kadtest.StrAddr] {
+	nodes := make([]kad.NodeInfo[key.Key8, kadtest.StrAddr], len(infos))
+	for i, n := range infos {
+		nodes[i] = n
+	}
+	return nodes
+}
+
+func TestCloserNodesPolicy(t *testing.T) {
+	self := kadtest.NewID(key.Key8(0b00000000))
+	responder := kadtest.NewID(key.Key8(0b11110000))
+	target := key.Key8(0b11111111)
+
+	nodes := closerInfos(kadtest.NewInfos(
+		key.Key8(0b00000000), // self
+		key.Key8(0b11110000), // responder
+		key.Key8(0b11111110), // closer to the target than the responder
+		key.Key8(0b00001111), // farther from the target than the responder
+	)...)
+
+	t.Run("default rejects self and responder", func(t *testing.T) {
+		p := NewCloserNodesPolicy[key.Key8, kadtest.StrAddr](self, nil)
+		accepted := p.Filter(responder, target, nodes)
+		require.Equal(t, nodes[2:], accepted)
+		require.Equal(t, CloserNodesStats{Self: 1, Responder: 1}, p.Rejected(responder))
+		require.Equal(t, int64(2), p.Rejected(responder).Total())
+	})
+
+	t.Run("strict rejects farther nodes", func(t *testing.T) {
+		p := NewCloserNodesPolicy[key.Key8, kadtest.StrAddr](self, &CloserNodesConfig[key.Key8, kadtest.StrAddr]{Strict: true})
+		accepted := p.Filter(responder, target, nodes)
+		require.Equal(t, nodes[2:3], accepted)
+		require.Equal(t, CloserNodesStats{Self: 1, Responder: 1, Farther: 1}, p.Rejected(responder))
+	})
+
+	t.Run("unroutable nodes are rejected", func(t *testing.T) {
+		p := NewCloserNodesPolicy[key.Key8, kadtest.StrAddr](self, &CloserNodesConfig[key.Key8, kadtest.StrAddr]{
+			Routable: func(a kadtest.StrAddr) bool { return a != "0xfe" },
+		})
+		noAddr := kadtest.NewInfo[key.Key8, kadtest.StrAddr](kadtest.NewID(key.Key8(0b11111100)), nil)
+		accepted := p.Filter(responder, target, append(nodes[2:], noAddr))
+		require.Equal(t, nodes[3:], accepted)
+		require.Equal(t, CloserNodesStats{Unroutable: 2}, p.Rejected(responder))
+	})
+
+	t.Run("rejects are counted and reported per responder", func(t *testing.T) {
+		var reported []kad.NodeID[key.Key8]
+		p := NewCloserNodesPolicy[key.Key8, kadtest.StrAddr](self, &CloserNodesConfig[key.Key8, kadtest.StrAddr]{
+			Report: func(id kad.NodeID[key.Key8]) { reported = append(reported, id) },
+		})
+		other := kadtest.NewID(key.Key8(0b11111110))
+
+		p.Filter(responder, target, nodes)
+		p.Filter(responder, target, nodes)
+		// a response without rejected closer nodes isn't reported
+		p.Filter(other, target, nodes[3:])
+
+		require.Equal(t, []kad.NodeID[key.Key8]{responder, responder}, reported)
+		require.Equal(t, CloserNodesStats{Self: 2, Responder: 2}, p.Rejected(responder))
+		require.Equal(t, CloserNodesStats{}, p.Rejected(other))
+
+		p.Forget(responder)
+		require.Equal(t, CloserNodesStats{}, p.Rejected(responder))
+	})
+}
+
+func TestCloserNodesPolicyFilterResponse(t *testing.T) {
+	self := kadtest.NewID(key.Key8(0b00000000))
+	responder := kadtest.NewID(key.Key8(0b11110000))
+	target := key.Key8(0b11111111)
+	p := NewCloserNodesPolicy[key.Key8, kadtest.StrAddr](self, nil)
+
+	require.Nil(t, p.FilterResponse(responder, target, nil))
+
+	// a response without rejected closer nodes is returned as is
+	valid := kadtest.NewResponse("resp", closerInfos(kadtest.NewInfos(key.Key8(0b11111110))...))
+	require.Same(t, valid, p.FilterResponse(responder, target, valid))
+
+	nodes := closerInfos(kadtest.NewInfos(key.Key8(0b00000000), key.Key8(0b11111110))...)
+	resp := p.FilterResponse(responder, target, kadtest.NewResponse("resp", nodes))
+	require.Equal(t, nodes[1:], resp.CloserNodes())
+	require.Len(t, nodes, 2)
+}
+
+func TestPublicIP(t *testing.T) {
+	for s, public := range map[string]bool{
+		"1.1.1.1":     true,
+		"2606:4700::": true,
+		"10.0.0.1":    false,
+		"192.168.1.1": false,
+		"127.0.0.1":   false,
+		"0.0.0.0":     false,
+		"169.254.0.1": false,
+		"224.0.0.1":   false,
+		"::1":         false,
+		"fd00::1":     false,
+	} {
+		require.Equal(t, public, PublicIP(net.ParseIP(s)), s)
+	}
+}
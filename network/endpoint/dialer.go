@@ -0,0 +1,218 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/kaderr"
+)
+
+// DialerConfig specifies optional configuration for a Dialer
+type DialerConfig struct {
+	Clock            clock.Clock   // a clock that may replaced by a mock when testing
+	MaxParallelDials int           // the maximum number of dials in progress at once, further dials wait for one to complete
+	BackoffBase      time.Duration // the delay before a peer may be dialled again after a failed dial, doubled after each further failure, zero disables backoff
+	BackoffMax       time.Duration // the maximum delay before a peer may be dialled again after failed dials
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *DialerConfig) Validate() error {
+	if cfg.Clock == nil {
+		return &kaderr.ConfigurationError{
+			Component: "DialerConfig",
+			Err:       fmt.Errorf("clock must not be nil"),
+		}
+	}
+
+	if cfg.MaxParallelDials < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "DialerConfig",
+			Err:       fmt.Errorf("max parallel dials must be greater than zero"),
+		}
+	}
+
+	if cfg.BackoffBase < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "DialerConfig",
+			Err:       fmt.Errorf("backoff base must not be negative"),
+		}
+	}
+
+	if cfg.BackoffMax < cfg.BackoffBase {
+		return &kaderr.ConfigurationError{
+			Component: "DialerConfig",
+			Err:       fmt.Errorf("backoff max must not be less than backoff base"),
+		}
+	}
+
+	return nil
+}
+
+// DefaultDialerConfig returns the default configuration options for a Dialer.
+// Options may be overridden before passing to NewDialer
+func DefaultDialerConfig() *DialerConfig {
+	return &DialerConfig{
+		Clock:            clock.New(), // use standard time
+		MaxParallelDials: 16,
+		BackoffBase:      5 * time.Second,
+		BackoffMax:       5 * time.Minute,
+	}
+}
+
+// DialStats holds the counters of a Dialer.
+type DialStats struct {
+	Started      int64 // the number of dials started
+	Succeeded    int64 // the number of dials that succeeded
+	Failed       int64 // the number of dials that failed
+	Deduplicated int64 // the number of dials that joined a dial to the same peer already in progress
+	BackedOff    int64 // the number of dials rejected with ErrDialBackoff
+	InProgress   int   // the number of dials in progress
+	Waiting      int   // the number of dials waiting for a dial in progress to complete
+}
+
+// Dialer dials remote peers on behalf of an endpoint. Concurrent dials to the same peer are
+// deduplicated, the number of dials in progress is capped, and a peer that could not be dialled
+// is not dialled again until a backoff has elapsed. The backoff doubles with each consecutive
+// failure, up to a maximum, and is reset by a successful dial. It is safe for concurrent use.
+type Dialer struct {
+	cfg   DialerConfig
+	slots chan struct{}
+
+	mu      sync.Mutex // guards all fields below
+	calls   map[string]*dialCall
+	backoff map[string]*backoffState
+	stats   DialStats
+}
+
+// dialCall is a dial in progress, done is closed once err is set.
+type dialCall struct {
+	done chan struct{}
+	err  error
+}
+
+type backoffState struct {
+	failures int
+	until    time.Time
+}
+
+// NewDialer returns a Dialer. If cfg is nil, the default config is used.
+func NewDialer(cfg *DialerConfig) (*Dialer, error) {
+	if cfg == nil {
+		cfg = DefaultDialerConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Dialer{
+		cfg:     *cfg,
+		slots:   make(chan struct{}, cfg.MaxParallelDials),
+		calls:   make(map[string]*dialCall),
+		backoff: make(map[string]*backoffState),
+	}, nil
+}
+
+// Dial dials peer by calling dial, or waits for the dial to peer already in progress and returns
+// its result. It returns ErrDialBackoff without dialling if the backoff of peer has not elapsed,
+// or the error of ctx if it is done before the dial completes. The dial of a peer is cancelled
+// when the context of the caller that started it is done.
+func (d *Dialer) Dial(ctx context.Context, peer string, dial func(context.Context) error) error {
+	d.mu.Lock()
+	if c, ok := d.calls[peer]; ok {
+		d.stats.Deduplicated++
+		d.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if d.backedOff(peer) {
+		d.stats.BackedOff++
+		d.mu.Unlock()
+		return ErrDialBackoff
+	}
+	c := &dialCall{done: make(chan struct{})}
+	d.calls[peer] = c
+	d.stats.Waiting++
+	d.mu.Unlock()
+
+	select {
+	case d.slots <- struct{}{}:
+		d.mu.Lock()
+		d.stats.Waiting--
+		d.stats.InProgress++
+		d.stats.Started++
+		d.mu.Unlock()
+
+		c.err = dial(ctx)
+		<-d.slots
+
+		d.mu.Lock()
+		d.stats.InProgress--
+		if c.err != nil {
+			d.stats.Failed++
+			d.failure(peer)
+		} else {
+			d.stats.Succeeded++
+			delete(d.backoff, peer)
+		}
+	case <-ctx.Done():
+		c.err = ctx.Err()
+		d.mu.Lock()
+		d.stats.Waiting--
+	}
+	delete(d.calls, peer)
+	d.mu.Unlock()
+	close(c.done)
+	return c.err
+}
+
+// Backoff reports whether dials to peer are rejected because of previous failures.
+func (d *Dialer) Backoff(peer string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.backedOff(peer)
+}
+
+// Stats returns the counters of the dialer.
+func (d *Dialer) Stats() DialStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stats
+}
+
+// backedOff reports whether the backoff of peer has not elapsed. d.mu must be held.
+func (d *Dialer) backedOff(peer string) bool {
+	if d.cfg.BackoffBase <= 0 {
+		return false
+	}
+	st, ok := d.backoff[peer]
+	return ok && d.cfg.Clock.Now().Before(st.until)
+}
+
+// failure records a failed dial to peer. d.mu must be held.
+func (d *Dialer) failure(peer string) {
+	if d.cfg.BackoffBase <= 0 {
+		return
+	}
+	st, ok := d.backoff[peer]
+	if !ok {
+		st = &backoffState{}
+		d.backoff[peer] = st
+	}
+	st.failures++
+
+	b := d.cfg.BackoffBase
+	for i := 1; i < st.failures && b < d.cfg.BackoffMax; i++ {
+		b *= 2
+	}
+	if b > d.cfg.BackoffMax {
+		b = d.cfg.BackoffMax
+	}
+	st.until = d.cfg.Clock.Now().Add(b)
+}
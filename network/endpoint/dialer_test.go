@@ -0,0 +1,201 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialerConfigValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		cfg := DefaultDialerConfig()
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("clock is not nil", func(t *testing.T) {
+		cfg := DefaultDialerConfig()
+		cfg.Clock = nil
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("max parallel dials positive", func(t *testing.T) {
+		cfg := DefaultDialerConfig()
+		cfg.MaxParallelDials = 0
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("backoff base not negative", func(t *testing.T) {
+		cfg := DefaultDialerConfig()
+		cfg.BackoffBase = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("backoff max not less than base", func(t *testing.T) {
+		cfg := DefaultDialerConfig()
+		cfg.BackoffMax = cfg.BackoffBase - 1
+		require.Error(t, cfg.Validate())
+	})
+}
+
+var errDial = errors.New("dial failed")
+
+func failDial(ctx context.Context) error { return errDial }
+
+func succeedDial(ctx context.Context) error { return nil }
+
+func TestDialerBackoff(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultDialerConfig()
+	cfg.Clock = clk
+	cfg.BackoffBase = time.Second
+	cfg.BackoffMax = 4 * time.Second
+	d, err := NewDialer(cfg)
+	require.NoError(t, err)
+
+	require.False(t, d.Backoff("peer"))
+
+	// the backoff doubles with each failure
+	require.ErrorIs(t, d.Dial(ctx, "peer", failDial), errDial)
+	require.True(t, d.Backoff("peer"))
+	require.False(t, d.Backoff("other"))
+	require.ErrorIs(t, d.Dial(ctx, "peer", succeedDial), ErrDialBackoff)
+	clk.Add(time.Second)
+	require.False(t, d.Backoff("peer"))
+
+	require.ErrorIs(t, d.Dial(ctx, "peer", failDial), errDial)
+	clk.Add(time.Second)
+	require.True(t, d.Backoff("peer"))
+	clk.Add(time.Second)
+	require.False(t, d.Backoff("peer"))
+
+	// up to the maximum
+	require.ErrorIs(t, d.Dial(ctx, "peer", failDial), errDial)
+	clk.Add(4 * time.Second)
+	require.ErrorIs(t, d.Dial(ctx, "peer", failDial), errDial)
+	clk.Add(4*time.Second - time.Nanosecond)
+	require.True(t, d.Backoff("peer"))
+	clk.Add(time.Nanosecond)
+	require.False(t, d.Backoff("peer"))
+
+	// a success resets the backoff
+	require.NoError(t, d.Dial(ctx, "peer", succeedDial))
+	require.ErrorIs(t, d.Dial(ctx, "peer", failDial), errDial)
+	clk.Add(time.Second)
+	require.False(t, d.Backoff("peer"))
+
+	stats := d.Stats()
+	require.Equal(t, int64(6), stats.Started)
+	require.Equal(t, int64(1), stats.Succeeded)
+	require.Equal(t, int64(5), stats.Failed)
+	require.Equal(t, int64(1), stats.BackedOff)
+}
+
+func TestDialerBackoffDisabled(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultDialerConfig()
+	cfg.Clock = clock.NewMock()
+	cfg.BackoffBase = 0
+	cfg.BackoffMax = 0
+	d, err := NewDialer(cfg)
+	require.NoError(t, err)
+
+	require.ErrorIs(t, d.Dial(ctx, "peer", failDial), errDial)
+	require.False(t, d.Backoff("peer"))
+	require.NoError(t, d.Dial(ctx, "peer", succeedDial))
+}
+
+func TestDialerDeduplicates(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDialer(nil)
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	dials := 0
+	dial := func(ctx context.Context) error {
+		dials++
+		close(started)
+		<-release
+		return errDial
+	}
+
+	errs := make(chan error, 2)
+	go func() { errs <- d.Dial(ctx, "peer", dial) }()
+	<-started
+	go func() { errs <- d.Dial(ctx, "peer", dial) }()
+	require.Eventually(t, func() bool { return d.Stats().Deduplicated == 1 }, time.Second, time.Millisecond)
+
+	close(release)
+	require.ErrorIs(t, <-errs, errDial)
+	require.ErrorIs(t, <-errs, errDial)
+	require.Equal(t, 1, dials)
+}
+
+func TestDialerMaxParallelDials(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultDialerConfig()
+	cfg.MaxParallelDials = 2
+	d, err := NewDialer(cfg)
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	dial := func(ctx context.Context) error {
+		<-release
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for _, p := range []string{"a", "b", "c"} {
+		p := p
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, d.Dial(ctx, p, dial))
+		}()
+	}
+	require.Eventually(t, func() bool {
+		stats := d.Stats()
+		return stats.InProgress == 2 && stats.Waiting == 1
+	}, time.Second, time.Millisecond)
+
+	close(release)
+	wg.Wait()
+
+	stats := d.Stats()
+	require.Equal(t, int64(3), stats.Started)
+	require.Equal(t, int64(3), stats.Succeeded)
+	require.Equal(t, 0, stats.InProgress)
+	require.Equal(t, 0, stats.Waiting)
+}
+
+func TestDialerContextDoneWhileWaiting(t *testing.T) {
+	cfg := DefaultDialerConfig()
+	cfg.MaxParallelDials = 1
+	d, err := NewDialer(cfg)
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- d.Dial(context.Background(), "a", func(ctx context.Context) error {
+			<-release
+			return nil
+		})
+	}()
+	require.Eventually(t, func() bool { return d.Stats().InProgress == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, d.Dial(ctx, "b", succeedDial), context.Canceled)
+
+	// a dial that didn't start is not a failure
+	require.False(t, d.Backoff("b"))
+	close(release)
+	require.NoError(t, <-done)
+}
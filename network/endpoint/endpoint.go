@@ -35,6 +35,27 @@ type RequestHandlerFn[K kad.Key[K]] func(context.Context, kad.NodeID[K],
 // request previously sent to a remote peer.
 type ResponseHandlerFn[K kad.Key[K], A kad.Address[A]] func(context.Context, kad.Response[K, A], error)
 
+// StreamingRequestHandlerFn defines a function that handles a request from a remote peer by
+// sending any number of responses with the given send function. The stream of responses ends
+// when the function returns, and is aborted if it returns an error.
+type StreamingRequestHandlerFn[K kad.Key[K]] func(context.Context, kad.NodeID[K],
+	kad.Message, func(kad.Message) error) error
+
+// StreamResponseHandlerFn defines a function that deals with each of the responses streamed
+// back to a request previously sent to a remote peer. It is called once per response with last
+// set to false, then a final time with last set to true and a nil response, with a nil error if
+// the stream ended normally.
+type StreamResponseHandlerFn[K kad.Key[K], A kad.Address[A]] func(ctx context.Context, resp kad.Response[K, A], last bool, err error)
+
+// NegotiatedResponseHandlerFn defines a function that deals with the response to a request
+// previously sent to a remote peer with one of several protocols, given the protocol that was
+// used. The protocol is empty if the request could not be sent with any of them.
+type NegotiatedResponseHandlerFn[K kad.Key[K], A kad.Address[A]] func(context.Context, address.ProtocolID, kad.Response[K, A], error)
+
+// MessageHandlerFn defines a function that handles a one-way message from a remote peer, which
+// expects no response.
+type MessageHandlerFn[K kad.Key[K]] func(context.Context, kad.NodeID[K], kad.Message)
+
 // Endpoint defines how Kademlia nodes interacts with each other.
 type Endpoint[K kad.Key[K], A kad.Address[A]] interface {
 	// MaybeAddToPeerstore adds the given address to the peerstore if it is
@@ -72,5 +93,86 @@ type NetworkedEndpoint[K kad.Key[K], A kad.Address[A]] interface {
 	Connectedness(kad.NodeID[K]) (Connectedness, error)
 }
 
+// ConnEventEndpoint is an endpoint emitting events when connections with remote peers are
+// established, closed or fail to be established.
+type ConnEventEndpoint[K kad.Key[K], A kad.Address[A]] interface {
+	Endpoint[K, A]
+	// SubscribeConnEvents returns a subscription to the connection events of the endpoint,
+	// buffering up to the given number of events.
+	SubscribeConnEvents(int) *ConnSubscription[K]
+}
+
+// PipelinedEndpoint is an endpoint multiplexing the requests sent to a remote peer on a single
+// logical connection, each request being identified by its own stream id. Requests beyond the
+// number a connection may have outstanding are queued, and sent in turn across protocols.
+type PipelinedEndpoint[K kad.Key[K], A kad.Address[A]] interface {
+	Endpoint[K, A]
+	// SendPipelinedRequest behaves like SendRequestHandleResponse and returns the stream id
+	// assigned to the request.
+	SendPipelinedRequest(context.Context, address.ProtocolID, kad.NodeID[K],
+		kad.Message, kad.Message, time.Duration,
+		ResponseHandlerFn[K, A]) (StreamID, error)
+	// Outstanding returns the number of requests sent to the given peer and waiting for a
+	// response.
+	Outstanding(kad.NodeID[K]) int
+}
+
+// StreamingEndpoint is a server endpoint whose request handlers may answer a request with a
+// stream of responses, such as providers trickling in.
+type StreamingEndpoint[K kad.Key[K], A kad.Address[A]] interface {
+	ServerEndpoint[K, A]
+	// AddStreamingRequestHandler registers a streaming handler for a given protocol ID,
+	// replacing any handler registered with AddRequestHandler.
+	AddStreamingRequestHandler(address.ProtocolID, kad.Message, StreamingRequestHandlerFn[K]) error
+	// SendRequestHandleStream attempts to send a request to the given peer and handles each
+	// response streamed back with the given handler. The timeout applies to the wait for each
+	// response of the stream.
+	SendRequestHandleStream(context.Context, address.ProtocolID, kad.NodeID[K],
+		kad.Message, kad.Message, time.Duration,
+		StreamResponseHandlerFn[K, A]) error
+}
+
+// PushEndpoint is an endpoint sending and receiving one-way messages, which are not answered,
+// for protocols such as gossip or notifications.
+type PushEndpoint[K kad.Key[K], A kad.Address[A]] interface {
+	Endpoint[K, A]
+	// PushMessage attempts to send a one-way message to the given peer. An error is returned if
+	// the endpoint is unable to initiate sending the message, but its delivery is not confirmed.
+	PushMessage(context.Context, address.ProtocolID, kad.NodeID[K], kad.Message) error
+	// AddMessageHandler registers a handler for the one-way messages of a given protocol ID.
+	AddMessageHandler(address.ProtocolID, MessageHandlerFn[K]) error
+	// RemoveMessageHandler removes the one-way message handler for a given protocol ID.
+	RemoveMessageHandler(address.ProtocolID)
+}
+
+// NegotiatingEndpoint is an endpoint that negotiates with the remote peer which of several
+// protocols to send a request with.
+type NegotiatingEndpoint[K kad.Key[K], A kad.Address[A]] interface {
+	Endpoint[K, A]
+	// SendRequestNegotiate behaves like SendRequestHandleResponse, sending the request with the
+	// first of the given protocols, in order of preference, that the remote peer supports.
+	// ErrProtocolNotSupported is passed to the handler if the peer supports none of them.
+	SendRequestNegotiate(context.Context, []address.ProtocolID, kad.NodeID[K],
+		kad.Message, kad.Message, time.Duration,
+		NegotiatedResponseHandlerFn[K, A]) error
+}
+
+// ProtocolCheckingEndpoint is an endpoint knowing which protocols remote nodes handle requests
+// for, as the nodes advertise them. Nodes in client mode serve no protocol, so they can be told
+// apart from the nodes serving the DHT and left out of the responses to other nodes.
+type ProtocolCheckingEndpoint[K kad.Key[K], A kad.Address[A]] interface {
+	Endpoint[K, A]
+	// PeerSupportsProtocol reports whether the node id handles requests for protoID. Nodes whose
+	// protocols are not known yet are assumed to handle it.
+	PeerSupportsProtocol(id kad.NodeID[K], protoID address.ProtocolID) bool
+}
+
+// Routable reports whether id serves protoID according to ep, or true if ep does not know the
+// protocols of remote nodes.
+func Routable[K kad.Key[K], A kad.Address[A]](ep Endpoint[K, A], id kad.NodeID[K], protoID address.ProtocolID) bool {
+	pe, ok := ep.(ProtocolCheckingEndpoint[K, A])
+	return !ok || pe.PeerSupportsProtocol(id, protoID)
+}
+
 // StreamID is a unique identifier for a stream.
 type StreamID uint64
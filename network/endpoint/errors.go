@@ -2,12 +2,101 @@ package endpoint
 
 import "errors"
 
+// Classes of network failures. The errors returned by endpoints, and passed to response
+// handlers, belong to at most one class, tested with errors.Is or obtained with Classify.
 var (
-	ErrCannotConnect                = errors.New("cannot connect")
+	ErrDialFailure      = errors.New("dial failure")               // a connection to the peer could not be established
+	ErrTimeout          = errors.New("request timeout")            // the peer did not answer in time
+	ErrProtocolMismatch = errors.New("protocol mismatch")          // the peer does not speak the requested protocol
+	ErrPeerReset        = errors.New("connection reset by peer")   // the peer closed the stream or connection before answering
+	ErrGarbageResponse  = errors.New("garbage response from peer") // the peer answered with a response that could not be used
+)
+
+var (
+	ErrCannotConnect                = classError(ErrDialFailure, "cannot connect")
 	ErrUnknownPeer                  = errors.New("unknown peer")
 	ErrInvalidPeer                  = errors.New("invalid peer")
-	ErrTimeout                      = errors.New("request timeout")
 	ErrNilRequestHandler            = errors.New("nil request handler")
 	ErrNilResponseHandler           = errors.New("nil response handler")
+	ErrNilMessageHandler            = errors.New("nil message handler")
 	ErrResponseReceivedAfterTimeout = errors.New("response received after timeout")
+	ErrThrottled                    = errors.New("request throttled by remote peer")
+	ErrQueueFull                    = errors.New("outbound request queue is full")
+	ErrMessageTooLarge              = errors.New("message exceeds maximum size")
+	ErrTooManyCloserNodes           = classError(ErrGarbageResponse, "response carries too many closer nodes")
+	ErrMalformedMessage             = classError(ErrGarbageResponse, "malformed message")
+	ErrDialBackoff                  = classError(ErrDialFailure, "dial backoff")
+	ErrStreamAborted                = classError(ErrPeerReset, "response stream aborted by remote peer")
+	ErrProtocolNotSupported         = classError(ErrProtocolMismatch, "protocol not supported by remote peer")
 )
+
+// FailureClass is the class of a network failure.
+type FailureClass int
+
+const (
+	FailureNone             FailureClass = iota // no failure
+	FailureOther                                // a failure that belongs to no class
+	FailureDial                                 // ErrDialFailure
+	FailureTimeout                              // ErrTimeout
+	FailureProtocolMismatch                     // ErrProtocolMismatch
+	FailurePeerReset                            // ErrPeerReset
+	FailureGarbageResponse                      // ErrGarbageResponse
+)
+
+var failureClasses = []struct {
+	class FailureClass
+	err   error
+}{
+	{FailureDial, ErrDialFailure},
+	{FailureTimeout, ErrTimeout},
+	{FailureProtocolMismatch, ErrProtocolMismatch},
+	{FailurePeerReset, ErrPeerReset},
+	{FailureGarbageResponse, ErrGarbageResponse},
+}
+
+// Classify returns the class of the network failure err, FailureNone if err is nil.
+func Classify(err error) FailureClass {
+	if err == nil {
+		return FailureNone
+	}
+	for _, fc := range failureClasses {
+		if errors.Is(err, fc.err) {
+			return fc.class
+		}
+	}
+	return FailureOther
+}
+
+func (c FailureClass) String() string {
+	switch c {
+	case FailureNone:
+		return "none"
+	case FailureDial:
+		return "dial"
+	case FailureTimeout:
+		return "timeout"
+	case FailureProtocolMismatch:
+		return "protocol mismatch"
+	case FailurePeerReset:
+		return "peer reset"
+	case FailureGarbageResponse:
+		return "garbage response"
+	default:
+		return "other"
+	}
+}
+
+// classedError is an error of a failure class, keeping its own message.
+type classedError struct {
+	msg   string
+	class error
+}
+
+// classError returns a new error with message msg, belonging to class.
+func classError(class error, msg string) error {
+	return &classedError{msg: msg, class: class}
+}
+
+func (e *classedError) Error() string { return e.msg }
+
+func (e *classedError) Unwrap() error { return e.class }
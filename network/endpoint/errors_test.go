@@ -0,0 +1,39 @@
+package endpoint
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err   error
+		class FailureClass
+	}{
+		{nil, FailureNone},
+		{errors.New("other"), FailureOther},
+		{ErrUnknownPeer, FailureOther},
+		{ErrCannotConnect, FailureDial},
+		{ErrDialBackoff, FailureDial},
+		{ErrTimeout, FailureTimeout},
+		{ErrProtocolNotSupported, FailureProtocolMismatch},
+		{ErrStreamAborted, FailurePeerReset},
+		{ErrMalformedMessage, FailureGarbageResponse},
+		{ErrTooManyCloserNodes, FailureGarbageResponse},
+		{fmt.Errorf("%w: stream reset", ErrPeerReset), FailurePeerReset},
+		{fmt.Errorf("wrapped: %w", ErrDialBackoff), FailureDial},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.class, Classify(tt.err), "%v", tt.err)
+	}
+}
+
+func TestClassErrorKeepsMessage(t *testing.T) {
+	require.Equal(t, "dial backoff", ErrDialBackoff.Error())
+	require.ErrorIs(t, ErrDialBackoff, ErrDialFailure)
+	require.NotErrorIs(t, ErrDialBackoff, ErrCannotConnect)
+	require.Equal(t, "dial", FailureDial.String())
+}
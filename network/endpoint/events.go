@@ -0,0 +1,111 @@
+package endpoint
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/plprobelab/go-kademlia/kad"
+)
+
+// ConnEvent is an event about the connection of the local node with a remote node.
+type ConnEvent[K kad.Key[K]] interface {
+	// Peer returns the remote node the event is about.
+	Peer() kad.NodeID[K]
+	connEvent()
+}
+
+// EventConnected is emitted when the local node establishes a connection with a remote node
+// it was not connected to.
+type EventConnected[K kad.Key[K]] struct {
+	NodeID kad.NodeID[K] // the node the local node connected to
+}
+
+// EventDisconnected is emitted when the last connection of the local node with a remote node
+// is closed.
+type EventDisconnected[K kad.Key[K]] struct {
+	NodeID kad.NodeID[K] // the node the local node disconnected from
+}
+
+// EventDialFailed is emitted when the local node fails to dial a remote node.
+type EventDialFailed[K kad.Key[K]] struct {
+	NodeID kad.NodeID[K] // the node that could not be dialled
+	Error  error         // the error that caused the failure
+}
+
+func (e *EventConnected[K]) Peer() kad.NodeID[K]    { return e.NodeID }
+func (e *EventDisconnected[K]) Peer() kad.NodeID[K] { return e.NodeID }
+func (e *EventDialFailed[K]) Peer() kad.NodeID[K]   { return e.NodeID }
+
+// connEvent() ensures that only connection events can be assigned to a ConnEvent.
+func (*EventConnected[K]) connEvent()    {}
+func (*EventDisconnected[K]) connEvent() {}
+func (*EventDialFailed[K]) connEvent()   {}
+
+// ConnEventBus delivers the connection events emitted by an endpoint to its subscribers. Emit
+// never blocks: an event is dropped for a subscriber whose buffer is full. It is safe for
+// concurrent use.
+type ConnEventBus[K kad.Key[K]] struct {
+	mu   sync.Mutex
+	subs map[*ConnSubscription[K]]struct{}
+}
+
+// NewConnEventBus returns a ConnEventBus without subscribers.
+func NewConnEventBus[K kad.Key[K]]() *ConnEventBus[K] {
+	return &ConnEventBus[K]{
+		subs: make(map[*ConnSubscription[K]]struct{}),
+	}
+}
+
+// Subscribe returns a subscription receiving the events emitted from now on, buffering up to
+// size events that have not been received.
+func (b *ConnEventBus[K]) Subscribe(size int) *ConnSubscription[K] {
+	s := &ConnSubscription[K]{
+		bus: b,
+		ch:  make(chan ConnEvent[K], size),
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[s] = struct{}{}
+	return s
+}
+
+// Emit delivers ev to all subscribers.
+func (b *ConnEventBus[K]) Emit(ev ConnEvent[K]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		select {
+		case s.ch <- ev:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// ConnSubscription is a subscription to the events of a ConnEventBus.
+type ConnSubscription[K kad.Key[K]] struct {
+	bus     *ConnEventBus[K]
+	ch      chan ConnEvent[K]
+	dropped atomic.Int64
+}
+
+// Events returns the channel the events are delivered on. It is closed by Close.
+func (s *ConnSubscription[K]) Events() <-chan ConnEvent[K] {
+	return s.ch
+}
+
+// Dropped returns the number of events dropped because the buffer of the subscription was full.
+func (s *ConnSubscription[K]) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close ends the subscription and closes its channel.
+func (s *ConnSubscription[K]) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subs[s]; !ok {
+		return
+	}
+	delete(s.bus.subs, s)
+	close(s.ch)
+}
@@ -0,0 +1,49 @@
+package endpoint
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+func TestConnEventBus(t *testing.T) {
+	bus := NewConnEventBus[key.Key8]()
+	a := kadtest.NewID(key.Key8(1))
+	b := kadtest.NewID(key.Key8(2))
+
+	// events emitted without subscribers are discarded
+	bus.Emit(&EventConnected[key.Key8]{NodeID: a})
+
+	s1 := bus.Subscribe(2)
+	s2 := bus.Subscribe(1)
+
+	dialErr := errors.New("dial failed")
+	bus.Emit(&EventConnected[key.Key8]{NodeID: a})
+	bus.Emit(&EventDialFailed[key.Key8]{NodeID: b, Error: dialErr})
+
+	ev := <-s1.Events()
+	require.Equal(t, &EventConnected[key.Key8]{NodeID: a}, ev)
+	require.Equal(t, a, ev.Peer())
+	ev = <-s1.Events()
+	require.Equal(t, &EventDialFailed[key.Key8]{NodeID: b, Error: dialErr}, ev)
+	require.Equal(t, int64(0), s1.Dropped())
+
+	// the second event doesn't fit in the buffer of s2
+	ev = <-s2.Events()
+	require.Equal(t, &EventConnected[key.Key8]{NodeID: a}, ev)
+	require.Equal(t, int64(1), s2.Dropped())
+
+	// a closed subscription receives no more events
+	s2.Close()
+	s2.Close()
+	_, ok := <-s2.Events()
+	require.False(t, ok)
+
+	bus.Emit(&EventDisconnected[key.Key8]{NodeID: a})
+	ev = <-s1.Events()
+	require.Equal(t, &EventDisconnected[key.Key8]{NodeID: a}, ev)
+}
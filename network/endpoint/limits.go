@@ -0,0 +1,108 @@
+package endpoint
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+)
+
+// MessageLimits bounds the messages an endpoint sends and accepts.
+type MessageLimits struct {
+	MaxRequestSize  int // the maximum size in bytes of an encoded request sent or received
+	MaxResponseSize int // the maximum size in bytes of an encoded response sent or received
+	MaxCloserNodes  int // the maximum number of closer nodes a received response may carry
+}
+
+// Validate checks the limits and returns an error if any have invalid values.
+func (l *MessageLimits) Validate() error {
+	if l.MaxRequestSize < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "MessageLimits",
+			Err:       fmt.Errorf("max request size must be greater than zero"),
+		}
+	}
+
+	if l.MaxResponseSize < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "MessageLimits",
+			Err:       fmt.Errorf("max response size must be greater than zero"),
+		}
+	}
+
+	if l.MaxCloserNodes < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "MessageLimits",
+			Err:       fmt.Errorf("max closer nodes must be greater than zero"),
+		}
+	}
+
+	return nil
+}
+
+// DefaultMessageLimits returns limits allowing messages of up to maxSize bytes carrying at most
+// 100 closer nodes, five times the bucket size of the IPFS DHT.
+func DefaultMessageLimits(maxSize int) MessageLimits {
+	return MessageLimits{
+		MaxRequestSize:  maxSize,
+		MaxResponseSize: maxSize,
+		MaxCloserNodes:  100,
+	}
+}
+
+// CheckSize returns an error wrapping ErrMessageTooLarge if size exceeds limit.
+func CheckSize(size, limit int) error {
+	if size > limit {
+		return fmt.Errorf("%w: %d bytes exceeds limit of %d", ErrMessageTooLarge, size, limit)
+	}
+	return nil
+}
+
+// CheckCloserNodes returns an error wrapping ErrTooManyCloserNodes if resp carries more closer
+// nodes than allowed by l.
+func CheckCloserNodes[K kad.Key[K], A kad.Address[A]](l MessageLimits, resp kad.Response[K, A]) error {
+	if n := len(resp.CloserNodes()); n > l.MaxCloserNodes {
+		return fmt.Errorf("%w: %d nodes exceeds limit of %d", ErrTooManyCloserNodes, n, l.MaxCloserNodes)
+	}
+	return nil
+}
+
+// MessageStats holds the numbers of messages an endpoint rejected.
+type MessageStats struct {
+	OversizedRequests  int64 // requests not sent or not handled because they exceeded MaxRequestSize
+	OversizedResponses int64 // responses not sent or not accepted because they exceeded MaxResponseSize
+	TooManyCloserNodes int64 // responses not accepted because they exceeded MaxCloserNodes
+	Malformed          int64 // received messages that could not be decoded
+}
+
+// MessageCounters counts the messages an endpoint rejected. It is safe for concurrent use and
+// its zero value is ready to use.
+type MessageCounters struct {
+	oversizedRequests  atomic.Int64
+	oversizedResponses atomic.Int64
+	tooManyCloserNodes atomic.Int64
+	malformed          atomic.Int64
+}
+
+// CountOversizedRequest counts a request rejected by MaxRequestSize.
+func (c *MessageCounters) CountOversizedRequest() { c.oversizedRequests.Add(1) }
+
+// CountOversizedResponse counts a response rejected by MaxResponseSize.
+func (c *MessageCounters) CountOversizedResponse() { c.oversizedResponses.Add(1) }
+
+// CountTooManyCloserNodes counts a response rejected by MaxCloserNodes.
+func (c *MessageCounters) CountTooManyCloserNodes() { c.tooManyCloserNodes.Add(1) }
+
+// CountMalformed counts a message that could not be decoded.
+func (c *MessageCounters) CountMalformed() { c.malformed.Add(1) }
+
+// Stats returns the current counts.
+func (c *MessageCounters) Stats() MessageStats {
+	return MessageStats{
+		OversizedRequests:  c.oversizedRequests.Load(),
+		OversizedResponses: c.oversizedResponses.Load(),
+		TooManyCloserNodes: c.tooManyCloserNodes.Load(),
+		Malformed:          c.malformed.Load(),
+	}
+}
@@ -0,0 +1,79 @@
+package endpoint
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+// closerResponse is a response carrying the given closer nodes.
+type closerResponse []kad.NodeInfo[key.Key8, net.IP]
+
+func (r closerResponse) CloserNodes() []kad.NodeInfo[key.Key8, net.IP] {
+	return r
+}
+
+func TestMessageLimitsValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		l := DefaultMessageLimits(1024)
+		require.NoError(t, l.Validate())
+	})
+
+	t.Run("max request size positive", func(t *testing.T) {
+		l := DefaultMessageLimits(1024)
+		l.MaxRequestSize = 0
+		require.Error(t, l.Validate())
+	})
+
+	t.Run("max response size positive", func(t *testing.T) {
+		l := DefaultMessageLimits(1024)
+		l.MaxResponseSize = 0
+		require.Error(t, l.Validate())
+	})
+
+	t.Run("max closer nodes positive", func(t *testing.T) {
+		l := DefaultMessageLimits(1024)
+		l.MaxCloserNodes = 0
+		require.Error(t, l.Validate())
+	})
+}
+
+func TestCheckSize(t *testing.T) {
+	require.NoError(t, CheckSize(10, 10))
+	require.ErrorIs(t, CheckSize(11, 10), ErrMessageTooLarge)
+}
+
+func TestCheckCloserNodes(t *testing.T) {
+	l := DefaultMessageLimits(1024)
+	l.MaxCloserNodes = 1
+
+	a := kadtest.NewInfo[key.Key8, net.IP](kadtest.NewID(key.Key8(1)), nil)
+	b := kadtest.NewInfo[key.Key8, net.IP](kadtest.NewID(key.Key8(2)), nil)
+
+	require.NoError(t, CheckCloserNodes[key.Key8, net.IP](l, closerResponse{}))
+	require.NoError(t, CheckCloserNodes[key.Key8, net.IP](l, closerResponse{a}))
+	require.ErrorIs(t, CheckCloserNodes[key.Key8, net.IP](l, closerResponse{a, b}), ErrTooManyCloserNodes)
+}
+
+func TestMessageCounters(t *testing.T) {
+	var c MessageCounters
+	require.Equal(t, MessageStats{}, c.Stats())
+
+	c.CountOversizedRequest()
+	c.CountOversizedResponse()
+	c.CountOversizedResponse()
+	c.CountTooManyCloserNodes()
+	c.CountMalformed()
+
+	require.Equal(t, MessageStats{
+		OversizedRequests:  1,
+		OversizedResponses: 2,
+		TooManyCloserNodes: 1,
+		Malformed:          1,
+	}, c.Stats())
+}
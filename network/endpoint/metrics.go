@@ -0,0 +1,169 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/kad"
+)
+
+// PeerMetrics holds the latency and reliability of a remote peer as observed by an endpoint.
+type PeerMetrics struct {
+	Successes    int           // the number of requests the peer answered
+	Failures     int           // the number of requests that failed, including timeouts
+	LastRTT      time.Duration // the round trip time of the last request the peer answered
+	SmoothedRTT  time.Duration // the exponentially weighted moving average of round trip times
+	RTTVariation time.Duration // the exponentially weighted mean deviation of round trip times
+	LastSuccess  time.Time     // the time the peer last answered a request
+	LastFailure  time.Time     // the time a request to the peer last failed
+}
+
+// Reliability returns the fraction of requests that the peer answered, or zero if no request
+// has completed.
+func (m PeerMetrics) Reliability() float64 {
+	total := m.Successes + m.Failures
+	if total == 0 {
+		return 0
+	}
+	return float64(m.Successes) / float64(total)
+}
+
+// Timeout returns a request timeout suited to the peer, computed from its round trip times as
+// TCP computes its retransmission timeout (RFC 6298), or def if the peer never answered.
+func (m PeerMetrics) Timeout(def time.Duration) time.Duration {
+	if m.Successes == 0 {
+		return def
+	}
+	return m.SmoothedRTT + 4*m.RTTVariation
+}
+
+// MetricsEndpoint is an endpoint recording the latency and reliability of the remote peers it
+// sends requests to.
+type MetricsEndpoint[K kad.Key[K], A kad.Address[A]] interface {
+	Endpoint[K, A]
+	// Metrics returns the metrics recorded for the given peer, and false if no request to the
+	// peer has completed.
+	Metrics(kad.NodeID[K]) (PeerMetrics, bool)
+}
+
+// PeerMetricsRecorder records the metrics of remote peers. It is safe for concurrent use.
+type PeerMetricsRecorder struct {
+	clk clock.Clock
+
+	mu    sync.Mutex
+	peers map[string]*PeerMetrics
+}
+
+// NewPeerMetricsRecorder returns a recorder timing requests with clk.
+func NewPeerMetricsRecorder(clk clock.Clock) *PeerMetricsRecorder {
+	return &PeerMetricsRecorder{
+		clk:   clk,
+		peers: make(map[string]*PeerMetrics),
+	}
+}
+
+// RecordSuccess records that peer answered a request after rtt.
+func (r *PeerMetricsRecorder) RecordSuccess(peer string, rtt time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m := r.get(peer)
+	if m.Successes == 0 {
+		m.SmoothedRTT, m.RTTVariation = rtt, rtt/2
+	} else {
+		// RFC 6298 gains: 1/8 for the average and 1/4 for the deviation
+		dev := m.SmoothedRTT - rtt
+		if dev < 0 {
+			dev = -dev
+		}
+		m.RTTVariation += (dev - m.RTTVariation) / 4
+		m.SmoothedRTT += (rtt - m.SmoothedRTT) / 8
+	}
+	m.Successes++
+	m.LastRTT = rtt
+	m.LastSuccess = r.clk.Now()
+}
+
+// RecordFailure records that a request to peer failed.
+func (r *PeerMetricsRecorder) RecordFailure(peer string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m := r.get(peer)
+	m.Failures++
+	m.LastFailure = r.clk.Now()
+}
+
+// get returns the metrics of peer, creating them if needed. r.mu must be held.
+func (r *PeerMetricsRecorder) get(peer string) *PeerMetrics {
+	m, ok := r.peers[peer]
+	if !ok {
+		m = &PeerMetrics{}
+		r.peers[peer] = m
+	}
+	return m
+}
+
+// Metrics returns the metrics recorded for peer, and false if none were.
+func (r *PeerMetricsRecorder) Metrics(peer string) (PeerMetrics, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.peers[peer]
+	if !ok {
+		return PeerMetrics{}, false
+	}
+	return *m, true
+}
+
+// Forget removes the metrics recorded for peer.
+func (r *PeerMetricsRecorder) Forget(peer string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.peers, peer)
+}
+
+// Track returns a response handler recording the outcome of a request sent to peer now, then
+// calling fn. A response is recorded as a success with the time elapsed since Track was called,
+// and any error as a failure.
+func Track[K kad.Key[K], A kad.Address[A]](r *PeerMetricsRecorder, peer string, fn ResponseHandlerFn[K, A]) ResponseHandlerFn[K, A] {
+	start := r.clk.Now()
+	return func(ctx context.Context, resp kad.Response[K, A], err error) {
+		if err != nil {
+			r.RecordFailure(peer)
+		} else {
+			r.RecordSuccess(peer, r.clk.Since(start))
+		}
+		if fn != nil {
+			fn(ctx, resp, err)
+		}
+	}
+}
+
+// AdaptiveTimeout returns a function giving the timeout of a request to a node from the metrics
+// of the node, bounded by lo and hi. Nodes without metrics are given hi. It may be used as the
+// RequestTimeoutFunc of a query.
+func AdaptiveTimeout[K kad.Key[K], A kad.Address[A]](ep MetricsEndpoint[K, A], lo, hi time.Duration) func(fmt.Stringer) time.Duration {
+	return func(node fmt.Stringer) time.Duration {
+		id, ok := node.(kad.NodeID[K])
+		if !ok {
+			return hi
+		}
+		m, ok := ep.Metrics(id)
+		if !ok {
+			return hi
+		}
+		t := m.Timeout(hi)
+		if t < lo {
+			return lo
+		}
+		if t > hi {
+			return hi
+		}
+		return t
+	}
+}
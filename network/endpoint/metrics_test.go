@@ -0,0 +1,107 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+func TestPeerMetricsRecorder(t *testing.T) {
+	clk := clock.NewMock()
+	r := NewPeerMetricsRecorder(clk)
+
+	_, ok := r.Metrics("a")
+	require.False(t, ok)
+
+	r.RecordSuccess("a", 100*time.Millisecond)
+	m, ok := r.Metrics("a")
+	require.True(t, ok)
+	require.Equal(t, 1, m.Successes)
+	require.Equal(t, 100*time.Millisecond, m.LastRTT)
+	require.Equal(t, 100*time.Millisecond, m.SmoothedRTT)
+	require.Equal(t, 50*time.Millisecond, m.RTTVariation)
+	require.Equal(t, clk.Now(), m.LastSuccess)
+	// the first sample gives a timeout of three times the round trip time
+	require.Equal(t, 300*time.Millisecond, m.Timeout(time.Minute))
+
+	r.RecordSuccess("a", 180*time.Millisecond)
+	m, _ = r.Metrics("a")
+	require.Equal(t, 180*time.Millisecond, m.LastRTT)
+	require.Equal(t, 110*time.Millisecond, m.SmoothedRTT)
+	require.Equal(t, 57500*time.Microsecond, m.RTTVariation)
+
+	clk.Add(time.Second)
+	r.RecordFailure("a")
+	m, _ = r.Metrics("a")
+	require.Equal(t, 1, m.Failures)
+	require.Equal(t, clk.Now(), m.LastFailure)
+	require.InDelta(t, 2.0/3.0, m.Reliability(), 1e-9)
+
+	r.Forget("a")
+	_, ok = r.Metrics("a")
+	require.False(t, ok)
+}
+
+func TestPeerMetricsDefaults(t *testing.T) {
+	require.Equal(t, 0.0, PeerMetrics{}.Reliability())
+	require.Equal(t, time.Minute, PeerMetrics{}.Timeout(time.Minute))
+	require.Equal(t, time.Minute, PeerMetrics{Failures: 2}.Timeout(time.Minute))
+}
+
+func TestTrack(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	r := NewPeerMetricsRecorder(clk)
+
+	var called int
+	h := Track[key.Key8, net.IP](r, "a", func(context.Context, kad.Response[key.Key8, net.IP], error) {
+		called++
+	})
+	clk.Add(20 * time.Millisecond)
+	h(ctx, nil, nil)
+
+	Track[key.Key8, net.IP](r, "a", nil)(ctx, nil, errors.New("failed"))
+
+	require.Equal(t, 1, called)
+	m, _ := r.Metrics("a")
+	require.Equal(t, 1, m.Successes)
+	require.Equal(t, 1, m.Failures)
+	require.Equal(t, 20*time.Millisecond, m.LastRTT)
+}
+
+// metricsEndpoint is a MetricsEndpoint serving the metrics of a recorder.
+type metricsEndpoint struct {
+	handlerEndpoint
+	r *PeerMetricsRecorder
+}
+
+func (e *metricsEndpoint) Metrics(id kad.NodeID[key.Key8]) (PeerMetrics, bool) {
+	return e.r.Metrics(id.String())
+}
+
+func TestAdaptiveTimeout(t *testing.T) {
+	r := NewPeerMetricsRecorder(clock.NewMock())
+	timeout := AdaptiveTimeout[key.Key8, net.IP](&metricsEndpoint{r: r}, time.Second, time.Minute)
+
+	fast := kadtest.NewID(key.Key8(1))
+	slow := kadtest.NewID(key.Key8(2))
+	average := kadtest.NewID(key.Key8(3))
+	unknown := kadtest.NewID(key.Key8(4))
+	r.RecordSuccess(fast.String(), time.Millisecond)
+	r.RecordSuccess(slow.String(), time.Hour)
+	r.RecordSuccess(average.String(), 2*time.Second)
+
+	require.Equal(t, time.Second, timeout(fast))
+	require.Equal(t, time.Minute, timeout(slow))
+	require.Equal(t, 6*time.Second, timeout(average))
+	require.Equal(t, time.Minute, timeout(unknown))
+}
@@ -0,0 +1,50 @@
+package endpoint
+
+import (
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/network/address"
+)
+
+// Middleware wraps a request handler with behaviour shared by several handlers, such as logging,
+// metrics or authorization. It returns a handler that usually calls next, but may also answer or
+// reject the request itself.
+type Middleware[K kad.Key[K]] func(next RequestHandlerFn[K]) RequestHandlerFn[K]
+
+// Chain wraps h with mws. The first middleware is the outermost one, it sees the request first
+// and the response last.
+func Chain[K kad.Key[K]](h RequestHandlerFn[K], mws ...Middleware[K]) RequestHandlerFn[K] {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// MiddlewareEndpoint is a ServerEndpoint wrapping the request handlers added to it with
+// middleware before adding them to the endpoint it wraps.
+type MiddlewareEndpoint[K kad.Key[K], A kad.Address[A]] struct {
+	ServerEndpoint[K, A]
+	mws []Middleware[K]
+}
+
+// WithMiddleware returns an endpoint that wraps the request handlers added to it with mws, in the
+// order given by Chain, before adding them to e. Requests are still sent directly through e.
+func WithMiddleware[K kad.Key[K], A kad.Address[A]](e ServerEndpoint[K, A], mws ...Middleware[K]) *MiddlewareEndpoint[K, A] {
+	return &MiddlewareEndpoint[K, A]{
+		ServerEndpoint: e,
+		mws:            mws,
+	}
+}
+
+// AddRequestHandler adds reqHandler, wrapped with the middleware of the endpoint, to the
+// wrapped endpoint.
+func (e *MiddlewareEndpoint[K, A]) AddRequestHandler(protoID address.ProtocolID, req kad.Message, reqHandler RequestHandlerFn[K]) error {
+	if reqHandler == nil {
+		return ErrNilRequestHandler
+	}
+	return e.ServerEndpoint.AddRequestHandler(protoID, req, Chain(reqHandler, e.mws...))
+}
+
+// Unwrap returns the wrapped endpoint, for instance to reach methods of a NetworkedEndpoint.
+func (e *MiddlewareEndpoint[K, A]) Unwrap() ServerEndpoint[K, A] {
+	return e.ServerEndpoint
+}
@@ -0,0 +1,122 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+)
+
+var _ ServerEndpoint[key.Key8, net.IP] = (*MiddlewareEndpoint[key.Key8, net.IP])(nil)
+
+// handlerEndpoint is a ServerEndpoint that only records its request handlers.
+type handlerEndpoint struct {
+	handlers map[address.ProtocolID]RequestHandlerFn[key.Key8]
+}
+
+func (e *handlerEndpoint) MaybeAddToPeerstore(context.Context, kad.NodeInfo[key.Key8, net.IP], time.Duration) error {
+	return nil
+}
+
+func (e *handlerEndpoint) SendRequestHandleResponse(context.Context, address.ProtocolID, kad.NodeID[key.Key8],
+	kad.Message, kad.Message, time.Duration, ResponseHandlerFn[key.Key8, net.IP],
+) error {
+	return nil
+}
+
+func (e *handlerEndpoint) NetworkAddress(kad.NodeID[key.Key8]) (kad.NodeInfo[key.Key8, net.IP], error) {
+	return nil, ErrUnknownPeer
+}
+
+func (e *handlerEndpoint) AddRequestHandler(protoID address.ProtocolID, req kad.Message, h RequestHandlerFn[key.Key8]) error {
+	e.handlers[protoID] = h
+	return nil
+}
+
+func (e *handlerEndpoint) RemoveRequestHandler(protoID address.ProtocolID) {
+	delete(e.handlers, protoID)
+}
+
+// tagMiddleware appends tag to the trace before and after calling the next handler.
+func tagMiddleware(trace *[]string, tag string) Middleware[key.Key8] {
+	return func(next RequestHandlerFn[key.Key8]) RequestHandlerFn[key.Key8] {
+		return func(ctx context.Context, id kad.NodeID[key.Key8], req kad.Message) (kad.Message, error) {
+			*trace = append(*trace, tag+" in")
+			resp, err := next(ctx, id, req)
+			*trace = append(*trace, tag+" out")
+			return resp, err
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	var trace []string
+	h := func(ctx context.Context, id kad.NodeID[key.Key8], req kad.Message) (kad.Message, error) {
+		trace = append(trace, "handler")
+		return req, nil
+	}
+
+	chained := Chain[key.Key8](h, tagMiddleware(&trace, "a"), tagMiddleware(&trace, "b"))
+	resp, err := chained(context.Background(), kadtest.NewID(key.Key8(1)), "req")
+	require.NoError(t, err)
+	require.Equal(t, "req", resp)
+	require.Equal(t, []string{"a in", "b in", "handler", "b out", "a out"}, trace)
+
+	// without middleware the handler is unchanged
+	trace = nil
+	_, err = Chain[key.Key8](h)(context.Background(), kadtest.NewID(key.Key8(1)), "req")
+	require.NoError(t, err)
+	require.Equal(t, []string{"handler"}, trace)
+}
+
+func TestWithMiddleware(t *testing.T) {
+	ctx := context.Background()
+	inner := &handlerEndpoint{handlers: make(map[address.ProtocolID]RequestHandlerFn[key.Key8])}
+
+	errDenied := errors.New("denied")
+	deny := func(next RequestHandlerFn[key.Key8]) RequestHandlerFn[key.Key8] {
+		return func(ctx context.Context, id kad.NodeID[key.Key8], req kad.Message) (kad.Message, error) {
+			if key.Equal(id.Key(), key.Key8(0)) {
+				return nil, errDenied
+			}
+			return next(ctx, id, req)
+		}
+	}
+
+	var trace []string
+	e := WithMiddleware[key.Key8, net.IP](inner, deny, tagMiddleware(&trace, "a"))
+	require.Equal(t, ServerEndpoint[key.Key8, net.IP](inner), e.Unwrap())
+
+	require.ErrorIs(t, e.AddRequestHandler("/test", nil, nil), ErrNilRequestHandler)
+
+	err := e.AddRequestHandler("/test", nil, func(ctx context.Context, id kad.NodeID[key.Key8], req kad.Message) (kad.Message, error) {
+		return req, nil
+	})
+	require.NoError(t, err)
+
+	// the handler registered on the inner endpoint is wrapped
+	h := inner.handlers["/test"]
+	require.NotNil(t, h)
+
+	resp, err := h(ctx, kadtest.NewID(key.Key8(1)), "req")
+	require.NoError(t, err)
+	require.Equal(t, "req", resp)
+	require.Equal(t, []string{"a in", "a out"}, trace)
+
+	// the middleware may reject requests before they reach the handler
+	_, err = h(ctx, kadtest.NewID(key.Key8(0)), "req")
+	require.ErrorIs(t, err, errDenied)
+	require.Len(t, trace, 2)
+
+	// handlers are removed from the inner endpoint
+	e.RemoveRequestHandler("/test")
+	require.Empty(t, inner.handlers)
+}
@@ -0,0 +1,46 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/network/address"
+)
+
+// SendRequestWithFallback sends a request to the given peer with the first of protoIDs, in
+// order of preference, that the peer supports, and handles the response with the given handler
+// along with the protocol used. Endpoints implementing NegotiatingEndpoint negotiate the
+// protocol themselves. With other endpoints the request is sent with each protocol in turn,
+// falling back to the next one as long as the response handler is passed an error wrapping
+// ErrProtocolNotSupported.
+func SendRequestWithFallback[K kad.Key[K], A kad.Address[A]](ctx context.Context, ep Endpoint[K, A],
+	protoIDs []address.ProtocolID, id kad.NodeID[K], req kad.Message, resp kad.Message,
+	timeout time.Duration, handleResp NegotiatedResponseHandlerFn[K, A],
+) error {
+	if handleResp == nil {
+		return ErrNilResponseHandler
+	}
+	if len(protoIDs) == 0 {
+		return ErrProtocolNotSupported
+	}
+	if ne, ok := ep.(NegotiatingEndpoint[K, A]); ok {
+		return ne.SendRequestNegotiate(ctx, protoIDs, id, req, resp, timeout, handleResp)
+	}
+
+	var send func(ctx context.Context, i int) error
+	send = func(ctx context.Context, i int) error {
+		return ep.SendRequestHandleResponse(ctx, protoIDs[i], id, req, resp, timeout,
+			func(ctx context.Context, r kad.Response[K, A], err error) {
+				if errors.Is(err, ErrProtocolNotSupported) && i+1 < len(protoIDs) {
+					if err := send(ctx, i+1); err != nil {
+						handleResp(ctx, "", nil, err)
+					}
+					return
+				}
+				handleResp(ctx, protoIDs[i], r, err)
+			})
+	}
+	return send(ctx, 0)
+}
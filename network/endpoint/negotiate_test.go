@@ -0,0 +1,86 @@
+package endpoint
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+)
+
+// protocolsEndpoint is an Endpoint whose remote peers only support some protocols. Requests
+// are answered synchronously.
+type protocolsEndpoint struct {
+	handlerEndpoint
+	supported map[address.ProtocolID]bool
+	sent      []address.ProtocolID
+}
+
+func (e *protocolsEndpoint) SendRequestHandleResponse(ctx context.Context, protoID address.ProtocolID, id kad.NodeID[key.Key8],
+	req kad.Message, resp kad.Message, timeout time.Duration, handleResp ResponseHandlerFn[key.Key8, net.IP],
+) error {
+	e.sent = append(e.sent, protoID)
+	if !e.supported[protoID] {
+		handleResp(ctx, nil, ErrProtocolNotSupported)
+		return nil
+	}
+	handleResp(ctx, nil, nil)
+	return nil
+}
+
+func TestSendRequestWithFallback(t *testing.T) {
+	ctx := context.Background()
+	id := kadtest.NewID(key.Key8(1))
+	v2 := address.ProtocolID("/test/2.0.0")
+	v1 := address.ProtocolID("/test/1.0.0")
+
+	t.Run("falls back to supported protocol", func(t *testing.T) {
+		ep := &protocolsEndpoint{supported: map[address.ProtocolID]bool{v1: true}}
+		var used address.ProtocolID
+		err := SendRequestWithFallback[key.Key8, net.IP](ctx, ep, []address.ProtocolID{v2, v1}, id, "req", nil, time.Second,
+			func(ctx context.Context, protoID address.ProtocolID, resp kad.Response[key.Key8, net.IP], err error) {
+				require.NoError(t, err)
+				used = protoID
+			})
+		require.NoError(t, err)
+		require.Equal(t, v1, used)
+		require.Equal(t, []address.ProtocolID{v2, v1}, ep.sent)
+	})
+
+	t.Run("preferred protocol is used first", func(t *testing.T) {
+		ep := &protocolsEndpoint{supported: map[address.ProtocolID]bool{v1: true, v2: true}}
+		var used address.ProtocolID
+		err := SendRequestWithFallback[key.Key8, net.IP](ctx, ep, []address.ProtocolID{v2, v1}, id, "req", nil, time.Second,
+			func(ctx context.Context, protoID address.ProtocolID, resp kad.Response[key.Key8, net.IP], err error) {
+				require.NoError(t, err)
+				used = protoID
+			})
+		require.NoError(t, err)
+		require.Equal(t, v2, used)
+		require.Equal(t, []address.ProtocolID{v2}, ep.sent)
+	})
+
+	t.Run("no protocol supported", func(t *testing.T) {
+		ep := &protocolsEndpoint{}
+		var gotErr error
+		err := SendRequestWithFallback[key.Key8, net.IP](ctx, ep, []address.ProtocolID{v2, v1}, id, "req", nil, time.Second,
+			func(ctx context.Context, protoID address.ProtocolID, resp kad.Response[key.Key8, net.IP], err error) {
+				gotErr = err
+				require.Equal(t, v1, protoID)
+			})
+		require.NoError(t, err)
+		require.ErrorIs(t, gotErr, ErrProtocolNotSupported)
+	})
+
+	t.Run("empty protocol list", func(t *testing.T) {
+		err := SendRequestWithFallback[key.Key8, net.IP](ctx, &protocolsEndpoint{}, nil, id, "req", nil, time.Second,
+			func(context.Context, address.ProtocolID, kad.Response[key.Key8, net.IP], error) {})
+		require.ErrorIs(t, err, ErrProtocolNotSupported)
+	})
+}
@@ -0,0 +1,235 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/network/address"
+)
+
+// RequestQueueConfig specifies optional configuration for a QueuedEndpoint
+type RequestQueueConfig struct {
+	MaxInFlight        int // the maximum number of requests awaiting a response across all remote peers
+	MaxInFlightPerPeer int // the maximum number of requests awaiting a response from a single remote peer
+	MaxQueued          int // the maximum number of requests waiting to be sent, zero rejects requests over the in-flight limits
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *RequestQueueConfig) Validate() error {
+	if cfg.MaxInFlight < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "RequestQueueConfig",
+			Err:       fmt.Errorf("max in flight must be greater than zero"),
+		}
+	}
+
+	if cfg.MaxInFlightPerPeer < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "RequestQueueConfig",
+			Err:       fmt.Errorf("max in flight per peer must be greater than zero"),
+		}
+	}
+
+	if cfg.MaxQueued < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "RequestQueueConfig",
+			Err:       fmt.Errorf("max queued must not be negative"),
+		}
+	}
+
+	return nil
+}
+
+// DefaultRequestQueueConfig returns the default configuration options for a QueuedEndpoint.
+// Options may be overridden before passing to WithRequestQueue
+func DefaultRequestQueueConfig() *RequestQueueConfig {
+	return &RequestQueueConfig{
+		MaxInFlight:        64,
+		MaxInFlightPerPeer: 4,
+		MaxQueued:          1024,
+	}
+}
+
+// QueuedEndpoint is an Endpoint bounding the number of requests awaiting a response, per remote
+// peer and overall. Requests over a limit wait in a queue and are sent in the order they were
+// submitted as soon as responses free capacity. Requests submitted while the queue is full are
+// rejected with ErrQueueFull.
+//
+// The timeout of a queued request starts when it is sent to the wrapped endpoint.
+type QueuedEndpoint[K kad.Key[K], A kad.Address[A]] struct {
+	Endpoint[K, A]
+	cfg RequestQueueConfig
+
+	mu       sync.Mutex // guards all fields below
+	inFlight int
+	perPeer  map[string]int
+	queue    []*queuedRequest[K, A]
+}
+
+type queuedRequest[K kad.Key[K], A kad.Address[A]] struct {
+	ctx        context.Context
+	protoID    address.ProtocolID
+	id         kad.NodeID[K]
+	req        kad.Message
+	resp       kad.Message
+	timeout    time.Duration
+	handleResp ResponseHandlerFn[K, A]
+}
+
+// WithRequestQueue returns an endpoint that queues the requests sent through it according to
+// cfg before sending them with e. If cfg is nil, DefaultRequestQueueConfig is used.
+func WithRequestQueue[K kad.Key[K], A kad.Address[A]](e Endpoint[K, A], cfg *RequestQueueConfig) (*QueuedEndpoint[K, A], error) {
+	if cfg == nil {
+		cfg = DefaultRequestQueueConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &QueuedEndpoint[K, A]{
+		Endpoint: e,
+		cfg:      *cfg,
+		perPeer:  make(map[string]int),
+	}, nil
+}
+
+// SendRequestHandleResponse sends the request with the wrapped endpoint if both in-flight limits
+// allow it, and queues it otherwise. ErrQueueFull is returned if the request can neither be sent
+// nor queued. If a queued request fails to be sent, handleResp is called with the error.
+func (e *QueuedEndpoint[K, A]) SendRequestHandleResponse(ctx context.Context,
+	protoID address.ProtocolID, id kad.NodeID[K], req kad.Message,
+	resp kad.Message, timeout time.Duration,
+	handleResp ResponseHandlerFn[K, A],
+) error {
+	if handleResp == nil {
+		return ErrNilResponseHandler
+	}
+	qr := &queuedRequest[K, A]{
+		ctx:        ctx,
+		protoID:    protoID,
+		id:         id,
+		req:        req,
+		resp:       resp,
+		timeout:    timeout,
+		handleResp: handleResp,
+	}
+
+	e.mu.Lock()
+	if !e.hasCapacity(id.String()) {
+		if len(e.queue) >= e.cfg.MaxQueued {
+			e.mu.Unlock()
+			return ErrQueueFull
+		}
+		e.queue = append(e.queue, qr)
+		e.mu.Unlock()
+		return nil
+	}
+	e.acquire(id.String())
+	e.mu.Unlock()
+
+	if err := e.send(qr); err != nil {
+		e.release(id.String())
+		return err
+	}
+	return nil
+}
+
+// InFlight returns the number of requests awaiting a response.
+func (e *QueuedEndpoint[K, A]) InFlight() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.inFlight
+}
+
+// Queued returns the number of requests waiting to be sent.
+func (e *QueuedEndpoint[K, A]) Queued() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.queue)
+}
+
+// Unwrap returns the wrapped endpoint.
+func (e *QueuedEndpoint[K, A]) Unwrap() Endpoint[K, A] {
+	return e.Endpoint
+}
+
+// send sends qr with the wrapped endpoint. The capacity taken by qr is released once its
+// response handler has run, so that requests sent by the handler queue up behind those already
+// waiting.
+func (e *QueuedEndpoint[K, A]) send(qr *queuedRequest[K, A]) error {
+	return e.Endpoint.SendRequestHandleResponse(qr.ctx, qr.protoID, qr.id, qr.req, qr.resp, qr.timeout,
+		func(ctx context.Context, resp kad.Response[K, A], err error) {
+			qr.handleResp(ctx, resp, err)
+			e.release(qr.id.String())
+		})
+}
+
+// release frees the capacity taken by a request to peer and sends the queued requests that fit.
+func (e *QueuedEndpoint[K, A]) release(peer string) {
+	e.mu.Lock()
+	e.free(peer)
+	ready := e.takeReady()
+	e.mu.Unlock()
+
+	for len(ready) > 0 {
+		qr := ready[0]
+		ready = ready[1:]
+
+		err := qr.ctx.Err()
+		if err == nil {
+			err = e.send(qr)
+		}
+		if err == nil {
+			continue
+		}
+		qr.handleResp(qr.ctx, nil, err)
+
+		// the failed request frees its capacity for the requests still queued
+		e.mu.Lock()
+		e.free(qr.id.String())
+		ready = append(ready, e.takeReady()...)
+		e.mu.Unlock()
+	}
+}
+
+// takeReady removes from the queue the requests that fit within the in-flight limits, in the
+// order they were queued, and takes capacity for them. It must be called with mu held.
+func (e *QueuedEndpoint[K, A]) takeReady() []*queuedRequest[K, A] {
+	var ready []*queuedRequest[K, A]
+	remaining := e.queue[:0]
+	for _, qr := range e.queue {
+		if e.hasCapacity(qr.id.String()) {
+			e.acquire(qr.id.String())
+			ready = append(ready, qr)
+		} else {
+			remaining = append(remaining, qr)
+		}
+	}
+	for i := len(remaining); i < len(e.queue); i++ {
+		e.queue[i] = nil
+	}
+	e.queue = remaining
+	return ready
+}
+
+// hasCapacity reports whether a request to peer may be sent. It must be called with mu held.
+func (e *QueuedEndpoint[K, A]) hasCapacity(peer string) bool {
+	return e.inFlight < e.cfg.MaxInFlight && e.perPeer[peer] < e.cfg.MaxInFlightPerPeer
+}
+
+// acquire takes capacity for a request to peer. It must be called with mu held.
+func (e *QueuedEndpoint[K, A]) acquire(peer string) {
+	e.inFlight++
+	e.perPeer[peer]++
+}
+
+// free releases the capacity taken for a request to peer. It must be called with mu held.
+func (e *QueuedEndpoint[K, A]) free(peer string) {
+	e.inFlight--
+	if e.perPeer[peer]--; e.perPeer[peer] == 0 {
+		delete(e.perPeer, peer)
+	}
+}
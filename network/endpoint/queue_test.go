@@ -0,0 +1,183 @@
+package endpoint
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+)
+
+var _ Endpoint[key.Key8, net.IP] = (*QueuedEndpoint[key.Key8, net.IP])(nil)
+
+type sentRequest struct {
+	id         kad.NodeID[key.Key8]
+	req        kad.Message
+	handleResp ResponseHandlerFn[key.Key8, net.IP]
+}
+
+// recordingEndpoint is an Endpoint recording the requests it sends, so that tests can answer them.
+type recordingEndpoint struct {
+	sent    []*sentRequest
+	sendErr error
+}
+
+func (e *recordingEndpoint) MaybeAddToPeerstore(context.Context, kad.NodeInfo[key.Key8, net.IP], time.Duration) error {
+	return nil
+}
+
+func (e *recordingEndpoint) SendRequestHandleResponse(ctx context.Context, protoID address.ProtocolID,
+	id kad.NodeID[key.Key8], req kad.Message, resp kad.Message, timeout time.Duration,
+	handleResp ResponseHandlerFn[key.Key8, net.IP],
+) error {
+	if e.sendErr != nil {
+		return e.sendErr
+	}
+	e.sent = append(e.sent, &sentRequest{id: id, req: req, handleResp: handleResp})
+	return nil
+}
+
+func (e *recordingEndpoint) NetworkAddress(kad.NodeID[key.Key8]) (kad.NodeInfo[key.Key8, net.IP], error) {
+	return nil, ErrUnknownPeer
+}
+
+// answer calls the response handler of the i-th sent request.
+func (e *recordingEndpoint) answer(i int) {
+	e.sent[i].handleResp(context.Background(), nil, nil)
+}
+
+func TestRequestQueueConfigValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		cfg := DefaultRequestQueueConfig()
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("max in flight positive", func(t *testing.T) {
+		cfg := DefaultRequestQueueConfig()
+		cfg.MaxInFlight = 0
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("max in flight per peer positive", func(t *testing.T) {
+		cfg := DefaultRequestQueueConfig()
+		cfg.MaxInFlightPerPeer = 0
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("max queued not negative", func(t *testing.T) {
+		cfg := DefaultRequestQueueConfig()
+		cfg.MaxQueued = 0
+		require.NoError(t, cfg.Validate())
+		cfg.MaxQueued = -1
+		require.Error(t, cfg.Validate())
+	})
+}
+
+func TestQueuedEndpoint(t *testing.T) {
+	ctx := context.Background()
+	inner := &recordingEndpoint{}
+
+	cfg := DefaultRequestQueueConfig()
+	cfg.MaxInFlight = 3
+	cfg.MaxInFlightPerPeer = 2
+	cfg.MaxQueued = 2
+	e, err := WithRequestQueue[key.Key8, net.IP](inner, cfg)
+	require.NoError(t, err)
+
+	a := kadtest.NewID(key.Key8(1))
+	b := kadtest.NewID(key.Key8(2))
+
+	var handled []string
+	send := func(id kad.NodeID[key.Key8], req string) error {
+		return e.SendRequestHandleResponse(ctx, "/test", id, req, nil, time.Second,
+			func(ctx context.Context, resp kad.Response[key.Key8, net.IP], err error) {
+				handled = append(handled, req)
+			})
+	}
+
+	// a2 fills the capacity for a, a3 is queued
+	require.NoError(t, send(a, "a1"))
+	require.NoError(t, send(a, "a2"))
+	require.NoError(t, send(a, "a3"))
+	require.Len(t, inner.sent, 2)
+
+	// b1 fills the endpoint capacity, b2 is queued and the queue is full
+	require.NoError(t, send(b, "b1"))
+	require.NoError(t, send(b, "b2"))
+	require.ErrorIs(t, send(b, "b3"), ErrQueueFull)
+	require.Len(t, inner.sent, 3)
+	require.Equal(t, 3, e.InFlight())
+	require.Equal(t, 2, e.Queued())
+
+	// a response from a sends the queued request to a, which was queued first
+	inner.answer(0)
+	require.Equal(t, []string{"a1"}, handled)
+	require.Len(t, inner.sent, 4)
+	require.Equal(t, "a3", inner.sent[3].req)
+	require.Equal(t, 1, e.Queued())
+
+	// a response from b sends the queued request to b
+	inner.answer(2)
+	require.Len(t, inner.sent, 5)
+	require.Equal(t, "b2", inner.sent[4].req)
+	require.Equal(t, 0, e.Queued())
+
+	inner.answer(1)
+	inner.answer(3)
+	inner.answer(4)
+	require.Equal(t, []string{"a1", "b1", "a2", "a3", "b2"}, handled)
+	require.Equal(t, 0, e.InFlight())
+	require.Empty(t, e.perPeer)
+}
+
+func TestQueuedEndpointSendError(t *testing.T) {
+	ctx := context.Background()
+	inner := &recordingEndpoint{}
+
+	cfg := DefaultRequestQueueConfig()
+	cfg.MaxInFlight = 1
+	e, err := WithRequestQueue[key.Key8, net.IP](inner, cfg)
+	require.NoError(t, err)
+
+	a := kadtest.NewID(key.Key8(1))
+	var errs []error
+	handler := func(ctx context.Context, resp kad.Response[key.Key8, net.IP], err error) {
+		errs = append(errs, err)
+	}
+
+	require.ErrorIs(t, e.SendRequestHandleResponse(ctx, "/test", a, "a0", nil, time.Second, nil), ErrNilResponseHandler)
+
+	// a request that fails to be sent right away returns the error and frees its capacity
+	inner.sendErr = ErrUnknownPeer
+	require.ErrorIs(t, e.SendRequestHandleResponse(ctx, "/test", a, "a1", nil, time.Second, handler), ErrUnknownPeer)
+	require.Equal(t, 0, e.InFlight())
+
+	inner.sendErr = nil
+	require.NoError(t, e.SendRequestHandleResponse(ctx, "/test", a, "a2", nil, time.Second, handler))
+
+	// a queued request that fails to be sent reports the error to its handler
+	require.NoError(t, e.SendRequestHandleResponse(ctx, "/test", a, "a3", nil, time.Second, handler))
+
+	// a queued request whose context is done is not sent
+	cctx, cancel := context.WithCancel(ctx)
+	require.NoError(t, e.SendRequestHandleResponse(cctx, "/test", a, "a4", nil, time.Second, handler))
+	cancel()
+
+	errSend := errors.New("send failed")
+	inner.sendErr = errSend
+	inner.answer(0)
+	require.Len(t, inner.sent, 1)
+	require.Len(t, errs, 3)
+	require.NoError(t, errs[0])
+	require.ErrorIs(t, errs[1], errSend)
+	require.ErrorIs(t, errs[2], context.Canceled)
+	require.Equal(t, 0, e.InFlight())
+	require.Equal(t, 0, e.Queued())
+}
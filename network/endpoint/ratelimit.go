@@ -0,0 +1,166 @@
+package endpoint
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/kaderr"
+)
+
+// RateLimitConfig specifies optional configuration for a RateLimiter
+type RateLimitConfig struct {
+	Clock       clock.Clock // a clock that may replaced by a mock when testing
+	PeerRate    float64     // the number of requests per second each remote peer may send, zero disables the per-peer limit
+	PeerBurst   int         // the number of requests a remote peer may send at once
+	GlobalRate  float64     // the number of requests per second all remote peers together may send, zero disables the global limit
+	GlobalBurst int         // the number of requests all remote peers together may send at once
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *RateLimitConfig) Validate() error {
+	if cfg.Clock == nil {
+		return &kaderr.ConfigurationError{
+			Component: "RateLimitConfig",
+			Err:       fmt.Errorf("clock must not be nil"),
+		}
+	}
+
+	if cfg.PeerRate < 0 || cfg.GlobalRate < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "RateLimitConfig",
+			Err:       fmt.Errorf("rates must not be negative"),
+		}
+	}
+
+	if cfg.PeerRate > 0 && cfg.PeerBurst < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "RateLimitConfig",
+			Err:       fmt.Errorf("peer burst must be greater than zero"),
+		}
+	}
+
+	if cfg.GlobalRate > 0 && cfg.GlobalBurst < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "RateLimitConfig",
+			Err:       fmt.Errorf("global burst must be greater than zero"),
+		}
+	}
+
+	return nil
+}
+
+// DefaultRateLimitConfig returns the default configuration options for a RateLimiter.
+// Options may be overridden before passing to NewRateLimiter
+func DefaultRateLimitConfig() *RateLimitConfig {
+	return &RateLimitConfig{
+		Clock:       clock.New(), // use standard time
+		PeerRate:    10,
+		PeerBurst:   20,
+		GlobalRate:  500,
+		GlobalBurst: 1000,
+	}
+}
+
+// RateLimiter limits the rate of inbound requests per remote peer and across all remote peers
+// with token buckets. A request is allowed only if both the bucket of its sender and the global
+// bucket hold a token, so that requests rejected by the per-peer limit don't use up the global
+// budget of other peers.
+type RateLimiter struct {
+	cfg RateLimitConfig
+
+	mu     sync.Mutex
+	global bucket
+	peers  map[string]*bucket
+}
+
+// bucket is a token bucket, refilled at a constant rate up to its burst size.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a RateLimiter with full buckets. If cfg is nil, DefaultRateLimitConfig
+// is used.
+func NewRateLimiter(cfg *RateLimitConfig) (*RateLimiter, error) {
+	if cfg == nil {
+		cfg = DefaultRateLimitConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &RateLimiter{
+		cfg: *cfg,
+		global: bucket{
+			tokens: float64(cfg.GlobalBurst),
+			last:   cfg.Clock.Now(),
+		},
+		peers: make(map[string]*bucket),
+	}, nil
+}
+
+// Allow reports whether a request from the remote peer identified by peer may be handled, and
+// if so takes a token from the buckets.
+func (l *RateLimiter) Allow(peer string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.cfg.Clock.Now()
+
+	var pb *bucket
+	if l.cfg.PeerRate > 0 {
+		pb = l.peers[peer]
+		if pb == nil {
+			pb = &bucket{tokens: float64(l.cfg.PeerBurst), last: now}
+			l.peers[peer] = pb
+		}
+		pb.refill(now, l.cfg.PeerRate, l.cfg.PeerBurst)
+		if pb.tokens < 1 {
+			return false
+		}
+	}
+
+	if l.cfg.GlobalRate > 0 {
+		l.global.refill(now, l.cfg.GlobalRate, l.cfg.GlobalBurst)
+		if l.global.tokens < 1 {
+			return false
+		}
+		l.global.tokens--
+	}
+
+	if pb != nil {
+		pb.tokens--
+	}
+	return true
+}
+
+// Sweep forgets the remote peers whose bucket has been refilled, since they are allowed as many
+// requests as a peer that was never seen, and returns the number of peers removed. It may be
+// scheduled with routing.ScheduleSweep to bound the memory used by the limiter.
+func (l *RateLimiter) Sweep() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.cfg.Clock.Now()
+	removed := 0
+	for p, b := range l.peers {
+		b.refill(now, l.cfg.PeerRate, l.cfg.PeerBurst)
+		if b.tokens >= float64(l.cfg.PeerBurst) {
+			delete(l.peers, p)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (b *bucket) refill(now time.Time, rate float64, burst int) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * rate
+		if b.tokens > float64(burst) {
+			b.tokens = float64(burst)
+		}
+	}
+	b.last = now
+}
@@ -0,0 +1,135 @@
+package endpoint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitConfigValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		cfg := DefaultRateLimitConfig()
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("clock is not nil", func(t *testing.T) {
+		cfg := DefaultRateLimitConfig()
+		cfg.Clock = nil
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("rates not negative", func(t *testing.T) {
+		cfg := DefaultRateLimitConfig()
+		cfg.PeerRate = -1
+		require.Error(t, cfg.Validate())
+
+		cfg = DefaultRateLimitConfig()
+		cfg.GlobalRate = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("bursts positive", func(t *testing.T) {
+		cfg := DefaultRateLimitConfig()
+		cfg.PeerBurst = 0
+		require.Error(t, cfg.Validate())
+
+		cfg = DefaultRateLimitConfig()
+		cfg.GlobalBurst = 0
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("bursts ignored when disabled", func(t *testing.T) {
+		cfg := DefaultRateLimitConfig()
+		cfg.PeerRate = 0
+		cfg.PeerBurst = 0
+		cfg.GlobalRate = 0
+		cfg.GlobalBurst = 0
+		require.NoError(t, cfg.Validate())
+	})
+}
+
+func TestRateLimiterPeer(t *testing.T) {
+	clk := clock.NewMock()
+	cfg := DefaultRateLimitConfig()
+	cfg.Clock = clk
+	cfg.PeerRate = 2
+	cfg.PeerBurst = 3
+	cfg.GlobalRate = 0
+
+	l, err := NewRateLimiter(cfg)
+	require.NoError(t, err)
+
+	// a peer may send a burst of requests
+	for i := 0; i < 3; i++ {
+		require.True(t, l.Allow("a"))
+	}
+	require.False(t, l.Allow("a"))
+
+	// other peers have their own bucket
+	require.True(t, l.Allow("b"))
+
+	// tokens are refilled at the peer rate
+	clk.Add(500 * time.Millisecond)
+	require.True(t, l.Allow("a"))
+	require.False(t, l.Allow("a"))
+
+	// up to the burst size
+	clk.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		require.True(t, l.Allow("a"))
+	}
+	require.False(t, l.Allow("a"))
+}
+
+func TestRateLimiterGlobal(t *testing.T) {
+	clk := clock.NewMock()
+	cfg := DefaultRateLimitConfig()
+	cfg.Clock = clk
+	cfg.PeerRate = 1
+	cfg.PeerBurst = 1
+	cfg.GlobalRate = 1
+	cfg.GlobalBurst = 2
+
+	l, err := NewRateLimiter(cfg)
+	require.NoError(t, err)
+
+	require.True(t, l.Allow("a"))
+
+	// requests throttled by the per-peer limit don't use the global budget
+	require.False(t, l.Allow("a"))
+	require.True(t, l.Allow("b"))
+
+	// the global budget is exhausted
+	require.False(t, l.Allow("c"))
+
+	clk.Add(time.Second)
+	require.True(t, l.Allow("c"))
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	clk := clock.NewMock()
+	cfg := DefaultRateLimitConfig()
+	cfg.Clock = clk
+	cfg.PeerRate = 1
+	cfg.PeerBurst = 2
+
+	l, err := NewRateLimiter(cfg)
+	require.NoError(t, err)
+
+	require.True(t, l.Allow("a"))
+	require.True(t, l.Allow("a"))
+	require.True(t, l.Allow("b"))
+
+	// both buckets hold less than their burst
+	require.Equal(t, 0, l.Sweep())
+
+	clk.Add(time.Second)
+	require.Equal(t, 1, l.Sweep())
+	require.Len(t, l.peers, 1)
+
+	clk.Add(time.Second)
+	require.Equal(t, 1, l.Sweep())
+	require.Empty(t, l.peers)
+}
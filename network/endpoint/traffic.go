@@ -0,0 +1,81 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/util"
+)
+
+// Direction tells whether a message was sent or received by an endpoint.
+type Direction string
+
+const (
+	Inbound  Direction = "in"  // the message was received from a remote peer
+	Outbound Direction = "out" // the message was sent to a remote peer
+)
+
+// TypedMessage is a message that names its type, such as the message type of the IPFS DHT, for
+// the messages of several types to be told apart in the metrics.
+type TypedMessage interface {
+	kad.Message
+	MessageType() string
+}
+
+// MessageType returns the type of msg as given by TypedMessage, or its Go type otherwise.
+func MessageType(msg kad.Message) string {
+	if tm, ok := msg.(TypedMessage); ok {
+		return tm.MessageType()
+	}
+	return fmt.Sprintf("%T", msg)
+}
+
+// Traffic records the messages sent and received by an endpoint, counting them and their bytes
+// by protocol, direction and message type. It is safe for concurrent use.
+type Traffic struct {
+	messages metric.Int64Counter
+	bytes    metric.Int64Counter
+}
+
+// NewTraffic creates the instruments of a Traffic with the meter provider mp. A nil mp records
+// nothing.
+func NewTraffic(mp metric.MeterProvider) (*Traffic, error) {
+	meter := util.Meter(mp)
+	messages, err := meter.Int64Counter("endpoint.messages",
+		metric.WithDescription("Number of messages sent and received"))
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, err := meter.Int64Counter("endpoint.bytes",
+		metric.WithDescription("Number of bytes of the messages sent and received"),
+		metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Traffic{
+		messages: messages,
+		bytes:    bytes,
+	}, nil
+}
+
+// Record records a message of protocol protoID sent or received, encoded in size bytes. The bytes
+// of messages that were not encoded, for example by a simulated endpoint, are not counted when
+// size is negative.
+func (t *Traffic) Record(ctx context.Context, protoID address.ProtocolID, dir Direction, msg kad.Message, size int) {
+	set := metric.WithAttributes(
+		attribute.String("protocol", string(protoID)),
+		attribute.String("direction", string(dir)),
+		attribute.String("type", MessageType(msg)),
+	)
+	t.messages.Add(ctx, 1, set)
+	if size >= 0 {
+		t.bytes.Add(ctx, int64(size), set)
+	}
+}
@@ -0,0 +1,37 @@
+package endpoint
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+)
+
+func TestTraffic(t *testing.T) {
+	ctx := context.Background()
+	mp := kadtest.NewMeterProvider()
+	tr, err := NewTraffic(mp)
+	require.NoError(t, err)
+
+	protoID := address.ProtocolID("/test/1.0.0")
+	msg := kadtest.NewRequest("a", key.Key8(0))
+	tr.Record(ctx, protoID, Outbound, msg, 10)
+	tr.Record(ctx, protoID, Outbound, msg, 5)
+	tr.Record(ctx, protoID, Inbound, msg, -1)
+
+	typ := attribute.String("type", MessageType(msg))
+	require.Equal(t, 3.0, mp.Sum("endpoint.messages", typ))
+	require.Equal(t, 2.0, mp.Sum("endpoint.messages", attribute.String("direction", "out")))
+	require.Equal(t, 15.0, mp.Sum("endpoint.bytes", attribute.String("protocol", "/test/1.0.0")))
+	require.Zero(t, mp.Count("endpoint.bytes", attribute.String("direction", "in")))
+
+	// nil providers record nothing
+	tr, err = NewTraffic(nil)
+	require.NoError(t, err)
+	tr.Record(ctx, protoID, Inbound, msg, 1)
+}
@@ -0,0 +1,263 @@
+package peerstore
+
+import (
+	"context"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/plprobelab/go-kademlia/datastore"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+// NodeInfoCodec encodes and decodes the node infos stored by a DatastorePeerstore.
+type NodeInfoCodec[K kad.Key[K], A kad.Address[A]] interface {
+	// MarshalNodeInfo returns the encoding of the node info.
+	MarshalNodeInfo(kad.NodeInfo[K, A]) ([]byte, error)
+
+	// UnmarshalNodeInfo decodes a node info.
+	UnmarshalNodeInfo([]byte) (kad.NodeInfo[K, A], error)
+}
+
+// datastorePrefix is the prefix of the datastore keys of peerstore entries.
+const datastorePrefix = "/peers/"
+
+// keyEncoding encodes node ids, which may contain any character, into datastore keys.
+var keyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// DatastorePeerstore is a Peerstore keeping its entries in a datastore.Datastore, which may be
+// persistent. The connectedness of nodes describes the current state of the local node and is
+// kept in memory only.
+type DatastorePeerstore[K kad.Key[K], A kad.Address[A]] struct {
+	cfg   Config
+	ds    datastore.Datastore
+	codec NodeInfoCodec[K, A]
+
+	// mu serializes adds so that the expiry of an entry is not replaced concurrently
+	mu sync.Mutex
+
+	connMu sync.Mutex // guards conns
+	conns  map[string]endpoint.Connectedness
+}
+
+// NewDatastorePeerstore returns a DatastorePeerstore keeping its entries in ds, which may
+// already hold entries stored by a previous DatastorePeerstore, encoded with codec. If cfg is
+// nil, DefaultConfig is used.
+func NewDatastorePeerstore[K kad.Key[K], A kad.Address[A]](ds datastore.Datastore, codec NodeInfoCodec[K, A], cfg *Config) (*DatastorePeerstore[K, A], error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if codec == nil {
+		return nil, ErrNoCodec
+	}
+
+	return &DatastorePeerstore[K, A]{
+		cfg:   *cfg,
+		ds:    ds,
+		codec: codec,
+		conns: make(map[string]endpoint.Connectedness),
+	}, nil
+}
+
+func (s *DatastorePeerstore[K, A]) Add(ctx context.Context, ni kad.NodeInfo[K, A], ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	data, err := s.codec.MarshalNodeInfo(ni)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dsKey := datastoreKey(ni.ID())
+	now := s.cfg.Clock.Now()
+	prev, err := s.expires(ctx, dsKey)
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrInvalidEncoding) {
+		return err
+	}
+	exists := err == nil && !expired(now, prev)
+	return s.ds.Put(ctx, dsKey, encodeEntry(expiry(now, ttl, prev, exists), data))
+}
+
+func (s *DatastorePeerstore[K, A]) Get(ctx context.Context, id kad.NodeID[K]) (kad.NodeInfo[K, A], error) {
+	expires, ni, err := s.get(ctx, datastoreKey(id))
+	if err != nil {
+		return nil, err
+	}
+	if expired(s.cfg.Clock.Now(), expires) {
+		return nil, ErrNotFound
+	}
+	return ni, nil
+}
+
+func (s *DatastorePeerstore[K, A]) UpdateTTL(ctx context.Context, id kad.NodeID[K], ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dsKey := datastoreKey(id)
+	data, err := s.ds.Get(ctx, dsKey)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	expires, data, err := decodeEntry(data)
+	if err != nil {
+		return err
+	}
+	now := s.cfg.Clock.Now()
+	if expired(now, expires) {
+		return ErrNotFound
+	}
+	if ttl <= 0 {
+		return s.ds.Delete(ctx, dsKey)
+	}
+	return s.ds.Put(ctx, dsKey, encodeEntry(updatedExpiry(now, ttl), data))
+}
+
+func (s *DatastorePeerstore[K, A]) RemovePeer(ctx context.Context, id kad.NodeID[K]) error {
+	s.mu.Lock()
+	err := s.ds.Delete(ctx, datastoreKey(id))
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	delete(s.conns, id.String())
+	return nil
+}
+
+func (s *DatastorePeerstore[K, A]) Peers(ctx context.Context) ([]kad.NodeInfo[K, A], error) {
+	keys, err := s.ds.Keys(ctx, datastorePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.cfg.Clock.Now()
+	nis := make([]kad.NodeInfo[K, A], 0, len(keys))
+	for _, k := range keys {
+		expires, ni, err := s.get(ctx, k)
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrInvalidEncoding) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if !expired(now, expires) {
+			nis = append(nis, ni)
+		}
+	}
+	sortByID(nis)
+	return nis, nil
+}
+
+func (s *DatastorePeerstore[K, A]) Connectedness(id kad.NodeID[K]) endpoint.Connectedness {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	return s.conns[id.String()]
+}
+
+func (s *DatastorePeerstore[K, A]) SetConnectedness(id kad.NodeID[K], c endpoint.Connectedness) {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	if c == endpoint.NotConnected {
+		delete(s.conns, id.String())
+		return
+	}
+	s.conns[id.String()] = c
+}
+
+func (s *DatastorePeerstore[K, A]) Sweep(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.ds.Keys(ctx, datastorePrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	now := s.cfg.Clock.Now()
+	removed := 0
+	for _, k := range keys {
+		expires, err := s.expires(ctx, k)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		// entries that can't be decoded are removed along with expired ones
+		if err == nil && !expired(now, expires) {
+			continue
+		} else if err != nil && !errors.Is(err, ErrInvalidEncoding) {
+			return removed, err
+		}
+		if err := s.ds.Delete(ctx, k); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// expires returns the expiry of the entry stored under dsKey without decoding its node info.
+func (s *DatastorePeerstore[K, A]) expires(ctx context.Context, dsKey string) (time.Time, error) {
+	data, err := s.ds.Get(ctx, dsKey)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return time.Time{}, ErrNotFound
+	} else if err != nil {
+		return time.Time{}, err
+	}
+	expires, _, err := decodeEntry(data)
+	return expires, err
+}
+
+func (s *DatastorePeerstore[K, A]) get(ctx context.Context, dsKey string) (time.Time, kad.NodeInfo[K, A], error) {
+	data, err := s.ds.Get(ctx, dsKey)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return time.Time{}, nil, ErrNotFound
+	} else if err != nil {
+		return time.Time{}, nil, err
+	}
+	expires, data, err := decodeEntry(data)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	ni, err := s.codec.UnmarshalNodeInfo(data)
+	if err != nil {
+		return time.Time{}, nil, ErrInvalidEncoding
+	}
+	return expires, ni, nil
+}
+
+// datastoreKey returns the datastore key of the entry stored for id.
+func datastoreKey[K kad.Key[K]](id kad.NodeID[K]) string {
+	return datastorePrefix + keyEncoding.EncodeToString([]byte(id.String()))
+}
+
+// encodeEntry encodes an entry: its expiry time in unix nanoseconds as a big endian uint64, zero
+// if it never expires, followed by the encoded node info.
+func encodeEntry(expires time.Time, data []byte) []byte {
+	b := make([]byte, 8+len(data))
+	if !expires.IsZero() {
+		binary.BigEndian.PutUint64(b, uint64(expires.UnixNano()))
+	}
+	copy(b[8:], data)
+	return b
+}
+
+func decodeEntry(b []byte) (time.Time, []byte, error) {
+	if len(b) < 8 {
+		return time.Time{}, nil, ErrInvalidEncoding
+	}
+	var expires time.Time
+	if ns := binary.BigEndian.Uint64(b); ns != 0 {
+		expires = time.Unix(0, int64(ns))
+	}
+	return expires, b[8:], nil
+}
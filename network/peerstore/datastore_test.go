@@ -0,0 +1,104 @@
+package peerstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/datastore"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+// infoCodec encodes a node info as the byte of its key followed by its addresses separated by
+// newlines.
+type infoCodec struct{}
+
+func (infoCodec) MarshalNodeInfo(ni kad.NodeInfo[key.Key8, kadtest.StrAddr]) ([]byte, error) {
+	addrs := make([]string, len(ni.Addresses()))
+	for i, a := range ni.Addresses() {
+		addrs[i] = string(a)
+	}
+	return append([]byte{byte(ni.ID().Key())}, strings.Join(addrs, "\n")...), nil
+}
+
+func (infoCodec) UnmarshalNodeInfo(data []byte) (kad.NodeInfo[key.Key8, kadtest.StrAddr], error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty node info")
+	}
+	var addrs []kadtest.StrAddr
+	if len(data) > 1 {
+		for _, a := range strings.Split(string(data[1:]), "\n") {
+			addrs = append(addrs, kadtest.StrAddr(a))
+		}
+	}
+	return newInfo(key.Key8(data[0]), addrs...), nil
+}
+
+func TestDatastorePeerstore(t *testing.T) {
+	testPeerstore(t, func(cfg *Config) Peerstore[key.Key8, kadtest.StrAddr] {
+		ps, err := NewDatastorePeerstore[key.Key8, kadtest.StrAddr](datastore.NewMapDatastore(), infoCodec{}, cfg)
+		require.NoError(t, err)
+		return ps
+	})
+}
+
+func TestNewDatastorePeerstore(t *testing.T) {
+	t.Run("invalid config", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Clock = nil
+		_, err := NewDatastorePeerstore[key.Key8, kadtest.StrAddr](datastore.NewMapDatastore(), infoCodec{}, cfg)
+		require.Error(t, err)
+	})
+
+	t.Run("codec not nil", func(t *testing.T) {
+		_, err := NewDatastorePeerstore[key.Key8, kadtest.StrAddr](datastore.NewMapDatastore(), nil, nil)
+		require.ErrorIs(t, err, ErrNoCodec)
+	})
+}
+
+func TestDatastorePeerstoreSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultConfig()
+	cfg.Clock = clk
+	ds := datastore.NewMapDatastore()
+
+	ps, err := NewDatastorePeerstore[key.Key8, kadtest.StrAddr](ds, infoCodec{}, cfg)
+	require.NoError(t, err)
+	a := newInfo(1, "a1", "a2")
+	require.NoError(t, ps.Add(ctx, a, time.Hour))
+
+	// a new peerstore on the same datastore returns the entry until it expires
+	ps, err = NewDatastorePeerstore[key.Key8, kadtest.StrAddr](ds, infoCodec{}, cfg)
+	require.NoError(t, err)
+	got, err := ps.Get(ctx, a.ID())
+	require.NoError(t, err)
+	require.Equal(t, a, got)
+
+	clk.Add(time.Hour)
+	_, err = ps.Get(ctx, a.ID())
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestDatastorePeerstoreSweepsInvalidEntries(t *testing.T) {
+	ctx := context.Background()
+	ds := datastore.NewMapDatastore()
+	ps, err := NewDatastorePeerstore[key.Key8, kadtest.StrAddr](ds, infoCodec{}, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, ds.Put(ctx, datastorePrefix+"invalid", []byte{1}))
+	peers, err := ps.Peers(ctx)
+	require.NoError(t, err)
+	require.Empty(t, peers)
+
+	n, err := ps.Sweep(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+}
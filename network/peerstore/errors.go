@@ -0,0 +1,19 @@
+package peerstore
+
+import (
+	"errors"
+
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+var (
+	// ErrNotFound is returned when a peerstore holds no unexpired entry for a node. It is
+	// endpoint.ErrUnknownPeer, so that endpoints can return it as is.
+	ErrNotFound = endpoint.ErrUnknownPeer
+
+	ErrInvalidEncoding  = errors.New("invalid peerstore entry encoding")
+	ErrNoCodec          = errors.New("no node info codec")
+	ErrInvalidSignature = errors.New("invalid address signature")
+	ErrUnsigned         = errors.New("addresses are not signed")
+	ErrUnknownKey       = errors.New("unknown public key")
+)
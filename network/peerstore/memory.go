@@ -0,0 +1,156 @@
+package peerstore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+// MemoryPeerstore is a Peerstore keeping its entries in memory.
+type MemoryPeerstore[K kad.Key[K], A kad.Address[A]] struct {
+	cfg Config
+
+	mu    sync.Mutex // guards peers and conns
+	peers map[string]*memoryEntry[K, A]
+	conns map[string]endpoint.Connectedness
+}
+
+type memoryEntry[K kad.Key[K], A kad.Address[A]] struct {
+	info    kad.NodeInfo[K, A]
+	expires time.Time // zero if the entry never expires
+}
+
+// NewMemoryPeerstore returns an empty MemoryPeerstore. If cfg is nil, DefaultConfig is used.
+func NewMemoryPeerstore[K kad.Key[K], A kad.Address[A]](cfg *Config) (*MemoryPeerstore[K, A], error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &MemoryPeerstore[K, A]{
+		cfg:   *cfg,
+		peers: make(map[string]*memoryEntry[K, A]),
+		conns: make(map[string]endpoint.Connectedness),
+	}, nil
+}
+
+func (s *MemoryPeerstore[K, A]) Add(ctx context.Context, ni kad.NodeInfo[K, A], ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := ni.ID().String()
+	now := s.cfg.Clock.Now()
+	pe, ok := s.peers[id]
+	exists := ok && !expired(now, pe.expires)
+	var prev time.Time
+	if exists {
+		prev = pe.expires
+	}
+	s.peers[id] = &memoryEntry[K, A]{
+		info:    ni,
+		expires: expiry(now, ttl, prev, exists),
+	}
+	return nil
+}
+
+func (s *MemoryPeerstore[K, A]) Get(ctx context.Context, id kad.NodeID[K]) (kad.NodeInfo[K, A], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pe, ok := s.peers[id.String()]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if expired(s.cfg.Clock.Now(), pe.expires) {
+		delete(s.peers, id.String())
+		return nil, ErrNotFound
+	}
+	return pe.info, nil
+}
+
+func (s *MemoryPeerstore[K, A]) UpdateTTL(ctx context.Context, id kad.NodeID[K], ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.cfg.Clock.Now()
+	pe, ok := s.peers[id.String()]
+	if !ok || expired(now, pe.expires) {
+		return ErrNotFound
+	}
+	if ttl <= 0 {
+		delete(s.peers, id.String())
+		return nil
+	}
+	pe.expires = updatedExpiry(now, ttl)
+	return nil
+}
+
+func (s *MemoryPeerstore[K, A]) RemovePeer(ctx context.Context, id kad.NodeID[K]) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.peers, id.String())
+	delete(s.conns, id.String())
+	return nil
+}
+
+func (s *MemoryPeerstore[K, A]) Peers(ctx context.Context) ([]kad.NodeInfo[K, A], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.cfg.Clock.Now()
+	nis := make([]kad.NodeInfo[K, A], 0, len(s.peers))
+	for _, pe := range s.peers {
+		if !expired(now, pe.expires) {
+			nis = append(nis, pe.info)
+		}
+	}
+	sortByID(nis)
+	return nis, nil
+}
+
+func (s *MemoryPeerstore[K, A]) Connectedness(id kad.NodeID[K]) endpoint.Connectedness {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns[id.String()]
+}
+
+func (s *MemoryPeerstore[K, A]) SetConnectedness(id kad.NodeID[K], c endpoint.Connectedness) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c == endpoint.NotConnected {
+		delete(s.conns, id.String())
+		return
+	}
+	s.conns[id.String()] = c
+}
+
+func (s *MemoryPeerstore[K, A]) Sweep(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.cfg.Clock.Now()
+	removed := 0
+	for id, pe := range s.peers {
+		if expired(now, pe.expires) {
+			delete(s.peers, id)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// Size returns the number of entries held by the peerstore, including expired entries that
+// have not been removed yet.
+func (s *MemoryPeerstore[K, A]) Size() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.peers)
+}
@@ -0,0 +1,41 @@
+package peerstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+func TestMemoryPeerstore(t *testing.T) {
+	testPeerstore(t, func(cfg *Config) Peerstore[key.Key8, kadtest.StrAddr] {
+		ps, err := NewMemoryPeerstore[key.Key8, kadtest.StrAddr](cfg)
+		require.NoError(t, err)
+		return ps
+	})
+}
+
+func TestNewMemoryPeerstoreInvalidConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Clock = nil
+	_, err := NewMemoryPeerstore[key.Key8, kadtest.StrAddr](cfg)
+	require.Error(t, err)
+}
+
+func TestMemoryPeerstoreGetRemovesExpired(t *testing.T) {
+	ctx := context.Background()
+	ps, err := NewMemoryPeerstore[key.Key8, kadtest.StrAddr](nil)
+	require.NoError(t, err)
+
+	a := newInfo(1, "a1")
+	require.NoError(t, ps.Add(ctx, a, time.Nanosecond))
+	time.Sleep(time.Millisecond)
+
+	_, err = ps.Get(ctx, a.ID())
+	require.ErrorIs(t, err, ErrNotFound)
+	require.Equal(t, 0, ps.Size())
+}
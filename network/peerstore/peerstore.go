@@ -0,0 +1,149 @@
+package peerstore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+// PermanentTTL is the ttl of entries that never expire.
+const PermanentTTL = time.Duration(math.MaxInt64)
+
+// Peerstore is the address book of an endpoint. It keeps the addresses of remote nodes for the
+// ttl they were added with, and the connectedness of the local node with them.
+type Peerstore[K kad.Key[K], A kad.Address[A]] interface {
+	// Add stores the node info of a node, replacing the info stored for the same node, if any.
+	// The entry is kept for at least ttl, an existing entry that would expire later keeps its
+	// expiry. A non-positive ttl leaves the peerstore unchanged.
+	Add(ctx context.Context, ni kad.NodeInfo[K, A], ttl time.Duration) error
+
+	// Get returns the node info stored for id, or ErrNotFound if there is none or it has expired.
+	Get(ctx context.Context, id kad.NodeID[K]) (kad.NodeInfo[K, A], error)
+
+	// UpdateTTL sets the expiry of the entry of id to ttl from now, replacing its current expiry
+	// even if it is later. A non-positive ttl removes the entry. It returns ErrNotFound if id
+	// has no unexpired entry.
+	UpdateTTL(ctx context.Context, id kad.NodeID[K], ttl time.Duration) error
+
+	// RemovePeer removes the entry of id and forgets the connectedness of the local node with
+	// it. Removing a node without an entry does nothing.
+	RemovePeer(ctx context.Context, id kad.NodeID[K]) error
+
+	// Peers returns the node infos of all nodes that have an unexpired entry, ordered by node id.
+	Peers(ctx context.Context) ([]kad.NodeInfo[K, A], error)
+
+	// Connectedness returns the connectedness of the local node with id, NotConnected if unknown.
+	Connectedness(id kad.NodeID[K]) endpoint.Connectedness
+
+	// SetConnectedness records the connectedness of the local node with id.
+	SetConnectedness(id kad.NodeID[K], c endpoint.Connectedness)
+
+	// Sweep removes the entries that have expired and returns the number of entries removed.
+	Sweep(ctx context.Context) (int, error)
+}
+
+// Config specifies optional configuration for a Peerstore
+type Config struct {
+	Clock clock.Clock // a clock that may replaced by a mock when testing
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *Config) Validate() error {
+	if cfg.Clock == nil {
+		return &kaderr.ConfigurationError{
+			Component: "PeerstoreConfig",
+			Err:       fmt.Errorf("clock must not be nil"),
+		}
+	}
+	return nil
+}
+
+// DefaultConfig returns the default configuration options for a Peerstore.
+// Options may be overridden before passing to NewMemoryPeerstore or NewDatastorePeerstore.
+func DefaultConfig() *Config {
+	return &Config{
+		Clock: clock.New(), // use standard time
+	}
+}
+
+// expiry returns the time an entry added at now with ttl expires, the zero time if it never
+// expires. An existing entry expiring at prev keeps its expiry if it is later.
+func expiry(now time.Time, ttl time.Duration, prev time.Time, exists bool) time.Time {
+	if ttl == PermanentTTL || (exists && prev.IsZero()) {
+		return time.Time{}
+	}
+	expires := now.Add(ttl)
+	if exists && prev.After(expires) {
+		return prev
+	}
+	return expires
+}
+
+// updatedExpiry returns the time an entry whose ttl is updated at now to a positive ttl expires,
+// the zero time if it never expires.
+func updatedExpiry(now time.Time, ttl time.Duration) time.Time {
+	if ttl == PermanentTTL {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+// expired reports whether an entry expiring at expires has expired at time now.
+func expired(now, expires time.Time) bool {
+	return !expires.IsZero() && !now.Before(expires)
+}
+
+// A Sweeper is a peerstore that can remove its expired entries.
+type Sweeper = event.Sweeper
+
+// ScheduleSweep schedules s to be swept on sched every interval, like event.ScheduleSweep.
+func ScheduleSweep(ctx context.Context, sched event.Scheduler, s Sweeper, interval time.Duration) *event.RepeatedAction {
+	return event.ScheduleSweep(ctx, sched, s, interval)
+}
+
+// NodeSource looks up the nodes of a peerstore by distance, so that queries may draw on the nodes
+// it knows besides those of the routing table. See query.NodeSource.
+type NodeSource[K kad.Key[K], A kad.Address[A]] struct {
+	ps Peerstore[K, A]
+}
+
+// NewNodeSource returns a NodeSource of the nodes of ps.
+func NewNodeSource[K kad.Key[K], A kad.Address[A]](ps Peerstore[K, A]) *NodeSource[K, A] {
+	return &NodeSource[K, A]{ps: ps}
+}
+
+// NearestNodes returns up to n of the nodes with an unexpired entry in the peerstore closest to
+// target, none if the peerstore cannot be read.
+func (s *NodeSource[K, A]) NearestNodes(target K, n int) []kad.NodeID[K] {
+	nis, err := s.ps.Peers(context.Background())
+	if err != nil {
+		return nil
+	}
+	sort.Slice(nis, func(i, j int) bool {
+		return key.DistanceCmp(target, nis[i].ID().Key(), nis[j].ID().Key()) < 0
+	})
+	if len(nis) > n {
+		nis = nis[:n]
+	}
+	nodes := make([]kad.NodeID[K], len(nis))
+	for i, ni := range nis {
+		nodes[i] = ni.ID()
+	}
+	return nodes
+}
+
+func sortByID[K kad.Key[K], A kad.Address[A]](nis []kad.NodeInfo[K, A]) {
+	sort.Slice(nis, func(i, j int) bool {
+		return nis[i].ID().String() < nis[j].ID().String()
+	})
+}
@@ -0,0 +1,294 @@
+package peerstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+var (
+	_ Peerstore[key.Key8, kadtest.StrAddr] = (*MemoryPeerstore[key.Key8, kadtest.StrAddr])(nil)
+	_ Peerstore[key.Key8, kadtest.StrAddr] = (*DatastorePeerstore[key.Key8, kadtest.StrAddr])(nil)
+)
+
+func TestConfigValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		cfg := DefaultConfig()
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("clock is not nil", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Clock = nil
+		require.Error(t, cfg.Validate())
+	})
+}
+
+func newInfo(k key.Key8, addrs ...kadtest.StrAddr) kad.NodeInfo[key.Key8, kadtest.StrAddr] {
+	return kadtest.NewInfo(kadtest.NewID(k), addrs)
+}
+
+// testPeerstore runs the tests shared by all Peerstore implementations against the peerstores
+// returned by newPeerstore.
+func testPeerstore(t *testing.T, newPeerstore func(cfg *Config) Peerstore[key.Key8, kadtest.StrAddr]) {
+	ctx := context.Background()
+
+	setup := func(t *testing.T) (*clock.Mock, Peerstore[key.Key8, kadtest.StrAddr]) {
+		clk := clock.NewMock()
+		cfg := DefaultConfig()
+		cfg.Clock = clk
+		return clk, newPeerstore(cfg)
+	}
+
+	t.Run("get unknown", func(t *testing.T) {
+		_, ps := setup(t)
+		_, err := ps.Get(ctx, kadtest.NewID(key.Key8(1)))
+		require.ErrorIs(t, err, ErrNotFound)
+		require.ErrorIs(t, err, endpoint.ErrUnknownPeer)
+	})
+
+	t.Run("add and get", func(t *testing.T) {
+		_, ps := setup(t)
+		a := newInfo(1, "a1", "a2")
+		require.NoError(t, ps.Add(ctx, a, time.Minute))
+
+		got, err := ps.Get(ctx, a.ID())
+		require.NoError(t, err)
+		require.Equal(t, a, got)
+	})
+
+	t.Run("add replaces info", func(t *testing.T) {
+		_, ps := setup(t)
+		require.NoError(t, ps.Add(ctx, newInfo(1, "a1"), time.Minute))
+		b := newInfo(1, "b1")
+		require.NoError(t, ps.Add(ctx, b, time.Minute))
+
+		got, err := ps.Get(ctx, b.ID())
+		require.NoError(t, err)
+		require.Equal(t, b, got)
+	})
+
+	t.Run("non-positive ttl ignored", func(t *testing.T) {
+		_, ps := setup(t)
+		a := newInfo(1, "a1")
+		require.NoError(t, ps.Add(ctx, a, 0))
+		require.NoError(t, ps.Add(ctx, a, -time.Minute))
+
+		_, err := ps.Get(ctx, a.ID())
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("entry expires", func(t *testing.T) {
+		clk, ps := setup(t)
+		a := newInfo(1, "a1")
+		require.NoError(t, ps.Add(ctx, a, time.Minute))
+
+		clk.Add(time.Minute - time.Second)
+		_, err := ps.Get(ctx, a.ID())
+		require.NoError(t, err)
+
+		clk.Add(time.Second)
+		_, err = ps.Get(ctx, a.ID())
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("shorter ttl keeps expiry", func(t *testing.T) {
+		clk, ps := setup(t)
+		a := newInfo(1, "a1")
+		require.NoError(t, ps.Add(ctx, a, time.Hour))
+		require.NoError(t, ps.Add(ctx, a, time.Minute))
+
+		clk.Add(30 * time.Minute)
+		_, err := ps.Get(ctx, a.ID())
+		require.NoError(t, err)
+	})
+
+	t.Run("expired entry is not extended", func(t *testing.T) {
+		clk, ps := setup(t)
+		a := newInfo(1, "a1")
+		require.NoError(t, ps.Add(ctx, a, time.Hour))
+
+		clk.Add(time.Hour)
+		require.NoError(t, ps.Add(ctx, a, time.Minute))
+
+		clk.Add(time.Minute)
+		_, err := ps.Get(ctx, a.ID())
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("permanent entry", func(t *testing.T) {
+		clk, ps := setup(t)
+		a := newInfo(1, "a1")
+		require.NoError(t, ps.Add(ctx, a, PermanentTTL))
+		require.NoError(t, ps.Add(ctx, a, time.Minute))
+
+		clk.Add(24 * time.Hour)
+		_, err := ps.Get(ctx, a.ID())
+		require.NoError(t, err)
+
+		n, err := ps.Sweep(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 0, n)
+	})
+
+	t.Run("peers", func(t *testing.T) {
+		clk, ps := setup(t)
+		a := newInfo(1, "a1")
+		b := newInfo(2, "b1")
+		c := newInfo(3, "c1")
+		require.NoError(t, ps.Add(ctx, c, time.Hour))
+		require.NoError(t, ps.Add(ctx, a, time.Hour))
+		require.NoError(t, ps.Add(ctx, b, time.Minute))
+
+		peers, err := ps.Peers(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []kad.NodeInfo[key.Key8, kadtest.StrAddr]{a, b, c}, peers)
+
+		clk.Add(time.Minute)
+		peers, err = ps.Peers(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []kad.NodeInfo[key.Key8, kadtest.StrAddr]{a, c}, peers)
+	})
+
+	t.Run("sweep", func(t *testing.T) {
+		clk, ps := setup(t)
+		require.NoError(t, ps.Add(ctx, newInfo(1, "a1"), time.Minute))
+		require.NoError(t, ps.Add(ctx, newInfo(2, "b1"), time.Hour))
+
+		n, err := ps.Sweep(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 0, n)
+
+		clk.Add(time.Minute)
+		n, err = ps.Sweep(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+
+		peers, err := ps.Peers(ctx)
+		require.NoError(t, err)
+		require.Len(t, peers, 1)
+	})
+
+	t.Run("update ttl", func(t *testing.T) {
+		clk, ps := setup(t)
+		a := newInfo(1, "a1")
+		require.NoError(t, ps.Add(ctx, a, time.Hour))
+
+		// a shorter ttl replaces the later expiry
+		require.NoError(t, ps.UpdateTTL(ctx, a.ID(), time.Minute))
+		clk.Add(time.Minute)
+		_, err := ps.Get(ctx, a.ID())
+		require.ErrorIs(t, err, ErrNotFound)
+
+		// expired entries are not updated
+		require.ErrorIs(t, ps.UpdateTTL(ctx, a.ID(), time.Hour), ErrNotFound)
+		require.ErrorIs(t, ps.UpdateTTL(ctx, kadtest.NewID(key.Key8(2)), time.Hour), ErrNotFound)
+	})
+
+	t.Run("update ttl to permanent", func(t *testing.T) {
+		clk, ps := setup(t)
+		a := newInfo(1, "a1")
+		require.NoError(t, ps.Add(ctx, a, time.Minute))
+		require.NoError(t, ps.UpdateTTL(ctx, a.ID(), PermanentTTL))
+
+		clk.Add(24 * time.Hour)
+		got, err := ps.Get(ctx, a.ID())
+		require.NoError(t, err)
+		require.Equal(t, a, got)
+	})
+
+	t.Run("update ttl non-positive removes", func(t *testing.T) {
+		_, ps := setup(t)
+		a := newInfo(1, "a1")
+		require.NoError(t, ps.Add(ctx, a, PermanentTTL))
+		require.NoError(t, ps.UpdateTTL(ctx, a.ID(), 0))
+
+		_, err := ps.Get(ctx, a.ID())
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("remove peer", func(t *testing.T) {
+		_, ps := setup(t)
+		a := newInfo(1, "a1")
+		require.NoError(t, ps.Add(ctx, a, PermanentTTL))
+		ps.SetConnectedness(a.ID(), endpoint.Connected)
+
+		require.NoError(t, ps.RemovePeer(ctx, a.ID()))
+		_, err := ps.Get(ctx, a.ID())
+		require.ErrorIs(t, err, ErrNotFound)
+		require.Equal(t, endpoint.NotConnected, ps.Connectedness(a.ID()))
+
+		// removing an unknown peer does nothing
+		require.NoError(t, ps.RemovePeer(ctx, a.ID()))
+	})
+
+	t.Run("schedule sweep", func(t *testing.T) {
+		clk, ps := setup(t)
+		sctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		sched := event.NewSimpleScheduler(clk)
+		ScheduleSweep(sctx, sched, ps, time.Minute)
+
+		require.NoError(t, ps.Add(ctx, newInfo(1, "a1"), time.Minute))
+		require.NoError(t, ps.Add(ctx, newInfo(2, "b1"), time.Hour))
+		event.RunAll(ctx, sched)
+
+		// the expired entry is swept, so a sweep run directly finds nothing left to remove
+		clk.Add(time.Minute)
+		event.RunAll(ctx, sched)
+		n, err := ps.Sweep(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 0, n)
+
+		// no further sweeps once the context is done
+		cancel()
+		require.NoError(t, ps.Add(ctx, newInfo(3, "c1"), time.Minute))
+		clk.Add(time.Minute)
+		event.RunAll(ctx, sched)
+		n, err = ps.Sweep(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+	})
+
+	t.Run("connectedness", func(t *testing.T) {
+		_, ps := setup(t)
+		id := kadtest.NewID(key.Key8(1))
+		require.Equal(t, endpoint.NotConnected, ps.Connectedness(id))
+
+		ps.SetConnectedness(id, endpoint.CanConnect)
+		require.Equal(t, endpoint.CanConnect, ps.Connectedness(id))
+
+		ps.SetConnectedness(id, endpoint.Connected)
+		require.Equal(t, endpoint.Connected, ps.Connectedness(id))
+
+		ps.SetConnectedness(id, endpoint.NotConnected)
+		require.Equal(t, endpoint.NotConnected, ps.Connectedness(id))
+	})
+}
+
+func TestNodeSource(t *testing.T) {
+	ctx := context.Background()
+	ps, err := NewMemoryPeerstore[key.Key8, kadtest.StrAddr](nil)
+	require.NoError(t, err)
+
+	for _, k := range []key.Key8{0b00100000, 0b00000001, 0b01000000, 0b00000100} {
+		require.NoError(t, ps.Add(ctx, newInfo(k, "addr"), time.Minute))
+	}
+
+	src := NewNodeSource[key.Key8, kadtest.StrAddr](ps)
+	nodes := src.NearestNodes(key.Key8(0), 3)
+	require.Equal(t, []kad.NodeID[key.Key8]{
+		kadtest.NewID(key.Key8(0b00000001)),
+		kadtest.NewID(key.Key8(0b00000100)),
+		kadtest.NewID(key.Key8(0b00100000)),
+	}, nodes)
+}
@@ -0,0 +1,110 @@
+package peerstore
+
+import (
+	"crypto/ed25519"
+	"fmt"
+
+	"github.com/plprobelab/go-kademlia/kad"
+)
+
+// SignedNodeInfo is a node info carrying a signature of its addresses by the node it describes,
+// so that a node passing it on can't change the addresses without being detected.
+type SignedNodeInfo[K kad.Key[K], A kad.Address[A]] interface {
+	kad.NodeInfo[K, A]
+
+	// Signature returns the signature of the node id and addresses by the node.
+	Signature() []byte
+}
+
+// Verifier checks the signatures of signed node infos.
+type Verifier[K kad.Key[K], A kad.Address[A]] interface {
+	// Verify returns nil if the signature of ni was made by the node ni describes, or an error
+	// wrapping ErrInvalidSignature otherwise.
+	Verify(ni SignedNodeInfo[K, A]) error
+}
+
+// SignaturePolicy decides which node infos an endpoint accepts based on their signatures.
+type SignaturePolicy[K kad.Key[K], A kad.Address[A]] struct {
+	Verifier                 Verifier[K, A] // verifies the signatures of signed node infos
+	RequireSignedCloserNodes bool           // whether responses with unsigned closer nodes are rejected
+}
+
+// Check verifies the signature of ni if it is signed. Unsigned node infos are accepted, such
+// as the addresses of bootstrap nodes supplied by the user.
+func (p *SignaturePolicy[K, A]) Check(ni kad.NodeInfo[K, A]) error {
+	sni, ok := ni.(SignedNodeInfo[K, A])
+	if !ok {
+		return nil
+	}
+	if p.Verifier == nil {
+		return fmt.Errorf("%w: no verifier", ErrInvalidSignature)
+	}
+	return p.Verifier.Verify(sni)
+}
+
+// CheckCloserNodes verifies the signatures of the closer nodes of a response. If
+// RequireSignedCloserNodes is set, an unsigned closer node results in an error wrapping
+// ErrUnsigned.
+func (p *SignaturePolicy[K, A]) CheckCloserNodes(resp kad.Response[K, A]) error {
+	for _, ni := range resp.CloserNodes() {
+		if _, ok := ni.(SignedNodeInfo[K, A]); !ok && p.RequireSignedCloserNodes {
+			return fmt.Errorf("%w: closer node %s", ErrUnsigned, ni.ID())
+		}
+		if err := p.Check(ni); err != nil {
+			return fmt.Errorf("closer node %s: %w", ni.ID(), err)
+		}
+	}
+	return nil
+}
+
+// SignedInfo is a SignedNodeInfo wrapping an unsigned node info and its signature.
+type SignedInfo[K kad.Key[K], A kad.Address[A]] struct {
+	kad.NodeInfo[K, A]
+	sig []byte
+}
+
+// NewSignedInfo returns a SignedInfo with the id and addresses of ni and the signature sig.
+func NewSignedInfo[K kad.Key[K], A kad.Address[A]](ni kad.NodeInfo[K, A], sig []byte) *SignedInfo[K, A] {
+	return &SignedInfo[K, A]{
+		NodeInfo: ni,
+		sig:      sig,
+	}
+}
+
+func (s *SignedInfo[K, A]) Signature() []byte {
+	return s.sig
+}
+
+// SigningPayload returns the bytes signed for a node info: the node id followed by each of its
+// addresses, each on a new line, formatted with fmt.
+func SigningPayload[K kad.Key[K], A kad.Address[A]](ni kad.NodeInfo[K, A]) []byte {
+	b := []byte(ni.ID().String())
+	for _, a := range ni.Addresses() {
+		b = append(b, '\n')
+		b = fmt.Append(b, a)
+	}
+	return b
+}
+
+// SignEd25519 signs the id and addresses of ni with the ed25519 private key of the node.
+func SignEd25519[K kad.Key[K], A kad.Address[A]](ni kad.NodeInfo[K, A], priv ed25519.PrivateKey) *SignedInfo[K, A] {
+	return NewSignedInfo(ni, ed25519.Sign(priv, SigningPayload(ni)))
+}
+
+// Ed25519Verifier is a Verifier of the signatures made by SignEd25519.
+type Ed25519Verifier[K kad.Key[K], A kad.Address[A]] struct {
+	// PublicKey returns the ed25519 public key of a node, or an error wrapping ErrUnknownKey if
+	// it isn't known.
+	PublicKey func(kad.NodeID[K]) (ed25519.PublicKey, error)
+}
+
+func (v *Ed25519Verifier[K, A]) Verify(ni SignedNodeInfo[K, A]) error {
+	pub, err := v.PublicKey(ni.ID())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if !ed25519.Verify(pub, SigningPayload[K, A](ni), ni.Signature()) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
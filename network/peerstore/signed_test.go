@@ -0,0 +1,100 @@
+package peerstore
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+var _ SignedNodeInfo[key.Key8, kadtest.StrAddr] = (*SignedInfo[key.Key8, kadtest.StrAddr])(nil)
+
+// testKeys holds the ed25519 keys of test nodes by node id.
+type testKeys map[string]ed25519.PrivateKey
+
+func (k testKeys) add(t *testing.T, id kad.NodeID[key.Key8]) ed25519.PrivateKey {
+	_, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	k[id.String()] = priv
+	return priv
+}
+
+func (k testKeys) verifier() *Ed25519Verifier[key.Key8, kadtest.StrAddr] {
+	return &Ed25519Verifier[key.Key8, kadtest.StrAddr]{
+		PublicKey: func(id kad.NodeID[key.Key8]) (ed25519.PublicKey, error) {
+			priv, ok := k[id.String()]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+			}
+			return priv.Public().(ed25519.PublicKey), nil
+		},
+	}
+}
+
+func TestEd25519Verifier(t *testing.T) {
+	keys := testKeys{}
+	a := newInfo(1, "a1", "a2")
+	priv := keys.add(t, a.ID())
+	v := keys.verifier()
+
+	t.Run("valid signature", func(t *testing.T) {
+		require.NoError(t, v.Verify(SignEd25519(a, priv)))
+	})
+
+	t.Run("changed addresses", func(t *testing.T) {
+		sig := SignEd25519(a, priv).Signature()
+		spoofed := NewSignedInfo[key.Key8, kadtest.StrAddr](newInfo(1, "a1", "evil"), sig)
+		require.ErrorIs(t, v.Verify(spoofed), ErrInvalidSignature)
+	})
+
+	t.Run("signed by another node", func(t *testing.T) {
+		b := newInfo(2, "b1")
+		keys.add(t, b.ID())
+		require.ErrorIs(t, v.Verify(SignEd25519(b, priv)), ErrInvalidSignature)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		_, other, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		err = v.Verify(SignEd25519(newInfo(3, "c1"), other))
+		require.ErrorIs(t, err, ErrInvalidSignature)
+	})
+}
+
+func TestSignaturePolicy(t *testing.T) {
+	keys := testKeys{}
+	a := newInfo(1, "a1")
+	signed := SignEd25519(a, keys.add(t, a.ID()))
+	spoofed := NewSignedInfo[key.Key8, kadtest.StrAddr](newInfo(1, "evil"), signed.Signature())
+
+	t.Run("check", func(t *testing.T) {
+		p := &SignaturePolicy[key.Key8, kadtest.StrAddr]{Verifier: keys.verifier()}
+		require.NoError(t, p.Check(a))
+		require.NoError(t, p.Check(signed))
+		require.ErrorIs(t, p.Check(spoofed), ErrInvalidSignature)
+	})
+
+	t.Run("no verifier", func(t *testing.T) {
+		p := &SignaturePolicy[key.Key8, kadtest.StrAddr]{}
+		require.NoError(t, p.Check(a))
+		require.ErrorIs(t, p.Check(signed), ErrInvalidSignature)
+	})
+
+	t.Run("closer nodes", func(t *testing.T) {
+		p := &SignaturePolicy[key.Key8, kadtest.StrAddr]{Verifier: keys.verifier()}
+		resp := func(nis ...kad.NodeInfo[key.Key8, kadtest.StrAddr]) kad.Response[key.Key8, kadtest.StrAddr] {
+			return kadtest.NewResponse("1", nis)
+		}
+		require.NoError(t, p.CheckCloserNodes(resp(a, signed)))
+		require.ErrorIs(t, p.CheckCloserNodes(resp(a, spoofed)), ErrInvalidSignature)
+
+		p.RequireSignedCloserNodes = true
+		require.NoError(t, p.CheckCloserNodes(resp(signed)))
+		require.ErrorIs(t, p.CheckCloserNodes(resp(signed, a)), ErrUnsigned)
+	})
+}
@@ -0,0 +1,226 @@
+// Package selfaddr tracks the addresses at which the local node can be reached, so that the
+// responses it sends tell remote nodes where to reach it in return.
+package selfaddr
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+// Config specifies optional configuration for a Manager.
+type Config struct {
+	Clock        clock.Clock   // a clock that may replaced by a mock when testing
+	ObservedTTL  time.Duration // the time an observation of an address by a peer is kept unless the peer observes it again
+	MinObservers int           // the number of distinct peers that must have observed an address before it is advertised
+	MaxObserved  int           // the maximum number of observed addresses advertised
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *Config) Validate() error {
+	if cfg.Clock == nil {
+		return &kaderr.ConfigurationError{
+			Component: "SelfAddrConfig",
+			Err:       fmt.Errorf("clock must not be nil"),
+		}
+	}
+	if cfg.ObservedTTL < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "SelfAddrConfig",
+			Err:       fmt.Errorf("observed ttl must be greater than zero"),
+		}
+	}
+	if cfg.MinObservers < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "SelfAddrConfig",
+			Err:       fmt.Errorf("minimum observers must be greater than zero"),
+		}
+	}
+	if cfg.MaxObserved < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "SelfAddrConfig",
+			Err:       fmt.Errorf("maximum observed addresses must not be negative"),
+		}
+	}
+	return nil
+}
+
+// DefaultConfig returns the default configuration options for a Manager.
+// Options may be overridden before passing to New.
+func DefaultConfig() *Config {
+	return &Config{
+		Clock:        clock.New(), // use standard time
+		ObservedTTL:  30 * time.Minute,
+		MinObservers: 3,
+		MaxObserved:  8,
+	}
+}
+
+// Manager tracks the addresses of the local node: the static addresses it is configured with,
+// such as its listen or announce addresses, and the addresses reported by remote peers that
+// observed it, as behind a NAT. It is safe for concurrent use.
+type Manager[K kad.Key[K], A kad.Address[A]] struct {
+	self kad.NodeID[K]
+	cfg  Config
+
+	mu       sync.Mutex // guards static and observed
+	static   []A
+	observed []*observation[A] // in the order the addresses were first observed
+}
+
+// observation records the peers that observed an address and when they last did.
+type observation[A kad.Address[A]] struct {
+	addr A
+	seen map[string]time.Time // keyed by the string of the observer id
+}
+
+// New returns a Manager of the addresses of self, initially advertising the static addresses
+// only. If cfg is nil, DefaultConfig is used.
+func New[K kad.Key[K], A kad.Address[A]](self kad.NodeID[K], static []A, cfg *Config) (*Manager[K, A], error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Manager[K, A]{
+		self:   self,
+		cfg:    *cfg,
+		static: append([]A(nil), static...),
+	}, nil
+}
+
+// SetStatic replaces the static addresses of the local node, for instance when it starts
+// listening on a new address.
+func (m *Manager[K, A]) SetStatic(addrs []A) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.static = append([]A(nil), addrs...)
+}
+
+// Observe records that observer reported seeing the local node at a. Observations by the local
+// node itself are ignored.
+func (m *Manager[K, A]) Observe(observer kad.NodeID[K], a A) {
+	if key.Equal(observer.Key(), m.self.Key()) {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.cfg.Clock.Now()
+	for _, o := range m.observed {
+		if o.addr.Equal(a) {
+			o.seen[observer.String()] = now
+			return
+		}
+	}
+	m.observed = append(m.observed, &observation[A]{
+		addr: a,
+		seen: map[string]time.Time{observer.String(): now},
+	})
+}
+
+// Addrs returns the addresses the local node advertises: its static addresses followed by the
+// addresses observed by at least MinObservers peers, up to MaxObserved of them, the addresses
+// observed by more peers first.
+func (m *Manager[K, A]) Addrs() []A {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.cfg.Clock.Now()
+	type candidate struct {
+		addr      A
+		observers int
+	}
+	var candidates []candidate
+	for _, o := range m.observed {
+		if m.isStatic(o.addr) {
+			continue
+		}
+		if n := m.observers(now, o); n >= m.cfg.MinObservers {
+			candidates = append(candidates, candidate{addr: o.addr, observers: n})
+		}
+	}
+	// the addresses observed first come first among those observed by as many peers
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].observers > candidates[j].observers
+	})
+	if len(candidates) > m.cfg.MaxObserved {
+		candidates = candidates[:m.cfg.MaxObserved]
+	}
+
+	addrs := make([]A, 0, len(m.static)+len(candidates))
+	addrs = append(addrs, m.static...)
+	for _, c := range candidates {
+		addrs = append(addrs, c.addr)
+	}
+	return addrs
+}
+
+// NodeInfo returns the node info of the local node with the addresses it advertises.
+func (m *Manager[K, A]) NodeInfo() kad.NodeInfo[K, A] {
+	return &nodeInfo[K, A]{id: m.self, addrs: m.Addrs()}
+}
+
+// Sweep forgets the observations older than ObservedTTL and returns the number of observed
+// addresses no peer observes anymore, which are removed.
+func (m *Manager[K, A]) Sweep() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.cfg.Clock.Now()
+	kept := m.observed[:0]
+	for _, o := range m.observed {
+		if m.observers(now, o) > 0 {
+			kept = append(kept, o)
+		}
+	}
+	removed := len(m.observed) - len(kept)
+	for i := len(kept); i < len(m.observed); i++ {
+		m.observed[i] = nil
+	}
+	m.observed = kept
+	return removed
+}
+
+// observers removes the expired observations of o and returns the number of peers that observed
+// it within ObservedTTL. It must be called with mu held.
+func (m *Manager[K, A]) observers(now time.Time, o *observation[A]) int {
+	for id, seen := range o.seen {
+		if !now.Before(seen.Add(m.cfg.ObservedTTL)) {
+			delete(o.seen, id)
+		}
+	}
+	return len(o.seen)
+}
+
+// isStatic reports whether a is one of the static addresses. It must be called with mu held.
+func (m *Manager[K, A]) isStatic(a A) bool {
+	for _, s := range m.static {
+		if s.Equal(a) {
+			return true
+		}
+	}
+	return false
+}
+
+type nodeInfo[K kad.Key[K], A kad.Address[A]] struct {
+	id    kad.NodeID[K]
+	addrs []A
+}
+
+func (ni *nodeInfo[K, A]) ID() kad.NodeID[K] {
+	return ni.id
+}
+
+func (ni *nodeInfo[K, A]) Addresses() []A {
+	return ni.addrs
+}
@@ -0,0 +1,136 @@
+package selfaddr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/routing"
+)
+
+func TestConfigValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		cfg := DefaultConfig()
+		require.NoError(t, cfg.Validate())
+	})
+
+	for name, mutate := range map[string]func(*Config){
+		"clock is not nil":             func(c *Config) { c.Clock = nil },
+		"observed ttl is positive":     func(c *Config) { c.ObservedTTL = 0 },
+		"min observers is positive":    func(c *Config) { c.MinObservers = 0 },
+		"max observed is not negative": func(c *Config) { c.MaxObserved = -1 },
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			mutate(cfg)
+			require.Error(t, cfg.Validate())
+			_, err := New[key.Key8, kadtest.StrAddr](kadtest.NewID(key.Key8(0)), nil, cfg)
+			require.Error(t, err)
+		})
+	}
+}
+
+func setup(t *testing.T, static ...kadtest.StrAddr) (*clock.Mock, *Manager[key.Key8, kadtest.StrAddr]) {
+	clk := clock.NewMock()
+	cfg := DefaultConfig()
+	cfg.Clock = clk
+	cfg.ObservedTTL = time.Minute
+	cfg.MinObservers = 2
+	cfg.MaxObserved = 2
+	m, err := New[key.Key8, kadtest.StrAddr](kadtest.NewID(key.Key8(0)), static, cfg)
+	require.NoError(t, err)
+	return clk, m
+}
+
+// observe records that the peers with the given keys observed a.
+func observe(m *Manager[key.Key8, kadtest.StrAddr], a kadtest.StrAddr, observers ...key.Key8) {
+	for _, k := range observers {
+		m.Observe(kadtest.NewID(k), a)
+	}
+}
+
+func TestManagerStatic(t *testing.T) {
+	_, m := setup(t, "a1", "a2")
+	require.Equal(t, []kadtest.StrAddr{"a1", "a2"}, m.Addrs())
+
+	ni := m.NodeInfo()
+	require.Equal(t, key.Key8(0), ni.ID().Key())
+	require.Equal(t, []kadtest.StrAddr{"a1", "a2"}, ni.Addresses())
+
+	m.SetStatic([]kadtest.StrAddr{"b1"})
+	require.Equal(t, []kadtest.StrAddr{"b1"}, m.Addrs())
+}
+
+func TestManagerObserved(t *testing.T) {
+	_, m := setup(t, "a1")
+
+	// an address is advertised once enough distinct peers observed it
+	observe(m, "o1", 1, 1)
+	require.Equal(t, []kadtest.StrAddr{"a1"}, m.Addrs())
+	observe(m, "o1", 2)
+	require.Equal(t, []kadtest.StrAddr{"a1", "o1"}, m.Addrs())
+
+	// observations by the local node are ignored
+	observe(m, "o2", 0, 3)
+	require.Equal(t, []kadtest.StrAddr{"a1", "o1"}, m.Addrs())
+
+	// the addresses observed by more peers come first, up to MaxObserved of them
+	observe(m, "o2", 4, 5)
+	observe(m, "o3", 6, 7)
+	require.Equal(t, []kadtest.StrAddr{"a1", "o2", "o1"}, m.Addrs())
+
+	// an observed static address is advertised once
+	observe(m, "a1", 1, 2, 3)
+	require.Equal(t, []kadtest.StrAddr{"a1", "o2", "o1"}, m.Addrs())
+}
+
+func TestManagerObservationsExpire(t *testing.T) {
+	clk, m := setup(t)
+
+	observe(m, "o1", 1)
+	clk.Add(30 * time.Second)
+	observe(m, "o1", 2)
+	require.Equal(t, []kadtest.StrAddr{"o1"}, m.Addrs())
+
+	// the first observation expires
+	clk.Add(30 * time.Second)
+	require.Empty(t, m.Addrs())
+
+	// an observation is renewed when the peer observes the address again
+	observe(m, "o1", 1)
+	require.Equal(t, []kadtest.StrAddr{"o1"}, m.Addrs())
+	clk.Add(30 * time.Second)
+	require.Empty(t, m.Addrs())
+	observe(m, "o1", 2)
+	require.Equal(t, []kadtest.StrAddr{"o1"}, m.Addrs())
+	clk.Add(time.Minute)
+	require.Empty(t, m.Addrs())
+}
+
+func TestManagerSweep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clk, m := setup(t)
+
+	observe(m, "o1", 1)
+	clk.Add(30 * time.Second)
+	observe(m, "o2", 1)
+	require.Equal(t, 0, m.Sweep())
+
+	clk.Add(30 * time.Second)
+	require.Equal(t, 1, m.Sweep())
+
+	// the sweeps may be scheduled
+	sched := event.NewSimpleScheduler(clk)
+	routing.ScheduleSweep(ctx, sched, m, time.Minute)
+	clk.Add(time.Minute)
+	event.RunAll(ctx, sched)
+	require.Equal(t, 0, m.Sweep())
+	require.Empty(t, m.observed)
+}
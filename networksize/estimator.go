@@ -0,0 +1,214 @@
+// Package networksize estimates the number of nodes of a network from the distances of the nodes
+// found by lookups.
+package networksize
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+)
+
+// ErrNotEnoughData is returned by Estimator.Estimate until the estimator has tracked enough
+// lookups.
+var ErrNotEnoughData = errors.New("not enough lookups tracked to estimate the network size")
+
+// Config specifies optional configuration for an Estimator
+type Config struct {
+	Peers      int     // the number of closest nodes of each lookup the estimate is based on
+	Window     int     // the number of most recent lookups the estimate is based on
+	MinSamples int     // the number of lookups to track before producing an estimate
+	Confidence float64 // the confidence level of the interval around the estimate, between 0 and 1
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *Config) Validate() error {
+	if cfg.Peers < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "NetworkSizeConfig",
+			Err:       fmt.Errorf("peers must be greater than zero"),
+		}
+	}
+	if cfg.Window < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "NetworkSizeConfig",
+			Err:       fmt.Errorf("window must be greater than zero"),
+		}
+	}
+	if cfg.MinSamples < 1 || cfg.MinSamples > cfg.Window {
+		return &kaderr.ConfigurationError{
+			Component: "NetworkSizeConfig",
+			Err:       fmt.Errorf("min samples must be between one and the window"),
+		}
+	}
+	if cfg.Confidence <= 0 || cfg.Confidence >= 1 {
+		return &kaderr.ConfigurationError{
+			Component: "NetworkSizeConfig",
+			Err:       fmt.Errorf("confidence must be between zero and one exclusive"),
+		}
+	}
+	return nil
+}
+
+// DefaultConfig returns the default configuration options for an Estimator.
+// Options may be overridden before passing to NewEstimator
+func DefaultConfig() *Config {
+	return &Config{
+		Peers:      20,
+		Window:     100,
+		MinSamples: 5,
+		Confidence: 0.95,
+	}
+}
+
+// Estimate is an estimate of the number of nodes of a network.
+type Estimate struct {
+	Size    float64 // the estimated number of nodes
+	Low     float64 // the lower bound of the confidence interval of the estimate
+	High    float64 // the upper bound of the confidence interval of the estimate, +Inf when unbounded
+	Samples int     // the number of lookups the estimate is based on
+}
+
+// An Estimator estimates the number of nodes of a network from the results of lookups. In a
+// network of N nodes with uniformly distributed keys, the i-th closest node to a random target
+// is expected at a normalized distance of i/(N+1) from the target. Each lookup tracked by the
+// estimator is summarised by the slope of the least squares fit of the distances of its closest
+// nodes against their rank, an estimate of 1/(N+1), and the estimate of the network size is
+// derived from the mean slope of the most recent lookups. The sums the estimate is computed
+// from are updated as lookups are tracked, so that an estimate costs the same whatever the
+// window. An Estimator is safe for concurrent use.
+type Estimator[K kad.Key[K]] struct {
+	cfg Config
+	z   float64 // the number of standard errors covered by the confidence interval
+
+	mu     sync.Mutex
+	slopes []float64 // a ring of the slopes of the most recent lookups
+	next   int       // the index in slopes of the next slope to track
+	sum    float64   // the sum of slopes
+	sumSq  float64   // the sum of the squares of slopes
+}
+
+// NewEstimator creates a new Estimator. If cfg is nil, the default config is used.
+func NewEstimator[K kad.Key[K]](cfg *Config) (*Estimator[K], error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Estimator[K]{
+		cfg:    *cfg,
+		z:      math.Sqrt2 * math.Erfinv(cfg.Confidence),
+		slopes: make([]float64, 0, cfg.Window),
+	}, nil
+}
+
+// Track adds the result of a lookup of target to the estimate, closest holding the keys of the
+// closest nodes the lookup found, in any order. Lookups of random targets that ran to completion
+// give the best estimates. It returns an error if closest is empty.
+func (e *Estimator[K]) Track(target K, closest []K) error {
+	if len(closest) == 0 {
+		return fmt.Errorf("lookup of %v found no nodes", target)
+	}
+	ds := make([]float64, len(closest))
+	for i, k := range closest {
+		ds[i] = fraction(target.Xor(k))
+	}
+	sort.Float64s(ds)
+	if len(ds) > e.cfg.Peers {
+		ds = ds[:e.cfg.Peers]
+	}
+
+	// least squares fit of d = slope * rank through the origin
+	var xy, xx float64
+	for i, d := range ds {
+		rank := float64(i + 1)
+		xy += rank * d
+		xx += rank * rank
+	}
+	slope := xy / xx
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.slopes) < e.cfg.Window {
+		e.slopes = append(e.slopes, slope)
+	} else {
+		old := e.slopes[e.next]
+		e.sum -= old
+		e.sumSq -= old * old
+		e.slopes[e.next] = slope
+	}
+	e.next = (e.next + 1) % e.cfg.Window
+	e.sum += slope
+	e.sumSq += slope * slope
+	return nil
+}
+
+// Estimate returns the current estimate of the network size, or ErrNotEnoughData if fewer than
+// MinSamples lookups were tracked.
+func (e *Estimator[K]) Estimate() (Estimate, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	n := len(e.slopes)
+	if n < e.cfg.MinSamples {
+		return Estimate{}, ErrNotEnoughData
+	}
+	mean := e.sum / float64(n)
+	if mean <= 0 {
+		// every lookup found its target, which tells nothing about the size of the network
+		return Estimate{}, ErrNotEnoughData
+	}
+	var se float64
+	if n > 1 {
+		// the variance may come out slightly negative from rounding when all slopes are equal
+		variance := math.Max(0, (e.sumSq-float64(n)*mean*mean)/float64(n-1))
+		se = math.Sqrt(variance / float64(n))
+	}
+
+	// the size is a decreasing function of the slope, the bounds of the interval swap
+	est := Estimate{
+		Size:    sizeOf(mean),
+		Low:     sizeOf(mean + e.z*se),
+		High:    math.Inf(1),
+		Samples: n,
+	}
+	if lo := mean - e.z*se; lo > 0 {
+		est.High = sizeOf(lo)
+	}
+	return est, nil
+}
+
+// Reset discards the lookups tracked by the estimator, for example after the node joined another
+// network.
+func (e *Estimator[K]) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.slopes = e.slopes[:0]
+	e.next = 0
+	e.sum = 0
+	e.sumSq = 0
+}
+
+// sizeOf returns the network size matching the expected distance slope of 1/(N+1).
+func sizeOf(slope float64) float64 {
+	return math.Max(0, 1/slope-1)
+}
+
+// fraction returns the distance d as a fraction of the key space, between 0 and 1. Only the first
+// 64 bits of d are significant.
+func fraction[K kad.Key[K]](d K) float64 {
+	var f float64
+	scale := 0.5
+	for i := 0; i < d.BitLen() && i < 64; i++ {
+		if d.Bit(i) == 1 {
+			f += scale
+		}
+		scale /= 2
+	}
+	return f
+}
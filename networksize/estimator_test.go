@@ -0,0 +1,116 @@
+package networksize
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+func TestConfigValidate(t *testing.T) {
+	require.NoError(t, DefaultConfig().Validate())
+
+	cfg := DefaultConfig()
+	cfg.Peers = 0
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultConfig()
+	cfg.Window = 0
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultConfig()
+	cfg.MinSamples = cfg.Window + 1
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultConfig()
+	cfg.Confidence = 1
+	require.Error(t, cfg.Validate())
+
+	_, err := NewEstimator[key.Key64](&Config{})
+	require.Error(t, err)
+}
+
+// lookup returns the keys of the peers closest nodes of nodes to target.
+func lookup(nodes []key.Key64, target key.Key64, peers int) []key.Key64 {
+	closest := append([]key.Key64(nil), nodes...)
+	sort.Slice(closest, func(i, j int) bool {
+		return target.Xor(closest[i]) < target.Xor(closest[j])
+	})
+	return closest[:peers]
+}
+
+func TestEstimator(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, size := range []int{50, 1000, 10000} {
+		nodes := make([]key.Key64, size)
+		for i := range nodes {
+			nodes[i] = key.Key64(rng.Uint64())
+		}
+
+		e, err := NewEstimator[key.Key64](nil)
+		require.NoError(t, err)
+		for i := 0; i < 100; i++ {
+			target := key.Key64(rng.Uint64())
+			require.NoError(t, e.Track(target, lookup(nodes, target, 20)))
+		}
+		est, err := e.Estimate()
+		require.NoError(t, err)
+		require.Equal(t, 100, est.Samples)
+		require.InEpsilon(t, float64(size), est.Size, 0.15)
+		require.Less(t, est.Low, float64(size))
+		require.Greater(t, est.High, float64(size))
+		require.LessOrEqual(t, est.Low, est.Size)
+		require.GreaterOrEqual(t, est.High, est.Size)
+	}
+}
+
+func TestEstimatorWindow(t *testing.T) {
+	e, err := NewEstimator[key.Key8](&Config{Peers: 2, Window: 2, MinSamples: 2, Confidence: 0.9})
+	require.NoError(t, err)
+
+	_, err = e.Estimate()
+	require.ErrorIs(t, err, ErrNotEnoughData)
+	require.Error(t, e.Track(key.Key8(0), nil))
+
+	// nodes at 1/4 and 2/4 of the key space, a slope of 1/4
+	require.NoError(t, e.Track(key.Key8(0), []key.Key8{0x80, 0x40, 0xc0}))
+	_, err = e.Estimate()
+	require.ErrorIs(t, err, ErrNotEnoughData)
+	require.NoError(t, e.Track(key.Key8(0), []key.Key8{0x40, 0x80}))
+	est, err := e.Estimate()
+	require.NoError(t, err)
+	require.InDelta(t, 3, est.Size, 1e-9)
+	require.InDelta(t, 3, est.Low, 1e-9)
+	require.InDelta(t, 3, est.High, 1e-9)
+
+	// the first lookups leave the window, nodes at 1/8 and 2/8 give a slope of 1/8
+	require.NoError(t, e.Track(key.Key8(0), []key.Key8{0x20, 0x40}))
+	require.NoError(t, e.Track(key.Key8(0), []key.Key8{0x20, 0x40}))
+	est, err = e.Estimate()
+	require.NoError(t, err)
+	require.InDelta(t, 7, est.Size, 1e-9)
+
+	// lookups disagreeing widen the interval
+	require.NoError(t, e.Track(key.Key8(0), []key.Key8{0x40, 0x80}))
+	est, err = e.Estimate()
+	require.NoError(t, err)
+	require.Less(t, est.Low, est.Size)
+	require.Greater(t, est.High, est.Size)
+
+	// an interval reaching a slope of zero has no upper bound
+	e, err = NewEstimator[key.Key8](&Config{Peers: 1, Window: 2, MinSamples: 2, Confidence: 0.99})
+	require.NoError(t, err)
+	require.NoError(t, e.Track(key.Key8(0), []key.Key8{0x01}))
+	require.NoError(t, e.Track(key.Key8(0), []key.Key8{0x80}))
+	est, err = e.Estimate()
+	require.NoError(t, err)
+	require.True(t, math.IsInf(est.High, 1))
+
+	e.Reset()
+	_, err = e.Estimate()
+	require.ErrorIs(t, err, ErrNotEnoughData)
+}
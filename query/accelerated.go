@@ -0,0 +1,97 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+// AccelerationConfig specifies how a query resolves from the nodes known locally before looking
+// up the network. A query given one whose known closest nodes include at least Candidates nodes
+// sharing a common prefix of at least MinCpl bits with the target skips the lookup: like a hybrid
+// query, it only contacts those candidates to verify that they are live, without following the
+// closer nodes they return. Otherwise, or if the candidates leave it short of results, the query
+// runs the full lookup. This saves the hops of repeated lookups in stable networks, where the
+// routing table, or a larger table of the nodes seen recently, already holds the closest nodes.
+type AccelerationConfig struct {
+	MinCpl     int // the common prefix length with the target a known node must have to be a candidate
+	Candidates int // the number of candidates needed to skip the lookup
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *AccelerationConfig) Validate() error {
+	if cfg.MinCpl < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "AccelerationConfig",
+			Err:       fmt.Errorf("minimum cpl must not be negative"),
+		}
+	}
+	if cfg.Candidates < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "AccelerationConfig",
+			Err:       fmt.Errorf("candidates must be greater than zero"),
+		}
+	}
+	return nil
+}
+
+// DefaultAccelerationConfig returns the default configuration options for accelerated queries.
+// Options may be overridden before setting QueryConfig.Acceleration
+func DefaultAccelerationConfig() *AccelerationConfig {
+	return &AccelerationConfig{
+		MinCpl:     16, // well past the closest nodes of a network of tens of thousands of nodes
+		Candidates: 20,
+	}
+}
+
+// accelerationCandidates returns the nodes of known, excluding self, sharing at least cfg.MinCpl
+// bits with target, the other nodes of known, and false if there are fewer than cfg.Candidates
+// candidates.
+func accelerationCandidates[K kad.Key[K]](cfg *AccelerationConfig, self kad.NodeID[K], target K, known []kad.NodeID[K]) ([]kad.NodeID[K], []kad.NodeID[K], bool) {
+	var close, others []kad.NodeID[K]
+	for _, n := range known {
+		if key.Equal(n.Key(), self.Key()) {
+			continue
+		}
+		if target.CommonPrefixLength(n.Key()) >= cfg.MinCpl {
+			close = append(close, n)
+		} else {
+			others = append(others, n)
+		}
+	}
+	return close, others, len(close) >= cfg.Candidates
+}
+
+// A NodeSource is a set of known nodes the candidates of accelerated queries may be drawn from,
+// such as a routing table, a peerstore or a cache of the nodes found by recent lookups.
+type NodeSource[K kad.Key[K]] interface {
+	// NearestNodes returns up to n of the nodes of the source closest to target.
+	NearestNodes(target K, n int) []kad.NodeID[K]
+}
+
+// NearestFrom returns up to n of the nodes of sources closest to target, ordered by increasing
+// distance to target. A node held by several sources is only returned once.
+func NearestFrom[K kad.Key[K]](target K, n int, sources ...NodeSource[K]) []kad.NodeID[K] {
+	seen := make(map[string]bool)
+	var nodes []kad.NodeID[K]
+	for _, src := range sources {
+		for _, node := range src.NearestNodes(target, n) {
+			k := key.FormatHex(node.Key())
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			nodes = append(nodes, node)
+		}
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return key.DistanceCmp(target, nodes[i].Key(), nodes[j].Key()) < 0
+	})
+	if len(nodes) > n {
+		nodes = nodes[:n]
+	}
+	return nodes
+}
@@ -0,0 +1,200 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+)
+
+func TestAccelerationConfigValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		cfg := DefaultAccelerationConfig()
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("min cpl not negative", func(t *testing.T) {
+		cfg := DefaultAccelerationConfig()
+		cfg.MinCpl = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("candidates positive", func(t *testing.T) {
+		cfg := DefaultAccelerationConfig()
+		cfg.Candidates = 0
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("validated by the query config", func(t *testing.T) {
+		cfg := DefaultQueryConfig[key.Key8]()
+		cfg.Acceleration = DefaultAccelerationConfig()
+		require.NoError(t, cfg.Validate())
+		cfg.Acceleration.Candidates = 0
+		require.Error(t, cfg.Validate())
+	})
+}
+
+// newAcceleratedQuery returns a query for target seeded with known, accelerated when two known
+// nodes share at least 5 bits with the target.
+func newAcceleratedQuery(t *testing.T, target key.Key8, known []kad.NodeID[key.Key8]) *Query[key.Key8, kadtest.StrAddr] {
+	cfg := DefaultQueryConfig[key.Key8]()
+	cfg.Clock = clock.NewMock()
+	cfg.Concurrency = 1
+	cfg.NumResults = 2
+	cfg.Acceleration = &AccelerationConfig{MinCpl: 5, Candidates: 2}
+
+	self := kadtest.NewID(key.Key8(0b10000000))
+	msg := kadtest.NewRequest("1", target)
+	qry, err := NewQuery[key.Key8, kadtest.StrAddr](self, "test", address.ProtocolID("testprotocol"), msg, NewClosestNodesIter(target), known, cfg)
+	require.NoError(t, err)
+	return qry
+}
+
+func TestQueryAccelerated(t *testing.T) {
+	ctx := context.Background()
+
+	target := key.Key8(0b00000000)
+	a := kadtest.NewID(key.Key8(0b00000001)) // cpl 7
+	b := kadtest.NewID(key.Key8(0b00000100)) // cpl 5
+	c := kadtest.NewID(key.Key8(0b00100000)) // cpl 2, not a candidate
+	x := kadtest.NewID(key.Key8(0b00000010)) // closer than b, not known
+
+	qry := newAcceleratedQuery(t, target, []kad.NodeID[key.Key8]{a, b, c})
+
+	state := qry.Advance(ctx, nil)
+	require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+	require.Equal(t, a, state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]).NodeID)
+
+	// the closer node returned by the candidate is not followed
+	state = qry.Advance(ctx, &EventQueryMessageResponse[key.Key8, kadtest.StrAddr]{
+		NodeID: a,
+		Response: kadtest.NewResponse("resp_a", []kad.NodeInfo[key.Key8, kadtest.StrAddr]{
+			kadtest.NewInfo(x, []kadtest.StrAddr{"addr_x"}),
+		}),
+	})
+	require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+	require.Equal(t, b, state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]).NodeID)
+
+	// the query finishes once the candidates are verified, without contacting c
+	state = qry.Advance(ctx, &EventQueryMessageResponse[key.Key8, kadtest.StrAddr]{NodeID: b})
+	require.IsType(t, &StateQueryFinished{}, state)
+	require.Equal(t, 2, state.(*StateQueryFinished).Stats.Requests)
+	require.Equal(t, 1, state.(*StateQueryFinished).Stats.Hops)
+}
+
+func TestQueryNotAccelerated(t *testing.T) {
+	ctx := context.Background()
+
+	target := key.Key8(0b00000000)
+	a := kadtest.NewID(key.Key8(0b00000001)) // cpl 7
+	c := kadtest.NewID(key.Key8(0b00100000)) // cpl 2, not a candidate
+	x := kadtest.NewID(key.Key8(0b00000010))
+
+	// a single candidate is not enough to skip the lookup
+	qry := newAcceleratedQuery(t, target, []kad.NodeID[key.Key8]{a, c})
+
+	state := qry.Advance(ctx, nil)
+	require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+	require.Equal(t, a, state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]).NodeID)
+
+	// the closer node is followed
+	state = qry.Advance(ctx, &EventQueryMessageResponse[key.Key8, kadtest.StrAddr]{
+		NodeID: a,
+		Response: kadtest.NewResponse("resp_a", []kad.NodeInfo[key.Key8, kadtest.StrAddr]{
+			kadtest.NewInfo(x, []kadtest.StrAddr{"addr_x"}),
+		}),
+	})
+	require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+	require.Equal(t, x, state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]).NodeID)
+}
+
+func TestQueryAcceleratedFallsBack(t *testing.T) {
+	ctx := context.Background()
+
+	target := key.Key8(0b00000000)
+	a := kadtest.NewID(key.Key8(0b00000001)) // cpl 7
+	b := kadtest.NewID(key.Key8(0b00000100)) // cpl 5
+	c := kadtest.NewID(key.Key8(0b00100000)) // cpl 2, not a candidate
+	x := kadtest.NewID(key.Key8(0b00000010)) // closer than b, not known
+
+	t.Run("unreachable candidates", func(t *testing.T) {
+		qry := newAcceleratedQuery(t, target, []kad.NodeID[key.Key8]{a, b, c})
+
+		state := qry.Advance(ctx, nil)
+		require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+		require.Equal(t, a, state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]).NodeID)
+
+		state = qry.Advance(ctx, &EventQueryMessageFailure[key.Key8]{NodeID: a})
+		require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+		require.Equal(t, b, state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]).NodeID)
+
+		// no candidate is live, the query looks up the network from the other known node
+		state = qry.Advance(ctx, &EventQueryMessageFailure[key.Key8]{NodeID: b})
+		require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+		require.Equal(t, c, state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]).NodeID)
+
+		// and follows the closer nodes again
+		state = qry.Advance(ctx, &EventQueryMessageResponse[key.Key8, kadtest.StrAddr]{
+			NodeID: c,
+			Response: kadtest.NewResponse("resp_c", []kad.NodeInfo[key.Key8, kadtest.StrAddr]{
+				kadtest.NewInfo(x, []kadtest.StrAddr{"addr_x"}),
+			}),
+		})
+		require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+		require.Equal(t, x, state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]).NodeID)
+	})
+
+	t.Run("too few live candidates", func(t *testing.T) {
+		qry := newAcceleratedQuery(t, target, []kad.NodeID[key.Key8]{a, b, c})
+
+		state := qry.Advance(ctx, nil)
+		require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+		require.Equal(t, a, state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]).NodeID)
+
+		state = qry.Advance(ctx, &EventQueryMessageResponse[key.Key8, kadtest.StrAddr]{
+			NodeID: a,
+			Response: kadtest.NewResponse("resp_a", []kad.NodeInfo[key.Key8, kadtest.StrAddr]{
+				kadtest.NewInfo(x, []kadtest.StrAddr{"addr_x"}),
+			}),
+		})
+		require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+		require.Equal(t, b, state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]).NodeID)
+
+		// a single candidate is live, the query falls back to the closer node it returned
+		state = qry.Advance(ctx, &EventQueryMessageFailure[key.Key8]{NodeID: b})
+		require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+		require.Equal(t, x, state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]).NodeID)
+
+		state = qry.Advance(ctx, &EventQueryMessageResponse[key.Key8, kadtest.StrAddr]{NodeID: x})
+		require.IsType(t, &StateQueryFinished{}, state)
+		require.Equal(t, 3, state.(*StateQueryFinished).Stats.Requests)
+		require.Equal(t, 2, state.(*StateQueryFinished).Stats.Success)
+		require.Equal(t, 2, state.(*StateQueryFinished).Stats.Hops)
+	})
+}
+
+// nodeList is a NodeSource of a list of nodes.
+type nodeList []kad.NodeID[key.Key8]
+
+func (l nodeList) NearestNodes(target key.Key8, n int) []kad.NodeID[key.Key8] {
+	return l
+}
+
+func TestNearestFrom(t *testing.T) {
+	a := kadtest.NewID(key.Key8(0b00000001))
+	b := kadtest.NewID(key.Key8(0b00000100))
+	c := kadtest.NewID(key.Key8(0b00100000))
+	d := kadtest.NewID(key.Key8(0b01000000))
+
+	target := key.Key8(0b00000000)
+	nodes := NearestFrom[key.Key8](target, 3, nodeList{c, a}, nodeList{d, b, a})
+	require.Equal(t, []kad.NodeID[key.Key8]{a, b, c}, nodes)
+
+	require.Empty(t, NearestFrom[key.Key8](target, 3))
+}
@@ -0,0 +1,76 @@
+package query
+
+import (
+	"fmt"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+)
+
+// HybridQueryConfig specifies optional configuration for a hybrid query
+type HybridQueryConfig[K kad.Key[K]] struct {
+	Query      *QueryConfig[K] // the configuration of the underlying query
+	Candidates int             // the number of candidates to take from the local snapshot for verification
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *HybridQueryConfig[K]) Validate() error {
+	if cfg.Query == nil {
+		return &kaderr.ConfigurationError{
+			Component: "HybridQueryConfig",
+			Err:       fmt.Errorf("query config must not be nil"),
+		}
+	}
+	if err := cfg.Query.Validate(); err != nil {
+		return &kaderr.ConfigurationError{
+			Component: "HybridQueryConfig",
+			Err:       err,
+		}
+	}
+	if cfg.Candidates < cfg.Query.NumResults {
+		return &kaderr.ConfigurationError{
+			Component: "HybridQueryConfig",
+			Err:       fmt.Errorf("candidates must be greater than or equal to num results"),
+		}
+	}
+	return nil
+}
+
+// DefaultHybridQueryConfig returns the default configuration options for a hybrid query.
+// Options may be overridden before passing to NewHybridQuery
+func DefaultHybridQueryConfig[K kad.Key[K]]() *HybridQueryConfig[K] {
+	qcfg := DefaultQueryConfig[K]()
+	return &HybridQueryConfig[K]{
+		Query: qcfg,
+		// allow for half of the candidates to be unreachable
+		Candidates: 2 * qcfg.NumResults,
+	}
+}
+
+// NewHybridQuery creates a query that computes its candidates from a local snapshot of the
+// network, such as a large routing table populated by crawling, and only contacts the closest
+// of those candidates to verify that they are live. Closer nodes returned by the candidates are
+// not followed, so the query sends at most cfg.Candidates messages and finishes as soon as
+// NumResults candidates have responded successfully.
+func NewHybridQuery[K kad.Key[K], A kad.Address[A]](self kad.NodeID[K], id QueryID, protocolID address.ProtocolID, msg kad.Request[K, A], snapshot kad.RoutingTable[K, kad.NodeID[K]], cfg *HybridQueryConfig[K]) (*Query[K, A], error) {
+	if cfg == nil {
+		cfg = DefaultHybridQueryConfig[K]()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	target := msg.Target()
+	candidates := snapshot.NearestNodesFiltered(target, cfg.Candidates, func(n kad.NodeID[K]) bool {
+		return !key.Equal(n.Key(), self.Key())
+	})
+
+	qry, err := NewQuery[K, A](self, id, protocolID, msg, NewClosestNodesIter(target), candidates, cfg.Query)
+	if err != nil {
+		return nil, err
+	}
+	qry.verifyOnly = true
+
+	return qry, nil
+}
@@ -0,0 +1,114 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/routing/triert"
+)
+
+func TestHybridQueryConfigValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		cfg := DefaultHybridQueryConfig[key.Key8]()
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("query config is not nil", func(t *testing.T) {
+		cfg := DefaultHybridQueryConfig[key.Key8]()
+		cfg.Query = nil
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("query config is valid", func(t *testing.T) {
+		cfg := DefaultHybridQueryConfig[key.Key8]()
+		cfg.Query.Clock = nil
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("candidates at least num results", func(t *testing.T) {
+		cfg := DefaultHybridQueryConfig[key.Key8]()
+		cfg.Candidates = cfg.Query.NumResults - 1
+		require.Error(t, cfg.Validate())
+		cfg.Candidates = cfg.Query.NumResults
+		require.NoError(t, cfg.Validate())
+	})
+}
+
+func TestHybridQueryVerifiesSnapshotCandidates(t *testing.T) {
+	ctx := context.Background()
+
+	target := key.Key8(0b00000001)
+	self := kadtest.NewID(key.Key8(0))
+	a := kadtest.NewID(key.Key8(0b00000100)) // 4
+	b := kadtest.NewID(key.Key8(0b00001000)) // 8
+	c := kadtest.NewID(key.Key8(0b00010000)) // 16
+	d := kadtest.NewID(key.Key8(0b00100000)) // 32
+	x := kadtest.NewID(key.Key8(0b00000010)) // 2, not in the snapshot
+
+	snapshot, err := triert.New[key.Key8, kad.NodeID[key.Key8]](self, nil)
+	require.NoError(t, err)
+	snapshot.AddNode(self)
+	snapshot.AddNode(a)
+	snapshot.AddNode(b)
+	snapshot.AddNode(c)
+	snapshot.AddNode(d)
+
+	clk := clock.NewMock()
+	cfg := DefaultHybridQueryConfig[key.Key8]()
+	cfg.Query.Clock = clk
+	cfg.Query.Concurrency = 1
+	cfg.Query.NumResults = 2
+	cfg.Candidates = 3
+
+	msg := kadtest.NewRequest("1", target)
+	queryID := QueryID("test")
+	protocolID := address.ProtocolID("testprotocol")
+
+	qry, err := NewHybridQuery[key.Key8, kadtest.StrAddr](self, queryID, protocolID, msg, snapshot, cfg)
+	require.NoError(t, err)
+
+	// the query should contact the closest candidate first
+	state := qry.Advance(ctx, nil)
+	require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+	st := state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr])
+	require.Equal(t, a, st.NodeID)
+
+	// the response includes a closer node that is not in the snapshot
+	state = qry.Advance(ctx, &EventQueryMessageResponse[key.Key8, kadtest.StrAddr]{
+		NodeID: a,
+		Response: kadtest.NewResponse("resp_a", []kad.NodeInfo[key.Key8, kadtest.StrAddr]{
+			kadtest.NewInfo(x, []kadtest.StrAddr{"addr_x"}),
+		}),
+	})
+
+	// the closer node is not followed, the query verifies the next candidate instead
+	require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+	st = state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr])
+	require.Equal(t, b, st.NodeID)
+
+	// the candidate cannot be reached so the query falls back to the next one
+	state = qry.Advance(ctx, &EventQueryMessageFailure[key.Key8]{
+		NodeID: b,
+	})
+	require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+	st = state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr])
+	require.Equal(t, c, st.NodeID)
+
+	// once enough candidates have been verified the query finishes without contacting d
+	state = qry.Advance(ctx, &EventQueryMessageResponse[key.Key8, kadtest.StrAddr]{
+		NodeID: c,
+	})
+	require.IsType(t, &StateQueryFinished{}, state)
+
+	stf := state.(*StateQueryFinished)
+	require.Equal(t, 3, stf.Stats.Requests)
+	require.Equal(t, 2, stf.Stats.Success)
+	require.Equal(t, 1, stf.Stats.Failure)
+}
@@ -9,6 +9,7 @@ import (
 type NodeStatus[K kad.Key[K]] struct {
 	NodeID kad.NodeID[K]
 	State  NodeState
+	Hops   int // the number of responses the node was learnt through, 1 for the nodes a query is seeded with
 }
 
 type NodeState interface {
@@ -9,7 +9,9 @@ import (
 
 	"github.com/plprobelab/go-kademlia/kad"
 	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/logging"
 	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/routing/denylist"
 	"github.com/plprobelab/go-kademlia/util"
 )
 
@@ -19,8 +21,15 @@ type Pool[K kad.Key[K], A kad.Address[A]] struct {
 	queries    []*Query[K, A]
 	queryIndex map[QueryID]*Query[K, A]
 
+	// paused holds the ids of the paused queries, which send no requests until they are resumed
+	paused map[QueryID]bool
+
+	// held holds the events of the paused queries, which are advanced with them once resumed
+	held map[QueryID][]QueryEvent
+
 	// cfg is a copy of the optional configuration supplied to the pool
 	cfg PoolConfig
+	log logging.Logger
 
 	// queriesInFlight is number of queries that are waiting for message responses
 	queriesInFlight int
@@ -28,12 +37,20 @@ type Pool[K kad.Key[K], A kad.Address[A]] struct {
 
 // PoolConfig specifies optional configuration for a Pool
 type PoolConfig struct {
-	Concurrency      int           // the maximum number of queries that may be waiting for message responses at any one time
-	Timeout          time.Duration // the time to wait before terminating a query that is not making progress
-	Replication      int           // the 'k' parameter defined by Kademlia
-	QueryConcurrency int           // the maximum number of concurrent requests that each query may have in flight
-	RequestTimeout   time.Duration // the timeout queries should use for contacting a single node
-	Clock            clock.Clock   // a clock that may replaced by a mock when testing
+	Concurrency      int                    // the maximum number of queries that may be waiting for message responses at any one time
+	Timeout          time.Duration          // the time to wait before terminating a query that is not making progress
+	Replication      int                    // the 'k' parameter defined by Kademlia
+	QueryConcurrency int                    // the maximum number of concurrent requests that each query may have in flight
+	RequestTimeout   time.Duration          // the timeout queries should use for contacting a single node
+	Clock            clock.Clock            // a clock that may replaced by a mock when testing
+	Quarantine       *Quarantine            // an optional quarantine of unreachable nodes shared by all queries
+	DenyList         *denylist.PeerDenyList // an optional list of banned nodes that queries must not contact
+	Logger           logging.Logger         // an optional logger the pool and its queries emit events to, nil logs nothing
+	Acceleration     *AccelerationConfig    // an optional configuration resolving queries from their known closest nodes when they are close enough, see QueryConfig
+
+	// RequestTimeoutFunc optionally gives the timeout queries should use for contacting each node,
+	// overriding RequestTimeout. See QueryConfig.
+	RequestTimeoutFunc func(fmt.Stringer) time.Duration
 }
 
 // Validate checks the configuration options and returns an error if any have invalid values.
@@ -77,19 +94,34 @@ func (cfg *PoolConfig) Validate() error {
 		}
 	}
 
+	if cfg.Acceleration != nil {
+		if err := cfg.Acceleration.Validate(); err != nil {
+			return &kaderr.ConfigurationError{
+				Component: "PoolConfig",
+				Err:       err,
+			}
+		}
+	}
+
 	return nil
 }
 
 // DefaultPoolConfig returns the default configuration options for a Pool.
 // Options may be overridden before passing to NewPool
 func DefaultPoolConfig() *PoolConfig {
+	return PoolConfigFrom(kad.DefaultConfig())
+}
+
+// PoolConfigFrom returns the default configuration options for a Pool whose queries use the
+// bucket size, concurrency and timeouts of kcfg.
+func PoolConfigFrom(kcfg *kad.Config) *PoolConfig {
 	return &PoolConfig{
 		Clock:            clock.New(), // use standard time
 		Concurrency:      3,
-		Timeout:          5 * time.Minute,
-		Replication:      20,
-		QueryConcurrency: 3,
-		RequestTimeout:   time.Minute,
+		Timeout:          kcfg.QueryTimeout,
+		Replication:      kcfg.BucketSize,
+		QueryConcurrency: kcfg.Concurrency,
+		RequestTimeout:   kcfg.RequestTimeout,
 	}
 }
 
@@ -103,8 +135,11 @@ func NewPool[K kad.Key[K], A kad.Address[A]](self kad.NodeID[K], cfg *PoolConfig
 	return &Pool[K, A]{
 		self:       self,
 		cfg:        *cfg,
+		log:        logging.OrNop(cfg.Logger),
 		queries:    make([]*Query[K, A], 0),
 		queryIndex: make(map[QueryID]*Query[K, A]),
+		paused:     make(map[QueryID]bool),
+		held:       make(map[QueryID][]QueryEvent),
 	}, nil
 }
 
@@ -132,12 +167,22 @@ func (p *Pool[K, A]) Advance(ctx context.Context, ev PoolEvent) PoolState {
 			}
 			eventQueryID = qry.id
 		}
+	case *EventPoolPauseQuery:
+		if _, ok := p.queryIndex[tev.QueryID]; ok {
+			p.paused[tev.QueryID] = true
+		}
+	case *EventPoolResumeQuery:
+		delete(p.paused, tev.QueryID)
 	case *EventPoolMessageResponse[K, A]:
 		if qry, ok := p.queryIndex[tev.QueryID]; ok {
-			state, terminal := p.advanceQuery(ctx, qry, &EventQueryMessageResponse[K, A]{
+			qev := &EventQueryMessageResponse[K, A]{
 				NodeID:   tev.NodeID,
 				Response: tev.Response,
-			})
+			}
+			if p.hold(qry.id, qev) {
+				break
+			}
+			state, terminal := p.advanceQuery(ctx, qry, qev)
 			if terminal {
 				return state
 			}
@@ -145,10 +190,14 @@ func (p *Pool[K, A]) Advance(ctx context.Context, ev PoolEvent) PoolState {
 		}
 	case *EventPoolMessageFailure[K]:
 		if qry, ok := p.queryIndex[tev.QueryID]; ok {
-			state, terminal := p.advanceQuery(ctx, qry, &EventQueryMessageFailure[K]{
+			qev := &EventQueryMessageFailure[K]{
 				NodeID: tev.NodeID,
 				Error:  tev.Error,
-			})
+			}
+			if p.hold(qry.id, qev) {
+				break
+			}
+			state, terminal := p.advanceQuery(ctx, qry, qev)
 			if terminal {
 				return state
 			}
@@ -170,8 +219,24 @@ func (p *Pool[K, A]) Advance(ctx context.Context, ev PoolEvent) PoolState {
 			// avoid advancing query twice
 			continue
 		}
+		if p.paused[qry.id] {
+			// a paused query is not advanced, and so it does not time out either
+			continue
+		}
+
+		// a resumed query is advanced with the events held back while it was paused first
+		var qev QueryEvent
+		if held := p.held[qry.id]; len(held) > 0 {
+			qev = held[0]
+			held[0] = nil
+			if len(held) == 1 {
+				delete(p.held, qry.id)
+			} else {
+				p.held[qry.id] = held[1:]
+			}
+		}
 
-		state, terminal := p.advanceQuery(ctx, qry, nil)
+		state, terminal := p.advanceQuery(ctx, qry, qev)
 		if terminal {
 			return state
 		}
@@ -211,6 +276,7 @@ func (p *Pool[K, A]) advanceQuery(ctx context.Context, qry *Query[K, A], qev Que
 		elapsed := p.cfg.Clock.Since(qry.stats.Start)
 		if elapsed > p.cfg.Timeout {
 			p.removeQuery(qry.id)
+			p.log.Info("query timed out", logging.String("query", string(st.QueryID)), logging.Duration("elapsed", elapsed))
 			return &StatePoolQueryTimeout{
 				QueryID: st.QueryID,
 				Stats:   st.Stats,
@@ -221,6 +287,7 @@ func (p *Pool[K, A]) advanceQuery(ctx context.Context, qry *Query[K, A], qev Que
 		elapsed := p.cfg.Clock.Since(qry.stats.Start)
 		if elapsed > p.cfg.Timeout {
 			p.removeQuery(qry.id)
+			p.log.Info("query timed out", logging.String("query", string(st.QueryID)), logging.Duration("elapsed", elapsed))
 			return &StatePoolQueryTimeout{
 				QueryID: st.QueryID,
 				Stats:   st.Stats,
@@ -243,6 +310,18 @@ func (p *Pool[K, A]) removeQuery(queryID QueryID) {
 		break
 	}
 	delete(p.queryIndex, queryID)
+	delete(p.paused, queryID)
+	delete(p.held, queryID)
+}
+
+// hold holds back qev if the query queryID is paused, or has events held back from a pause that
+// it has not been advanced with yet, returning false otherwise.
+func (p *Pool[K, A]) hold(queryID QueryID, qev QueryEvent) bool {
+	if !p.paused[queryID] && len(p.held[queryID]) == 0 {
+		return false
+	}
+	p.held[queryID] = append(p.held[queryID], qev)
+	return true
 }
 
 // addQuery adds a query to the pool, returning the new query id
@@ -257,6 +336,11 @@ func (p *Pool[K, A]) addQuery(ctx context.Context, queryID QueryID, target K, pr
 	qryCfg.Clock = p.cfg.Clock
 	qryCfg.Concurrency = p.cfg.QueryConcurrency
 	qryCfg.RequestTimeout = p.cfg.RequestTimeout
+	qryCfg.RequestTimeoutFunc = p.cfg.RequestTimeoutFunc
+	qryCfg.Quarantine = p.cfg.Quarantine
+	qryCfg.DenyList = p.cfg.DenyList
+	qryCfg.Logger = p.cfg.Logger
+	qryCfg.Acceleration = p.cfg.Acceleration
 
 	qry, err := NewQuery[K](p.self, queryID, protocolID, msg, iter, knownClosestNodes, qryCfg)
 	if err != nil {
@@ -265,6 +349,7 @@ func (p *Pool[K, A]) addQuery(ctx context.Context, queryID QueryID, target K, pr
 
 	p.queries = append(p.queries, qry)
 	p.queryIndex[queryID] = qry
+	p.log.Debug("query added", logging.String("query", string(queryID)), logging.String("protocol", string(protocolID)))
 
 	return nil
 }
@@ -334,6 +419,20 @@ type EventPoolStopQuery struct {
 	QueryID QueryID // the id of the query that should be stopped
 }
 
+// EventPoolPauseQuery notifies a pool to pause a query, for example because the results of the
+// query are produced faster than they are consumed. A paused query sends no more messages and does
+// not time out while paused. The responses to the messages it already sent are held back until it
+// is resumed.
+type EventPoolPauseQuery struct {
+	QueryID QueryID // the id of the query that should be paused
+}
+
+// EventPoolResumeQuery notifies a pool to resume a paused query, which is then advanced with the
+// responses held back while it was paused.
+type EventPoolResumeQuery struct {
+	QueryID QueryID // the id of the query that should be resumed
+}
+
 // EventPoolMessageResponse notifies a pool that a query that a sent message has received a successful response.
 type EventPoolMessageResponse[K kad.Key[K], A kad.Address[A]] struct {
 	QueryID  QueryID            // the id of the query that sent the message
@@ -354,6 +453,8 @@ type EventPoolPoll struct{}
 // poolEvent() ensures that only Pool events can be assigned to the PoolEvent interface.
 func (*EventPoolAddQuery[K, A]) poolEvent()        {}
 func (*EventPoolStopQuery) poolEvent()             {}
+func (*EventPoolPauseQuery) poolEvent()            {}
+func (*EventPoolResumeQuery) poolEvent()           {}
 func (*EventPoolMessageResponse[K, A]) poolEvent() {}
 func (*EventPoolMessageFailure[K]) poolEvent()     {}
 func (*EventPoolPoll) poolEvent()                  {}
@@ -7,8 +7,8 @@ import (
 	"github.com/benbjohnson/clock"
 	"github.com/stretchr/testify/require"
 
-	"github.com/plprobelab/go-kademlia/internal/kadtest"
 	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
 	"github.com/plprobelab/go-kademlia/key"
 	"github.com/plprobelab/go-kademlia/network/address"
 )
@@ -368,3 +368,95 @@ func TestPoolRespectsConcurrency(t *testing.T) {
 	require.Equal(t, queryID3, st.QueryID)
 	require.Equal(t, a, st.NodeID)
 }
+
+func TestPoolPauseQuery(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultPoolConfig()
+	cfg.Clock = clk
+
+	self := kadtest.NewID(key.Key8(0))
+	p, err := NewPool[key.Key8, kadtest.StrAddr](self, cfg)
+	require.NoError(t, err)
+
+	target := key.Key8(0b00000001)
+	a := kadtest.NewID(key.Key8(0b00000100)) // 4
+	b := kadtest.NewID(key.Key8(0b00001000)) // 8
+	c := kadtest.NewID(key.Key8(0b00000010)) // 2, returned by a
+
+	msg := kadtest.NewRequest("1", target)
+	queryID := QueryID("test")
+
+	// the query contacts a and b
+	state := p.Advance(ctx, &EventPoolAddQuery[key.Key8, kadtest.StrAddr]{
+		QueryID:           queryID,
+		Target:            target,
+		ProtocolID:        address.ProtocolID("testprotocol"),
+		Message:           msg,
+		KnownClosestNodes: []kad.NodeID[key.Key8]{a, b},
+	})
+	require.IsType(t, &StatePoolQueryMessage[key.Key8, kadtest.StrAddr]{}, state)
+	require.Equal(t, a, state.(*StatePoolQueryMessage[key.Key8, kadtest.StrAddr]).NodeID)
+	state = p.Advance(ctx, &EventPoolPoll{})
+	require.IsType(t, &StatePoolQueryMessage[key.Key8, kadtest.StrAddr]{}, state)
+	require.Equal(t, b, state.(*StatePoolQueryMessage[key.Key8, kadtest.StrAddr]).NodeID)
+
+	// the response of a is held back while the query is paused, so c is not contacted
+	state = p.Advance(ctx, &EventPoolPauseQuery{QueryID: queryID})
+	require.IsType(t, &StatePoolIdle{}, state)
+	state = p.Advance(ctx, &EventPoolMessageResponse[key.Key8, kadtest.StrAddr]{
+		QueryID:  queryID,
+		NodeID:   a,
+		Response: kadtest.NewResponse("resp", []kad.NodeInfo[key.Key8, kadtest.StrAddr]{kadtest.NewInfo[key.Key8, kadtest.StrAddr](c, nil)}),
+	})
+	require.IsType(t, &StatePoolIdle{}, state)
+
+	// once resumed, the query is advanced with the response of a and contacts c
+	state = p.Advance(ctx, &EventPoolResumeQuery{QueryID: queryID})
+	require.IsType(t, &StatePoolQueryMessage[key.Key8, kadtest.StrAddr]{}, state)
+	require.Equal(t, c, state.(*StatePoolQueryMessage[key.Key8, kadtest.StrAddr]).NodeID)
+
+	state = p.Advance(ctx, &EventPoolMessageResponse[key.Key8, kadtest.StrAddr]{QueryID: queryID, NodeID: b})
+	require.IsType(t, &StatePoolWaitingWithCapacity{}, state)
+	state = p.Advance(ctx, &EventPoolMessageResponse[key.Key8, kadtest.StrAddr]{QueryID: queryID, NodeID: c})
+	require.IsType(t, &StatePoolQueryFinished{}, state)
+	stf := state.(*StatePoolQueryFinished)
+	require.Equal(t, 3, stf.Stats.Requests)
+	require.Equal(t, 3, stf.Stats.Success)
+}
+
+func TestPoolStopPausedQuery(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultPoolConfig()
+	cfg.Clock = clk
+
+	self := kadtest.NewID(key.Key8(0))
+	p, err := NewPool[key.Key8, kadtest.StrAddr](self, cfg)
+	require.NoError(t, err)
+
+	a := kadtest.NewID(key.Key8(0b00000100)) // 4
+	queryID := QueryID("test")
+	state := p.Advance(ctx, &EventPoolAddQuery[key.Key8, kadtest.StrAddr]{
+		QueryID:           queryID,
+		Target:            key.Key8(0b00000001),
+		ProtocolID:        address.ProtocolID("testprotocol"),
+		Message:           kadtest.NewRequest("1", key.Key8(0b00000001)),
+		KnownClosestNodes: []kad.NodeID[key.Key8]{a},
+	})
+	require.IsType(t, &StatePoolQueryMessage[key.Key8, kadtest.StrAddr]{}, state)
+
+	p.Advance(ctx, &EventPoolPauseQuery{QueryID: queryID})
+	p.Advance(ctx, &EventPoolMessageResponse[key.Key8, kadtest.StrAddr]{QueryID: queryID, NodeID: a})
+
+	// the paused query does not time out
+	clk.Add(2 * cfg.Timeout)
+	state = p.Advance(ctx, &EventPoolPoll{})
+	require.IsType(t, &StatePoolIdle{}, state)
+
+	// a paused query can be stopped, dropping the responses held back
+	state = p.Advance(ctx, &EventPoolStopQuery{QueryID: queryID})
+	require.IsType(t, &StatePoolQueryFinished{}, state)
+	require.Empty(t, p.paused)
+	require.Empty(t, p.held)
+}
@@ -0,0 +1,113 @@
+package query
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/kaderr"
+)
+
+// QuarantineConfig specifies optional configuration for a Quarantine
+type QuarantineConfig struct {
+	TTL   time.Duration // the duration for which an unreachable node is quarantined
+	Clock clock.Clock   // a clock that may replaced by a mock when testing
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *QuarantineConfig) Validate() error {
+	if cfg.Clock == nil {
+		return &kaderr.ConfigurationError{
+			Component: "QuarantineConfig",
+			Err:       fmt.Errorf("clock must not be nil"),
+		}
+	}
+	if cfg.TTL < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "QuarantineConfig",
+			Err:       fmt.Errorf("ttl must be greater than zero"),
+		}
+	}
+	return nil
+}
+
+// DefaultQuarantineConfig returns the default configuration options for a Quarantine.
+// Options may be overridden before passing to NewQuarantine
+func DefaultQuarantineConfig() *QuarantineConfig {
+	return &QuarantineConfig{
+		TTL:   10 * time.Minute,
+		Clock: clock.New(), // use standard time
+	}
+}
+
+// A Quarantine records nodes that were found to be unreachable so that queries sharing it can skip
+// them immediately instead of each waiting for a request to time out. Nodes are released from the
+// quarantine once their TTL has elapsed. Nodes are identified by the string form of their NodeID.
+// A Quarantine is safe for concurrent use.
+type Quarantine struct {
+	cfg QuarantineConfig
+
+	mu    sync.Mutex
+	nodes map[string]time.Time // expiry time of each quarantined node
+}
+
+// NewQuarantine creates a new Quarantine. If cfg is nil, the default config is used.
+func NewQuarantine(cfg *QuarantineConfig) (*Quarantine, error) {
+	if cfg == nil {
+		cfg = DefaultQuarantineConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Quarantine{
+		cfg:   *cfg,
+		nodes: make(map[string]time.Time),
+	}, nil
+}
+
+// Add quarantines a node for the configured TTL, extending the quarantine if it was already present.
+func (q *Quarantine) Add(id fmt.Stringer) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.nodes[id.String()] = q.cfg.Clock.Now().Add(q.cfg.TTL)
+}
+
+// Remove releases a node from the quarantine, for example after it was successfully contacted.
+func (q *Quarantine) Remove(id fmt.Stringer) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.nodes, id.String())
+}
+
+// Contains reports whether a node is currently quarantined.
+func (q *Quarantine) Contains(id fmt.Stringer) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	k := id.String()
+	expiry, ok := q.nodes[k]
+	if !ok {
+		return false
+	}
+	if !q.cfg.Clock.Now().Before(expiry) {
+		delete(q.nodes, k)
+		return false
+	}
+	return true
+}
+
+// Len returns the number of nodes currently quarantined.
+func (q *Quarantine) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.cfg.Clock.Now()
+	for k, expiry := range q.nodes {
+		if !now.Before(expiry) {
+			delete(q.nodes, k)
+		}
+	}
+	return len(q.nodes)
+}
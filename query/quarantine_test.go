@@ -0,0 +1,172 @@
+package query
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+func TestQuarantineConfigValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		cfg := DefaultQuarantineConfig()
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("clock is not nil", func(t *testing.T) {
+		cfg := DefaultQuarantineConfig()
+		cfg.Clock = nil
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("ttl positive", func(t *testing.T) {
+		cfg := DefaultQuarantineConfig()
+		cfg.TTL = 0
+		require.Error(t, cfg.Validate())
+		cfg.TTL = -1
+		require.Error(t, cfg.Validate())
+	})
+}
+
+func TestQuarantineExpiry(t *testing.T) {
+	clk := clock.NewMock()
+	cfg := DefaultQuarantineConfig()
+	cfg.Clock = clk
+	cfg.TTL = time.Minute
+
+	qt, err := NewQuarantine(cfg)
+	require.NoError(t, err)
+
+	a := kadtest.NewID(key.Key8(0b00000100))
+	b := kadtest.NewID(key.Key8(0b00001000))
+
+	require.False(t, qt.Contains(a))
+	qt.Add(a)
+	require.True(t, qt.Contains(a))
+	require.False(t, qt.Contains(b))
+	require.Equal(t, 1, qt.Len())
+
+	clk.Add(30 * time.Second)
+	qt.Add(b)
+	require.Equal(t, 2, qt.Len())
+
+	// a is released once its ttl has elapsed
+	clk.Add(30 * time.Second)
+	require.False(t, qt.Contains(a))
+	require.True(t, qt.Contains(b))
+	require.Equal(t, 1, qt.Len())
+
+	qt.Remove(b)
+	require.False(t, qt.Contains(b))
+	require.Equal(t, 0, qt.Len())
+}
+
+func TestQueryQuarantineSharedAcrossQueries(t *testing.T) {
+	ctx := context.Background()
+
+	target := key.Key8(0b00000001)
+	a := kadtest.NewID(key.Key8(0b00000100)) // 4
+	b := kadtest.NewID(key.Key8(0b00001000)) // 8
+
+	clk := clock.NewMock()
+
+	qcfg := DefaultQuarantineConfig()
+	qcfg.Clock = clk
+	qt, err := NewQuarantine(qcfg)
+	require.NoError(t, err)
+
+	cfg := DefaultQueryConfig[key.Key8]()
+	cfg.Clock = clk
+	cfg.Concurrency = 1
+	cfg.RequestTimeout = time.Minute
+	cfg.Quarantine = qt
+
+	msg := kadtest.NewRequest("1", target)
+	protocolID := address.ProtocolID("testprotocol")
+	self := kadtest.NewID(key.Key8(0))
+	knownNodes := []kad.NodeID[key.Key8]{a, b}
+
+	qry1, err := NewQuery[key.Key8, kadtest.StrAddr](self, QueryID("q1"), protocolID, msg, NewClosestNodesIter(target), knownNodes, cfg)
+	require.NoError(t, err)
+	qry2, err := NewQuery[key.Key8, kadtest.StrAddr](self, QueryID("q2"), protocolID, msg, NewClosestNodesIter(target), knownNodes, cfg)
+	require.NoError(t, err)
+
+	// the first query contacts the nearest node
+	state := qry1.Advance(ctx, nil)
+	require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+	require.Equal(t, a, state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]).NodeID)
+
+	// the request times out so the node is quarantined and the query moves on
+	clk.Add(2 * time.Minute)
+	state = qry1.Advance(ctx, nil)
+	require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+	require.Equal(t, b, state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]).NodeID)
+	require.True(t, qt.Contains(a))
+
+	// the second query skips the quarantined node without contacting it
+	state = qry2.Advance(ctx, nil)
+	require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+	stwm := state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr])
+	require.Equal(t, b, stwm.NodeID)
+	require.Equal(t, 1, stwm.Stats.Requests)
+
+	// a node that fails is quarantined too
+	qry2.Advance(ctx, &EventQueryMessageFailure[key.Key8]{NodeID: b})
+	require.True(t, qt.Contains(b))
+
+	// a successful response releases the node
+	qry1.Advance(ctx, &EventQueryMessageResponse[key.Key8, kadtest.StrAddr]{NodeID: b})
+	require.False(t, qt.Contains(b))
+}
+
+func TestQueryQuarantineByFailureClass(t *testing.T) {
+	ctx := context.Background()
+
+	target := key.Key8(0b00000001)
+	a := kadtest.NewID(key.Key8(0b00000100)) // 4
+	b := kadtest.NewID(key.Key8(0b00001000)) // 8
+	c := kadtest.NewID(key.Key8(0b00010000)) // 16
+
+	clk := clock.NewMock()
+
+	qcfg := DefaultQuarantineConfig()
+	qcfg.Clock = clk
+	qt, err := NewQuarantine(qcfg)
+	require.NoError(t, err)
+
+	cfg := DefaultQueryConfig[key.Key8]()
+	cfg.Clock = clk
+	cfg.Concurrency = 3
+	cfg.Quarantine = qt
+
+	msg := kadtest.NewRequest("1", target)
+	protocolID := address.ProtocolID("testprotocol")
+	self := kadtest.NewID(key.Key8(0))
+	knownNodes := []kad.NodeID[key.Key8]{a, b, c}
+
+	qry, err := NewQuery[key.Key8, kadtest.StrAddr](self, QueryID("test"), protocolID, msg, NewClosestNodesIter(target), knownNodes, cfg)
+	require.NoError(t, err)
+
+	for range knownNodes {
+		state := qry.Advance(ctx, nil)
+		require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+	}
+
+	// a node that cannot be dialled is unreachable
+	qry.Advance(ctx, &EventQueryMessageFailure[key.Key8]{NodeID: a, Error: endpoint.ErrDialBackoff})
+	require.True(t, qt.Contains(a))
+
+	// nodes that answered, even uselessly, are reachable
+	qry.Advance(ctx, &EventQueryMessageFailure[key.Key8]{NodeID: b, Error: endpoint.ErrMalformedMessage})
+	require.False(t, qt.Contains(b))
+	qry.Advance(ctx, &EventQueryMessageFailure[key.Key8]{NodeID: c, Error: endpoint.ErrProtocolNotSupported})
+	require.False(t, qt.Contains(c))
+}
@@ -10,7 +10,10 @@ import (
 	"github.com/plprobelab/go-kademlia/kad"
 	"github.com/plprobelab/go-kademlia/kaderr"
 	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/logging"
 	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+	"github.com/plprobelab/go-kademlia/routing/denylist"
 	"github.com/plprobelab/go-kademlia/util"
 )
 
@@ -24,6 +27,7 @@ type QueryStats struct {
 	Requests int
 	Success  int
 	Failure  int
+	Hops     int // the largest number of hops to a node that responded, 1 if only the seed nodes did
 }
 
 type QueryState interface {
@@ -89,10 +93,18 @@ func (*EventQueryMessageFailure[K]) queryEvent()     {}
 
 // QueryConfig specifies optional configuration for a Query
 type QueryConfig[K kad.Key[K]] struct {
-	Concurrency    int           // the maximum number of concurrent requests that may be in flight
-	NumResults     int           // the minimum number of nodes to successfully contact before considering iteration complete
-	RequestTimeout time.Duration // the timeout for contacting a single node
-	Clock          clock.Clock   // a clock that may replaced by a mock when testing
+	Concurrency    int                    // the maximum number of concurrent requests that may be in flight
+	NumResults     int                    // the minimum number of nodes to successfully contact before considering iteration complete
+	RequestTimeout time.Duration          // the timeout for contacting a single node
+	Clock          clock.Clock            // a clock that may replaced by a mock when testing
+	Quarantine     *Quarantine            // an optional quarantine of unreachable nodes shared with other queries
+	DenyList       *denylist.PeerDenyList // an optional list of banned nodes that must not be contacted
+	Logger         logging.Logger         // an optional logger the query emits events to, nil logs nothing
+	Acceleration   *AccelerationConfig    // an optional configuration resolving the query from the known closest nodes when they are close enough, nil always looks up the network
+
+	// RequestTimeoutFunc optionally gives the timeout for contacting each node, such as one adapted to
+	// the latency of the node by endpoint.AdaptiveTimeout. RequestTimeout is used if it returns zero.
+	RequestTimeoutFunc func(fmt.Stringer) time.Duration
 }
 
 // Validate checks the configuration options and returns an error if any have invalid values.
@@ -121,16 +133,30 @@ func (cfg *QueryConfig[K]) Validate() error {
 			Err:       fmt.Errorf("request timeout must be greater than zero"),
 		}
 	}
+	if cfg.Acceleration != nil {
+		if err := cfg.Acceleration.Validate(); err != nil {
+			return &kaderr.ConfigurationError{
+				Component: "QueryConfig",
+				Err:       err,
+			}
+		}
+	}
 	return nil
 }
 
 // DefaultQueryConfig returns the default configuration options for a Query.
 // Options may be overridden before passing to NewQuery
 func DefaultQueryConfig[K kad.Key[K]]() *QueryConfig[K] {
+	return QueryConfigFrom[K](kad.DefaultConfig())
+}
+
+// QueryConfigFrom returns the default configuration options for a Query with the concurrency,
+// resiliency and request timeout of kcfg.
+func QueryConfigFrom[K kad.Key[K]](kcfg *kad.Config) *QueryConfig[K] {
 	return &QueryConfig[K]{
-		Concurrency:    3,
-		NumResults:     20,
-		RequestTimeout: time.Minute,
+		Concurrency:    kcfg.Concurrency,
+		NumResults:     kcfg.Resiliency,
+		RequestTimeout: kcfg.RequestTimeout,
 		Clock:          clock.New(), // use standard time
 	}
 }
@@ -141,6 +167,7 @@ type Query[K kad.Key[K], A kad.Address[A]] struct {
 
 	// cfg is a copy of the optional configuration supplied to the query
 	cfg QueryConfig[K]
+	log logging.Logger
 
 	iter       NodeIter[K]
 	protocolID address.ProtocolID
@@ -152,6 +179,14 @@ type Query[K kad.Key[K], A kad.Address[A]] struct {
 
 	// inFlight is number of requests in flight, will be <= concurrency
 	inFlight int
+
+	// verifyOnly indicates that closer nodes returned in responses are not added to the iteration,
+	// limiting the query to the known closest nodes it was seeded with.
+	verifyOnly bool
+
+	// fallback holds the known nodes that were not candidates and the closer nodes returned while
+	// verifying the candidates, which the iteration falls back to if too few candidates respond.
+	fallback []*NodeStatus[K]
 }
 
 func NewQuery[K kad.Key[K], A kad.Address[A]](self kad.NodeID[K], id QueryID, protocolID address.ProtocolID, msg kad.Request[K, A], iter NodeIter[K], knownClosestNodes []kad.NodeID[K], cfg *QueryConfig[K]) (*Query[K, A], error) {
@@ -161,6 +196,25 @@ func NewQuery[K kad.Key[K], A kad.Address[A]](self kad.NodeID[K], id QueryID, pr
 		return nil, err
 	}
 
+	log := logging.OrNop(cfg.Logger)
+	verifyOnly := false
+	var fallback []*NodeStatus[K]
+	if cfg.Acceleration != nil {
+		if candidates, others, ok := accelerationCandidates(cfg.Acceleration, self, msg.Target(), knownClosestNodes); ok {
+			// the closest nodes are known, only check that they are live
+			knownClosestNodes = candidates
+			for _, node := range others {
+				fallback = append(fallback, &NodeStatus[K]{
+					NodeID: node,
+					State:  &StateNodeNotContacted{},
+					Hops:   1,
+				})
+			}
+			verifyOnly = true
+			log.Debug("query accelerated", logging.String("query", string(id)), logging.Int("candidates", len(candidates)))
+		}
+	}
+
 	for _, node := range knownClosestNodes {
 		// exclude self from closest nodes
 		if key.Equal(node.Key(), self.Key()) {
@@ -169,6 +223,7 @@ func NewQuery[K kad.Key[K], A kad.Address[A]](self kad.NodeID[K], id QueryID, pr
 		iter.Add(&NodeStatus[K]{
 			NodeID: node,
 			State:  &StateNodeNotContacted{},
+			Hops:   1,
 		})
 	}
 
@@ -176,9 +231,12 @@ func NewQuery[K kad.Key[K], A kad.Address[A]](self kad.NodeID[K], id QueryID, pr
 		self:       self,
 		id:         id,
 		cfg:        *cfg,
+		log:        log,
 		iter:       iter,
 		protocolID: protocolID,
 		msg:        msg,
+		verifyOnly: verifyOnly,
+		fallback:   fallback,
 	}, nil
 }
 
@@ -202,7 +260,7 @@ func (q *Query[K, A]) Advance(ctx context.Context, ev QueryEvent) QueryState {
 	case *EventQueryMessageResponse[K, A]:
 		q.onMessageResponse(ctx, tev.NodeID, tev.Response)
 	case *EventQueryMessageFailure[K]:
-		q.onMessageFailure(ctx, tev.NodeID)
+		q.onMessageFailure(ctx, tev.NodeID, tev.Error)
 	case nil:
 		// TEMPORARY: no event to process
 	default:
@@ -233,6 +291,8 @@ func (q *Query[K, A]) Advance(ctx context.Context, ev QueryEvent) QueryState {
 				ni.State = &StateNodeUnresponsive{}
 				q.inFlight--
 				q.stats.Failure++
+				q.quarantine(ni.NodeID)
+				q.log.Debug("query node unresponsive", logging.String("query", string(q.id)), logging.Stringer("node", ni.NodeID))
 			} else if atCapacity() {
 				returnState = &StateQueryWaitingAtCapacity{
 					QueryID: q.id,
@@ -258,14 +318,25 @@ func (q *Query[K, A]) Advance(ctx context.Context, ev QueryEvent) QueryState {
 			}
 
 		case *StateNodeNotContacted:
+			if q.cfg.DenyList != nil && q.cfg.DenyList.Banned(ni.NodeID) {
+				// the node is banned, never contact it
+				ni.State = &StateNodeFailed{}
+				return false
+			}
+			if q.cfg.Quarantine != nil && q.cfg.Quarantine.Contains(ni.NodeID) {
+				// another query found the node to be unreachable, skip it
+				ni.State = &StateNodeFailed{}
+				return false
+			}
 			if !atCapacity() {
-				deadline := q.cfg.Clock.Now().Add(q.cfg.RequestTimeout)
+				deadline := q.cfg.Clock.Now().Add(q.requestTimeout(ni.NodeID))
 				ni.State = &StateNodeWaiting{Deadline: deadline}
 				q.inFlight++
 				q.stats.Requests++
 				if q.stats.Start.IsZero() {
 					q.stats.Start = q.cfg.Clock.Now()
 				}
+				q.log.Debug("query request", logging.String("query", string(q.id)), logging.Stringer("node", ni.NodeID))
 				returnState = &StateQueryWaitingMessage[K, A]{
 					NodeID:     ni.NodeID,
 					QueryID:    q.id,
@@ -304,6 +375,12 @@ func (q *Query[K, A]) Advance(ctx context.Context, ev QueryEvent) QueryState {
 		}
 	}
 
+	if q.verifyOnly {
+		// too few candidates responded to resolve the query from them, look up the network
+		q.fallBack()
+		return q.Advance(ctx, nil)
+	}
+
 	// The iterator is finished because all available nodes have been contacted
 	// and the iterator is not waiting for any more results.
 	q.markFinished()
@@ -317,7 +394,38 @@ func (q *Query[K, A]) markFinished() {
 	q.finished = true
 	if q.stats.End.IsZero() {
 		q.stats.End = q.cfg.Clock.Now()
+		q.log.Info("query finished",
+			logging.String("query", string(q.id)),
+			logging.Int("requests", q.stats.Requests),
+			logging.Int("success", q.stats.Success),
+			logging.Int("failure", q.stats.Failure),
+			logging.Int("hops", q.stats.Hops),
+			logging.Duration("duration", q.stats.End.Sub(q.stats.Start)))
+	}
+}
+
+// fallBack turns an accelerated query whose candidates did not resolve it into a normal
+// iteration, continuing from the nodes it knows of besides the candidates.
+func (q *Query[K, A]) fallBack() {
+	q.verifyOnly = false
+	q.log.Debug("accelerated query falls back to lookup", logging.String("query", string(q.id)), logging.Int("nodes", len(q.fallback)))
+	for _, ni := range q.fallback {
+		if _, found := q.iter.Find(ni.NodeID.Key()); found {
+			continue
+		}
+		q.iter.Add(ni)
 	}
+	q.fallback = nil
+}
+
+// requestTimeout returns the timeout for contacting node.
+func (q *Query[K, A]) requestTimeout(node kad.NodeID[K]) time.Duration {
+	if q.cfg.RequestTimeoutFunc != nil {
+		if t := q.cfg.RequestTimeoutFunc(node); t > 0 {
+			return t
+		}
+	}
+	return q.cfg.RequestTimeout
 }
 
 // onMessageResponse processes the result of a successful response received from a node.
@@ -348,23 +456,45 @@ func (q *Query[K, A]) onMessageResponse(ctx context.Context, node kad.NodeID[K],
 	}
 
 	if resp != nil {
-		// add closer nodes to list
+		// add closer nodes to list, or keep them for a fall back while verifying candidates
 		for _, info := range resp.CloserNodes() {
 			// exclude self from closest nodes
 			if key.Equal(info.ID().Key(), q.self.Key()) {
 				continue
 			}
-			q.iter.Add(&NodeStatus[K]{
+			closer := &NodeStatus[K]{
 				NodeID: info.ID(),
 				State:  &StateNodeNotContacted{},
-			})
+				Hops:   ni.Hops + 1,
+			}
+			if q.verifyOnly {
+				q.fallback = append(q.fallback, closer)
+				continue
+			}
+			q.iter.Add(closer)
 		}
 	}
 	ni.State = &StateNodeSucceeded{}
+	if ni.Hops > q.stats.Hops {
+		q.stats.Hops = ni.Hops
+	}
+	if q.cfg.Quarantine != nil {
+		// the node is reachable again
+		q.cfg.Quarantine.Remove(node)
+	}
+}
+
+// quarantine records that a node is unreachable in the quarantine shared by queries, if any.
+func (q *Query[K, A]) quarantine(node kad.NodeID[K]) {
+	if q.cfg.Quarantine != nil {
+		q.cfg.Quarantine.Add(node)
+	}
 }
 
-// onMessageFailure processes the result of a failed attempt to contact a node.
-func (q *Query[K, A]) onMessageFailure(ctx context.Context, node kad.NodeID[K]) {
+// onMessageFailure processes the result of a failed attempt to contact a node. A node that
+// answered with a garbage response or does not speak the protocol is reachable, so it is
+// not quarantined.
+func (q *Query[K, A]) onMessageFailure(ctx context.Context, node kad.NodeID[K], err error) {
 	ni, found := q.iter.Find(node.Key())
 	if !found {
 		// got a rogue message
@@ -374,6 +504,12 @@ func (q *Query[K, A]) onMessageFailure(ctx context.Context, node kad.NodeID[K])
 	case *StateNodeWaiting:
 		q.inFlight--
 		q.stats.Failure++
+		q.log.Debug("query request failed", logging.String("query", string(q.id)), logging.Stringer("node", node), logging.Error(err))
+		switch endpoint.Classify(err) {
+		case endpoint.FailureGarbageResponse, endpoint.FailureProtocolMismatch:
+		default:
+			q.quarantine(node)
+		}
 	case *StateNodeUnresponsive:
 		// update node state to failed
 		break
@@ -2,16 +2,18 @@ package query
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/benbjohnson/clock"
 	"github.com/stretchr/testify/require"
 
-	"github.com/plprobelab/go-kademlia/internal/kadtest"
 	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
 	"github.com/plprobelab/go-kademlia/key"
 	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/routing/denylist"
 )
 
 func TestQueryConfigValidate(t *testing.T) {
@@ -20,6 +22,17 @@ func TestQueryConfigValidate(t *testing.T) {
 		require.NoError(t, cfg.Validate())
 	})
 
+	t.Run("from kad config", func(t *testing.T) {
+		kcfg := kad.DefaultConfig()
+		kcfg.Concurrency = 5
+		kcfg.Resiliency = 3
+		cfg := QueryConfigFrom[key.Key8](kcfg)
+		require.NoError(t, cfg.Validate())
+		require.Equal(t, 5, cfg.Concurrency)
+		require.Equal(t, 3, cfg.NumResults)
+		require.Equal(t, kcfg.RequestTimeout, cfg.RequestTimeout)
+	})
+
 	t.Run("clock is not nil", func(t *testing.T) {
 		cfg := DefaultQueryConfig[key.Key8]()
 		cfg.Clock = nil
@@ -529,6 +542,58 @@ func TestQueryCloserNodesAreAddedToIteration(t *testing.T) {
 	require.Equal(t, a, st.NodeID)
 }
 
+func TestQueryStatsHops(t *testing.T) {
+	ctx := context.Background()
+
+	target := key.Key8(0b00000001)
+	a := kadtest.NewID(key.Key8(0b00000100)) // 4
+	b := kadtest.NewID(key.Key8(0b00001000)) // 8
+	c := kadtest.NewID(key.Key8(0b00010000)) // 16
+	d := kadtest.NewID(key.Key8(0b00100000)) // 32
+
+	cfg := DefaultQueryConfig[key.Key8]()
+	cfg.Clock = clock.NewMock()
+	cfg.Concurrency = 1
+	cfg.NumResults = 3
+
+	msg := kadtest.NewRequest("1", target)
+	self := kadtest.NewID(key.Key8(0))
+	qry, err := NewQuery[key.Key8, kadtest.StrAddr](self, "test", address.ProtocolID("testprotocol"), msg, NewClosestNodesIter(target), []kad.NodeID[key.Key8]{c, d}, cfg)
+	require.NoError(t, err)
+
+	state := qry.Advance(ctx, nil)
+	require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+	require.Equal(t, c, state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]).NodeID)
+
+	// b is learnt from the response of the seed node c
+	state = qry.Advance(ctx, &EventQueryMessageResponse[key.Key8, kadtest.StrAddr]{
+		NodeID: c,
+		Response: kadtest.NewResponse("resp_c", []kad.NodeInfo[key.Key8, kadtest.StrAddr]{
+			kadtest.NewInfo(b, []kadtest.StrAddr{"addr_b"}),
+		}),
+	})
+	require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+	require.Equal(t, b, state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]).NodeID)
+	require.Equal(t, 1, state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]).Stats.Hops)
+
+	// a from that of b
+	state = qry.Advance(ctx, &EventQueryMessageResponse[key.Key8, kadtest.StrAddr]{
+		NodeID: b,
+		Response: kadtest.NewResponse("resp_b", []kad.NodeInfo[key.Key8, kadtest.StrAddr]{
+			kadtest.NewInfo(a, []kadtest.StrAddr{"addr_a"}),
+		}),
+	})
+	require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+	require.Equal(t, a, state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]).NodeID)
+	require.Equal(t, 2, state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]).Stats.Hops)
+
+	// the query took three hops, although four nodes were known
+	state = qry.Advance(ctx, &EventQueryMessageResponse[key.Key8, kadtest.StrAddr]{NodeID: a})
+	require.IsType(t, &StateQueryFinished{}, state)
+	require.Equal(t, 3, state.(*StateQueryFinished).Stats.Hops)
+	require.Equal(t, 3, state.(*StateQueryFinished).Stats.Success)
+}
+
 func TestQueryCloserNodesIgnoresDuplicates(t *testing.T) {
 	ctx := context.Background()
 
@@ -1117,3 +1182,139 @@ func TestQueryNeverMessagesSelf(t *testing.T) {
 	require.Equal(t, 1, stf.Stats.Success)
 	require.Equal(t, 0, stf.Stats.Failure)
 }
+
+func TestQueryDenyList(t *testing.T) {
+	ctx := context.Background()
+
+	target := key.Key8(0b00000001)
+	a := kadtest.NewID(key.Key8(0b00000100)) // 4
+	b := kadtest.NewID(key.Key8(0b00001000)) // 8
+
+	d, err := denylist.New(nil)
+	require.NoError(t, err)
+	d.Ban(a, 0)
+
+	clk := clock.NewMock()
+	cfg := DefaultQueryConfig[key.Key8]()
+	cfg.Clock = clk
+	cfg.DenyList = d
+
+	msg := kadtest.NewRequest("1", target)
+	protocolID := address.ProtocolID("testprotocol")
+	self := kadtest.NewID(key.Key8(0))
+	knownNodes := []kad.NodeID[key.Key8]{a, b}
+
+	qry, err := NewQuery[key.Key8, kadtest.StrAddr](self, QueryID("test"), protocolID, msg, NewClosestNodesIter(target), knownNodes, cfg)
+	require.NoError(t, err)
+
+	// the query skips the banned nearest node
+	state := qry.Advance(ctx, nil)
+	require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+	stwm := state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr])
+	require.Equal(t, b, stwm.NodeID)
+	require.Equal(t, 1, stwm.Stats.Requests)
+}
+
+func TestQueryRequestTimeoutFunc(t *testing.T) {
+	ctx := context.Background()
+
+	target := key.Key8(0b00000001)
+	a := kadtest.NewID(key.Key8(0b00000100)) // 4
+	b := kadtest.NewID(key.Key8(0b00001000)) // 8
+	c := kadtest.NewID(key.Key8(0b00010000)) // 16
+
+	clk := clock.NewMock()
+	iter := NewClosestNodesIter(target)
+
+	cfg := DefaultQueryConfig[key.Key8]()
+	cfg.Clock = clk
+	cfg.RequestTimeout = 3 * time.Minute
+	cfg.Concurrency = 2
+	// node a is known to answer quickly, other nodes use the default timeout
+	cfg.RequestTimeoutFunc = func(node fmt.Stringer) time.Duration {
+		if node.String() == a.String() {
+			return time.Second
+		}
+		return 0
+	}
+
+	msg := kadtest.NewRequest("1", target)
+	self := kadtest.NewID(key.Key8(0))
+	qry, err := NewQuery[key.Key8, kadtest.StrAddr](self, QueryID("test"), address.ProtocolID("testprotocol"), msg, iter, []kad.NodeID[key.Key8]{a, b, c}, cfg)
+	require.NoError(t, err)
+
+	state := qry.Advance(ctx, nil)
+	require.Equal(t, a, state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]).NodeID)
+	state = qry.Advance(ctx, nil)
+	require.Equal(t, b, state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]).NodeID)
+	state = qry.Advance(ctx, nil)
+	require.IsType(t, &StateQueryWaitingAtCapacity{}, state)
+
+	// the request to a times out after its own timeout, making capacity for c
+	clk.Add(2 * time.Second)
+	state = qry.Advance(ctx, nil)
+	require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+	stwm := state.(*StateQueryWaitingMessage[key.Key8, kadtest.StrAddr])
+	require.Equal(t, c, stwm.NodeID)
+	require.Equal(t, 1, stwm.Stats.Failure)
+
+	// b is still within the default timeout
+	clk.Add(time.Minute)
+	state = qry.Advance(ctx, nil)
+	require.IsType(t, &StateQueryWaitingAtCapacity{}, state)
+}
+
+func TestQueryLogger(t *testing.T) {
+	ctx := context.Background()
+
+	target := key.Key8(0b00000001)
+	a := kadtest.NewID(key.Key8(0b00000100)) // 4
+	b := kadtest.NewID(key.Key8(0b00001000)) // 8
+
+	clk := clock.NewMock()
+	logger := kadtest.NewLogger()
+
+	cfg := DefaultQueryConfig[key.Key8]()
+	cfg.Clock = clk
+	cfg.RequestTimeout = time.Minute
+	cfg.Logger = logger
+
+	msg := kadtest.NewRequest("1", target)
+	self := kadtest.NewID(key.Key8(0))
+	qry, err := NewQuery[key.Key8, kadtest.StrAddr](self, "test", "testprotocol", msg, NewClosestNodesIter(target), []kad.NodeID[key.Key8]{a, b}, cfg)
+	require.NoError(t, err)
+
+	// requests are sent to both nodes
+	state := qry.Advance(ctx, nil)
+	require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+	state = qry.Advance(ctx, nil)
+	require.IsType(t, &StateQueryWaitingMessage[key.Key8, kadtest.StrAddr]{}, state)
+	reqs := logger.Entries("query request")
+	require.Len(t, reqs, 2)
+	require.Equal(t, "debug", reqs[0].Level)
+	require.Equal(t, "test", reqs[0].Fields["query"])
+	require.Equal(t, a.String(), reqs[0].Fields["node"])
+
+	// the first node fails, the second one does not answer in time
+	qry.Advance(ctx, &EventQueryMessageFailure[key.Key8]{NodeID: a, Error: fmt.Errorf("boom")})
+	failed := logger.Entries("query request failed")
+	require.Len(t, failed, 1)
+	require.Equal(t, a.String(), failed[0].Fields["node"])
+	require.EqualError(t, failed[0].Fields["error"].(error), "boom")
+
+	clk.Add(2 * time.Minute)
+	state = qry.Advance(ctx, nil)
+	require.IsType(t, &StateQueryFinished{}, state)
+	unresponsive := logger.Entries("query node unresponsive")
+	require.Len(t, unresponsive, 1)
+	require.Equal(t, b.String(), unresponsive[0].Fields["node"])
+
+	// the end of the query is logged once
+	qry.Advance(ctx, nil)
+	finished := logger.Entries("query finished")
+	require.Len(t, finished, 1)
+	require.Equal(t, "info", finished[0].Level)
+	require.Equal(t, 2, finished[0].Fields["requests"])
+	require.Equal(t, 2, finished[0].Fields["failure"])
+	require.Equal(t, 2*time.Minute, finished[0].Fields["duration"])
+}
@@ -43,6 +43,11 @@ type Config[K kad.Key[K], A kad.Address[A]] struct {
 	Endpoint endpoint.Endpoint[K, A]
 	// Scheduler is the scheduler used to schedule events for the single worker
 	Scheduler event.Scheduler
+
+	// CloserNodes optionally validates the closer nodes of responses. The rejected
+	// nodes are never queried, and responses without accepted closer nodes don't
+	// add the responder to the routing table.
+	CloserNodes *endpoint.CloserNodesPolicy[K, A]
 }
 
 // Apply applies the SimpleQuery options to this Option
@@ -181,3 +186,10 @@ func WithScheduler[K kad.Key[K], A kad.Address[A]](sched event.Scheduler) Option
 		return nil
 	}
 }
+
+func WithCloserNodesPolicy[K kad.Key[K], A kad.Address[A]](p *endpoint.CloserNodesPolicy[K, A]) Option[K, A] {
+	return func(cfg *Config[K, A]) error {
+		cfg.CloserNodes = p
+		return nil
+	}
+}
@@ -4,13 +4,14 @@ import (
 	"context"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/stretchr/testify/require"
 
-	"github.com/plprobelab/go-kademlia/internal/kadtest"
 	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
 	"github.com/plprobelab/go-kademlia/key"
 	"github.com/plprobelab/go-kademlia/sim"
 )
@@ -189,7 +190,7 @@ func TestMultiAddrs(t *testing.T) {
 		id := kadtest.NewID(kadtest.Key256WithLeadingBytes([]byte{byte(16 * i)}))
 		ids[i] = id
 		addrs[i] = kadtest.NewInfo[key.Key256, net.IP](id, []net.IP{})
-		ep.MaybeAddToPeerstore(ctx, addrs[i], 1)
+		ep.MaybeAddToPeerstore(ctx, addrs[i], time.Hour)
 	}
 
 	// ids[0]: 0000...
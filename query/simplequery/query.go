@@ -41,9 +41,13 @@ type SimpleQuery[K kad.Key[K], A kad.Address[A]] struct {
 	rt          kad.RoutingTable[K, kad.NodeID[K]]
 	sched       event.Scheduler
 
-	inflightRequests int // requests that are either in flight or scheduled
+	inflightRequests int                        // requests that are either in flight or scheduled
+	queuedRequests   []*event.CancellableAction // the scheduled requests that may not have run yet
 	peerlist         *PeerList[K, A]
 
+	// closerNodes optionally validates the closer nodes of responses
+	closerNodes *endpoint.CloserNodesPolicy[K, A]
+
 	// response handling
 	handleResultFn HandleResultFn[K, A]
 	// failure callback
@@ -61,7 +65,7 @@ func NewSimpleQuery[K kad.Key[K], A kad.Address[A]](ctx context.Context, self ka
 	opts ...Option[K, A],
 ) (*SimpleQuery[K, A], error) {
 	ctx, span := util.StartSpan(ctx, "SimpleQuery.NewSimpleQuery",
-		trace.WithAttributes(attribute.String("Target", key.HexString(req.Target()))))
+		trace.WithAttributes(attribute.String("Target", key.FormatHex(req.Target()))))
 	defer span.End()
 
 	// apply options
@@ -95,6 +99,7 @@ func NewSimpleQuery[K kad.Key[K], A kad.Address[A]](ctx context.Context, self ka
 		rt:              cfg.RoutingTable,
 		msgEndpoint:     cfg.Endpoint,
 		sched:           cfg.Scheduler,
+		closerNodes:     cfg.CloserNodes,
 		handleResultFn:  cfg.HandleResultsFunc,
 		notifyFailureFn: cfg.NotifyFailureFunc,
 		peerlist:        pl,
@@ -144,10 +149,19 @@ func (q *SimpleQuery[K, A]) enqueueNewRequests(ctx context.Context) {
 	span.AddEvent("newRequestsToSend: " + strconv.Itoa(newRequestsToSend) +
 		" q.inflightRequests: " + strconv.Itoa(q.inflightRequests))
 
-	for i := 0; i < newRequestsToSend; i++ {
-		// add new pending request(s) for this query to eventqueue
-		q.sched.EnqueueAction(ctx, event.BasicAction(q.newRequest))
+	// add new pending request(s) for this query to eventqueue
+	requests := make([]event.Action, newRequestsToSend)
+	name := "SimpleQuery.newRequest(target=" + key.FormatHex(q.req.Target()) + ")"
+	for i := range requests {
+		requests[i] = event.NewNamedAction(name, event.BasicAction(q.newRequest))
+	}
+	pending := q.queuedRequests[:0]
+	for _, r := range q.queuedRequests {
+		if r.Pending() {
+			pending = append(pending, r)
+		}
 	}
+	q.queuedRequests = append(pending, event.EnqueueActions(ctx, q.sched, requests)...)
 	// increase number of inflight requests. Note that it counts both queued
 	// requests and requests in flight
 	q.inflightRequests += newRequestsToSend
@@ -157,7 +171,7 @@ func (q *SimpleQuery[K, A]) enqueueNewRequests(ctx context.Context) {
 
 // newRequest sends a request to the closest peer that hasn't been queried yet.
 func (q *SimpleQuery[K, A]) newRequest(ctx context.Context) {
-	ctx, span := util.StartSpan(ctx, "SimpleQuery.newRequest")
+	ctx, span := util.StartMessageSpan(ctx, "SimpleQuery.newRequest", nil)
 	defer span.End()
 
 	if err := q.checkIfDone(); err != nil {
@@ -199,13 +213,28 @@ func (q *SimpleQuery[K, A]) newRequest(ctx context.Context) {
 	}
 }
 
+// cancelQueuedRequests cancels the requests that are still in the event queue, so that they
+// don't run once the query is done.
+func (q *SimpleQuery[K, A]) cancelQueuedRequests() {
+	for _, r := range q.queuedRequests {
+		if r.Cancel() {
+			// the request will never run
+			q.inflightRequests--
+		}
+	}
+	q.queuedRequests = nil
+}
+
 // handleResponse handles a response to a past query request
 func (q *SimpleQuery[K, A]) handleResponse(ctx context.Context, id kad.NodeID[K],
 	resp kad.Response[K, A],
 ) {
-	ctx, span := util.StartSpan(ctx, "SimpleQuery.handleResponse",
-		trace.WithAttributes(attribute.String("Target", key.HexString(q.req.Target())),
-			attribute.String("From Peer", id.String())))
+	ctx, span := util.StartMessageSpan(ctx, "SimpleQuery.handleResponse", func() []attribute.KeyValue {
+		return []attribute.KeyValue{
+			attribute.String("Target", key.FormatHex(q.req.Target())),
+			attribute.String("From Peer", id.String()),
+		}
+	})
 	defer span.End()
 
 	if err := q.checkIfDone(); err != nil {
@@ -223,6 +252,21 @@ func (q *SimpleQuery[K, A]) handleResponse(ctx context.Context, id kad.NodeID[K]
 	}
 
 	closerPeers := resp.CloserNodes()
+	// the keys of the closer peers rejected by the policy, which are never queried
+	var rejected map[string]struct{}
+	if q.closerNodes != nil {
+		accepted := q.closerNodes.Filter(id, q.req.Target(), closerPeers)
+		if len(accepted) < len(closerPeers) {
+			rejected = make(map[string]struct{})
+			for _, n := range closerPeers {
+				rejected[key.FormatHex(n.ID().Key())] = struct{}{}
+			}
+			for _, n := range accepted {
+				delete(rejected, key.FormatHex(n.ID().Key()))
+			}
+		}
+		closerPeers = accepted
+	}
 	if len(closerPeers) > 0 {
 		// consider that remote peer is behaving correctly if it returns
 		// at least 1 peer. We add it to our routing table only if it behaves
@@ -243,18 +287,21 @@ func (q *SimpleQuery[K, A]) handleResponse(ctx context.Context, id kad.NodeID[K]
 		// query is done, don't send any more requests
 		span.AddEvent("query over")
 		q.done = true
+		q.cancelQueuedRequests()
 		return
 	}
 
-	// remove all occurreneces of q.self from usefulNodeIDs
+	// remove all occurreneces of q.self and of the rejected closer peers from usefulNodeIDs
 	writeIndex := 0
 	for _, id := range usefulNodeIDs {
-		if !key.Equal(q.self.Key(), id.Key()) {
+		if key.Equal(q.self.Key(), id.Key()) {
+			span.AddEvent("never add self to query peerlist")
+		} else if _, ok := rejected[key.FormatHex(id.Key())]; ok {
+			span.AddEvent("rejected closer peer not added to query peerlist")
+		} else {
 			// id is valid and isn't self
 			usefulNodeIDs[writeIndex] = id
 			writeIndex++
-		} else {
-			span.AddEvent("never add self to query peerlist")
 		}
 	}
 	usefulNodeIDs = usefulNodeIDs[:writeIndex]
@@ -269,9 +316,12 @@ func (q *SimpleQuery[K, A]) handleResponse(ctx context.Context, id kad.NodeID[K]
 // requestError handle an error that occured while sending a request or
 // receiving a response.
 func (q *SimpleQuery[K, A]) requestError(ctx context.Context, id kad.NodeID[K], err error) {
-	ctx, span := util.StartSpan(ctx, "SimpleQuery.requestError",
-		trace.WithAttributes(attribute.String("PeerID", id.String()),
-			attribute.String("Error", err.Error())))
+	ctx, span := util.StartMessageSpan(ctx, "SimpleQuery.requestError", func() []attribute.KeyValue {
+		return []attribute.KeyValue{
+			attribute.String("PeerID", id.String()),
+			attribute.String("Error", err.Error()),
+		}
+	})
 	defer span.End()
 
 	// the request isn't in flight anymore since it failed
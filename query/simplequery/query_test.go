@@ -12,10 +12,11 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/plprobelab/go-kademlia/event"
-	"github.com/plprobelab/go-kademlia/internal/kadtest"
 	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
 	"github.com/plprobelab/go-kademlia/key"
 	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
 	"github.com/plprobelab/go-kademlia/routing/simplert"
 	"github.com/plprobelab/go-kademlia/server"
 	"github.com/plprobelab/go-kademlia/sim"
@@ -712,3 +713,105 @@ func TestCornerCases(t *testing.T) {
 
 	require.True(t, q.done)
 }
+
+func TestCloserNodesPolicy(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+
+	protoID := address.ProtocolID("/test/1.0.0")
+	peerstoreTTL := time.Minute
+
+	router := sim.NewRouter[key.Key8, net.IP]()
+	node0 := kadtest.NewInfo[key.Key8, net.IP](kadtest.NewID(key.Key8(0x00)), nil)
+	sched0 := event.NewSimpleScheduler(clk)
+	fendpoint0 := sim.NewEndpoint(node0.ID(), sched0, router)
+	rt0 := simplert.New[key.Key8, kad.NodeID[key.Key8]](node0.ID(), 4)
+
+	node1 := kadtest.NewInfo[key.Key8, net.IP](kadtest.NewID(key.Key8(0x01)), nil)
+	node2 := kadtest.NewInfo[key.Key8, net.IP](kadtest.NewID(key.Key8(0x02)), nil)
+	fendpoint0.MaybeAddToPeerstore(ctx, node1, peerstoreTTL)
+	fendpoint0.MaybeAddToPeerstore(ctx, node2, peerstoreTTL)
+
+	// the query starts from a node far from the target
+	seed := kadtest.NewInfo[key.Key8, net.IP](kadtest.NewID(key.Key8(0x80)), nil)
+	fendpoint0.MaybeAddToPeerstore(ctx, seed, peerstoreTTL)
+	success := rt0.AddNode(seed.ID())
+	require.True(t, success)
+
+	req := sim.NewRequest[key.Key8, net.IP](key.Key8(0xff))
+
+	responseHandler := func(ctx context.Context, sender kad.NodeID[key.Key8],
+		msg kad.Response[key.Key8, net.IP],
+	) (bool, []kad.NodeID[key.Key8]) {
+		ids := make([]kad.NodeID[key.Key8], len(msg.CloserNodes()))
+		for i, peer := range msg.CloserNodes() {
+			ids[i] = peer.ID()
+		}
+		return false, ids
+	}
+
+	policy := endpoint.NewCloserNodesPolicy[key.Key8, net.IP](node0.ID(), nil)
+	queryOpts := []Option[key.Key8, net.IP]{
+		WithProtocolID[key.Key8, net.IP](protoID),
+		WithConcurrency[key.Key8, net.IP](1),
+		WithNumberUsefulCloserPeers[key.Key8, net.IP](4),
+		WithRequestTimeout[key.Key8, net.IP](time.Millisecond),
+		WithEndpoint[key.Key8, net.IP](fendpoint0),
+		WithRoutingTable[key.Key8, net.IP](rt0),
+		WithScheduler[key.Key8, net.IP](sched0),
+		WithHandleResultsFunc(responseHandler),
+		WithCloserNodesPolicy[key.Key8, net.IP](policy),
+	}
+
+	q, err := NewSimpleQuery[key.Key8, net.IP](ctx, node0.ID(), req, queryOpts...)
+	require.NoError(t, err)
+
+	// a response holding only rejected closer nodes doesn't add the responder
+	// to the routing table
+	q.handleResponse(ctx, node1.ID(), sim.NewResponse([]kad.NodeInfo[key.Key8, net.IP]{node0, node1}))
+	require.Equal(t, 1, rt0.Size())
+	require.Equal(t, endpoint.CloserNodesStats{Self: 1, Responder: 1}, policy.Rejected(node1.ID()))
+
+	// the rejected closer nodes aren't added to the peerlist
+	node3 := kadtest.NewInfo[key.Key8, net.IP](kadtest.NewID(key.Key8(0xee)), nil)
+	q.handleResponse(ctx, node2.ID(), sim.NewResponse([]kad.NodeInfo[key.Key8, net.IP]{node0, node2, node3}))
+	require.Equal(t, 2, rt0.Size())
+	require.Equal(t, node3.ID(), q.peerlist.closest.id)
+	require.Equal(t, seed.ID(), q.peerlist.closest.next.id)
+	require.Nil(t, q.peerlist.closest.next.next)
+}
+
+func TestCancelQueuedRequests(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+
+	router := sim.NewRouter[key.Key8, net.IP]()
+	node0 := kadtest.NewInfo[key.Key8, net.IP](kadtest.NewID(key.Key8(0x00)), nil)
+	sched0 := event.NewSimpleScheduler(clk)
+	fendpoint0 := sim.NewEndpoint(node0.ID(), sched0, router)
+	rt0 := simplert.New[key.Key8, kad.NodeID[key.Key8]](node0.ID(), 4)
+	for _, k := range []key.Key8{0x01, 0x02, 0x03} {
+		require.True(t, rt0.AddNode(kadtest.NewID(k)))
+	}
+
+	req := sim.NewRequest[key.Key8, net.IP](key.Key8(0xff))
+	q, err := NewSimpleQuery[key.Key8, net.IP](ctx, node0.ID(), req,
+		WithConcurrency[key.Key8, net.IP](3),
+		WithEndpoint[key.Key8, net.IP](fendpoint0),
+		WithRoutingTable[key.Key8, net.IP](rt0),
+		WithScheduler[key.Key8, net.IP](sched0),
+		WithHandleResultsFunc(func(context.Context, kad.NodeID[key.Key8], kad.Response[key.Key8, net.IP]) (bool, []kad.NodeID[key.Key8]) {
+			return true, nil
+		}),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 3, q.inflightRequests)
+	require.Len(t, q.queuedRequests, 3)
+
+	// the requests that were still queued when the query stopped never run
+	q.done = true
+	q.cancelQueuedRequests()
+	require.Equal(t, 0, q.inflightRequests)
+	event.RunAll(ctx, sched0)
+	require.Zero(t, sched0.Stats().ActionsRun)
+}
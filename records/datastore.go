@@ -0,0 +1,147 @@
+package records
+
+import (
+	"context"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plprobelab/go-kademlia/datastore"
+)
+
+// datastorePrefix is the prefix of the datastore keys of records.
+const datastorePrefix = "/records/"
+
+// keyEncoding encodes record keys, which are arbitrary bytes, into datastore keys.
+var keyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// DatastoreStore is a RecordStore keeping its records in a datastore.Datastore, which may be
+// persistent.
+type DatastoreStore struct {
+	cfg Config
+	ds  datastore.Datastore
+
+	// mu serializes puts so that the record a new record is compared against is not replaced
+	// concurrently
+	mu sync.Mutex
+}
+
+var _ RecordStore = (*DatastoreStore)(nil)
+
+// NewDatastoreStore returns a DatastoreStore keeping its records in ds, which may already hold
+// records stored by a previous DatastoreStore. If cfg is nil, DefaultConfig is used.
+func NewDatastoreStore(ds datastore.Datastore, cfg *Config) (*DatastoreStore, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &DatastoreStore{
+		cfg: *cfg,
+		ds:  ds,
+	}, nil
+}
+
+func (s *DatastoreStore) Get(ctx context.Context, key []byte) (*Record, error) {
+	rec, err := s.get(ctx, datastoreKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if rec.Expired(s.cfg.Clock.Now()) {
+		return nil, ErrNotFound
+	}
+	return rec, nil
+}
+
+func (s *DatastoreStore) Put(ctx context.Context, rec *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dsKey := datastoreKey(rec.Key)
+	existing, err := s.get(ctx, dsKey)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	rec, err = prepare(&s.cfg, rec, existing)
+	if err != nil {
+		return err
+	}
+	return s.ds.Put(ctx, dsKey, encodeRecord(rec))
+}
+
+func (s *DatastoreStore) Delete(ctx context.Context, key []byte) error {
+	return s.ds.Delete(ctx, datastoreKey(key))
+}
+
+func (s *DatastoreStore) Sweep(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.ds.Keys(ctx, datastorePrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	now := s.cfg.Clock.Now()
+	removed := 0
+	for _, k := range keys {
+		rec, err := s.get(ctx, k)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		// records that can't be decoded are removed along with expired ones
+		if err == nil && !rec.Expired(now) {
+			continue
+		}
+		if err := s.ds.Delete(ctx, k); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func (s *DatastoreStore) get(ctx context.Context, dsKey string) (*Record, error) {
+	data, err := s.ds.Get(ctx, dsKey)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	key, err := keyEncoding.DecodeString(strings.TrimPrefix(dsKey, datastorePrefix))
+	if err != nil {
+		return nil, ErrInvalidEncoding
+	}
+	return decodeRecord(key, data)
+}
+
+// datastoreKey returns the datastore key of the record stored under key.
+func datastoreKey(key []byte) string {
+	return datastorePrefix + keyEncoding.EncodeToString(key)
+}
+
+// encodeRecord encodes the times and value of a record: the received and expiry times in unix
+// nanoseconds as two big endian uint64s, followed by the value.
+func encodeRecord(rec *Record) []byte {
+	data := make([]byte, 16+len(rec.Value))
+	binary.BigEndian.PutUint64(data[0:], uint64(rec.Received.UnixNano()))
+	binary.BigEndian.PutUint64(data[8:], uint64(rec.Expires.UnixNano()))
+	copy(data[16:], rec.Value)
+	return data
+}
+
+func decodeRecord(key, data []byte) (*Record, error) {
+	if len(data) < 16 {
+		return nil, ErrInvalidEncoding
+	}
+	return &Record{
+		Key:      key,
+		Value:    append([]byte(nil), data[16:]...),
+		Received: time.Unix(0, int64(binary.BigEndian.Uint64(data[0:]))),
+		Expires:  time.Unix(0, int64(binary.BigEndian.Uint64(data[8:]))),
+	}, nil
+}
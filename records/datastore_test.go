@@ -0,0 +1,68 @@
+package records
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/datastore"
+)
+
+func TestDatastoreStore(t *testing.T) {
+	testRecordStore(t, func(cfg *Config) RecordStore {
+		s, err := NewDatastoreStore(datastore.NewMapDatastore(), cfg)
+		require.NoError(t, err)
+		return s
+	})
+}
+
+func TestDatastoreStoreReopen(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultConfig()
+	cfg.Clock = clk
+	cfg.TTL = time.Hour
+
+	ds := datastore.NewMapDatastore()
+	s, err := NewDatastoreStore(ds, cfg)
+	require.NoError(t, err)
+
+	key := []byte{0x00, '/', 0xff}
+	require.NoError(t, s.Put(ctx, &Record{Key: key, Value: []byte("a")}))
+
+	// a new store over the same datastore finds the record, including its times
+	s, err = NewDatastoreStore(ds, cfg)
+	require.NoError(t, err)
+
+	rec, err := s.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, key, rec.Key)
+	require.Equal(t, []byte("a"), rec.Value)
+	require.True(t, rec.Received.Equal(clk.Now()))
+	require.True(t, rec.Expires.Equal(clk.Now().Add(time.Hour)))
+}
+
+func TestDatastoreStoreSweepInvalid(t *testing.T) {
+	ctx := context.Background()
+
+	ds := datastore.NewMapDatastore()
+	s, err := NewDatastoreStore(ds, nil)
+	require.NoError(t, err)
+
+	// values the store can't decode are swept, values outside its prefix are left alone
+	require.NoError(t, ds.Put(ctx, datastoreKey([]byte("key")), []byte("short")))
+	require.NoError(t, ds.Put(ctx, "/other/key", []byte("short")))
+
+	_, err = s.Get(ctx, []byte("key"))
+	require.ErrorIs(t, err, ErrInvalidEncoding)
+
+	n, err := s.Sweep(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	_, err = ds.Get(ctx, "/other/key")
+	require.NoError(t, err)
+}
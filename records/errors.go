@@ -0,0 +1,20 @@
+package records
+
+import (
+	"errors"
+
+	"github.com/plprobelab/go-kademlia/server"
+)
+
+var (
+	// ErrNotFound is returned for missing and expired records. It is the error a server.ValueStore
+	// reports for missing values, so handlers can match either.
+	ErrNotFound = server.ErrNotFound
+
+	ErrExpiredRecord    = errors.New("record has already expired")
+	ErrObsoleteRecord   = errors.New("stored record is better than the new record")
+	ErrNoValues         = errors.New("no values to select from")
+	ErrUnknownNamespace = errors.New("no validator for key namespace")
+	ErrInvalidEncoding  = errors.New("invalid stored record encoding")
+	ErrTooManyProviders = errors.New("key has the maximum number of providers")
+)
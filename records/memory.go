@@ -0,0 +1,88 @@
+package records
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore is a RecordStore keeping its records in memory.
+type MemoryStore struct {
+	cfg Config
+
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+var _ RecordStore = (*MemoryStore)(nil)
+
+// NewMemoryStore returns an empty MemoryStore. If cfg is nil, DefaultConfig is used.
+func NewMemoryStore(cfg *Config) (*MemoryStore, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &MemoryStore{
+		cfg:     *cfg,
+		records: make(map[string]*Record),
+	}, nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key []byte) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[string(key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if rec.Expired(s.cfg.Clock.Now()) {
+		delete(s.records, string(key))
+		return nil, ErrNotFound
+	}
+	return rec.clone(), nil
+}
+
+func (s *MemoryStore) Put(ctx context.Context, rec *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, err := prepare(&s.cfg, rec, s.records[string(rec.Key)])
+	if err != nil {
+		return err
+	}
+	s.records[string(rec.Key)] = rec
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, string(key))
+	return nil
+}
+
+func (s *MemoryStore) Sweep(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.cfg.Clock.Now()
+	removed := 0
+	for k, rec := range s.records {
+		if rec.Expired(now) {
+			delete(s.records, k)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// Size returns the number of records held by the store, including expired records that have not
+// been swept yet.
+func (s *MemoryStore) Size() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records)
+}
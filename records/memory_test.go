@@ -0,0 +1,22 @@
+package records
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testRecordStore(t, func(cfg *Config) RecordStore {
+		s, err := NewMemoryStore(cfg)
+		require.NoError(t, err)
+		return s
+	})
+}
+
+func TestNewMemoryStoreInvalidConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TTL = 0
+	_, err := NewMemoryStore(cfg)
+	require.Error(t, err)
+}
@@ -0,0 +1,178 @@
+package records
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+)
+
+// ProviderConfig specifies optional configuration for a ProviderStore
+type ProviderConfig struct {
+	Clock              clock.Clock   // a clock that may replaced by a mock when testing
+	TTL                time.Duration // the time after which a provider that has not announced itself again is removed
+	MaxProvidersPerKey int           // the maximum number of providers stored for a single key
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *ProviderConfig) Validate() error {
+	if cfg.Clock == nil {
+		return &kaderr.ConfigurationError{
+			Component: "ProviderConfig",
+			Err:       fmt.Errorf("clock must not be nil"),
+		}
+	}
+
+	if cfg.TTL < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "ProviderConfig",
+			Err:       fmt.Errorf("ttl must be greater than zero"),
+		}
+	}
+
+	if cfg.MaxProvidersPerKey < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "ProviderConfig",
+			Err:       fmt.Errorf("max providers per key must be greater than zero"),
+		}
+	}
+
+	return nil
+}
+
+// DefaultProviderConfig returns the default configuration options for a ProviderStore.
+// Options may be overridden before passing to NewProviderStore
+func DefaultProviderConfig() *ProviderConfig {
+	return ProviderConfigFrom(kad.DefaultConfig())
+}
+
+// ProviderConfigFrom returns the default configuration options for a ProviderStore keeping
+// providers for the ProviderTTL of kcfg.
+func ProviderConfigFrom(kcfg *kad.Config) *ProviderConfig {
+	return &ProviderConfig{
+		Clock:              clock.New(), // use standard time
+		TTL:                kcfg.ProviderTTL,
+		MaxProvidersPerKey: 100,
+	}
+}
+
+// ProviderStore stores in memory the nodes providing the content of keys, as announced by
+// ADD_PROVIDER requests, until they expire.
+type ProviderStore[K kad.Key[K], A kad.Address[A]] struct {
+	cfg ProviderConfig
+
+	mu sync.Mutex
+	// providers holds the providers of each key, by the string form of their node id
+	providers map[string]map[string]*provider[K, A]
+}
+
+type provider[K kad.Key[K], A kad.Address[A]] struct {
+	info    kad.NodeInfo[K, A]
+	expires time.Time
+}
+
+// NewProviderStore returns an empty ProviderStore. If cfg is nil, DefaultProviderConfig is used.
+func NewProviderStore[K kad.Key[K], A kad.Address[A]](cfg *ProviderConfig) (*ProviderStore[K, A], error) {
+	if cfg == nil {
+		cfg = DefaultProviderConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &ProviderStore[K, A]{
+		cfg:       *cfg,
+		providers: make(map[string]map[string]*provider[K, A]),
+	}, nil
+}
+
+// AddProvider records that prov provides the content of key until the configured TTL elapses. A
+// provider that is already stored is refreshed, with its addresses replaced. A new provider is
+// rejected with ErrTooManyProviders if the key already has the maximum number of providers.
+func (s *ProviderStore[K, A]) AddProvider(ctx context.Context, key []byte, prov kad.NodeInfo[K, A]) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.cfg.Clock.Now()
+	provs, ok := s.providers[string(key)]
+	if !ok {
+		provs = make(map[string]*provider[K, A])
+		s.providers[string(key)] = provs
+	}
+
+	id := prov.ID().String()
+	if _, ok := provs[id]; !ok && s.live(provs, now) >= s.cfg.MaxProvidersPerKey {
+		return ErrTooManyProviders
+	}
+	provs[id] = &provider[K, A]{
+		info:    prov,
+		expires: now.Add(s.cfg.TTL),
+	}
+	return nil
+}
+
+// GetProviders returns the providers of key that have not expired, the most recently announced
+// first. It returns an empty list if key has no providers.
+func (s *ProviderStore[K, A]) GetProviders(ctx context.Context, key []byte) ([]kad.NodeInfo[K, A], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.cfg.Clock.Now()
+	live := make([]*provider[K, A], 0, len(s.providers[string(key)]))
+	for _, p := range s.providers[string(key)] {
+		if now.Before(p.expires) {
+			live = append(live, p)
+		}
+	}
+	sort.Slice(live, func(i, j int) bool {
+		if !live[i].expires.Equal(live[j].expires) {
+			return live[i].expires.After(live[j].expires)
+		}
+		// break ties by id so that the order is stable
+		return live[i].info.ID().String() < live[j].info.ID().String()
+	})
+
+	infos := make([]kad.NodeInfo[K, A], len(live))
+	for i, p := range live {
+		infos[i] = p.info
+	}
+	return infos, nil
+}
+
+// Sweep removes the providers that have expired and returns the number of providers removed.
+// It may be scheduled with ScheduleSweep.
+func (s *ProviderStore[K, A]) Sweep(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.cfg.Clock.Now()
+	removed := 0
+	for k, provs := range s.providers {
+		for id, p := range provs {
+			if !now.Before(p.expires) {
+				delete(provs, id)
+				removed++
+			}
+		}
+		if len(provs) == 0 {
+			delete(s.providers, k)
+		}
+	}
+	return removed, nil
+}
+
+// live returns the number of providers in provs that have not expired at time now.
+func (s *ProviderStore[K, A]) live(provs map[string]*provider[K, A], now time.Time) int {
+	n := 0
+	for _, p := range provs {
+		if now.Before(p.expires) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,171 @@
+package records
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/server"
+)
+
+var _ server.ProviderStore[key.Key8, kadtest.StrAddr] = (*ProviderStore[key.Key8, kadtest.StrAddr])(nil)
+
+func TestProviderConfigValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		cfg := DefaultProviderConfig()
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("clock is not nil", func(t *testing.T) {
+		cfg := DefaultProviderConfig()
+		cfg.Clock = nil
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("ttl positive", func(t *testing.T) {
+		cfg := DefaultProviderConfig()
+		cfg.TTL = 0
+		require.Error(t, cfg.Validate())
+		cfg.TTL = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("max providers per key positive", func(t *testing.T) {
+		cfg := DefaultProviderConfig()
+		cfg.MaxProvidersPerKey = 0
+		require.Error(t, cfg.Validate())
+		cfg.MaxProvidersPerKey = -1
+		require.Error(t, cfg.Validate())
+	})
+}
+
+func newTestProviderStore(t *testing.T) (*ProviderStore[key.Key8, kadtest.StrAddr], *clock.Mock) {
+	clk := clock.NewMock()
+	cfg := DefaultProviderConfig()
+	cfg.Clock = clk
+	cfg.TTL = time.Hour
+	cfg.MaxProvidersPerKey = 2
+	s, err := NewProviderStore[key.Key8, kadtest.StrAddr](cfg)
+	require.NoError(t, err)
+	return s, clk
+}
+
+func newProvider(k key.Key8, addr kadtest.StrAddr) *kadtest.Info[key.Key8, kadtest.StrAddr] {
+	return kadtest.NewInfo[key.Key8, kadtest.StrAddr](kadtest.NewID(k), []kadtest.StrAddr{addr})
+}
+
+func TestProviderStoreAddGet(t *testing.T) {
+	ctx := context.Background()
+	s, clk := newTestProviderStore(t)
+
+	provs, err := s.GetProviders(ctx, []byte("key"))
+	require.NoError(t, err)
+	require.Empty(t, provs)
+
+	a := newProvider(key.Key8(1), "a")
+	b := newProvider(key.Key8(2), "b")
+	require.NoError(t, s.AddProvider(ctx, []byte("key"), a))
+	clk.Add(time.Minute)
+	require.NoError(t, s.AddProvider(ctx, []byte("key"), b))
+
+	// the most recently announced provider comes first
+	provs, err = s.GetProviders(ctx, []byte("key"))
+	require.NoError(t, err)
+	require.Equal(t, []kad.NodeInfo[key.Key8, kadtest.StrAddr]{b, a}, provs)
+
+	// other keys are not affected
+	provs, err = s.GetProviders(ctx, []byte("other"))
+	require.NoError(t, err)
+	require.Empty(t, provs)
+
+	// announcing again refreshes the provider and replaces its addresses
+	clk.Add(time.Minute)
+	a2 := newProvider(key.Key8(1), "a2")
+	require.NoError(t, s.AddProvider(ctx, []byte("key"), a2))
+
+	provs, err = s.GetProviders(ctx, []byte("key"))
+	require.NoError(t, err)
+	require.Equal(t, []kad.NodeInfo[key.Key8, kadtest.StrAddr]{a2, b}, provs)
+}
+
+func TestProviderStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	s, clk := newTestProviderStore(t)
+
+	a := newProvider(key.Key8(1), "a")
+	b := newProvider(key.Key8(2), "b")
+	require.NoError(t, s.AddProvider(ctx, []byte("key"), a))
+	clk.Add(30 * time.Minute)
+	require.NoError(t, s.AddProvider(ctx, []byte("key"), b))
+
+	clk.Add(30 * time.Minute)
+	provs, err := s.GetProviders(ctx, []byte("key"))
+	require.NoError(t, err)
+	require.Equal(t, []kad.NodeInfo[key.Key8, kadtest.StrAddr]{b}, provs)
+
+	n, err := s.Sweep(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	n, err = s.Sweep(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	clk.Add(30 * time.Minute)
+	n, err = s.Sweep(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Empty(t, s.providers)
+}
+
+func TestProviderStoreLimit(t *testing.T) {
+	ctx := context.Background()
+	s, clk := newTestProviderStore(t)
+
+	a := newProvider(key.Key8(1), "a")
+	b := newProvider(key.Key8(2), "b")
+	c := newProvider(key.Key8(3), "c")
+	require.NoError(t, s.AddProvider(ctx, []byte("key"), a))
+	require.NoError(t, s.AddProvider(ctx, []byte("key"), b))
+
+	// the key is full
+	require.ErrorIs(t, s.AddProvider(ctx, []byte("key"), c), ErrTooManyProviders)
+
+	// stored providers may still refresh
+	require.NoError(t, s.AddProvider(ctx, []byte("key"), a))
+
+	// the limit is per key
+	require.NoError(t, s.AddProvider(ctx, []byte("other"), c))
+
+	// expired providers don't count towards the limit
+	clk.Add(time.Hour)
+	require.NoError(t, s.AddProvider(ctx, []byte("key"), c))
+}
+
+func TestProviderStoreScheduleSweep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s, clk := newTestProviderStore(t)
+	sched := event.NewSimpleScheduler(clk)
+	ScheduleSweep(ctx, sched, s, time.Hour)
+
+	require.NoError(t, s.AddProvider(ctx, []byte("key"), newProvider(key.Key8(1), "a")))
+	clk.Add(30 * time.Minute)
+	require.NoError(t, s.AddProvider(ctx, []byte("key"), newProvider(key.Key8(2), "b")))
+
+	// the first provider expires at the first sweep, the second one at the next
+	clk.Add(30 * time.Minute)
+	event.RunAll(ctx, sched)
+	require.Len(t, s.providers["key"], 1)
+
+	clk.Add(time.Hour)
+	event.RunAll(ctx, sched)
+	require.Empty(t, s.providers)
+}
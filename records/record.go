@@ -0,0 +1,161 @@
+package records
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/server"
+)
+
+// A Record is a value stored under a key until it expires.
+type Record struct {
+	Key      []byte    // the key the record is stored under
+	Value    []byte    // the value of the record
+	Received time.Time // the time the record was stored, set by the RecordStore
+	Expires  time.Time // the time after which the record is removed, defaults to now plus the store TTL
+}
+
+// Expired reports whether the record has expired at time now.
+func (r *Record) Expired(now time.Time) bool {
+	return !now.Before(r.Expires)
+}
+
+func (r *Record) clone() *Record {
+	return &Record{
+		Key:      append([]byte(nil), r.Key...),
+		Value:    append([]byte(nil), r.Value...),
+		Received: r.Received,
+		Expires:  r.Expires,
+	}
+}
+
+// RecordStore stores records until they expire.
+type RecordStore interface {
+	// Get returns the record stored under key, or ErrNotFound if there is none or it has expired.
+	Get(ctx context.Context, key []byte) (*Record, error)
+
+	// Put validates rec and stores it, replacing the record stored under the same key if the
+	// Validator selects rec over it.
+	Put(ctx context.Context, rec *Record) error
+
+	// Delete removes the record stored under key, if any.
+	Delete(ctx context.Context, key []byte) error
+
+	// Sweep removes the records that have expired and returns the number of records removed.
+	Sweep(ctx context.Context) (int, error)
+}
+
+// Config specifies optional configuration for a RecordStore
+type Config struct {
+	Clock     clock.Clock   // a clock that may replaced by a mock when testing
+	TTL       time.Duration // the lifetime of records that are put without an expiry
+	Validator Validator     // validates records and selects the best of two records with the same key
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *Config) Validate() error {
+	if cfg.Clock == nil {
+		return &kaderr.ConfigurationError{
+			Component: "RecordsConfig",
+			Err:       fmt.Errorf("clock must not be nil"),
+		}
+	}
+
+	if cfg.TTL < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "RecordsConfig",
+			Err:       fmt.Errorf("ttl must be greater than zero"),
+		}
+	}
+
+	if cfg.Validator == nil {
+		return &kaderr.ConfigurationError{
+			Component: "RecordsConfig",
+			Err:       fmt.Errorf("validator must not be nil"),
+		}
+	}
+
+	return nil
+}
+
+// DefaultConfig returns the default configuration options for a RecordStore.
+// Options may be overridden before passing to NewMemoryStore or NewDatastoreStore.
+func DefaultConfig() *Config {
+	return ConfigFrom(kad.DefaultConfig())
+}
+
+// ConfigFrom returns the default configuration options for a RecordStore keeping records for the
+// RecordTTL of kcfg.
+func ConfigFrom(kcfg *kad.Config) *Config {
+	return &Config{
+		Clock:     clock.New(), // use standard time
+		TTL:       kcfg.RecordTTL,
+		Validator: AcceptAll{},
+	}
+}
+
+// prepare validates rec against the record currently stored under its key, which may be nil,
+// and returns the copy of rec to store.
+func prepare(cfg *Config, rec *Record, existing *Record) (*Record, error) {
+	if err := cfg.Validator.Validate(rec.Key, rec.Value); err != nil {
+		return nil, err
+	}
+
+	now := cfg.Clock.Now()
+	rec = rec.clone()
+	rec.Received = now
+	if rec.Expires.IsZero() {
+		rec.Expires = now.Add(cfg.TTL)
+	} else if rec.Expired(now) {
+		return nil, ErrExpiredRecord
+	}
+
+	if existing != nil && !existing.Expired(now) && !bytes.Equal(existing.Value, rec.Value) {
+		i, err := cfg.Validator.Select(rec.Key, [][]byte{rec.Value, existing.Value})
+		if err != nil {
+			return nil, err
+		}
+		if i != 0 {
+			return nil, ErrObsoleteRecord
+		}
+	}
+	return rec, nil
+}
+
+// A Sweeper is a store that can remove its expired entries, such as a RecordStore or a
+// ProviderStore.
+type Sweeper = event.Sweeper
+
+// ScheduleSweep schedules s to be swept on sched every interval, like event.ScheduleSweep.
+func ScheduleSweep(ctx context.Context, sched event.Scheduler, s Sweeper, interval time.Duration) *event.RepeatedAction {
+	return event.ScheduleSweep(ctx, sched, s, interval)
+}
+
+// ValueStore returns a server.ValueStore storing values as records in s, so that the GET_VALUE
+// and PUT_VALUE handlers of a server validate and expire them.
+func ValueStore(s RecordStore) server.ValueStore {
+	return &valueStore{s: s}
+}
+
+type valueStore struct {
+	s RecordStore
+}
+
+func (v *valueStore) Get(ctx context.Context, key []byte) ([]byte, error) {
+	rec, err := v.s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return rec.Value, nil
+}
+
+func (v *valueStore) Put(ctx context.Context, key, value []byte) error {
+	return v.s.Put(ctx, &Record{Key: key, Value: value})
+}
@@ -0,0 +1,268 @@
+package records
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/server"
+)
+
+func TestConfigValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		cfg := DefaultConfig()
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("clock is not nil", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Clock = nil
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("ttl positive", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.TTL = 0
+		require.Error(t, cfg.Validate())
+		cfg.TTL = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("validator is not nil", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Validator = nil
+		require.Error(t, cfg.Validate())
+	})
+}
+
+// newestValidator selects the greatest value, so that records can be ordered by their values.
+type newestValidator struct{}
+
+func (newestValidator) Validate(key, value []byte) error {
+	if len(value) == 0 {
+		return ErrInvalidEncoding
+	}
+	return nil
+}
+
+func (newestValidator) Select(key []byte, values [][]byte) (int, error) {
+	best := 0
+	for i, v := range values {
+		if string(v) > string(values[best]) {
+			best = i
+		}
+	}
+	return best, nil
+}
+
+// testRecordStore runs the tests every RecordStore must pass against the store returned by newStore.
+func testRecordStore(t *testing.T, newStore func(cfg *Config) RecordStore) {
+	ctx := context.Background()
+
+	newTestStore := func() (RecordStore, *clock.Mock) {
+		clk := clock.NewMock()
+		cfg := DefaultConfig()
+		cfg.Clock = clk
+		cfg.TTL = time.Hour
+		cfg.Validator = newestValidator{}
+		return newStore(cfg), clk
+	}
+
+	t.Run("put and get", func(t *testing.T) {
+		s, clk := newTestStore()
+
+		_, err := s.Get(ctx, []byte("key"))
+		require.ErrorIs(t, err, ErrNotFound)
+
+		require.NoError(t, s.Put(ctx, &Record{Key: []byte("key"), Value: []byte("a")}))
+
+		rec, err := s.Get(ctx, []byte("key"))
+		require.NoError(t, err)
+		require.Equal(t, []byte("key"), rec.Key)
+		require.Equal(t, []byte("a"), rec.Value)
+		require.True(t, rec.Received.Equal(clk.Now()))
+		require.True(t, rec.Expires.Equal(clk.Now().Add(time.Hour)))
+	})
+
+	t.Run("invalid record", func(t *testing.T) {
+		s, _ := newTestStore()
+
+		err := s.Put(ctx, &Record{Key: []byte("key")})
+		require.ErrorIs(t, err, ErrInvalidEncoding)
+
+		_, err = s.Get(ctx, []byte("key"))
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("best record wins", func(t *testing.T) {
+		s, _ := newTestStore()
+
+		require.NoError(t, s.Put(ctx, &Record{Key: []byte("key"), Value: []byte("b")}))
+
+		err := s.Put(ctx, &Record{Key: []byte("key"), Value: []byte("a")})
+		require.ErrorIs(t, err, ErrObsoleteRecord)
+
+		rec, err := s.Get(ctx, []byte("key"))
+		require.NoError(t, err)
+		require.Equal(t, []byte("b"), rec.Value)
+
+		require.NoError(t, s.Put(ctx, &Record{Key: []byte("key"), Value: []byte("c")}))
+
+		rec, err = s.Get(ctx, []byte("key"))
+		require.NoError(t, err)
+		require.Equal(t, []byte("c"), rec.Value)
+	})
+
+	t.Run("same record refreshes expiry", func(t *testing.T) {
+		s, clk := newTestStore()
+
+		require.NoError(t, s.Put(ctx, &Record{Key: []byte("key"), Value: []byte("a")}))
+		clk.Add(30 * time.Minute)
+		require.NoError(t, s.Put(ctx, &Record{Key: []byte("key"), Value: []byte("a")}))
+
+		rec, err := s.Get(ctx, []byte("key"))
+		require.NoError(t, err)
+		require.True(t, rec.Expires.Equal(clk.Now().Add(time.Hour)))
+	})
+
+	t.Run("per record ttl", func(t *testing.T) {
+		s, clk := newTestStore()
+
+		require.NoError(t, s.Put(ctx, &Record{Key: []byte("short"), Value: []byte("a"), Expires: clk.Now().Add(time.Minute)}))
+		require.NoError(t, s.Put(ctx, &Record{Key: []byte("long"), Value: []byte("a")}))
+
+		err := s.Put(ctx, &Record{Key: []byte("expired"), Value: []byte("a"), Expires: clk.Now()})
+		require.ErrorIs(t, err, ErrExpiredRecord)
+
+		clk.Add(time.Minute)
+
+		_, err = s.Get(ctx, []byte("short"))
+		require.ErrorIs(t, err, ErrNotFound)
+
+		_, err = s.Get(ctx, []byte("long"))
+		require.NoError(t, err)
+	})
+
+	t.Run("expired record is replaced", func(t *testing.T) {
+		s, clk := newTestStore()
+
+		require.NoError(t, s.Put(ctx, &Record{Key: []byte("key"), Value: []byte("b")}))
+		clk.Add(time.Hour)
+
+		// the worse record is accepted since the stored record has expired
+		require.NoError(t, s.Put(ctx, &Record{Key: []byte("key"), Value: []byte("a")}))
+
+		rec, err := s.Get(ctx, []byte("key"))
+		require.NoError(t, err)
+		require.Equal(t, []byte("a"), rec.Value)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		s, _ := newTestStore()
+
+		require.NoError(t, s.Put(ctx, &Record{Key: []byte("key"), Value: []byte("a")}))
+		require.NoError(t, s.Delete(ctx, []byte("key")))
+
+		_, err := s.Get(ctx, []byte("key"))
+		require.ErrorIs(t, err, ErrNotFound)
+
+		// deleting a missing record is not an error
+		require.NoError(t, s.Delete(ctx, []byte("key")))
+	})
+
+	t.Run("sweep", func(t *testing.T) {
+		s, clk := newTestStore()
+
+		require.NoError(t, s.Put(ctx, &Record{Key: []byte("a"), Value: []byte("a"), Expires: clk.Now().Add(time.Minute)}))
+		require.NoError(t, s.Put(ctx, &Record{Key: []byte("b"), Value: []byte("b"), Expires: clk.Now().Add(time.Minute)}))
+		require.NoError(t, s.Put(ctx, &Record{Key: []byte("c"), Value: []byte("c")}))
+
+		n, err := s.Sweep(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 0, n)
+
+		clk.Add(time.Minute)
+
+		n, err = s.Sweep(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 2, n)
+
+		_, err = s.Get(ctx, []byte("c"))
+		require.NoError(t, err)
+	})
+
+	t.Run("stored record is a copy", func(t *testing.T) {
+		s, _ := newTestStore()
+
+		rec := &Record{Key: []byte("key"), Value: []byte("a")}
+		require.NoError(t, s.Put(ctx, rec))
+		rec.Value[0] = 'z'
+
+		got, err := s.Get(ctx, []byte("key"))
+		require.NoError(t, err)
+		require.Equal(t, []byte("a"), got.Value)
+	})
+}
+
+func TestScheduleSweep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clk := clock.NewMock()
+	sched := event.NewSimpleScheduler(clk)
+
+	cfg := DefaultConfig()
+	cfg.Clock = clk
+	cfg.TTL = time.Minute
+	s, err := NewMemoryStore(cfg)
+	require.NoError(t, err)
+
+	ScheduleSweep(ctx, sched, s, time.Minute)
+
+	require.NoError(t, s.Put(ctx, &Record{Key: []byte("a"), Value: []byte("a")}))
+	event.RunAll(ctx, sched)
+	require.Equal(t, 1, s.Size())
+
+	// the record expires and is swept
+	clk.Add(time.Minute)
+	event.RunAll(ctx, sched)
+	require.Equal(t, 0, s.Size())
+
+	// the sweep is rescheduled
+	require.NoError(t, s.Put(ctx, &Record{Key: []byte("b"), Value: []byte("b")}))
+	clk.Add(time.Minute)
+	event.RunAll(ctx, sched)
+	require.Equal(t, 0, s.Size())
+
+	// no further sweeps once the context is done
+	cancel()
+	require.NoError(t, s.Put(context.Background(), &Record{Key: []byte("c"), Value: []byte("c")}))
+	clk.Add(time.Minute)
+	event.RunAll(context.Background(), sched)
+	require.Equal(t, 1, s.Size())
+}
+
+func TestValueStore(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := DefaultConfig()
+	cfg.Validator = newestValidator{}
+	s, err := NewMemoryStore(cfg)
+	require.NoError(t, err)
+
+	vs := ValueStore(s)
+
+	_, err = vs.Get(ctx, []byte("key"))
+	require.ErrorIs(t, err, server.ErrNotFound)
+
+	require.NoError(t, vs.Put(ctx, []byte("key"), []byte("b")))
+	require.ErrorIs(t, vs.Put(ctx, []byte("key"), []byte("a")), ErrObsoleteRecord)
+
+	value, err := vs.Get(ctx, []byte("key"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("b"), value)
+}
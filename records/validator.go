@@ -0,0 +1,79 @@
+package records
+
+import (
+	"bytes"
+)
+
+// A Validator decides which records may be stored and which of several records with the same key
+// is the best one.
+type Validator interface {
+	// Validate returns an error if value is not a valid record value for key.
+	Validate(key, value []byte) error
+
+	// Select returns the index of the best of values, which are all valid values for key.
+	Select(key []byte, values [][]byte) (int, error)
+}
+
+// AcceptAll is a Validator that accepts any record and selects the first of several values.
+// Since a RecordStore offers the new record first, the latest record put under a key always wins.
+type AcceptAll struct{}
+
+var _ Validator = AcceptAll{}
+
+func (AcceptAll) Validate(key, value []byte) error {
+	return nil
+}
+
+func (AcceptAll) Select(key []byte, values [][]byte) (int, error) {
+	if len(values) == 0 {
+		return 0, ErrNoValues
+	}
+	return 0, nil
+}
+
+// NamespacedValidator is a Validator that delegates to a Validator chosen by the namespace of
+// the key, the first segment of a key of the form /namespace/rest such as /pk/... or /ipns/...
+// Keys without a namespace or with a namespace that has no Validator are rejected.
+type NamespacedValidator map[string]Validator
+
+var _ Validator = NamespacedValidator(nil)
+
+func (v NamespacedValidator) Validate(key, value []byte) error {
+	vv, err := v.validator(key)
+	if err != nil {
+		return err
+	}
+	return vv.Validate(key, value)
+}
+
+func (v NamespacedValidator) Select(key []byte, values [][]byte) (int, error) {
+	vv, err := v.validator(key)
+	if err != nil {
+		return 0, err
+	}
+	return vv.Select(key, values)
+}
+
+func (v NamespacedValidator) validator(key []byte) (Validator, error) {
+	ns, ok := namespace(key)
+	if !ok {
+		return nil, ErrUnknownNamespace
+	}
+	vv, ok := v[ns]
+	if !ok {
+		return nil, ErrUnknownNamespace
+	}
+	return vv, nil
+}
+
+// namespace returns the first segment of a key of the form /namespace/rest.
+func namespace(key []byte) (string, bool) {
+	if len(key) == 0 || key[0] != '/' {
+		return "", false
+	}
+	i := bytes.IndexByte(key[1:], '/')
+	if i < 1 {
+		return "", false
+	}
+	return string(key[1 : i+1]), true
+}
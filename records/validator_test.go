@@ -0,0 +1,40 @@
+package records
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptAll(t *testing.T) {
+	v := AcceptAll{}
+	require.NoError(t, v.Validate([]byte("key"), nil))
+
+	i, err := v.Select([]byte("key"), [][]byte{[]byte("a"), []byte("b")})
+	require.NoError(t, err)
+	require.Equal(t, 0, i)
+
+	_, err = v.Select([]byte("key"), nil)
+	require.ErrorIs(t, err, ErrNoValues)
+}
+
+func TestNamespacedValidator(t *testing.T) {
+	v := NamespacedValidator{
+		"pk":   AcceptAll{},
+		"test": newestValidator{},
+	}
+
+	require.NoError(t, v.Validate([]byte("/pk/abc"), nil))
+	require.ErrorIs(t, v.Validate([]byte("/test/abc"), nil), ErrInvalidEncoding)
+	require.NoError(t, v.Validate([]byte("/test/abc"), []byte("a")))
+
+	i, err := v.Select([]byte("/test/abc"), [][]byte{[]byte("a"), []byte("b")})
+	require.NoError(t, err)
+	require.Equal(t, 1, i)
+
+	for _, key := range []string{"", "pk", "/pk", "//abc", "/other/abc", "pk/abc"} {
+		require.ErrorIs(t, v.Validate([]byte(key), nil), ErrUnknownNamespace, key)
+		_, err := v.Select([]byte(key), [][]byte{nil})
+		require.ErrorIs(t, err, ErrUnknownNamespace, key)
+	}
+}
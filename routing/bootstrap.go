@@ -9,8 +9,10 @@ import (
 
 	"github.com/plprobelab/go-kademlia/kad"
 	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/logging"
 	"github.com/plprobelab/go-kademlia/network/address"
 	"github.com/plprobelab/go-kademlia/query"
+	"github.com/plprobelab/go-kademlia/routing/denylist"
 	"github.com/plprobelab/go-kademlia/util"
 )
 
@@ -23,14 +25,22 @@ type Bootstrap[K kad.Key[K], A kad.Address[A]] struct {
 
 	// cfg is a copy of the optional configuration supplied to the Bootstrap
 	cfg BootstrapConfig[K, A]
+	log logging.Logger
 }
 
 // BootstrapConfig specifies optional configuration for a Bootstrap
 type BootstrapConfig[K kad.Key[K], A kad.Address[A]] struct {
-	Timeout            time.Duration // the time to wait before terminating a query that is not making progress
-	RequestConcurrency int           // the maximum number of concurrent requests that each query may have in flight
-	RequestTimeout     time.Duration // the timeout queries should use for contacting a single node
-	Clock              clock.Clock   // a clock that may replaced by a mock when testing
+	Timeout            time.Duration          // the time to wait before terminating a query that is not making progress
+	RequestConcurrency int                    // the maximum number of concurrent requests that each query may have in flight
+	RequestTimeout     time.Duration          // the timeout queries should use for contacting a single node
+	Clock              clock.Clock            // a clock that may replaced by a mock when testing
+	Quarantine         *query.Quarantine      // an optional quarantine of unreachable nodes shared with other queries
+	DenyList           *denylist.PeerDenyList // an optional list of banned nodes that must not be contacted
+	Logger             logging.Logger         // an optional logger the bootstrap and its query emit events to, nil logs nothing
+
+	// RequestTimeoutFunc optionally gives the timeout for contacting each node, overriding
+	// RequestTimeout. See query.QueryConfig.
+	RequestTimeoutFunc func(fmt.Stringer) time.Duration
 }
 
 // Validate checks the configuration options and returns an error if any have invalid values.
@@ -69,11 +79,17 @@ func (cfg *BootstrapConfig[K, A]) Validate() error {
 // DefaultBootstrapConfig returns the default configuration options for a Bootstrap.
 // Options may be overridden before passing to NewBootstrap
 func DefaultBootstrapConfig[K kad.Key[K], A kad.Address[A]]() *BootstrapConfig[K, A] {
+	return BootstrapConfigFrom[K, A](kad.DefaultConfig())
+}
+
+// BootstrapConfigFrom returns the default configuration options for a Bootstrap whose query uses
+// the concurrency and timeouts of kcfg.
+func BootstrapConfigFrom[K kad.Key[K], A kad.Address[A]](kcfg *kad.Config) *BootstrapConfig[K, A] {
 	return &BootstrapConfig[K, A]{
 		Clock:              clock.New(), // use standard time
-		Timeout:            5 * time.Minute,
-		RequestConcurrency: 3,
-		RequestTimeout:     time.Minute,
+		Timeout:            kcfg.QueryTimeout,
+		RequestConcurrency: kcfg.Concurrency,
+		RequestTimeout:     kcfg.RequestTimeout,
 	}
 }
 
@@ -87,6 +103,7 @@ func NewBootstrap[K kad.Key[K], A kad.Address[A]](self kad.NodeID[K], cfg *Boots
 	return &Bootstrap[K, A]{
 		self: self,
 		cfg:  *cfg,
+		log:  logging.OrNop(cfg.Logger),
 	}, nil
 }
 
@@ -104,6 +121,10 @@ func (b *Bootstrap[K, A]) Advance(ctx context.Context, ev BootstrapEvent) Bootst
 		qryCfg.Clock = b.cfg.Clock
 		qryCfg.Concurrency = b.cfg.RequestConcurrency
 		qryCfg.RequestTimeout = b.cfg.RequestTimeout
+		qryCfg.RequestTimeoutFunc = b.cfg.RequestTimeoutFunc
+		qryCfg.Quarantine = b.cfg.Quarantine
+		qryCfg.DenyList = b.cfg.DenyList
+		qryCfg.Logger = b.cfg.Logger
 
 		queryID := query.QueryID("bootstrap")
 
@@ -113,14 +134,22 @@ func (b *Bootstrap[K, A]) Advance(ctx context.Context, ev BootstrapEvent) Bootst
 			panic(err)
 		}
 		b.qry = qry
+		b.log.Info("bootstrap started", logging.Int("seeds", len(tev.KnownClosestNodes)))
 		return b.advanceQuery(ctx, nil)
 
 	case *EventBootstrapMessageResponse[K, A]:
+		if b.qry == nil {
+			// a late response to a bootstrap that already finished
+			return &StateBootstrapIdle{}
+		}
 		return b.advanceQuery(ctx, &query.EventQueryMessageResponse[K, A]{
 			NodeID:   tev.NodeID,
 			Response: tev.Response,
 		})
 	case *EventBootstrapMessageFailure[K]:
+		if b.qry == nil {
+			return &StateBootstrapIdle{}
+		}
 		return b.advanceQuery(ctx, &query.EventQueryMessageFailure[K]{
 			NodeID: tev.NodeID,
 			Error:  tev.Error,
@@ -151,12 +180,17 @@ func (b *Bootstrap[K, A]) advanceQuery(ctx context.Context, qev query.QueryEvent
 			Message:    st.Message,
 		}
 	case *query.StateQueryFinished:
+		// the bootstrap is idle again once it reported its end
+		b.qry = nil
+		b.log.Info("bootstrap finished", logging.Int("success", st.Stats.Success), logging.Int("failure", st.Stats.Failure))
 		return &StateBootstrapFinished{
 			Stats: st.Stats,
 		}
 	case *query.StateQueryWaitingAtCapacity:
 		elapsed := b.cfg.Clock.Since(st.Stats.Start)
 		if elapsed > b.cfg.Timeout {
+			b.qry = nil
+			b.log.Info("bootstrap timed out", logging.Duration("elapsed", elapsed))
 			return &StateBootstrapTimeout{
 				Stats: st.Stats,
 			}
@@ -167,6 +201,8 @@ func (b *Bootstrap[K, A]) advanceQuery(ctx context.Context, qev query.QueryEvent
 	case *query.StateQueryWaitingWithCapacity:
 		elapsed := b.cfg.Clock.Since(st.Stats.Start)
 		if elapsed > b.cfg.Timeout {
+			b.qry = nil
+			b.log.Info("bootstrap timed out", logging.Duration("elapsed", elapsed))
 			return &StateBootstrapTimeout{
 				Stats: st.Stats,
 			}
@@ -7,8 +7,8 @@ import (
 	"github.com/benbjohnson/clock"
 	"github.com/stretchr/testify/require"
 
-	"github.com/plprobelab/go-kademlia/internal/kadtest"
 	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
 	"github.com/plprobelab/go-kademlia/key"
 	"github.com/plprobelab/go-kademlia/network/address"
 	"github.com/plprobelab/go-kademlia/query"
@@ -239,4 +239,49 @@ func TestBootstrapProgress(t *testing.T) {
 	stf := state.(*StateBootstrapFinished)
 	require.Equal(t, 4, stf.Stats.Requests)
 	require.Equal(t, 4, stf.Stats.Success)
+
+	// the bootstrap is idle once it has reported that it finished
+	state = bs.Advance(ctx, &EventBootstrapPoll{})
+	require.IsType(t, &StateBootstrapIdle{}, state)
+
+	// a late response is ignored
+	state = bs.Advance(ctx, &EventBootstrapMessageResponse[key.Key8, kadtest.StrAddr]{
+		NodeID: d,
+	})
+	require.IsType(t, &StateBootstrapIdle{}, state)
+}
+
+func TestBootstrapLogger(t *testing.T) {
+	ctx := context.Background()
+	logger := kadtest.NewLogger()
+	cfg := DefaultBootstrapConfig[key.Key8, kadtest.StrAddr]()
+	cfg.Clock = clock.NewMock()
+	cfg.Logger = logger
+
+	self := kadtest.NewID(key.Key8(0))
+	bs, err := NewBootstrap[key.Key8, kadtest.StrAddr](self, cfg)
+	require.NoError(t, err)
+
+	a := kadtest.NewID(key.Key8(0b00000100)) // 4
+	state := bs.Advance(ctx, &EventBootstrapStart[key.Key8, kadtest.StrAddr]{
+		ProtocolID:        address.ProtocolID("testprotocol"),
+		Message:           kadtest.NewRequest("1", self.Key()),
+		KnownClosestNodes: []kad.NodeID[key.Key8]{a},
+	})
+	require.IsType(t, &StateBootstrapMessage[key.Key8, kadtest.StrAddr]{}, state)
+	started := logger.Entries("bootstrap started")
+	require.Len(t, started, 1)
+	require.Equal(t, 1, started[0].Fields["seeds"])
+
+	// the query of the bootstrap logs to the same logger
+	require.Len(t, logger.Entries("query request"), 1)
+
+	state = bs.Advance(ctx, &EventBootstrapMessageResponse[key.Key8, kadtest.StrAddr]{
+		NodeID: a,
+	})
+	require.IsType(t, &StateBootstrapFinished{}, state)
+	finished := logger.Entries("bootstrap finished")
+	require.Len(t, finished, 1)
+	require.Equal(t, "info", finished[0].Level)
+	require.Equal(t, 1, finished[0].Fields["success"])
 }
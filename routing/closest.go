@@ -0,0 +1,156 @@
+package routing
+
+import (
+	"sort"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+// ClosestSet tracks the n known nodes closest to the local node, independently of the bucket
+// limits of any routing table. Protocols that depend on the strict k-closest neighbourhood, such as
+// record replication decisions, can consult it instead of querying the routing table, which may have
+// rejected close nodes because a bucket was full.
+type ClosestSet[K kad.Key[K], N kad.NodeID[K]] struct {
+	self  K
+	n     int
+	nodes []N // ordered by increasing distance from self
+}
+
+// NewClosestSet creates a ClosestSet tracking the n nodes closest to self.
+func NewClosestSet[K kad.Key[K], N kad.NodeID[K]](self K, n int) *ClosestSet[K, N] {
+	return &ClosestSet[K, N]{
+		self:  self,
+		n:     n,
+		nodes: make([]N, 0, n),
+	}
+}
+
+// Add adds a node to the set if it is closer to the local node than the farthest node in the set,
+// or the set is not full, evicting the farthest node if needed. It returns true if the node was added.
+func (s *ClosestSet[K, N]) Add(node N) bool {
+	kk := node.Key()
+	if s.n < 1 || key.Equal(kk, s.self) {
+		return false
+	}
+
+	i := sort.Search(len(s.nodes), func(i int) bool {
+		return key.DistanceCmp(s.self, kk, s.nodes[i].Key()) <= 0
+	})
+	if i < len(s.nodes) && key.Equal(s.nodes[i].Key(), kk) {
+		// already present
+		return false
+	}
+	if i >= s.n {
+		// farther than all nodes in a full set
+		return false
+	}
+
+	if len(s.nodes) == s.n {
+		s.nodes = s.nodes[:len(s.nodes)-1]
+	}
+	var zero N
+	s.nodes = append(s.nodes, zero)
+	copy(s.nodes[i+1:], s.nodes[i:])
+	s.nodes[i] = node
+	return true
+}
+
+// Remove removes the node identified by kk from the set. It returns true if the node was present.
+func (s *ClosestSet[K, N]) Remove(kk K) bool {
+	for i, n := range s.nodes {
+		if key.Equal(n.Key(), kk) {
+			s.nodes = append(s.nodes[:i], s.nodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Contains reports whether the node identified by kk is in the set.
+func (s *ClosestSet[K, N]) Contains(kk K) bool {
+	for _, n := range s.nodes {
+		if key.Equal(n.Key(), kk) {
+			return true
+		}
+	}
+	return false
+}
+
+// Nodes returns the nodes in the set, ordered by increasing distance from the local node.
+func (s *ClosestSet[K, N]) Nodes() []N {
+	nodes := make([]N, len(s.nodes))
+	copy(nodes, s.nodes)
+	return nodes
+}
+
+// Len returns the number of nodes in the set.
+func (s *ClosestSet[K, N]) Len() int {
+	return len(s.nodes)
+}
+
+// Full reports whether the set holds n nodes.
+func (s *ClosestSet[K, N]) Full() bool {
+	return len(s.nodes) >= s.n
+}
+
+// ClosestTrackingRT is a routing table that keeps a ClosestSet updated alongside an underlying
+// routing table, and nodes removed from the table are removed from the set. The routing table
+// cannot tell why it rejected a node, so every node offered to the table is also offered to the
+// set, whether or not the table accepts it: this lets the set hold close nodes that did not fit in
+// a full bucket, and leaves the policies of the table, such as a deny list or key filter, to the
+// accept function, which must hold for a node to enter the set. Like the routing tables, the set
+// keeps the nodes that stop being accepted once they entered it.
+type ClosestTrackingRT[K kad.Key[K], N kad.NodeID[K]] struct {
+	rt     kad.RoutingTable[K, N]
+	set    *ClosestSet[K, N]
+	accept func(N) bool
+}
+
+var _ kad.RoutingTable[key.Key8, kad.NodeID[key.Key8]] = (*ClosestTrackingRT[key.Key8, kad.NodeID[key.Key8]])(nil)
+
+// TrackClosest wraps rt so that set is updated alongside it. Only the nodes for which accept
+// returns true are added to the set, for example those not banned by the deny list of rt. If accept
+// is nil, all nodes are accepted.
+func TrackClosest[K kad.Key[K], N kad.NodeID[K]](rt kad.RoutingTable[K, N], set *ClosestSet[K, N], accept func(N) bool) *ClosestTrackingRT[K, N] {
+	if accept == nil {
+		accept = func(N) bool { return true }
+	}
+	return &ClosestTrackingRT[K, N]{
+		rt:     rt,
+		set:    set,
+		accept: accept,
+	}
+}
+
+// Closest returns the set updated alongside the routing table.
+func (c *ClosestTrackingRT[K, N]) Closest() *ClosestSet[K, N] {
+	return c.set
+}
+
+func (c *ClosestTrackingRT[K, N]) AddNode(node N) bool {
+	if c.accept(node) {
+		c.set.Add(node)
+	}
+	return c.rt.AddNode(node)
+}
+
+// RemoveKey removes the node from the routing table and from the set. When the set loses a node
+// it is refilled with the closest nodes remaining in the routing table.
+func (c *ClosestTrackingRT[K, N]) RemoveKey(kk K) bool {
+	removed := c.rt.RemoveKey(kk)
+	if c.set.Remove(kk) {
+		for _, n := range c.rt.NearestNodesFiltered(c.set.self, c.set.n, c.accept) {
+			c.set.Add(n)
+		}
+	}
+	return removed
+}
+
+func (c *ClosestTrackingRT[K, N]) NearestNodes(kk K, n int) []N {
+	return c.rt.NearestNodes(kk, n)
+}
+
+func (c *ClosestTrackingRT[K, N]) NearestNodesFiltered(kk K, n int, pred func(N) bool) []N {
+	return c.rt.NearestNodesFiltered(kk, n, pred)
+}
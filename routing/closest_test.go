@@ -0,0 +1,108 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/routing/denylist"
+	"github.com/plprobelab/go-kademlia/routing/triert"
+)
+
+func TestClosestSet(t *testing.T) {
+	a := kadtest.NewID(key.Key8(0b00000001)) // 1
+	b := kadtest.NewID(key.Key8(0b00000010)) // 2
+	c := kadtest.NewID(key.Key8(0b00000100)) // 4
+	d := kadtest.NewID(key.Key8(0b00001000)) // 8
+
+	s := NewClosestSet[key.Key8, kad.NodeID[key.Key8]](key.Key8(0), 3)
+	require.Equal(t, 0, s.Len())
+
+	require.True(t, s.Add(c))
+	require.True(t, s.Add(d))
+	require.True(t, s.Add(a))
+	require.False(t, s.Add(a)) // already present
+	require.False(t, s.Add(kadtest.NewID(key.Key8(0))))
+	require.True(t, s.Full())
+	require.Equal(t, []kad.NodeID[key.Key8]{a, c, d}, s.Nodes())
+
+	// a closer node evicts the farthest
+	require.True(t, s.Add(b))
+	require.Equal(t, []kad.NodeID[key.Key8]{a, b, c}, s.Nodes())
+	require.False(t, s.Contains(d.Key()))
+
+	// a farther node is not added to a full set
+	require.False(t, s.Add(d))
+
+	require.True(t, s.Remove(b.Key()))
+	require.False(t, s.Remove(b.Key()))
+	require.Equal(t, []kad.NodeID[key.Key8]{a, c}, s.Nodes())
+}
+
+func TestClosestTrackingRT(t *testing.T) {
+	self := kadtest.NewID(key.Key8(0))
+	a := kadtest.NewID(key.Key8(0b00000001)) // 1
+	b := kadtest.NewID(key.Key8(0b00000010)) // 2
+	c := kadtest.NewID(key.Key8(0b00000011)) // 3
+	d := kadtest.NewID(key.Key8(0b10000000)) // 128
+
+	// a full table rejects close nodes that the set still tracks
+	cfg := triert.DefaultConfig[key.Key8, kad.NodeID[key.Key8]]()
+	cfg.MaxEntries = 2
+	rt, err := triert.New[key.Key8, kad.NodeID[key.Key8]](self, cfg)
+	require.NoError(t, err)
+	set := NewClosestSet[key.Key8, kad.NodeID[key.Key8]](self.Key(), 2)
+	tr := TrackClosest[key.Key8, kad.NodeID[key.Key8]](rt, set, nil)
+
+	require.True(t, tr.AddNode(d))
+	require.True(t, tr.AddNode(c))
+	require.False(t, tr.AddNode(b))
+	require.False(t, tr.AddNode(a))
+	require.Equal(t, []kad.NodeID[key.Key8]{a, b}, tr.Closest().Nodes())
+
+	// removing a tracked node refills the set from the table
+	require.False(t, tr.RemoveKey(a.Key()))
+	require.Equal(t, []kad.NodeID[key.Key8]{b, c}, tr.Closest().Nodes())
+
+	require.True(t, tr.RemoveKey(c.Key()))
+	require.Equal(t, []kad.NodeID[key.Key8]{b, d}, tr.Closest().Nodes())
+}
+
+func TestClosestTrackingRTAccept(t *testing.T) {
+	self := kadtest.NewID(key.Key8(0))
+	a := kadtest.NewID(key.Key8(0b00000001)) // 1
+	b := kadtest.NewID(key.Key8(0b00000010)) // 2
+	c := kadtest.NewID(key.Key8(0b10000000)) // 128
+
+	d, err := denylist.New(nil)
+	require.NoError(t, err)
+	d.Ban(a, 0)
+
+	cfg := triert.DefaultConfig[key.Key8, kad.NodeID[key.Key8]]()
+	cfg.DenyList = d
+	rt, err := triert.New[key.Key8, kad.NodeID[key.Key8]](self, cfg)
+	require.NoError(t, err)
+	set := NewClosestSet[key.Key8, kad.NodeID[key.Key8]](self.Key(), 2)
+	tr := TrackClosest[key.Key8, kad.NodeID[key.Key8]](rt, set, func(n kad.NodeID[key.Key8]) bool {
+		return !d.Banned(n)
+	})
+
+	// banned nodes are rejected by the table and never enter the set
+	require.False(t, tr.AddNode(a))
+	require.True(t, tr.AddNode(b))
+	require.True(t, tr.AddNode(c))
+	require.Equal(t, []kad.NodeID[key.Key8]{b, c}, tr.Closest().Nodes())
+
+	// like the table, the set keeps nodes banned after they were added, but does not take them
+	// back once removed
+	d.Ban(c, 0)
+	require.True(t, tr.RemoveKey(b.Key()))
+	require.Equal(t, []kad.NodeID[key.Key8]{c}, tr.Closest().Nodes())
+	tr.Closest().Remove(c.Key())
+	require.True(t, tr.AddNode(b))
+	require.True(t, tr.RemoveKey(b.Key()))
+	require.Empty(t, tr.Closest().Nodes())
+}
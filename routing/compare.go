@@ -0,0 +1,134 @@
+package routing
+
+import (
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+// ComparisonOp identifies the routing table operation that produced a Divergence.
+type ComparisonOp string
+
+const (
+	ComparisonOpAddNode              ComparisonOp = "AddNode"
+	ComparisonOpRemoveKey            ComparisonOp = "RemoveKey"
+	ComparisonOpNearestNodes         ComparisonOp = "NearestNodes"
+	ComparisonOpNearestNodesFiltered ComparisonOp = "NearestNodesFiltered"
+)
+
+// Divergence describes an operation for which the primary and shadow routing tables of a
+// ComparisonRT returned different results.
+type Divergence[K kad.Key[K], N kad.NodeID[K]] struct {
+	Op     ComparisonOp // the operation that diverged
+	Key    K            // the key passed to the operation
+	N      int          // the number of nodes requested, only set for nearest nodes operations
+	Result [2]bool      // the results of AddNode or RemoveKey for the primary and shadow tables
+	Nodes  [2][]N       // the results of a nearest nodes operation for the primary and shadow tables
+}
+
+// DivergenceFunc is called by a ComparisonRT whenever its routing tables diverged.
+type DivergenceFunc[K kad.Key[K], N kad.NodeID[K]] func(*Divergence[K, N])
+
+// ComparisonRT is a routing table that mirrors all operations to a primary and a shadow
+// routing table and reports any divergence between their results. Results are always
+// taken from the primary table, which allows a new implementation to be validated
+// against an existing one on live workloads before switching.
+type ComparisonRT[K kad.Key[K], N kad.NodeID[K]] struct {
+	primary  kad.RoutingTable[K, N]
+	shadow   kad.RoutingTable[K, N]
+	diverged DivergenceFunc[K, N]
+}
+
+var _ kad.RoutingTable[key.Key8, kad.NodeID[key.Key8]] = (*ComparisonRT[key.Key8, kad.NodeID[key.Key8]])(nil)
+
+// NewComparisonRT creates a ComparisonRT that returns the results of primary, mirrors all
+// operations to shadow and calls diverged for each operation whose results differ.
+func NewComparisonRT[K kad.Key[K], N kad.NodeID[K]](primary, shadow kad.RoutingTable[K, N], diverged DivergenceFunc[K, N]) *ComparisonRT[K, N] {
+	return &ComparisonRT[K, N]{
+		primary:  primary,
+		shadow:   shadow,
+		diverged: diverged,
+	}
+}
+
+// Primary returns the routing table whose results are returned by the ComparisonRT.
+func (c *ComparisonRT[K, N]) Primary() kad.RoutingTable[K, N] {
+	return c.primary
+}
+
+// Shadow returns the routing table whose results are only compared against the primary.
+func (c *ComparisonRT[K, N]) Shadow() kad.RoutingTable[K, N] {
+	return c.shadow
+}
+
+func (c *ComparisonRT[K, N]) AddNode(node N) bool {
+	p := c.primary.AddNode(node)
+	s := c.shadow.AddNode(node)
+	if p != s {
+		c.report(&Divergence[K, N]{
+			Op:     ComparisonOpAddNode,
+			Key:    node.Key(),
+			Result: [2]bool{p, s},
+		})
+	}
+	return p
+}
+
+func (c *ComparisonRT[K, N]) RemoveKey(kk K) bool {
+	p := c.primary.RemoveKey(kk)
+	s := c.shadow.RemoveKey(kk)
+	if p != s {
+		c.report(&Divergence[K, N]{
+			Op:     ComparisonOpRemoveKey,
+			Key:    kk,
+			Result: [2]bool{p, s},
+		})
+	}
+	return p
+}
+
+func (c *ComparisonRT[K, N]) NearestNodes(kk K, n int) []N {
+	p := c.primary.NearestNodes(kk, n)
+	s := c.shadow.NearestNodes(kk, n)
+	if !equalNodes[K](p, s) {
+		c.report(&Divergence[K, N]{
+			Op:    ComparisonOpNearestNodes,
+			Key:   kk,
+			N:     n,
+			Nodes: [2][]N{p, s},
+		})
+	}
+	return p
+}
+
+func (c *ComparisonRT[K, N]) NearestNodesFiltered(kk K, n int, pred func(N) bool) []N {
+	p := c.primary.NearestNodesFiltered(kk, n, pred)
+	s := c.shadow.NearestNodesFiltered(kk, n, pred)
+	if !equalNodes[K](p, s) {
+		c.report(&Divergence[K, N]{
+			Op:    ComparisonOpNearestNodesFiltered,
+			Key:   kk,
+			N:     n,
+			Nodes: [2][]N{p, s},
+		})
+	}
+	return p
+}
+
+func (c *ComparisonRT[K, N]) report(d *Divergence[K, N]) {
+	if c.diverged != nil {
+		c.diverged(d)
+	}
+}
+
+// equalNodes reports whether a and b contain nodes with the same keys in the same order.
+func equalNodes[K kad.Key[K], N kad.NodeID[K]](a, b []N) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !key.Equal(a[i].Key(), b[i].Key()) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/routing/simplert"
+	"github.com/plprobelab/go-kademlia/routing/triert"
+)
+
+func TestComparisonRTNoDivergence(t *testing.T) {
+	self := kadtest.NewID(key.Key8(0))
+
+	primary, err := triert.New[key.Key8, kad.NodeID[key.Key8]](self, nil)
+	require.NoError(t, err)
+	shadow, err := triert.New[key.Key8, kad.NodeID[key.Key8]](self, nil)
+	require.NoError(t, err)
+
+	var divergences []*Divergence[key.Key8, kad.NodeID[key.Key8]]
+	rt := NewComparisonRT[key.Key8, kad.NodeID[key.Key8]](primary, shadow, func(d *Divergence[key.Key8, kad.NodeID[key.Key8]]) {
+		divergences = append(divergences, d)
+	})
+
+	a := kadtest.NewID(key.Key8(0b00000100))
+	b := kadtest.NewID(key.Key8(0b00001000))
+
+	require.True(t, rt.AddNode(a))
+	require.True(t, rt.AddNode(b))
+	require.False(t, rt.AddNode(a))
+	require.Equal(t, []kad.NodeID[key.Key8]{a, b}, rt.NearestNodes(key.Key8(0), 5))
+	require.True(t, rt.RemoveKey(a.Key()))
+	require.False(t, rt.RemoveKey(a.Key()))
+
+	// both tables received every operation
+	require.Equal(t, 1, primary.Size())
+	require.Equal(t, 1, shadow.Size())
+
+	require.Empty(t, divergences)
+}
+
+func TestComparisonRTReportsDivergence(t *testing.T) {
+	self := kadtest.NewID(key.Key8(0))
+
+	primary, err := triert.New[key.Key8, kad.NodeID[key.Key8]](self, nil)
+	require.NoError(t, err)
+	// a shadow table with a bucket size of 1 will reject nodes the primary accepts
+	shadow := simplert.New[key.Key8, kad.NodeID[key.Key8]](self, 1)
+
+	var divergences []*Divergence[key.Key8, kad.NodeID[key.Key8]]
+	rt := NewComparisonRT[key.Key8, kad.NodeID[key.Key8]](primary, shadow, func(d *Divergence[key.Key8, kad.NodeID[key.Key8]]) {
+		divergences = append(divergences, d)
+	})
+
+	a := kadtest.NewID(key.Key8(0b10000000))
+	b := kadtest.NewID(key.Key8(0b11000000))
+
+	require.True(t, rt.AddNode(a))
+	require.Empty(t, divergences)
+
+	// b shares a cpl of 0 with self, like a, so the shadow bucket is full
+	require.True(t, rt.AddNode(b))
+	require.Equal(t, 1, len(divergences))
+	require.Equal(t, ComparisonOpAddNode, divergences[0].Op)
+	require.Equal(t, b.Key(), divergences[0].Key)
+	require.Equal(t, [2]bool{true, false}, divergences[0].Result)
+
+	// results are taken from the primary table
+	nodes := rt.NearestNodes(b.Key(), 2)
+	require.Equal(t, []kad.NodeID[key.Key8]{b, a}, nodes)
+	require.Equal(t, 2, len(divergences))
+	require.Equal(t, ComparisonOpNearestNodes, divergences[1].Op)
+	require.Equal(t, 2, divergences[1].N)
+	require.Equal(t, nodes, divergences[1].Nodes[0])
+	require.Equal(t, []kad.NodeID[key.Key8]{a}, divergences[1].Nodes[1])
+}
@@ -0,0 +1,119 @@
+// Package denylist provides a list of banned nodes shared by routing tables, queries and servers.
+package denylist
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/kaderr"
+)
+
+// Config specifies optional configuration for a PeerDenyList
+type Config struct {
+	Clock clock.Clock // a clock that may replaced by a mock when testing
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *Config) Validate() error {
+	if cfg.Clock == nil {
+		return &kaderr.ConfigurationError{
+			Component: "DenyListConfig",
+			Err:       fmt.Errorf("clock must not be nil"),
+		}
+	}
+	return nil
+}
+
+// DefaultConfig returns the default configuration options for a PeerDenyList.
+// Options may be overridden before passing to New
+func DefaultConfig() *Config {
+	return &Config{
+		Clock: clock.New(), // use standard time
+	}
+}
+
+// A PeerDenyList records banned nodes. Routing tables consulting it refuse to add banned nodes and
+// queries consulting it do not contact them. Nodes already present in a routing table when they are
+// banned are not removed. Nodes are identified by the string form of their NodeID.
+// A PeerDenyList is safe for concurrent use.
+type PeerDenyList struct {
+	cfg Config
+
+	mu    sync.Mutex
+	nodes map[string]time.Time // expiry time of each ban, zero for a permanent ban
+}
+
+// New creates a new PeerDenyList. If cfg is nil, the default config is used.
+func New(cfg *Config) (*PeerDenyList, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &PeerDenyList{
+		cfg:   *cfg,
+		nodes: make(map[string]time.Time),
+	}, nil
+}
+
+// Ban bans a node for the duration ttl, replacing any existing ban. A ttl of zero or less bans
+// the node until it is unbanned.
+func (d *PeerDenyList) Ban(id fmt.Stringer, ttl time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var expiry time.Time
+	if ttl > 0 {
+		expiry = d.cfg.Clock.Now().Add(ttl)
+	}
+	d.nodes[id.String()] = expiry
+}
+
+// Unban lifts the ban of a node. It returns false if the node was not banned.
+func (d *PeerDenyList) Unban(id fmt.Stringer) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	k := id.String()
+	if !d.banned(k, d.cfg.Clock.Now()) {
+		return false
+	}
+	delete(d.nodes, k)
+	return true
+}
+
+// Banned reports whether a node is currently banned.
+func (d *PeerDenyList) Banned(id fmt.Stringer) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.banned(id.String(), d.cfg.Clock.Now())
+}
+
+// Len returns the number of nodes currently banned.
+func (d *PeerDenyList) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.cfg.Clock.Now()
+	for k := range d.nodes {
+		d.banned(k, now)
+	}
+	return len(d.nodes)
+}
+
+// banned reports whether the node identified by k is banned at time now, forgetting expired bans.
+func (d *PeerDenyList) banned(k string, now time.Time) bool {
+	expiry, ok := d.nodes[k]
+	if !ok {
+		return false
+	}
+	if !expiry.IsZero() && !now.Before(expiry) {
+		delete(d.nodes, k)
+		return false
+	}
+	return true
+}
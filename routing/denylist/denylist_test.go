@@ -0,0 +1,66 @@
+package denylist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+func TestConfigValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		cfg := DefaultConfig()
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("clock is not nil", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Clock = nil
+		require.Error(t, cfg.Validate())
+	})
+}
+
+func TestBan(t *testing.T) {
+	clk := clock.NewMock()
+	d, err := New(&Config{Clock: clk})
+	require.NoError(t, err)
+
+	a := kadtest.NewID(key.Key8(0b00000100))
+	b := kadtest.NewID(key.Key8(0b00001000))
+	c := kadtest.NewID(key.Key8(0b00010000))
+
+	d.Ban(a, time.Minute)
+	d.Ban(b, 0) // permanent
+	require.True(t, d.Banned(a))
+	require.True(t, d.Banned(b))
+	require.False(t, d.Banned(c))
+	require.Equal(t, 2, d.Len())
+
+	// bans expire after their ttl
+	clk.Add(time.Minute)
+	require.False(t, d.Banned(a))
+	require.True(t, d.Banned(b))
+	require.Equal(t, 1, d.Len())
+
+	// banning again replaces the ban
+	d.Ban(b, time.Second)
+	clk.Add(time.Second)
+	require.False(t, d.Banned(b))
+}
+
+func TestUnban(t *testing.T) {
+	d, err := New(nil)
+	require.NoError(t, err)
+
+	a := kadtest.NewID(key.Key8(0b00000100))
+	require.False(t, d.Unban(a))
+
+	d.Ban(a, time.Hour)
+	require.True(t, d.Unban(a))
+	require.False(t, d.Banned(a))
+	require.Equal(t, 0, d.Len())
+}
@@ -0,0 +1,44 @@
+// Package filter provides key filters shared by the routing table implementations. A key filter is
+// applied before a key is added to a routing table and returns false to prevent the key from being added.
+//
+// Filters are generic over the routing table they consult so a single filter can be instantiated as
+// the KeyFilterFunc of any table that implements Table, for example:
+//
+//	cfg.KeyFilter = filter.BucketLimit20[key.Key256, *triert.TrieRT[key.Key256, kad.NodeID[key.Key256]]]
+package filter
+
+import "github.com/plprobelab/go-kademlia/kad"
+
+// Table is the view of a routing table that the filters in this package consult.
+type Table[K kad.Key[K]] interface {
+	// Cpl returns the longest common prefix length the supplied key shares with the table's key.
+	Cpl(kk K) int
+
+	// CplSize returns the number of nodes in the table whose longest common prefix with the table's key is of length cpl.
+	CplSize(cpl int) int
+}
+
+// BucketLimit returns a filter that limits the number of keys in the table sharing the same common
+// prefix length with the table's key to n.
+func BucketLimit[K kad.Key[K], T Table[K]](n int) func(rt T, kk K) bool {
+	return func(rt T, kk K) bool {
+		return rt.CplSize(rt.Cpl(kk)) < n
+	}
+}
+
+// BucketLimit20 is a filter that limits the occupancy of buckets in the table to 20 keys.
+func BucketLimit20[K kad.Key[K], T Table[K]](rt T, kk K) bool {
+	return BucketLimit[K, T](20)(rt, kk)
+}
+
+// All returns a filter that accepts a key only if all the supplied filters accept it.
+func All[K kad.Key[K], T any](filters ...func(rt T, kk K) bool) func(rt T, kk K) bool {
+	return func(rt T, kk K) bool {
+		for _, f := range filters {
+			if !f(rt, kk) {
+				return false
+			}
+		}
+		return true
+	}
+}
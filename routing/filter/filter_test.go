@@ -0,0 +1,44 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+// table is a Table whose common prefix lengths and sizes are fixed for testing.
+type table struct {
+	sizes map[int]int
+}
+
+func (t *table) Cpl(kk key.Key8) int {
+	return key.Key8(0).CommonPrefixLength(kk)
+}
+
+func (t *table) CplSize(cpl int) int {
+	return t.sizes[cpl]
+}
+
+func TestBucketLimit(t *testing.T) {
+	rt := &table{sizes: map[int]int{0: 2, 1: 20}}
+	limit2 := BucketLimit[key.Key8, *table](2)
+
+	require.False(t, limit2(rt, key.Key8(0b10000000))) // cpl 0 holds 2 keys
+	require.True(t, limit2(rt, key.Key8(0b00100000)))  // cpl 2 is empty
+
+	require.True(t, BucketLimit20[key.Key8, *table](rt, key.Key8(0b10000000)))
+	require.False(t, BucketLimit20[key.Key8, *table](rt, key.Key8(0b01000000))) // cpl 1 holds 20 keys
+}
+
+func TestAll(t *testing.T) {
+	rt := &table{sizes: map[int]int{0: 2}}
+	accept := func(*table, key.Key8) bool { return true }
+	reject := func(*table, key.Key8) bool { return false }
+
+	require.True(t, All[key.Key8, *table]()(rt, key.Key8(1)))
+	require.True(t, All[key.Key8](accept, accept)(rt, key.Key8(1)))
+	require.False(t, All[key.Key8](accept, reject)(rt, key.Key8(1)))
+	require.False(t, All[key.Key8](accept, BucketLimit[key.Key8, *table](2))(rt, key.Key8(0b10000000)))
+}
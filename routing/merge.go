@@ -0,0 +1,151 @@
+package routing
+
+import (
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+// A NodeLister can enumerate all the nodes it holds. TrieRT and SimpleRT are NodeListers.
+type NodeLister[K kad.Key[K], N kad.NodeID[K]] interface {
+	// AllNodes returns all the nodes held.
+	AllNodes() []N
+}
+
+// Nodes is a NodeLister over a fixed list of nodes, such as a persisted snapshot of a routing table.
+type Nodes[K kad.Key[K], N kad.NodeID[K]] []N
+
+func (ns Nodes[K, N]) AllNodes() []N {
+	return ns
+}
+
+// ListableRT is a routing table that can enumerate its nodes.
+type ListableRT[K kad.Key[K], N kad.NodeID[K]] interface {
+	kad.RoutingTable[K, N]
+	NodeLister[K, N]
+}
+
+// A Change is a key held by both sides of a TableDiff under a different node, for example
+// because the node identifier changed representation. Nodes are compared by their string form.
+type Change[K kad.Key[K], N kad.NodeID[K]] struct {
+	Ours   N // the node held by the local table
+	Theirs N // the node held by the other table
+}
+
+// TableDiff describes the differences between a local routing table and another set of nodes.
+type TableDiff[K kad.Key[K], N kad.NodeID[K]] struct {
+	Added   []N            // nodes only held by the other side
+	Removed []N            // nodes only held by the local table
+	Changed []Change[K, N] // keys held by both sides under different nodes
+}
+
+// Empty reports whether the diff contains no differences.
+func (d *TableDiff[K, N]) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares the nodes held by ours with those held by other. Nodes are matched by their Kademlia key.
+// The nodes in each list of the diff keep the order in which they were enumerated.
+func Diff[K kad.Key[K], N kad.NodeID[K]](ours, other NodeLister[K, N]) *TableDiff[K, N] {
+	d := &TableDiff[K, N]{}
+
+	theirs := make(map[string]N)
+	for _, n := range other.AllNodes() {
+		theirs[key.HexString(n.Key())] = n
+	}
+
+	seen := make(map[string]bool)
+	for _, n := range ours.AllNodes() {
+		hk := key.HexString(n.Key())
+		seen[hk] = true
+		t, ok := theirs[hk]
+		if !ok {
+			d.Removed = append(d.Removed, n)
+			continue
+		}
+		if t.String() != n.String() {
+			d.Changed = append(d.Changed, Change[K, N]{Ours: n, Theirs: t})
+		}
+	}
+
+	for _, n := range other.AllNodes() {
+		if !seen[key.HexString(n.Key())] {
+			d.Added = append(d.Added, n)
+		}
+	}
+
+	return d
+}
+
+// A MergePolicy controls which entries win when merging another set of nodes into a routing table.
+type MergePolicy[K kad.Key[K], N kad.NodeID[K]] interface {
+	// Resolve returns the node to keep when both sides hold a different node under the same key.
+	Resolve(ours, theirs N) N
+
+	// KeepMissing reports whether a node held by the local table but not by the other side is kept.
+	KeepMissing(ours N) bool
+}
+
+// PreferLocal is a MergePolicy that keeps the local entry on conflict and never removes local nodes.
+type PreferLocal[K kad.Key[K], N kad.NodeID[K]] struct{}
+
+func (PreferLocal[K, N]) Resolve(ours, theirs N) N { return ours }
+func (PreferLocal[K, N]) KeepMissing(N) bool       { return true }
+
+// PreferRemote is a MergePolicy that takes the other side's entry on conflict and never removes local nodes.
+type PreferRemote[K kad.Key[K], N kad.NodeID[K]] struct{}
+
+func (PreferRemote[K, N]) Resolve(ours, theirs N) N { return theirs }
+func (PreferRemote[K, N]) KeepMissing(N) bool       { return true }
+
+// Mirror is a MergePolicy that makes the local table match the other side as closely as its bucket
+// limits allow: it takes the other side's entries on conflict and removes local nodes the other side does not hold.
+type Mirror[K kad.Key[K], N kad.NodeID[K]] struct{}
+
+func (Mirror[K, N]) Resolve(ours, theirs N) N { return theirs }
+func (Mirror[K, N]) KeepMissing(N) bool       { return false }
+
+// MergeStats reports the outcome of a Merge.
+type MergeStats struct {
+	Added    int // the number of nodes added to the local table
+	Replaced int // the number of local nodes replaced by the other side's node under the same key
+	Removed  int // the number of local nodes removed because the other side does not hold them
+	Rejected int // the number of nodes the local table refused to add, e.g. because a bucket was full
+}
+
+// Merge reconciles rt with the nodes held by other according to policy. Local nodes are removed
+// before nodes are added so that space freed in full buckets can be used by the other side's nodes.
+func Merge[K kad.Key[K], N kad.NodeID[K]](rt ListableRT[K, N], other NodeLister[K, N], policy MergePolicy[K, N]) MergeStats {
+	var stats MergeStats
+	d := Diff[K, N](rt, other)
+
+	for _, n := range d.Removed {
+		if !policy.KeepMissing(n) && rt.RemoveKey(n.Key()) {
+			stats.Removed++
+		}
+	}
+
+	for _, c := range d.Changed {
+		winner := policy.Resolve(c.Ours, c.Theirs)
+		if winner.String() == c.Ours.String() {
+			continue
+		}
+		rt.RemoveKey(c.Ours.Key())
+		if rt.AddNode(winner) {
+			stats.Replaced++
+			continue
+		}
+		// restore the local node if the table refused the replacement
+		rt.AddNode(c.Ours)
+		stats.Rejected++
+	}
+
+	for _, n := range d.Added {
+		if rt.AddNode(n) {
+			stats.Added++
+		} else {
+			stats.Rejected++
+		}
+	}
+
+	return stats
+}
@@ -0,0 +1,87 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/routing/triert"
+)
+
+// namedNode is a node whose string form is independent of its key so that tests can hold
+// different nodes under the same key.
+type namedNode struct {
+	name string
+	key  key.Key8
+}
+
+func (n *namedNode) Key() key.Key8  { return n.key }
+func (n *namedNode) String() string { return n.name }
+
+func newMergeTable(t *testing.T, maxEntries int, nodes ...kad.NodeID[key.Key8]) *triert.TrieRT[key.Key8, kad.NodeID[key.Key8]] {
+	cfg := triert.DefaultConfig[key.Key8, kad.NodeID[key.Key8]]()
+	cfg.MaxEntries = maxEntries
+	rt, err := triert.New[key.Key8, kad.NodeID[key.Key8]](kadtest.NewID(key.Key8(0)), cfg)
+	require.NoError(t, err)
+	for _, n := range nodes {
+		require.True(t, rt.AddNode(n))
+	}
+	return rt
+}
+
+func TestDiff(t *testing.T) {
+	a := &namedNode{name: "a", key: key.Key8(0b00000001)}
+	b := &namedNode{name: "b", key: key.Key8(0b00000010)}
+	b2 := &namedNode{name: "b2", key: key.Key8(0b00000010)}
+	c := &namedNode{name: "c", key: key.Key8(0b00000100)}
+
+	rt := newMergeTable(t, 0, a, b)
+
+	d := Diff[key.Key8, kad.NodeID[key.Key8]](rt, rt)
+	require.True(t, d.Empty())
+
+	d = Diff[key.Key8, kad.NodeID[key.Key8]](rt, Nodes[key.Key8, kad.NodeID[key.Key8]]{b2, c})
+	require.False(t, d.Empty())
+	require.Equal(t, []kad.NodeID[key.Key8]{c}, d.Added)
+	require.Equal(t, []kad.NodeID[key.Key8]{a}, d.Removed)
+	require.Equal(t, []Change[key.Key8, kad.NodeID[key.Key8]]{{Ours: b, Theirs: b2}}, d.Changed)
+}
+
+func TestMerge(t *testing.T) {
+	a := &namedNode{name: "a", key: key.Key8(0b00000001)}
+	b := &namedNode{name: "b", key: key.Key8(0b00000010)}
+	b2 := &namedNode{name: "b2", key: key.Key8(0b00000010)}
+	c := &namedNode{name: "c", key: key.Key8(0b00000100)}
+	snapshot := Nodes[key.Key8, kad.NodeID[key.Key8]]{b2, c}
+
+	t.Run("prefer local", func(t *testing.T) {
+		rt := newMergeTable(t, 0, a, b)
+		stats := Merge[key.Key8, kad.NodeID[key.Key8]](rt, snapshot, PreferLocal[key.Key8, kad.NodeID[key.Key8]]{})
+		require.Equal(t, MergeStats{Added: 1}, stats)
+		require.ElementsMatch(t, []kad.NodeID[key.Key8]{a, b, c}, rt.AllNodes())
+	})
+
+	t.Run("prefer remote", func(t *testing.T) {
+		rt := newMergeTable(t, 0, a, b)
+		stats := Merge[key.Key8, kad.NodeID[key.Key8]](rt, snapshot, PreferRemote[key.Key8, kad.NodeID[key.Key8]]{})
+		require.Equal(t, MergeStats{Added: 1, Replaced: 1}, stats)
+		require.ElementsMatch(t, []kad.NodeID[key.Key8]{a, b2, c}, rt.AllNodes())
+	})
+
+	t.Run("mirror", func(t *testing.T) {
+		rt := newMergeTable(t, 0, a, b)
+		stats := Merge[key.Key8, kad.NodeID[key.Key8]](rt, snapshot, Mirror[key.Key8, kad.NodeID[key.Key8]]{})
+		require.Equal(t, MergeStats{Added: 1, Replaced: 1, Removed: 1}, stats)
+		require.True(t, Diff[key.Key8, kad.NodeID[key.Key8]](rt, snapshot).Empty())
+	})
+
+	t.Run("full table rejects nodes", func(t *testing.T) {
+		rt := newMergeTable(t, 2, a, b)
+		stats := Merge[key.Key8, kad.NodeID[key.Key8]](rt, snapshot, PreferRemote[key.Key8, kad.NodeID[key.Key8]]{})
+		require.Equal(t, MergeStats{Replaced: 1, Rejected: 1}, stats)
+		require.ElementsMatch(t, []kad.NodeID[key.Key8]{a, b2}, rt.AllNodes())
+	})
+}
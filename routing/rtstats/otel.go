@@ -0,0 +1,66 @@
+package rtstats
+
+import (
+	"context"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Register creates observable instruments on meter that report the statistics of p each time
+// metrics are collected. The attributes are added to every observation, for example to distinguish
+// several routing tables. p is called from the metric collection goroutine so it must be safe for
+// concurrent use with the routing table it reports on. Unregister the returned registration to stop
+// reporting.
+func Register(meter metric.Meter, p Provider, attrs ...attribute.KeyValue) (metric.Registration, error) {
+	size, err := meter.Int64ObservableGauge("rt.size",
+		metric.WithDescription("Total number of nodes in the routing table"))
+	if err != nil {
+		return nil, err
+	}
+
+	bucketSize, err := meter.Int64ObservableGauge("rt.bucket.size",
+		metric.WithDescription("Number of nodes in each bucket of the routing table"))
+	if err != nil {
+		return nil, err
+	}
+
+	fill, err := meter.Float64ObservableGauge("rt.bucket.average_fill",
+		metric.WithDescription("Average number of nodes per bucket of the routing table"))
+	if err != nil {
+		return nil, err
+	}
+
+	lastRefresh, err := meter.Int64ObservableGauge("rt.last_refresh",
+		metric.WithDescription("Unix time at which a node was last added to or refreshed in the routing table"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+
+	oldestRefresh, err := meter.Int64ObservableGauge("rt.oldest_refresh",
+		metric.WithDescription("Unix time of the least recent refresh of a node in the routing table"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+
+	set := metric.WithAttributes(attrs...)
+	return meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		s := p.Stats()
+		o.ObserveInt64(size, int64(s.Size), set)
+		o.ObserveFloat64(fill, s.AverageFill, set)
+		for i, n := range s.Buckets {
+			bucketAttrs := append([]attribute.KeyValue{attribute.String("bucket", strconv.Itoa(i))}, attrs...)
+			o.ObserveInt64(bucketSize, int64(n), metric.WithAttributes(bucketAttrs...))
+		}
+		if !s.LastRefresh.IsZero() {
+			o.ObserveInt64(lastRefresh, s.LastRefresh.Unix(), set)
+		}
+		if !s.OldestRefresh.IsZero() {
+			o.ObserveInt64(oldestRefresh, s.OldestRefresh.Unix(), set)
+		}
+		return nil
+	}, size, bucketSize, fill, lastRefresh, oldestRefresh)
+}
@@ -0,0 +1,54 @@
+// Package rtstats defines the statistics routing tables report about their contents and an adapter
+// exposing them as OpenTelemetry instruments.
+package rtstats
+
+import "time"
+
+// Stats is a snapshot of the contents of a routing table.
+type Stats struct {
+	Size           int       // the total number of nodes in the table
+	Buckets        []int     // the number of nodes in each bucket, for tables without fixed buckets indexed by common prefix length with the table's key
+	BucketCapacity int       // the maximum number of nodes a bucket may hold, zero if unbounded
+	AverageFill    float64   // the average number of nodes per bucket, zero if the table has no buckets
+	LastRefresh    time.Time // the most recent time a node was added or refreshed, zero if not tracked
+	OldestRefresh  time.Time // the least recent time a node currently in the table was added or refreshed, zero if not tracked
+}
+
+// A Provider is a routing table that can report statistics about its contents.
+type Provider interface {
+	// Stats returns a snapshot of the routing table's statistics.
+	Stats() Stats
+}
+
+// ProviderFunc adapts a function to a Provider, for example to collect statistics from a routing
+// table through a synchronizing wrapper.
+type ProviderFunc func() Stats
+
+func (f ProviderFunc) Stats() Stats {
+	return f()
+}
+
+// AverageFill returns the mean number of nodes per bucket given the sizes of the buckets.
+func AverageFill(buckets []int) float64 {
+	if len(buckets) == 0 {
+		return 0
+	}
+	total := 0
+	for _, n := range buckets {
+		total += n
+	}
+	return float64(total) / float64(len(buckets))
+}
+
+// Refreshed updates the LastRefresh and OldestRefresh bounds of s with the refresh time of a node.
+func (s *Stats) Refreshed(t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	if s.LastRefresh.IsZero() || t.After(s.LastRefresh) {
+		s.LastRefresh = t
+	}
+	if s.OldestRefresh.IsZero() || t.Before(s.OldestRefresh) {
+		s.OldestRefresh = t
+	}
+}
@@ -0,0 +1,44 @@
+package rtstats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+func TestAverageFill(t *testing.T) {
+	require.Zero(t, AverageFill(nil))
+	require.Equal(t, 2.0, AverageFill([]int{1, 3}))
+	require.Equal(t, 1.5, AverageFill([]int{3, 0}))
+}
+
+func TestRefreshed(t *testing.T) {
+	var s Stats
+	s.Refreshed(time.Time{})
+	require.True(t, s.LastRefresh.IsZero())
+	require.True(t, s.OldestRefresh.IsZero())
+
+	t0 := time.Unix(1000, 0)
+	s.Refreshed(t0)
+	require.Equal(t, t0, s.LastRefresh)
+	require.Equal(t, t0, s.OldestRefresh)
+
+	s.Refreshed(t0.Add(time.Minute))
+	s.Refreshed(t0.Add(-time.Minute))
+	require.Equal(t, t0.Add(time.Minute), s.LastRefresh)
+	require.Equal(t, t0.Add(-time.Minute), s.OldestRefresh)
+}
+
+func TestRegister(t *testing.T) {
+	p := ProviderFunc(func() Stats {
+		return Stats{Size: 3, Buckets: []int{2, 1}, AverageFill: 1.5}
+	})
+	require.Equal(t, 3, p.Stats().Size)
+
+	reg, err := Register(noop.NewMeterProvider().Meter("test"), p, attribute.String("table", "main"))
+	require.NoError(t, err)
+	require.NoError(t, reg.Unregister())
+}
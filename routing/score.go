@@ -0,0 +1,18 @@
+package routing
+
+import (
+	"github.com/plprobelab/go-kademlia/kad"
+)
+
+// A Scorer is a routing table that tracks how useful its nodes are to queries, such as
+// triert.TrieRT. The coordinator records the outcome of every query request with the scorer
+// it is given as its routing table.
+type Scorer[K kad.Key[K]] interface {
+	// RecordResponse records that the node identified by kk answered a query request, contributing
+	// the given number of closer nodes. It returns false if the node is not present in the table.
+	RecordResponse(kk K, closer int) bool
+
+	// RecordFailure records that the node identified by kk failed to answer a query request.
+	// It returns false if the node is not present in the table.
+	RecordFailure(kk K) bool
+}
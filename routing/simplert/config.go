@@ -0,0 +1,78 @@
+package simplert
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/routing/denylist"
+)
+
+// Config holds configuration options for a SimpleRT.
+type Config[K kad.Key[K], N kad.NodeID[K]] struct {
+	// BucketSize is the maximum number of peers held in each bucket.
+	BucketSize int
+
+	// KeyFilter defines the filter that is applied before a key is added to the table.
+	// If nil, no filter is applied.
+	KeyFilter KeyFilterFunc[K, N]
+
+	// DenyList is consulted before a peer is added to the table, banned peers are rejected.
+	// If nil, no peers are banned.
+	DenyList *denylist.PeerDenyList
+
+	// EntryTTL is the duration after which a peer that has not been refreshed, by being added again
+	// or marked alive, is removed from the table by the next Sweep. If zero, peers never expire.
+	EntryTTL time.Duration
+
+	// Clock is the clock used to track the expiry of peers. It may be replaced by a mock when testing.
+	Clock clock.Clock
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *Config[K, N]) Validate() error {
+	if cfg.BucketSize < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "SimpleRTConfig",
+			Err:       fmt.Errorf("bucket size must be greater than zero"),
+		}
+	}
+	if cfg.EntryTTL < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "SimpleRTConfig",
+			Err:       fmt.Errorf("entry ttl must not be negative"),
+		}
+	}
+	if cfg.EntryTTL > 0 && cfg.Clock == nil {
+		return &kaderr.ConfigurationError{
+			Component: "SimpleRTConfig",
+			Err:       fmt.Errorf("clock must not be nil when entries expire"),
+		}
+	}
+	return nil
+}
+
+// DefaultConfig returns a default configuration for a SimpleRT.
+func DefaultConfig[K kad.Key[K], N kad.NodeID[K]]() *Config[K, N] {
+	return ConfigFrom[K, N](kad.DefaultConfig())
+}
+
+// ConfigFrom returns the default configuration for a SimpleRT whose buckets hold the BucketSize
+// of kcfg.
+func ConfigFrom[K kad.Key[K], N kad.NodeID[K]](kcfg *kad.Config) *Config[K, N] {
+	return &Config[K, N]{
+		BucketSize: kcfg.BucketSize,
+		KeyFilter:  nil,
+		DenyList:   nil,
+		EntryTTL:   0,
+		Clock:      clock.New(), // use standard time
+	}
+}
+
+// KeyFilterFunc is a function that is applied before a key is added to the table.
+// Return false to prevent the key from being added.
+// Filters shared with other routing tables are provided by the filter package.
+type KeyFilterFunc[K kad.Key[K], N kad.NodeID[K]] func(rt *SimpleRT[K, N], kk K) bool
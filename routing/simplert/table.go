@@ -3,11 +3,16 @@ package simplert
 import (
 	"context"
 	"sort"
+	"time"
 
-	"github.com/plprobelab/go-kademlia/internal/kadtest"
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/kadtest"
 
 	"github.com/plprobelab/go-kademlia/kad"
 	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/routing/denylist"
+	"github.com/plprobelab/go-kademlia/routing/rtstats"
 	"github.com/plprobelab/go-kademlia/util"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
@@ -22,6 +27,18 @@ type SimpleRT[K kad.Key[K], N kad.NodeID[K]] struct {
 	self       K
 	buckets    [][]peerInfo[K, N]
 	bucketSize int
+
+	// entryTTL is the duration after which peers that have not been refreshed are removed by Sweep,
+	// zero if peers never expire
+	entryTTL time.Duration
+	clk      clock.Clock
+	// lastSeen holds the time each peer was last added or marked alive, keyed by the hex form of its key
+	lastSeen map[string]time.Time
+
+	// denyList is consulted before a peer is added to the table, nil if no peers are banned
+	denyList *denylist.PeerDenyList
+	// keyFilter is applied before a key is added to the table, nil if no filter is applied
+	keyFilter KeyFilterFunc[K, N]
 }
 
 var _ kad.RoutingTable[key.Key256, kadtest.ID[key.Key256]] = (*SimpleRT[key.Key256, kadtest.ID[key.Key256]])(nil)
@@ -37,6 +54,66 @@ func New[K kad.Key[K], N kad.NodeID[K]](self N, bucketSize int) *SimpleRT[K, N]
 	return &rt
 }
 
+// NewWithConfig creates a new SimpleRT using the supplied config. If cfg is nil, the default config is used.
+func NewWithConfig[K kad.Key[K], N kad.NodeID[K]](self N, cfg *Config[K, N]) (*SimpleRT[K, N], error) {
+	if cfg == nil {
+		cfg = DefaultConfig[K, N]()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	rt := New[K, N](self, cfg.BucketSize)
+	rt.keyFilter = cfg.KeyFilter
+	rt.denyList = cfg.DenyList
+	if cfg.EntryTTL > 0 {
+		rt.entryTTL = cfg.EntryTTL
+		rt.clk = cfg.Clock
+		rt.lastSeen = make(map[string]time.Time)
+	}
+	return rt, nil
+}
+
+// MarkAlive refreshes the expiry of the peer identified by kadId. It returns false if the peer
+// is not present in the table.
+func (rt *SimpleRT[K, N]) MarkAlive(kadId K) bool {
+	bid, _ := rt.BucketIdForKey(kadId)
+	if !rt.alreadyInBucket(kadId, bid) {
+		return false
+	}
+	rt.touch(kadId)
+	return true
+}
+
+// Sweep removes all peers that have not been refreshed within the EntryTTL of the config and
+// returns the number of peers removed. It does nothing if expiry is not enabled.
+func (rt *SimpleRT[K, N]) Sweep() int {
+	if rt.entryTTL == 0 {
+		return 0
+	}
+
+	now := rt.clk.Now()
+	expired := make([]K, 0)
+	for _, b := range rt.buckets {
+		for _, p := range b {
+			if now.Sub(rt.lastSeen[key.HexString(p.kadId)]) >= rt.entryTTL {
+				expired = append(expired, p.kadId)
+			}
+		}
+	}
+
+	for _, kadId := range expired {
+		rt.RemoveKey(kadId)
+	}
+	return len(expired)
+}
+
+// touch records the peer as seen now if expiry is enabled.
+func (rt *SimpleRT[K, N]) touch(kadId K) {
+	if rt.entryTTL > 0 {
+		rt.lastSeen[key.HexString(kadId)] = rt.clk.Now()
+	}
+}
+
 func (rt *SimpleRT[K, N]) Self() K {
 	return rt.self
 }
@@ -50,25 +127,83 @@ func (rt *SimpleRT[K, N]) BucketSize() int {
 }
 
 func (rt *SimpleRT[K, N]) BucketIdForKey(kadId K) (int, error) {
-	bid := rt.self.CommonPrefixLength(kadId)
-	nBuckets := len(rt.buckets)
-	if bid >= nBuckets {
-		bid = nBuckets - 1
-	}
-	return bid, nil
+	return key.BucketIndex(rt.self, kadId, len(rt.buckets)), nil
 }
 
 func (rt *SimpleRT[K, N]) SizeOfBucket(bucketId int) int {
 	return len(rt.buckets[bucketId])
 }
 
+// Size returns the number of peers contained in the table.
+func (rt *SimpleRT[K, N]) Size() int {
+	size := 0
+	for _, b := range rt.buckets {
+		size += len(b)
+	}
+	return size
+}
+
+// AllNodes returns all the nodes contained in the table, ordered by bucket.
+func (rt *SimpleRT[K, N]) AllNodes() []N {
+	nodes := make([]N, 0, rt.Size())
+	for _, b := range rt.buckets {
+		for _, p := range b {
+			nodes = append(nodes, p.id)
+		}
+	}
+	return nodes
+}
+
+// CplSize returns the number of peers in the table whose longest common prefix with the table's key is of length cpl.
+func (rt *SimpleRT[K, N]) CplSize(cpl int) int {
+	if cpl < 0 {
+		return 0
+	}
+	lastBucketId := len(rt.buckets) - 1
+	if cpl < lastBucketId {
+		// all peers in buckets other than the last share exactly the bucket id as prefix
+		return len(rt.buckets[cpl])
+	}
+	// the last bucket also holds peers with longer common prefixes
+	n := 0
+	for _, p := range rt.buckets[lastBucketId] {
+		if rt.self.CommonPrefixLength(p.kadId) == cpl {
+			n++
+		}
+	}
+	return n
+}
+
 func (rt *SimpleRT[K, N]) AddNode(id N) bool {
-	return rt.addPeer(id.Key(), id)
+	if rt.denyList != nil && rt.denyList.Banned(id) {
+		return false
+	}
+
+	kadId := id.Key()
+	if rt.MarkAlive(kadId) {
+		// the peer is already present and has been refreshed
+		return false
+	}
+
+	if rt.keyFilter != nil && !rt.keyFilter(rt, kadId) {
+		return false
+	}
+
+	if !rt.addPeer(kadId, id) {
+		return false
+	}
+	rt.touch(kadId)
+	return true
+}
+
+// Cpl returns the longest common prefix length the supplied key shares with the table's key.
+func (rt *SimpleRT[K, N]) Cpl(kk K) int {
+	return rt.self.CommonPrefixLength(kk)
 }
 
 func (rt *SimpleRT[K, N]) addPeer(kadId K, id N) bool {
 	//_, span := util.StartSpan(ctx, "routing.simple.addPeer", trace.WithAttributes(
-	//	attribute.String("KadID", key.HexString(kadId)),
+	//	attribute.String("KadID", key.FormatHex(kadId)),
 	//	attribute.Stringer("PeerID", id),
 	//))
 	//defer span.End()
@@ -154,7 +289,7 @@ func (rt *SimpleRT[K, N]) alreadyInBucket(kadId K, bucketId int) bool {
 
 func (rt *SimpleRT[K, N]) RemoveKey(kadId K) bool {
 	//_, span := util.StartSpan(ctx, "routing.simple.removeKey", trace.WithAttributes(
-	//	attribute.String("KadID", key.HexString(kadId)),
+	//	attribute.String("KadID", key.FormatHex(kadId)),
 	//))
 	//defer span.End()
 
@@ -164,6 +299,10 @@ func (rt *SimpleRT[K, N]) RemoveKey(kadId K) bool {
 			// remove peer from bucket
 			rt.buckets[bid][i] = rt.buckets[bid][len(rt.buckets[bid])-1]
 			rt.buckets[bid] = rt.buckets[bid][:len(rt.buckets[bid])-1]
+			if rt.entryTTL > 0 {
+				delete(rt.lastSeen, key.HexString(kadId))
+			}
+			rt.mergeBuckets()
 
 			// span.AddEvent(fmt.Sprint(p.id.String(), "removed from bucket", bid))
 			return true
@@ -174,9 +313,27 @@ func (rt *SimpleRT[K, N]) RemoveKey(kadId K) bool {
 	return false
 }
 
+// mergeBuckets undoes bucket splits as the table shrinks: the last bucket is merged into the
+// previous one for as long as their combined peers fit in a single bucket.
+func (rt *SimpleRT[K, N]) mergeBuckets() {
+	for len(rt.buckets) > 1 {
+		lastBucketId := len(rt.buckets) - 1
+		if len(rt.buckets[lastBucketId])+len(rt.buckets[lastBucketId-1]) > rt.bucketSize {
+			return
+		}
+		rt.buckets[lastBucketId-1] = append(rt.buckets[lastBucketId-1], rt.buckets[lastBucketId]...)
+		rt.buckets = rt.buckets[:lastBucketId]
+	}
+}
+
+// NBuckets returns the number of buckets the table currently uses.
+func (rt *SimpleRT[K, N]) NBuckets() int {
+	return len(rt.buckets)
+}
+
 func (rt *SimpleRT[K, N]) Find(ctx context.Context, kadId K) (N, error) {
 	_, span := util.StartSpan(ctx, "routing.simple.find", trace.WithAttributes(
-		attribute.String("KadID", key.HexString(kadId)),
+		attribute.String("KadID", key.FormatHex(kadId)),
 	))
 	defer span.End()
 
@@ -192,8 +349,14 @@ func (rt *SimpleRT[K, N]) Find(ctx context.Context, kadId K) (N, error) {
 // TODO: not exactly working as expected
 // returns min(n, bucketSize) peers from the bucket matching the given key
 func (rt *SimpleRT[K, N]) NearestNodes(kadId K, n int) []N {
+	return rt.NearestNodesFiltered(kadId, n, nil)
+}
+
+// NearestNodesFiltered returns the n closest nodes to the given key for which
+// pred returns true. A nil pred accepts all nodes.
+func (rt *SimpleRT[K, N]) NearestNodesFiltered(kadId K, n int, pred func(N) bool) []N {
 	//_, span := util.StartSpan(ctx, "routing.simple.nearestPeers", trace.WithAttributes(
-	//	attribute.String("KadID", key.HexString(kadId)),
+	//	attribute.String("KadID", key.FormatHex(kadId)),
 	//	attribute.Int("n", int(n)),
 	//))
 	//defer span.End()
@@ -202,14 +365,14 @@ func (rt *SimpleRT[K, N]) NearestNodes(kadId K, n int) []N {
 
 	var peers []peerInfo[K, N]
 	// TODO: optimize this
-	if len(rt.buckets[bid]) == n {
+	if pred == nil && len(rt.buckets[bid]) == n {
 		peers = make([]peerInfo[K, N], len(rt.buckets[bid]))
 		copy(peers, rt.buckets[bid])
 	} else {
 		peers = make([]peerInfo[K, N], 0)
 		for i := 0; i < len(rt.buckets); i++ {
 			for _, p := range rt.buckets[i] {
-				if !key.Equal(rt.self, p.kadId) {
+				if !key.Equal(rt.self, p.kadId) && (pred == nil || pred(p.id)) {
 					peers = append(peers, p)
 				}
 			}
@@ -217,14 +380,7 @@ func (rt *SimpleRT[K, N]) NearestNodes(kadId K, n int) []N {
 	}
 
 	sort.SliceStable(peers, func(i, j int) bool {
-		distI := peers[i].kadId.Xor(kadId)
-		distJ := peers[j].kadId.Xor(kadId)
-
-		cmp := distI.Compare(distJ)
-		if cmp != 0 {
-			return cmp < 0
-		}
-		return false
+		return key.DistanceCmp(kadId, peers[i].kadId, peers[j].kadId) < 0
 	})
 	pids := make([]N, min(n, len(peers)))
 	for i := 0; i < min(n, len(peers)); i++ {
@@ -240,3 +396,23 @@ func min(a, b int) int {
 	}
 	return b
 }
+
+// Stats returns a snapshot of the table's statistics. Refresh times are only tracked when expiry
+// is enabled.
+func (rt *SimpleRT[K, N]) Stats() rtstats.Stats {
+	s := rtstats.Stats{
+		Size:           rt.Size(),
+		Buckets:        make([]int, len(rt.buckets)),
+		BucketCapacity: rt.bucketSize,
+	}
+	for i, b := range rt.buckets {
+		s.Buckets[i] = len(b)
+		if rt.entryTTL > 0 {
+			for _, p := range b {
+				s.Refreshed(rt.lastSeen[key.HexString(p.kadId)])
+			}
+		}
+	}
+	s.AverageFill = rtstats.AverageFill(s.Buckets)
+	return s
+}
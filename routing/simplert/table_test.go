@@ -4,12 +4,17 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
 
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/plprobelab/go-kademlia/libp2p"
 
-	kt "github.com/plprobelab/go-kademlia/internal/kadtest"
+	kt "github.com/plprobelab/go-kademlia/kadtest"
 	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/routing/denylist"
+	"github.com/plprobelab/go-kademlia/routing/filter"
 	"github.com/stretchr/testify/require"
 )
 
@@ -117,6 +122,51 @@ func TestAddPeer(t *testing.T) {
 	require.False(t, success)
 }
 
+func TestMergeBuckets(t *testing.T) {
+	p := kt.NewID(key0) // irrelevant
+
+	rt := New[key.Key256](kt.NewID(key0), 2)
+
+	// fill bucket 0 with CPL = 0 peers
+	require.True(t, rt.addPeer(key2, p))
+	require.True(t, rt.addPeer(key3, p))
+	require.Equal(t, 1, rt.NBuckets())
+
+	// CPL = 1 peers split bucket 0
+	require.True(t, rt.addPeer(key1, p))
+	require.True(t, rt.addPeer(key5, p))
+	require.Equal(t, 2, rt.NBuckets())
+
+	// a CPL = 2 peer splits bucket 1
+	require.True(t, rt.addPeer(key10, p))
+	require.Equal(t, 3, rt.NBuckets())
+	require.Equal(t, 1, rt.SizeOfBucket(2))
+
+	// the last two buckets hold 3 peers, more than a bucket can hold
+	require.True(t, rt.RemoveKey(key10))
+	require.Equal(t, 2, rt.NBuckets())
+
+	// the remaining CPL = 1 peer is merged back into bucket 0 once it fits
+	require.True(t, rt.RemoveKey(key5))
+	require.Equal(t, 2, rt.NBuckets())
+	require.True(t, rt.RemoveKey(key2))
+	require.Equal(t, 1, rt.NBuckets())
+	require.Equal(t, 2, rt.SizeOfBucket(0))
+
+	// the merged peers are still found
+	require.Equal(t, 2, len(rt.NearestNodes(key0, 10)))
+	require.True(t, rt.RemoveKey(key1))
+	require.True(t, rt.RemoveKey(key3))
+	require.Equal(t, 1, rt.NBuckets())
+	require.Equal(t, 0, rt.Size())
+
+	// the table splits again as it grows
+	require.True(t, rt.addPeer(key2, p))
+	require.True(t, rt.addPeer(key3, p))
+	require.True(t, rt.addPeer(key1, p))
+	require.Equal(t, 2, rt.NBuckets())
+}
+
 func TestRemovePeer(t *testing.T) {
 	p := kt.NewID(key0) // irrelevant
 
@@ -204,3 +254,226 @@ func TestNearestPeers(t *testing.T) {
 	peers = rt2.NearestNodes(key0, 10)
 	require.Equal(t, peers[0], peers[1])
 }
+
+func TestNearestPeersFiltered(t *testing.T) {
+	peerIds := make([]libp2p.PeerID, 0, 12)
+	for i := 0; i < 12; i++ {
+		peerIds = append(peerIds, libp2p.PeerID{ID: peer.ID(fmt.Sprintf("QmPeer%d", i))})
+	}
+
+	bucketSize := 5
+
+	rt := SimpleRT[key.Key256, libp2p.PeerID]{
+		self:       key0,
+		buckets:    make([][]peerInfo[key.Key256, libp2p.PeerID], 0),
+		bucketSize: bucketSize,
+	}
+	rt.buckets = append(rt.buckets, make([]peerInfo[key.Key256, libp2p.PeerID], 0))
+
+	rt.addPeer(key1, peerIds[1])
+	rt.addPeer(key2, peerIds[2])
+	rt.addPeer(key3, peerIds[3])
+	rt.addPeer(key4, peerIds[4])
+	rt.addPeer(key5, peerIds[5])
+	rt.addPeer(key6, peerIds[6])
+	rt.addPeer(key7, peerIds[7])
+	rt.addPeer(key8, peerIds[8])
+	rt.addPeer(key9, peerIds[9])
+	rt.addPeer(key10, peerIds[10])
+	rt.addPeer(key11, peerIds[11])
+
+	// a nil predicate behaves like NearestNodes
+	require.Equal(t, rt.NearestNodes(key0, bucketSize), rt.NearestNodesFiltered(key0, bucketSize, nil))
+
+	// exclude the closest peer to key0, the next closest peer takes its place
+	peers := rt.NearestNodesFiltered(key0, bucketSize, func(p libp2p.PeerID) bool {
+		return p.ID != peerIds[9].ID
+	})
+	require.Equal(t, bucketSize, len(peers))
+
+	expectedOrder := rt.NearestNodes(key0, bucketSize+1)[1:]
+	require.Equal(t, expectedOrder, peers)
+}
+
+func TestAllNodesAndCplSize(t *testing.T) {
+	rt := New[key.Key256](kt.NewID(key0), 100)
+	require.Equal(t, 0, rt.Size())
+	require.Empty(t, rt.AllNodes())
+	require.Equal(t, 0, rt.CplSize(0))
+
+	keys := []key.Key256{key1, key2, key3, key4, key5, key6, key7, key8, key9, key10, key11}
+	for _, k := range keys {
+		require.True(t, rt.AddNode(kt.NewID(k)))
+	}
+	require.Equal(t, len(keys), rt.Size())
+
+	all := rt.AllNodes()
+	require.Equal(t, len(keys), len(all))
+	for _, k := range keys {
+		found := false
+		for _, n := range all {
+			if key.Equal(k, n.Key()) {
+				found = true
+				break
+			}
+		}
+		require.True(t, found, "key %s missing from AllNodes", key.HexString(k))
+	}
+
+	require.Equal(t, 3, rt.CplSize(0)) // key2, key3, key4
+	require.Equal(t, 3, rt.CplSize(1)) // key1, key5, key6
+	require.Equal(t, 2, rt.CplSize(2)) // key10, key11
+	require.Equal(t, 3, rt.CplSize(3)) // key7, key8, key9
+	require.Equal(t, 0, rt.CplSize(4))
+	require.Equal(t, 0, rt.CplSize(-1))
+
+	// splitting buckets does not change the counts
+	rt2 := New[key.Key256](kt.NewID(key0), 3)
+	for _, k := range keys {
+		rt2.AddNode(kt.NewID(k))
+	}
+	for cpl := 0; cpl < 4; cpl++ {
+		n := 0
+		for _, node := range rt2.AllNodes() {
+			if key0.CommonPrefixLength(node.Key()) == cpl {
+				n++
+			}
+		}
+		require.Equal(t, n, rt2.CplSize(cpl))
+	}
+}
+
+func TestEntryTTL(t *testing.T) {
+	clk := clock.NewMock()
+
+	// expiry is disabled by default
+	rt := New[key.Key256](kt.NewID(key0), 100)
+	require.True(t, rt.AddNode(kt.NewID(key1)))
+	clk.Add(time.Hour)
+	require.Equal(t, 0, rt.Sweep())
+
+	cfg := DefaultConfig[key.Key256, *kt.ID[key.Key256]]()
+	cfg.BucketSize = 100
+	cfg.EntryTTL = time.Minute
+	cfg.Clock = clk
+	rt, err := NewWithConfig[key.Key256](kt.NewID(key0), cfg)
+	require.NoError(t, err)
+	require.True(t, rt.AddNode(kt.NewID(key1)))
+	require.True(t, rt.AddNode(kt.NewID(key2)))
+	require.True(t, rt.AddNode(kt.NewID(key3)))
+
+	clk.Add(30 * time.Second)
+	require.False(t, rt.AddNode(kt.NewID(key1)))
+	require.True(t, rt.MarkAlive(key2))
+	require.False(t, rt.MarkAlive(key4))
+
+	// key3 expires, the refreshed peers do not
+	clk.Add(30 * time.Second)
+	require.Equal(t, 1, rt.Sweep())
+	require.Equal(t, 2, rt.Size())
+
+	clk.Add(time.Minute)
+	require.Equal(t, 2, rt.Sweep())
+	require.Equal(t, 0, rt.Size())
+}
+
+func TestStats(t *testing.T) {
+	rt := New[key.Key256](kt.NewID(key0), 2)
+	for _, k := range []key.Key256{key1, key2, key3, key7} {
+		rt.AddNode(kt.NewID(k))
+	}
+
+	s := rt.Stats()
+	require.Equal(t, rt.Size(), s.Size)
+	require.Equal(t, rt.NBuckets(), len(s.Buckets))
+	for i, n := range s.Buckets {
+		require.Equal(t, rt.SizeOfBucket(i), n)
+	}
+	require.Equal(t, 2, s.BucketCapacity)
+	require.Equal(t, float64(s.Size)/float64(len(s.Buckets)), s.AverageFill)
+
+	// refresh times are not tracked without expiry
+	require.True(t, s.LastRefresh.IsZero())
+
+	clk := clock.NewMock()
+	cfg := DefaultConfig[key.Key256, *kt.ID[key.Key256]]()
+	cfg.BucketSize = 2
+	cfg.EntryTTL = time.Hour
+	cfg.Clock = clk
+	rt, err := NewWithConfig[key.Key256](kt.NewID(key0), cfg)
+	require.NoError(t, err)
+	start := clk.Now()
+	for _, k := range []key.Key256{key1, key2, key3, key7} {
+		rt.AddNode(kt.NewID(k))
+	}
+	clk.Add(time.Minute)
+	rt.MarkAlive(key1)
+
+	s = rt.Stats()
+	require.Equal(t, start, s.OldestRefresh)
+	require.Equal(t, clk.Now(), s.LastRefresh)
+}
+
+func TestDenyList(t *testing.T) {
+	d, err := denylist.New(nil)
+	require.NoError(t, err)
+
+	cfg := DefaultConfig[key.Key256, *kt.ID[key.Key256]]()
+	cfg.DenyList = d
+	rt, err := NewWithConfig[key.Key256](kt.NewID(key0), cfg)
+	require.NoError(t, err)
+
+	d.Ban(kt.NewID(key1), 0)
+	require.False(t, rt.AddNode(kt.NewID(key1)))
+	require.True(t, rt.AddNode(kt.NewID(key2)))
+
+	// banning a peer does not remove it from the table
+	d.Ban(kt.NewID(key2), 0)
+	require.Equal(t, 1, rt.Size())
+
+	d.Unban(kt.NewID(key1))
+	require.True(t, rt.AddNode(kt.NewID(key1)))
+}
+
+func TestNewWithConfig(t *testing.T) {
+	rt, err := NewWithConfig[key.Key256](kt.NewID(key0), nil)
+	require.NoError(t, err)
+	require.Equal(t, 20, rt.BucketSize())
+
+	cfg := DefaultConfig[key.Key256, *kt.ID[key.Key256]]()
+	cfg.BucketSize = 0
+	_, err = NewWithConfig[key.Key256](kt.NewID(key0), cfg)
+	require.Error(t, err)
+
+	cfg = DefaultConfig[key.Key256, *kt.ID[key.Key256]]()
+	cfg.EntryTTL = -1
+	_, err = NewWithConfig[key.Key256](kt.NewID(key0), cfg)
+	require.Error(t, err)
+
+	cfg = DefaultConfig[key.Key256, *kt.ID[key.Key256]]()
+	cfg.EntryTTL = time.Minute
+	cfg.Clock = nil
+	_, err = NewWithConfig[key.Key256](kt.NewID(key0), cfg)
+	require.Error(t, err)
+}
+
+func TestKeyFilter(t *testing.T) {
+	cfg := DefaultConfig[key.Key256, *kt.ID[key.Key256]]()
+	cfg.KeyFilter = filter.BucketLimit[key.Key256, *SimpleRT[key.Key256, *kt.ID[key.Key256]]](2)
+	rt, err := NewWithConfig[key.Key256](kt.NewID(key0), cfg)
+	require.NoError(t, err)
+
+	// key2, key3 and key4 all have cpl 0
+	require.True(t, rt.AddNode(kt.NewID(key2)))
+	require.True(t, rt.AddNode(kt.NewID(key3)))
+	require.False(t, rt.AddNode(kt.NewID(key4)))
+
+	// keys with a different cpl are not affected
+	require.True(t, rt.AddNode(kt.NewID(key1)))
+
+	// a peer already present is still refreshed
+	require.False(t, rt.AddNode(kt.NewID(key2)))
+
+	require.True(t, rt.RemoveKey(key3))
+	require.True(t, rt.AddNode(kt.NewID(key4)))
+}
@@ -0,0 +1,21 @@
+package routing
+
+import (
+	"context"
+	"time"
+
+	"github.com/plprobelab/go-kademlia/event"
+)
+
+// A Sweeper is a routing table that can remove its stale entries.
+type Sweeper interface {
+	// Sweep removes the entries that have expired and returns the number of entries removed.
+	Sweep() int
+}
+
+// ScheduleSweep schedules s to be swept on sched every interval, like event.ScheduleSweep.
+func ScheduleSweep(ctx context.Context, sched event.Scheduler, s Sweeper, interval time.Duration) *event.RepeatedAction {
+	return event.ScheduleSweep(ctx, sched, event.SweepFunc(func(context.Context) (int, error) {
+		return s.Sweep(), nil
+	}), interval)
+}
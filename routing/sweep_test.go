@@ -0,0 +1,50 @@
+package routing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+)
+
+type countingSweeper struct {
+	sweeps int
+}
+
+func (s *countingSweeper) Sweep() int {
+	s.sweeps++
+	return 0
+}
+
+func TestScheduleSweep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clk := clock.NewMock()
+	sched := event.NewSimpleScheduler(clk)
+	sw := &countingSweeper{}
+
+	ScheduleSweep(ctx, sched, sw, time.Minute)
+
+	event.RunAll(ctx, sched)
+	require.Equal(t, 0, sw.sweeps)
+
+	clk.Add(time.Minute)
+	event.RunAll(ctx, sched)
+	require.Equal(t, 1, sw.sweeps)
+
+	// the sweep is rescheduled
+	clk.Add(time.Minute)
+	event.RunAll(ctx, sched)
+	require.Equal(t, 2, sw.sweeps)
+
+	// no further sweeps once the context is done
+	cancel()
+	clk.Add(time.Minute)
+	event.RunAll(context.Background(), sched)
+	require.Equal(t, 2, sw.sweeps)
+}
@@ -0,0 +1,78 @@
+package routing
+
+import (
+	"sync"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+// SynchronizedRT is a routing table that guards all access to an underlying routing table
+// with a read-write mutex so that it can be safely shared between goroutines.
+type SynchronizedRT[K kad.Key[K], N kad.NodeID[K]] struct {
+	mu sync.RWMutex
+	rt kad.RoutingTable[K, N]
+}
+
+var _ kad.RoutingTable[key.Key8, kad.NodeID[key.Key8]] = (*SynchronizedRT[key.Key8, kad.NodeID[key.Key8]])(nil)
+
+// Synchronized wraps rt in a SynchronizedRT. Callers must not access rt directly afterwards.
+func Synchronized[K kad.Key[K], N kad.NodeID[K]](rt kad.RoutingTable[K, N]) *SynchronizedRT[K, N] {
+	return &SynchronizedRT[K, N]{
+		rt: rt,
+	}
+}
+
+func (s *SynchronizedRT[K, N]) AddNode(node N) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rt.AddNode(node)
+}
+
+func (s *SynchronizedRT[K, N]) RemoveKey(kk K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rt.RemoveKey(kk)
+}
+
+func (s *SynchronizedRT[K, N]) NearestNodes(kk K, n int) []N {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rt.NearestNodes(kk, n)
+}
+
+func (s *SynchronizedRT[K, N]) NearestNodesFiltered(kk K, n int, pred func(N) bool) []N {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rt.NearestNodesFiltered(kk, n, pred)
+}
+
+// RecordResponse records the response of the node identified by kk with the underlying routing
+// table if it is a Scorer. It returns false if it is not, or if the node is not in the table.
+func (s *SynchronizedRT[K, N]) RecordResponse(kk K, closer int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sc, ok := s.rt.(Scorer[K]); ok {
+		return sc.RecordResponse(kk, closer)
+	}
+	return false
+}
+
+// RecordFailure records the failure of the node identified by kk with the underlying routing
+// table if it is a Scorer. It returns false if it is not, or if the node is not in the table.
+func (s *SynchronizedRT[K, N]) RecordFailure(kk K) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sc, ok := s.rt.(Scorer[K]); ok {
+		return sc.RecordFailure(kk)
+	}
+	return false
+}
+
+// Do calls fn with the underlying routing table while holding the write lock, allowing
+// operations that are not part of the RoutingTable interface to be performed safely.
+func (s *SynchronizedRT[K, N]) Do(fn func(rt kad.RoutingTable[K, N])) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.rt)
+}
@@ -0,0 +1,67 @@
+package routing
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/routing/triert"
+)
+
+func TestSynchronizedConcurrentAccess(t *testing.T) {
+	self := kadtest.NewID(key.Key32(0))
+	tr, err := triert.New[key.Key32, kad.NodeID[key.Key32]](self, nil)
+	require.NoError(t, err)
+
+	rt := Synchronized[key.Key32, kad.NodeID[key.Key32]](tr)
+
+	const writers = 4
+	const perWriter = 100
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(2)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				rt.AddNode(kadtest.NewID(key.Key32(uint32(w*perWriter + i + 1))))
+			}
+		}(w)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				rt.NearestNodes(key.Key32(uint32(i)), 20)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, writers*perWriter, tr.Size())
+
+	rt.Do(func(inner kad.RoutingTable[key.Key32, kad.NodeID[key.Key32]]) {
+		require.Same(t, tr, inner)
+	})
+
+	require.True(t, rt.RemoveKey(key.Key32(1)))
+	require.Equal(t, 20, len(rt.NearestNodesFiltered(key.Key32(0), 20, nil)))
+}
+
+func TestSynchronizedScorer(t *testing.T) {
+	self := kadtest.NewID(key.Key32(0))
+	node := kadtest.NewID(key.Key32(1))
+	tr, err := triert.New[key.Key32, kad.NodeID[key.Key32]](self, nil)
+	require.NoError(t, err)
+
+	var rt Scorer[key.Key32] = Synchronized[key.Key32, kad.NodeID[key.Key32]](tr)
+	require.False(t, rt.RecordResponse(node.Key(), 2))
+
+	tr.AddNode(node)
+	require.True(t, rt.RecordResponse(node.Key(), 2))
+	require.True(t, rt.RecordFailure(node.Key()))
+	st, _ := tr.NodeStats(node.Key())
+	require.Equal(t, triert.NodeStats{Answered: 1, Closer: 2, Failures: 1}, st)
+}
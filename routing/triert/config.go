@@ -1,7 +1,13 @@
 package triert
 
 import (
+	"time"
+
+	"github.com/benbjohnson/clock"
+
 	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/routing/denylist"
+	"github.com/plprobelab/go-kademlia/routing/filter"
 )
 
 // Config holds configuration options for a TrieRT.
@@ -9,11 +15,45 @@ type Config[K kad.Key[K], N kad.NodeID[K]] struct {
 	// KeyFilter defines the filter that is applied before a key is added to the table.
 	// If nil, no filter is applied.
 	KeyFilter KeyFilterFunc[K, N]
+
+	// MaxEntries is the maximum number of nodes the table may hold across all buckets.
+	// If zero, the size of the table is not limited.
+	MaxEntries int
+
+	// ShedFunc selects the node that is removed to make room for a new node when the table holds
+	// MaxEntries nodes. If nil, new nodes are rejected once the table is full.
+	ShedFunc ShedFunc[K, N]
+
+	// EntryTTL is the duration after which a node that has not been refreshed, by being added again
+	// or marked alive, is removed from the table by the next Sweep. If zero, nodes never expire.
+	EntryTTL time.Duration
+
+	// Clock is the clock used to track the expiry of nodes. It may be replaced by a mock when testing.
+	// If nil, the standard time is used.
+	Clock clock.Clock
+
+	// DenyList is consulted before a node is added to the table, banned nodes are rejected.
+	// If nil, no nodes are banned.
+	DenyList *denylist.PeerDenyList
 }
 
 // DefaultConfig returns a default configuration for a TrieRT.
 func DefaultConfig[K kad.Key[K], N kad.NodeID[K]]() *Config[K, N] {
 	return &Config[K, N]{
-		KeyFilter: nil,
+		KeyFilter:  nil,
+		MaxEntries: 0,
+		ShedFunc:   nil,
+		EntryTTL:   0,
+		Clock:      clock.New(), // use standard time
+		DenyList:   nil,
 	}
 }
+
+// ConfigFrom returns the default configuration for a TrieRT whose buckets hold up to the BucketSize
+// of kcfg, enforced by a filter.BucketLimit key filter. Unlike DefaultConfig, which leaves the
+// buckets unlimited, it gives a table bounded like the simplert table of the same kcfg.
+func ConfigFrom[K kad.Key[K], N kad.NodeID[K]](kcfg *kad.Config) *Config[K, N] {
+	cfg := DefaultConfig[K, N]()
+	cfg.KeyFilter = filter.BucketLimit[K, *TrieRT[K, N]](kcfg.BucketSize)
+	return cfg
+}
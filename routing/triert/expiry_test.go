@@ -0,0 +1,65 @@
+package triert
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+func TestEntryTTL(t *testing.T) {
+	clk := clock.NewMock()
+	cfg := DefaultConfig[key.Key32, node[key.Key32]]()
+	cfg.Clock = clk
+	cfg.EntryTTL = time.Minute
+	rt, err := New(node0, cfg)
+	require.NoError(t, err)
+
+	require.True(t, rt.AddNode(node1))
+	require.True(t, rt.AddNode(node2))
+	require.True(t, rt.AddNode(node3))
+
+	clk.Add(30 * time.Second)
+	require.Equal(t, 0, rt.Sweep())
+
+	// refresh node1 by adding it again and node2 by marking it alive
+	require.False(t, rt.AddNode(node1))
+	require.True(t, rt.MarkAlive(node2.Key()))
+	require.False(t, rt.MarkAlive(node4.Key()))
+
+	// node3 expires, the refreshed nodes do not
+	clk.Add(30 * time.Second)
+	require.Equal(t, 1, rt.Sweep())
+	require.Equal(t, 2, rt.Size())
+	found, err := rt.Find(context.Background(), node3.Key())
+	require.NoError(t, err)
+	require.Nil(t, found)
+
+	clk.Add(30 * time.Second)
+	require.Equal(t, 2, rt.Sweep())
+	require.Equal(t, 0, rt.Size())
+}
+
+func TestEntryTTLDisabled(t *testing.T) {
+	clk := clock.NewMock()
+	cfg := DefaultConfig[key.Key32, node[key.Key32]]()
+	cfg.Clock = clk
+	rt, err := New(node0, cfg)
+	require.NoError(t, err)
+
+	require.True(t, rt.AddNode(node1))
+	clk.Add(24 * time.Hour)
+	require.Equal(t, 0, rt.Sweep())
+	require.Equal(t, 1, rt.Size())
+}
+
+func TestEntryTTLNegative(t *testing.T) {
+	cfg := DefaultConfig[key.Key32, node[key.Key32]]()
+	cfg.EntryTTL = -1
+	_, err := New(node0, cfg)
+	require.Error(t, err)
+}
@@ -1,13 +1,18 @@
 package triert
 
-import "github.com/plprobelab/go-kademlia/kad"
+import (
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/routing/filter"
+)
 
 // KeyFilterFunc is a function that is applied before a key is added to the table.
 // Return false to prevent the key from being added.
+// Filters shared with other routing tables are provided by the filter package.
 type KeyFilterFunc[K kad.Key[K], N kad.NodeID[K]] func(rt *TrieRT[K, N], kk K) bool
 
 // BucketLimit20 is a filter function that limits the occupancy of buckets in the table to 20 keys.
+//
+// Deprecated: use filter.BucketLimit20 instead.
 func BucketLimit20[K kad.Key[K], N kad.NodeID[K]](rt *TrieRT[K, N], kk K) bool {
-	cpl := rt.Cpl(kk)
-	return rt.CplSize(cpl) < 20
+	return filter.BucketLimit20[K, *TrieRT[K, N]](rt, kk)
 }
@@ -4,8 +4,9 @@ import (
 	"fmt"
 	"testing"
 
-	"github.com/plprobelab/go-kademlia/internal/kadtest"
+	"github.com/plprobelab/go-kademlia/kadtest"
 	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/routing/filter"
 	"github.com/stretchr/testify/require"
 )
 
@@ -45,3 +46,14 @@ func TestBucketLimit20(t *testing.T) {
 	success = rt.AddNode(nodes[20])
 	require.True(t, success)
 }
+
+func TestSharedFilter(t *testing.T) {
+	cfg := DefaultConfig[key.Key32, node[key.Key32]]()
+	cfg.KeyFilter = filter.BucketLimit[key.Key32, *TrieRT[key.Key32, node[key.Key32]]](1)
+	rt, err := New(node0, cfg)
+	require.NoError(t, err)
+
+	require.True(t, rt.AddNode(node2))  // cpl 0
+	require.False(t, rt.AddNode(node3)) // cpl 0
+	require.True(t, rt.AddNode(node1))  // cpl 1
+}
@@ -0,0 +1,70 @@
+package triert
+
+import (
+	"github.com/plprobelab/go-kademlia/key/trie"
+)
+
+// NodeStats holds statistics about the usefulness of a node in the table.
+type NodeStats struct {
+	Answered int // the number of queries the node answered successfully
+	Closer   int // the total number of closer nodes contributed in the node's answers
+	Failures int // the number of queries the node failed to answer
+}
+
+// Score returns a usefulness score for the node, higher values indicating more useful nodes.
+// The score is the average number of closer nodes contributed per query sent to the node,
+// plus one for each successful answer, so a node that never failed and always answered scores
+// at least one and a node that only failed scores zero. Nodes without any recorded queries
+// score zero.
+func (s NodeStats) Score() float64 {
+	total := s.Answered + s.Failures
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Answered+s.Closer) / float64(total)
+}
+
+// RecordResponse records that the node identified by kk successfully answered a query, contributing
+// the given number of closer nodes. It returns false if the node is not present in the table.
+func (rt *TrieRT[K, N]) RecordResponse(kk K, closer int) bool {
+	found, e := trie.Find(rt.keys, kk)
+	if !found {
+		return false
+	}
+	e.stats.Answered++
+	e.stats.Closer += closer
+	return true
+}
+
+// RecordFailure records that the node identified by kk failed to answer a query.
+// It returns false if the node is not present in the table.
+func (rt *TrieRT[K, N]) RecordFailure(kk K) bool {
+	found, e := trie.Find(rt.keys, kk)
+	if !found {
+		return false
+	}
+	e.stats.Failures++
+	return true
+}
+
+// NodeStats returns the usefulness statistics for the node identified by kk.
+// It returns false if the node is not present in the table.
+func (rt *TrieRT[K, N]) NodeStats(kk K) (NodeStats, bool) {
+	found, e := trie.Find(rt.keys, kk)
+	if !found {
+		return NodeStats{}, false
+	}
+	return e.stats, true
+}
+
+// SetNodeStats replaces the usefulness statistics for the node identified by kk, for example with
+// the numbers of requests answered and failed that an endpoint recorded for the node in its
+// endpoint.PeerMetrics. It returns false if the node is not present in the table.
+func (rt *TrieRT[K, N]) SetNodeStats(kk K, s NodeStats) bool {
+	found, e := trie.Find(rt.keys, kk)
+	if !found {
+		return false
+	}
+	e.stats = s
+	return true
+}
@@ -0,0 +1,78 @@
+package triert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+func TestNodeStatsScore(t *testing.T) {
+	require.Equal(t, 0.0, NodeStats{}.Score())
+	require.Equal(t, 0.0, NodeStats{Failures: 3}.Score())
+	require.Equal(t, 1.0, NodeStats{Answered: 2}.Score())
+	require.Equal(t, 3.0, NodeStats{Answered: 2, Closer: 4}.Score())
+	require.Equal(t, 1.5, NodeStats{Answered: 2, Closer: 4, Failures: 2}.Score())
+}
+
+func TestRecordNodeStats(t *testing.T) {
+	rt, err := New[key.Key32](node0, nil)
+	require.NoError(t, err)
+
+	// unknown nodes are not tracked
+	require.False(t, rt.RecordResponse(key1, 3))
+	require.False(t, rt.RecordFailure(key1))
+	_, found := rt.NodeStats(key1)
+	require.False(t, found)
+
+	rt.AddNode(node1)
+	rt.AddNode(node2)
+
+	stats, found := rt.NodeStats(key1)
+	require.True(t, found)
+	require.Equal(t, NodeStats{}, stats)
+
+	require.True(t, rt.RecordResponse(key1, 3))
+	require.True(t, rt.RecordResponse(key1, 1))
+	require.True(t, rt.RecordFailure(key1))
+	require.True(t, rt.RecordFailure(key2))
+
+	stats, found = rt.NodeStats(key1)
+	require.True(t, found)
+	require.Equal(t, NodeStats{Answered: 2, Closer: 4, Failures: 1}, stats)
+
+	stats, found = rt.NodeStats(key2)
+	require.True(t, found)
+	require.Equal(t, NodeStats{Failures: 1}, stats)
+
+	// adding a node that is already present keeps its stats
+	require.False(t, rt.AddNode(node1))
+	stats, _ = rt.NodeStats(key1)
+	require.Equal(t, 2, stats.Answered)
+
+	// stats are discarded when the node is removed
+	require.True(t, rt.RemoveKey(key1))
+	_, found = rt.NodeStats(key1)
+	require.False(t, found)
+	rt.AddNode(node1)
+	stats, _ = rt.NodeStats(key1)
+	require.Equal(t, NodeStats{}, stats)
+}
+
+func TestSetNodeStats(t *testing.T) {
+	rt, err := New[key.Key32](node0, nil)
+	require.NoError(t, err)
+	require.False(t, rt.SetNodeStats(key1, NodeStats{Answered: 1}))
+
+	rt.AddNode(node1)
+	require.True(t, rt.RecordResponse(key1, 3))
+
+	stats, _ := rt.NodeStats(key1)
+	stats.Answered, stats.Failures = 5, 2
+	require.True(t, rt.SetNodeStats(key1, stats))
+
+	stats, found := rt.NodeStats(key1)
+	require.True(t, found)
+	require.Equal(t, NodeStats{Answered: 5, Closer: 3, Failures: 2}, stats)
+}
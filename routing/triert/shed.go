@@ -0,0 +1,85 @@
+package triert
+
+import (
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key/trie"
+)
+
+// ShedFunc is a function that is applied when a key is about to be added to a full table. It returns
+// the key of the node that should be removed to make room for kk, or false if kk should be rejected.
+type ShedFunc[K kad.Key[K], N kad.NodeID[K]] func(rt *TrieRT[K, N], kk K) (K, bool)
+
+// ShedLargestBucket is a shed function that removes the least useful node from the most populated
+// bucket. The new key is rejected if its own bucket is the most populated one.
+func ShedLargestBucket[K kad.Key[K], N kad.NodeID[K]](rt *TrieRT[K, N], kk K) (K, bool) {
+	target := rt.Cpl(kk)
+	largest, size := -1, rt.CplSize(target)
+	for cpl := 0; cpl < rt.self.BitLen(); cpl++ {
+		if cpl == target {
+			continue
+		}
+		if n := rt.CplSize(cpl); n > size {
+			largest, size = cpl, n
+		}
+	}
+	if largest < 0 {
+		var zero K
+		return zero, false
+	}
+	return rt.leastUseful(largest)
+}
+
+// ShedFarthestBucket is a shed function that removes the least useful node from the non-empty bucket
+// furthest from the table's key. The new key is rejected if no bucket is further away than its own.
+func ShedFarthestBucket[K kad.Key[K], N kad.NodeID[K]](rt *TrieRT[K, N], kk K) (K, bool) {
+	target := rt.Cpl(kk)
+	for cpl := 0; cpl < target; cpl++ {
+		if rt.CplSize(cpl) > 0 {
+			return rt.leastUseful(cpl)
+		}
+	}
+	var zero K
+	return zero, false
+}
+
+// leastUseful returns the key of the node with the lowest usefulness score in the bucket for cpl.
+// Ties are broken in favour of removing the node furthest from the table's key.
+func (rt *TrieRT[K, N]) leastUseful(cpl int) (K, bool) {
+	var victim K
+	found := false
+	var lowest float64
+	for _, e := range collectCpl(rt.keys, rt.self, cpl, 0) {
+		score := e.Data.stats.Score()
+		if !found || score <= lowest {
+			victim, lowest, found = e.Key, score, true
+		}
+	}
+	return victim, found
+}
+
+// collectCpl returns the entries of t whose longest common prefix with kk is of length cpl,
+// ordered by increasing distance from kk.
+func collectCpl[K kad.Key[K], D any](t *trie.Trie[K, D], kk K, cpl int, depth int) []trie.Entry[K, D] {
+	// special cases for very small tables where keys may be placed higher in the trie due to low population
+	if t.IsLeaf() {
+		if !t.HasKey() {
+			return nil
+		}
+		if kk.CommonPrefixLength(*t.Key()) == cpl {
+			return []trie.Entry[K, D]{{Key: *t.Key(), Data: t.Data()}}
+		}
+		return nil
+	}
+
+	if depth > kk.BitLen() {
+		return nil
+	}
+
+	if depth == cpl {
+		// return the entries that do not share the next bit with kk
+		branch := t.Branch(1 - int(kk.Bit(depth)))
+		return trie.Closest(branch, kk, branch.Size())
+	}
+
+	return collectCpl(t.Branch(int(kk.Bit(depth))), kk, cpl, depth+1)
+}
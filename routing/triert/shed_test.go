@@ -0,0 +1,103 @@
+package triert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+func TestMaxEntriesRejectsWhenFull(t *testing.T) {
+	cfg := DefaultConfig[key.Key32, node[key.Key32]]()
+	cfg.MaxEntries = 2
+	rt, err := New(node0, cfg)
+	require.NoError(t, err)
+
+	require.False(t, rt.Full())
+	require.True(t, rt.AddNode(newNode("a", kadtest.RandomKeyWithPrefix("1"))))
+	require.False(t, rt.Full())
+	require.True(t, rt.AddNode(newNode("b", kadtest.RandomKeyWithPrefix("01"))))
+	require.True(t, rt.Full())
+
+	// without a shed function new nodes are rejected
+	require.False(t, rt.AddNode(newNode("c", kadtest.RandomKeyWithPrefix("001"))))
+	require.Equal(t, 2, rt.Size())
+
+	// removing a node makes room again
+	require.True(t, rt.RemoveKey(rt.NearestNodes(key0, 1)[0].Key()))
+	require.False(t, rt.Full())
+	require.True(t, rt.AddNode(newNode("c", kadtest.RandomKeyWithPrefix("001"))))
+}
+
+func TestMaxEntriesNegative(t *testing.T) {
+	cfg := DefaultConfig[key.Key32, node[key.Key32]]()
+	cfg.MaxEntries = -1
+	_, err := New(node0, cfg)
+	require.Error(t, err)
+}
+
+func TestShedLargestBucket(t *testing.T) {
+	cfg := DefaultConfig[key.Key32, node[key.Key32]]()
+	cfg.MaxEntries = 4
+	cfg.ShedFunc = ShedLargestBucket[key.Key32, node[key.Key32]]
+	rt, err := New(node0, cfg)
+	require.NoError(t, err)
+
+	cpl0a := newNode("cpl0a", kadtest.RandomKeyWithPrefix("1"))
+	cpl0b := newNode("cpl0b", kadtest.RandomKeyWithPrefix("1"))
+	cpl0c := newNode("cpl0c", kadtest.RandomKeyWithPrefix("1"))
+	cpl1a := newNode("cpl1a", kadtest.RandomKeyWithPrefix("01"))
+	require.True(t, rt.AddNode(cpl0a))
+	require.True(t, rt.AddNode(cpl0b))
+	require.True(t, rt.AddNode(cpl0c))
+	require.True(t, rt.AddNode(cpl1a))
+	require.True(t, rt.Full())
+
+	// make all but one cpl 0 node useful
+	rt.RecordResponse(cpl0a.Key(), 1)
+	rt.RecordResponse(cpl0c.Key(), 1)
+
+	// a node for a smaller bucket displaces the least useful node of the largest bucket
+	require.True(t, rt.AddNode(newNode("cpl2a", kadtest.RandomKeyWithPrefix("001"))))
+	require.Equal(t, 4, rt.Size())
+	require.Equal(t, 2, rt.CplSize(0))
+	_, found := rt.NodeStats(cpl0b.Key())
+	require.False(t, found)
+
+	// a node for the largest bucket is rejected
+	require.False(t, rt.AddNode(newNode("cpl0d", kadtest.RandomKeyWithPrefix("1"))))
+	require.Equal(t, 4, rt.Size())
+}
+
+func TestShedFarthestBucket(t *testing.T) {
+	cfg := DefaultConfig[key.Key32, node[key.Key32]]()
+	cfg.MaxEntries = 3
+	cfg.ShedFunc = ShedFarthestBucket[key.Key32, node[key.Key32]]
+	rt, err := New(node0, cfg)
+	require.NoError(t, err)
+
+	cpl0a := newNode("cpl0a", kadtest.RandomKeyWithPrefix("1"))
+	cpl1a := newNode("cpl1a", kadtest.RandomKeyWithPrefix("01"))
+	cpl1b := newNode("cpl1b", kadtest.RandomKeyWithPrefix("01"))
+	require.True(t, rt.AddNode(cpl0a))
+	require.True(t, rt.AddNode(cpl1a))
+	require.True(t, rt.AddNode(cpl1b))
+	require.True(t, rt.Full())
+
+	// a closer node displaces the node in the furthest bucket
+	require.True(t, rt.AddNode(newNode("cpl2a", kadtest.RandomKeyWithPrefix("001"))))
+	require.Equal(t, 0, rt.CplSize(0))
+	require.Equal(t, 2, rt.CplSize(1))
+	require.Equal(t, 1, rt.CplSize(2))
+
+	// a node in the furthest non-empty bucket is rejected
+	require.False(t, rt.AddNode(newNode("cpl0b", kadtest.RandomKeyWithPrefix("1"))))
+	require.False(t, rt.AddNode(newNode("cpl1c", kadtest.RandomKeyWithPrefix("01"))))
+
+	// a closer node displaces one from the next furthest bucket
+	require.True(t, rt.AddNode(newNode("cpl3a", kadtest.RandomKeyWithPrefix("0001"))))
+	require.Equal(t, 1, rt.CplSize(1))
+	require.Equal(t, 3, rt.Size())
+}
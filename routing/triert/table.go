@@ -3,20 +3,38 @@ package triert
 import (
 	"context"
 	"fmt"
+	"time"
+
+	"github.com/benbjohnson/clock"
 
-	"github.com/plprobelab/go-kademlia/internal/kadtest"
 	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/kadtest"
 	"github.com/plprobelab/go-kademlia/key"
 	"github.com/plprobelab/go-kademlia/key/trie"
+	"github.com/plprobelab/go-kademlia/routing/denylist"
+	"github.com/plprobelab/go-kademlia/routing/rtstats"
 )
 
 // TrieRT is a routing table backed by a XOR Trie which offers good scalablity and performance
 // for large networks.
 type TrieRT[K kad.Key[K], N kad.NodeID[K]] struct {
-	self      K
-	keyFilter KeyFilterFunc[K, N]
+	self       K
+	keyFilter  KeyFilterFunc[K, N]
+	maxEntries int
+	shed       ShedFunc[K, N]
+	entryTTL   time.Duration
+	clk        clock.Clock
+	denyList   *denylist.PeerDenyList
+
+	keys *trie.Trie[K, *entry[K, N]]
+}
 
-	keys *trie.Trie[K, N]
+// entry is the data stored in the trie for each node in the table.
+type entry[K kad.Key[K], N kad.NodeID[K]] struct {
+	node     N
+	stats    NodeStats
+	lastSeen time.Time // the time the node was last added or marked alive
 }
 
 var _ kad.RoutingTable[key.Key256, kadtest.ID[key.Key256]] = (*TrieRT[key.Key256, kadtest.ID[key.Key256]])(nil)
@@ -26,7 +44,7 @@ var _ kad.RoutingTable[key.Key256, kadtest.ID[key.Key256]] = (*TrieRT[key.Key256
 func New[K kad.Key[K], N kad.NodeID[K]](self N, cfg *Config[K, N]) (*TrieRT[K, N], error) {
 	rt := &TrieRT[K, N]{
 		self: self.Key(),
-		keys: &trie.Trie[K, N]{},
+		keys: &trie.Trie[K, *entry[K, N]]{},
 	}
 	if err := rt.apply(cfg); err != nil {
 		return nil, fmt.Errorf("apply config: %w", err)
@@ -39,7 +57,29 @@ func (rt *TrieRT[K, N]) apply(cfg *Config[K, N]) error {
 		cfg = DefaultConfig[K, N]()
 	}
 
+	if cfg.MaxEntries < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "TrieRTConfig",
+			Err:       fmt.Errorf("max entries must not be negative"),
+		}
+	}
+
+	if cfg.EntryTTL < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "TrieRTConfig",
+			Err:       fmt.Errorf("entry ttl must not be negative"),
+		}
+	}
+
 	rt.keyFilter = cfg.KeyFilter
+	rt.maxEntries = cfg.MaxEntries
+	rt.shed = cfg.ShedFunc
+	rt.entryTTL = cfg.EntryTTL
+	rt.denyList = cfg.DenyList
+	rt.clk = cfg.Clock
+	if rt.clk == nil {
+		rt.clk = clock.New()
+	}
 
 	return nil
 }
@@ -49,14 +89,72 @@ func (rt *TrieRT[K, N]) Self() K {
 	return rt.self
 }
 
-// AddNode tries to add a node to the routing table.
+// AddNode tries to add a node to the routing table. Adding a node that is already present
+// refreshes its expiry and returns false.
 func (rt *TrieRT[K, N]) AddNode(node N) bool {
 	kk := node.Key()
+	if found, e := trie.Find(rt.keys, kk); found {
+		e.lastSeen = rt.clk.Now()
+		return false
+	}
+
+	if rt.denyList != nil && rt.denyList.Banned(node) {
+		return false
+	}
+
 	if rt.keyFilter != nil && !rt.keyFilter(rt, kk) {
 		return false
 	}
 
-	return rt.keys.Add(kk, node)
+	if rt.Full() {
+		if rt.shed == nil {
+			return false
+		}
+		victim, ok := rt.shed(rt, kk)
+		if !ok || !rt.keys.Remove(victim) {
+			return false
+		}
+	}
+
+	return rt.keys.Add(kk, &entry[K, N]{node: node, lastSeen: rt.clk.Now()})
+}
+
+// MarkAlive refreshes the expiry of the node identified by kk. It returns false if the node
+// is not present in the table.
+func (rt *TrieRT[K, N]) MarkAlive(kk K) bool {
+	found, e := trie.Find(rt.keys, kk)
+	if !found {
+		return false
+	}
+	e.lastSeen = rt.clk.Now()
+	return true
+}
+
+// Sweep removes all nodes that have not been refreshed within the configured EntryTTL and returns
+// the number of nodes removed. It does nothing if EntryTTL is zero.
+func (rt *TrieRT[K, N]) Sweep() int {
+	if rt.entryTTL == 0 {
+		return 0
+	}
+
+	now := rt.clk.Now()
+	expired := make([]K, 0)
+	for _, e := range trie.Closest(rt.keys, rt.self, rt.keys.Size()) {
+		if now.Sub(e.Data.lastSeen) >= rt.entryTTL {
+			expired = append(expired, e.Key)
+		}
+	}
+
+	for _, kk := range expired {
+		rt.keys.Remove(kk)
+	}
+	return len(expired)
+}
+
+// Full reports whether the table holds the maximum number of nodes allowed by its configuration.
+// Callers may use it as a signal to stop proactively discovering new nodes.
+func (rt *TrieRT[K, N]) Full() bool {
+	return rt.maxEntries > 0 && rt.keys.Size() >= rt.maxEntries
 }
 
 // RemoveKey tries to remove a node identified by its Kademlia key from the
@@ -67,23 +165,59 @@ func (rt *TrieRT[K, N]) RemoveKey(kk K) bool {
 
 // NearestNodes returns the n closest nodes to a given key.
 func (rt *TrieRT[K, N]) NearestNodes(target K, n int) []N {
-	closestEntries := trie.Closest(rt.keys, target, n)
+	return rt.NearestNodesFiltered(target, n, nil)
+}
+
+// NearestNodesFiltered returns the n closest nodes to a given key for which pred returns true.
+// A nil pred accepts all nodes.
+func (rt *TrieRT[K, N]) NearestNodesFiltered(target K, n int, pred func(N) bool) []N {
+	var keep func(K, *entry[K, N]) bool
+	if pred != nil {
+		keep = func(_ K, e *entry[K, N]) bool { return pred(e.node) }
+	}
+
+	closestEntries := trie.ClosestFiltered(rt.keys, target, n, keep)
 	if len(closestEntries) == 0 {
 		return []N{}
 	}
 
 	nodes := make([]N, 0, len(closestEntries))
 	for _, c := range closestEntries {
-		nodes = append(nodes, c.Data)
+		nodes = append(nodes, c.Data.node)
 	}
 
 	return nodes
 }
 
+// NearestIterator lazily yields the nodes of a TrieRT in order of increasing distance from a target key.
+// It must not be used after the table has been modified.
+type NearestIterator[K kad.Key[K], N kad.NodeID[K]] struct {
+	it *trie.ClosestIterator[K, *entry[K, N]]
+}
+
+// NearestIter returns an iterator over the nodes of the table ordered by increasing distance from target.
+// Callers that want the next closest node until some condition is met, such as crawlers, can use it
+// instead of repeatedly calling NearestNodes with a growing count.
+func (rt *TrieRT[K, N]) NearestIter(target K) *NearestIterator[K, N] {
+	return &NearestIterator[K, N]{
+		it: trie.NewClosestIterator(rt.keys, target),
+	}
+}
+
+// Next returns the next closest node and true, or false if all nodes have been visited.
+func (it *NearestIterator[K, N]) Next() (N, bool) {
+	e, ok := it.it.Next()
+	if !ok {
+		var zero N
+		return zero, false
+	}
+	return e.Data.node, true
+}
+
 func (rt *TrieRT[K, N]) Find(ctx context.Context, kk K) (kad.NodeID[K], error) {
-	found, node := trie.Find(rt.keys, kk)
+	found, e := trie.Find(rt.keys, kk)
 	if found {
-		return node, nil
+		return e.node, nil
 	}
 
 	return nil, nil
@@ -94,6 +228,11 @@ func (rt *TrieRT[K, N]) Size() int {
 	return rt.keys.Size()
 }
 
+// AllNodes returns all the nodes contained in the table, ordered by increasing distance from the table's key.
+func (rt *TrieRT[K, N]) AllNodes() []N {
+	return rt.NearestNodes(rt.self, rt.keys.Size())
+}
+
 // Cpl returns the longest common prefix length the supplied key shares with the table's key.
 func (rt *TrieRT[K, N]) Cpl(kk K) int {
 	return rt.self.CommonPrefixLength(kk)
@@ -108,7 +247,7 @@ func (rt *TrieRT[K, N]) CplSize(cpl int) int {
 	return n
 }
 
-func countCpl[K kad.Key[K], N kad.NodeID[K]](t *trie.Trie[K, N], kk K, cpl int, depth int) (int, error) {
+func countCpl[K kad.Key[K], D any](t *trie.Trie[K, D], kk K, cpl int, depth int) (int, error) {
 	// special cases for very small tables where keys may be placed higher in the trie due to low population
 	if t.IsLeaf() {
 		if !t.HasKey() {
@@ -132,3 +271,22 @@ func countCpl[K kad.Key[K], N kad.NodeID[K]](t *trie.Trie[K, N], kk K, cpl int,
 
 	return countCpl(t.Branch(int(kk.Bit(depth))), kk, cpl, depth+1)
 }
+
+// Stats returns a snapshot of the table's statistics. Buckets are indexed by the common prefix
+// length of their nodes with the table's key, up to the longest common prefix in the table.
+func (rt *TrieRT[K, N]) Stats() rtstats.Stats {
+	s := rtstats.Stats{
+		Size:    rt.keys.Size(),
+		Buckets: []int{},
+	}
+	for _, e := range trie.Closest(rt.keys, rt.self, rt.keys.Size()) {
+		cpl := rt.self.CommonPrefixLength(e.Key)
+		for len(s.Buckets) <= cpl {
+			s.Buckets = append(s.Buckets, 0)
+		}
+		s.Buckets[cpl]++
+		s.Refreshed(e.Data.lastSeen)
+	}
+	s.AverageFill = rtstats.AverageFill(s.Buckets)
+	return s
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"math/rand"
 	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
 
-	"github.com/plprobelab/go-kademlia/internal/kadtest"
 	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
 	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/routing/denylist"
 	"github.com/stretchr/testify/require"
 )
 
@@ -178,6 +182,52 @@ func TestNearestPeers(t *testing.T) {
 	require.Equal(t, 2, len(peers))
 }
 
+func TestNearestPeersFiltered(t *testing.T) {
+	rt, err := New[key.Key32](node0, nil)
+	require.NoError(t, err)
+	rt.AddNode(node1)
+	rt.AddNode(node2)
+	rt.AddNode(node3)
+	rt.AddNode(node4)
+	rt.AddNode(node5)
+	rt.AddNode(node6)
+	rt.AddNode(node7)
+	rt.AddNode(node8)
+	rt.AddNode(node9)
+	rt.AddNode(node10)
+	rt.AddNode(node11)
+
+	// a nil predicate behaves like NearestNodes
+	require.Equal(t, rt.NearestNodes(key0, 5), rt.NearestNodesFiltered(key0, 5, nil))
+
+	// exclude the closest peer to key0, the next closest peer takes its place
+	peers := rt.NearestNodesFiltered(key0, 5, func(n node[key.Key32]) bool {
+		return n.id != node9.id
+	})
+	require.Equal(t, 5, len(peers))
+
+	expectedOrder := rt.NearestNodes(key0, 6)[1:]
+	require.Equal(t, expectedOrder, peers)
+}
+
+func TestAllNodes(t *testing.T) {
+	rt, err := New[key.Key32](node0, nil)
+	require.NoError(t, err)
+	require.Empty(t, rt.AllNodes())
+
+	rt.AddNode(node1)
+	rt.AddNode(node2)
+	rt.AddNode(node3)
+	rt.AddNode(node4)
+
+	all := rt.AllNodes()
+	require.Equal(t, rt.Size(), len(all))
+	require.ElementsMatch(t, []node[key.Key32]{node1, node2, node3, node4}, all)
+
+	// nodes are ordered by distance from the table's key
+	require.Equal(t, rt.NearestNodes(key0, 4), all)
+}
+
 func TestCplSize(t *testing.T) {
 	t.Run("empty", func(t *testing.T) {
 		rt, err := New[key.Key32](node0, nil)
@@ -315,6 +365,98 @@ func TestKeyFilter(t *testing.T) {
 	require.Equal(t, want, got)
 }
 
+func TestConfigFrom(t *testing.T) {
+	kcfg := kad.DefaultConfig()
+	kcfg.BucketSize = 1
+	rt, err := New[key.Key32](node0, ConfigFrom[key.Key32, node[key.Key32]](kcfg))
+	require.NoError(t, err)
+
+	// key1 and key5 share a common prefix of length 1 with key0, only one fits in the bucket
+	require.True(t, rt.AddNode(node1))
+	require.False(t, rt.AddNode(node5))
+	require.True(t, rt.AddNode(node2))
+	require.Equal(t, 2, rt.Size())
+}
+
+func TestStats(t *testing.T) {
+	clk := clock.NewMock()
+	cfg := DefaultConfig[key.Key32, node[key.Key32]]()
+	cfg.Clock = clk
+	rt, err := New[key.Key32](node0, cfg)
+	require.NoError(t, err)
+
+	s := rt.Stats()
+	require.Equal(t, 0, s.Size)
+	require.Empty(t, s.Buckets)
+	require.Zero(t, s.AverageFill)
+	require.True(t, s.LastRefresh.IsZero())
+
+	start := clk.Now()
+	rt.AddNode(node1) // cpl 1
+	rt.AddNode(node2) // cpl 0
+	clk.Add(time.Minute)
+	rt.AddNode(node3) // cpl 0
+	rt.AddNode(node7) // cpl 3
+
+	s = rt.Stats()
+	require.Equal(t, 4, s.Size)
+	require.Equal(t, []int{2, 1, 0, 1}, s.Buckets)
+	require.Equal(t, 0, s.BucketCapacity)
+	require.Equal(t, 1.0, s.AverageFill)
+	require.Equal(t, start, s.OldestRefresh)
+	require.Equal(t, clk.Now(), s.LastRefresh)
+}
+
+func TestDenyList(t *testing.T) {
+	d, err := denylist.New(nil)
+	require.NoError(t, err)
+
+	cfg := DefaultConfig[key.Key32, node[key.Key32]]()
+	cfg.DenyList = d
+	rt, err := New[key.Key32](node0, cfg)
+	require.NoError(t, err)
+
+	d.Ban(node1, 0)
+	require.False(t, rt.AddNode(node1))
+	require.True(t, rt.AddNode(node2))
+
+	d.Unban(node1)
+	require.True(t, rt.AddNode(node1))
+	require.Equal(t, 2, rt.Size())
+}
+
+func TestNearestIter(t *testing.T) {
+	rt, err := New[key.Key32](node0, nil)
+	require.NoError(t, err)
+
+	_, ok := rt.NearestIter(key0).Next()
+	require.False(t, ok)
+
+	for _, n := range []node[key.Key32]{node1, node2, node3, node4, node5, node6, node7, node8} {
+		rt.AddNode(n)
+	}
+
+	for _, target := range []key.Key32{key0, key3, key7} {
+		it := rt.NearestIter(target)
+		var found []node[key.Key32]
+		for n, ok := it.Next(); ok; n, ok = it.Next() {
+			found = append(found, n)
+		}
+		require.Equal(t, rt.NearestNodes(target, rt.Size()), found)
+	}
+
+	// stop at the first node matching a predicate
+	it := rt.NearestIter(key0)
+	var n node[key.Key32]
+	for n, ok = it.Next(); ok; n, ok = it.Next() {
+		if rt.Cpl(n.Key()) == 0 {
+			break
+		}
+	}
+	require.True(t, ok)
+	require.Equal(t, rt.NearestNodesFiltered(key0, 1, func(n node[key.Key32]) bool { return rt.Cpl(n.Key()) == 0 }), []node[key.Key32]{n})
+}
+
 func BenchmarkBuildTable(b *testing.B) {
 	b.Run("1000", benchmarkBuildTable(1000))
 	b.Run("10000", benchmarkBuildTable(10000))
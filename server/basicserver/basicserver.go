@@ -1,7 +1,9 @@
 package basicserver
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -12,7 +14,10 @@ import (
 	"github.com/plprobelab/go-kademlia/kad"
 	"github.com/plprobelab/go-kademlia/key"
 	"github.com/plprobelab/go-kademlia/libp2p"
+	"github.com/plprobelab/go-kademlia/network/address"
 	"github.com/plprobelab/go-kademlia/network/endpoint"
+	"github.com/plprobelab/go-kademlia/server"
+	"github.com/plprobelab/go-kademlia/server/token"
 	"github.com/plprobelab/go-kademlia/sim"
 	"github.com/plprobelab/go-kademlia/util"
 )
@@ -23,9 +28,14 @@ type BasicServer[A kad.Address[A]] struct {
 
 	peerstoreTTL              time.Duration
 	numberOfCloserPeersToSend int
+	values                    server.ValueStore
+	providers                 server.ProviderStore[key.Key256, multiaddr.Multiaddr]
+	tokens                    *token.Manager[key.Key256]
+	routableProto             address.ProtocolID
+	self                      server.SelfAddrs[key.Key256, multiaddr.Multiaddr]
 }
 
-// var _ server.Server = (*BasicServer)(nil)
+var _ server.Server[key.Key256] = (*BasicServer[multiaddr.Multiaddr])(nil)
 
 func NewBasicServer[A kad.Address[A]](rt kad.RoutingTable[key.Key256, kad.NodeID[key.Key256]], endpoint endpoint.Endpoint[key.Key256, A],
 	options ...Option,
@@ -40,6 +50,11 @@ func NewBasicServer[A kad.Address[A]](rt kad.RoutingTable[key.Key256, kad.NodeID
 		endpoint:                  endpoint,
 		peerstoreTTL:              cfg.PeerstoreTTL,
 		numberOfCloserPeersToSend: cfg.NumberUsefulCloserPeers,
+		values:                    cfg.ValueStore,
+		providers:                 cfg.ProviderStore,
+		tokens:                    cfg.TokenManager,
+		routableProto:             cfg.RoutableProtocol,
+		self:                      cfg.SelfAddrs,
 	}
 }
 
@@ -53,6 +68,16 @@ func (s *BasicServer[A]) HandleRequest(ctx context.Context, rpeer kad.NodeID[key
 		switch msg.GetType() {
 		case libp2p.Message_FIND_NODE:
 			return s.HandleFindNodeRequest(ctx, rpeer, msg)
+		case libp2p.Message_PING:
+			return s.HandlePingRequest(ctx, rpeer, msg)
+		case libp2p.Message_GET_VALUE:
+			return s.HandleGetValueRequest(ctx, rpeer, msg)
+		case libp2p.Message_PUT_VALUE:
+			return s.HandlePutValueRequest(ctx, rpeer, msg)
+		case libp2p.Message_ADD_PROVIDER:
+			return s.HandleAddProviderRequest(ctx, rpeer, msg)
+		case libp2p.Message_GET_PROVIDERS:
+			return s.HandleGetProvidersRequest(ctx, rpeer, msg)
 		default:
 			return nil, ErrIpfsV1InvalidRequest
 		}
@@ -84,10 +109,10 @@ func (s *BasicServer[A]) HandleFindNodeRequest(ctx context.Context,
 
 	_, span := util.StartSpan(ctx, "SimServer.HandleFindNodeRequest", trace.WithAttributes(
 		attribute.Stringer("Requester", rpeer),
-		attribute.String("Target", key.HexString(target))))
+		attribute.String("Target", key.FormatHex(target))))
 	defer span.End()
 
-	peers := s.rt.NearestNodes(target, s.numberOfCloserPeersToSend)
+	peers := s.closerPeers(rpeer, target)
 
 	span.AddEvent("Nearest peers", trace.WithAttributes(
 		attribute.Int("count", len(peers)),
@@ -114,8 +139,231 @@ func (s *BasicServer[A]) HandleFindNodeRequest(ctx context.Context,
 			span.RecordError(ErrNotNetworkedEndpoint)
 			return nil, ErrNotNetworkedEndpoint
 		}
-		resp = libp2p.FindPeerResponse(peers, nEndpoint)
+		m := libp2p.FindPeerResponse(peers, nEndpoint)
+		if self := s.selfInfo(); self != nil && key.Equal(self.PeerID().Key(), target) {
+			// the requester looks up the local node, which tells where it can be reached
+			m.CloserPeers = append([]*libp2p.Message_Peer{libp2p.AddrInfoToPbPeer(self)}, m.CloserPeers...)
+		}
+		resp = m
+	}
+
+	return resp, nil
+}
+
+// closerPeers returns the nodes of the routing table closest to target, excluding the requester
+// and the nodes known not to serve the routable protocol, if any.
+func (s *BasicServer[A]) closerPeers(rpeer kad.NodeID[key.Key256], target key.Key256) []kad.NodeID[key.Key256] {
+	return s.rt.NearestNodesFiltered(target, s.numberOfCloserPeersToSend, func(n kad.NodeID[key.Key256]) bool {
+		// never include the requester in the closer peers it is sent
+		if key.Equal(n.Key(), rpeer.Key()) {
+			return false
+		}
+		return s.routableProto == "" || endpoint.Routable(s.endpoint, n, s.routableProto)
+	})
+}
+
+// HandlePingRequest answers a PING request.
+func (s *BasicServer[A]) HandlePingRequest(ctx context.Context,
+	rpeer kad.NodeID[key.Key256], msg *libp2p.Message,
+) (kad.Message, error) {
+	_, span := util.StartSpan(ctx, "BasicServer.HandlePingRequest", trace.WithAttributes(
+		attribute.Stringer("Requester", rpeer)))
+	defer span.End()
+
+	return libp2p.PingResponse(), nil
+}
+
+// HandleGetValueRequest answers a GET_VALUE request with the value stored under the requested
+// key, if any, and the nodes closer to the key.
+func (s *BasicServer[A]) HandleGetValueRequest(ctx context.Context,
+	rpeer kad.NodeID[key.Key256], msg *libp2p.Message,
+) (kad.Message, error) {
+	ctx, span := util.StartSpan(ctx, "BasicServer.HandleGetValueRequest", trace.WithAttributes(
+		attribute.Stringer("Requester", rpeer)))
+	defer span.End()
+
+	if s.values == nil {
+		span.RecordError(ErrNoValueStore)
+		return nil, ErrNoValueStore
+	}
+	nEndpoint, ok := s.endpoint.(endpoint.NetworkedEndpoint[key.Key256, multiaddr.Multiaddr])
+	if !ok {
+		span.RecordError(ErrNotNetworkedEndpoint)
+		return nil, ErrNotNetworkedEndpoint
+	}
+
+	var rec *libp2p.Record
+	value, err := s.values.Get(ctx, msg.GetKey())
+	switch {
+	case err == nil:
+		rec = &libp2p.Record{Key: msg.GetKey(), Value: value}
+	case errors.Is(err, server.ErrNotFound):
+		// the requester may find the value at one of the closer peers
+	default:
+		span.RecordError(err)
+		return nil, err
+	}
+
+	peers := s.closerPeers(rpeer, msg.Target())
+	resp := libp2p.GetValueResponse(msg.GetKey(), rec, peers, nEndpoint)
+	if err := s.issueToken(rpeer, resp); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// HandlePutValueRequest stores the record of a PUT_VALUE request. The key of the record must be
+// the key of the request, and the request must carry a token of the requester if the server
+// requires them.
+func (s *BasicServer[A]) HandlePutValueRequest(ctx context.Context,
+	rpeer kad.NodeID[key.Key256], msg *libp2p.Message,
+) (kad.Message, error) {
+	ctx, span := util.StartSpan(ctx, "BasicServer.HandlePutValueRequest", trace.WithAttributes(
+		attribute.Stringer("Requester", rpeer)))
+	defer span.End()
+
+	if s.values == nil {
+		span.RecordError(ErrNoValueStore)
+		return nil, ErrNoValueStore
+	}
+
+	rec := msg.GetRecord()
+	if rec == nil || !bytes.Equal(rec.GetKey(), msg.GetKey()) {
+		span.RecordError(ErrIpfsV1InvalidRecord)
+		return nil, ErrIpfsV1InvalidRecord
+	}
+	if err := s.checkToken(rpeer, msg); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	if err := s.values.Put(ctx, rec.GetKey(), rec.GetValue()); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return libp2p.PutValueResponse(msg), nil
+}
+
+// HandleAddProviderRequest stores the providers announced by an ADD_PROVIDER request. As in the
+// public IPFS DHT, only the requester may announce itself as a provider, other providers and
+// providers without addresses are ignored. ADD_PROVIDER requests have no response, and must carry
+// a token of the requester if the server requires them.
+func (s *BasicServer[A]) HandleAddProviderRequest(ctx context.Context,
+	rpeer kad.NodeID[key.Key256], msg *libp2p.Message,
+) (kad.Message, error) {
+	ctx, span := util.StartSpan(ctx, "BasicServer.HandleAddProviderRequest", trace.WithAttributes(
+		attribute.Stringer("Requester", rpeer)))
+	defer span.End()
+
+	if s.providers == nil {
+		span.RecordError(ErrNoProviderStore)
+		return nil, ErrNoProviderStore
+	}
+	if len(msg.GetKey()) == 0 {
+		span.RecordError(ErrIpfsV1InvalidKey)
+		return nil, ErrIpfsV1InvalidKey
+	}
+	if err := s.checkToken(rpeer, msg); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	for _, prov := range msg.ProviderNodes() {
+		if !key.Equal(prov.ID().Key(), rpeer.Key()) || len(prov.Addresses()) == 0 {
+			continue
+		}
+		if err := s.providers.AddProvider(ctx, msg.GetKey(), prov); err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// HandleGetProvidersRequest answers a GET_PROVIDERS request with the known providers of the
+// requested key and the nodes closer to the key.
+func (s *BasicServer[A]) HandleGetProvidersRequest(ctx context.Context,
+	rpeer kad.NodeID[key.Key256], msg *libp2p.Message,
+) (kad.Message, error) {
+	ctx, span := util.StartSpan(ctx, "BasicServer.HandleGetProvidersRequest", trace.WithAttributes(
+		attribute.Stringer("Requester", rpeer)))
+	defer span.End()
+
+	if s.providers == nil {
+		span.RecordError(ErrNoProviderStore)
+		return nil, ErrNoProviderStore
+	}
+	if len(msg.GetKey()) == 0 {
+		span.RecordError(ErrIpfsV1InvalidKey)
+		return nil, ErrIpfsV1InvalidKey
+	}
+	nEndpoint, ok := s.endpoint.(endpoint.NetworkedEndpoint[key.Key256, multiaddr.Multiaddr])
+	if !ok {
+		span.RecordError(ErrNotNetworkedEndpoint)
+		return nil, ErrNotNetworkedEndpoint
+	}
+
+	provs, err := s.providers.GetProviders(ctx, msg.GetKey())
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	self := s.selfInfo()
+	infos := make([]*libp2p.AddrInfo, 0, len(provs))
+	for _, prov := range provs {
+		ai, ok := prov.(*libp2p.AddrInfo)
+		if !ok {
+			// only libp2p peers can be sent in a GET_PROVIDERS response
+			continue
+		}
+		if self != nil && ai.AddrInfo.ID == self.AddrInfo.ID {
+			// the stored addresses of the local node may be outdated
+			ai = self
+		}
+		infos = append(infos, ai)
 	}
 
+	peers := s.closerPeers(rpeer, msg.Target())
+	resp := libp2p.GetProvidersResponse(msg.GetKey(), infos, peers, nEndpoint)
+	if err := s.issueToken(rpeer, resp); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
 	return resp, nil
 }
+
+// selfInfo returns the local node with the addresses it advertises, or nil if the server has no
+// source of self addresses or the local node has no address.
+func (s *BasicServer[A]) selfInfo() *libp2p.AddrInfo {
+	if s.self == nil {
+		return nil
+	}
+	ni := s.self.NodeInfo()
+	pid, ok := ni.ID().(*libp2p.PeerID)
+	if !ok || len(ni.Addresses()) == 0 {
+		return nil
+	}
+	return libp2p.NewAddrInfo(peer.AddrInfo{ID: pid.ID, Addrs: ni.Addresses()})
+}
+
+// issueToken sets in resp the write token of rpeer, if the server requires tokens.
+func (s *BasicServer[A]) issueToken(rpeer kad.NodeID[key.Key256], resp *libp2p.Message) error {
+	if s.tokens == nil {
+		return nil
+	}
+	tok, err := s.tokens.Issue(rpeer)
+	if err != nil {
+		return err
+	}
+	resp.SetToken(tok)
+	return nil
+}
+
+// checkToken returns token.ErrInvalidToken if the server requires tokens and msg does not carry
+// a valid token for rpeer.
+func (s *BasicServer[A]) checkToken(rpeer kad.NodeID[key.Key256], msg *libp2p.Message) error {
+	if s.tokens == nil {
+		return nil
+	}
+	return s.tokens.Check(rpeer, msg.GetToken())
+}
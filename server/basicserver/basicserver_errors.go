@@ -8,4 +8,8 @@ var (
 	ErrIpfsV1InvalidPeerID  = errors.New("IpfsV1 Message contains invalid peer.ID")
 	ErrIpfsV1InvalidRequest = errors.New("IpfsV1 Message unknown request type")
 	ErrSimMessageNilTarget  = errors.New("SimMessage target is nil")
+	ErrIpfsV1InvalidRecord  = errors.New("IpfsV1 Message record is missing or does not match the key")
+	ErrNoValueStore         = errors.New("no value store configured")
+	ErrNoProviderStore      = errors.New("no provider store configured")
+	ErrIpfsV1InvalidKey     = errors.New("IpfsV1 Message key is empty")
 )
@@ -3,6 +3,14 @@ package basicserver
 import (
 	"fmt"
 	"time"
+
+	"github.com/multiformats/go-multiaddr"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/server"
+	"github.com/plprobelab/go-kademlia/server/token"
 )
 
 // Config is a structure containing all the options that can be used when
@@ -10,6 +18,11 @@ import (
 type Config struct {
 	PeerstoreTTL            time.Duration
 	NumberUsefulCloserPeers int
+	ValueStore              server.ValueStore
+	ProviderStore           server.ProviderStore[key.Key256, multiaddr.Multiaddr]
+	TokenManager            *token.Manager[key.Key256]
+	RoutableProtocol        address.ProtocolID
+	SelfAddrs               server.SelfAddrs[key.Key256, multiaddr.Multiaddr]
 }
 
 // Apply applies the BasicServer options to this Option
@@ -27,11 +40,19 @@ type Option func(*Config) error
 
 // DefaultConfig is the default options for BasicServer. This option is always
 // prepended to the list of options passed to the BasicServer constructor.
-var DefaultConfig = func(cfg *Config) error {
-	cfg.PeerstoreTTL = 30 * time.Minute
-	cfg.NumberUsefulCloserPeers = 20
+var DefaultConfig = WithKadConfig(kad.DefaultConfig())
 
-	return nil
+// WithKadConfig sets the peerstore TTL and the number of closer peers sent in responses to the
+// PeerstoreTTL and BucketSize of kcfg.
+func WithKadConfig(kcfg *kad.Config) Option {
+	return func(cfg *Config) error {
+		if err := kcfg.Validate(); err != nil {
+			return err
+		}
+		cfg.PeerstoreTTL = kcfg.PeerstoreTTL
+		cfg.NumberUsefulCloserPeers = kcfg.BucketSize
+		return nil
+	}
 }
 
 func WithPeerstoreTTL(ttl time.Duration) Option {
@@ -47,3 +68,53 @@ func WithNumberUsefulCloserPeers(n int) Option {
 		return nil
 	}
 }
+
+// WithValueStore sets the store backing the GET_VALUE and PUT_VALUE handlers. Without a store,
+// value requests are rejected.
+func WithValueStore(store server.ValueStore) Option {
+	return func(cfg *Config) error {
+		cfg.ValueStore = store
+		return nil
+	}
+}
+
+// WithProviderStore sets the store backing the ADD_PROVIDER and GET_PROVIDERS handlers. Without a
+// store, provider requests are rejected.
+func WithProviderStore(store server.ProviderStore[key.Key256, multiaddr.Multiaddr]) Option {
+	return func(cfg *Config) error {
+		cfg.ProviderStore = store
+		return nil
+	}
+}
+
+// WithTokenManager requires write tokens issued by m. The GET_VALUE and GET_PROVIDERS handlers
+// issue a token to the requester in their response, and the PUT_VALUE and ADD_PROVIDER handlers
+// reject requests without a valid token for the requester. Without a manager, writes are accepted
+// without token.
+func WithTokenManager(m *token.Manager[key.Key256]) Option {
+	return func(cfg *Config) error {
+		cfg.TokenManager = m
+		return nil
+	}
+}
+
+// WithRoutableProtocol leaves the nodes that do not serve protoID, such as nodes in client mode,
+// out of the closer peers sent to requesters, as far as the endpoint knows the protocols of remote
+// nodes. Without a protocol, all the nodes of the routing table may be sent.
+func WithRoutableProtocol(protoID address.ProtocolID) Option {
+	return func(cfg *Config) error {
+		cfg.RoutableProtocol = protoID
+		return nil
+	}
+}
+
+// WithSelfAddrs sets the source of the addresses the server advertises for the local node. A
+// FIND_NODE request for the local node is answered with the local node along with the closer
+// peers, and the local node is sent with its current addresses when it is among the providers of
+// a GET_PROVIDERS response. Without a source, the local node is never sent.
+func WithSelfAddrs(s server.SelfAddrs[key.Key256, multiaddr.Multiaddr]) Option {
+	return func(cfg *Config) error {
+		cfg.SelfAddrs = s
+		return nil
+	}
+}
@@ -12,14 +12,18 @@ import (
 	"github.com/plprobelab/go-kademlia/libp2p"
 	"github.com/plprobelab/go-kademlia/network/address"
 	"github.com/plprobelab/go-kademlia/network/endpoint"
+	"github.com/plprobelab/go-kademlia/network/selfaddr"
 
 	"github.com/benbjohnson/clock"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/plprobelab/go-kademlia/event"
-	"github.com/plprobelab/go-kademlia/internal/kadtest"
 	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
 	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/records"
 	"github.com/plprobelab/go-kademlia/routing/simplert"
+	"github.com/plprobelab/go-kademlia/server"
+	"github.com/plprobelab/go-kademlia/server/token"
 	"github.com/plprobelab/go-kademlia/sim"
 	"github.com/stretchr/testify/require"
 )
@@ -394,3 +398,361 @@ func TestIPFSv1Handling(t *testing.T) {
 		require.Equal(t, order[i], p.ID())
 	}
 }
+
+// mapValueStore is a ValueStore backed by a map
+type mapValueStore map[string][]byte
+
+func (m mapValueStore) Get(ctx context.Context, k []byte) ([]byte, error) {
+	v, ok := m[string(k)]
+	if !ok {
+		return nil, server.ErrNotFound
+	}
+	return v, nil
+}
+
+func (m mapValueStore) Put(ctx context.Context, k, v []byte) error {
+	m[string(k)] = v
+	return nil
+}
+
+func TestIPFSv1ValueHandling(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+
+	selfPid, err := peer.Decode("1EooooSELF")
+	require.NoError(t, err)
+	self := libp2p.NewPeerID(selfPid)
+
+	router := sim.NewRouter[key.Key256, multiaddr.Multiaddr]()
+	sched := event.NewSimpleScheduler(clk)
+	fakeEndpoint := sim.NewEndpoint[key.Key256, multiaddr.Multiaddr](self.NodeID(), sched, router)
+	rt := simplert.New[key.Key256, kad.NodeID[key.Key256]](self, 4)
+
+	p, err := peer.Decode("1EoooPEER2")
+	require.NoError(t, err)
+	closer := libp2p.NewAddrInfo(peer.AddrInfo{
+		ID:    p,
+		Addrs: []multiaddr.Multiaddr{multiaddr.StringCast("/ip4/2.2.2.2")},
+	})
+	require.NoError(t, fakeEndpoint.MaybeAddToPeerstore(ctx, closer, time.Second))
+	require.True(t, rt.AddNode(closer.PeerID()))
+
+	requesterPid, err := peer.Decode("1WoooREQUESTER")
+	require.NoError(t, err)
+	requester := libp2p.NewPeerID(requesterPid)
+
+	// value requests are rejected without a value store
+	s0 := NewBasicServer[multiaddr.Multiaddr](rt, fakeEndpoint)
+	_, err = s0.HandleRequest(ctx, requester, libp2p.GetValueRequest([]byte("k")))
+	require.ErrorIs(t, err, ErrNoValueStore)
+
+	store := mapValueStore{}
+	s0 = NewBasicServer[multiaddr.Multiaddr](rt, fakeEndpoint, WithValueStore(store))
+
+	// ping
+	msg, err := s0.HandleRequest(ctx, requester, libp2p.PingRequest())
+	require.NoError(t, err)
+	require.Equal(t, libp2p.Message_PING, msg.(*libp2p.Message).GetType())
+
+	// unknown value, only closer peers are returned
+	msg, err = s0.HandleRequest(ctx, requester, libp2p.GetValueRequest([]byte("k")))
+	require.NoError(t, err)
+	resp := msg.(*libp2p.Message)
+	require.Nil(t, resp.GetRecord())
+	require.Equal(t, []kad.NodeInfo[key.Key256, multiaddr.Multiaddr]{closer}, resp.CloserNodes())
+
+	// put value
+	req := libp2p.PutValueRequest([]byte("k"), []byte("v"))
+	msg, err = s0.HandleRequest(ctx, requester, req)
+	require.NoError(t, err)
+	require.Equal(t, libp2p.PutValueResponse(req), msg)
+	require.Equal(t, []byte("v"), store["k"])
+
+	// the stored value is returned
+	msg, err = s0.HandleRequest(ctx, requester, libp2p.GetValueRequest([]byte("k")))
+	require.NoError(t, err)
+	resp = msg.(*libp2p.Message)
+	require.Equal(t, []byte("k"), resp.GetRecord().GetKey())
+	require.Equal(t, []byte("v"), resp.GetRecord().GetValue())
+
+	// the record key must match the request key
+	req = libp2p.PutValueRequest([]byte("k"), []byte("v"))
+	req.Key = []byte("other")
+	_, err = s0.HandleRequest(ctx, requester, req)
+	require.ErrorIs(t, err, ErrIpfsV1InvalidRecord)
+
+	req.Record = nil
+	_, err = s0.HandleRequest(ctx, requester, req)
+	require.ErrorIs(t, err, ErrIpfsV1InvalidRecord)
+}
+
+func TestIPFSv1ProviderHandling(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+
+	selfPid, err := peer.Decode("1EooooSELF")
+	require.NoError(t, err)
+	self := libp2p.NewPeerID(selfPid)
+
+	router := sim.NewRouter[key.Key256, multiaddr.Multiaddr]()
+	sched := event.NewSimpleScheduler(clk)
+	fakeEndpoint := sim.NewEndpoint[key.Key256, multiaddr.Multiaddr](self.NodeID(), sched, router)
+	rt := simplert.New[key.Key256, kad.NodeID[key.Key256]](self, 4)
+
+	p, err := peer.Decode("1EoooPEER2")
+	require.NoError(t, err)
+	closer := libp2p.NewAddrInfo(peer.AddrInfo{
+		ID:    p,
+		Addrs: []multiaddr.Multiaddr{multiaddr.StringCast("/ip4/2.2.2.2")},
+	})
+	require.NoError(t, fakeEndpoint.MaybeAddToPeerstore(ctx, closer, time.Second))
+	require.True(t, rt.AddNode(closer.PeerID()))
+
+	requesterPid, err := peer.Decode("1WoooREQUESTER")
+	require.NoError(t, err)
+	requester := libp2p.NewPeerID(requesterPid)
+	provider := libp2p.NewAddrInfo(peer.AddrInfo{
+		ID:    requesterPid,
+		Addrs: []multiaddr.Multiaddr{multiaddr.StringCast("/ip4/3.3.3.3")},
+	})
+
+	// provider requests are rejected without a provider store
+	s0 := NewBasicServer[multiaddr.Multiaddr](rt, fakeEndpoint)
+	_, err = s0.HandleRequest(ctx, requester, libp2p.GetProvidersRequest([]byte("k")))
+	require.ErrorIs(t, err, ErrNoProviderStore)
+	_, err = s0.HandleRequest(ctx, requester, libp2p.AddProviderRequest([]byte("k"), provider))
+	require.ErrorIs(t, err, ErrNoProviderStore)
+
+	cfg := records.DefaultProviderConfig()
+	cfg.Clock = clk
+	store, err := records.NewProviderStore[key.Key256, multiaddr.Multiaddr](cfg)
+	require.NoError(t, err)
+	s0 = NewBasicServer[multiaddr.Multiaddr](rt, fakeEndpoint, WithProviderStore(store))
+
+	// no providers, only closer peers are returned
+	msg, err := s0.HandleRequest(ctx, requester, libp2p.GetProvidersRequest([]byte("k")))
+	require.NoError(t, err)
+	resp := msg.(*libp2p.Message)
+	require.Empty(t, resp.ProviderNodes())
+	require.Equal(t, []kad.NodeInfo[key.Key256, multiaddr.Multiaddr]{closer}, resp.CloserNodes())
+
+	// announcements of another peer are ignored
+	msg, err = s0.HandleRequest(ctx, requester, libp2p.AddProviderRequest([]byte("k"), closer))
+	require.NoError(t, err)
+	require.Nil(t, msg)
+
+	// the requester announces itself, there is no response
+	msg, err = s0.HandleRequest(ctx, requester, libp2p.AddProviderRequest([]byte("k"), provider))
+	require.NoError(t, err)
+	require.Nil(t, msg)
+
+	msg, err = s0.HandleRequest(ctx, requester, libp2p.GetProvidersRequest([]byte("k")))
+	require.NoError(t, err)
+	resp = msg.(*libp2p.Message)
+	require.Equal(t, []kad.NodeInfo[key.Key256, multiaddr.Multiaddr]{provider}, resp.ProviderNodes())
+
+	// the key must not be empty
+	_, err = s0.HandleRequest(ctx, requester, libp2p.GetProvidersRequest(nil))
+	require.ErrorIs(t, err, ErrIpfsV1InvalidKey)
+	_, err = s0.HandleRequest(ctx, requester, libp2p.AddProviderRequest(nil, provider))
+	require.ErrorIs(t, err, ErrIpfsV1InvalidKey)
+}
+
+func TestIPFSv1TokenHandling(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+
+	selfPid, err := peer.Decode("1EooooSELF")
+	require.NoError(t, err)
+	self := libp2p.NewPeerID(selfPid)
+
+	router := sim.NewRouter[key.Key256, multiaddr.Multiaddr]()
+	sched := event.NewSimpleScheduler(clk)
+	fakeEndpoint := sim.NewEndpoint[key.Key256, multiaddr.Multiaddr](self.NodeID(), sched, router)
+	rt := simplert.New[key.Key256, kad.NodeID[key.Key256]](self, 4)
+
+	requesterPid, err := peer.Decode("1WoooREQUESTER")
+	require.NoError(t, err)
+	requester := libp2p.NewPeerID(requesterPid)
+	provider := libp2p.NewAddrInfo(peer.AddrInfo{
+		ID:    requesterPid,
+		Addrs: []multiaddr.Multiaddr{multiaddr.StringCast("/ip4/3.3.3.3")},
+	})
+	otherPid, err := peer.Decode("1EoooPEER2")
+	require.NoError(t, err)
+	other := libp2p.NewPeerID(otherPid)
+
+	tcfg := token.DefaultConfig()
+	tcfg.Clock = clk
+	tokens, err := token.NewManager[key.Key256](tcfg)
+	require.NoError(t, err)
+
+	pcfg := records.DefaultProviderConfig()
+	pcfg.Clock = clk
+	providers, err := records.NewProviderStore[key.Key256, multiaddr.Multiaddr](pcfg)
+	require.NoError(t, err)
+	values := mapValueStore{}
+	s0 := NewBasicServer[multiaddr.Multiaddr](rt, fakeEndpoint, WithValueStore(values),
+		WithProviderStore(providers), WithTokenManager(tokens))
+
+	// writes without a token are rejected
+	_, err = s0.HandleRequest(ctx, requester, libp2p.PutValueRequest([]byte("k"), []byte("v")))
+	require.ErrorIs(t, err, token.ErrInvalidToken)
+	_, err = s0.HandleRequest(ctx, requester, libp2p.AddProviderRequest([]byte("k"), provider))
+	require.ErrorIs(t, err, token.ErrInvalidToken)
+	require.Empty(t, values)
+
+	// both GET requests issue a token to the requester
+	msg, err := s0.HandleRequest(ctx, requester, libp2p.GetValueRequest([]byte("k")))
+	require.NoError(t, err)
+	tok := msg.(*libp2p.Message).GetToken()
+	require.NotEmpty(t, tok)
+	msg, err = s0.HandleRequest(ctx, requester, libp2p.GetProvidersRequest([]byte("k")))
+	require.NoError(t, err)
+	require.Equal(t, tok, msg.(*libp2p.Message).GetToken())
+
+	// the token of another peer is rejected
+	req := libp2p.PutValueRequest([]byte("k"), []byte("v"))
+	req.SetToken(tok)
+	_, err = s0.HandleRequest(ctx, other, req)
+	require.ErrorIs(t, err, token.ErrInvalidToken)
+
+	// the requester presents its token
+	msg, err = s0.HandleRequest(ctx, requester, req)
+	require.NoError(t, err)
+	require.Equal(t, []byte("v"), values["k"])
+	require.Nil(t, msg.(*libp2p.Message).GetToken())
+
+	addReq := libp2p.AddProviderRequest([]byte("k"), provider)
+	addReq.SetToken(tok)
+	_, err = s0.HandleRequest(ctx, requester, addReq)
+	require.NoError(t, err)
+	provs, err := providers.GetProviders(ctx, []byte("k"))
+	require.NoError(t, err)
+	require.Len(t, provs, 1)
+}
+
+func TestIPFSv1RoutableProtocol(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+
+	selfPid, err := peer.Decode("1EooooSELF")
+	require.NoError(t, err)
+	self := libp2p.NewPeerID(selfPid)
+
+	router := sim.NewRouter[key.Key256, multiaddr.Multiaddr]()
+	sched := event.NewSimpleScheduler(clk)
+	fakeEndpoint := sim.NewEndpoint[key.Key256, multiaddr.Multiaddr](self.NodeID(), sched, router)
+	rt := simplert.New[key.Key256, kad.NodeID[key.Key256]](self, 4)
+
+	// peer 2 serves the DHT, peer 3 is a client registering no handler
+	var infos []kad.NodeInfo[key.Key256, multiaddr.Multiaddr]
+	for i := 2; i <= 3; i++ {
+		p, err := peer.Decode("1EoooPEER" + fmt.Sprint(i))
+		require.NoError(t, err)
+		info := libp2p.NewAddrInfo(peer.AddrInfo{
+			ID:    p,
+			Addrs: []multiaddr.Multiaddr{multiaddr.StringCast("/ip4/" + fmt.Sprint(i) + ".0.0.1")},
+		})
+		ep := sim.NewEndpoint[key.Key256, multiaddr.Multiaddr](info.PeerID().NodeID(), sched, router)
+		if i == 2 {
+			require.NoError(t, ep.AddRequestHandler(libp2p.ProtocolIPFSDHT, &libp2p.Message{},
+				func(context.Context, kad.NodeID[key.Key256], kad.Message) (kad.Message, error) {
+					return nil, nil
+				}))
+		}
+		require.NoError(t, fakeEndpoint.MaybeAddToPeerstore(ctx, info, time.Second))
+		require.True(t, rt.AddNode(info.PeerID()))
+		infos = append(infos, info)
+	}
+	require.True(t, fakeEndpoint.PeerSupportsProtocol(infos[0].ID(), libp2p.ProtocolIPFSDHT))
+	require.False(t, fakeEndpoint.PeerSupportsProtocol(infos[1].ID(), libp2p.ProtocolIPFSDHT))
+
+	requesterPid, err := peer.Decode("1WoooREQUESTER")
+	require.NoError(t, err)
+	requester := libp2p.NewPeerID(requesterPid)
+
+	// by default all the nodes of the routing table may be sent
+	s0 := NewBasicServer[multiaddr.Multiaddr](rt, fakeEndpoint)
+	msg, err := s0.HandleRequest(ctx, requester, libp2p.FindPeerRequest(self))
+	require.NoError(t, err)
+	require.Len(t, msg.(*libp2p.Message).CloserNodes(), 2)
+
+	// the client is left out
+	s0 = NewBasicServer[multiaddr.Multiaddr](rt, fakeEndpoint, WithRoutableProtocol(libp2p.ProtocolIPFSDHT))
+	msg, err = s0.HandleRequest(ctx, requester, libp2p.FindPeerRequest(self))
+	require.NoError(t, err)
+	require.Equal(t, infos[:1], msg.(*libp2p.Message).CloserNodes())
+}
+
+func TestIPFSv1SelfAddrs(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+
+	selfPid, err := peer.Decode("1EooooSELF")
+	require.NoError(t, err)
+	self := libp2p.NewPeerID(selfPid)
+
+	router := sim.NewRouter[key.Key256, multiaddr.Multiaddr]()
+	sched := event.NewSimpleScheduler(clk)
+	fakeEndpoint := sim.NewEndpoint[key.Key256, multiaddr.Multiaddr](self.NodeID(), sched, router)
+	rt := simplert.New[key.Key256, kad.NodeID[key.Key256]](self, 4)
+
+	p, err := peer.Decode("1EoooPEER2")
+	require.NoError(t, err)
+	closer := libp2p.NewAddrInfo(peer.AddrInfo{
+		ID:    p,
+		Addrs: []multiaddr.Multiaddr{multiaddr.StringCast("/ip4/2.2.2.2")},
+	})
+	require.NoError(t, fakeEndpoint.MaybeAddToPeerstore(ctx, closer, time.Second))
+	require.True(t, rt.AddNode(closer.PeerID()))
+
+	requesterPid, err := peer.Decode("1WoooREQUESTER")
+	require.NoError(t, err)
+	requester := libp2p.NewPeerID(requesterPid)
+
+	cfg := records.DefaultProviderConfig()
+	cfg.Clock = clk
+	store, err := records.NewProviderStore[key.Key256, multiaddr.Multiaddr](cfg)
+	require.NoError(t, err)
+	outdated := libp2p.NewAddrInfo(peer.AddrInfo{
+		ID:    selfPid,
+		Addrs: []multiaddr.Multiaddr{multiaddr.StringCast("/ip4/9.9.9.9")},
+	})
+	require.NoError(t, store.AddProvider(ctx, []byte("k"), outdated))
+
+	// without self addresses, the local node is not sent in closer peers
+	s0 := NewBasicServer[multiaddr.Multiaddr](rt, fakeEndpoint, WithProviderStore(store))
+	msg, err := s0.HandleRequest(ctx, requester, libp2p.FindPeerRequest(self))
+	require.NoError(t, err)
+	require.Equal(t, []kad.NodeInfo[key.Key256, multiaddr.Multiaddr]{closer}, msg.(*libp2p.Message).CloserNodes())
+
+	addrs, err := selfaddr.New[key.Key256, multiaddr.Multiaddr](self, nil, nil)
+	require.NoError(t, err)
+	s0 = NewBasicServer[multiaddr.Multiaddr](rt, fakeEndpoint, WithProviderStore(store), WithSelfAddrs(addrs))
+
+	// the local node is not sent until it has an address
+	msg, err = s0.HandleRequest(ctx, requester, libp2p.FindPeerRequest(self))
+	require.NoError(t, err)
+	require.Len(t, msg.(*libp2p.Message).CloserNodes(), 1)
+
+	// a lookup of the local node learns its advertised addresses
+	addrs.SetStatic([]multiaddr.Multiaddr{multiaddr.StringCast("/ip4/1.1.1.1")})
+	current := libp2p.NewAddrInfo(peer.AddrInfo{
+		ID:    selfPid,
+		Addrs: []multiaddr.Multiaddr{multiaddr.StringCast("/ip4/1.1.1.1")},
+	})
+	msg, err = s0.HandleRequest(ctx, requester, libp2p.FindPeerRequest(self))
+	require.NoError(t, err)
+	require.Equal(t, []kad.NodeInfo[key.Key256, multiaddr.Multiaddr]{current, closer}, msg.(*libp2p.Message).CloserNodes())
+
+	// the local node is only sent when it is the target
+	msg, err = s0.HandleRequest(ctx, requester, libp2p.FindPeerRequest(closer.PeerID()))
+	require.NoError(t, err)
+	require.Equal(t, []kad.NodeInfo[key.Key256, multiaddr.Multiaddr]{closer}, msg.(*libp2p.Message).CloserNodes())
+
+	// the local node is sent as a provider with its current addresses
+	msg, err = s0.HandleRequest(ctx, requester, libp2p.GetProvidersRequest([]byte("k")))
+	require.NoError(t, err)
+	require.Equal(t, []kad.NodeInfo[key.Key256, multiaddr.Multiaddr]{current}, msg.(*libp2p.Message).ProviderNodes())
+}
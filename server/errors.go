@@ -0,0 +1,11 @@
+package server
+
+import "errors"
+
+var (
+	ErrThrottled = errors.New("request rate limit exceeded")
+	ErrBanned    = errors.New("remote node is banned")
+
+	ErrUnsupportedRequest = errors.New("unsupported request")
+	ErrNotFound           = errors.New("not found")
+)
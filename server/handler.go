@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+// HandlerFunc is an adapter to allow the use of ordinary functions as a Server.
+type HandlerFunc[K kad.Key[K]] func(context.Context, kad.NodeID[K], kad.Message) (kad.Message, error)
+
+var _ Server[key.Key8] = HandlerFunc[key.Key8](nil)
+
+// HandleRequest calls f(ctx, rpeer, msg).
+func (f HandlerFunc[K]) HandleRequest(ctx context.Context, rpeer kad.NodeID[K], msg kad.Message) (kad.Message, error) {
+	return f(ctx, rpeer, msg)
+}
+
+// Register registers s to handle the requests of the given protocol received by e. Requests are
+// decoded into messages of the same type as req.
+func Register[K kad.Key[K], A kad.Address[A]](e endpoint.ServerEndpoint[K, A], protoID address.ProtocolID,
+	req kad.Message, s Server[K],
+) error {
+	return e.AddRequestHandler(protoID, req, s.HandleRequest)
+}
+
+// Mux is a Server dispatching each request to the Server registered for its request type.
+// The type of a request is determined by a classifier function, for instance returning
+// the type field of a protobuf message. It is safe for concurrent use.
+type Mux[K kad.Key[K], T comparable] struct {
+	classify func(kad.Message) (T, bool)
+
+	mu       sync.RWMutex
+	handlers map[T]Server[K]
+}
+
+var _ Server[key.Key8] = (*Mux[key.Key8, int])(nil)
+
+// NewMux creates a Mux using classify to determine the type of each request. classify returns
+// false if the message is not a request it understands.
+func NewMux[K kad.Key[K], T comparable](classify func(kad.Message) (T, bool)) *Mux[K, T] {
+	return &Mux[K, T]{
+		classify: classify,
+		handlers: make(map[T]Server[K]),
+	}
+}
+
+// Handle registers s for requests of type t, replacing any Server previously registered for t.
+func (m *Mux[K, T]) Handle(t T, s Server[K]) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[t] = s
+}
+
+// Remove removes the Server registered for requests of type t.
+func (m *Mux[K, T]) Remove(t T) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.handlers, t)
+}
+
+// HandleRequest passes the request to the Server registered for its type. It returns
+// ErrUnsupportedRequest if the message cannot be classified or no Server handles its type.
+func (m *Mux[K, T]) HandleRequest(ctx context.Context, rpeer kad.NodeID[K], msg kad.Message) (kad.Message, error) {
+	t, ok := m.classify(msg)
+	if !ok {
+		return nil, ErrUnsupportedRequest
+	}
+	m.mu.RLock()
+	s, ok := m.handlers[t]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnsupportedRequest
+	}
+	return s.HandleRequest(ctx, rpeer, msg)
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+// recordingEndpoint is a ServerEndpoint recording the registered request handlers.
+type recordingEndpoint struct {
+	endpoint.Endpoint[key.Key8, kadtest.StrAddr]
+	handlers map[address.ProtocolID]endpoint.RequestHandlerFn[key.Key8]
+}
+
+func (e *recordingEndpoint) AddRequestHandler(protoID address.ProtocolID, _ kad.Message,
+	fn endpoint.RequestHandlerFn[key.Key8],
+) error {
+	if fn == nil {
+		return endpoint.ErrNilRequestHandler
+	}
+	e.handlers[protoID] = fn
+	return nil
+}
+
+func (e *recordingEndpoint) RemoveRequestHandler(protoID address.ProtocolID) {
+	delete(e.handlers, protoID)
+}
+
+type typedMessage struct {
+	typ string
+}
+
+func classifyTyped(msg kad.Message) (string, bool) {
+	m, ok := msg.(*typedMessage)
+	if !ok {
+		return "", false
+	}
+	return m.typ, true
+}
+
+func TestHandlerFunc(t *testing.T) {
+	ctx := context.Background()
+	requester := kadtest.NewID(key.Key8(1))
+
+	var got kad.NodeID[key.Key8]
+	s := HandlerFunc[key.Key8](func(ctx context.Context, rpeer kad.NodeID[key.Key8], msg kad.Message) (kad.Message, error) {
+		got = rpeer
+		return msg, nil
+	})
+	resp, err := s.HandleRequest(ctx, requester, "hello")
+	require.NoError(t, err)
+	require.Equal(t, "hello", resp)
+	require.Equal(t, requester, got)
+}
+
+func TestRegister(t *testing.T) {
+	ctx := context.Background()
+	e := &recordingEndpoint{handlers: make(map[address.ProtocolID]endpoint.RequestHandlerFn[key.Key8])}
+	protoID := address.ProtocolID("/test/1.0.0")
+
+	err := Register[key.Key8, kadtest.StrAddr](e, protoID, &typedMessage{}, &testServer{})
+	require.NoError(t, err)
+
+	fn, ok := e.handlers[protoID]
+	require.True(t, ok)
+	resp, err := fn(ctx, kadtest.NewID(key.Key8(1)), "hello")
+	require.NoError(t, err)
+	require.Equal(t, "hello", resp)
+}
+
+func TestMux(t *testing.T) {
+	ctx := context.Background()
+	requester := kadtest.NewID(key.Key8(1))
+
+	mux := NewMux[key.Key8](classifyTyped)
+	mux.Handle("ping", HandlerFunc[key.Key8](func(context.Context, kad.NodeID[key.Key8], kad.Message) (kad.Message, error) {
+		return "pong", nil
+	}))
+	mux.Handle("fail", &testServer{fail: true})
+
+	resp, err := mux.HandleRequest(ctx, requester, &typedMessage{typ: "ping"})
+	require.NoError(t, err)
+	require.Equal(t, "pong", resp)
+
+	_, err = mux.HandleRequest(ctx, requester, &typedMessage{typ: "fail"})
+	require.ErrorIs(t, err, errBadMessage)
+
+	// no handler for the request type
+	_, err = mux.HandleRequest(ctx, requester, &typedMessage{typ: "other"})
+	require.ErrorIs(t, err, ErrUnsupportedRequest)
+
+	// message that cannot be classified
+	_, err = mux.HandleRequest(ctx, requester, "hello")
+	require.ErrorIs(t, err, ErrUnsupportedRequest)
+
+	mux.Remove("ping")
+	_, err = mux.HandleRequest(ctx, requester, &typedMessage{typ: "ping"})
+	require.ErrorIs(t, err, ErrUnsupportedRequest)
+}
@@ -0,0 +1,283 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/routing/denylist"
+	"github.com/plprobelab/go-kademlia/util"
+)
+
+// PeerStats holds the message statistics a Monitor keeps for a remote node.
+type PeerStats struct {
+	Requests       int       // the total number of requests received from the node
+	Invalid        int       // the total number of requests from the node that could not be handled
+	WindowRequests int       // the number of requests received in the current window
+	WindowInvalid  int       // the number of invalid requests received in the current window
+	WindowStart    time.Time // the start of the current window
+	LastSeen       time.Time // the time the last request was received
+}
+
+// Verdict is the action a Policy decides to take against a remote node.
+type Verdict int
+
+const (
+	VerdictAllow    Verdict = iota // handle the request
+	VerdictWarn                    // handle the request but report the node
+	VerdictThrottle                // reject the request
+	VerdictBan                     // reject the request and ban the node
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case VerdictAllow:
+		return "allow"
+	case VerdictWarn:
+		return "warn"
+	case VerdictThrottle:
+		return "throttle"
+	case VerdictBan:
+		return "ban"
+	default:
+		return fmt.Sprintf("Verdict(%d)", int(v))
+	}
+}
+
+// A Policy decides how a Monitor treats a remote node based on its statistics.
+type Policy interface {
+	// Evaluate returns the verdict for a node whose statistics, including the request being
+	// evaluated, are given.
+	Evaluate(PeerStats) Verdict
+}
+
+// ThresholdPolicy is a Policy that compares the per-window counts of a node against fixed
+// thresholds. A zero threshold is disabled. The most severe verdict whose threshold is reached wins.
+type ThresholdPolicy struct {
+	WarnRequests     int // the number of requests per window at which a node is reported
+	ThrottleRequests int // the number of requests per window at which requests are rejected
+	BanRequests      int // the number of requests per window at which a node is banned
+	BanInvalid       int // the number of invalid requests per window at which a node is banned
+}
+
+var _ Policy = (*ThresholdPolicy)(nil)
+
+func (p *ThresholdPolicy) Evaluate(s PeerStats) Verdict {
+	switch {
+	case p.BanRequests > 0 && s.WindowRequests >= p.BanRequests:
+		return VerdictBan
+	case p.BanInvalid > 0 && s.WindowInvalid >= p.BanInvalid:
+		return VerdictBan
+	case p.ThrottleRequests > 0 && s.WindowRequests >= p.ThrottleRequests:
+		return VerdictThrottle
+	case p.WarnRequests > 0 && s.WindowRequests >= p.WarnRequests:
+		return VerdictWarn
+	default:
+		return VerdictAllow
+	}
+}
+
+// A Banner bans remote nodes for a period of time, typically a denylist.PeerDenyList shared with
+// the routing table and queries.
+type Banner interface {
+	// Ban bans the node for the given duration.
+	Ban(fmt.Stringer, time.Duration)
+}
+
+var _ Banner = (*denylist.PeerDenyList)(nil)
+
+// VerdictFunc is called by a Monitor whenever its policy returns a verdict other than VerdictAllow.
+type VerdictFunc[K kad.Key[K]] func(kad.NodeID[K], PeerStats, Verdict)
+
+// MonitorConfig specifies optional configuration for a Monitor
+type MonitorConfig[K kad.Key[K]] struct {
+	Window      time.Duration  // the duration of the window over which request rates are measured
+	Policy      Policy         // the policy deciding how remote nodes are treated
+	Banner      Banner         // the component banned nodes are reported to, if nil bans only reject the request
+	BanDuration time.Duration  // the duration for which banned nodes are banned
+	OnVerdict   VerdictFunc[K] // an optional function called with every verdict other than VerdictAllow
+	Clock       clock.Clock    // a clock that may replaced by a mock when testing
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *MonitorConfig[K]) Validate() error {
+	if cfg.Clock == nil {
+		return &kaderr.ConfigurationError{
+			Component: "MonitorConfig",
+			Err:       fmt.Errorf("clock must not be nil"),
+		}
+	}
+	if cfg.Window < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "MonitorConfig",
+			Err:       fmt.Errorf("window must be greater than zero"),
+		}
+	}
+	if cfg.Policy == nil {
+		return &kaderr.ConfigurationError{
+			Component: "MonitorConfig",
+			Err:       fmt.Errorf("policy must not be nil"),
+		}
+	}
+	if cfg.BanDuration < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "MonitorConfig",
+			Err:       fmt.Errorf("ban duration must be greater than zero"),
+		}
+	}
+	return nil
+}
+
+// DefaultMonitorConfig returns the default configuration options for a Monitor.
+// Options may be overridden before passing to NewMonitor
+func DefaultMonitorConfig[K kad.Key[K]]() *MonitorConfig[K] {
+	return &MonitorConfig[K]{
+		Window: time.Minute,
+		Policy: &ThresholdPolicy{
+			WarnRequests:     300,
+			ThrottleRequests: 600,
+			BanRequests:      1200,
+			BanInvalid:       10,
+		},
+		BanDuration: time.Hour,
+		Clock:       clock.New(), // use standard time
+	}
+}
+
+// Monitor is a Server that aggregates inbound message statistics per remote node and applies a
+// Policy to them before passing requests on to another Server. Requests the wrapped server fails
+// to handle are counted as invalid.
+type Monitor[K kad.Key[K]] struct {
+	cfg   MonitorConfig[K]
+	inner Server[K]
+
+	mu    sync.Mutex
+	peers map[string]*PeerStats
+}
+
+var _ Server[key.Key8] = (*Monitor[key.Key8])(nil)
+
+// NewMonitor creates a Monitor that passes requests to inner. If cfg is nil, the default config is used.
+func NewMonitor[K kad.Key[K]](inner Server[K], cfg *MonitorConfig[K]) (*Monitor[K], error) {
+	if cfg == nil {
+		cfg = DefaultMonitorConfig[K]()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Monitor[K]{
+		cfg:   *cfg,
+		inner: inner,
+		peers: make(map[string]*PeerStats),
+	}, nil
+}
+
+// HandleRequest records the request, evaluates the policy and, if allowed, passes the request on.
+func (m *Monitor[K]) HandleRequest(ctx context.Context, rpeer kad.NodeID[K], msg kad.Message) (kad.Message, error) {
+	ctx, span := util.StartSpan(ctx, "Monitor.HandleRequest", trace.WithAttributes(
+		attribute.Stringer("Requester", rpeer)))
+	defer span.End()
+
+	stats, verdict := m.record(rpeer, false)
+	if verdict != VerdictAllow {
+		span.AddEvent("verdict", trace.WithAttributes(attribute.Stringer("verdict", verdict)))
+		if m.cfg.OnVerdict != nil {
+			m.cfg.OnVerdict(rpeer, stats, verdict)
+		}
+	}
+
+	switch verdict {
+	case VerdictThrottle:
+		return nil, ErrThrottled
+	case VerdictBan:
+		if m.cfg.Banner != nil {
+			m.cfg.Banner.Ban(rpeer, m.cfg.BanDuration)
+		}
+		return nil, ErrBanned
+	}
+
+	resp, err := m.inner.HandleRequest(ctx, rpeer, msg)
+	if err != nil {
+		span.RecordError(err)
+		m.RecordInvalid(rpeer)
+	}
+	return resp, err
+}
+
+// RecordInvalid records that a message received from rpeer was invalid. It may be called by
+// components that detect invalid messages outside of the wrapped server. If the policy decides
+// to ban the node it is reported to the banner.
+func (m *Monitor[K]) RecordInvalid(rpeer kad.NodeID[K]) {
+	stats, verdict := m.record(rpeer, true)
+	if verdict == VerdictAllow {
+		return
+	}
+	if m.cfg.OnVerdict != nil {
+		m.cfg.OnVerdict(rpeer, stats, verdict)
+	}
+	if verdict == VerdictBan && m.cfg.Banner != nil {
+		m.cfg.Banner.Ban(rpeer, m.cfg.BanDuration)
+	}
+}
+
+// Stats returns the statistics recorded for a remote node.
+func (m *Monitor[K]) Stats(rpeer kad.NodeID[K]) (PeerStats, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.peers[rpeer.String()]
+	if !ok {
+		return PeerStats{}, false
+	}
+	return *s, true
+}
+
+// Prune forgets the statistics of remote nodes that have not sent a request for a full window.
+func (m *Monitor[K]) Prune() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.cfg.Clock.Now()
+	for k, s := range m.peers {
+		if now.Sub(s.LastSeen) >= m.cfg.Window {
+			delete(m.peers, k)
+		}
+	}
+}
+
+// record updates the statistics of rpeer with a new request, or marks its last request as invalid,
+// and returns the updated statistics together with the policy's verdict.
+func (m *Monitor[K]) record(rpeer kad.NodeID[K], invalid bool) (PeerStats, Verdict) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.cfg.Clock.Now()
+	s, ok := m.peers[rpeer.String()]
+	if !ok {
+		s = &PeerStats{WindowStart: now}
+		m.peers[rpeer.String()] = s
+	}
+	if now.Sub(s.WindowStart) >= m.cfg.Window {
+		// start a new window
+		s.WindowStart = now
+		s.WindowRequests = 0
+		s.WindowInvalid = 0
+	}
+
+	if invalid {
+		s.Invalid++
+		s.WindowInvalid++
+	} else {
+		s.Requests++
+		s.WindowRequests++
+		s.LastSeen = now
+	}
+
+	return *s, m.cfg.Policy.Evaluate(*s)
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+var errBadMessage = errors.New("bad message")
+
+type testServer struct {
+	fail bool
+}
+
+func (s *testServer) HandleRequest(ctx context.Context, rpeer kad.NodeID[key.Key8], msg kad.Message) (kad.Message, error) {
+	if s.fail {
+		return nil, errBadMessage
+	}
+	return msg, nil
+}
+
+type testBanner struct {
+	banned map[string]time.Duration
+}
+
+func (b *testBanner) Ban(id fmt.Stringer, d time.Duration) {
+	b.banned[id.String()] = d
+}
+
+func TestMonitorConfigValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		cfg := DefaultMonitorConfig[key.Key8]()
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("clock is not nil", func(t *testing.T) {
+		cfg := DefaultMonitorConfig[key.Key8]()
+		cfg.Clock = nil
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("window positive", func(t *testing.T) {
+		cfg := DefaultMonitorConfig[key.Key8]()
+		cfg.Window = 0
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("policy is not nil", func(t *testing.T) {
+		cfg := DefaultMonitorConfig[key.Key8]()
+		cfg.Policy = nil
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("ban duration positive", func(t *testing.T) {
+		cfg := DefaultMonitorConfig[key.Key8]()
+		cfg.BanDuration = 0
+		require.Error(t, cfg.Validate())
+	})
+}
+
+func TestThresholdPolicy(t *testing.T) {
+	p := &ThresholdPolicy{WarnRequests: 2, ThrottleRequests: 3, BanRequests: 5, BanInvalid: 2}
+	require.Equal(t, VerdictAllow, p.Evaluate(PeerStats{WindowRequests: 1}))
+	require.Equal(t, VerdictWarn, p.Evaluate(PeerStats{WindowRequests: 2}))
+	require.Equal(t, VerdictThrottle, p.Evaluate(PeerStats{WindowRequests: 4}))
+	require.Equal(t, VerdictBan, p.Evaluate(PeerStats{WindowRequests: 5}))
+	require.Equal(t, VerdictBan, p.Evaluate(PeerStats{WindowRequests: 1, WindowInvalid: 2}))
+
+	// zero thresholds are disabled
+	require.Equal(t, VerdictAllow, (&ThresholdPolicy{}).Evaluate(PeerStats{WindowRequests: 1000}))
+}
+
+func TestMonitorRates(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	banner := &testBanner{banned: make(map[string]time.Duration)}
+
+	var verdicts []Verdict
+	cfg := DefaultMonitorConfig[key.Key8]()
+	cfg.Clock = clk
+	cfg.Window = time.Minute
+	cfg.Policy = &ThresholdPolicy{WarnRequests: 2, ThrottleRequests: 3, BanRequests: 5}
+	cfg.Banner = banner
+	cfg.OnVerdict = func(_ kad.NodeID[key.Key8], _ PeerStats, v Verdict) {
+		verdicts = append(verdicts, v)
+	}
+
+	m, err := NewMonitor[key.Key8](&testServer{}, cfg)
+	require.NoError(t, err)
+
+	a := kadtest.NewID(key.Key8(0b00000100))
+	b := kadtest.NewID(key.Key8(0b00001000))
+	msg := kadtest.NewRequest("1", key.Key8(0))
+
+	_, err = m.HandleRequest(ctx, a, msg)
+	require.NoError(t, err)
+	_, err = m.HandleRequest(ctx, a, msg)
+	require.NoError(t, err)
+	require.Equal(t, []Verdict{VerdictWarn}, verdicts)
+
+	_, err = m.HandleRequest(ctx, a, msg)
+	require.ErrorIs(t, err, ErrThrottled)
+
+	// other nodes are tracked separately
+	_, err = m.HandleRequest(ctx, b, msg)
+	require.NoError(t, err)
+
+	_, err = m.HandleRequest(ctx, a, msg)
+	require.ErrorIs(t, err, ErrThrottled)
+	_, err = m.HandleRequest(ctx, a, msg)
+	require.ErrorIs(t, err, ErrBanned)
+	require.Equal(t, cfg.BanDuration, banner.banned[a.String()])
+	require.NotContains(t, banner.banned, b.String())
+
+	stats, ok := m.Stats(a)
+	require.True(t, ok)
+	require.Equal(t, 5, stats.Requests)
+	require.Equal(t, 5, stats.WindowRequests)
+
+	// a new window resets the rate
+	clk.Add(time.Minute)
+	_, err = m.HandleRequest(ctx, a, msg)
+	require.NoError(t, err)
+
+	stats, ok = m.Stats(a)
+	require.True(t, ok)
+	require.Equal(t, 6, stats.Requests)
+	require.Equal(t, 1, stats.WindowRequests)
+}
+
+func TestMonitorInvalid(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	banner := &testBanner{banned: make(map[string]time.Duration)}
+
+	cfg := DefaultMonitorConfig[key.Key8]()
+	cfg.Clock = clk
+	cfg.Policy = &ThresholdPolicy{BanInvalid: 2}
+	cfg.Banner = banner
+
+	m, err := NewMonitor[key.Key8](&testServer{fail: true}, cfg)
+	require.NoError(t, err)
+
+	a := kadtest.NewID(key.Key8(0b00000100))
+	msg := kadtest.NewRequest("1", key.Key8(0))
+
+	_, err = m.HandleRequest(ctx, a, msg)
+	require.ErrorIs(t, err, errBadMessage)
+	require.Empty(t, banner.banned)
+
+	// the second invalid message triggers the ban
+	_, err = m.HandleRequest(ctx, a, msg)
+	require.ErrorIs(t, err, errBadMessage)
+	require.Contains(t, banner.banned, a.String())
+
+	stats, ok := m.Stats(a)
+	require.True(t, ok)
+	require.Equal(t, 2, stats.Invalid)
+}
+
+func TestMonitorPrune(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	cfg := DefaultMonitorConfig[key.Key8]()
+	cfg.Clock = clk
+
+	m, err := NewMonitor[key.Key8](&testServer{}, cfg)
+	require.NoError(t, err)
+
+	a := kadtest.NewID(key.Key8(0b00000100))
+	_, err = m.HandleRequest(ctx, a, kadtest.NewRequest("1", key.Key8(0)))
+	require.NoError(t, err)
+
+	m.Prune()
+	_, ok := m.Stats(a)
+	require.True(t, ok)
+
+	clk.Add(cfg.Window)
+	m.Prune()
+	_, ok = m.Stats(a)
+	require.False(t, ok)
+}
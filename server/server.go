@@ -11,3 +11,10 @@ type Server[K kad.Key[K]] interface {
 	// HandleRequest handles a request from a remote peer.
 	HandleRequest(context.Context, kad.NodeID[K], kad.Message) (kad.Message, error)
 }
+
+// SelfAddrs supplies the node info of the local node, with the addresses it advertises, to the
+// handlers building responses that describe the local node, such as selfaddr.Manager.
+type SelfAddrs[K kad.Key[K], A kad.Address[A]] interface {
+	// NodeInfo returns the node info of the local node.
+	NodeInfo() kad.NodeInfo[K, A]
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"context"
+
+	"github.com/plprobelab/go-kademlia/kad"
+)
+
+// ValueStore stores the values served by the GET_VALUE and PUT_VALUE request handlers.
+type ValueStore interface {
+	// Get returns the value stored under key, or ErrNotFound if there is none.
+	Get(ctx context.Context, key []byte) ([]byte, error)
+
+	// Put stores value under key.
+	Put(ctx context.Context, key, value []byte) error
+}
+
+// ProviderStore stores the providers served by the GET_PROVIDERS request handler and announced by
+// ADD_PROVIDER requests.
+type ProviderStore[K kad.Key[K], A kad.Address[A]] interface {
+	// AddProvider records that prov provides the content of key.
+	AddProvider(ctx context.Context, key []byte, prov kad.NodeInfo[K, A]) error
+
+	// GetProviders returns the known providers of key, or an empty list if there are none.
+	GetProviders(ctx context.Context, key []byte) ([]kad.NodeInfo[K, A], error)
+}
@@ -0,0 +1,43 @@
+package token
+
+import (
+	"sync"
+
+	"github.com/plprobelab/go-kademlia/kad"
+)
+
+// Tokens holds the tokens a client received from servers in response to GET requests so that
+// they can be presented in subsequent PUT requests to the same servers.
+type Tokens[K kad.Key[K]] struct {
+	mu     sync.Mutex
+	tokens map[string][]byte
+}
+
+// NewTokens creates an empty token holder.
+func NewTokens[K kad.Key[K]]() *Tokens[K] {
+	return &Tokens[K]{
+		tokens: make(map[string][]byte),
+	}
+}
+
+// Set records the token issued by server, replacing any previous token.
+func (t *Tokens[K]) Set(server kad.NodeID[K], tok []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tokens[server.String()] = tok
+}
+
+// Get returns the token issued by server, if any.
+func (t *Tokens[K]) Get(server kad.NodeID[K]) ([]byte, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tok, ok := t.tokens[server.String()]
+	return tok, ok
+}
+
+// Delete forgets the token issued by server, for example after it was rejected.
+func (t *Tokens[K]) Delete(server kad.NodeID[K]) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.tokens, server.String())
+}
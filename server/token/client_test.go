@@ -0,0 +1,36 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+func TestTokensRoundTrip(t *testing.T) {
+	m, err := NewManager[key.Key8](nil)
+	require.NoError(t, err)
+
+	client := kadtest.NewID(key.Key8(0b00000100))
+	server := kadtest.NewID(key.Key8(0b00001000))
+
+	tokens := NewTokens[key.Key8]()
+	_, ok := tokens.Get(server)
+	require.False(t, ok)
+
+	// the server issues a token to the client when answering a GET
+	tok, err := m.Issue(client)
+	require.NoError(t, err)
+	tokens.Set(server, tok)
+
+	// the client presents it in its PUT
+	got, ok := tokens.Get(server)
+	require.True(t, ok)
+	require.NoError(t, m.Check(client, got))
+
+	tokens.Delete(server)
+	_, ok = tokens.Get(server)
+	require.False(t, ok)
+}
@@ -0,0 +1,5 @@
+package token
+
+import "errors"
+
+var ErrInvalidToken = errors.New("invalid write token")
@@ -0,0 +1,172 @@
+// Package token implements the write authorization tokens used to protect PUT operations.
+//
+// A server issues a token to a requester when answering a GET request. The requester must
+// present the same token in a subsequent PUT request to the same server, which proves that
+// it can receive messages sent to its identity (as in the BitTorrent DHT). Tokens are derived
+// from a secret that is rotated periodically; tokens issued with the previous secret remain
+// valid so that a token is accepted for at least one rotation interval.
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+)
+
+// SecretSize is the size in bytes of the secrets used to derive tokens.
+const SecretSize = 32
+
+// Config specifies optional configuration for a Manager
+type Config struct {
+	RotationInterval time.Duration // the interval at which the secret used to derive tokens is rotated
+	Clock            clock.Clock   // a clock that may replaced by a mock when testing
+	Rand             io.Reader     // the source of randomness used to generate secrets
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *Config) Validate() error {
+	if cfg.RotationInterval < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "TokenConfig",
+			Err:       fmt.Errorf("rotation interval must be greater than zero"),
+		}
+	}
+	if cfg.Clock == nil {
+		return &kaderr.ConfigurationError{
+			Component: "TokenConfig",
+			Err:       fmt.Errorf("clock must not be nil"),
+		}
+	}
+	if cfg.Rand == nil {
+		return &kaderr.ConfigurationError{
+			Component: "TokenConfig",
+			Err:       fmt.Errorf("rand must not be nil"),
+		}
+	}
+	return nil
+}
+
+// DefaultConfig returns the default configuration options for a Manager.
+// Options may be overridden before passing to NewManager
+func DefaultConfig() *Config {
+	return &Config{
+		RotationInterval: 5 * time.Minute,
+		Clock:            clock.New(), // use standard time
+		Rand:             rand.Reader,
+	}
+}
+
+// Manager issues and verifies write authorization tokens on behalf of a server.
+type Manager[K kad.Key[K]] struct {
+	cfg Config
+
+	mu       sync.Mutex
+	current  []byte
+	previous []byte
+	rotated  time.Time
+}
+
+// NewManager creates a new Manager. If cfg is nil, the default config is used.
+func NewManager[K kad.Key[K]](cfg *Config) (*Manager[K], error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	m := &Manager[K]{
+		cfg: *cfg,
+	}
+
+	var err error
+	if m.current, err = m.newSecret(); err != nil {
+		return nil, err
+	}
+	if m.previous, err = m.newSecret(); err != nil {
+		return nil, err
+	}
+	m.rotated = m.cfg.Clock.Now()
+
+	return m, nil
+}
+
+// Issue returns a token that requester must present to authorize a write.
+func (m *Manager[K]) Issue(requester kad.NodeID[K]) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.maybeRotate(); err != nil {
+		return nil, err
+	}
+	return derive(m.current, requester), nil
+}
+
+// Verify reports whether tok is a valid token for requester. Tokens issued before the
+// most recent rotation of the secret are still accepted.
+func (m *Manager[K]) Verify(requester kad.NodeID[K], tok []byte) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.maybeRotate(); err != nil {
+		return false
+	}
+	return hmac.Equal(tok, derive(m.current, requester)) || hmac.Equal(tok, derive(m.previous, requester))
+}
+
+// Check returns ErrInvalidToken if tok is not a valid token for requester.
+func (m *Manager[K]) Check(requester kad.NodeID[K], tok []byte) error {
+	if !m.Verify(requester, tok) {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// maybeRotate rotates the secrets if the rotation interval has elapsed. It must be called with mu held.
+func (m *Manager[K]) maybeRotate() error {
+	elapsed := m.cfg.Clock.Since(m.rotated)
+	if elapsed < m.cfg.RotationInterval {
+		return nil
+	}
+
+	next, err := m.newSecret()
+	if err != nil {
+		return err
+	}
+
+	if elapsed >= 2*m.cfg.RotationInterval {
+		// the current secret is older than a full rotation interval so tokens derived from it are expired too
+		prev, err := m.newSecret()
+		if err != nil {
+			return err
+		}
+		m.previous = prev
+	} else {
+		m.previous = m.current
+	}
+	m.current = next
+	m.rotated = m.cfg.Clock.Now()
+	return nil
+}
+
+func (m *Manager[K]) newSecret() ([]byte, error) {
+	secret := make([]byte, SecretSize)
+	if _, err := io.ReadFull(m.cfg.Rand, secret); err != nil {
+		return nil, fmt.Errorf("generate secret: %w", err)
+	}
+	return secret, nil
+}
+
+func derive[K kad.Key[K]](secret []byte, requester kad.NodeID[K]) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(requester.String()))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,94 @@
+package token
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+func TestConfigValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		cfg := DefaultConfig()
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("rotation interval positive", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.RotationInterval = 0
+		require.Error(t, cfg.Validate())
+		cfg.RotationInterval = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("clock is not nil", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Clock = nil
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("rand is not nil", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Rand = nil
+		require.Error(t, cfg.Validate())
+	})
+}
+
+func TestIssueVerify(t *testing.T) {
+	m, err := NewManager[key.Key8](nil)
+	require.NoError(t, err)
+
+	a := kadtest.NewID(key.Key8(0b00000100))
+	b := kadtest.NewID(key.Key8(0b00001000))
+
+	tok, err := m.Issue(a)
+	require.NoError(t, err)
+
+	require.True(t, m.Verify(a, tok))
+	require.NoError(t, m.Check(a, tok))
+
+	// the token is bound to the requester
+	require.False(t, m.Verify(b, tok))
+	require.ErrorIs(t, m.Check(b, tok), ErrInvalidToken)
+
+	// arbitrary tokens are rejected
+	require.False(t, m.Verify(a, nil))
+	require.False(t, m.Verify(a, []byte("forged")))
+}
+
+func TestTokenExpiry(t *testing.T) {
+	clk := clock.NewMock()
+	cfg := DefaultConfig()
+	cfg.Clock = clk
+	cfg.RotationInterval = time.Minute
+
+	m, err := NewManager[key.Key8](cfg)
+	require.NoError(t, err)
+
+	a := kadtest.NewID(key.Key8(0b00000100))
+	tok, err := m.Issue(a)
+	require.NoError(t, err)
+
+	// after one rotation the token is still accepted
+	clk.Add(time.Minute)
+	require.True(t, m.Verify(a, tok))
+
+	// a new token is issued from the new secret
+	tok2, err := m.Issue(a)
+	require.NoError(t, err)
+	require.False(t, bytes.Equal(tok, tok2))
+
+	// after a second rotation the first token has expired
+	clk.Add(time.Minute)
+	require.False(t, m.Verify(a, tok))
+	require.True(t, m.Verify(a, tok2))
+
+	// all tokens expire when the manager has not rotated for more than two intervals
+	clk.Add(3 * time.Minute)
+	require.False(t, m.Verify(a, tok2))
+}
@@ -0,0 +1,89 @@
+package sim
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+// ErrNoResponse is returned by the request handlers of adversaries that do not respond, so that
+// the requests they receive time out.
+var ErrNoResponse = errors.New("adversary does not respond")
+
+// The handlers below model malicious nodes. They are registered with AddRequestHandler like any
+// handler, in place of or around the handler of a Server, so that the mitigations of a protocol
+// can be evaluated against attackers without writing attacker code for each simulation.
+
+// BogusCloserHandler returns a request handler that answers all requests with nodes, whatever
+// their target. The nodes are usually made up and unreachable, wasting the requests that queries
+// send to them.
+func BogusCloserHandler[K kad.Key[K], A kad.Address[A]](nodes []kad.NodeInfo[K, A]) endpoint.RequestHandlerFn[K] {
+	return func(ctx context.Context, rpeer kad.NodeID[K], req kad.Message) (kad.Message, error) {
+		return NewResponse(nodes), nil
+	}
+}
+
+// EclipseHandler returns a request handler that eclipses the key target: requests for a key
+// sharing at least prefix bits with target are answered with the colluding attackers closest to
+// the requested key, hiding the honest nodes near target. Other requests are passed to honest, so
+// that the attacker is hard to tell apart from an honest node, or answered with the attackers if
+// honest is nil.
+func EclipseHandler[K kad.Key[K], A kad.Address[A]](target K, prefix int, attackers []kad.NodeInfo[K, A],
+	honest endpoint.RequestHandlerFn[K],
+) endpoint.RequestHandlerFn[K] {
+	return func(ctx context.Context, rpeer kad.NodeID[K], req kad.Message) (kad.Message, error) {
+		r, ok := req.(interface{ Target() K })
+		if !ok {
+			return nil, ErrUnknownMessageFormat
+		}
+		requested := r.Target()
+		if honest != nil && requested.CommonPrefixLength(target) < prefix {
+			return honest(ctx, rpeer, req)
+		}
+
+		nodes := make([]kad.NodeInfo[K, A], 0, len(attackers))
+		for _, a := range attackers {
+			// never include the requester in the closer nodes it is sent
+			if !key.Equal(a.ID().Key(), rpeer.Key()) {
+				nodes = append(nodes, a)
+			}
+		}
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return key.DistanceCmp(requested, nodes[i].ID().Key(), nodes[j].ID().Key()) < 0
+		})
+		return NewResponse(nodes), nil
+	}
+}
+
+// DelayHandler returns a request handler that answers requests as h does, but sends its responses
+// only after delay. A delay just under the timeout of the requester keeps its requests in flight
+// for as long as possible without them failing.
+func DelayHandler[K kad.Key[K]](h endpoint.RequestHandlerFn[K], delay time.Duration) endpoint.RequestHandlerFn[K] {
+	return func(ctx context.Context, rpeer kad.NodeID[K], req kad.Message) (kad.Message, error) {
+		resp, err := h(ctx, rpeer, req)
+		if err != nil {
+			return nil, err
+		}
+		return &delayedResponse{Message: resp, delay: delay}, nil
+	}
+}
+
+// SilentHandler returns a request handler that never responds. The requests are delivered, as if
+// the dial succeeded, and time out.
+func SilentHandler[K kad.Key[K]]() endpoint.RequestHandlerFn[K] {
+	return func(ctx context.Context, rpeer kad.NodeID[K], req kad.Message) (kad.Message, error) {
+		return nil, ErrNoResponse
+	}
+}
+
+// delayedResponse is returned by the handlers of DelayHandler to let the Endpoint know when to
+// send the response it wraps.
+type delayedResponse struct {
+	kad.Message
+	delay time.Duration
+}
@@ -0,0 +1,102 @@
+package sim
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+func TestAdversaries(t *testing.T) {
+	ctx := context.Background()
+	protoID := address.ProtocolID("/test/proto")
+
+	infos := make([]kad.NodeInfo[key.Key8, net.IP], 8)
+	for i := range infos {
+		infos[i] = kadtest.NewInfo[key.Key8, net.IP](kadtest.NewID(key.Key8(0x80+i)), nil)
+	}
+	honest := func(ctx context.Context, id kad.NodeID[key.Key8], req kad.Message) (kad.Message, error) {
+		return NewResponse(infos[:1]), nil
+	}
+
+	type result struct {
+		resp kad.Response[key.Key8, net.IP]
+		err  error
+		at   time.Duration
+	}
+	// request sends a request for target from node 0 to node 1, which handles it with h, and
+	// returns the result reported to the response handler
+	request := func(h endpoint.RequestHandlerFn[key.Key8], target key.Key8) result {
+		clk := clock.NewMock()
+		start := clk.Now()
+		router := NewRouter[key.Key8, net.IP]()
+		sim := NewLiteSimulator(clk)
+		endpoints := make([]*Endpoint[key.Key8, net.IP], 2)
+		for i := range endpoints {
+			sched := event.NewSimpleScheduler(clk)
+			endpoints[i] = NewEndpoint[key.Key8, net.IP](kadtest.NewID(key.Key8(i)), sched, router)
+			sim.Add(sched)
+		}
+		require.NoError(t, endpoints[1].AddRequestHandler(protoID, &Message[key.Key8, net.IP]{}, h))
+		require.NoError(t, endpoints[0].MaybeAddToPeerstore(ctx,
+			kadtest.NewInfo[key.Key8, net.IP](endpoints[1].self.(*kadtest.ID[key.Key8]), nil), time.Hour))
+
+		var results []result
+		err := endpoints[0].SendRequestHandleResponse(ctx, protoID, endpoints[1].self, NewRequest[key.Key8, net.IP](target),
+			&Message[key.Key8, net.IP]{}, time.Second, func(ctx context.Context, resp kad.Response[key.Key8, net.IP], err error) {
+				results = append(results, result{resp: resp, err: err, at: clk.Since(start)})
+			})
+		require.NoError(t, err)
+		sim.Run(ctx)
+		require.Len(t, results, 1)
+		return results[0]
+	}
+
+	t.Run("bogus", func(t *testing.T) {
+		res := request(BogusCloserHandler(infos[2:4]), key.Key8(0))
+		require.NoError(t, res.err)
+		require.Equal(t, infos[2:4], res.resp.CloserNodes())
+	})
+
+	t.Run("eclipse", func(t *testing.T) {
+		h := EclipseHandler(key.Key8(0x84), 4, infos, honest)
+		// requests near the eclipsed key are answered with the closest attackers
+		res := request(h, key.Key8(0x85))
+		require.NoError(t, res.err)
+		nodes := res.resp.CloserNodes()
+		require.Len(t, nodes, len(infos))
+		require.Equal(t, infos[5], nodes[0])
+		require.Equal(t, infos[4], nodes[1])
+
+		// other requests are answered honestly
+		res = request(h, key.Key8(0x05))
+		require.NoError(t, res.err)
+		require.Equal(t, infos[:1], res.resp.CloserNodes())
+	})
+
+	t.Run("delay", func(t *testing.T) {
+		res := request(DelayHandler(honest, time.Second-time.Millisecond), key.Key8(0))
+		require.NoError(t, res.err)
+		require.Equal(t, infos[:1], res.resp.CloserNodes())
+		require.Equal(t, time.Second-time.Millisecond, res.at)
+
+		res = request(DelayHandler(honest, 2*time.Second), key.Key8(0))
+		require.ErrorIs(t, res.err, endpoint.ErrTimeout)
+	})
+
+	t.Run("silent", func(t *testing.T) {
+		res := request(SilentHandler[key.Key8](), key.Key8(0))
+		require.ErrorIs(t, res.err, endpoint.ErrTimeout)
+		require.Equal(t, time.Second, res.at)
+	})
+}
@@ -0,0 +1,139 @@
+package sim
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/binary"
+	"io"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+// Identity is the keypair with which an Endpoint authenticates the messages it sends, modelling
+// the authenticated channels of secure transports.
+type Identity struct {
+	priv ed25519.PrivateKey
+}
+
+// NewIdentity generates a new identity using entropy from rand, or crypto/rand if rand is nil.
+func NewIdentity(rand io.Reader) (*Identity, error) {
+	_, priv, err := ed25519.GenerateKey(rand)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{priv: priv}, nil
+}
+
+// PublicKey returns the public key against which the messages signed by the identity are verified.
+func (i *Identity) PublicKey() ed25519.PublicKey {
+	return i.priv.Public().(ed25519.PublicKey)
+}
+
+// sign returns the signature binding a message of stream sid to its sender and recipient.
+func (i *Identity) sign(from, to string, protoID address.ProtocolID, sid endpoint.StreamID) []byte {
+	return ed25519.Sign(i.priv, signedPayload(from, to, protoID, sid))
+}
+
+// signedPayload returns the bytes signed for a message of stream sid sent from from to to.
+func signedPayload(from, to string, protoID address.ProtocolID, sid endpoint.StreamID) []byte {
+	b := make([]byte, 0, len(from)+len(to)+len(protoID)+3*binary.MaxVarintLen64)
+	for _, s := range []string{from, to, string(protoID)} {
+		b = binary.AppendUvarint(b, uint64(len(s)))
+		b = append(b, s...)
+	}
+	return binary.AppendUvarint(b, uint64(sid))
+}
+
+// Authenticated is the envelope of a message sent by an Endpoint that has an Identity. The
+// Endpoint receiving it verifies Signature against the public key registered with the Router
+// for the sender before handling Message.
+type Authenticated struct {
+	Message   kad.Message
+	Signature []byte
+}
+
+// SetPeerKey registers the public key against which the messages sent by id are verified.
+func (r *Router[K, A]) SetPeerKey(id kad.NodeID[K], pub ed25519.PublicKey) {
+	r.nodes[r.indexOf(id)].key = pub
+}
+
+// verify reports whether sig was made by the key registered for from over a message of stream
+// sid sent to to.
+func (r *Router[K, A]) verify(from, to kad.NodeID[K], protoID address.ProtocolID, sid endpoint.StreamID, sig []byte) bool {
+	i, ok := r.lookup(from.String())
+	if !ok || r.nodes[i].key == nil {
+		return false
+	}
+	pub := r.nodes[i].key
+	return ed25519.Verify(pub, signedPayload(from.String(), to.String(), protoID, sid), sig)
+}
+
+// Impersonate sends msg to to as if it was sent by as on stream sid, signed with signer, so
+// that tests can verify that impersonation attempts are rejected. A nil signer sends msg
+// unsigned. A zero sid starts a new stream.
+func (r *Router[K, A]) Impersonate(ctx context.Context, as, to kad.NodeID[K], protoID address.ProtocolID,
+	sid endpoint.StreamID, msg kad.Message, signer *Identity,
+) (endpoint.StreamID, error) {
+	if sid == 0 {
+		sid = r.NewStreamID()
+	}
+	if signer != nil {
+		msg = &Authenticated{
+			Message:   msg,
+			Signature: signer.sign(as.String(), to.String(), protoID, sid),
+		}
+	}
+	return r.SendMessage(ctx, as, to, protoID, sid, msg)
+}
+
+// SetIdentity sets the identity with which the endpoint signs the messages it sends and
+// registers its public key with the router. A nil identity sends messages unsigned.
+func (e *Endpoint[K, A]) SetIdentity(id *Identity) {
+	e.identity = id
+	if id != nil {
+		e.router.SetPeerKey(e.self, id.PublicKey())
+	}
+}
+
+// SetRequireAuthentication sets whether the endpoint rejects messages that are not signed by
+// their sender. Messages with an invalid signature are always rejected.
+func (e *Endpoint[K, A]) SetRequireAuthentication(require bool) {
+	e.requireAuth = require
+}
+
+// sendMessage sends msg to id on stream sid, signed with the identity of the endpoint if it
+// has one.
+func (e *Endpoint[K, A]) sendMessage(ctx context.Context, id kad.NodeID[K], protoID address.ProtocolID,
+	sid endpoint.StreamID, msg kad.Message,
+) (endpoint.StreamID, error) {
+	if e.identity == nil {
+		return e.router.SendMessage(ctx, e.self, id, protoID, sid, msg)
+	}
+	if sid == 0 {
+		sid = e.router.NewStreamID()
+	}
+	msg = &Authenticated{
+		Message:   msg,
+		Signature: e.identity.sign(e.self.String(), id.String(), protoID, sid),
+	}
+	return e.router.SendMessage(ctx, e.self, id, protoID, sid, msg)
+}
+
+// authenticate returns the message received from id on stream sid, removing its envelope, or
+// ErrUnauthenticated if its signature is invalid or it is unsigned and the endpoint requires
+// authentication.
+func (e *Endpoint[K, A]) authenticate(id kad.NodeID[K], protoID address.ProtocolID, sid endpoint.StreamID, msg kad.Message) (kad.Message, error) {
+	am, ok := msg.(*Authenticated)
+	if !ok {
+		if e.requireAuth {
+			return nil, ErrUnauthenticated
+		}
+		return msg, nil
+	}
+	if !e.router.verify(id, e.self, protoID, sid, am.Signature) {
+		return nil, ErrUnauthenticated
+	}
+	return am.Message, nil
+}
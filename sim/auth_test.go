@@ -0,0 +1,151 @@
+package sim
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+// newAuthenticatedEndpoints returns n endpoints with their own identity that require the
+// messages they receive to be authenticated, and their schedulers.
+func newAuthenticatedEndpoints(t *testing.T, clk clock.Clock, n int) ([]*Endpoint[key.Key256, net.IP], []event.AwareScheduler) {
+	t.Helper()
+	router := NewRouter[key.Key256, net.IP]()
+
+	eps := make([]*Endpoint[key.Key256, net.IP], n)
+	scheds := make([]event.AwareScheduler, n)
+	for i := range eps {
+		id := kadtest.NewID(kadtest.Key256WithLeadingBytes([]byte{byte(i)}))
+		scheds[i] = event.NewSimpleScheduler(clk)
+		eps[i] = NewEndpoint[key.Key256, net.IP](id, scheds[i], router)
+
+		ident, err := NewIdentity(nil)
+		require.NoError(t, err)
+		eps[i].SetIdentity(ident)
+		eps[i].SetRequireAuthentication(true)
+	}
+	for i := range eps {
+		for j := range eps {
+			if i != j {
+				info := kadtest.NewInfo[key.Key256, net.IP](eps[j].self.(*kadtest.ID[key.Key256]), nil)
+				require.NoError(t, eps[i].MaybeAddToPeerstore(context.Background(), info, peerstoreTTL))
+			}
+		}
+	}
+	return eps, scheds
+}
+
+func runAll(ctx context.Context, scheds []event.AwareScheduler) {
+	for progress := true; progress; {
+		progress = false
+		for _, s := range scheds {
+			if s.RunOne(ctx) {
+				progress = true
+			}
+		}
+	}
+}
+
+func TestAuthenticatedRequest(t *testing.T) {
+	ctx := context.Background()
+	eps, scheds := newAuthenticatedEndpoints(t, clock.NewMock(), 2)
+
+	var requester kad.NodeID[key.Key256]
+	err := eps[1].AddRequestHandler(protoID, nil, func(ctx context.Context, id kad.NodeID[key.Key256], req kad.Message) (kad.Message, error) {
+		requester = id
+		return NewResponse[key.Key256, net.IP](nil), nil
+	})
+	require.NoError(t, err)
+
+	var respErr error
+	responded := false
+	err = eps[0].SendRequestHandleResponse(ctx, protoID, eps[1].self, nil, nil, time.Second,
+		func(ctx context.Context, resp kad.Response[key.Key256, net.IP], err error) {
+			responded = true
+			respErr = err
+		})
+	require.NoError(t, err)
+	runAll(ctx, scheds)
+
+	// the envelope is removed before messages reach handlers
+	require.True(t, responded)
+	require.NoError(t, respErr)
+	require.Equal(t, eps[0].self, requester)
+}
+
+func TestImpersonatedRequestRejected(t *testing.T) {
+	ctx := context.Background()
+	eps, scheds := newAuthenticatedEndpoints(t, clock.NewMock(), 3)
+	victim, server, attacker := eps[0], eps[1], eps[2]
+
+	handled := 0
+	err := server.AddRequestHandler(protoID, nil, func(ctx context.Context, id kad.NodeID[key.Key256], req kad.Message) (kad.Message, error) {
+		handled++
+		return NewResponse[key.Key256, net.IP](nil), nil
+	})
+	require.NoError(t, err)
+
+	// a request claiming to come from the victim, signed by the attacker, is rejected
+	_, err = server.router.Impersonate(ctx, victim.self, server.self, protoID, 0, NewRequest[key.Key256, net.IP](server.Key()), attacker.identity)
+	require.NoError(t, err)
+	runAll(ctx, scheds)
+	require.Equal(t, 0, handled)
+
+	// so is an unsigned one
+	_, err = server.router.Impersonate(ctx, victim.self, server.self, protoID, 0, NewRequest[key.Key256, net.IP](server.Key()), nil)
+	require.NoError(t, err)
+	runAll(ctx, scheds)
+	require.Equal(t, 0, handled)
+
+	// a request signed by its actual sender is handled
+	_, err = server.router.Impersonate(ctx, attacker.self, server.self, protoID, 0, NewRequest[key.Key256, net.IP](server.Key()), attacker.identity)
+	require.NoError(t, err)
+	runAll(ctx, scheds)
+	require.Equal(t, 1, handled)
+}
+
+func TestImpersonatedResponseRejected(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	eps, scheds := newAuthenticatedEndpoints(t, clk, 3)
+	client, server, attacker := eps[0], eps[1], eps[2]
+
+	// the server does not answer, giving the attacker time to answer in its place
+	err := server.AddRequestHandler(protoID, nil, func(ctx context.Context, id kad.NodeID[key.Key256], req kad.Message) (kad.Message, error) {
+		return nil, endpoint.ErrTimeout
+	})
+	require.NoError(t, err)
+
+	var respErr error
+	responded := false
+	sid, err := client.SendPipelinedRequest(ctx, protoID, server.self, nil, nil, time.Second,
+		func(ctx context.Context, resp kad.Response[key.Key256, net.IP], err error) {
+			responded = true
+			respErr = err
+		})
+	require.NoError(t, err)
+	runAll(ctx, scheds)
+
+	// a response on the stream of the request, claiming to come from the server, is rejected
+	poisoned := NewResponse([]kad.NodeInfo[key.Key256, net.IP]{kadtest.NewInfo[key.Key256, net.IP](attacker.self.(*kadtest.ID[key.Key256]), nil)})
+	_, err = client.router.Impersonate(ctx, server.self, client.self, protoID, sid, poisoned, attacker.identity)
+	require.NoError(t, err)
+	runAll(ctx, scheds)
+	require.False(t, responded)
+
+	// the request times out instead
+	clk.Add(time.Second)
+	runAll(ctx, scheds)
+	require.True(t, responded)
+	require.ErrorIs(t, respErr, endpoint.ErrTimeout)
+}
@@ -0,0 +1,104 @@
+package sim
+
+import (
+	"time"
+
+	"github.com/plprobelab/go-kademlia/kad"
+)
+
+// Bandwidth is the capacity of the network connection of a node, in bytes per second. A zero
+// capacity is unlimited.
+type Bandwidth struct {
+	Up   int64 // the capacity for the messages the node sends
+	Down int64 // the capacity for the messages the node receives
+}
+
+// MessageSizeFunc returns the size in bytes of a message once serialized.
+type MessageSizeFunc func(kad.Message) int
+
+// Estimated sizes used by EstimateMessageSize.
+const (
+	messageHeaderSize = 16 // framing, message type and stream id
+	addressSize       = 32 // a multiaddress or an IP address and port
+	signatureSize     = 64 // an ed25519 signature
+)
+
+// EstimateMessageSize returns the size of a message as reported by its Size method, such as the
+// size of a protobuf message, or else an estimate from the keys and node records it holds.
+func EstimateMessageSize[K kad.Key[K], A kad.Address[A]](msg kad.Message) int {
+	switch m := msg.(type) {
+	case interface{ Size() int }:
+		return m.Size()
+	case *Authenticated:
+		return EstimateMessageSize[K, A](m.Message) + signatureSize
+	case *pushedMessage:
+		return EstimateMessageSize[K, A](m.Message)
+	}
+
+	size := messageHeaderSize
+	if req, ok := msg.(kad.Request[K, A]); ok {
+		size += keySize(req.Target())
+	}
+	if resp, ok := msg.(kad.Response[K, A]); ok {
+		for _, n := range resp.CloserNodes() {
+			size += keySize(n.ID().Key()) + addressSize*len(n.Addresses())
+		}
+	}
+	return size
+}
+
+func keySize[K kad.Key[K]](k K) int {
+	return (k.BitLen() + 7) / 8
+}
+
+// SetBandwidth sets the bandwidth of the node id. Nodes without a bandwidth have an unlimited
+// capacity. The transfer of a message takes its size divided by the lowest capacity of its sender
+// and recipient, and to share their capacity the transfers of a node happen one after the other:
+// a message waits for the messages sent by its sender and received by its recipient before it.
+func (r *Router[K, A]) SetBandwidth(id kad.NodeID[K], b Bandwidth) {
+	n := &r.nodes[r.indexOf(id)]
+	if n.bw == (Bandwidth{}) && b != (Bandwidth{}) {
+		r.limited++
+	} else if n.bw != (Bandwidth{}) && b == (Bandwidth{}) {
+		r.limited--
+	}
+	n.bw = b
+}
+
+// SetMessageSize sets the function giving the size of messages to compute their transfer time. It
+// defaults to EstimateMessageSize.
+func (r *Router[K, A]) SetMessageSize(f MessageSizeFunc) {
+	r.msgSize = f
+}
+
+// transfer reserves the capacity for a message of msg sent by from to to at now, and returns the
+// time its transfer ends.
+func (r *Router[K, A]) transfer(from, to int32, msg kad.Message, now time.Time) time.Time {
+	sender, recipient := &r.nodes[from], &r.nodes[to]
+	up := sender.bw.Up
+	down := recipient.bw.Down
+	capacity := up
+	if capacity == 0 || (down != 0 && down < capacity) {
+		capacity = down
+	}
+	if capacity == 0 {
+		return now
+	}
+
+	start := now
+	if t := sender.upFree; t.After(start) {
+		start = t
+	}
+	if t := recipient.downFree; t.After(start) {
+		start = t
+	}
+	size := r.msgSize(msg)
+	end := start.Add(time.Duration(int64(size) * int64(time.Second) / capacity))
+	if up != 0 {
+		sender.upFree = end
+	}
+	if down != 0 {
+		recipient.downFree = end
+	}
+	return end
+}
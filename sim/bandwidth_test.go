@@ -0,0 +1,84 @@
+package sim
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+)
+
+type sizedMessage int
+
+func (m sizedMessage) Size() int { return int(m) }
+
+func TestEstimateMessageSize(t *testing.T) {
+	estimate := EstimateMessageSize[key.Key8, net.IP]
+	require.Equal(t, messageHeaderSize+1, estimate(NewRequest[key.Key8, net.IP](key.Key8(0))))
+
+	resp := NewResponse([]kad.NodeInfo[key.Key8, net.IP]{
+		kadtest.NewInfo[key.Key8, net.IP](kadtest.NewID(key.Key8(1)), nil),
+		kadtest.NewInfo[key.Key8, net.IP](kadtest.NewID(key.Key8(2)), []net.IP{net.ParseIP("127.0.0.1")}),
+	})
+	// sim messages are both requests and responses, and carry a target
+	require.Equal(t, messageHeaderSize+1+2+addressSize, estimate(resp))
+
+	require.Equal(t, 42, estimate(sizedMessage(42)))
+	require.Equal(t, 42+signatureSize, estimate(&Authenticated{Message: sizedMessage(42)}))
+}
+
+func TestRouterBandwidth(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	router := NewRouter[key.Key8, net.IP]()
+	router.SetMessageSize(func(msg kad.Message) int { return int(msg.(sizedMessage)) })
+
+	ids := make([]kad.NodeID[key.Key8], 4)
+	scheds := make([]*event.SimpleScheduler, len(ids))
+	for i := range ids {
+		ids[i] = kadtest.NewID(key.Key8(i))
+		scheds[i] = event.NewSimpleScheduler(clk)
+		NewEndpoint[key.Key8, net.IP](ids[i], scheds[i], router)
+	}
+	router.SetBandwidth(ids[0], Bandwidth{Up: 1000})
+	router.SetBandwidth(ids[3], Bandwidth{Down: 500})
+	protoID := address.ProtocolID("/test/proto")
+
+	send := func(from, to int, size int) {
+		_, err := router.SendMessage(ctx, ids[from], ids[to], protoID, 0, sizedMessage(size))
+		require.NoError(t, err)
+	}
+
+	// the transfers of node 0 share its capacity and end one after the other
+	send(0, 1, 100)
+	send(0, 2, 100)
+	require.Equal(t, clk.Now().Add(100*time.Millisecond), scheds[1].NextActionTime(ctx))
+	require.Equal(t, clk.Now().Add(200*time.Millisecond), scheds[2].NextActionTime(ctx))
+
+	// unlimited nodes deliver immediately
+	send(1, 2, 100)
+	require.True(t, scheds[2].RunOne(ctx))
+
+	// the lowest capacity of the sender and recipient limits the transfer
+	send(1, 3, 100)
+	send(2, 3, 100)
+	require.Equal(t, clk.Now().Add(200*time.Millisecond), scheds[3].NextActionTime(ctx))
+	clk.Add(200 * time.Millisecond)
+	require.True(t, scheds[3].RunOne(ctx))
+	require.False(t, scheds[3].RunOne(ctx))
+	require.Equal(t, clk.Now().Add(200*time.Millisecond), scheds[3].NextActionTime(ctx))
+
+	// a node that was idle transfers from the current time
+	clk.Add(time.Second)
+	send(0, 1, 100)
+	event.RunAll(ctx, scheds[1])
+	require.Equal(t, clk.Now().Add(100*time.Millisecond), scheds[1].NextActionTime(ctx))
+}
@@ -0,0 +1,182 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+)
+
+// SessionLength draws the length of a session, such as the time a node stays online, from a
+// distribution using rng.
+type SessionLength func(rng *rand.Rand) time.Duration
+
+// ConstantSession returns a SessionLength in which all sessions last d.
+func ConstantSession(d time.Duration) SessionLength {
+	return func(*rand.Rand) time.Duration { return d }
+}
+
+// UniformSession returns a SessionLength in which sessions last between min and max.
+func UniformSession(min, max time.Duration) SessionLength {
+	return func(rng *rand.Rand) time.Duration {
+		if max <= min {
+			return min
+		}
+		return min + time.Duration(rng.Int63n(int64(max-min)))
+	}
+}
+
+// ExponentialSession returns a SessionLength in which the lengths of sessions are exponentially
+// distributed with the given mean, as when nodes leave at a constant rate.
+func ExponentialSession(mean time.Duration) SessionLength {
+	return func(rng *rand.Rand) time.Duration {
+		return time.Duration(rng.ExpFloat64() * float64(mean))
+	}
+}
+
+// WeibullSession returns a SessionLength in which the lengths of sessions follow a Weibull
+// distribution with the given scale and shape. Measurements of peer-to-peer networks find shapes
+// below 1, where most sessions are short but some nodes stay online for a long time.
+func WeibullSession(scale time.Duration, shape float64) SessionLength {
+	return func(rng *rand.Rand) time.Duration {
+		u := 1 - rng.Float64() // in (0, 1]
+		return time.Duration(float64(scale) * math.Pow(-math.Log(u), 1/shape))
+	}
+}
+
+// SpawnFunc creates a new node, adds it to the simulation and returns its id.
+type SpawnFunc[K kad.Key[K]] func(ctx context.Context) (kad.NodeID[K], error)
+
+// KillFunc is called when a node is killed, after it was removed from the router, for example to
+// remove its scheduler from the simulator.
+type KillFunc[K kad.Key[K]] func(ctx context.Context, id kad.NodeID[K])
+
+// ChurnConfig specifies optional configuration for a Churn.
+type ChurnConfig[K kad.Key[K]] struct {
+	Session     SessionLength // the time nodes stay online
+	Replacement SessionLength // the time between a node being killed and its replacement spawning
+	Seed        int64         // seeds the generator drawing session lengths, for reproducible simulations
+	OnKill      KillFunc[K]   // called when a node is killed, may be nil
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *ChurnConfig[K]) Validate() error {
+	if cfg.Session == nil {
+		return &kaderr.ConfigurationError{
+			Component: "ChurnConfig",
+			Err:       fmt.Errorf("session length must not be nil"),
+		}
+	}
+	if cfg.Replacement == nil {
+		return &kaderr.ConfigurationError{
+			Component: "ChurnConfig",
+			Err:       fmt.Errorf("replacement delay must not be nil"),
+		}
+	}
+	return nil
+}
+
+// DefaultChurnConfig returns the default configuration options for a Churn.
+// Options may be overridden before passing to NewChurn.
+func DefaultChurnConfig[K kad.Key[K]]() *ChurnConfig[K] {
+	return &ChurnConfig[K]{
+		Session:     ExponentialSession(time.Hour),
+		Replacement: ConstantSession(0), // replace nodes immediately
+	}
+}
+
+// ChurnStats counts the nodes a Churn killed and spawned.
+type ChurnStats struct {
+	Killed  uint64 // the number of nodes killed
+	Spawned uint64 // the number of replacement nodes spawned
+	Failed  uint64 // the number of replacement nodes that failed to spawn
+}
+
+// Churn kills the nodes of a simulation at the end of their session, removing them from the
+// router, and spawns a replacement for each node killed, so that the size of the network stays
+// stable while its nodes change. Sessions are timed by the clock of the scheduler the Churn runs
+// on, usually a scheduler added to the simulator for this purpose.
+type Churn[K kad.Key[K], A kad.Address[A]] struct {
+	cfg    ChurnConfig[K]
+	router *Router[K, A]
+	sched  event.Scheduler
+	spawn  SpawnFunc[K]
+	rng    *rand.Rand
+
+	sessions map[string]event.PlannedAction // the planned end of the session of each online node
+	stats    ChurnStats
+}
+
+// NewChurn creates a new Churn running on sched, spawning replacement nodes with spawn.
+func NewChurn[K kad.Key[K], A kad.Address[A]](router *Router[K, A], sched event.Scheduler, spawn SpawnFunc[K],
+	cfg *ChurnConfig[K],
+) (*Churn[K, A], error) {
+	if cfg == nil {
+		cfg = DefaultChurnConfig[K]()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Churn[K, A]{
+		cfg:      *cfg,
+		router:   router,
+		sched:    sched,
+		spawn:    spawn,
+		rng:      rand.New(rand.NewSource(cfg.Seed)),
+		sessions: make(map[string]event.PlannedAction),
+	}, nil
+}
+
+// Track starts the session of a node that is online, after which it is killed and replaced.
+func (c *Churn[K, A]) Track(ctx context.Context, id kad.NodeID[K]) {
+	if pa, ok := c.sessions[id.String()]; ok {
+		c.sched.RemovePlannedAction(ctx, pa)
+	}
+	c.sessions[id.String()] = event.ScheduleActionIn(ctx, c.sched, c.cfg.Session(c.rng),
+		event.BasicAction(func(ctx context.Context) {
+			c.kill(ctx, id)
+		}))
+}
+
+// Untrack ends tracking a node, which then stays online.
+func (c *Churn[K, A]) Untrack(ctx context.Context, id kad.NodeID[K]) {
+	if pa, ok := c.sessions[id.String()]; ok {
+		c.sched.RemovePlannedAction(ctx, pa)
+		delete(c.sessions, id.String())
+	}
+}
+
+// Online returns the number of tracked nodes that are online.
+func (c *Churn[K, A]) Online() int {
+	return len(c.sessions)
+}
+
+// Stats returns the number of nodes killed and spawned.
+func (c *Churn[K, A]) Stats() ChurnStats {
+	return c.stats
+}
+
+// kill removes a node from the simulation and plans the spawning of its replacement.
+func (c *Churn[K, A]) kill(ctx context.Context, id kad.NodeID[K]) {
+	delete(c.sessions, id.String())
+	c.router.RemovePeer(id)
+	c.stats.Killed++
+	if c.cfg.OnKill != nil {
+		c.cfg.OnKill(ctx, id)
+	}
+
+	event.ScheduleActionIn(ctx, c.sched, c.cfg.Replacement(c.rng), event.BasicAction(func(ctx context.Context) {
+		id, err := c.spawn(ctx)
+		if err != nil {
+			c.stats.Failed++
+			return
+		}
+		c.stats.Spawned++
+		c.Track(ctx, id)
+	}))
+}
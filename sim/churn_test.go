@@ -0,0 +1,117 @@
+package sim
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+func TestSessionLengths(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	mean := func(s SessionLength) time.Duration {
+		var total time.Duration
+		for i := 0; i < 10000; i++ {
+			d := s(rng)
+			require.GreaterOrEqual(t, d, time.Duration(0))
+			total += d
+		}
+		return total / 10000
+	}
+
+	require.Equal(t, time.Minute, mean(ConstantSession(time.Minute)))
+	require.InDelta(t, float64(90*time.Second), float64(mean(UniformSession(time.Minute, 2*time.Minute))), float64(5*time.Second))
+	require.InDelta(t, float64(time.Hour), float64(mean(ExponentialSession(time.Hour))), float64(5*time.Minute))
+	// a Weibull distribution of shape 1 is exponential
+	require.InDelta(t, float64(time.Hour), float64(mean(WeibullSession(time.Hour, 1))), float64(5*time.Minute))
+}
+
+func TestChurnConfigValidate(t *testing.T) {
+	cfg := DefaultChurnConfig[key.Key8]()
+	require.NoError(t, cfg.Validate())
+
+	cfg.Session = nil
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultChurnConfig[key.Key8]()
+	cfg.Replacement = nil
+	require.Error(t, cfg.Validate())
+}
+
+func TestChurn(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	router := NewRouter[key.Key8, net.IP]()
+	sim := NewLiteSimulator(clk)
+
+	scheds := make(map[string]*event.SimpleScheduler)
+	next := 0
+	spawn := func(ctx context.Context) (kad.NodeID[key.Key8], error) {
+		id := kadtest.NewID(key.Key8(next))
+		next++
+		sched := event.NewSimpleScheduler(clk)
+		NewEndpoint[key.Key8, net.IP](id, sched, router)
+		sim.Add(sched)
+		scheds[id.String()] = sched
+		return id, nil
+	}
+
+	churnSched := event.NewSimpleScheduler(clk)
+	sim.Add(churnSched)
+	cfg := DefaultChurnConfig[key.Key8]()
+	cfg.Session = ConstantSession(10 * time.Minute)
+	cfg.Replacement = ConstantSession(time.Minute)
+	var killed []kad.NodeID[key.Key8]
+	cfg.OnKill = func(ctx context.Context, id kad.NodeID[key.Key8]) {
+		killed = append(killed, id)
+		sim.Remove(scheds[id.String()])
+	}
+	churn, err := NewChurn[key.Key8, net.IP](router, churnSched, spawn, cfg)
+	require.NoError(t, err)
+
+	var ids []kad.NodeID[key.Key8]
+	for i := 0; i < 10; i++ {
+		id, err := spawn(ctx)
+		require.NoError(t, err)
+		ids = append(ids, id)
+		churn.Track(ctx, id)
+	}
+	// a node that is not tracked stays online
+	churn.Untrack(ctx, ids[9])
+	require.Equal(t, 9, churn.Online())
+
+	// runUntil runs the actions of the churn that are due until t, the nodes have none
+	runUntil := func(t time.Time) {
+		for !churnSched.NextActionTime(ctx).After(t) && sim.Step(ctx) {
+		}
+	}
+
+	start := clk.Now()
+	runUntil(start.Add(10 * time.Minute))
+	require.Len(t, killed, 9)
+	require.Zero(t, churn.Online())
+	for _, id := range ids[:9] {
+		_, _, ok := router.peerOf(id.String())
+		require.False(t, ok)
+	}
+	_, _, ok := router.peerOf(ids[9].String())
+	require.True(t, ok)
+
+	// the replacements are spawned a minute later, and killed at the end of their session
+	runUntil(start.Add(11 * time.Minute))
+	require.Equal(t, 9, churn.Online())
+	require.Equal(t, ChurnStats{Killed: 9, Spawned: 9}, churn.Stats())
+	require.Equal(t, 10, router.NumPeers())
+
+	runUntil(start.Add(21 * time.Minute))
+	require.Equal(t, ChurnStats{Killed: 18, Spawned: 9}, churn.Stats())
+}
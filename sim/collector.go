@@ -0,0 +1,299 @@
+package sim
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+// QueryStats describes a query run during a simulation.
+type QueryStats struct {
+	Name      string        `json:"name"`
+	Start     time.Time     `json:"start"`
+	Duration  time.Duration `json:"duration"`  // the time from the start of the query to its end, zero while it runs
+	Responses int           `json:"responses"` // the number of responses the query handled
+	Hops      int           `json:"hops"`      // the length of the longest chain of referrals followed, or leading to success
+	Done      bool          `json:"done"`
+	Succeeded bool          `json:"succeeded"`
+}
+
+// NodeStats describes the load of a node during a simulation.
+type NodeStats struct {
+	ID       string `json:"id"`
+	Sent     int    `json:"sent"`     // the number of messages the node sent
+	Received int    `json:"received"` // the number of messages delivered to the node
+}
+
+// Summary aggregates the statistics of a simulation.
+type Summary struct {
+	Queries     int     `json:"queries"`      // the number of queries that are done
+	Succeeded   int     `json:"succeeded"`    // the number of queries that succeeded
+	SuccessRate float64 `json:"success_rate"` // the share of the queries done that succeeded
+	MeanHops    float64 `json:"mean_hops"`    // the mean hop count of the queries that succeeded
+	Messages    int     `json:"messages"`     // the number of messages sent
+}
+
+// Report holds the statistics collected during a simulation, as exported by Collector.WriteJSON.
+type Report struct {
+	Summary Summary      `json:"summary"`
+	Queries []QueryStats `json:"queries"`
+	Nodes   []NodeStats  `json:"nodes"`
+}
+
+// Collector records statistics during a simulation: the hop count, responses and outcome of each
+// query it tracks, and the messages each node sends and receives. Add it to the router of the
+// simulation with Router.AddObserver to count messages, and track queries with TrackQuery. The
+// statistics can be exported to JSON or CSV for plotting.
+type Collector[K kad.Key[K], A kad.Address[A]] struct {
+	clk     clock.Clock
+	queries []*QueryRecorder[K, A]
+	nodes   map[string]*NodeStats
+	sent    int
+}
+
+var _ MessageObserver[key.Key256] = (*Collector[key.Key256, net.IP])(nil)
+
+// NewCollector creates a Collector timing queries with clk.
+func NewCollector[K kad.Key[K], A kad.Address[A]](clk clock.Clock) *Collector[K, A] {
+	return &Collector[K, A]{
+		clk:   clk,
+		nodes: make(map[string]*NodeStats),
+	}
+}
+
+func (c *Collector[K, A]) node(id kad.NodeID[K]) *NodeStats {
+	n, ok := c.nodes[id.String()]
+	if !ok {
+		n = &NodeStats{ID: id.String()}
+		c.nodes[id.String()] = n
+	}
+	return n
+}
+
+// MessageSent counts a message sent by from.
+func (c *Collector[K, A]) MessageSent(from, to kad.NodeID[K], msg kad.Message, at time.Time) {
+	c.node(from).Sent++
+	c.sent++
+}
+
+// MessageDelivered counts a message received by to.
+func (c *Collector[K, A]) MessageDelivered(from, to kad.NodeID[K], msg kad.Message, at time.Time) {
+	c.node(to).Received++
+}
+
+// TrackQuery starts recording a query named name, starting now. The functions of the returned
+// QueryRecorder wrap the result and failure handlers of the query.
+func (c *Collector[K, A]) TrackQuery(name string) *QueryRecorder[K, A] {
+	q := &QueryRecorder[K, A]{
+		clk:   c.clk,
+		stats: QueryStats{Name: name, Start: c.clk.Now()},
+		hops:  make(map[string]int),
+
+		referrers: make(map[string]string),
+	}
+	c.queries = append(c.queries, q)
+	return q
+}
+
+// Queries returns the statistics of the tracked queries, in the order they were tracked.
+func (c *Collector[K, A]) Queries() []QueryStats {
+	qs := make([]QueryStats, len(c.queries))
+	for i, q := range c.queries {
+		qs[i] = q.stats
+	}
+	return qs
+}
+
+// Paths returns the paths of the tracked queries, in the order they were tracked.
+func (c *Collector[K, A]) Paths() []QueryPath {
+	ps := make([]QueryPath, len(c.queries))
+	for i, q := range c.queries {
+		ps[i] = QueryPath{Name: q.stats.Name, Steps: q.Path()}
+	}
+	return ps
+}
+
+// Nodes returns the load of the nodes that sent or received messages, ordered by id.
+func (c *Collector[K, A]) Nodes() []NodeStats {
+	ns := make([]NodeStats, 0, len(c.nodes))
+	for _, n := range c.nodes {
+		ns = append(ns, *n)
+	}
+	sort.Slice(ns, func(i, j int) bool { return ns[i].ID < ns[j].ID })
+	return ns
+}
+
+// Summary aggregates the collected statistics.
+func (c *Collector[K, A]) Summary() Summary {
+	s := Summary{Messages: c.sent}
+	var hops int
+	for _, q := range c.queries {
+		if !q.stats.Done {
+			continue
+		}
+		s.Queries++
+		if q.stats.Succeeded {
+			s.Succeeded++
+			hops += q.stats.Hops
+		}
+	}
+	if s.Queries > 0 {
+		s.SuccessRate = float64(s.Succeeded) / float64(s.Queries)
+	}
+	if s.Succeeded > 0 {
+		s.MeanHops = float64(hops) / float64(s.Succeeded)
+	}
+	return s
+}
+
+// Report returns all the collected statistics.
+func (c *Collector[K, A]) Report() Report {
+	return Report{
+		Summary: c.Summary(),
+		Queries: c.Queries(),
+		Nodes:   c.Nodes(),
+	}
+}
+
+// WriteJSON writes the Report of the collected statistics to w in JSON.
+func (c *Collector[K, A]) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c.Report())
+}
+
+// WriteQueriesCSV writes the statistics of the tracked queries to w in CSV, one query per row.
+// Durations are written in milliseconds.
+func (c *Collector[K, A]) WriteQueriesCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"name", "start", "duration_ms", "responses", "hops", "done", "succeeded"}); err != nil {
+		return err
+	}
+	for _, q := range c.Queries() {
+		if err := cw.Write([]string{
+			q.Name,
+			q.Start.Format(time.RFC3339Nano),
+			strconv.FormatInt(q.Duration.Milliseconds(), 10),
+			strconv.Itoa(q.Responses),
+			strconv.Itoa(q.Hops),
+			strconv.FormatBool(q.Done),
+			strconv.FormatBool(q.Succeeded),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteNodesCSV writes the load of the nodes to w in CSV, one node per row.
+func (c *Collector[K, A]) WriteNodesCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "sent", "received"}); err != nil {
+		return err
+	}
+	for _, n := range c.Nodes() {
+		if err := cw.Write([]string{n.ID, strconv.Itoa(n.Sent), strconv.Itoa(n.Received)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// QueryRecorder records the progress of a query tracked by a Collector. The nodes the query
+// starts with are at hop 1, and the nodes returned by a node at hop h are at hop h+1.
+type QueryRecorder[K kad.Key[K], A kad.Address[A]] struct {
+	clk       clock.Clock
+	stats     QueryStats
+	hops      map[string]int    // the hop of each node the query heard of
+	referrers map[string]string // the node that returned each node the query heard of
+	path      []PathStep
+}
+
+// PathStep is a response handled by a query, the steps of a query forming the path of the nodes
+// it contacted.
+type PathStep struct {
+	Node     string    `json:"node"`     // the node that responded
+	Referrer string    `json:"referrer"` // the node that returned Node to the query, empty for the nodes the query started with
+	Hop      int       `json:"hop"`
+	Time     time.Time `json:"time"` // the time the response was handled
+}
+
+// HandleResults wraps the function handling the responses of the query, recording each response
+// and the hops of the nodes it returns. The query succeeds when fn stops it. The returned function
+// can be used as the HandleResultsFunc of a simplequery.
+func (q *QueryRecorder[K, A]) HandleResults(fn func(context.Context, kad.NodeID[K], kad.Response[K, A]) (bool, []kad.NodeID[K]),
+) func(context.Context, kad.NodeID[K], kad.Response[K, A]) (bool, []kad.NodeID[K]) {
+	return func(ctx context.Context, id kad.NodeID[K], resp kad.Response[K, A]) (bool, []kad.NodeID[K]) {
+		stop, ids := fn(ctx, id, resp)
+		if q.stats.Done {
+			return stop, ids
+		}
+		q.stats.Responses++
+		hop, ok := q.hops[id.String()]
+		if !ok {
+			hop = 1
+		}
+		if hop > q.stats.Hops {
+			q.stats.Hops = hop
+		}
+		q.path = append(q.path, PathStep{
+			Node:     id.String(),
+			Referrer: q.referrers[id.String()],
+			Hop:      hop,
+			Time:     q.clk.Now(),
+		})
+		for _, n := range ids {
+			if _, ok := q.hops[n.String()]; !ok {
+				q.hops[n.String()] = hop + 1
+				q.referrers[n.String()] = id.String()
+			}
+		}
+		if stop {
+			q.stats.Hops = hop
+			q.end(true)
+		}
+		return stop, ids
+	}
+}
+
+// NotifyFailure wraps the function notified of the failure of the query, recording the failure.
+// A nil fn is allowed. The returned function can be used as the NotifyFailureFunc of a
+// simplequery.
+func (q *QueryRecorder[K, A]) NotifyFailure(fn func(context.Context)) func(context.Context) {
+	return func(ctx context.Context) {
+		if !q.stats.Done {
+			q.end(false)
+		}
+		if fn != nil {
+			fn(ctx)
+		}
+	}
+}
+
+// Path returns the responses the query handled, in the order it handled them.
+func (q *QueryRecorder[K, A]) Path() []PathStep {
+	return append([]PathStep(nil), q.path...)
+}
+
+// Stats returns the statistics of the query.
+func (q *QueryRecorder[K, A]) Stats() QueryStats {
+	return q.stats
+}
+
+func (q *QueryRecorder[K, A]) end(succeeded bool) {
+	q.stats.Done = true
+	q.stats.Succeeded = succeeded
+	q.stats.Duration = q.clk.Since(q.stats.Start)
+}
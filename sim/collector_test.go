@@ -0,0 +1,123 @@
+package sim
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	sq "github.com/plprobelab/go-kademlia/query/simplequery"
+)
+
+func TestCollector(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+
+	infos := make([]kad.NodeInfo[key.Key8, net.IP], 4)
+	for i := range infos {
+		infos[i] = kadtest.NewInfo[key.Key8, net.IP](kadtest.NewID(key.Key8(i)), nil)
+	}
+	cfg := DefaultNetworkConfig[key.Key8]()
+	// the nodes form a chain, each knowing the previous and the next
+	cfg.Topology = func(_ *rand.Rand, n int) [][]int {
+		adj := make([][]int, n)
+		for i := 1; i < n; i++ {
+			connect(adj, i-1, i)
+		}
+		return adj
+	}
+	n, err := NewNetwork(ctx, clk, infos, cfg)
+	require.NoError(t, err)
+	n.Router.SetLatencyModel(ConstantLatency[key.Key8](10 * time.Millisecond))
+
+	c := NewCollector[key.Key8, net.IP](clk)
+	n.Router.AddObserver(c)
+
+	// query runs a query from the first node looking for target
+	query := func(name string, target key.Key8) {
+		rec := c.TrackQuery(name)
+		handle := func(ctx context.Context, id kad.NodeID[key.Key8], resp kad.Response[key.Key8, net.IP]) (bool, []kad.NodeID[key.Key8]) {
+			ids := make([]kad.NodeID[key.Key8], 0, len(resp.CloserNodes()))
+			for _, p := range resp.CloserNodes() {
+				if key.Equal(p.ID().Key(), target) {
+					return true, nil
+				}
+				ids = append(ids, p.ID())
+			}
+			return false, ids
+		}
+		node := n.Nodes[0]
+		_, err := sq.NewSimpleQuery[key.Key8, net.IP](ctx, node.Info.ID(), NewRequest[key.Key8, net.IP](target),
+			sq.WithProtocolID[key.Key8, net.IP](cfg.ProtocolID),
+			sq.WithConcurrency[key.Key8, net.IP](1),
+			sq.WithHandleResultsFunc(sq.HandleResultFn[key.Key8, net.IP](rec.HandleResults(handle))),
+			sq.WithNotifyFailureFunc[key.Key8, net.IP](rec.NotifyFailure(nil)),
+			sq.WithRoutingTable[key.Key8, net.IP](node.RoutingTable),
+			sq.WithEndpoint[key.Key8, net.IP](node.Endpoint),
+			sq.WithScheduler[key.Key8, net.IP](node.Scheduler))
+		require.NoError(t, err)
+		n.Simulator.Run(ctx)
+	}
+
+	// the first node asks the second, which refers it to the third, which returns the target
+	query("found", key.Key8(3))
+	// a missing key is looked for in the whole network
+	query("missing", key.Key8(0x10))
+
+	qs := c.Queries()
+	require.Len(t, qs, 2)
+	require.True(t, qs[0].Done)
+	require.True(t, qs[0].Succeeded)
+	require.Equal(t, 2, qs[0].Hops)
+	require.Equal(t, 2, qs[0].Responses)
+	require.Equal(t, 40*time.Millisecond, qs[0].Duration)
+	require.True(t, qs[1].Done)
+	require.False(t, qs[1].Succeeded)
+	require.Equal(t, 3, qs[1].Hops)
+
+	s := c.Summary()
+	require.Equal(t, 2, s.Queries)
+	require.Equal(t, 1, s.Succeeded)
+	require.Equal(t, 0.5, s.SuccessRate)
+	require.Equal(t, 2.0, s.MeanHops)
+	// each of the five requests is answered
+	require.Equal(t, 10, s.Messages)
+
+	nodes := c.Nodes()
+	require.Len(t, nodes, 4)
+	require.Equal(t, NodeStats{ID: infos[0].ID().String(), Sent: 5, Received: 5}, nodes[0])
+	require.Equal(t, NodeStats{ID: infos[1].ID().String(), Sent: 2, Received: 2}, nodes[1])
+
+	var buf bytes.Buffer
+	require.NoError(t, c.WriteJSON(&buf))
+	var report Report
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+	require.Equal(t, s, report.Summary)
+	require.Equal(t, nodes, report.Nodes)
+	require.Len(t, report.Queries, 2)
+	require.Equal(t, qs[1].Hops, report.Queries[1].Hops)
+
+	buf.Reset()
+	require.NoError(t, c.WriteQueriesCSV(&buf))
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	require.Equal(t, []string{"found", qs[0].Start.Format(time.RFC3339Nano), "40", "2", "2", "true", "true"}, rows[1])
+
+	buf.Reset()
+	require.NoError(t, c.WriteNodesCSV(&buf))
+	rows, err = csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 5)
+	require.Equal(t, []string{"id", "sent", "received"}, rows[0])
+}
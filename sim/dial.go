@@ -0,0 +1,108 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+// DialConfig specifies how long an Endpoint takes to dial its peers, and how often it fails to.
+type DialConfig[K kad.Key[K]] struct {
+	Latency LatencyModel[K] // the time a dial takes, nil for dials completing on the next action
+	Failure float64         // the probability that a dial fails
+	Seed    int64           // seeds the pseudo-random generator deciding which dials fail
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *DialConfig[K]) Validate() error {
+	if cfg.Failure < 0 || cfg.Failure > 1 {
+		return &kaderr.ConfigurationError{
+			Component: "DialConfig",
+			Err:       fmt.Errorf("failure probability must be between 0 and 1"),
+		}
+	}
+	return nil
+}
+
+// dialer dials the peers of an Endpoint asynchronously.
+type dialer[K kad.Key[K]] struct {
+	cfg     DialConfig[K]
+	rng     *rand.Rand
+	pending map[string][]func(context.Context, error) // the functions waiting for each dial in progress
+}
+
+// SetDialConfig makes the endpoint dial its peers asynchronously, each dial completing or failing
+// after some virtual time, so that the behaviour of queries during slow dials can be studied.
+// Requests and messages sent to a peer being dialled are sent once the dial succeeds, and fail
+// with endpoint.ErrCannotConnect if it fails. A nil cfg restores instantaneous dials.
+func (e *Endpoint[K, A]) SetDialConfig(cfg *DialConfig[K]) error {
+	if cfg == nil {
+		e.dialer = nil
+		return nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	e.dialer = &dialer[K]{
+		cfg:     *cfg,
+		rng:     rand.New(rand.NewSource(cfg.Seed)),
+		pending: make(map[string][]func(context.Context, error)),
+	}
+	return nil
+}
+
+// dialsAsync reports whether dialling id takes time: the endpoint has a dial config and id can be
+// connected to but is not connected yet.
+func (e *Endpoint[K, A]) dialsAsync(id kad.NodeID[K]) bool {
+	if e.dialer == nil || e.peerstore.Connectedness(id) != endpoint.CanConnect {
+		return false
+	}
+	return e.router == nil || e.router.reachable(e.self, id)
+}
+
+// dialAsync dials id, or joins the dial of id in progress, and calls fn once the dial is done. fn
+// may be nil. The endpoint must dial asynchronously, see dialsAsync.
+func (e *Endpoint[K, A]) dialAsync(ctx context.Context, id kad.NodeID[K], fn func(context.Context, error)) {
+	d := e.dialer
+	name := id.String()
+	waiting, dialling := d.pending[name]
+	if fn != nil {
+		waiting = append(waiting, fn)
+	}
+	d.pending[name] = waiting
+	if dialling {
+		return
+	}
+
+	done := event.BasicAction(func(ctx context.Context) {
+		waiting := d.pending[name]
+		delete(d.pending, name)
+
+		var err error
+		if d.cfg.Failure > 0 && d.rng.Float64() < d.cfg.Failure {
+			err = endpoint.ErrCannotConnect
+		} else if e.router != nil && !e.router.reachable(e.self, id) {
+			// the peer or the endpoint went down during the dial
+			err = endpoint.ErrCannotConnect
+		}
+		if err != nil {
+			e.events.Emit(&endpoint.EventDialFailed[K]{NodeID: id, Error: err})
+		} else if e.peerstore.Connectedness(id) == endpoint.CanConnect {
+			e.peerstore.SetConnectedness(id, endpoint.Connected)
+			e.events.Emit(&endpoint.EventConnected[K]{NodeID: id})
+		}
+		for _, fn := range waiting {
+			fn(ctx, err)
+		}
+	})
+	if d.cfg.Latency == nil {
+		e.sched.EnqueueAction(ctx, done)
+		return
+	}
+	event.ScheduleActionIn(ctx, e.sched, d.cfg.Latency.Latency(e.self, id), done)
+}
@@ -0,0 +1,109 @@
+package sim
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+func TestDialConfigValidate(t *testing.T) {
+	require.NoError(t, (&DialConfig[key.Key8]{}).Validate())
+	require.NoError(t, (&DialConfig[key.Key8]{Failure: 1}).Validate())
+	require.Error(t, (&DialConfig[key.Key8]{Failure: -0.1}).Validate())
+	require.Error(t, (&DialConfig[key.Key8]{Failure: 1.1}).Validate())
+
+	e := NewEndpoint[key.Key8, net.IP](kadtest.NewID(key.Key8(0)), nil, nil)
+	require.Error(t, e.SetDialConfig(&DialConfig[key.Key8]{Failure: 2}))
+}
+
+func TestAsyncDial(t *testing.T) {
+	ctx := context.Background()
+	protoID := address.ProtocolID("/test/proto")
+
+	// setup creates a client dialling with cfg and the server it sends requests to
+	setup := func(cfg *DialConfig[key.Key8]) (*clock.Mock, *LiteSimulator, *Endpoint[key.Key8, net.IP], kad.NodeID[key.Key8]) {
+		clk := clock.NewMock()
+		router := NewRouter[key.Key8, net.IP]()
+		sim := NewLiteSimulator(clk)
+		endpoints := make([]*Endpoint[key.Key8, net.IP], 2)
+		for i := range endpoints {
+			sched := event.NewSimpleScheduler(clk)
+			endpoints[i] = NewEndpoint[key.Key8, net.IP](kadtest.NewID(key.Key8(i)), sched, router)
+			sim.Add(sched)
+		}
+		client, server := endpoints[0], endpoints[1]
+		require.NoError(t, client.SetDialConfig(cfg))
+		require.NoError(t, server.AddRequestHandler(protoID, &Message[key.Key8, net.IP]{},
+			func(ctx context.Context, id kad.NodeID[key.Key8], req kad.Message) (kad.Message, error) {
+				return &Message[key.Key8, net.IP]{}, nil
+			}))
+		require.NoError(t, client.MaybeAddToPeerstore(ctx,
+			kadtest.NewInfo[key.Key8, net.IP](server.self.(*kadtest.ID[key.Key8]), nil), time.Hour))
+		return clk, sim, client, server.self
+	}
+
+	t.Run("latency", func(t *testing.T) {
+		clk, sim, client, server := setup(&DialConfig[key.Key8]{Latency: ConstantLatency[key.Key8](2 * time.Second)})
+		sub := client.SubscribeConnEvents(10)
+		start := clk.Now()
+
+		// concurrent requests wait for the same dial
+		var at []time.Time
+		for i := 0; i < 3; i++ {
+			err := client.SendRequestHandleResponse(ctx, protoID, server, &Message[key.Key8, net.IP]{},
+				&Message[key.Key8, net.IP]{}, time.Second, func(ctx context.Context, resp kad.Response[key.Key8, net.IP], err error) {
+					require.NoError(t, err)
+					at = append(at, clk.Now())
+				})
+			require.NoError(t, err)
+		}
+		require.Equal(t, endpoint.CanConnect, client.peerstore.Connectedness(server))
+		sim.Run(ctx)
+		require.Equal(t, endpoint.Connected, client.peerstore.Connectedness(server))
+		require.Len(t, at, 3)
+		for _, tm := range at {
+			require.Equal(t, start.Add(2*time.Second), tm)
+		}
+		require.Len(t, sub.Events(), 1)
+		require.IsType(t, &endpoint.EventConnected[key.Key8]{}, <-sub.Events())
+
+		// once connected, requests are sent at once
+		at = nil
+		err := client.SendRequestHandleResponse(ctx, protoID, server, &Message[key.Key8, net.IP]{},
+			&Message[key.Key8, net.IP]{}, time.Second, func(ctx context.Context, resp kad.Response[key.Key8, net.IP], err error) {
+				at = append(at, clk.Now())
+			})
+		require.NoError(t, err)
+		sim.Run(ctx)
+		require.Equal(t, []time.Time{start.Add(2 * time.Second)}, at)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		_, sim, client, server := setup(&DialConfig[key.Key8]{Failure: 1})
+		sub := client.SubscribeConnEvents(10)
+		var errs []error
+		err := client.SendRequestHandleResponse(ctx, protoID, server, &Message[key.Key8, net.IP]{},
+			&Message[key.Key8, net.IP]{}, time.Second, func(ctx context.Context, resp kad.Response[key.Key8, net.IP], err error) {
+				errs = append(errs, err)
+			})
+		require.NoError(t, err)
+		require.NoError(t, client.DialPeer(ctx, server))
+		sim.Run(ctx)
+		require.Len(t, errs, 1)
+		require.ErrorIs(t, errs[0], endpoint.ErrDialFailure)
+		require.Equal(t, endpoint.CanConnect, client.peerstore.Connectedness(server))
+		require.Len(t, sub.Events(), 1)
+		require.IsType(t, &endpoint.EventDialFailed[key.Key8]{}, <-sub.Events())
+	})
+}
@@ -1,4 +1,7 @@
 // Package sim provides simple implementations of the principal Kademlia interfaces that allow the
 // construction of simulations and test scenarios. The implementations are not suitable for use
 // with real Kademlia networks.
+//
+// Like the schedulers of the event package, the components of a simulation take their time from a
+// clock.Clock, usually a mock shared by all the nodes and advanced by a LiteSimulator.
 package sim
@@ -12,9 +12,9 @@ import (
 	"github.com/plprobelab/go-kademlia/key"
 	"github.com/plprobelab/go-kademlia/network/address"
 	"github.com/plprobelab/go-kademlia/network/endpoint"
+	"github.com/plprobelab/go-kademlia/network/peerstore"
 	"github.com/plprobelab/go-kademlia/util"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/trace"
 )
 
 // SimEndpoint is a simulated endpoint that doesn't operate on real network
@@ -31,32 +31,68 @@ type Endpoint[K kad.Key[K], A kad.Address[A]] struct {
 	self  kad.NodeID[K]
 	sched event.Scheduler // client
 
-	peerstore    map[string]kad.NodeInfo[K, A]
-	connStatus   map[string]endpoint.Connectedness
-	serverProtos map[address.ProtocolID]endpoint.RequestHandlerFn[K] // server
+	peerstore       peerstore.Peerstore[K, A]
+	serverProtos    map[address.ProtocolID]endpoint.RequestHandlerFn[K]          // server
+	streamingProtos map[address.ProtocolID]endpoint.StreamingRequestHandlerFn[K] // server
+	pushProtos      map[address.ProtocolID]endpoint.MessageHandlerFn[K]          // server
 
-	streamMu       sync.Mutex                                             // guards access to streamFollowup and streamTimeout
+	streamMu       sync.Mutex                                             // guards access to streamFollowup, streamChunks, streamTimeout and conns
 	streamFollowup map[endpoint.StreamID]endpoint.ResponseHandlerFn[K, A] // client
+	streamChunks   map[endpoint.StreamID]*streamFollowup[K, A]            // client
 	streamTimeout  map[endpoint.StreamID]event.PlannedAction              // client
-
-	router *Router[K, A]
+	conns          map[string]*simConn[K, A]                              // client
+	maxOutstanding int                                                    // the maximum number of outstanding requests per connection, zero for no limit
+
+	events     *endpoint.ConnEventBus[K]
+	metrics    *endpoint.PeerMetricsRecorder
+	router     *Router[K, A]
+	limiter    *endpoint.RateLimiter            // optional limiter of inbound requests
+	signatures *peerstore.SignaturePolicy[K, A] // optional policy for the signatures of added node infos
+	closerTTL  time.Duration                    // the ttl of the closer nodes of responses added to the peerstore
+
+	dialer      *dialer[K] // optional dialer making dials take time
+	identity    *Identity  // optional identity with which sent messages are signed
+	requireAuth bool       // whether received messages must be signed by their sender
 }
 
-var _ SimEndpoint[key.Key256, net.IP] = (*Endpoint[key.Key256, net.IP])(nil)
+var (
+	_ SimEndpoint[key.Key256, net.IP]                = (*Endpoint[key.Key256, net.IP])(nil)
+	_ endpoint.ConnEventEndpoint[key.Key256, net.IP] = (*Endpoint[key.Key256, net.IP])(nil)
+	_ endpoint.PipelinedEndpoint[key.Key256, net.IP] = (*Endpoint[key.Key256, net.IP])(nil)
+	_ endpoint.StreamingEndpoint[key.Key256, net.IP] = (*Endpoint[key.Key256, net.IP])(nil)
+
+	_ endpoint.NegotiatingEndpoint[key.Key256, net.IP]      = (*Endpoint[key.Key256, net.IP])(nil)
+	_ endpoint.MetricsEndpoint[key.Key256, net.IP]          = (*Endpoint[key.Key256, net.IP])(nil)
+	_ endpoint.PushEndpoint[key.Key256, net.IP]             = (*Endpoint[key.Key256, net.IP])(nil)
+	_ endpoint.ProtocolCheckingEndpoint[key.Key256, net.IP] = (*Endpoint[key.Key256, net.IP])(nil)
+)
 
 func NewEndpoint[K kad.Key[K], A kad.Address[A]](self kad.NodeID[K], sched event.Scheduler, router *Router[K, A]) *Endpoint[K, A] {
+	psCfg := peerstore.DefaultConfig()
+	if sched != nil {
+		psCfg.Clock = sched.Clock()
+	}
+	ps, _ := peerstore.NewMemoryPeerstore[K, A](psCfg)
+
 	e := &Endpoint[K, A]{
 		self:         self,
 		sched:        sched,
 		serverProtos: make(map[address.ProtocolID]endpoint.RequestHandlerFn[K]),
 
-		peerstore:  make(map[string]kad.NodeInfo[K, A]),
-		connStatus: make(map[string]endpoint.Connectedness),
+		streamingProtos: make(map[address.ProtocolID]endpoint.StreamingRequestHandlerFn[K]),
+		pushProtos:      make(map[address.ProtocolID]endpoint.MessageHandlerFn[K]),
+
+		peerstore: ps,
+		closerTTL: peerstore.PermanentTTL,
 
 		streamFollowup: make(map[endpoint.StreamID]endpoint.ResponseHandlerFn[K, A]),
+		streamChunks:   make(map[endpoint.StreamID]*streamFollowup[K, A]),
 		streamTimeout:  make(map[endpoint.StreamID]event.PlannedAction),
+		conns:          make(map[string]*simConn[K, A]),
 
-		router: router,
+		events:  endpoint.NewConnEventBus[K](),
+		metrics: endpoint.NewPeerMetricsRecorder(psCfg.Clock),
+		router:  router,
 	}
 	if router != nil {
 		router.AddPeer(self, e, sched)
@@ -64,42 +100,80 @@ func NewEndpoint[K kad.Key[K], A kad.Address[A]](self kad.NodeID[K], sched event
 	return e
 }
 
+// DialPeer connects to id. With a dial config, see SetDialConfig, a peer that is not connected yet
+// is dialled asynchronously: DialPeer returns nil once the dial has started, and its outcome is
+// reported by the connection events of the endpoint.
 func (e *Endpoint[K, A]) DialPeer(ctx context.Context, id kad.NodeID[K]) error {
-	_, span := util.StartSpan(ctx, "DialPeer",
-		trace.WithAttributes(attribute.String("id", id.String())),
-	)
+	_, span := util.StartMessageSpan(ctx, "DialPeer", func() []attribute.KeyValue {
+		return []attribute.KeyValue{attribute.String("id", id.String())}
+	})
 	defer span.End()
 
-	status, ok := e.connStatus[id.String()]
-
-	if ok {
-		switch status {
-		case endpoint.Connected:
-			return nil
-		case endpoint.CanConnect:
-			e.connStatus[id.String()] = endpoint.Connected
-			return nil
-		}
+	if e.dialsAsync(id) {
+		e.dialAsync(ctx, id, nil)
+		return nil
+	}
+	if e.router != nil && !e.router.reachable(e.self, id) {
+		span.RecordError(endpoint.ErrCannotConnect)
+		e.events.Emit(&endpoint.EventDialFailed[K]{NodeID: id, Error: endpoint.ErrCannotConnect})
+		return endpoint.ErrCannotConnect
+	}
+	switch e.peerstore.Connectedness(id) {
+	case endpoint.Connected:
+		return nil
+	case endpoint.CanConnect:
+		e.peerstore.SetConnectedness(id, endpoint.Connected)
+		e.events.Emit(&endpoint.EventConnected[K]{NodeID: id})
+		return nil
 	}
 	span.RecordError(endpoint.ErrUnknownPeer)
+	e.events.Emit(&endpoint.EventDialFailed[K]{NodeID: id, Error: endpoint.ErrUnknownPeer})
 	return endpoint.ErrUnknownPeer
 }
 
-// MaybeAddToPeerstore adds the given address to the peerstore. Endpoint
-// doesn't take into account the ttl.
+// Disconnect closes the simulated connection with id, which may be dialled again.
+func (e *Endpoint[K, A]) Disconnect(id kad.NodeID[K]) {
+	if e.peerstore.Connectedness(id) != endpoint.Connected {
+		return
+	}
+	e.peerstore.SetConnectedness(id, endpoint.CanConnect)
+	e.events.Emit(&endpoint.EventDisconnected[K]{NodeID: id})
+}
+
+// SubscribeConnEvents returns a subscription to the connection events of the endpoint,
+// buffering up to size events. Events are emitted on the go routine that dials or
+// disconnects.
+func (e *Endpoint[K, A]) SubscribeConnEvents(size int) *endpoint.ConnSubscription[K] {
+	return e.events.Subscribe(size)
+}
+
+// MaybeAddToPeerstore adds the given address to the peerstore, where it is kept for at least
+// ttl. A node added again keeps the later of its current and new expiry times, and a
+// non-positive ttl leaves the peerstore unchanged.
 func (e *Endpoint[K, A]) MaybeAddToPeerstore(ctx context.Context, id kad.NodeInfo[K, A], ttl time.Duration) error {
-	strNodeID := id.ID().String()
-	_, span := util.StartSpan(ctx, "MaybeAddToPeerstore",
-		trace.WithAttributes(attribute.String("self", e.self.String())),
-		trace.WithAttributes(attribute.String("id", strNodeID)),
-	)
+	_, span := util.StartMessageSpan(ctx, "MaybeAddToPeerstore", func() []attribute.KeyValue {
+		return []attribute.KeyValue{
+			attribute.String("self", e.self.String()),
+			attribute.String("id", id.ID().String()),
+		}
+	})
 	defer span.End()
 
-	if _, ok := e.peerstore[strNodeID]; !ok {
-		e.peerstore[strNodeID] = id
+	if e.signatures != nil {
+		if err := e.signatures.Check(id); err != nil {
+			span.RecordError(err)
+			return err
+		}
+	}
+	if ttl <= 0 {
+		return nil
+	}
+	if err := e.peerstore.Add(ctx, id, ttl); err != nil {
+		span.RecordError(err)
+		return err
 	}
-	if _, ok := e.connStatus[strNodeID]; !ok {
-		e.connStatus[strNodeID] = endpoint.CanConnect
+	if e.peerstore.Connectedness(id.ID()) == endpoint.NotConnected {
+		e.peerstore.SetConnectedness(id.ID(), endpoint.CanConnect)
 	}
 	return nil
 }
@@ -109,42 +183,103 @@ func (e *Endpoint[K, A]) SendRequestHandleResponse(ctx context.Context,
 	resp kad.Message, timeout time.Duration,
 	handleResp endpoint.ResponseHandlerFn[K, A],
 ) error {
-	ctx, span := util.StartSpan(ctx, "SendRequestHandleResponse",
-		trace.WithAttributes(attribute.Stringer("id", id)),
-	)
+	_, err := e.SendPipelinedRequest(ctx, protoID, id, req, resp, timeout, handleResp)
+	return err
+}
+
+// SendPipelinedRequest sends a request to the given peer on the simulated connection with the
+// peer and returns the stream id of the request. If the connection already has the maximum
+// number of outstanding requests, the request is queued until a response is received or a
+// request times out. The timeout starts when the request is sent.
+func (e *Endpoint[K, A]) SendPipelinedRequest(ctx context.Context,
+	protoID address.ProtocolID, id kad.NodeID[K], req kad.Message,
+	resp kad.Message, timeout time.Duration,
+	handleResp endpoint.ResponseHandlerFn[K, A],
+) (endpoint.StreamID, error) {
+	ctx, span := util.StartMessageSpan(ctx, "SendPipelinedRequest", func() []attribute.KeyValue {
+		return []attribute.KeyValue{attribute.Stringer("id", id)}
+	})
 	defer span.End()
 
+	handleResp = endpoint.Track(e.metrics, id.String(), handleResp)
+	if e.dialsAsync(id) {
+		span.AddEvent("Dialling peer")
+		r := e.newPipelinedRequest(protoID, req, timeout, handleResp)
+		e.dialAsync(ctx, id, func(ctx context.Context, err error) {
+			if err != nil {
+				handleResp(ctx, nil, err)
+				return
+			}
+			e.startRequest(ctx, id, r)
+		})
+		return r.sid, nil
+	}
 	if err := e.DialPeer(ctx, id); err != nil {
 		span.RecordError(err)
 		e.sched.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
 			handleResp(ctx, nil, err)
 		}))
-		return nil
+		return 0, nil
 	}
 
-	// send request. id.String() is guaranteed to be in peerstore, because
-	// DialPeer checks it, and an error is returned if it's not there.
-	addr := e.peerstore[id.String()]
+	r := e.newPipelinedRequest(protoID, req, timeout, handleResp)
+	if e.startRequest(ctx, id, r) {
+		span.AddEvent("Request queued")
+	}
+	return r.sid, nil
+}
 
-	sid, err := e.router.SendMessage(ctx, e.self, addr.ID(), protoID, 0, req)
-	if err != nil {
-		span.RecordError(err)
+func (e *Endpoint[K, A]) newPipelinedRequest(protoID address.ProtocolID, req kad.Message, timeout time.Duration,
+	handleResp endpoint.ResponseHandlerFn[K, A],
+) *pipelinedRequest[K, A] {
+	return &pipelinedRequest[K, A]{
+		sid:     e.router.NewStreamID(),
+		protoID: protoID,
+		req:     req,
+		timeout: timeout,
+		handler: handleResp,
+	}
+}
+
+// startRequest sends a request on the connection with id, or queues it if the connection already
+// has the maximum number of outstanding requests, in which case it returns true.
+func (e *Endpoint[K, A]) startRequest(ctx context.Context, id kad.NodeID[K], r *pipelinedRequest[K, A]) bool {
+	e.streamMu.Lock()
+	c := e.conn(id)
+	if e.maxOutstanding > 0 && c.outstanding >= e.maxOutstanding {
+		c.enqueue(r)
+		e.streamMu.Unlock()
+		return true
+	}
+	c.outstanding++
+	c.last = r.protoID
+	e.streamMu.Unlock()
+
+	e.send(ctx, id, r)
+	return false
+}
+
+// send sends a request counted as outstanding on the connection with id.
+func (e *Endpoint[K, A]) send(ctx context.Context, id kad.NodeID[K], r *pipelinedRequest[K, A]) {
+	sid, handleResp := r.sid, r.handler
+	if _, err := e.sendMessage(ctx, id, r.protoID, sid, r.req); err != nil {
+		e.release(ctx, id)
 		e.sched.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
 			handleResp(ctx, nil, err)
 		}))
-		return nil
+		return
 	}
 	e.streamMu.Lock()
 	defer e.streamMu.Unlock()
 
 	e.streamFollowup[sid] = handleResp
 	// timeout
-	if timeout != 0 {
-		e.streamTimeout[sid] = event.ScheduleActionIn(ctx, e.sched, timeout,
+	if r.timeout != 0 {
+		e.streamTimeout[sid] = event.ScheduleActionIn(ctx, e.sched, r.timeout,
 			event.BasicAction(func(ctx context.Context) {
-				ctx, span := util.StartSpan(ctx, "SendRequestHandleResponse timeout",
-					trace.WithAttributes(attribute.Stringer("id", id)),
-				)
+				ctx, span := util.StartMessageSpan(ctx, "SendRequestHandleResponse timeout", func() []attribute.KeyValue {
+					return []attribute.KeyValue{attribute.Stringer("id", id)}
+				})
 				defer span.End()
 
 				e.streamMu.Lock()
@@ -153,6 +288,9 @@ func (e *Endpoint[K, A]) SendRequestHandleResponse(ctx context.Context,
 				delete(e.streamTimeout, sid)
 				e.streamMu.Unlock()
 
+				if ok {
+					e.release(ctx, id)
+				}
 				if !ok || handleFn == nil {
 					span.RecordError(fmt.Errorf("no followup for stream %d", sid))
 					return
@@ -160,20 +298,39 @@ func (e *Endpoint[K, A]) SendRequestHandleResponse(ctx context.Context,
 				handleFn(ctx, nil, endpoint.ErrTimeout)
 			}))
 	}
-	return nil
+}
+
+// release ends an outstanding request on the connection with id and sends the next queued
+// request, if any.
+func (e *Endpoint[K, A]) release(ctx context.Context, id kad.NodeID[K]) {
+	e.streamMu.Lock()
+	c, ok := e.conns[id.String()]
+	if !ok {
+		e.streamMu.Unlock()
+		return
+	}
+	c.outstanding--
+	next := c.next()
+	if next != nil {
+		c.outstanding++
+	}
+	if c.outstanding == 0 {
+		delete(e.conns, id.String())
+	}
+	e.streamMu.Unlock()
+
+	if next != nil {
+		e.send(ctx, id, next)
+	}
 }
 
 // Peerstore functions
 func (e *Endpoint[K, A]) Connectedness(id kad.NodeID[K]) (endpoint.Connectedness, error) {
-	if s, ok := e.connStatus[id.String()]; !ok {
-		return endpoint.NotConnected, nil
-	} else {
-		return s, nil
-	}
+	return e.peerstore.Connectedness(id), nil
 }
 
 func (e *Endpoint[K, A]) NetworkAddress(id kad.NodeID[K]) (kad.NodeInfo[K, A], error) {
-	if ai, ok := e.peerstore[id.String()]; ok {
+	if ai, err := e.peerstore.Get(context.Background(), id); err == nil {
 		return ai, nil
 	}
 	if na, ok := id.(kad.NodeInfo[K, A]); ok {
@@ -189,11 +346,30 @@ func (e *Endpoint[K, A]) Key() K {
 func (e *Endpoint[K, A]) HandleMessage(ctx context.Context, id kad.NodeID[K],
 	protoID address.ProtocolID, sid endpoint.StreamID, msg kad.Message,
 ) {
-	_, span := util.StartSpan(ctx, "HandleMessage",
-		trace.WithAttributes(attribute.Stringer("id", id),
-			attribute.Int64("StreamID", int64(sid))))
+	_, span := util.StartMessageSpan(ctx, "HandleMessage", func() []attribute.KeyValue {
+		return []attribute.KeyValue{
+			attribute.Stringer("id", id),
+			attribute.Int64("StreamID", int64(sid)),
+		}
+	})
 	defer span.End()
 
+	msg, err := e.authenticate(id, protoID, sid, msg)
+	if err != nil {
+		span.RecordError(err)
+		return
+	}
+
+	if pm, ok := msg.(*pushedMessage); ok {
+		e.handlePush(ctx, id, protoID, pm.Message)
+		return
+	}
+
+	if e.handleStreamResponse(ctx, id, sid, msg) {
+		span.AddEvent("Streamed response to previous request")
+		return
+	}
+
 	e.streamMu.Lock()
 	followup, ok := e.streamFollowup[sid]
 	e.streamMu.Unlock()
@@ -210,16 +386,26 @@ func (e *Endpoint[K, A]) HandleMessage(ctx context.Context, id kad.NodeID[K],
 		delete(e.streamFollowup, sid)
 		delete(e.streamTimeout, sid)
 		e.streamMu.Unlock()
+		e.release(ctx, id)
 
 		resp, ok := msg.(kad.Response[K, A])
+		_, throttled := msg.(*Throttled[K, A])
 		var err error
-		if ok {
+		switch {
+		case throttled:
+			err = endpoint.ErrThrottled
+		case !ok:
+			err = ErrInvalidResponseType
+		case e.signatures != nil:
+			err = e.signatures.CheckCloserNodes(resp)
+		}
+		if err != nil {
+			resp = nil
+		} else {
 			for _, p := range resp.CloserNodes() {
-				e.peerstore[p.ID().String()] = p
-				e.connStatus[p.ID().String()] = endpoint.CanConnect
+				e.peerstore.Add(ctx, p, e.closerTTL)
+				e.peerstore.SetConnectedness(p.ID(), endpoint.CanConnect)
 			}
-		} else {
-			err = ErrInvalidResponseType
 		}
 		if followup != nil {
 			e.sched.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
@@ -229,15 +415,33 @@ func (e *Endpoint[K, A]) HandleMessage(ctx context.Context, id kad.NodeID[K],
 		return
 	}
 
-	if handler, ok := e.serverProtos[protoID]; ok && handler != nil {
-		// it isn't a response, so treat it as a request
-		resp, err := handler(ctx, id, msg)
-		if err != nil {
-			span.RecordError(err)
-			return
-		}
-		e.router.SendMessage(ctx, e.self, id, protoID, sid, resp)
+	// it isn't a response, so treat it as a request
+	handler, ok := e.serverProtos[protoID]
+	streamHandler, streaming := e.streamingProtos[protoID]
+	if (!ok || handler == nil) && !streaming {
+		return
+	}
+	if e.limiter != nil && !e.limiter.Allow(id.String()) {
+		span.AddEvent("Request throttled")
+		e.sendMessage(ctx, id, protoID, sid, &Throttled[K, A]{})
+		return
 	}
+	if streaming {
+		e.serveStream(ctx, id, protoID, sid, msg, streamHandler)
+		return
+	}
+	resp, err := handler(ctx, id, msg)
+	if err != nil {
+		span.RecordError(err)
+		return
+	}
+	if d, ok := resp.(*delayedResponse); ok {
+		event.ScheduleActionIn(ctx, e.sched, d.delay, event.BasicAction(func(ctx context.Context) {
+			e.sendMessage(ctx, id, protoID, sid, d.Message)
+		}))
+		return
+	}
+	e.sendMessage(ctx, id, protoID, sid, resp)
 }
 
 func (e *Endpoint[K, A]) AddRequestHandler(protoID address.ProtocolID,
@@ -246,10 +450,116 @@ func (e *Endpoint[K, A]) AddRequestHandler(protoID address.ProtocolID,
 	if reqHandler == nil {
 		return endpoint.ErrNilRequestHandler
 	}
+	delete(e.streamingProtos, protoID)
 	e.serverProtos[protoID] = reqHandler
 	return nil
 }
 
+// SetRateLimiter sets the limiter applied to inbound requests before they are dispatched to
+// request handlers. Requests rejected by the limiter are answered with a Throttled response. A
+// nil limiter disables rate limiting.
+func (e *Endpoint[K, A]) SetRateLimiter(l *endpoint.RateLimiter) {
+	e.limiter = l
+}
+
+// Peerstore returns the address book of the endpoint.
+func (e *Endpoint[K, A]) Peerstore() peerstore.Peerstore[K, A] {
+	return e.peerstore
+}
+
+// SetPeerstore replaces the address book of the endpoint, a MemoryPeerstore using the clock of
+// the scheduler of the endpoint by default.
+func (e *Endpoint[K, A]) SetPeerstore(ps peerstore.Peerstore[K, A]) {
+	e.peerstore = ps
+}
+
+// SetCloserNodesTTL sets the ttl with which the closer nodes of the responses received are
+// added to the peerstore, PermanentTTL by default. A node already in the peerstore keeps the
+// later of its current and new expiry times.
+func (e *Endpoint[K, A]) SetCloserNodesTTL(ttl time.Duration) {
+	e.closerTTL = ttl
+}
+
+// UpdateTTL sets the expiry of the address of id to ttl from now, see peerstore.Peerstore.
+func (e *Endpoint[K, A]) UpdateTTL(ctx context.Context, id kad.NodeID[K], ttl time.Duration) error {
+	return e.peerstore.UpdateTTL(ctx, id, ttl)
+}
+
+// RemovePeer closes the simulated connection with id, if any, and removes its address from the
+// peerstore, so that it can't be dialled until added again.
+func (e *Endpoint[K, A]) RemovePeer(ctx context.Context, id kad.NodeID[K]) error {
+	e.Disconnect(id)
+	return e.peerstore.RemovePeer(ctx, id)
+}
+
+// SchedulePeerstoreSweep schedules the removal of the expired addresses of the peerstore on the
+// scheduler of the endpoint every interval, until ctx is done or the returned action is
+// cancelled.
+func (e *Endpoint[K, A]) SchedulePeerstoreSweep(ctx context.Context, interval time.Duration) *event.RepeatedAction {
+	return peerstore.ScheduleSweep(ctx, e.sched, e.peerstore, interval)
+}
+
+// SetSignaturePolicy sets the policy applied to the signatures of the node infos added to the
+// peerstore. Signed node infos passed to MaybeAddToPeerstore with an invalid signature are
+// rejected, and responses with closer nodes rejected by the policy are reported to the response
+// handler with an error. A nil policy accepts all node infos.
+func (e *Endpoint[K, A]) SetSignaturePolicy(p *peerstore.SignaturePolicy[K, A]) {
+	e.signatures = p
+}
+
+// Metrics returns the latency and reliability recorded for the requests sent to id.
+func (e *Endpoint[K, A]) Metrics(id kad.NodeID[K]) (endpoint.PeerMetrics, bool) {
+	return e.metrics.Metrics(id.String())
+}
+
+// SupportsProtocol reports whether the endpoint handles requests for protoID.
+func (e *Endpoint[K, A]) SupportsProtocol(protoID address.ProtocolID) bool {
+	if h, ok := e.serverProtos[protoID]; ok && h != nil {
+		return true
+	}
+	_, ok := e.streamingProtos[protoID]
+	return ok
+}
+
+// PeerSupportsProtocol reports whether the peer id handles requests for protoID, which is false for
+// peers unknown to the router.
+func (e *Endpoint[K, A]) PeerSupportsProtocol(id kad.NodeID[K], protoID address.ProtocolID) bool {
+	_, err := e.router.Negotiate(id, []address.ProtocolID{protoID})
+	return err == nil
+}
+
+// SendRequestNegotiate sends a request to the given peer with the first of protoIDs that the
+// peer handles requests for.
+func (e *Endpoint[K, A]) SendRequestNegotiate(ctx context.Context,
+	protoIDs []address.ProtocolID, id kad.NodeID[K], req kad.Message,
+	resp kad.Message, timeout time.Duration,
+	handleResp endpoint.NegotiatedResponseHandlerFn[K, A],
+) error {
+	ctx, span := util.StartMessageSpan(ctx, "SendRequestNegotiate", func() []attribute.KeyValue {
+		return []attribute.KeyValue{attribute.Stringer("id", id)}
+	})
+	defer span.End()
+
+	if handleResp == nil {
+		return endpoint.ErrNilResponseHandler
+	}
+
+	protoID, err := e.router.Negotiate(id, protoIDs)
+	if err != nil {
+		span.RecordError(err)
+		e.sched.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
+			handleResp(ctx, "", nil, err)
+		}))
+		return nil
+	}
+	span.SetAttributes(attribute.String("protocol", string(protoID)))
+	return e.SendRequestHandleResponse(ctx, protoID, id, req, resp, timeout,
+		func(ctx context.Context, resp kad.Response[K, A], err error) {
+			handleResp(ctx, protoID, resp, err)
+		})
+}
+
 func (e *Endpoint[K, A]) RemoveRequestHandler(protoID address.ProtocolID) {
 	delete(e.serverProtos, protoID)
+	delete(e.streamingProtos, protoID)
 }
@@ -2,19 +2,21 @@ package sim
 
 import (
 	"context"
+	"crypto/ed25519"
 	"net"
 	"testing"
 	"time"
 
 	"github.com/benbjohnson/clock"
-	"github.com/plprobelab/go-kademlia/internal/kadtest"
 	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
 	"github.com/stretchr/testify/require"
 
 	"github.com/plprobelab/go-kademlia/event"
 	"github.com/plprobelab/go-kademlia/key"
 	"github.com/plprobelab/go-kademlia/network/address"
 	"github.com/plprobelab/go-kademlia/network/endpoint"
+	"github.com/plprobelab/go-kademlia/network/peerstore"
 	"github.com/plprobelab/go-kademlia/routing/simplert"
 )
 
@@ -277,3 +279,448 @@ func TestRequestTimeout(t *testing.T) {
 	require.True(t, scheds[0].RunOne(ctx))
 	require.False(t, scheds[0].RunOne(ctx))
 }
+
+func TestRateLimit(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	router := NewRouter[key.Key256, net.IP]()
+
+	nPeers := 2
+	scheds := make([]event.AwareScheduler, nPeers)
+	ids := make([]kad.NodeInfo[key.Key256, net.IP], nPeers)
+	fakeEndpoints := make([]*Endpoint[key.Key256, net.IP], nPeers)
+	for i := 0; i < nPeers; i++ {
+		ids[i] = kadtest.NewInfo[key.Key256, net.IP](kadtest.NewID(kadtest.Key256WithLeadingBytes([]byte{byte(i)})), nil)
+		scheds[i] = event.NewSimpleScheduler(clk)
+		fakeEndpoints[i] = NewEndpoint[key.Key256, net.IP](ids[i].ID(), scheds[i], router)
+	}
+	fakeEndpoints[0].MaybeAddToPeerstore(ctx, ids[1], peerstoreTTL)
+
+	handled := 0
+	fakeEndpoints[1].AddRequestHandler(protoID, nil, func(ctx context.Context, id kad.NodeID[key.Key256],
+		req kad.Message,
+	) (kad.Message, error) {
+		handled++
+		return NewResponse[key.Key256, net.IP](nil), nil
+	})
+
+	cfg := endpoint.DefaultRateLimitConfig()
+	cfg.Clock = clk
+	cfg.PeerRate = 1
+	cfg.PeerBurst = 1
+	limiter, err := endpoint.NewRateLimiter(cfg)
+	require.NoError(t, err)
+	fakeEndpoints[1].SetRateLimiter(limiter)
+
+	var errs []error
+	send := func() {
+		err := fakeEndpoints[0].SendRequestHandleResponse(ctx, protoID, ids[1].ID(), nil, nil, 0,
+			func(ctx context.Context, msg kad.Response[key.Key256, net.IP], err error) {
+				errs = append(errs, err)
+			})
+		require.NoError(t, err)
+		event.RunAll(ctx, scheds[1])
+		event.RunAll(ctx, scheds[0])
+	}
+
+	// the first request is handled, the second exceeds the rate limit
+	send()
+	send()
+	require.Equal(t, 1, handled)
+	require.Equal(t, []error{nil, endpoint.ErrThrottled}, errs)
+
+	// the bucket of the requester is refilled
+	clk.Add(time.Second)
+	send()
+	require.Equal(t, 2, handled)
+	require.Equal(t, []error{nil, endpoint.ErrThrottled, nil}, errs)
+}
+
+func TestEndpointPeerstore(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	sched := event.NewSimpleScheduler(clk)
+	self := kadtest.NewInfo[key.Key256, net.IP](kadtest.NewID(kadtest.Key256WithLeadingBytes([]byte{0})), nil)
+	e := NewEndpoint[key.Key256, net.IP](self.ID(), sched, nil)
+
+	node := kadtest.NewInfo(kadtest.NewID(kadtest.Key256WithLeadingBytes([]byte{1})), []net.IP{net.ParseIP("127.0.0.1")})
+	require.NoError(t, e.MaybeAddToPeerstore(ctx, node, peerstoreTTL))
+	ni, err := e.Peerstore().Get(ctx, node.ID())
+	require.NoError(t, err)
+	require.Equal(t, node, ni)
+	require.Equal(t, endpoint.CanConnect, e.Peerstore().Connectedness(node.ID()))
+
+	// adding the node again extends its expiry
+	clk.Add(peerstoreTTL - time.Second)
+	require.NoError(t, e.MaybeAddToPeerstore(ctx, node, peerstoreTTL))
+	clk.Add(time.Second)
+	_, err = e.NetworkAddress(node.ID())
+	require.NoError(t, err)
+
+	// the address is no longer returned once its ttl elapsed
+	clk.Add(peerstoreTTL)
+	_, err = e.NetworkAddress(node.ID())
+	require.ErrorIs(t, err, endpoint.ErrUnknownPeer)
+
+	// a non-positive ttl adds nothing
+	require.NoError(t, e.MaybeAddToPeerstore(ctx, node, 0))
+	_, err = e.NetworkAddress(node.ID())
+	require.ErrorIs(t, err, endpoint.ErrUnknownPeer)
+
+	// the endpoint uses the peerstore it is given
+	ps, err := peerstore.NewMemoryPeerstore[key.Key256, net.IP](nil)
+	require.NoError(t, err)
+	e.SetPeerstore(ps)
+	_, err = e.NetworkAddress(node.ID())
+	require.ErrorIs(t, err, endpoint.ErrUnknownPeer)
+
+	require.NoError(t, e.MaybeAddToPeerstore(ctx, node, peerstoreTTL))
+	ni, err = ps.Get(ctx, node.ID())
+	require.NoError(t, err)
+	require.Equal(t, node, ni)
+}
+
+func TestEndpointPeerstoreTTL(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clk := clock.NewMock()
+	sched := event.NewSimpleScheduler(clk)
+	self := kadtest.NewInfo[key.Key256, net.IP](kadtest.NewID(kadtest.Key256WithLeadingBytes([]byte{0})), nil)
+	e := NewEndpoint[key.Key256, net.IP](self.ID(), sched, nil)
+
+	a := kadtest.NewInfo(kadtest.NewID(kadtest.Key256WithLeadingBytes([]byte{1})), []net.IP{net.ParseIP("127.0.0.1")})
+	b := kadtest.NewInfo(kadtest.NewID(kadtest.Key256WithLeadingBytes([]byte{2})), []net.IP{net.ParseIP("127.0.0.2")})
+	require.NoError(t, e.MaybeAddToPeerstore(ctx, a, peerstoreTTL))
+	require.NoError(t, e.MaybeAddToPeerstore(ctx, b, peerstoreTTL))
+
+	// the ttl of a may be made permanent
+	require.NoError(t, e.UpdateTTL(ctx, a.ID(), peerstore.PermanentTTL))
+	require.ErrorIs(t, e.UpdateTTL(ctx, self.ID(), peerstoreTTL), peerstore.ErrNotFound)
+
+	// the expired address of b is swept
+	ps := e.Peerstore().(*peerstore.MemoryPeerstore[key.Key256, net.IP])
+	e.SchedulePeerstoreSweep(ctx, peerstoreTTL)
+	clk.Add(peerstoreTTL)
+	event.RunAll(ctx, sched)
+	require.Equal(t, 1, ps.Size())
+
+	// a removed peer can't be dialled
+	require.NoError(t, e.DialPeer(ctx, a.ID()))
+	sub := e.SubscribeConnEvents(1)
+	require.NoError(t, e.RemovePeer(ctx, a.ID()))
+	require.Equal(t, &endpoint.EventDisconnected[key.Key256]{NodeID: a.ID()}, <-sub.Events())
+	require.Equal(t, 0, ps.Size())
+	require.ErrorIs(t, e.DialPeer(ctx, a.ID()), endpoint.ErrUnknownPeer)
+}
+
+func TestSignaturePolicy(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	router := NewRouter[key.Key256, net.IP]()
+
+	nPeers := 2
+	scheds := make([]event.AwareScheduler, nPeers)
+	ids := make([]kad.NodeInfo[key.Key256, net.IP], nPeers)
+	fakeEndpoints := make([]*Endpoint[key.Key256, net.IP], nPeers)
+	for i := 0; i < nPeers; i++ {
+		ids[i] = kadtest.NewInfo[key.Key256, net.IP](kadtest.NewID(kadtest.Key256WithLeadingBytes([]byte{byte(i)})), nil)
+		scheds[i] = event.NewSimpleScheduler(clk)
+		fakeEndpoints[i] = NewEndpoint[key.Key256, net.IP](ids[i].ID(), scheds[i], router)
+	}
+	fakeEndpoints[0].MaybeAddToPeerstore(ctx, ids[1], peerstoreTTL)
+
+	// node 2 signs its address, which node 1 returns in its responses
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	node := kadtest.NewInfo(kadtest.NewID(kadtest.Key256WithLeadingBytes([]byte{2})), []net.IP{net.ParseIP("10.0.0.2")})
+	signed := peerstore.SignEd25519[key.Key256, net.IP](node, priv)
+	spoofed := peerstore.NewSignedInfo[key.Key256, net.IP](
+		kadtest.NewInfo(node.ID().(*kadtest.ID[key.Key256]), []net.IP{net.ParseIP("10.6.6.6")}), signed.Signature())
+
+	var closer []kad.NodeInfo[key.Key256, net.IP]
+	fakeEndpoints[1].AddRequestHandler(protoID, nil, func(ctx context.Context, id kad.NodeID[key.Key256],
+		req kad.Message,
+	) (kad.Message, error) {
+		return NewResponse(closer), nil
+	})
+
+	policy := &peerstore.SignaturePolicy[key.Key256, net.IP]{
+		Verifier: &peerstore.Ed25519Verifier[key.Key256, net.IP]{
+			PublicKey: func(id kad.NodeID[key.Key256]) (ed25519.PublicKey, error) {
+				if !key.Equal(id.Key(), node.ID().Key()) {
+					return nil, peerstore.ErrUnknownKey
+				}
+				return pub, nil
+			},
+		},
+	}
+	fakeEndpoints[0].SetSignaturePolicy(policy)
+
+	send := func() error {
+		var respErr error
+		err := fakeEndpoints[0].SendRequestHandleResponse(ctx, protoID, ids[1].ID(), nil, nil, 0,
+			func(ctx context.Context, msg kad.Response[key.Key256, net.IP], err error) {
+				respErr = err
+			})
+		require.NoError(t, err)
+		event.RunAll(ctx, scheds[1])
+		event.RunAll(ctx, scheds[0])
+		return respErr
+	}
+
+	// spoofed addresses are rejected
+	require.ErrorIs(t, fakeEndpoints[0].MaybeAddToPeerstore(ctx, spoofed, peerstoreTTL), peerstore.ErrInvalidSignature)
+	closer = []kad.NodeInfo[key.Key256, net.IP]{spoofed}
+	require.ErrorIs(t, send(), peerstore.ErrInvalidSignature)
+	_, err = fakeEndpoints[0].Peerstore().Get(ctx, node.ID())
+	require.ErrorIs(t, err, peerstore.ErrNotFound)
+
+	// unsigned closer nodes are accepted unless signatures are required
+	closer = []kad.NodeInfo[key.Key256, net.IP]{node}
+	require.NoError(t, send())
+	policy.RequireSignedCloserNodes = true
+	require.ErrorIs(t, send(), peerstore.ErrUnsigned)
+
+	closer = []kad.NodeInfo[key.Key256, net.IP]{signed}
+	require.NoError(t, send())
+	ni, err := fakeEndpoints[0].Peerstore().Get(ctx, node.ID())
+	require.NoError(t, err)
+	require.Equal(t, signed, ni)
+}
+
+func TestConnEvents(t *testing.T) {
+	ctx := context.Background()
+	sched := event.NewSimpleScheduler(clock.NewMock())
+	self := kadtest.NewID(kadtest.Key256WithLeadingBytes([]byte{0}))
+	e := NewEndpoint[key.Key256, net.IP](self, sched, nil)
+	sub := e.SubscribeConnEvents(10)
+	defer sub.Close()
+
+	node := kadtest.NewInfo[key.Key256, net.IP](kadtest.NewID(kadtest.Key256WithLeadingBytes([]byte{1})), nil)
+
+	require.Equal(t, endpoint.ErrUnknownPeer, e.DialPeer(ctx, node.ID()))
+	require.Equal(t, &endpoint.EventDialFailed[key.Key256]{NodeID: node.ID(), Error: endpoint.ErrUnknownPeer}, <-sub.Events())
+
+	require.NoError(t, e.MaybeAddToPeerstore(ctx, node, peerstoreTTL))
+	require.NoError(t, e.DialPeer(ctx, node.ID()))
+	require.Equal(t, &endpoint.EventConnected[key.Key256]{NodeID: node.ID()}, <-sub.Events())
+
+	// dialling a connected node emits no event
+	require.NoError(t, e.DialPeer(ctx, node.ID()))
+
+	e.Disconnect(node.ID())
+	require.Equal(t, &endpoint.EventDisconnected[key.Key256]{NodeID: node.ID()}, <-sub.Events())
+	conn, err := e.Connectedness(node.ID())
+	require.NoError(t, err)
+	require.Equal(t, endpoint.CanConnect, conn)
+
+	// disconnecting a node that isn't connected emits no event
+	e.Disconnect(node.ID())
+	require.Len(t, sub.Events(), 0)
+}
+
+func TestPipelinedRequests(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	router := NewRouter[key.Key256, net.IP]()
+
+	nPeers := 2
+	scheds := make([]event.AwareScheduler, nPeers)
+	ids := make([]kad.NodeInfo[key.Key256, net.IP], nPeers)
+	fakeEndpoints := make([]*Endpoint[key.Key256, net.IP], nPeers)
+	for i := 0; i < nPeers; i++ {
+		ids[i] = kadtest.NewInfo[key.Key256, net.IP](kadtest.NewID(kadtest.Key256WithLeadingBytes([]byte{byte(i)})), nil)
+		scheds[i] = event.NewSimpleScheduler(clk)
+		fakeEndpoints[i] = NewEndpoint[key.Key256, net.IP](ids[i].ID(), scheds[i], router)
+	}
+	fakeEndpoints[0].MaybeAddToPeerstore(ctx, ids[1], peerstoreTTL)
+	fakeEndpoints[0].SetMaxOutstanding(1)
+
+	protoA := address.ProtocolID("/test/a/1.0.0")
+	protoB := address.ProtocolID("/test/b/1.0.0")
+	echo := func(ctx context.Context, id kad.NodeID[key.Key256], req kad.Message) (kad.Message, error) {
+		return req, nil
+	}
+	fakeEndpoints[1].AddRequestHandler(protoA, nil, echo)
+	fakeEndpoints[1].AddRequestHandler(protoB, nil, echo)
+
+	var order []string
+	send := func(protoID address.ProtocolID, name string) endpoint.StreamID {
+		sid, err := fakeEndpoints[0].SendPipelinedRequest(ctx, protoID, ids[1].ID(),
+			NewResponse([]kad.NodeInfo[key.Key256, net.IP]{}), nil, time.Second,
+			func(ctx context.Context, resp kad.Response[key.Key256, net.IP], err error) {
+				require.NoError(t, err)
+				order = append(order, name)
+			})
+		require.NoError(t, err)
+		return sid
+	}
+
+	sids := map[endpoint.StreamID]struct{}{
+		send(protoA, "a1"): {},
+		send(protoA, "a2"): {},
+		send(protoB, "b1"): {},
+	}
+	// every request has its own stream id
+	require.Len(t, sids, 3)
+
+	// a single request is outstanding, the others are queued
+	require.Equal(t, 1, fakeEndpoints[0].Outstanding(ids[1].ID()))
+	require.Equal(t, 2, fakeEndpoints[0].Queued(ids[1].ID()))
+
+	for scheds[0].RunOne(ctx) || scheds[1].RunOne(ctx) {
+	}
+
+	// queued requests are sent in turn across protocols
+	require.Equal(t, []string{"a1", "b1", "a2"}, order)
+	require.Equal(t, 0, fakeEndpoints[0].Outstanding(ids[1].ID()))
+	require.Equal(t, 0, fakeEndpoints[0].Queued(ids[1].ID()))
+}
+
+func TestPipelinedRequestTimeout(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	router := NewRouter[key.Key256, net.IP]()
+
+	sched := event.NewSimpleScheduler(clk)
+	self := kadtest.NewID(kadtest.Key256WithLeadingBytes([]byte{0}))
+	remote := kadtest.NewInfo[key.Key256, net.IP](kadtest.NewID(kadtest.Key256WithLeadingBytes([]byte{1})), nil)
+	// the remote endpoint never handles the requests
+	NewEndpoint[key.Key256, net.IP](remote.ID(), event.NewSimpleScheduler(clk), router)
+
+	fe := NewEndpoint[key.Key256, net.IP](self, sched, router)
+	fe.MaybeAddToPeerstore(ctx, remote, peerstoreTTL)
+	fe.SetMaxOutstanding(1)
+
+	var errs []error
+	for i := 0; i < 2; i++ {
+		_, err := fe.SendPipelinedRequest(ctx, protoID, remote.ID(), nil, nil, time.Second,
+			func(ctx context.Context, resp kad.Response[key.Key256, net.IP], err error) {
+				errs = append(errs, err)
+			})
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, fe.Outstanding(remote.ID()))
+
+	// the first request times out, which sends the queued request
+	clk.Add(time.Second)
+	require.True(t, sched.RunOne(ctx))
+	require.Equal(t, []error{endpoint.ErrTimeout}, errs)
+	require.Equal(t, 1, fe.Outstanding(remote.ID()))
+	require.Equal(t, 0, fe.Queued(remote.ID()))
+
+	// the timeout of the second request starts when it is sent
+	require.False(t, sched.RunOne(ctx))
+	clk.Add(time.Second)
+	require.True(t, sched.RunOne(ctx))
+	require.Equal(t, []error{endpoint.ErrTimeout, endpoint.ErrTimeout}, errs)
+	require.Equal(t, 0, fe.Outstanding(remote.ID()))
+}
+
+func TestSendRequestNegotiate(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	router := NewRouter[key.Key256, net.IP]()
+
+	scheds := make([]event.AwareScheduler, 2)
+	ids := make([]kad.NodeInfo[key.Key256, net.IP], 2)
+	fakeEndpoints := make([]*Endpoint[key.Key256, net.IP], 2)
+	for i := range fakeEndpoints {
+		ids[i] = kadtest.NewInfo[key.Key256, net.IP](kadtest.NewID(kadtest.Key256WithLeadingBytes([]byte{byte(i)})), nil)
+		scheds[i] = event.NewSimpleScheduler(clk)
+		fakeEndpoints[i] = NewEndpoint[key.Key256, net.IP](ids[i].ID(), scheds[i], router)
+	}
+	fakeEndpoints[0].MaybeAddToPeerstore(ctx, ids[1], peerstoreTTL)
+
+	v1 := address.ProtocolID("/test/1.0.0")
+	v2 := address.ProtocolID("/test/2.0.0")
+	echo := func(ctx context.Context, id kad.NodeID[key.Key256], req kad.Message) (kad.Message, error) {
+		return req, nil
+	}
+	// the remote peer hasn't been upgraded to v2 yet
+	fakeEndpoints[1].AddRequestHandler(v1, nil, echo)
+
+	var used address.ProtocolID
+	var respErr error
+	handler := func(ctx context.Context, protoID address.ProtocolID, resp kad.Response[key.Key256, net.IP], err error) {
+		used, respErr = protoID, err
+	}
+	msg := NewResponse([]kad.NodeInfo[key.Key256, net.IP]{})
+	run := func() {
+		for scheds[0].RunOne(ctx) || scheds[1].RunOne(ctx) {
+		}
+	}
+
+	err := fakeEndpoints[0].SendRequestNegotiate(ctx, []address.ProtocolID{v2, v1}, ids[1].ID(), msg, nil, time.Second, handler)
+	require.NoError(t, err)
+	run()
+	require.NoError(t, respErr)
+	require.Equal(t, v1, used)
+
+	// once upgraded the preferred protocol is used
+	fakeEndpoints[1].AddRequestHandler(v2, nil, echo)
+	err = endpoint.SendRequestWithFallback[key.Key256, net.IP](ctx, fakeEndpoints[0], []address.ProtocolID{v2, v1}, ids[1].ID(), msg, nil, time.Second, handler)
+	require.NoError(t, err)
+	run()
+	require.NoError(t, respErr)
+	require.Equal(t, v2, used)
+
+	// no protocol in common
+	err = fakeEndpoints[0].SendRequestNegotiate(ctx, []address.ProtocolID{"/test/3.0.0"}, ids[1].ID(), msg, nil, time.Second, handler)
+	require.NoError(t, err)
+	run()
+	require.ErrorIs(t, respErr, endpoint.ErrProtocolNotSupported)
+	require.Equal(t, address.ProtocolID(""), used)
+}
+
+func TestEndpointMetrics(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	router := NewRouter[key.Key256, net.IP]()
+
+	scheds := make([]event.AwareScheduler, 2)
+	ids := make([]kad.NodeInfo[key.Key256, net.IP], 2)
+	fakeEndpoints := make([]*Endpoint[key.Key256, net.IP], 2)
+	for i := range fakeEndpoints {
+		ids[i] = kadtest.NewInfo[key.Key256, net.IP](kadtest.NewID(kadtest.Key256WithLeadingBytes([]byte{byte(i)})), nil)
+		scheds[i] = event.NewSimpleScheduler(clk)
+		fakeEndpoints[i] = NewEndpoint[key.Key256, net.IP](ids[i].ID(), scheds[i], router)
+	}
+	fakeEndpoints[0].MaybeAddToPeerstore(ctx, ids[1], peerstoreTTL)
+
+	respond := true
+	fakeEndpoints[1].AddRequestHandler(protoID, nil, func(ctx context.Context, id kad.NodeID[key.Key256], req kad.Message) (kad.Message, error) {
+		if !respond {
+			return nil, endpoint.ErrUnknownPeer
+		}
+		return req, nil
+	})
+
+	msg := NewResponse([]kad.NodeInfo[key.Key256, net.IP]{})
+	send := func() {
+		err := fakeEndpoints[0].SendRequestHandleResponse(ctx, protoID, ids[1].ID(), msg, nil, time.Second, nil)
+		require.NoError(t, err)
+	}
+
+	// the response is handled 10ms after the request is sent
+	send()
+	require.True(t, scheds[1].RunOne(ctx))
+	clk.Add(10 * time.Millisecond)
+	for scheds[0].RunOne(ctx) {
+	}
+
+	// the next request times out
+	respond = false
+	send()
+	require.True(t, scheds[1].RunOne(ctx))
+	clk.Add(time.Second)
+	for scheds[0].RunOne(ctx) {
+	}
+
+	m, ok := fakeEndpoints[0].Metrics(ids[1].ID())
+	require.True(t, ok)
+	require.Equal(t, 1, m.Successes)
+	require.Equal(t, 1, m.Failures)
+	require.Equal(t, 10*time.Millisecond, m.LastRTT)
+	require.Equal(t, clk.Now(), m.LastFailure)
+}
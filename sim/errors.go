@@ -1,9 +1,20 @@
 package sim
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
 
 var (
 	ErrNotNetworkedEndpoint = errors.New("endpoint is not a NetworkedEndpoint")
 	ErrUnknownMessageFormat = errors.New("unknown message format")
-	ErrInvalidResponseType  = errors.New("invalid response type, expected MinKadResponseMessage")
+	ErrInvalidResponseType  = fmt.Errorf("%w: invalid response type, expected MinKadResponseMessage", endpoint.ErrGarbageResponse)
+	ErrInvalidStep          = errors.New("step is out of range")
+	ErrNonDeterministic     = errors.New("simulation diverged from its recorded history")
+	ErrUnauthenticated      = errors.New("message sender could not be authenticated")
+	ErrLivelock             = errors.New("simulation stopped making progress")
+	ErrIncompleteTrace      = errors.New("trace cannot be replayed")
+	ErrInvariantBroken      = errors.New("simulation invariant broken")
 )
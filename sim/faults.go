@@ -0,0 +1,146 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+)
+
+// LinkFaults specifies the faults a Router injects in the messages sent over a link, to exercise
+// the timeout and retry behaviour of protocols.
+type LinkFaults struct {
+	Drop      float64 // the probability that a message is lost
+	Duplicate float64 // the probability that a message that is not lost is delivered twice
+}
+
+// Validate checks the probabilities and returns an error if any is not between 0 and 1.
+func (f *LinkFaults) Validate() error {
+	if f.Drop < 0 || f.Drop > 1 {
+		return &kaderr.ConfigurationError{
+			Component: "LinkFaults",
+			Err:       fmt.Errorf("drop probability must be between 0 and 1"),
+		}
+	}
+	if f.Duplicate < 0 || f.Duplicate > 1 {
+		return &kaderr.ConfigurationError{
+			Component: "LinkFaults",
+			Err:       fmt.Errorf("duplicate probability must be between 0 and 1"),
+		}
+	}
+	return nil
+}
+
+// RouterStats counts the faults a Router injected and the messages it lost.
+type RouterStats struct {
+	Dropped     uint64 // the number of messages lost
+	Duplicated  uint64 // the number of messages delivered twice
+	Blocked     uint64 // the number of messages lost to a partition
+	Intercepted uint64 // the number of messages dropped by an interceptor
+	Down        uint64 // the number of messages lost to their recipient being down
+}
+
+// SetDefaultFaults sets the faults injected in the links that have none set with SetLinkFaults.
+func (r *Router[K, A]) SetDefaultFaults(f LinkFaults) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	r.defaultFaults = f
+	return nil
+}
+
+// SetLinkFaults sets the faults injected in the messages sent by from to to, leaving the opposite
+// direction as is.
+func (r *Router[K, A]) SetLinkFaults(from, to kad.NodeID[K], f LinkFaults) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	r.faults[indexLink{from: r.indexOf(from), to: r.indexOf(to)}] = f
+	return nil
+}
+
+// SetFaultSeed seeds the pseudo-random generator deciding which messages are dropped or
+// duplicated, so that simulations using the same seed inject the same faults.
+func (r *Router[K, A]) SetFaultSeed(seed int64) {
+	r.rng = rand.New(rand.NewSource(seed))
+}
+
+// Stats returns the number of faults the router injected.
+func (r *Router[K, A]) Stats() RouterStats {
+	return r.stats
+}
+
+// injectFaults decides the fate of a message sent by from to to, returning the number of times
+// it must be delivered.
+func (r *Router[K, A]) injectFaults(from, to int32) int {
+	if r.partitioned(from, to) {
+		r.stats.Blocked++
+		return 0
+	}
+	f := r.defaultFaults
+	if len(r.faults) > 0 {
+		if lf, ok := r.faults[indexLink{from: from, to: to}]; ok {
+			f = lf
+		}
+	}
+	if f.Drop > 0 && r.rng.Float64() < f.Drop {
+		r.stats.Dropped++
+		return 0
+	}
+	if f.Duplicate > 0 && r.rng.Float64() < f.Duplicate {
+		r.stats.Duplicated++
+		return 2
+	}
+	return 1
+}
+
+// Partition blocks the messages between the nodes of groupA and the nodes of groupB, in both
+// directions, until Heal is called. Messages sent across the partition, or in transit when it
+// starts, are lost, so that requests across it time out. Partitions add up: a node may be cut from
+// several groups.
+func (r *Router[K, A]) Partition(groupA, groupB []kad.NodeID[K]) {
+	for _, a := range groupA {
+		ai := r.indexOf(a)
+		for _, b := range groupB {
+			bi := r.indexOf(b)
+			r.partitions[indexLink{from: ai, to: bi}] = struct{}{}
+			r.partitions[indexLink{from: bi, to: ai}] = struct{}{}
+		}
+	}
+}
+
+// PartitionFor partitions groupA from groupB like Partition, and removes this partition once d
+// has elapsed on sched. Other partitions are left as they are.
+func (r *Router[K, A]) PartitionFor(ctx context.Context, sched event.Scheduler, d time.Duration,
+	groupA, groupB []kad.NodeID[K],
+) {
+	r.Partition(groupA, groupB)
+	event.ScheduleActionIn(ctx, sched, d, event.BasicAction(func(context.Context) {
+		for _, a := range groupA {
+			ai := r.indexOf(a)
+			for _, b := range groupB {
+				bi := r.indexOf(b)
+				delete(r.partitions, indexLink{from: ai, to: bi})
+				delete(r.partitions, indexLink{from: bi, to: ai})
+			}
+		}
+	}))
+}
+
+// Heal removes all partitions, restoring the delivery of messages between all nodes.
+func (r *Router[K, A]) Heal() {
+	r.partitions = make(map[indexLink]struct{})
+}
+
+// partitioned reports whether a partition blocks the messages sent by from to to.
+func (r *Router[K, A]) partitioned(from, to int32) bool {
+	if len(r.partitions) == 0 {
+		return false
+	}
+	_, ok := r.partitions[indexLink{from: from, to: to}]
+	return ok
+}
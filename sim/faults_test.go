@@ -0,0 +1,193 @@
+package sim
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+func TestLinkFaultsValidate(t *testing.T) {
+	require.NoError(t, (&LinkFaults{}).Validate())
+	require.NoError(t, (&LinkFaults{Drop: 1, Duplicate: 1}).Validate())
+	require.Error(t, (&LinkFaults{Drop: -0.1}).Validate())
+	require.Error(t, (&LinkFaults{Drop: 1.1}).Validate())
+	require.Error(t, (&LinkFaults{Duplicate: -0.1}).Validate())
+	require.Error(t, (&LinkFaults{Duplicate: 1.1}).Validate())
+
+	router := NewRouter[key.Key8, net.IP]()
+	require.Error(t, router.SetDefaultFaults(LinkFaults{Drop: 2}))
+	require.Error(t, router.SetLinkFaults(kadtest.NewID(key.Key8(0)), kadtest.NewID(key.Key8(1)), LinkFaults{Drop: 2}))
+}
+
+// countDelivered sends n messages from a to b and returns the number of messages b received.
+func countDelivered(t *testing.T, router *Router[key.Key8, net.IP], n int) int {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	a, b := kadtest.NewID(key.Key8(0)), kadtest.NewID(key.Key8(1))
+	NewEndpoint[key.Key8, net.IP](a, event.NewSimpleScheduler(clk), router)
+	sched := event.NewSimpleScheduler(clk)
+	NewEndpoint[key.Key8, net.IP](b, sched, router)
+
+	for i := 0; i < n; i++ {
+		_, err := router.SendMessage(ctx, a, b, address.ProtocolID("/test/proto"), 0, nil)
+		require.NoError(t, err)
+	}
+	var delivered int
+	for sched.RunOne(ctx) {
+		delivered++
+	}
+	return delivered
+}
+
+func TestRouterFaults(t *testing.T) {
+	t.Run("drop", func(t *testing.T) {
+		router := NewRouter[key.Key8, net.IP]()
+		require.NoError(t, router.SetDefaultFaults(LinkFaults{Drop: 1}))
+		require.Zero(t, countDelivered(t, router, 10))
+		require.Equal(t, RouterStats{Dropped: 10}, router.Stats())
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		router := NewRouter[key.Key8, net.IP]()
+		require.NoError(t, router.SetDefaultFaults(LinkFaults{Duplicate: 1}))
+		require.Equal(t, 20, countDelivered(t, router, 10))
+		require.Equal(t, RouterStats{Duplicated: 10}, router.Stats())
+	})
+
+	t.Run("per link", func(t *testing.T) {
+		router := NewRouter[key.Key8, net.IP]()
+		require.NoError(t, router.SetDefaultFaults(LinkFaults{Drop: 1}))
+		require.NoError(t, router.SetLinkFaults(kadtest.NewID(key.Key8(0)), kadtest.NewID(key.Key8(1)), LinkFaults{}))
+		require.Equal(t, 10, countDelivered(t, router, 10))
+	})
+
+	t.Run("seeded", func(t *testing.T) {
+		run := func(seed int64) (int, RouterStats) {
+			router := NewRouter[key.Key8, net.IP]()
+			router.SetFaultSeed(seed)
+			require.NoError(t, router.SetDefaultFaults(LinkFaults{Drop: 0.5, Duplicate: 0.5}))
+			return countDelivered(t, router, 1000), router.Stats()
+		}
+		delivered, stats := run(1)
+		require.InDelta(t, 500, stats.Dropped, 100)
+		require.InDelta(t, 250, stats.Duplicated, 100)
+		require.EqualValues(t, 1000-stats.Dropped+stats.Duplicated, delivered)
+
+		// the same seed injects the same faults
+		again, againStats := run(1)
+		require.Equal(t, delivered, again)
+		require.Equal(t, stats, againStats)
+	})
+}
+
+func TestRequestFaults(t *testing.T) {
+	ctx := context.Background()
+	protoID := address.ProtocolID("/test/proto")
+
+	// request sends a request from a node to another and returns the errors its response handler
+	// was called with, and the number of times the request handler was called
+	request := func(f LinkFaults) ([]error, int) {
+		clk := clock.NewMock()
+		router := NewRouter[key.Key8, net.IP]()
+		require.NoError(t, router.SetDefaultFaults(f))
+
+		sim := NewLiteSimulator(clk)
+		endpoints := make([]*Endpoint[key.Key8, net.IP], 2)
+		for i := range endpoints {
+			sched := event.NewSimpleScheduler(clk)
+			endpoints[i] = NewEndpoint[key.Key8, net.IP](kadtest.NewID(key.Key8(i)), sched, router)
+			sim.Add(sched)
+		}
+		var handled int
+		require.NoError(t, endpoints[1].AddRequestHandler(protoID, &Message[key.Key8, net.IP]{},
+			func(ctx context.Context, id kad.NodeID[key.Key8], req kad.Message) (kad.Message, error) {
+				handled++
+				return &Message[key.Key8, net.IP]{}, nil
+			}))
+		require.NoError(t, endpoints[0].MaybeAddToPeerstore(ctx,
+			kadtest.NewInfo[key.Key8, net.IP](endpoints[1].self.(*kadtest.ID[key.Key8]), nil), time.Hour))
+
+		var errs []error
+		err := endpoints[0].SendRequestHandleResponse(ctx, protoID, endpoints[1].self, &Message[key.Key8, net.IP]{},
+			&Message[key.Key8, net.IP]{}, time.Second, func(ctx context.Context, resp kad.Response[key.Key8, net.IP], err error) {
+				errs = append(errs, err)
+			})
+		require.NoError(t, err)
+		sim.Run(ctx)
+		return errs, handled
+	}
+
+	// a lost request times out
+	errs, handled := request(LinkFaults{Drop: 1})
+	require.Len(t, errs, 1)
+	require.ErrorIs(t, errs[0], endpoint.ErrTimeout)
+	require.Zero(t, handled)
+
+	// a duplicated request is handled twice, but its response only once
+	errs, handled = request(LinkFaults{Duplicate: 1})
+	require.Equal(t, []error{nil}, errs)
+	require.Equal(t, 2, handled)
+}
+
+func TestRouterPartition(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	router := NewRouter[key.Key8, net.IP]()
+	protoID := address.ProtocolID("/test/proto")
+
+	ids := make([]kad.NodeID[key.Key8], 4)
+	scheds := make([]*event.SimpleScheduler, len(ids))
+	for i := range ids {
+		ids[i] = kadtest.NewID(key.Key8(i))
+		scheds[i] = event.NewSimpleScheduler(clk)
+		NewEndpoint[key.Key8, net.IP](ids[i], scheds[i], router)
+	}
+	// delivered reports whether a message sent by from reaches to
+	delivered := func(from, to int) bool {
+		_, err := router.SendMessage(ctx, ids[from], ids[to], protoID, 0, nil)
+		require.NoError(t, err)
+		return scheds[to].RunOne(ctx)
+	}
+
+	router.Partition(ids[:2], ids[2:])
+	require.True(t, delivered(0, 1))
+	require.True(t, delivered(2, 3))
+	require.False(t, delivered(0, 2))
+	require.False(t, delivered(3, 1))
+	require.EqualValues(t, 2, router.Stats().Blocked)
+
+	router.Heal()
+	require.True(t, delivered(0, 2))
+	require.True(t, delivered(3, 1))
+
+	// messages in transit when the partition starts are lost
+	router.SetLatencyModel(ConstantLatency[key.Key8](time.Second))
+	_, err := router.SendMessage(ctx, ids[0], ids[2], protoID, 0, nil)
+	require.NoError(t, err)
+	router.Partition(ids[:1], ids[2:3])
+	clk.Add(time.Second)
+	require.True(t, scheds[2].RunOne(ctx))
+	require.EqualValues(t, 3, router.Stats().Blocked)
+	router.Heal()
+	router.SetLatencyModel(nil)
+
+	// a partition for a period heals on its own, leaving other partitions
+	router.Partition(ids[:1], ids[3:])
+	router.PartitionFor(ctx, scheds[0], time.Minute, ids[:1], ids[1:2])
+	require.False(t, delivered(0, 1))
+	clk.Add(time.Minute)
+	event.RunAll(ctx, scheds[0])
+	require.True(t, delivered(0, 1))
+	require.False(t, delivered(0, 3))
+}
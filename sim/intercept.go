@@ -0,0 +1,78 @@
+package sim
+
+import (
+	"time"
+
+	"github.com/plprobelab/go-kademlia/kad"
+)
+
+// Verdict is the decision of an Interceptor on a message carried by a Router. The zero Verdict
+// lets the message through unchanged.
+type Verdict struct {
+	Drop    bool          // whether the message is lost
+	Replace kad.Message   // the message delivered instead, nil to deliver the message sent
+	Delay   time.Duration // the time added to the delivery of the message
+}
+
+// Interceptor decides the fate of a message sent by from to to, before the router injects its
+// faults. msg is the message as sent, possibly wrapped in an authentication or push envelope.
+type Interceptor[K kad.Key[K]] func(from, to kad.NodeID[K], msg kad.Message) Verdict
+
+// AddInterceptor adds an interceptor consulted for each message the router carries, to build
+// precise failure scenarios. Interceptors are consulted in the order they were added, each
+// seeing the message replaced by the ones before, until one drops the message. Their delays add
+// up.
+func (r *Router[K, A]) AddInterceptor(i Interceptor[K]) {
+	r.interceptors = append(r.interceptors, i)
+}
+
+// ClearInterceptors removes all the interceptors of the router.
+func (r *Router[K, A]) ClearInterceptors() {
+	r.interceptors = nil
+}
+
+// intercept consults the interceptors on a message sent by from to to, returning the message to
+// deliver, the delay to add to its delivery, and false if the message is dropped.
+func (r *Router[K, A]) intercept(from, to kad.NodeID[K], msg kad.Message) (kad.Message, time.Duration, bool) {
+	var delay time.Duration
+	for _, i := range r.interceptors {
+		v := i(from, to, msg)
+		if v.Drop {
+			r.stats.Intercepted++
+			return nil, 0, false
+		}
+		if v.Replace != nil {
+			msg = v.Replace
+		}
+		delay += v.Delay
+	}
+	return msg, delay, true
+}
+
+// DropNth returns an interceptor dropping the n-th message sent by from to to that match
+// accepts, counting from 1, as in "drop the third request from A to B". match is given the
+// message out of its envelopes, and a nil match accepts all messages.
+func DropNth[K kad.Key[K]](from, to kad.NodeID[K], n int, match func(kad.Message) bool) Interceptor[K] {
+	var seen int
+	return func(f, t kad.NodeID[K], msg kad.Message) Verdict {
+		if f.String() != from.String() || t.String() != to.String() {
+			return Verdict{}
+		}
+		if match != nil && !match(unwrapMessage(msg)) {
+			return Verdict{}
+		}
+		seen++
+		return Verdict{Drop: seen == n}
+	}
+}
+
+// unwrapMessage returns msg out of its authentication and push envelopes.
+func unwrapMessage(msg kad.Message) kad.Message {
+	if am, ok := msg.(*Authenticated); ok {
+		msg = am.Message
+	}
+	if pm, ok := msg.(*pushedMessage); ok {
+		msg = pm.Message
+	}
+	return msg
+}
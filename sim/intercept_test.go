@@ -0,0 +1,89 @@
+package sim
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+)
+
+func TestRouterInterceptors(t *testing.T) {
+	ctx := context.Background()
+	protoID := address.ProtocolID("/test/proto")
+	a, b, c := kadtest.NewID(key.Key8(0)), kadtest.NewID(key.Key8(1)), kadtest.NewID(key.Key8(2))
+
+	// setup connects a and c to b and returns the targets of the requests b receives, with the
+	// time they were received
+	setup := func() (*Router[key.Key8, net.IP], *clock.Mock, *event.SimpleScheduler, *[]key.Key8, *[]time.Time) {
+		clk := clock.NewMock()
+		router := NewRouter[key.Key8, net.IP]()
+		NewEndpoint[key.Key8, net.IP](a, event.NewSimpleScheduler(clk), router)
+		NewEndpoint[key.Key8, net.IP](c, event.NewSimpleScheduler(clk), router)
+		sched := event.NewSimpleScheduler(clk)
+		eb := NewEndpoint[key.Key8, net.IP](b, sched, router)
+		var targets []key.Key8
+		var times []time.Time
+		require.NoError(t, eb.AddRequestHandler(protoID, &Message[key.Key8, net.IP]{},
+			func(ctx context.Context, id kad.NodeID[key.Key8], req kad.Message) (kad.Message, error) {
+				targets = append(targets, req.(*Message[key.Key8, net.IP]).Target())
+				times = append(times, clk.Now())
+				return nil, nil
+			}))
+		return router, clk, sched, &targets, &times
+	}
+	send := func(router *Router[key.Key8, net.IP], from kad.NodeID[key.Key8], targets ...key.Key8) {
+		for _, tgt := range targets {
+			_, err := router.SendMessage(ctx, from, b, protoID, 0, NewRequest[key.Key8, net.IP](tgt))
+			require.NoError(t, err)
+		}
+	}
+
+	t.Run("drop nth", func(t *testing.T) {
+		router, _, sched, targets, _ := setup()
+		isRequest := func(msg kad.Message) bool {
+			m, ok := msg.(*Message[key.Key8, net.IP])
+			return ok && m.closerPeers == nil
+		}
+		router.AddInterceptor(DropNth[key.Key8](a, b, 3, isRequest))
+		send(router, a, 1, 2, 3, 4)
+		send(router, c, 5, 6, 7)
+		for sched.RunOne(ctx) {
+		}
+		// only the third request from a to b is dropped
+		require.Equal(t, []key.Key8{1, 2, 4, 5, 6, 7}, *targets)
+		require.EqualValues(t, 1, router.Stats().Intercepted)
+	})
+
+	t.Run("replace and delay", func(t *testing.T) {
+		router, clk, sched, targets, times := setup()
+		start := clk.Now()
+		router.AddInterceptor(func(from, to kad.NodeID[key.Key8], msg kad.Message) Verdict {
+			return Verdict{Replace: NewRequest[key.Key8, net.IP](key.Key8(42)), Delay: time.Second}
+		})
+		router.AddInterceptor(func(from, to kad.NodeID[key.Key8], msg kad.Message) Verdict {
+			// later interceptors see the replaced message
+			require.Equal(t, key.Key8(42), msg.(*Message[key.Key8, net.IP]).Target())
+			return Verdict{Delay: time.Second}
+		})
+		send(router, a, 1)
+		require.False(t, sched.RunOne(ctx))
+		clk.Add(2 * time.Second)
+		require.True(t, sched.RunOne(ctx))
+		require.Equal(t, []key.Key8{42}, *targets)
+		require.Equal(t, []time.Time{start.Add(2 * time.Second)}, *times)
+
+		router.ClearInterceptors()
+		send(router, a, 1)
+		require.True(t, sched.RunOne(ctx))
+		require.Equal(t, []key.Key8{42, 1}, *targets)
+	})
+}
@@ -0,0 +1,114 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/plprobelab/go-kademlia/kad"
+)
+
+// Invariant is a property of a simulation that must hold throughout, checked by a Runner.
+type Invariant struct {
+	Name  string                          // describes the invariant in errors
+	Check func(ctx context.Context) error // returns an error describing how the invariant is broken, nil if it holds
+}
+
+// InvariantError is the error returned by Runner.Run when an invariant is broken, telling the
+// step and virtual time at which it was found broken.
+type InvariantError struct {
+	Name string
+	Step int       // the number of steps run when the invariant was found broken
+	Time time.Time // the virtual time at which the invariant was found broken
+	Err  error     // describes how the invariant is broken
+}
+
+func (e *InvariantError) Error() string {
+	return fmt.Sprintf("invariant %q broken at step %d (%s): %v", e.Name, e.Step, e.Time, e.Err)
+}
+
+// Is makes InvariantErrors match ErrInvariantBroken.
+func (e *InvariantError) Is(target error) bool {
+	return target == ErrInvariantBroken
+}
+
+func (e *InvariantError) Unwrap() error {
+	return e.Err
+}
+
+// RoutingTablesLive is the invariant that the routing tables of the nodes of n only contain nodes
+// that are in the router of n and not down.
+func RoutingTablesLive[K kad.Key[K], A kad.Address[A]](n *Network[K, A]) Invariant {
+	return Invariant{
+		Name: "routing tables contain only live nodes",
+		Check: func(ctx context.Context) error {
+			for _, node := range n.Nodes {
+				for _, id := range node.routingTableNodes() {
+					if _, _, ok := n.Router.peerOf(id.String()); !ok {
+						return fmt.Errorf("node %s holds %s, which left", node.Info.ID(), id)
+					}
+					if n.Router.IsNodeDown(id) {
+						return fmt.Errorf("node %s holds %s, which is down", node.Info.ID(), id)
+					}
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// BucketsAtMost is the invariant that no bucket of the routing tables of the nodes of n holds
+// more than k nodes. The buckets of routing tables reporting their sizes, as simplert does, are
+// checked, other routing tables are checked assuming a bucket for each common prefix length with
+// the local node.
+func BucketsAtMost[K kad.Key[K], A kad.Address[A]](n *Network[K, A], k int) Invariant {
+	return Invariant{
+		Name: fmt.Sprintf("every bucket holds at most %d nodes", k),
+		Check: func(ctx context.Context) error {
+			for _, node := range n.Nodes {
+				if b, ok := node.RoutingTable.(interface {
+					NBuckets() int
+					SizeOfBucket(int) int
+				}); ok {
+					for i := 0; i < b.NBuckets(); i++ {
+						if size := b.SizeOfBucket(i); size > k {
+							return fmt.Errorf("bucket %d of node %s holds %d nodes", i, node.Info.ID(), size)
+						}
+					}
+					continue
+				}
+				self := node.Info.ID().Key()
+				sizes := make(map[int]int)
+				for _, id := range node.routingTableNodes() {
+					cpl := self.CommonPrefixLength(id.Key())
+					if sizes[cpl]++; sizes[cpl] > k {
+						return fmt.Errorf("bucket %d of node %s holds more than %d nodes", cpl, node.Info.ID(), k)
+					}
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// OutstandingRequestsValid is the invariant that the counters of outstanding requests of the
+// connections of the nodes of n are never negative, nor above the limit of their endpoint.
+func OutstandingRequestsValid[K kad.Key[K], A kad.Address[A]](n *Network[K, A]) Invariant {
+	return Invariant{
+		Name: "outstanding request counters are within bounds",
+		Check: func(ctx context.Context) error {
+			for _, node := range n.Nodes {
+				e := node.Endpoint
+				e.streamMu.Lock()
+				for id, c := range e.conns {
+					if c.outstanding < 0 || (e.maxOutstanding > 0 && c.outstanding > e.maxOutstanding) {
+						e.streamMu.Unlock()
+						return fmt.Errorf("node %s has %d outstanding requests to %s", node.Info.ID(), c.outstanding, id)
+					}
+				}
+				e.streamMu.Unlock()
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,99 @@
+package sim
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+func TestRunnerInvariants(t *testing.T) {
+	ctx := context.Background()
+
+	// setup creates a network of nodes knowing the first of them
+	setup := func() *Network[key.Key8, net.IP] {
+		infos := make([]kad.NodeInfo[key.Key8, net.IP], 4)
+		for i := range infos {
+			infos[i] = kadtest.NewInfo[key.Key8, net.IP](kadtest.NewID(key.Key8(i)), nil)
+		}
+		cfg := DefaultNetworkConfig[key.Key8]()
+		cfg.Topology = StarTopology()
+		n, err := NewNetwork(ctx, clock.NewMock(), infos, cfg)
+		require.NoError(t, err)
+		return n
+	}
+
+	t.Run("hold", func(t *testing.T) {
+		n := setup()
+		for i := 1; i <= 3; i++ {
+			event.ScheduleActionIn(ctx, n.Nodes[i].Scheduler, time.Duration(i)*time.Second, event.BasicAction(func(context.Context) {}))
+		}
+		r, err := NewRunner(n.Simulator, &RunnerConfig{Invariants: []Invariant{
+			RoutingTablesLive(n), BucketsAtMost(n, 20), OutstandingRequestsValid(n),
+		}})
+		require.NoError(t, err)
+		res, err := r.Run(ctx)
+		require.NoError(t, err)
+		require.Equal(t, StopQuiescent, res.Reason)
+		require.Equal(t, 3, res.Steps)
+	})
+
+	t.Run("broken during the run", func(t *testing.T) {
+		n := setup()
+		start := n.Clock.Now()
+		// the third node goes down after five seconds, without the others noticing
+		event.ScheduleActionIn(ctx, n.Nodes[3].Scheduler, 5*time.Second, event.BasicAction(func(context.Context) {
+			n.Router.SetNodeDown(n.Nodes[3].Info.ID())
+		}))
+		event.ScheduleActionIn(ctx, n.Nodes[1].Scheduler, 10*time.Second, event.BasicAction(func(context.Context) {}))
+
+		r, err := NewRunner(n.Simulator, &RunnerConfig{Invariants: []Invariant{RoutingTablesLive(n)}})
+		require.NoError(t, err)
+		res, err := r.Run(ctx)
+		require.ErrorIs(t, err, ErrInvariantBroken)
+		require.Equal(t, StopInvariant, res.Reason)
+
+		var ierr *InvariantError
+		require.True(t, errors.As(err, &ierr))
+		require.Equal(t, RoutingTablesLive(n).Name, ierr.Name)
+		require.Equal(t, 1, ierr.Step)
+		require.Equal(t, start.Add(5*time.Second), ierr.Time)
+		require.Contains(t, ierr.Error(), "down")
+	})
+
+	t.Run("broken from the start", func(t *testing.T) {
+		n := setup()
+		r, err := NewRunner(n.Simulator, &RunnerConfig{Invariants: []Invariant{BucketsAtMost(n, 1)}})
+		require.NoError(t, err)
+		res, err := r.Run(ctx)
+		require.ErrorIs(t, err, ErrInvariantBroken)
+		require.Equal(t, StopInvariant, res.Reason)
+		require.Zero(t, res.Steps)
+	})
+
+	t.Run("interval", func(t *testing.T) {
+		n := setup()
+		for i := 1; i <= 4; i++ {
+			event.ScheduleActionIn(ctx, n.Nodes[1].Scheduler, time.Duration(i)*time.Second, event.BasicAction(func(context.Context) {}))
+		}
+		checks := 0
+		r, err := NewRunner(n.Simulator, &RunnerConfig{
+			Invariants:        []Invariant{{Name: "count", Check: func(context.Context) error { checks++; return nil }}},
+			InvariantInterval: 2,
+		})
+		require.NoError(t, err)
+		_, err = r.Run(ctx)
+		require.NoError(t, err)
+		// before the first step, and after the second and fourth
+		require.Equal(t, 3, checks)
+	})
+}
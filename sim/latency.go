@@ -0,0 +1,118 @@
+package sim
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+// LatencyModel gives the time messages take to travel between two nodes of a simulation. A
+// Router delivers each message to the scheduler of its recipient once the latency of the link has
+// elapsed after it was sent.
+type LatencyModel[K kad.Key[K]] interface {
+	// Latency returns the latency of a message sent by from to to. It is called once for each
+	// message, so that it may vary between messages.
+	Latency(from, to kad.NodeID[K]) time.Duration
+}
+
+// ConstantLatency is a LatencyModel in which all messages take the same time.
+type ConstantLatency[K kad.Key[K]] time.Duration
+
+var _ LatencyModel[key.Key256] = ConstantLatency[key.Key256](0)
+
+// Latency returns the constant latency for any link.
+func (l ConstantLatency[K]) Latency(from, to kad.NodeID[K]) time.Duration {
+	return time.Duration(l)
+}
+
+// UniformLatency is a LatencyModel in which the latency of each message is drawn uniformly
+// between two bounds from a seeded pseudo-random generator, so that simulations using the same
+// seed are reproducible.
+type UniformLatency[K kad.Key[K]] struct {
+	min, max time.Duration
+	rng      *rand.Rand
+}
+
+var _ LatencyModel[key.Key256] = (*UniformLatency[key.Key256])(nil)
+
+// NewUniformLatency creates a UniformLatency drawing its latencies in [min, max) from a generator
+// seeded with seed. A max lower than or equal to min gives a constant latency of min.
+func NewUniformLatency[K kad.Key[K]](min, max time.Duration, seed int64) *UniformLatency[K] {
+	return &UniformLatency[K]{
+		min: min,
+		max: max,
+		rng: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Latency returns a latency drawn uniformly between the bounds of the model.
+func (l *UniformLatency[K]) Latency(from, to kad.NodeID[K]) time.Duration {
+	if l.max <= l.min {
+		return l.min
+	}
+	return l.min + time.Duration(l.rng.Int63n(int64(l.max-l.min)))
+}
+
+// DistanceLatency is a LatencyModel in which the latency of a link grows linearly with the XOR
+// distance between the keys of its nodes, measured as the number of bits after their common
+// prefix: nodes with the same key are Min apart, nodes differing on their first bit are Max apart.
+type DistanceLatency[K kad.Key[K]] struct {
+	Min time.Duration // the latency between the closest nodes
+	Max time.Duration // the latency between the most distant nodes
+}
+
+var _ LatencyModel[key.Key256] = (*DistanceLatency[key.Key256])(nil)
+
+// Latency returns the latency of the link for the distance between its nodes.
+func (l *DistanceLatency[K]) Latency(from, to kad.NodeID[K]) time.Duration {
+	fk, tk := from.Key(), to.Key()
+	bits := fk.BitLen()
+	if bits == 0 {
+		return l.Min
+	}
+	far := key.LogDistance(fk, tk)
+	return l.Min + (l.Max-l.Min)*time.Duration(far)/time.Duration(bits)
+}
+
+// MatrixLatency is a LatencyModel with a latency set for each link, and a default latency for the
+// links that have none.
+type MatrixLatency[K kad.Key[K]] struct {
+	def   time.Duration
+	links map[link]time.Duration
+}
+
+var _ LatencyModel[key.Key256] = (*MatrixLatency[key.Key256])(nil)
+
+// link is a directed link between two nodes, identified by their string representation.
+type link struct {
+	from, to string
+}
+
+// NewMatrixLatency creates a MatrixLatency with the latency def for the links that have none set.
+func NewMatrixLatency[K kad.Key[K]](def time.Duration) *MatrixLatency[K] {
+	return &MatrixLatency[K]{
+		def:   def,
+		links: make(map[link]time.Duration),
+	}
+}
+
+// Set sets the latency of the links between a and b, in both directions.
+func (l *MatrixLatency[K]) Set(a, b kad.NodeID[K], d time.Duration) {
+	l.links[link{from: a.String(), to: b.String()}] = d
+	l.links[link{from: b.String(), to: a.String()}] = d
+}
+
+// SetDirected sets the latency of the link from from to to, leaving the opposite direction as is.
+func (l *MatrixLatency[K]) SetDirected(from, to kad.NodeID[K], d time.Duration) {
+	l.links[link{from: from.String(), to: to.String()}] = d
+}
+
+// Latency returns the latency set for the link, or the default latency.
+func (l *MatrixLatency[K]) Latency(from, to kad.NodeID[K]) time.Duration {
+	if d, ok := l.links[link{from: from.String(), to: to.String()}]; ok {
+		return d
+	}
+	return l.def
+}
@@ -0,0 +1,124 @@
+package sim
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+)
+
+func TestLatencyModels(t *testing.T) {
+	a := kadtest.NewID(key.Key8(0b00000000))
+	b := kadtest.NewID(key.Key8(0b00001111))
+	c := kadtest.NewID(key.Key8(0b10000000))
+
+	t.Run("constant", func(t *testing.T) {
+		l := ConstantLatency[key.Key8](10 * time.Millisecond)
+		require.Equal(t, 10*time.Millisecond, l.Latency(a, b))
+	})
+
+	t.Run("uniform", func(t *testing.T) {
+		l := NewUniformLatency[key.Key8](10*time.Millisecond, 20*time.Millisecond, 1)
+		same := NewUniformLatency[key.Key8](10*time.Millisecond, 20*time.Millisecond, 1)
+		for i := 0; i < 100; i++ {
+			d := l.Latency(a, b)
+			require.GreaterOrEqual(t, d, 10*time.Millisecond)
+			require.Less(t, d, 20*time.Millisecond)
+			// the same seed draws the same latencies
+			require.Equal(t, d, same.Latency(a, b))
+		}
+		require.Equal(t, time.Second, NewUniformLatency[key.Key8](time.Second, 0, 1).Latency(a, b))
+	})
+
+	t.Run("distance", func(t *testing.T) {
+		l := &DistanceLatency[key.Key8]{Min: 0, Max: 80 * time.Millisecond}
+		require.Equal(t, time.Duration(0), l.Latency(a, a))
+		require.Equal(t, 40*time.Millisecond, l.Latency(a, b))
+		require.Equal(t, 80*time.Millisecond, l.Latency(a, c))
+	})
+
+	t.Run("matrix", func(t *testing.T) {
+		l := NewMatrixLatency[key.Key8](time.Second)
+		l.Set(a, b, time.Millisecond)
+		l.SetDirected(a, c, 2*time.Millisecond)
+		require.Equal(t, time.Millisecond, l.Latency(a, b))
+		require.Equal(t, time.Millisecond, l.Latency(b, a))
+		require.Equal(t, 2*time.Millisecond, l.Latency(a, c))
+		require.Equal(t, time.Second, l.Latency(c, a))
+	})
+}
+
+func TestRouterLatency(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	router := NewRouter[key.Key8, net.IP]()
+	router.SetLatencyModel(ConstantLatency[key.Key8](50 * time.Millisecond))
+
+	ids := make([]kad.NodeID[key.Key8], 2)
+	scheds := make([]*event.SimpleScheduler, 2)
+	for i := range ids {
+		ids[i] = kadtest.NewID(key.Key8(i))
+		scheds[i] = event.NewSimpleScheduler(clk)
+		NewEndpoint[key.Key8, net.IP](ids[i], scheds[i], router)
+	}
+
+	_, err := router.SendMessage(ctx, ids[0], ids[1], address.ProtocolID("/test/proto"), 0, nil)
+	require.NoError(t, err)
+
+	// the message is delivered once the latency has elapsed
+	require.False(t, scheds[1].RunOne(ctx))
+	require.Equal(t, clk.Now().Add(50*time.Millisecond), scheds[1].NextActionTime(ctx))
+	clk.Add(50 * time.Millisecond)
+	require.True(t, scheds[1].RunOne(ctx))
+
+	// messages in transit to a peer that left are dropped
+	_, err = router.SendMessage(ctx, ids[0], ids[1], address.ProtocolID("/test/proto"), 0, nil)
+	require.NoError(t, err)
+	router.RemovePeer(ids[1])
+	clk.Add(50 * time.Millisecond)
+	require.True(t, scheds[1].RunOne(ctx))
+}
+
+func TestRequestLatency(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	router := NewRouter[key.Key8, net.IP]()
+	router.SetLatencyModel(ConstantLatency[key.Key8](30 * time.Millisecond))
+
+	protoID := address.ProtocolID("/test/proto")
+	sim := NewLiteSimulator(clk)
+	endpoints := make([]*Endpoint[key.Key8, net.IP], 2)
+	for i := range endpoints {
+		sched := event.NewSimpleScheduler(clk)
+		endpoints[i] = NewEndpoint[key.Key8, net.IP](kadtest.NewID(key.Key8(i)), sched, router)
+		sim.Add(sched)
+	}
+	require.NoError(t, endpoints[1].AddRequestHandler(protoID, &Message[key.Key8, net.IP]{},
+		func(ctx context.Context, id kad.NodeID[key.Key8], req kad.Message) (kad.Message, error) {
+			return &Message[key.Key8, net.IP]{}, nil
+		}))
+
+	require.NoError(t, endpoints[0].MaybeAddToPeerstore(ctx,
+		kadtest.NewInfo[key.Key8, net.IP](endpoints[1].self.(*kadtest.ID[key.Key8]), nil), time.Hour))
+
+	// the response arrives one round trip after the request was sent
+	start := clk.Now()
+	var rtt time.Duration
+	err := endpoints[0].SendRequestHandleResponse(ctx, protoID, endpoints[1].self, &Message[key.Key8, net.IP]{},
+		&Message[key.Key8, net.IP]{}, time.Second, func(ctx context.Context, resp kad.Response[key.Key8, net.IP], err error) {
+			require.NoError(t, err)
+			rtt = clk.Now().Sub(start)
+		})
+	require.NoError(t, err)
+	sim.Run(ctx)
+	require.Equal(t, 60*time.Millisecond, rtt)
+}
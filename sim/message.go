@@ -34,3 +34,22 @@ func (m *Message[K, A]) EmptyResponse() kad.Response[K, A] {
 func (m *Message[K, A]) CloserNodes() []kad.NodeInfo[K, A] {
 	return m.closerPeers
 }
+
+// Throttled is the response an Endpoint sends to a request it rejects because the requester
+// exceeded its rate limit. The Endpoint of the requester reports it as endpoint.ErrThrottled.
+type Throttled[K kad.Key[K], A kad.Address[A]] struct{}
+
+func (*Throttled[K, A]) CloserNodes() []kad.NodeInfo[K, A] {
+	return nil
+}
+
+// EndOfStream is the message an Endpoint sends after the last response streamed back to a
+// request. Aborted is set if the streaming request handler returned an error, which the Endpoint
+// of the requester reports as endpoint.ErrStreamAborted.
+type EndOfStream[K kad.Key[K], A kad.Address[A]] struct {
+	Aborted bool
+}
+
+func (*EndOfStream[K, A]) CloserNodes() []kad.NodeInfo[K, A] {
+	return nil
+}
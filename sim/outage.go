@@ -0,0 +1,65 @@
+package sim
+
+import (
+	"context"
+
+	"github.com/plprobelab/go-kademlia/kad"
+)
+
+// OutagePolicy tells what a Router does with the messages in transit to a node that is down.
+type OutagePolicy int
+
+const (
+	// OutageDrop loses the messages in transit to a node that is down, as in a crash.
+	OutageDrop OutagePolicy = iota
+	// OutageHold delivers the messages in transit to a node that is down once it is up again, as
+	// when a node loses its connectivity for a while.
+	OutageHold
+)
+
+// SetOutagePolicy sets what the router does with the messages in transit to the nodes that are
+// down. The default policy is OutageDrop.
+func (r *Router[K, A]) SetOutagePolicy(p OutagePolicy) {
+	r.outage = p
+}
+
+// SetNodeDown makes id unreachable until SetNodeUp is called, modelling a temporary outage: unlike
+// a node removed from the router, the node keeps its endpoint and state. Dialling a node that is
+// down fails, a node that is down cannot dial or send messages, and the messages in transit to it
+// are lost or held following the outage policy of the router.
+func (r *Router[K, A]) SetNodeDown(id kad.NodeID[K]) {
+	r.nodes[r.indexOf(id)].down = true
+}
+
+// SetNodeUp ends the outage of id and delivers the messages held while it was down, in the order
+// they were due.
+func (r *Router[K, A]) SetNodeUp(ctx context.Context, id kad.NodeID[K]) {
+	i, ok := r.lookup(id.String())
+	if !ok {
+		return
+	}
+	n := &r.nodes[i]
+	n.down = false
+	held := n.held
+	n.held = nil
+	for _, d := range held {
+		if n.sched == nil {
+			// the node left during its outage
+			r.stats.Down++
+			d.recycle()
+			continue
+		}
+		n.sched.EnqueueAction(ctx, d)
+	}
+}
+
+// IsNodeDown reports whether id is down.
+func (r *Router[K, A]) IsNodeDown(id kad.NodeID[K]) bool {
+	i, ok := r.lookup(id.String())
+	return ok && r.nodes[i].down
+}
+
+// reachable reports whether from can reach to, neither being down.
+func (r *Router[K, A]) reachable(from, to kad.NodeID[K]) bool {
+	return !r.IsNodeDown(from) && !r.IsNodeDown(to)
+}
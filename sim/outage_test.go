@@ -0,0 +1,116 @@
+package sim
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+func TestNodeOutage(t *testing.T) {
+	ctx := context.Background()
+	protoID := address.ProtocolID("/test/proto")
+
+	// setup creates a client and a server answering its requests, one second away from each other
+	setup := func() (*Router[key.Key8, net.IP], *clock.Mock, *Endpoint[key.Key8, net.IP], *Endpoint[key.Key8, net.IP], *int) {
+		clk := clock.NewMock()
+		router := NewRouter[key.Key8, net.IP]()
+		router.SetLatencyModel(ConstantLatency[key.Key8](time.Second))
+		endpoints := make([]*Endpoint[key.Key8, net.IP], 2)
+		for i := range endpoints {
+			endpoints[i] = NewEndpoint[key.Key8, net.IP](kadtest.NewID(key.Key8(i)), event.NewSimpleScheduler(clk), router)
+		}
+		client, server := endpoints[0], endpoints[1]
+		var handled int
+		require.NoError(t, server.AddRequestHandler(protoID, &Message[key.Key8, net.IP]{},
+			func(ctx context.Context, id kad.NodeID[key.Key8], req kad.Message) (kad.Message, error) {
+				handled++
+				return &Message[key.Key8, net.IP]{}, nil
+			}))
+		require.NoError(t, client.MaybeAddToPeerstore(ctx,
+			kadtest.NewInfo[key.Key8, net.IP](server.self.(*kadtest.ID[key.Key8]), nil), time.Hour))
+		return router, clk, client, server, &handled
+	}
+	request := func(client, server *Endpoint[key.Key8, net.IP], errs *[]error) {
+		err := client.SendRequestHandleResponse(ctx, protoID, server.self, &Message[key.Key8, net.IP]{},
+			&Message[key.Key8, net.IP]{}, 10*time.Second, func(ctx context.Context, resp kad.Response[key.Key8, net.IP], err error) {
+				*errs = append(*errs, err)
+			})
+		require.NoError(t, err)
+	}
+	run := func(clk *clock.Mock, endpoints ...*Endpoint[key.Key8, net.IP]) {
+		sim := NewLiteSimulator(clk)
+		for _, e := range endpoints {
+			sim.Add(e.sched.(event.AwareScheduler))
+		}
+		sim.Run(ctx)
+	}
+
+	t.Run("dial", func(t *testing.T) {
+		router, clk, client, server, handled := setup()
+		router.SetNodeDown(server.self)
+		require.True(t, router.IsNodeDown(server.self))
+		require.ErrorIs(t, client.DialPeer(ctx, server.self), endpoint.ErrDialFailure)
+
+		var errs []error
+		request(client, server, &errs)
+		run(clk, client, server)
+		require.Len(t, errs, 1)
+		require.ErrorIs(t, errs[0], endpoint.ErrDialFailure)
+
+		// a node that is down cannot dial either
+		router.SetNodeUp(ctx, server.self)
+		router.SetNodeDown(client.self)
+		require.ErrorIs(t, client.DialPeer(ctx, server.self), endpoint.ErrDialFailure)
+
+		router.SetNodeUp(ctx, client.self)
+		require.NoError(t, client.DialPeer(ctx, server.self))
+		request(client, server, &errs)
+		run(clk, client, server)
+		require.Equal(t, []error{errs[0], nil}, errs)
+		require.Equal(t, 1, *handled)
+	})
+
+	t.Run("drop in transit", func(t *testing.T) {
+		router, clk, client, server, handled := setup()
+		var errs []error
+		request(client, server, &errs)
+		router.SetNodeDown(server.self)
+		run(clk, client, server)
+		require.Len(t, errs, 1)
+		require.ErrorIs(t, errs[0], endpoint.ErrTimeout)
+		require.Zero(t, *handled)
+		require.EqualValues(t, 1, router.Stats().Down)
+	})
+
+	t.Run("hold in transit", func(t *testing.T) {
+		router, clk, client, server, handled := setup()
+		router.SetOutagePolicy(OutageHold)
+		var errs []error
+		request(client, server, &errs)
+		router.SetNodeDown(server.self)
+
+		// the request reaches the server while it is down, and is held until it is up
+		sim := NewLiteSimulator(clk)
+		sim.Add(client.sched.(event.AwareScheduler))
+		sim.Add(server.sched.(event.AwareScheduler))
+		event.ScheduleActionIn(ctx, server.sched, 5*time.Second, event.BasicAction(func(ctx context.Context) {
+			require.Zero(t, *handled)
+			router.SetNodeUp(ctx, server.self)
+		}))
+		sim.Run(ctx)
+		require.Equal(t, 1, *handled)
+		require.Equal(t, []error{nil}, errs)
+		require.Zero(t, router.Stats().Down)
+	})
+}
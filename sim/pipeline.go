@@ -0,0 +1,105 @@
+package sim
+
+import (
+	"time"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+// pipelinedRequest is a request sent, or queued to be sent, on a simulated connection.
+type pipelinedRequest[K kad.Key[K], A kad.Address[A]] struct {
+	sid     endpoint.StreamID
+	protoID address.ProtocolID
+	req     kad.Message
+	timeout time.Duration
+	handler endpoint.ResponseHandlerFn[K, A]
+}
+
+// simConn is the simulated connection of an endpoint with a remote peer, on which requests are
+// pipelined. Queued requests are sent in turn across protocols, so that many requests for one
+// protocol don't delay the requests for other protocols.
+type simConn[K kad.Key[K], A kad.Address[A]] struct {
+	outstanding int                                              // the number of requests sent and waiting for a response
+	protos      []address.ProtocolID                             // the protocols with queued requests, in the order they are served
+	queued      map[address.ProtocolID][]*pipelinedRequest[K, A] // the queued requests of each protocol
+	last        address.ProtocolID                               // the protocol of the last request sent
+}
+
+// conn returns the connection with id, creating it if needed. e.streamMu must be held.
+func (e *Endpoint[K, A]) conn(id kad.NodeID[K]) *simConn[K, A] {
+	c, ok := e.conns[id.String()]
+	if !ok {
+		c = &simConn[K, A]{queued: make(map[address.ProtocolID][]*pipelinedRequest[K, A])}
+		e.conns[id.String()] = c
+	}
+	return c
+}
+
+func (c *simConn[K, A]) enqueue(r *pipelinedRequest[K, A]) {
+	if len(c.queued[r.protoID]) == 0 {
+		c.protos = append(c.protos, r.protoID)
+	}
+	c.queued[r.protoID] = append(c.queued[r.protoID], r)
+}
+
+// next removes and returns the next queued request, nil if there is none. The protocol of the
+// last request sent is served after the other protocols with queued requests.
+func (c *simConn[K, A]) next() *pipelinedRequest[K, A] {
+	if len(c.protos) == 0 {
+		return nil
+	}
+	if c.protos[0] == c.last && len(c.protos) > 1 {
+		c.protos = append(c.protos[1:], c.protos[0])
+	}
+	protoID := c.protos[0]
+	c.protos = c.protos[1:]
+	q := c.queued[protoID]
+	r := q[0]
+	if len(q) == 1 {
+		delete(c.queued, protoID)
+	} else {
+		c.queued[protoID] = q[1:]
+		c.protos = append(c.protos, protoID)
+	}
+	c.last = protoID
+	return r
+}
+
+func (c *simConn[K, A]) queuedLen() int {
+	n := 0
+	for _, q := range c.queued {
+		n += len(q)
+	}
+	return n
+}
+
+// SetMaxOutstanding sets the maximum number of requests that may be outstanding on the
+// connection with each peer, further requests being queued. Zero, the default, removes the
+// limit.
+func (e *Endpoint[K, A]) SetMaxOutstanding(n int) {
+	e.streamMu.Lock()
+	defer e.streamMu.Unlock()
+	e.maxOutstanding = n
+}
+
+// Outstanding returns the number of requests sent to id and waiting for a response.
+func (e *Endpoint[K, A]) Outstanding(id kad.NodeID[K]) int {
+	e.streamMu.Lock()
+	defer e.streamMu.Unlock()
+	if c, ok := e.conns[id.String()]; ok {
+		return c.outstanding
+	}
+	return 0
+}
+
+// Queued returns the number of requests to id waiting to be sent.
+func (e *Endpoint[K, A]) Queued(id kad.NodeID[K]) int {
+	e.streamMu.Lock()
+	defer e.streamMu.Unlock()
+	if c, ok := e.conns[id.String()]; ok {
+		return c.queuedLen()
+	}
+	return 0
+}
@@ -0,0 +1,84 @@
+package sim
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+	"github.com/plprobelab/go-kademlia/util"
+)
+
+// pushedMessage is the envelope of a one-way message, telling the recipient not to handle it as a
+// request or a response.
+type pushedMessage struct {
+	Message kad.Message
+}
+
+// PushMessage sends a one-way message to id, which handles it with the message handler of protoID
+// and does not answer. It returns an error if id cannot be dialled, but messages lost on the way
+// or not handled by id go unnoticed, as do the failures of asynchronous dials.
+func (e *Endpoint[K, A]) PushMessage(ctx context.Context, protoID address.ProtocolID, id kad.NodeID[K],
+	msg kad.Message,
+) error {
+	ctx, span := util.StartMessageSpan(ctx, "PushMessage", func() []attribute.KeyValue {
+		return []attribute.KeyValue{attribute.Stringer("id", id)}
+	})
+	defer span.End()
+
+	if e.dialsAsync(id) {
+		// the message is pushed once connected, and lost if the dial fails
+		e.dialAsync(ctx, id, func(ctx context.Context, err error) {
+			if err == nil {
+				_, _ = e.sendMessage(ctx, id, protoID, 0, &pushedMessage{Message: msg})
+			}
+		})
+		return nil
+	}
+	if err := e.DialPeer(ctx, id); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if _, err := e.sendMessage(ctx, id, protoID, 0, &pushedMessage{Message: msg}); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return nil
+}
+
+// AddMessageHandler registers the handler of the one-way messages of protoID, which may be
+// registered alongside a request handler for the same protocol.
+func (e *Endpoint[K, A]) AddMessageHandler(protoID address.ProtocolID, h endpoint.MessageHandlerFn[K]) error {
+	if h == nil {
+		return endpoint.ErrNilMessageHandler
+	}
+	e.pushProtos[protoID] = h
+	return nil
+}
+
+// RemoveMessageHandler removes the handler of the one-way messages of protoID.
+func (e *Endpoint[K, A]) RemoveMessageHandler(protoID address.ProtocolID) {
+	delete(e.pushProtos, protoID)
+}
+
+// handlePush passes a one-way message received from id to its handler. Messages without a handler,
+// or rejected by the rate limiter of the endpoint, are dropped.
+func (e *Endpoint[K, A]) handlePush(ctx context.Context, id kad.NodeID[K], protoID address.ProtocolID, msg kad.Message) {
+	_, span := util.StartMessageSpan(ctx, "HandlePush", func() []attribute.KeyValue {
+		return []attribute.KeyValue{attribute.Stringer("id", id)}
+	})
+	defer span.End()
+
+	h, ok := e.pushProtos[protoID]
+	if !ok {
+		span.AddEvent("No message handler")
+		return
+	}
+	if e.limiter != nil && !e.limiter.Allow(id.String()) {
+		span.AddEvent("Message throttled")
+		return
+	}
+	h(ctx, id, msg)
+}
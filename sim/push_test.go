@@ -0,0 +1,68 @@
+package sim
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+func TestPushMessage(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	router := NewRouter[key.Key8, net.IP]()
+	protoID := address.ProtocolID("/test/gossip")
+
+	sim := NewLiteSimulator(clk)
+	endpoints := make([]*Endpoint[key.Key8, net.IP], 2)
+	for i := range endpoints {
+		sched := event.NewSimpleScheduler(clk)
+		endpoints[i] = NewEndpoint[key.Key8, net.IP](kadtest.NewID(key.Key8(i)), sched, router)
+		sim.Add(sched)
+	}
+	sender, recipient := endpoints[0], endpoints[1]
+
+	// a peer that cannot be dialled is reported
+	msg := NewRequest[key.Key8, net.IP](key.Key8(0x42))
+	require.ErrorIs(t, sender.PushMessage(ctx, protoID, recipient.self, msg), endpoint.ErrUnknownPeer)
+
+	require.NoError(t, sender.MaybeAddToPeerstore(ctx,
+		kadtest.NewInfo[key.Key8, net.IP](recipient.self.(*kadtest.ID[key.Key8]), nil), time.Hour))
+
+	require.ErrorIs(t, recipient.AddMessageHandler(protoID, nil), endpoint.ErrNilMessageHandler)
+	var received []kad.Message
+	require.NoError(t, recipient.AddMessageHandler(protoID, func(ctx context.Context, id kad.NodeID[key.Key8], m kad.Message) {
+		require.Equal(t, sender.self, id)
+		received = append(received, m)
+	}))
+	// the request handler of the same protocol is not called for one-way messages
+	require.NoError(t, recipient.AddRequestHandler(protoID, nil, func(ctx context.Context, id kad.NodeID[key.Key8], req kad.Message) (kad.Message, error) {
+		t.Fatal("request handler called for a one-way message")
+		return nil, nil
+	}))
+
+	require.NoError(t, sender.PushMessage(ctx, protoID, recipient.self, msg))
+	require.NoError(t, sender.PushMessage(ctx, protoID, recipient.self, msg))
+	sim.Run(ctx)
+	require.Equal(t, []kad.Message{msg, msg}, received)
+
+	// messages without a handler are dropped, and not answered
+	trace := NewTrace[key.Key8]()
+	router.SetTrace(trace)
+	recipient.RemoveMessageHandler(protoID)
+	require.NoError(t, sender.PushMessage(ctx, protoID, recipient.self, msg))
+	sim.Run(ctx)
+	require.Len(t, received, 2)
+	require.Len(t, trace.Events, 2)
+	require.Equal(t, "push target=0x42", trace.Events[0].Summary)
+}
@@ -2,6 +2,10 @@ package sim
 
 import (
 	"context"
+	"crypto/ed25519"
+	"math/rand"
+	"sync"
+	"time"
 
 	"github.com/plprobelab/go-kademlia/event"
 	"github.com/plprobelab/go-kademlia/kad"
@@ -9,43 +13,292 @@ import (
 	"github.com/plprobelab/go-kademlia/network/endpoint"
 )
 
+// Router carries the messages between the endpoints of a simulation. Each node the router hears
+// of is assigned a compact index, under which the router keeps all it knows of the node, so that
+// carrying a message takes a single lookup per node and simulations of hundreds of thousands of
+// nodes fit in memory.
 type Router[K kad.Key[K], A kad.Address[A]] struct {
 	currStream endpoint.StreamID
-	peers      map[string]SimEndpoint[K, A]
-	scheds     map[string]event.Scheduler
+	index      map[string]int32 // the index of each node the router heard of
+	nodes      []routerNode[K, A]
+	online     int // the number of nodes with an endpoint
+
+	latency LatencyModel[K] // delays the delivery of messages, nil to deliver them immediately
+
+	defaultFaults LinkFaults               // the faults of the links that have none set
+	faults        map[indexLink]LinkFaults // the faults of each link
+	rng           *rand.Rand               // decides which messages are faulty
+	partitions    map[indexLink]struct{}   // the links blocked by a partition
+	stats         RouterStats
+	outage        OutagePolicy // what to do with the messages in transit to nodes that are down
+
+	limited int             // the number of nodes with a limited bandwidth
+	msgSize MessageSizeFunc // the size of messages, to compute their transfer time
+
+	observers    []MessageObserver[K] // notified of the messages sent and delivered
+	interceptors []Interceptor[K]     // decide the fate of the messages sent
+	trace        *Trace[K]            // records the messages sent and delivered, nil to record none
+	replaying    bool                 // whether deliveries are driven by a trace instead of the messages sent
+
+	deliveries sync.Pool                    // recycles the deliveries of messages
+	inFlight   map[*delivery[K, A]]struct{} // the deliveries not run yet
+	sent       uint64                       // the number of deliveries created, to order them
+}
+
+// routerNode is what a Router knows of a node. The node is online while it has an endpoint.
+type routerNode[K kad.Key[K], A kad.Address[A]] struct {
+	id       kad.NodeID[K]
+	peer     SimEndpoint[K, A]
+	sched    event.Scheduler
+	key      ed25519.PublicKey // the public key of the node, to authenticate its messages
+	bw       Bandwidth         // the bandwidth of the node, unlimited if zero
+	upFree   time.Time         // the time the node has finished sending the messages before
+	downFree time.Time         // the time the node has finished receiving the messages before
+	down     bool              // whether the node is unreachable, see SetNodeDown
+	held     []*delivery[K, A] // the messages held while the node is down
+}
+
+// indexLink is a directed link between two nodes, identified by their index in a Router.
+type indexLink struct {
+	from, to int32
+}
+
+// MessageObserver is notified of the messages a Router carries, for example to collect statistics
+// about a simulation. Sent is called once for each message sent, including messages lost to
+// faults, and Delivered once for each copy of a message handed to its recipient.
+type MessageObserver[K kad.Key[K]] interface {
+	MessageSent(from, to kad.NodeID[K], msg kad.Message, at time.Time)
+	MessageDelivered(from, to kad.NodeID[K], msg kad.Message, at time.Time)
 }
 
 func NewRouter[K kad.Key[K], A kad.Address[A]]() *Router[K, A] {
 	return &Router[K, A]{
 		currStream: 1,
-		peers:      make(map[string]SimEndpoint[K, A]),
-		scheds:     make(map[string]event.Scheduler),
+		index:      make(map[string]int32),
+		faults:     make(map[indexLink]LinkFaults),
+		partitions: make(map[indexLink]struct{}),
+		inFlight:   make(map[*delivery[K, A]]struct{}),
+		rng:        rand.New(rand.NewSource(0)),
+		msgSize:    EstimateMessageSize[K, A],
 	}
 }
 
+// lookup returns the index of the node named name, and false if the router never heard of it.
+func (r *Router[K, A]) lookup(name string) (int32, bool) {
+	i, ok := r.index[name]
+	return i, ok
+}
+
+// indexOf returns the index of id, assigning it one if the router never heard of it. Indexes are
+// never reused, so that the links and settings of a node outlive its removal.
+func (r *Router[K, A]) indexOf(id kad.NodeID[K]) int32 {
+	name := id.String()
+	if i, ok := r.index[name]; ok {
+		return i
+	}
+	i := int32(len(r.nodes))
+	r.index[name] = i
+	r.nodes = append(r.nodes, routerNode[K, A]{id: id})
+	return i
+}
+
+// peerOf returns the endpoint and scheduler of the online node named name.
+func (r *Router[K, A]) peerOf(name string) (SimEndpoint[K, A], event.Scheduler, bool) {
+	i, ok := r.index[name]
+	if !ok || r.nodes[i].peer == nil {
+		return nil, nil, false
+	}
+	return r.nodes[i].peer, r.nodes[i].sched, true
+}
+
 func (r *Router[K, A]) AddPeer(id kad.NodeID[K], peer SimEndpoint[K, A], sched event.Scheduler) {
-	r.peers[id.String()] = peer
-	r.scheds[id.String()] = sched
+	n := &r.nodes[r.indexOf(id)]
+	if n.peer == nil {
+		r.online++
+	}
+	n.id, n.peer, n.sched = id, peer, sched
 }
 
 func (r *Router[K, A]) RemovePeer(id kad.NodeID[K]) {
-	delete(r.peers, id.String())
-	delete(r.scheds, id.String())
+	i, ok := r.lookup(id.String())
+	if !ok || r.nodes[i].peer == nil {
+		return
+	}
+	r.nodes[i].peer, r.nodes[i].sched = nil, nil
+	r.online--
+}
+
+// NumPeers returns the number of peers in the router.
+func (r *Router[K, A]) NumPeers() int {
+	return r.online
+}
+
+// SetLatencyModel sets the model giving the time messages take to reach their recipient. A nil
+// model delivers messages as soon as the recipient runs its next action.
+func (r *Router[K, A]) SetLatencyModel(m LatencyModel[K]) {
+	r.latency = m
+}
+
+// AddObserver adds an observer notified of the messages the router carries.
+func (r *Router[K, A]) AddObserver(o MessageObserver[K]) {
+	r.observers = append(r.observers, o)
+}
+
+// NewStreamID returns a new stream id, unique among the streams of the router.
+func (r *Router[K, A]) NewStreamID() endpoint.StreamID {
+	sid := r.currStream
+	r.currStream++
+	return sid
+}
+
+// Negotiate returns the first of protoIDs that the peer to handles requests for, as a
+// multistream negotiation would, or endpoint.ErrProtocolNotSupported if it handles none of them.
+// Peers that don't report the protocols they support are assumed to support the first one.
+func (r *Router[K, A]) Negotiate(to kad.NodeID[K], protoIDs []address.ProtocolID) (address.ProtocolID, error) {
+	peer, _, ok := r.peerOf(to.String())
+	if !ok {
+		return "", endpoint.ErrUnknownPeer
+	}
+	if len(protoIDs) == 0 {
+		return "", endpoint.ErrProtocolNotSupported
+	}
+	ps, ok := peer.(interface {
+		SupportsProtocol(address.ProtocolID) bool
+	})
+	if !ok {
+		return protoIDs[0], nil
+	}
+	for _, protoID := range protoIDs {
+		if ps.SupportsProtocol(protoID) {
+			return protoID, nil
+		}
+	}
+	return "", endpoint.ErrProtocolNotSupported
 }
 
 func (r *Router[K, A]) SendMessage(ctx context.Context, from, to kad.NodeID[K],
 	protoID address.ProtocolID, sid endpoint.StreamID,
 	msg kad.Message,
 ) (endpoint.StreamID, error) {
-	if _, ok := r.peers[to.String()]; !ok {
+	toIdx, ok := r.lookup(to.String())
+	if !ok || r.nodes[toIdx].peer == nil {
 		return 0, endpoint.ErrUnknownPeer
 	}
+	fromIdx := r.indexOf(from)
+	if r.nodes[toIdx].down || r.nodes[fromIdx].down {
+		return 0, endpoint.ErrCannotConnect
+	}
 	if sid == 0 {
-		sid = r.currStream
-		r.currStream++
+		sid = r.NewStreamID()
+	}
+
+	sched := r.nodes[toIdx].sched
+	now := sched.Clock().Now()
+	for _, o := range r.observers {
+		o.MessageSent(from, to, msg, now)
+	}
+	if r.trace != nil {
+		r.record(TraceSent, now, from, to.String(), protoID, sid, msg)
+	}
+	if r.replaying {
+		// the delivery of the message is replayed from a trace
+		return sid, nil
+	}
+	var delay time.Duration
+	if len(r.interceptors) > 0 {
+		if msg, delay, ok = r.intercept(from, to, msg); !ok {
+			return sid, nil
+		}
+	}
+	for i := r.injectFaults(fromIdx, toIdx); i > 0; i-- {
+		at := now.Add(delay)
+		if r.limited > 0 {
+			at = r.transfer(fromIdx, toIdx, msg, at)
+		}
+		if r.latency != nil {
+			at = at.Add(r.latency.Latency(from, to))
+		}
+		d := r.newDelivery()
+		d.from, d.to, d.fromIdx, d.toIdx = from, to, fromIdx, toIdx
+		d.protoID, d.sid, d.msg = protoID, sid, msg
+		d.due = at
+		r.track(d)
+		if at.After(now) {
+			sched.ScheduleAction(ctx, at, d)
+		} else {
+			sched.EnqueueAction(ctx, d)
+		}
 	}
-	r.scheds[to.String()].EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
-		r.peers[to.String()].HandleMessage(ctx, from, protoID, sid, msg)
-	}))
 	return sid, nil
 }
+
+// delivery is the action delivering a message to its recipient. Deliveries are recycled once run.
+type delivery[K kad.Key[K], A kad.Address[A]] struct {
+	r              *Router[K, A]
+	from, to       kad.NodeID[K]
+	fromIdx, toIdx int32
+	protoID        address.ProtocolID
+	sid            endpoint.StreamID
+	msg            kad.Message
+	due            time.Time // the time the message reaches its recipient
+	seq            uint64    // the order in which the message was sent
+}
+
+func (r *Router[K, A]) newDelivery() *delivery[K, A] {
+	if d, ok := r.deliveries.Get().(*delivery[K, A]); ok {
+		return d
+	}
+	return &delivery[K, A]{r: r}
+}
+
+// track records d as in flight until it is recycled.
+func (r *Router[K, A]) track(d *delivery[K, A]) {
+	r.sent++
+	d.seq = r.sent
+	r.inFlight[d] = struct{}{}
+}
+
+// recycle returns the delivery to the pool of its router.
+func (d *delivery[K, A]) recycle() {
+	r := d.r
+	delete(r.inFlight, d)
+	*d = delivery[K, A]{r: r}
+	r.deliveries.Put(d)
+}
+
+// Run hands the message to its recipient, if it is still online and reachable.
+func (d *delivery[K, A]) Run(ctx context.Context) {
+	r := d.r
+	n := &r.nodes[d.toIdx]
+	if n.down && n.peer != nil {
+		if r.outage == OutageHold {
+			n.held = append(n.held, d)
+			return
+		}
+		r.stats.Down++
+		d.recycle()
+		return
+	}
+	from, to, protoID, sid, msg := d.from, d.to, d.protoID, d.sid, d.msg
+	blocked := r.partitioned(d.fromIdx, d.toIdx)
+	d.recycle()
+
+	if n.peer == nil {
+		// the recipient left while the message was in transit
+		return
+	}
+	if blocked {
+		// a partition started while the message was in transit
+		r.stats.Blocked++
+		return
+	}
+	peer := n.peer
+	if len(r.observers) > 0 || r.trace != nil {
+		now := n.sched.Clock().Now()
+		for _, o := range r.observers {
+			o.MessageDelivered(from, to, msg, now)
+		}
+		r.record(TraceDelivered, now, from, to.String(), protoID, sid, msg)
+	}
+	peer.HandleMessage(ctx, from, protoID, sid, msg)
+}
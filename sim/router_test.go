@@ -2,15 +2,17 @@ package sim
 
 import (
 	"context"
+	"math/rand"
 	"net"
+	"runtime"
 	"testing"
 
 	"github.com/benbjohnson/clock"
 	"github.com/stretchr/testify/require"
 
 	"github.com/plprobelab/go-kademlia/event"
-	"github.com/plprobelab/go-kademlia/internal/kadtest"
 	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
 	"github.com/plprobelab/go-kademlia/key"
 	"github.com/plprobelab/go-kademlia/network/address"
 	"github.com/plprobelab/go-kademlia/network/endpoint"
@@ -64,3 +66,75 @@ func TestRouter(t *testing.T) {
 	require.Equal(t, endpoint.StreamID(0), sid)
 	require.False(t, scheds[1].RunOne(ctx))
 }
+
+func BenchmarkRouterSendMessage(b *testing.B) {
+	b.Run("1000", benchmarkRouterSendMessage(1000))
+	b.Run("10000", benchmarkRouterSendMessage(10000))
+	b.Run("100000", benchmarkRouterSendMessage(100000))
+}
+
+func BenchmarkNewNetwork(b *testing.B) {
+	b.Run("1000", benchmarkNewNetwork(1000))
+	b.Run("10000", benchmarkNewNetwork(10000))
+	b.Run("100000", benchmarkNewNetwork(100000))
+}
+
+// benchmarkRouterSendMessage sends messages between random peers of a router with n peers, and
+// delivers them.
+func benchmarkRouterSendMessage(n int) func(b *testing.B) {
+	return func(b *testing.B) {
+		ctx := context.Background()
+		clk := clock.NewMock()
+		router := NewRouter[key.Key32, net.IP]()
+		// the peers share a scheduler, to measure the router rather than the schedulers
+		sched := event.NewSimpleScheduler(clk)
+		ids := make([]kad.NodeID[key.Key32], n)
+		for i := range ids {
+			ids[i] = kadtest.NewID(key.Key32(i))
+			NewEndpoint[key.Key32, net.IP](ids[i], sched, router)
+		}
+		protoID := address.ProtocolID("/test/proto")
+		msg := NewRequest[key.Key32, net.IP](key.Key32(0))
+		rng := rand.New(rand.NewSource(1))
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			from, to := ids[rng.Intn(n)], ids[rng.Intn(n)]
+			if _, err := router.SendMessage(ctx, from, to, protoID, 0, msg); err != nil {
+				b.Fatal(err)
+			}
+			sched.RunOne(ctx)
+		}
+	}
+}
+
+// benchmarkNewNetwork builds networks of n nodes, reporting the memory used per node.
+func benchmarkNewNetwork(n int) func(b *testing.B) {
+	return func(b *testing.B) {
+		ctx := context.Background()
+		infos := make([]kad.NodeInfo[key.Key32, net.IP], n)
+		for i := range infos {
+			infos[i] = kadtest.NewInfo[key.Key32, net.IP](kadtest.NewID(key.Key32(i)), nil)
+		}
+		cfg := DefaultNetworkConfig[key.Key32]()
+		cfg.Topology = RandomTopology(4)
+
+		var before, after runtime.MemStats
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+			net, err := NewNetwork(ctx, clock.NewMock(), infos, cfg)
+			if err != nil {
+				b.Fatal(err)
+			}
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+			runtime.KeepAlive(net)
+		}
+		b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/float64(n), "B/node")
+		kadtest.ReportTimePerItemMetric(b, n*b.N, "node")
+	}
+}
@@ -0,0 +1,250 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/util"
+)
+
+// StopReason tells why a Runner stopped a simulation.
+type StopReason int
+
+const (
+	// StopQuiescent means that the schedulers had no more actions to run.
+	StopQuiescent StopReason = iota
+	// StopCondition means that the stop condition of the runner was met, for example because all
+	// queries were done.
+	StopCondition
+	// StopTimeLimit means that the virtual time limit of the runner was reached.
+	StopTimeLimit
+	// StopStepLimit means that the runner ran its maximum number of steps.
+	StopStepLimit
+	// StopLivelock means that the simulation made no progress for too many steps.
+	StopLivelock
+	// StopCancelled means that the context of the run was cancelled.
+	StopCancelled
+	// StopInvariant means that an invariant of the simulation was broken.
+	StopInvariant
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopQuiescent:
+		return "quiescent"
+	case StopCondition:
+		return "condition"
+	case StopTimeLimit:
+		return "time limit"
+	case StopStepLimit:
+		return "step limit"
+	case StopLivelock:
+		return "livelock"
+	case StopCancelled:
+		return "cancelled"
+	case StopInvariant:
+		return "invariant"
+	default:
+		return fmt.Sprintf("StopReason(%d)", int(r))
+	}
+}
+
+// Progress describes the state of a simulation run by a Runner.
+type Progress struct {
+	Steps   int           // the number of actions run since the start of the run
+	Now     time.Time     // the current virtual time
+	Elapsed time.Duration // the virtual time elapsed since the start of the run
+}
+
+// RunResult describes how a Runner ended a simulation.
+type RunResult struct {
+	Progress
+	Reason StopReason
+}
+
+// RunnerConfig specifies optional configuration for a Runner.
+type RunnerConfig struct {
+	Stop             func(ctx context.Context) bool // checked after each step, the run stops when it returns true, may be nil
+	TimeLimit        time.Duration                  // the virtual time after which the run stops, zero for no limit
+	MaxSteps         int                            // the number of steps after which the run stops, zero for no limit
+	ProgressInterval int                            // the number of steps between progress reports, zero for no reports
+	OnProgress       func(Progress)                 // receives the progress reports and a last report when the run stops, may be nil
+	LivelockSteps    int                            // the number of steps without progress after which the run stops, zero to disable
+	State            func() uint64                  // summarises the state of the simulation to detect progress, may be nil
+
+	Invariants        []Invariant // checked before the first step and periodically after, see InvariantInterval
+	InvariantInterval int         // the number of steps between invariant checks, zero to check after every step
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *RunnerConfig) Validate() error {
+	if cfg.TimeLimit < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "RunnerConfig",
+			Err:       fmt.Errorf("time limit must not be negative"),
+		}
+	}
+	if cfg.MaxSteps < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "RunnerConfig",
+			Err:       fmt.Errorf("max steps must not be negative"),
+		}
+	}
+	if cfg.ProgressInterval < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "RunnerConfig",
+			Err:       fmt.Errorf("progress interval must not be negative"),
+		}
+	}
+	if cfg.LivelockSteps < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "RunnerConfig",
+			Err:       fmt.Errorf("livelock steps must not be negative"),
+		}
+	}
+	if cfg.InvariantInterval < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "RunnerConfig",
+			Err:       fmt.Errorf("invariant interval must not be negative"),
+		}
+	}
+	for i, inv := range cfg.Invariants {
+		if inv.Check == nil {
+			return &kaderr.ConfigurationError{
+				Component: "RunnerConfig",
+				Err:       fmt.Errorf("invariant %d has no check", i),
+			}
+		}
+	}
+	return nil
+}
+
+// DefaultRunnerConfig returns the default configuration options for a Runner.
+// Options may be overridden before passing to NewRunner.
+func DefaultRunnerConfig() *RunnerConfig {
+	return &RunnerConfig{
+		LivelockSteps: 1_000_000,
+	}
+}
+
+// Runner advances the schedulers of a LiteSimulator in lock-step virtual time until a stop
+// condition is met, reporting its progress along the way. A run makes progress when the virtual
+// time advances or, if the configuration has a State function, when the state it returns
+// changes. A run making no progress for LivelockSteps steps is stopped as a livelock, as when
+// actions keep enqueuing each other without the simulation moving forward. A run breaking one of
+// the invariants of the configuration is stopped at the step the invariant was found broken.
+type Runner struct {
+	cfg RunnerConfig
+	sim *LiteSimulator
+}
+
+// NewRunner creates a Runner for sim. If cfg is nil, the default config is used.
+func NewRunner(sim *LiteSimulator, cfg *RunnerConfig) (*Runner, error) {
+	if cfg == nil {
+		cfg = DefaultRunnerConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Runner{
+		cfg: *cfg,
+		sim: sim,
+	}, nil
+}
+
+// Run runs the simulation until a stop condition is met and returns why it stopped. It returns
+// ErrLivelock if the simulation stopped making progress, and an *InvariantError if it broke an
+// invariant. When the time limit is reached, the
+// clock is advanced to the limit and the actions due after it are left to run.
+func (r *Runner) Run(ctx context.Context) (RunResult, error) {
+	ctx, span := util.StartSpan(ctx, "Runner.Run")
+	defer span.End()
+
+	clk := r.sim.Clock()
+	start := clk.Now()
+	deadline := start.Add(r.cfg.TimeLimit)
+
+	var state uint64
+	if r.cfg.State != nil {
+		state = r.cfg.State()
+	}
+	lastTime := start
+	idle := 0 // the number of steps since the last progress
+
+	res := RunResult{}
+	progress := func() Progress {
+		return Progress{Steps: res.Steps, Now: clk.Now(), Elapsed: clk.Since(start)}
+	}
+	stop := func(reason StopReason) RunResult {
+		res.Progress = progress()
+		res.Reason = reason
+		if r.cfg.OnProgress != nil {
+			r.cfg.OnProgress(res.Progress)
+		}
+		return res
+	}
+
+	check := func() error {
+		for _, inv := range r.cfg.Invariants {
+			if err := inv.Check(ctx); err != nil {
+				return &InvariantError{Name: inv.Name, Step: res.Steps, Time: clk.Now(), Err: err}
+			}
+		}
+		return nil
+	}
+	if err := check(); err != nil {
+		return stop(StopInvariant), err
+	}
+
+	for {
+		if ctx.Err() != nil {
+			return stop(StopCancelled), ctx.Err()
+		}
+		if r.cfg.MaxSteps > 0 && res.Steps >= r.cfg.MaxSteps {
+			return stop(StopStepLimit), nil
+		}
+		if r.cfg.TimeLimit > 0 && len(r.sim.round) == 0 {
+			if next := r.sim.nextActionTime(ctx); next != event.MaxTime && next.After(deadline) {
+				if clk.Now().Before(deadline) {
+					clk.Set(deadline)
+				}
+				return stop(StopTimeLimit), nil
+			}
+		}
+		if !r.sim.Step(ctx) {
+			return stop(StopQuiescent), nil
+		}
+		res.Steps++
+
+		if len(r.cfg.Invariants) > 0 && (r.cfg.InvariantInterval == 0 || res.Steps%r.cfg.InvariantInterval == 0) {
+			if err := check(); err != nil {
+				span.RecordError(err)
+				return stop(StopInvariant), err
+			}
+		}
+		if r.cfg.ProgressInterval > 0 && r.cfg.OnProgress != nil && res.Steps%r.cfg.ProgressInterval == 0 {
+			r.cfg.OnProgress(progress())
+		}
+		if r.cfg.Stop != nil && r.cfg.Stop(ctx) {
+			return stop(StopCondition), nil
+		}
+
+		if r.cfg.LivelockSteps > 0 {
+			progressed := clk.Now().After(lastTime)
+			lastTime = clk.Now()
+			if r.cfg.State != nil {
+				if s := r.cfg.State(); s != state {
+					state = s
+					progressed = true
+				}
+			}
+			if progressed {
+				idle = 0
+			} else if idle++; idle >= r.cfg.LivelockSteps {
+				return stop(StopLivelock), ErrLivelock
+			}
+		}
+	}
+}
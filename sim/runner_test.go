@@ -0,0 +1,144 @@
+package sim
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+)
+
+func TestRunnerConfigValidate(t *testing.T) {
+	require.NoError(t, DefaultRunnerConfig().Validate())
+	require.Error(t, (&RunnerConfig{TimeLimit: -1}).Validate())
+	require.Error(t, (&RunnerConfig{MaxSteps: -1}).Validate())
+	require.Error(t, (&RunnerConfig{ProgressInterval: -1}).Validate())
+	require.Error(t, (&RunnerConfig{LivelockSteps: -1}).Validate())
+	require.Error(t, (&RunnerConfig{InvariantInterval: -1}).Validate())
+	require.Error(t, (&RunnerConfig{Invariants: []Invariant{{Name: "nil"}}}).Validate())
+}
+
+func TestRunner(t *testing.T) {
+	ctx := context.Background()
+
+	// setup returns a simulator whose scheduler runs an action every second, until the tenth
+	setup := func() (*LiteSimulator, *int) {
+		clk := clock.NewMock()
+		sim := NewLiteSimulator(clk)
+		sched := event.NewSimpleScheduler(clk)
+		sim.Add(sched)
+		runs := new(int)
+		for i := 1; i <= 10; i++ {
+			event.ScheduleActionIn(ctx, sched, time.Duration(i)*time.Second, event.BasicAction(func(context.Context) {
+				*runs++
+			}))
+		}
+		return sim, runs
+	}
+
+	t.Run("quiescent", func(t *testing.T) {
+		sim, runs := setup()
+		r, err := NewRunner(sim, nil)
+		require.NoError(t, err)
+		res, err := r.Run(ctx)
+		require.NoError(t, err)
+		require.Equal(t, StopQuiescent, res.Reason)
+		require.Equal(t, 10, res.Steps)
+		require.Equal(t, 10, *runs)
+		require.Equal(t, 10*time.Second, res.Elapsed)
+	})
+
+	t.Run("condition", func(t *testing.T) {
+		sim, runs := setup()
+		r, err := NewRunner(sim, &RunnerConfig{Stop: func(context.Context) bool { return *runs == 3 }})
+		require.NoError(t, err)
+		res, err := r.Run(ctx)
+		require.NoError(t, err)
+		require.Equal(t, StopCondition, res.Reason)
+		require.Equal(t, 3, *runs)
+	})
+
+	t.Run("time limit", func(t *testing.T) {
+		sim, runs := setup()
+		r, err := NewRunner(sim, &RunnerConfig{TimeLimit: 4500 * time.Millisecond})
+		require.NoError(t, err)
+		res, err := r.Run(ctx)
+		require.NoError(t, err)
+		require.Equal(t, StopTimeLimit, res.Reason)
+		require.Equal(t, 4, *runs)
+		require.Equal(t, 4500*time.Millisecond, res.Elapsed)
+	})
+
+	t.Run("step limit", func(t *testing.T) {
+		sim, runs := setup()
+		r, err := NewRunner(sim, &RunnerConfig{MaxSteps: 2})
+		require.NoError(t, err)
+		res, err := r.Run(ctx)
+		require.NoError(t, err)
+		require.Equal(t, StopStepLimit, res.Reason)
+		require.Equal(t, 2, *runs)
+	})
+
+	t.Run("progress", func(t *testing.T) {
+		sim, _ := setup()
+		var reports []Progress
+		r, err := NewRunner(sim, &RunnerConfig{ProgressInterval: 4, OnProgress: func(p Progress) {
+			reports = append(reports, p)
+		}})
+		require.NoError(t, err)
+		_, err = r.Run(ctx)
+		require.NoError(t, err)
+		require.Len(t, reports, 3)
+		require.Equal(t, 4, reports[0].Steps)
+		require.Equal(t, 8, reports[1].Steps)
+		require.Equal(t, 8*time.Second, reports[1].Elapsed)
+		require.Equal(t, 10, reports[2].Steps)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		sim, _ := setup()
+		r, err := NewRunner(sim, nil)
+		require.NoError(t, err)
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		res, err := r.Run(cctx)
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, StopCancelled, res.Reason)
+	})
+}
+
+func TestRunnerLivelock(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	sim := NewLiteSimulator(clk)
+	sched := event.NewSimpleScheduler(clk)
+	sim.Add(sched)
+
+	// an action that enqueues itself forever, without time advancing
+	var loop event.BasicAction
+	var counter uint64
+	loop = func(ctx context.Context) {
+		sched.EnqueueAction(ctx, loop)
+	}
+	sched.EnqueueAction(ctx, loop)
+
+	r, err := NewRunner(sim, &RunnerConfig{LivelockSteps: 100})
+	require.NoError(t, err)
+	res, err := r.Run(ctx)
+	require.ErrorIs(t, err, ErrLivelock)
+	require.Equal(t, StopLivelock, res.Reason)
+	require.Equal(t, 100, res.Steps)
+
+	// a changing state counts as progress
+	r, err = NewRunner(sim, &RunnerConfig{LivelockSteps: 100, MaxSteps: 500, State: func() uint64 {
+		counter++
+		return counter
+	}})
+	require.NoError(t, err)
+	res, err = r.Run(ctx)
+	require.NoError(t, err)
+	require.Equal(t, StopStepLimit, res.Reason)
+}
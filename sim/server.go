@@ -9,6 +9,7 @@ import (
 
 	"github.com/plprobelab/go-kademlia/kad"
 	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/logging"
 	"github.com/plprobelab/go-kademlia/network/endpoint"
 	"github.com/plprobelab/go-kademlia/util"
 )
@@ -19,6 +20,7 @@ type Server[K kad.Key[K], A kad.Address[A]] struct {
 
 	peerstoreTTL              time.Duration
 	numberOfCloserPeersToSend int
+	log                       logging.Logger
 }
 
 func NewServer[K kad.Key[K], A kad.Address[A]](rt kad.RoutingTable[K, kad.NodeID[K]], endpoint endpoint.Endpoint[K, A], cfg *ServerConfig) *Server[K, A] {
@@ -30,6 +32,7 @@ func NewServer[K kad.Key[K], A kad.Address[A]](rt kad.RoutingTable[K, kad.NodeID
 		endpoint:                  endpoint,
 		peerstoreTTL:              cfg.PeerstoreTTL,
 		numberOfCloserPeersToSend: cfg.NumberUsefulCloserPeers,
+		log:                       logging.OrNop(cfg.Logger),
 	}
 }
 
@@ -40,6 +43,7 @@ func (s *Server[K, A]) HandleRequest(ctx context.Context, rpeer kad.NodeID[K],
 	case *Message[K, A]:
 		return s.HandleFindNodeRequest(ctx, rpeer, msg)
 	default:
+		s.log.Debug("unknown request", logging.Stringer("requester", rpeer), logging.String("type", endpoint.MessageType(msg)))
 		return nil, ErrUnknownMessageFormat
 	}
 }
@@ -57,12 +61,18 @@ func (s *Server[K, A]) HandleFindNodeRequest(ctx context.Context,
 		return nil, ErrUnknownMessageFormat
 	}
 
-	_, span := util.StartSpan(ctx, "Server.HandleFindNodeRequest", trace.WithAttributes(
-		attribute.Stringer("Requester", rpeer),
-		attribute.String("Target", key.HexString(target))))
+	_, span := util.StartMessageSpan(ctx, "Server.HandleFindNodeRequest", func() []attribute.KeyValue {
+		return []attribute.KeyValue{
+			attribute.Stringer("Requester", rpeer),
+			attribute.String("Target", key.FormatHex(target)),
+		}
+	})
 	defer span.End()
 
-	nodes := s.rt.NearestNodes(target, s.numberOfCloserPeersToSend)
+	// never include the requester in the closer nodes it is sent
+	nodes := s.rt.NearestNodesFiltered(target, s.numberOfCloserPeersToSend, func(n kad.NodeID[K]) bool {
+		return !key.Equal(n.Key(), rpeer.Key())
+	})
 	span.AddEvent("Nearest nodes", trace.WithAttributes(
 		attribute.Int("count", len(nodes)),
 	))
@@ -76,12 +86,14 @@ func (s *Server[K, A]) HandleFindNodeRequest(ctx context.Context,
 			na, err := s.endpoint.NetworkAddress(p)
 			if err != nil {
 				span.RecordError(err)
+				s.log.Debug("closer node without address", logging.Stringer("node", p), logging.Error(err))
 				continue
 			}
 			peerAddrs[index] = na
 			index++
 		}
 		resp = NewResponse(peerAddrs[:index])
+		s.log.Debug("find node request handled", logging.Stringer("requester", rpeer), logging.String("target", key.FormatHex(target)), logging.Int("closer", index))
 	}
 
 	return resp, nil
@@ -90,6 +102,7 @@ func (s *Server[K, A]) HandleFindNodeRequest(ctx context.Context,
 type ServerConfig struct {
 	PeerstoreTTL            time.Duration
 	NumberUsefulCloserPeers int
+	Logger                  logging.Logger // an optional logger the server emits events about requests to, nil logs nothing
 }
 
 func DefaultServerConfig() *ServerConfig {
@@ -11,8 +11,8 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/plprobelab/go-kademlia/event"
-	"github.com/plprobelab/go-kademlia/internal/kadtest"
 	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
 	"github.com/plprobelab/go-kademlia/key"
 	"github.com/plprobelab/go-kademlia/routing/simplert"
 )
@@ -178,7 +178,10 @@ func TestRequestNoNetworkAddress(t *testing.T) {
 	success := rt.AddNode(node)
 	require.True(t, success)
 
-	s := NewServer[key.Key8, net.IP](rt, fakeEndpoint, DefaultServerConfig())
+	logger := kadtest.NewLogger()
+	cfg := DefaultServerConfig()
+	cfg.Logger = logger
+	s := NewServer[key.Key8, net.IP](rt, fakeEndpoint, cfg)
 	require.NotNil(t, s)
 
 	requester := kadtest.NewID(key.Key8(0x80))
@@ -191,4 +194,11 @@ func TestRequestNoNetworkAddress(t *testing.T) {
 	require.True(t, ok)
 	fmt.Println(resp.CloserNodes())
 	require.Len(t, resp.CloserNodes(), 0)
+
+	// the node missing from the peerstore is reported
+	require.Len(t, logger.Entries("closer node without address"), 1)
+	handled := logger.Entries("find node request handled")
+	require.Len(t, handled, 1)
+	require.Equal(t, 0, handled[0].Fields["closer"])
+	require.Equal(t, requester.String(), handled[0].Fields["requester"])
 }
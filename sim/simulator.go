@@ -2,6 +2,7 @@ package sim
 
 import (
 	"context"
+	"math/rand"
 	"time"
 
 	"github.com/benbjohnson/clock"
@@ -37,6 +38,14 @@ func RemoveSchedulers(s Simulator, schedulers ...event.AwareScheduler) {
 type LiteSimulator struct {
 	clk        *clock.Mock
 	schedulers []event.AwareScheduler // replace with custom linked list
+
+	// round holds the indexes of the schedulers that still have to run an action in the current
+	// round of Step calls
+	round []int
+
+	// rng orders the schedulers due at the same time, nil to run them in the order they were
+	// added
+	rng *rand.Rand
 }
 
 var _ Simulator = (*LiteSimulator)(nil)
@@ -52,8 +61,26 @@ func (s *LiteSimulator) Clock() *clock.Mock {
 	return s.clk
 }
 
+// SetSeed makes the simulator run the schedulers whose next action is due at the same time in
+// an order drawn from a pseudo-random generator seeded with seed, instead of the order they
+// were added. Simulations using the same seed run their actions in the same order, while
+// different seeds explore different interleavings of the nodes.
+func (s *LiteSimulator) SetSeed(seed int64) {
+	s.rng = rand.New(rand.NewSource(seed))
+	s.round = nil
+}
+
+// shuffle orders the indexes of schedulers due at the same time.
+func (s *LiteSimulator) shuffle(ids []int) {
+	if s.rng == nil {
+		return
+	}
+	s.rng.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+}
+
 func (s *LiteSimulator) Add(sched event.AwareScheduler) {
 	s.schedulers = append(s.schedulers, sched)
+	s.round = nil
 }
 
 func (s *LiteSimulator) Remove(sched event.AwareScheduler) {
@@ -62,6 +89,56 @@ func (s *LiteSimulator) Remove(sched event.AwareScheduler) {
 			s.schedulers = append(s.schedulers[:i], s.schedulers[i+1:]...)
 		}
 	}
+	s.round = nil
+}
+
+// Step runs a single action and returns false if there are no more actions to run. Actions are run
+// in the same order as Run: all schedulers whose next action is due at the earliest time run one
+// action each, in the order they were added or in a seeded order, see SetSeed, before the
+// schedulers are polled again.
+func (s *LiteSimulator) Step(ctx context.Context) bool {
+	_, ok := s.step(ctx)
+	return ok
+}
+
+// step runs a single action and returns the index of the scheduler that ran it.
+func (s *LiteSimulator) step(ctx context.Context) (int, bool) {
+	if len(s.round) == 0 {
+		minTime := s.nextActionTime(ctx)
+		if minTime == event.MaxTime {
+			// no more actions to run
+			return -1, false
+		}
+
+		for id, sched := range s.schedulers {
+			if sched.NextActionTime(ctx) == minTime {
+				s.round = append(s.round, id)
+			}
+		}
+		s.shuffle(s.round)
+
+		if minTime.After(s.clk.Now()) {
+			// "wait" minTime for the next action
+			s.clk.Set(minTime)
+		}
+	}
+
+	id := s.round[0]
+	s.round = s.round[1:]
+	s.schedulers[id].RunOne(ctx)
+	return id, true
+}
+
+// nextActionTime returns the time of the earliest action of the schedulers, or event.MaxTime if
+// they have no action to run.
+func (s *LiteSimulator) nextActionTime(ctx context.Context) time.Time {
+	minTime := event.MaxTime
+	for _, sched := range s.schedulers {
+		if t := sched.NextActionTime(ctx); t.Before(minTime) {
+			minTime = t
+		}
+	}
+	return minTime
 }
 
 func (s *LiteSimulator) Run(ctx context.Context) {
@@ -105,6 +182,7 @@ func (s *LiteSimulator) Run(ctx context.Context) {
 			copy(ongoing, upNext)
 
 			upNext = make([]int, 0)
+			s.shuffle(ongoing)
 			for _, id := range ongoing {
 				// run one action for this peer
 				s.schedulers[id].RunOne(ctx)
@@ -75,3 +75,38 @@ func TestLiteSimulator(t *testing.T) {
 		require.Equal(t, i, e)
 	}
 }
+
+func TestLiteSimulatorSeed(t *testing.T) {
+	ctx := context.Background()
+
+	// run returns the order in which nodes due at the same time ran, with Run or Step
+	run := func(seed int64, step bool) []int {
+		clk := clock.NewMock()
+		sim := NewLiteSimulator(clk)
+		sim.SetSeed(seed)
+
+		var order []int
+		for i := 0; i < 16; i++ {
+			i := i
+			sched := event.NewSimpleScheduler(clk)
+			sim.Add(sched)
+			event.ScheduleActionIn(ctx, sched, time.Second, event.BasicAction(func(context.Context) {
+				order = append(order, i)
+			}))
+		}
+		if step {
+			for sim.Step(ctx) {
+			}
+		} else {
+			sim.Run(ctx)
+		}
+		require.Len(t, order, 16)
+		return order
+	}
+
+	for _, step := range []bool{false, true} {
+		// the same seed gives the same order, another seed gives another order
+		require.Equal(t, run(1, step), run(1, step))
+		require.NotEqual(t, run(1, step), run(2, step))
+	}
+}
@@ -0,0 +1,230 @@
+package sim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+	"github.com/plprobelab/go-kademlia/util"
+)
+
+// Snapshot is the state of a simulated Network at a step of its simulation: the routing tables,
+// peerstores and scheduler queues of its nodes, and the messages in flight between them. The
+// actions of a simulation are closures that cannot be copied, so a snapshot describes the state
+// rather than holding it. It can be written to disk, compared with the state of another run, and
+// a simulation is restored to it by re-executing the simulation, see TimeTravel.Restore.
+type Snapshot struct {
+	Step     int            `json:"step"` // the step of the simulation the snapshot was taken after
+	Time     time.Time      `json:"time"` // the virtual time of the snapshot
+	Hash     uint64         `json:"hash"` // the state hash of the simulation, zero if it has no hash function
+	Nodes    []NodeState    `json:"nodes"`
+	InFlight []MessageState `json:"in_flight"` // the messages sent but not delivered yet, in the order they were sent
+}
+
+// NodeState is the state of a node captured in a Snapshot.
+type NodeState struct {
+	ID           string         `json:"id"`
+	RoutingTable []string       `json:"routing_table"` // the nodes in the routing table, ordered by id
+	Peerstore    []string       `json:"peerstore"`     // the nodes with an unexpired peerstore entry, ordered by id
+	Queued       []string       `json:"queued"`        // the names of the actions waiting to run, in order
+	Planned      []PlannedState `json:"planned"`       // the actions planned to run later, in the order they are due
+}
+
+// PlannedState describes an action planned to run later.
+type PlannedState struct {
+	Name string        `json:"name"`
+	In   time.Duration `json:"in"` // the time from the snapshot until the action is due
+}
+
+// MessageState describes a message in flight.
+type MessageState struct {
+	From     string             `json:"from"`
+	To       string             `json:"to"`
+	Protocol address.ProtocolID `json:"protocol"`
+	Stream   endpoint.StreamID  `json:"stream"`
+	Type     string             `json:"type"`
+	Summary  string             `json:"summary"`
+	In       time.Duration      `json:"in"` // the time from the snapshot until the message is due
+}
+
+// Snapshot captures the state of the network.
+func (n *Network[K, A]) Snapshot(ctx context.Context) *Snapshot {
+	ctx, span := util.StartSpan(ctx, "Network.Snapshot")
+	defer span.End()
+
+	now := n.Clock.Now()
+	s := &Snapshot{
+		Time:     now,
+		Nodes:    make([]NodeState, len(n.Nodes)),
+		InFlight: n.Router.inFlightStates(now),
+	}
+	for i, node := range n.Nodes {
+		s.Nodes[i] = node.state(ctx)
+	}
+	return s
+}
+
+func (node *Node[K, A]) state(ctx context.Context) NodeState {
+	st := NodeState{ID: node.Info.ID().String()}
+	for _, id := range node.routingTableNodes() {
+		st.RoutingTable = append(st.RoutingTable, id.String())
+	}
+	sort.Strings(st.RoutingTable)
+
+	if peers, err := node.Endpoint.Peerstore().Peers(ctx); err == nil {
+		for _, p := range peers {
+			st.Peerstore = append(st.Peerstore, p.ID().String())
+		}
+		sort.Strings(st.Peerstore)
+	}
+
+	dump := node.Scheduler.Dump(ctx)
+	for _, a := range dump.Queued {
+		st.Queued = append(st.Queued, a.Name)
+	}
+	for _, a := range dump.Planned {
+		st.Planned = append(st.Planned, PlannedState{Name: a.Name, In: a.Time.Sub(dump.Now)})
+	}
+	return st
+}
+
+// routingTableNodes returns all the nodes in the routing table of the node.
+func (node *Node[K, A]) routingTableNodes() []kad.NodeID[K] {
+	if all, ok := node.RoutingTable.(interface{ AllNodes() []kad.NodeID[K] }); ok {
+		return all.AllNodes()
+	}
+	return node.RoutingTable.NearestNodes(node.Info.ID().Key(), math.MaxInt32)
+}
+
+// inFlightStates describes the messages in flight, in the order they were sent.
+func (r *Router[K, A]) inFlightStates(now time.Time) []MessageState {
+	ds := make([]*delivery[K, A], 0, len(r.inFlight))
+	for d := range r.inFlight {
+		ds = append(ds, d)
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i].seq < ds[j].seq })
+
+	states := make([]MessageState, len(ds))
+	for i, d := range ds {
+		states[i] = MessageState{
+			From:     d.from.String(),
+			To:       d.to.String(),
+			Protocol: d.protoID,
+			Stream:   d.sid,
+			Type:     messageType(d.msg),
+			Summary:  summarize[K, A](d.msg),
+			In:       d.due.Sub(now),
+		}
+	}
+	return states
+}
+
+// Diff describes the first difference between s and o, or returns an empty string if they
+// describe the same state.
+func (s *Snapshot) Diff(o *Snapshot) string {
+	switch {
+	case s.Step != o.Step:
+		return fmt.Sprintf("step %d != %d", s.Step, o.Step)
+	case !s.Time.Equal(o.Time):
+		return fmt.Sprintf("time %s != %s", s.Time, o.Time)
+	case s.Hash != o.Hash:
+		return fmt.Sprintf("hash %x != %x", s.Hash, o.Hash)
+	case len(s.Nodes) != len(o.Nodes):
+		return fmt.Sprintf("%d nodes != %d nodes", len(s.Nodes), len(o.Nodes))
+	}
+	for i := range s.Nodes {
+		if !reflect.DeepEqual(s.Nodes[i], o.Nodes[i]) {
+			return fmt.Sprintf("node %d (%s) differs", i, s.Nodes[i].ID)
+		}
+	}
+	if len(s.InFlight) != len(o.InFlight) {
+		return fmt.Sprintf("%d messages in flight != %d", len(s.InFlight), len(o.InFlight))
+	}
+	for i := range s.InFlight {
+		if s.InFlight[i] != o.InFlight[i] {
+			return fmt.Sprintf("message in flight %d differs", i)
+		}
+	}
+	return ""
+}
+
+// WriteJSON writes the snapshot to w in JSON.
+func (s *Snapshot) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// ReadSnapshot decodes a snapshot written by Snapshot.WriteJSON from r.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	var s Snapshot
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, fmt.Errorf("decode snapshot: %w", err)
+	}
+	return &s, nil
+}
+
+// SnapshotFunc captures the state of the simulation of a TimeTravel, as rebuilt by its SetupFunc,
+// typically by calling Network.Snapshot on the network it built last.
+type SnapshotFunc func(ctx context.Context) *Snapshot
+
+// Snapshot captures the state of the simulation with capture, recording the current step and
+// state hash, to checkpoint a long experiment.
+func (tt *TimeTravel) Snapshot(ctx context.Context, capture SnapshotFunc) *Snapshot {
+	s := capture(ctx)
+	s.Step = tt.step
+	s.Hash = tt.stateHash()
+	return s
+}
+
+// Restore moves the simulation to the step of s, re-executing it if needed, and checks that its
+// state is the one s describes. It returns ErrNonDeterministic if the restored state differs, for
+// example because the snapshot was taken with a different setup.
+func (tt *TimeTravel) Restore(ctx context.Context, s *Snapshot, capture SnapshotFunc) error {
+	ctx, span := util.StartSpan(ctx, "TimeTravel.Restore")
+	defer span.End()
+
+	if err := tt.Seek(ctx, s.Step); err != nil {
+		return err
+	}
+	if diff := tt.Snapshot(ctx, capture).Diff(s); diff != "" {
+		return fmt.Errorf("%w: %s", ErrNonDeterministic, diff)
+	}
+	return nil
+}
+
+// Bisect finds the first step after from, and up to to, after which check fails, to find when an
+// invariant broke. check must hold after step from and fail after step to. Bisect leaves the
+// simulation at the step it returns.
+func (tt *TimeTravel) Bisect(ctx context.Context, from, to int, check func() bool) (int, error) {
+	ctx, span := util.StartSpan(ctx, "TimeTravel.Bisect")
+	defer span.End()
+
+	if from < 0 || to <= from {
+		return 0, ErrInvalidStep
+	}
+	lo, hi := from, to // check holds after lo and fails after hi
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		if err := tt.Seek(ctx, mid); err != nil {
+			return 0, err
+		}
+		if check() {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	if err := tt.Seek(ctx, hi); err != nil {
+		return 0, err
+	}
+	return hi, nil
+}
@@ -0,0 +1,114 @@
+package sim
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+// networkSetup returns a setup function for a network of nodes in a ring, the first of which
+// sends requests to the others. The returned pointers refer to the network of the most recently
+// built simulation and to the number of responses its first node received.
+func networkSetup(t *testing.T) (SetupFunc, **Network[key.Key8, net.IP], *int) {
+	n := new(*Network[key.Key8, net.IP])
+	responses := new(int)
+	setup := func(ctx context.Context) (*LiteSimulator, StateHashFunc, error) {
+		*responses = 0
+		infos := make([]kad.NodeInfo[key.Key8, net.IP], 6)
+		for i := range infos {
+			infos[i] = kadtest.NewInfo[key.Key8, net.IP](kadtest.NewID(key.Key8(i)), nil)
+		}
+		cfg := DefaultNetworkConfig[key.Key8]()
+		cfg.Topology = RingTopology(6)
+		nw, err := NewNetwork(ctx, clock.NewMock(), infos, cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		nw.Router.SetLatencyModel(ConstantLatency[key.Key8](time.Second))
+		for _, j := range []int{1, 5} {
+			err := nw.Nodes[0].Endpoint.SendRequestHandleResponse(ctx, cfg.ProtocolID, infos[j].ID(),
+				NewRequest[key.Key8, net.IP](key.Key8(3)), &Message[key.Key8, net.IP]{}, 10*time.Second,
+				func(ctx context.Context, resp kad.Response[key.Key8, net.IP], err error) {
+					require.NoError(t, err)
+					*responses++
+				})
+			require.NoError(t, err)
+		}
+		*n = nw
+		return nw.Simulator, func() uint64 { return uint64(*responses) }, nil
+	}
+	return setup, n, responses
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	ctx := context.Background()
+	setup, n, responses := networkSetup(t)
+	capture := func(ctx context.Context) *Snapshot { return (*n).Snapshot(ctx) }
+
+	tt, err := NewTimeTravel(ctx, setup, nil)
+	require.NoError(t, err)
+	require.NoError(t, tt.Seek(ctx, 2))
+
+	s := tt.Snapshot(ctx, capture)
+	require.Equal(t, 2, s.Step)
+	require.Len(t, s.Nodes, 6)
+	require.Equal(t, []string{"1", "5"}, s.Nodes[0].RoutingTable)
+	require.Equal(t, []string{"1", "5"}, s.Nodes[0].Peerstore)
+	require.NotEmpty(t, s.InFlight)
+	for _, m := range s.InFlight {
+		require.Equal(t, time.Second, m.In)
+	}
+
+	// the snapshot survives a round trip to disk
+	var buf bytes.Buffer
+	require.NoError(t, s.WriteJSON(&buf))
+	read, err := ReadSnapshot(&buf)
+	require.NoError(t, err)
+	require.Empty(t, read.Diff(s))
+
+	// after running on, the simulation is restored to the snapshot
+	tt.Run(ctx)
+	require.Equal(t, 2, *responses)
+	require.NotEmpty(t, tt.Snapshot(ctx, capture).Diff(s))
+	require.NoError(t, tt.Restore(ctx, read, capture))
+	require.Equal(t, 2, tt.CurrentStep())
+	require.Empty(t, tt.Snapshot(ctx, capture).Diff(s))
+
+	// a snapshot the simulation cannot reach is reported
+	read.Nodes[3].RoutingTable = nil
+	require.ErrorIs(t, tt.Restore(ctx, read, capture), ErrNonDeterministic)
+
+	_, err = ReadSnapshot(bytes.NewBufferString("{"))
+	require.Error(t, err)
+}
+
+func TestTimeTravelBisect(t *testing.T) {
+	ctx := context.Background()
+	setup, _, responses := networkSetup(t)
+
+	tt, err := NewTimeTravel(ctx, setup, nil)
+	require.NoError(t, err)
+	tt.Run(ctx)
+	end := tt.CurrentStep()
+
+	// find the step after which the first node received its second response
+	check := func() bool { return *responses < 2 }
+	step, err := tt.Bisect(ctx, 0, end, check)
+	require.NoError(t, err)
+	require.Equal(t, step, tt.CurrentStep())
+	require.False(t, check())
+	require.NoError(t, tt.Seek(ctx, step-1))
+	require.True(t, check())
+
+	_, err = tt.Bisect(ctx, 3, 3, check)
+	require.ErrorIs(t, err, ErrInvalidStep)
+}
@@ -0,0 +1,195 @@
+package sim
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+	"github.com/plprobelab/go-kademlia/util"
+)
+
+// streamFollowup holds the handler of the responses streamed back to a request.
+type streamFollowup[K kad.Key[K], A kad.Address[A]] struct {
+	handler endpoint.StreamResponseHandlerFn[K, A]
+	timeout time.Duration // the time to wait for each response, zero for no limit
+}
+
+// AddStreamingRequestHandler registers a handler answering the requests for protoID with a
+// stream of responses, replacing any handler registered with AddRequestHandler.
+func (e *Endpoint[K, A]) AddStreamingRequestHandler(protoID address.ProtocolID,
+	req kad.Message, reqHandler endpoint.StreamingRequestHandlerFn[K],
+) error {
+	if reqHandler == nil {
+		return endpoint.ErrNilRequestHandler
+	}
+	delete(e.serverProtos, protoID)
+	e.streamingProtos[protoID] = reqHandler
+	return nil
+}
+
+// SendRequestHandleStream sends a request to the given peer and calls handleResp for each of
+// the responses streamed back, then a final time once the stream ended. The timeout is restarted
+// on each response received.
+func (e *Endpoint[K, A]) SendRequestHandleStream(ctx context.Context,
+	protoID address.ProtocolID, id kad.NodeID[K], req kad.Message,
+	resp kad.Message, timeout time.Duration,
+	handleResp endpoint.StreamResponseHandlerFn[K, A],
+) error {
+	ctx, span := util.StartMessageSpan(ctx, "SendRequestHandleStream", func() []attribute.KeyValue {
+		return []attribute.KeyValue{attribute.Stringer("id", id)}
+	})
+	defer span.End()
+
+	if handleResp == nil {
+		return endpoint.ErrNilResponseHandler
+	}
+
+	if e.dialsAsync(id) {
+		span.AddEvent("Dialling peer")
+		e.dialAsync(ctx, id, func(ctx context.Context, err error) {
+			if err != nil {
+				handleResp(ctx, nil, true, err)
+				return
+			}
+			e.startStream(ctx, protoID, id, req, timeout, handleResp)
+		})
+		return nil
+	}
+	if err := e.DialPeer(ctx, id); err != nil {
+		span.RecordError(err)
+		e.sched.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
+			handleResp(ctx, nil, true, err)
+		}))
+		return nil
+	}
+	e.startStream(ctx, protoID, id, req, timeout, handleResp)
+	return nil
+}
+
+// startStream sends a request to the connected peer id and waits for the responses of its stream.
+func (e *Endpoint[K, A]) startStream(ctx context.Context, protoID address.ProtocolID, id kad.NodeID[K],
+	req kad.Message, timeout time.Duration, handleResp endpoint.StreamResponseHandlerFn[K, A],
+) {
+	sid := e.router.NewStreamID()
+	if _, err := e.sendMessage(ctx, id, protoID, sid, req); err != nil {
+		e.sched.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
+			handleResp(ctx, nil, true, err)
+		}))
+		return
+	}
+
+	e.streamMu.Lock()
+	defer e.streamMu.Unlock()
+	e.streamChunks[sid] = &streamFollowup[K, A]{handler: handleResp, timeout: timeout}
+	e.scheduleStreamTimeout(ctx, id, sid, timeout)
+}
+
+// scheduleStreamTimeout schedules the timeout of the wait for the next response of stream sid.
+// e.streamMu must be held.
+func (e *Endpoint[K, A]) scheduleStreamTimeout(ctx context.Context, id kad.NodeID[K], sid endpoint.StreamID, timeout time.Duration) {
+	if timeout == 0 {
+		return
+	}
+	e.streamTimeout[sid] = event.ScheduleActionIn(ctx, e.sched, timeout,
+		event.BasicAction(func(ctx context.Context) {
+			ctx, span := util.StartMessageSpan(ctx, "SendRequestHandleStream timeout", func() []attribute.KeyValue {
+				return []attribute.KeyValue{attribute.Stringer("id", id)}
+			})
+			defer span.End()
+
+			e.streamMu.Lock()
+			f, ok := e.streamChunks[sid]
+			delete(e.streamChunks, sid)
+			delete(e.streamTimeout, sid)
+			e.streamMu.Unlock()
+
+			if ok {
+				f.handler(ctx, nil, true, endpoint.ErrTimeout)
+			}
+		}))
+}
+
+// handleStreamResponse handles msg if it was streamed back to a request sent with
+// SendRequestHandleStream, and reports whether it did.
+func (e *Endpoint[K, A]) handleStreamResponse(ctx context.Context, id kad.NodeID[K], sid endpoint.StreamID, msg kad.Message) bool {
+	e.streamMu.Lock()
+	f, ok := e.streamChunks[sid]
+	if !ok {
+		e.streamMu.Unlock()
+		return false
+	}
+	if timeout, ok := e.streamTimeout[sid]; ok {
+		e.sched.RemovePlannedAction(ctx, timeout)
+		delete(e.streamTimeout, sid)
+	}
+
+	var (
+		resp kad.Response[K, A]
+		last bool
+		err  error
+	)
+	switch m := msg.(type) {
+	case *EndOfStream[K, A]:
+		last = true
+		if m.Aborted {
+			err = endpoint.ErrStreamAborted
+		}
+	case *Throttled[K, A]:
+		last, err = true, endpoint.ErrThrottled
+	case kad.Response[K, A]:
+		if e.signatures != nil {
+			err = e.signatures.CheckCloserNodes(m)
+		}
+		if err != nil {
+			last = true
+		} else {
+			resp = m
+		}
+	default:
+		last, err = true, ErrInvalidResponseType
+	}
+
+	if last {
+		delete(e.streamChunks, sid)
+	} else {
+		e.scheduleStreamTimeout(ctx, id, sid, f.timeout)
+	}
+	e.streamMu.Unlock()
+
+	if resp != nil {
+		for _, p := range resp.CloserNodes() {
+			e.peerstore.Add(ctx, p, e.closerTTL)
+			e.peerstore.SetConnectedness(p.ID(), endpoint.CanConnect)
+		}
+	}
+	e.sched.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
+		f.handler(ctx, resp, last, err)
+	}))
+	return true
+}
+
+// serveStream runs a streaming request handler, sending each of its responses to the requester
+// followed by an EndOfStream.
+func (e *Endpoint[K, A]) serveStream(ctx context.Context, id kad.NodeID[K], protoID address.ProtocolID,
+	sid endpoint.StreamID, req kad.Message, handler endpoint.StreamingRequestHandlerFn[K],
+) {
+	ctx, span := util.StartMessageSpan(ctx, "serveStream", func() []attribute.KeyValue {
+		return []attribute.KeyValue{attribute.Stringer("id", id)}
+	})
+	defer span.End()
+
+	send := func(resp kad.Message) error {
+		_, err := e.sendMessage(ctx, id, protoID, sid, resp)
+		return err
+	}
+	err := handler(ctx, id, req, send)
+	if err != nil {
+		span.RecordError(err)
+	}
+	e.sendMessage(ctx, id, protoID, sid, &EndOfStream[K, A]{Aborted: err != nil})
+}
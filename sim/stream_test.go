@@ -0,0 +1,146 @@
+package sim
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+type streamChunk struct {
+	resp kad.Response[key.Key256, net.IP]
+	last bool
+	err  error
+}
+
+// newStreamingPair returns a client and a server endpoint connected to each other, with their
+// schedulers.
+func newStreamingPair(t *testing.T, clk clock.Clock) ([2]*Endpoint[key.Key256, net.IP], [2]event.AwareScheduler) {
+	t.Helper()
+	router := NewRouter[key.Key256, net.IP]()
+
+	var eps [2]*Endpoint[key.Key256, net.IP]
+	var scheds [2]event.AwareScheduler
+	ids := make([]kad.NodeInfo[key.Key256, net.IP], 2)
+	for i := range eps {
+		ids[i] = kadtest.NewInfo[key.Key256, net.IP](kadtest.NewID(kadtest.Key256WithLeadingBytes([]byte{byte(i)})), nil)
+		scheds[i] = event.NewSimpleScheduler(clk)
+		eps[i] = NewEndpoint[key.Key256, net.IP](ids[i].ID(), scheds[i], router)
+	}
+	require.NoError(t, eps[0].MaybeAddToPeerstore(context.Background(), ids[1], peerstoreTTL))
+	return eps, scheds
+}
+
+func TestSendRequestHandleStream(t *testing.T) {
+	ctx := context.Background()
+	eps, scheds := newStreamingPair(t, clock.NewMock())
+
+	provider := kadtest.NewInfo[key.Key256, net.IP](kadtest.NewID(kadtest.Key256WithLeadingBytes([]byte{2})), nil)
+	err := eps[1].AddStreamingRequestHandler(protoID, nil,
+		func(ctx context.Context, id kad.NodeID[key.Key256], req kad.Message, send func(kad.Message) error) error {
+			for i := 0; i < 3; i++ {
+				if err := send(NewResponse([]kad.NodeInfo[key.Key256, net.IP]{provider})); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	require.NoError(t, err)
+
+	var chunks []streamChunk
+	err = eps[0].SendRequestHandleStream(ctx, protoID, eps[1].self, nil, nil, time.Second,
+		func(ctx context.Context, resp kad.Response[key.Key256, net.IP], last bool, err error) {
+			chunks = append(chunks, streamChunk{resp, last, err})
+		})
+	require.NoError(t, err)
+
+	for scheds[0].RunOne(ctx) || scheds[1].RunOne(ctx) {
+	}
+
+	// the handler is called once per response, then with the terminal marker
+	require.Len(t, chunks, 4)
+	for _, c := range chunks[:3] {
+		require.False(t, c.last)
+		require.NoError(t, c.err)
+		require.Equal(t, provider.ID(), c.resp.CloserNodes()[0].ID())
+	}
+	require.Equal(t, streamChunk{nil, true, nil}, chunks[3])
+
+	// closer nodes of streamed responses are added to the peerstore
+	_, err = eps[0].NetworkAddress(provider.ID())
+	require.NoError(t, err)
+
+	// the stream is forgotten once ended
+	require.Empty(t, eps[0].streamChunks)
+	require.Empty(t, eps[0].streamTimeout)
+}
+
+func TestSendRequestHandleStreamAborted(t *testing.T) {
+	ctx := context.Background()
+	eps, scheds := newStreamingPair(t, clock.NewMock())
+
+	err := eps[1].AddStreamingRequestHandler(protoID, nil,
+		func(ctx context.Context, id kad.NodeID[key.Key256], req kad.Message, send func(kad.Message) error) error {
+			if err := send(NewResponse([]kad.NodeInfo[key.Key256, net.IP]{})); err != nil {
+				return err
+			}
+			return errors.New("storage failure")
+		})
+	require.NoError(t, err)
+
+	var chunks []streamChunk
+	err = eps[0].SendRequestHandleStream(ctx, protoID, eps[1].self, nil, nil, time.Second,
+		func(ctx context.Context, resp kad.Response[key.Key256, net.IP], last bool, err error) {
+			chunks = append(chunks, streamChunk{resp, last, err})
+		})
+	require.NoError(t, err)
+
+	for scheds[0].RunOne(ctx) || scheds[1].RunOne(ctx) {
+	}
+
+	require.Len(t, chunks, 2)
+	require.False(t, chunks[0].last)
+	require.True(t, chunks[1].last)
+	require.ErrorIs(t, chunks[1].err, endpoint.ErrStreamAborted)
+}
+
+func TestSendRequestHandleStreamTimeout(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	eps, scheds := newStreamingPair(t, clk)
+
+	// the server has no handler and never responds
+	var chunks []streamChunk
+	err := eps[0].SendRequestHandleStream(ctx, protoID, eps[1].self, nil, nil, time.Second,
+		func(ctx context.Context, resp kad.Response[key.Key256, net.IP], last bool, err error) {
+			chunks = append(chunks, streamChunk{resp, last, err})
+		})
+	require.NoError(t, err)
+
+	require.True(t, scheds[1].RunOne(ctx))
+	require.False(t, scheds[0].RunOne(ctx))
+
+	clk.Add(time.Second)
+	require.True(t, scheds[0].RunOne(ctx))
+	require.Equal(t, []streamChunk{{nil, true, endpoint.ErrTimeout}}, chunks)
+	require.Empty(t, eps[0].streamChunks)
+}
+
+func TestSendRequestHandleStreamNilHandler(t *testing.T) {
+	eps, _ := newStreamingPair(t, clock.NewMock())
+	err := eps[0].SendRequestHandleStream(context.Background(), protoID, eps[1].self, nil, nil, time.Second, nil)
+	require.ErrorIs(t, err, endpoint.ErrNilResponseHandler)
+
+	err = eps[1].AddStreamingRequestHandler(protoID, nil, nil)
+	require.ErrorIs(t, err, endpoint.ErrNilRequestHandler)
+}
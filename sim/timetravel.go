@@ -0,0 +1,217 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/util"
+)
+
+// StepRecord describes a single action run by a TimeTravel simulation.
+type StepRecord struct {
+	Step      int       // the sequence number of the step, starting at 1
+	Time      time.Time // the simulated time at which the action ran
+	Scheduler int       // the index of the scheduler that ran the action
+	Hash      uint64    // the state hash after the action ran, zero if the simulation has no hash function
+}
+
+// StateHashFunc returns a hash of the state of a simulation. Equal states must produce equal hashes.
+type StateHashFunc func() uint64
+
+// SetupFunc creates a new simulation in its initial state, together with an optional function
+// hashing its state. It is called again each time a TimeTravel moves backwards, so it must build
+// the simulation deterministically: every call must produce a simulation that runs the same actions
+// in the same order.
+type SetupFunc func(ctx context.Context) (*LiteSimulator, StateHashFunc, error)
+
+// TimeTravelConfig specifies optional configuration for a TimeTravel
+type TimeTravelConfig struct {
+	HistorySize int // the number of most recent steps kept in the history ring buffer
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *TimeTravelConfig) Validate() error {
+	if cfg.HistorySize < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "TimeTravelConfig",
+			Err:       fmt.Errorf("history size must be greater than zero"),
+		}
+	}
+	return nil
+}
+
+// DefaultTimeTravelConfig returns the default configuration options for a TimeTravel.
+// Options may be overridden before passing to NewTimeTravel
+func DefaultTimeTravelConfig() *TimeTravelConfig {
+	return &TimeTravelConfig{
+		HistorySize: 1024,
+	}
+}
+
+// TimeTravel runs a simulation one action at a time, recording each step in a ring buffer and
+// allowing the simulation to be moved back to an earlier step. Since the state of a simulation
+// cannot be copied, moving backwards rebuilds the simulation from its initial state, which acts as
+// the checkpoint, and re-executes it up to the chosen step. The state hashes of re-executed steps
+// are compared against the recorded history to detect non-deterministic simulations.
+type TimeTravel struct {
+	cfg   TimeTravelConfig
+	setup SetupFunc
+
+	sim  *LiteSimulator
+	hash StateHashFunc
+
+	// step is the number of steps that have run since the initial state
+	step int
+
+	// history is a ring buffer holding the most recent steps, next is the position of the next record
+	history []StepRecord
+	next    int
+}
+
+// NewTimeTravel creates a TimeTravel for the simulation built by setup.
+// If cfg is nil, the default config is used.
+func NewTimeTravel(ctx context.Context, setup SetupFunc, cfg *TimeTravelConfig) (*TimeTravel, error) {
+	if cfg == nil {
+		cfg = DefaultTimeTravelConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	tt := &TimeTravel{
+		cfg:     *cfg,
+		setup:   setup,
+		history: make([]StepRecord, 0, cfg.HistorySize),
+	}
+	if err := tt.reset(ctx); err != nil {
+		return nil, err
+	}
+	return tt, nil
+}
+
+// Simulator returns the simulation in its current state. The simulation is replaced each time
+// the TimeTravel moves backwards.
+func (tt *TimeTravel) Simulator() *LiteSimulator {
+	return tt.sim
+}
+
+// CurrentStep returns the number of steps that have run since the initial state.
+func (tt *TimeTravel) CurrentStep() int {
+	return tt.step
+}
+
+// Step runs the next action of the simulation and records it. It returns false if there are no
+// more actions to run.
+func (tt *TimeTravel) Step(ctx context.Context) bool {
+	id, ok := tt.sim.step(ctx)
+	if !ok {
+		return false
+	}
+	tt.step++
+	tt.record(StepRecord{
+		Step:      tt.step,
+		Time:      tt.sim.Clock().Now(),
+		Scheduler: id,
+		Hash:      tt.stateHash(),
+	})
+	return true
+}
+
+// Run steps the simulation until there are no more actions to run.
+func (tt *TimeTravel) Run(ctx context.Context) {
+	for tt.Step(ctx) {
+	}
+}
+
+// StepBack moves the simulation back by n steps.
+func (tt *TimeTravel) StepBack(ctx context.Context, n int) error {
+	return tt.Seek(ctx, tt.step-n)
+}
+
+// Seek moves the simulation to the state it had after the given step. Moving forwards runs the
+// simulation, moving backwards re-executes it from its initial state. Seek returns ErrInvalidStep
+// if the simulation finishes before reaching the step and ErrNonDeterministic if a re-executed step
+// differs from the recorded history.
+func (tt *TimeTravel) Seek(ctx context.Context, step int) error {
+	ctx, span := util.StartSpan(ctx, "TimeTravel.Seek")
+	defer span.End()
+
+	if step < 0 {
+		return ErrInvalidStep
+	}
+
+	if step < tt.step {
+		// keep the recorded history to verify the re-executed steps
+		recorded := tt.History()
+		if err := tt.reset(ctx); err != nil {
+			return err
+		}
+		for tt.step < step {
+			id, ok := tt.sim.step(ctx)
+			if !ok {
+				return ErrNonDeterministic
+			}
+			tt.step++
+			rec := StepRecord{
+				Step:      tt.step,
+				Time:      tt.sim.Clock().Now(),
+				Scheduler: id,
+				Hash:      tt.stateHash(),
+			}
+			if len(recorded) > 0 && recorded[0].Step <= tt.step {
+				if recorded[tt.step-recorded[0].Step] != rec {
+					return ErrNonDeterministic
+				}
+			}
+			tt.record(rec)
+		}
+		return nil
+	}
+
+	for tt.step < step {
+		if !tt.Step(ctx) {
+			return ErrInvalidStep
+		}
+	}
+	return nil
+}
+
+// History returns the recorded steps held in the ring buffer, oldest first.
+func (tt *TimeTravel) History() []StepRecord {
+	h := make([]StepRecord, 0, len(tt.history))
+	if len(tt.history) < tt.cfg.HistorySize {
+		return append(h, tt.history...)
+	}
+	h = append(h, tt.history[tt.next:]...)
+	return append(h, tt.history[:tt.next]...)
+}
+
+func (tt *TimeTravel) reset(ctx context.Context) error {
+	sim, hash, err := tt.setup(ctx)
+	if err != nil {
+		return fmt.Errorf("setup simulation: %w", err)
+	}
+	tt.sim = sim
+	tt.hash = hash
+	tt.step = 0
+	tt.history = tt.history[:0]
+	tt.next = 0
+	return nil
+}
+
+func (tt *TimeTravel) record(rec StepRecord) {
+	if len(tt.history) < tt.cfg.HistorySize {
+		tt.history = append(tt.history, rec)
+		return
+	}
+	tt.history[tt.next] = rec
+	tt.next = (tt.next + 1) % tt.cfg.HistorySize
+}
+
+func (tt *TimeTravel) stateHash() uint64 {
+	if tt.hash == nil {
+		return 0
+	}
+	return tt.hash()
+}
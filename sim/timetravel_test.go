@@ -0,0 +1,166 @@
+package sim
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+)
+
+func TestTimeTravelConfigValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		cfg := DefaultTimeTravelConfig()
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("history size positive", func(t *testing.T) {
+		cfg := DefaultTimeTravelConfig()
+		cfg.HistorySize = 0
+		require.Error(t, cfg.Validate())
+		cfg.HistorySize = -1
+		require.Error(t, cfg.Validate())
+	})
+}
+
+// counterSetup returns a setup function for a simulation of two schedulers appending to a shared log.
+// The returned pointer always refers to the log of the most recently built simulation.
+func counterSetup() (SetupFunc, *[]int) {
+	log := new([]int)
+	setup := func(ctx context.Context) (*LiteSimulator, StateHashFunc, error) {
+		*log = []int{}
+		clk := clock.NewMock()
+		a := event.NewSimpleScheduler(clk)
+		b := event.NewSimpleScheduler(clk)
+
+		sim := NewLiteSimulator(clk)
+		AddSchedulers(sim, a, b)
+
+		for i := 0; i < 3; i++ {
+			i := i
+			event.ScheduleActionIn(ctx, a, time.Duration(i)*time.Second, event.BasicAction(func(context.Context) {
+				*log = append(*log, 10+i)
+			}))
+			event.ScheduleActionIn(ctx, b, time.Duration(i)*time.Second, event.BasicAction(func(context.Context) {
+				*log = append(*log, 20+i)
+			}))
+		}
+
+		hash := func() uint64 {
+			var h uint64
+			for _, v := range *log {
+				h = h*31 + uint64(v)
+			}
+			return h
+		}
+		return sim, hash, nil
+	}
+	return setup, log
+}
+
+func TestTimeTravelStepMatchesRun(t *testing.T) {
+	ctx := context.Background()
+	setup, log := counterSetup()
+
+	sim, _, err := setup(ctx)
+	require.NoError(t, err)
+	sim.Run(ctx)
+	expected := append([]int{}, *log...)
+
+	tt, err := NewTimeTravel(ctx, setup, nil)
+	require.NoError(t, err)
+	tt.Run(ctx)
+
+	require.Equal(t, expected, *log)
+	require.Equal(t, 6, tt.CurrentStep())
+	require.False(t, tt.Step(ctx))
+}
+
+func TestTimeTravelStepBack(t *testing.T) {
+	ctx := context.Background()
+	setup, log := counterSetup()
+
+	tt, err := NewTimeTravel(ctx, setup, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, tt.Seek(ctx, 4))
+	require.Equal(t, 4, tt.CurrentStep())
+	atFour := append([]int{}, *log...)
+	require.Len(t, atFour, 4)
+	timeAtFour := tt.Simulator().Clock().Now()
+
+	tt.Run(ctx)
+	require.Len(t, *log, 6)
+
+	// step back to the state after step 4
+	require.NoError(t, tt.StepBack(ctx, 2))
+	require.Equal(t, 4, tt.CurrentStep())
+	require.Equal(t, atFour, *log)
+	require.Equal(t, timeAtFour, tt.Simulator().Clock().Now())
+
+	hist := tt.History()
+	require.Len(t, hist, 4)
+	for i, rec := range hist {
+		require.Equal(t, i+1, rec.Step)
+	}
+	require.Equal(t, 0, hist[0].Scheduler)
+	require.Equal(t, 1, hist[1].Scheduler)
+
+	// back to the initial state
+	require.NoError(t, tt.Seek(ctx, 0))
+	require.Empty(t, *log)
+
+	require.ErrorIs(t, tt.Seek(ctx, -1), ErrInvalidStep)
+	require.ErrorIs(t, tt.Seek(ctx, 7), ErrInvalidStep)
+}
+
+func TestTimeTravelHistoryRingBuffer(t *testing.T) {
+	ctx := context.Background()
+	setup, _ := counterSetup()
+
+	cfg := DefaultTimeTravelConfig()
+	cfg.HistorySize = 4
+	tt, err := NewTimeTravel(ctx, setup, cfg)
+	require.NoError(t, err)
+	tt.Run(ctx)
+
+	hist := tt.History()
+	require.Len(t, hist, 4)
+	for i, rec := range hist {
+		require.Equal(t, i+3, rec.Step)
+	}
+
+	// stepping back before the oldest recorded step still works
+	require.NoError(t, tt.Seek(ctx, 1))
+	require.Equal(t, 1, tt.CurrentStep())
+}
+
+func TestTimeTravelDetectsNonDeterminism(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	setup := func(ctx context.Context) (*LiteSimulator, StateHashFunc, error) {
+		calls++
+		clk := clock.NewMock()
+		a := event.NewSimpleScheduler(clk)
+		sim := NewLiteSimulator(clk)
+		sim.Add(a)
+
+		state := uint64(0)
+		seed := uint64(calls)
+		for i := 0; i < 3; i++ {
+			a.EnqueueAction(ctx, event.BasicAction(func(context.Context) {
+				state += seed
+			}))
+		}
+		return sim, func() uint64 { return state }, nil
+	}
+
+	tt, err := NewTimeTravel(ctx, setup, nil)
+	require.NoError(t, err)
+	tt.Run(ctx)
+
+	require.ErrorIs(t, tt.StepBack(ctx, 1), ErrNonDeterministic)
+}
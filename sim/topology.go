@@ -0,0 +1,298 @@
+package sim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/peerstore"
+	"github.com/plprobelab/go-kademlia/routing/simplert"
+)
+
+// Topology decides which nodes of a simulation know each other when it starts. It returns, for
+// each of the n nodes, the indexes of the nodes in its routing table, drawing any random choice
+// from rng.
+type Topology func(rng *rand.Rand, n int) [][]int
+
+// connect makes nodes i and j know each other.
+func connect(adj [][]int, i, j int) {
+	adj[i] = append(adj[i], j)
+	adj[j] = append(adj[j], i)
+}
+
+// RandomTopology returns a Topology in which each node connects to degree other nodes chosen at
+// random. Connections are mutual, so that nodes end up knowing degree nodes or more.
+func RandomTopology(degree int) Topology {
+	return func(rng *rand.Rand, n int) [][]int {
+		adj := make([][]int, n)
+		if n < 2 {
+			return adj
+		}
+		d := degree
+		if d > n-1 {
+			d = n - 1
+		}
+		known := make([]map[int]struct{}, n)
+		for i := range known {
+			known[i] = make(map[int]struct{})
+		}
+		for i := 0; i < n; i++ {
+			// draw nodes at random rather than permuting all nodes, which would take quadratic
+			// time in large networks, falling back to a permutation if draws keep colliding
+			for draws := 0; len(known[i]) < d && draws < 4*d; draws++ {
+				if j := rng.Intn(n); j != i {
+					if _, ok := known[i][j]; !ok {
+						known[i][j] = struct{}{}
+						known[j][i] = struct{}{}
+						connect(adj, i, j)
+					}
+				}
+			}
+			if len(known[i]) >= d {
+				continue
+			}
+			for _, j := range rng.Perm(n) {
+				if len(known[i]) >= d {
+					break
+				}
+				if _, ok := known[i][j]; ok || j == i {
+					continue
+				}
+				known[i][j] = struct{}{}
+				known[j][i] = struct{}{}
+				connect(adj, i, j)
+			}
+		}
+		return adj
+	}
+}
+
+// RingTopology returns a Topology in which the first bootstrappers nodes are bootstrap servers
+// connected in a ring, each knowing the previous and the next, and every other node connects to
+// a bootstrap server chosen at random, as nodes joining a network do.
+func RingTopology(bootstrappers int) Topology {
+	return func(rng *rand.Rand, n int) [][]int {
+		adj := make([][]int, n)
+		b := bootstrappers
+		if b > n {
+			b = n
+		}
+		if b < 1 {
+			return adj
+		}
+		switch {
+		case b == 2:
+			connect(adj, 0, 1)
+		case b > 2:
+			for i := 0; i < b; i++ {
+				connect(adj, i, (i+1)%b)
+			}
+		}
+		for i := b; i < n; i++ {
+			connect(adj, i, rng.Intn(b))
+		}
+		return adj
+	}
+}
+
+// StarTopology returns a Topology in which every node connects to the first node, and knows no
+// other node.
+func StarTopology() Topology {
+	return func(_ *rand.Rand, n int) [][]int {
+		adj := make([][]int, n)
+		for i := 1; i < n; i++ {
+			connect(adj, 0, i)
+		}
+		return adj
+	}
+}
+
+// CrawlSnapshot is the routing graph of a network as found by a crawler, listing the neighbours
+// each peer returned. Its JSON form is read by ReadCrawlSnapshot.
+type CrawlSnapshot struct {
+	Peers []CrawlPeer `json:"peers"`
+}
+
+// CrawlPeer is a peer found by a crawler and the peers in its routing table.
+type CrawlPeer struct {
+	ID        string   `json:"id"`
+	Neighbors []string `json:"neighbors"`
+}
+
+// ReadCrawlSnapshot decodes a CrawlSnapshot in JSON from r.
+func ReadCrawlSnapshot(r io.Reader) (*CrawlSnapshot, error) {
+	var s CrawlSnapshot
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, fmt.Errorf("decode crawl snapshot: %w", err)
+	}
+	return &s, nil
+}
+
+// CrawlTopology returns a Topology reproducing the routing graph of a crawled network: node i of
+// the simulation stands for the i-th peer of the snapshot and knows the nodes standing for its
+// neighbours. Neighbours that were not crawled, and the peers beyond the number of nodes of the
+// simulation, are ignored. Nodes beyond the number of peers of the snapshot know no other node.
+func CrawlTopology(s *CrawlSnapshot) Topology {
+	return func(_ *rand.Rand, n int) [][]int {
+		adj := make([][]int, n)
+		index := make(map[string]int, len(s.Peers))
+		for i, p := range s.Peers {
+			if i < n {
+				index[p.ID] = i
+			}
+		}
+		for i, p := range s.Peers {
+			if i >= n {
+				break
+			}
+			for _, nb := range p.Neighbors {
+				if j, ok := index[nb]; ok && j != i {
+					adj[i] = append(adj[i], j)
+				}
+			}
+		}
+		return adj
+	}
+}
+
+// RoutingTableFunc creates the routing table of the node self.
+type RoutingTableFunc[K kad.Key[K]] func(self kad.NodeID[K]) kad.RoutingTable[K, kad.NodeID[K]]
+
+// NetworkConfig specifies optional configuration for a Network.
+type NetworkConfig[K kad.Key[K]] struct {
+	Topology     Topology            // the nodes each node knows when the simulation starts
+	RoutingTable RoutingTableFunc[K] // creates the routing table of each node
+	ProtocolID   address.ProtocolID  // the protocol the servers of the nodes handle
+	Server       *ServerConfig       // the configuration of the servers of the nodes
+	PeerstoreTTL time.Duration       // the time for which the nodes keep the nodes they know in their peerstore
+	Seed         int64               // seeds the random choices of the topology, for reproducible simulations
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *NetworkConfig[K]) Validate() error {
+	if cfg.Topology == nil {
+		return &kaderr.ConfigurationError{
+			Component: "NetworkConfig",
+			Err:       fmt.Errorf("topology must not be nil"),
+		}
+	}
+	if cfg.RoutingTable == nil {
+		return &kaderr.ConfigurationError{
+			Component: "NetworkConfig",
+			Err:       fmt.Errorf("routing table constructor must not be nil"),
+		}
+	}
+	if cfg.ProtocolID == "" {
+		return &kaderr.ConfigurationError{
+			Component: "NetworkConfig",
+			Err:       fmt.Errorf("protocol id must not be empty"),
+		}
+	}
+	if cfg.PeerstoreTTL < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "NetworkConfig",
+			Err:       fmt.Errorf("peerstore ttl must be greater than zero"),
+		}
+	}
+	return nil
+}
+
+// DefaultNetworkConfig returns the default configuration options for a Network.
+// Options may be overridden before passing to NewNetwork.
+func DefaultNetworkConfig[K kad.Key[K]]() *NetworkConfig[K] {
+	return &NetworkConfig[K]{
+		Topology: RandomTopology(8),
+		RoutingTable: func(self kad.NodeID[K]) kad.RoutingTable[K, kad.NodeID[K]] {
+			return simplert.New[K, kad.NodeID[K]](self, 20)
+		},
+		ProtocolID:   address.ProtocolID("/sim/kad/1.0.0"),
+		Server:       DefaultServerConfig(),
+		PeerstoreTTL: peerstore.PermanentTTL, // the nodes of the topology stay known for the whole simulation
+	}
+}
+
+// Node is a node of a Network, with the components it is made of.
+type Node[K kad.Key[K], A kad.Address[A]] struct {
+	Info         kad.NodeInfo[K, A]
+	Scheduler    *event.SimpleScheduler
+	Endpoint     *Endpoint[K, A]
+	RoutingTable kad.RoutingTable[K, kad.NodeID[K]]
+	Server       *Server[K, A]
+}
+
+// Network is a simulated network of nodes serving requests for closer nodes, sharing a mock clock
+// and a router, and run by a LiteSimulator. It saves tests from wiring the nodes of a simulation
+// by hand.
+type Network[K kad.Key[K], A kad.Address[A]] struct {
+	Clock     *clock.Mock
+	Router    *Router[K, A]
+	Simulator *LiteSimulator
+	Nodes     []*Node[K, A]
+}
+
+// NewNetwork creates a Network of the nodes described by infos, timed by clk, in which the nodes
+// know each other as the topology of cfg decides. The nodes are in each other's routing tables
+// and peerstores, and their servers handle the protocol of cfg.
+func NewNetwork[K kad.Key[K], A kad.Address[A]](ctx context.Context, clk *clock.Mock, infos []kad.NodeInfo[K, A],
+	cfg *NetworkConfig[K],
+) (*Network[K, A], error) {
+	if cfg == nil {
+		cfg = DefaultNetworkConfig[K]()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	n := &Network[K, A]{
+		Clock:     clk,
+		Router:    NewRouter[K, A](),
+		Simulator: NewLiteSimulator(clk),
+		Nodes:     make([]*Node[K, A], len(infos)),
+	}
+	for i, info := range infos {
+		node := &Node[K, A]{
+			Info:         info,
+			Scheduler:    event.NewSliceScheduler(clk),
+			RoutingTable: cfg.RoutingTable(info.ID()),
+		}
+		node.Endpoint = NewEndpoint[K, A](info.ID(), node.Scheduler, n.Router)
+		node.Server = NewServer[K, A](node.RoutingTable, node.Endpoint, cfg.Server)
+		if err := node.Endpoint.AddRequestHandler(cfg.ProtocolID, nil, node.Server.HandleRequest); err != nil {
+			return nil, err
+		}
+		n.Simulator.Add(node.Scheduler)
+		n.Nodes[i] = node
+	}
+
+	adj := cfg.Topology(rand.New(rand.NewSource(cfg.Seed)), len(infos))
+	for i, known := range adj {
+		for _, j := range known {
+			if err := n.Connect(ctx, i, j, cfg.PeerstoreTTL); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Connect adds node j to the routing table and peerstore of node i, keeping it in the peerstore
+// for ttl.
+func (n *Network[K, A]) Connect(ctx context.Context, i, j int, ttl time.Duration) error {
+	if i < 0 || i >= len(n.Nodes) || j < 0 || j >= len(n.Nodes) {
+		return fmt.Errorf("node index out of range")
+	}
+	from, to := n.Nodes[i], n.Nodes[j]
+	if err := from.Endpoint.MaybeAddToPeerstore(ctx, to.Info, ttl); err != nil {
+		return err
+	}
+	from.RoutingTable.AddNode(to.Info.ID())
+	return nil
+}
@@ -0,0 +1,135 @@
+package sim
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+)
+
+func TestTopologies(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	t.Run("random", func(t *testing.T) {
+		adj := RandomTopology(4)(rng, 50)
+		require.Len(t, adj, 50)
+		for i, known := range adj {
+			require.GreaterOrEqual(t, len(known), 4)
+			require.NotContains(t, known, i)
+			for _, j := range known {
+				require.Contains(t, adj[j], i)
+			}
+		}
+		// a degree larger than the network connects all nodes
+		adj = RandomTopology(10)(rng, 3)
+		for _, known := range adj {
+			require.Len(t, known, 2)
+		}
+	})
+
+	t.Run("ring", func(t *testing.T) {
+		adj := RingTopology(4)(rng, 10)
+		for i := 0; i < 4; i++ {
+			require.Contains(t, adj[i], (i+1)%4)
+			require.Contains(t, adj[i], (i+3)%4)
+		}
+		for i := 4; i < 10; i++ {
+			require.Len(t, adj[i], 1)
+			require.Less(t, adj[i][0], 4)
+		}
+		require.Equal(t, [][]int{{1}, {0}}, RingTopology(2)(rng, 2))
+	})
+
+	t.Run("star", func(t *testing.T) {
+		adj := StarTopology()(rng, 4)
+		require.Equal(t, [][]int{{1, 2, 3}, {0}, {0}, {0}}, adj)
+	})
+
+	t.Run("crawl", func(t *testing.T) {
+		s, err := ReadCrawlSnapshot(strings.NewReader(`{"peers": [
+			{"id": "a", "neighbors": ["b", "c", "unknown"]},
+			{"id": "b", "neighbors": ["a"]},
+			{"id": "c", "neighbors": ["a", "b"]}
+		]}`))
+		require.NoError(t, err)
+		require.Equal(t, [][]int{{1, 2}, {0}, {0, 1}, nil}, CrawlTopology(s)(rng, 4))
+		require.Equal(t, [][]int{{1}, {0}}, CrawlTopology(s)(rng, 2))
+
+		_, err = ReadCrawlSnapshot(strings.NewReader(`{`))
+		require.Error(t, err)
+	})
+}
+
+func TestNetworkConfigValidate(t *testing.T) {
+	cfg := DefaultNetworkConfig[key.Key8]()
+	require.NoError(t, cfg.Validate())
+
+	cfg.Topology = nil
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultNetworkConfig[key.Key8]()
+	cfg.RoutingTable = nil
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultNetworkConfig[key.Key8]()
+	cfg.ProtocolID = ""
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultNetworkConfig[key.Key8]()
+	cfg.PeerstoreTTL = -1
+	require.Error(t, cfg.Validate())
+}
+
+func TestNetwork(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+
+	infos := make([]kad.NodeInfo[key.Key8, net.IP], 5)
+	for i := range infos {
+		infos[i] = kadtest.NewInfo[key.Key8, net.IP](kadtest.NewID(key.Key8(i)), nil)
+	}
+	cfg := DefaultNetworkConfig[key.Key8]()
+	cfg.Topology = StarTopology()
+	n, err := NewNetwork(ctx, clk, infos, cfg)
+	require.NoError(t, err)
+	require.Len(t, n.Nodes, 5)
+
+	keys := func(rt kad.RoutingTable[key.Key8, kad.NodeID[key.Key8]]) []key.Key8 {
+		var ks []key.Key8
+		for _, id := range rt.NearestNodes(key.Key8(0), 10) {
+			ks = append(ks, id.Key())
+		}
+		sort.Slice(ks, func(i, j int) bool { return ks[i] < ks[j] })
+		return ks
+	}
+	require.Equal(t, []key.Key8{1, 2, 3, 4}, keys(n.Nodes[0].RoutingTable))
+	require.Equal(t, []key.Key8{0}, keys(n.Nodes[3].RoutingTable))
+
+	// the nodes serve the requests for closer nodes of the nodes they know
+	var closer []kad.NodeInfo[key.Key8, net.IP]
+	err = n.Nodes[3].Endpoint.SendRequestHandleResponse(ctx, cfg.ProtocolID, infos[0].ID(), NewRequest[key.Key8, net.IP](key.Key8(1)),
+		&Message[key.Key8, net.IP]{}, time.Second, func(ctx context.Context, resp kad.Response[key.Key8, net.IP], err error) {
+			require.NoError(t, err)
+			closer = resp.CloserNodes()
+		})
+	require.NoError(t, err)
+	n.Simulator.Run(ctx)
+	require.NotEmpty(t, closer)
+	require.Equal(t, infos[1].ID(), closer[0].ID())
+
+	require.Error(t, n.Connect(ctx, 0, 5, time.Hour))
+
+	_, err = NewNetwork(ctx, clk, infos, &NetworkConfig[key.Key8]{ProtocolID: address.ProtocolID("/test")})
+	require.Error(t, err)
+}
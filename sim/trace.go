@@ -0,0 +1,199 @@
+package sim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+// TraceEventKind tells whether a TraceEvent records the sending or the delivery of a message.
+type TraceEventKind string
+
+const (
+	TraceSent      TraceEventKind = "sent"
+	TraceDelivered TraceEventKind = "delivered"
+)
+
+// TraceEvent records a message sent or delivered by a Router.
+type TraceEvent[K kad.Key[K]] struct {
+	Seq      int                // the sequence number of the event in its trace, starting at 1
+	Kind     TraceEventKind     // whether the message was sent or delivered
+	Time     time.Time          // the virtual time of the event
+	From     string             // the sender of the message
+	To       string             // the recipient of the message
+	Protocol address.ProtocolID // the protocol the message belongs to
+	Stream   endpoint.StreamID  // the stream the message was sent on
+	Type     string             // the type of the message
+	Summary  string             // a short description of the content of the message
+
+	// the sender and the message, needed to replay the trace and not exported to JSON
+	FromID  kad.NodeID[K] `json:"-"`
+	Message kad.Message   `json:"-"`
+}
+
+// Trace is the log of the messages carried by a Router, recorded with Router.SetTrace.
+type Trace[K kad.Key[K]] struct {
+	Events []TraceEvent[K]
+}
+
+// NewTrace creates an empty trace.
+func NewTrace[K kad.Key[K]]() *Trace[K] {
+	return &Trace[K]{}
+}
+
+func (t *Trace[K]) add(ev TraceEvent[K]) {
+	ev.Seq = len(t.Events) + 1
+	t.Events = append(t.Events, ev)
+}
+
+// Delivered returns the events recording deliveries, in the order they happened.
+func (t *Trace[K]) Delivered() []TraceEvent[K] {
+	var evs []TraceEvent[K]
+	for _, ev := range t.Events {
+		if ev.Kind == TraceDelivered {
+			evs = append(evs, ev)
+		}
+	}
+	return evs
+}
+
+// WriteJSON writes the events of the trace to w in JSON, one event per line. The messages
+// themselves are not written, only their type and summary.
+func (t *Trace[K]) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, ev := range t.Events {
+		if err := enc.Encode(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadTrace decodes a trace written by Trace.WriteJSON from r. The decoded trace lacks the
+// messages and cannot be replayed, but can be compared with the trace of another run.
+func ReadTrace[K kad.Key[K]](r io.Reader) (*Trace[K], error) {
+	t := NewTrace[K]()
+	dec := json.NewDecoder(r)
+	for dec.More() {
+		var ev TraceEvent[K]
+		if err := dec.Decode(&ev); err != nil {
+			return nil, fmt.Errorf("decode trace event %d: %w", len(t.Events)+1, err)
+		}
+		t.Events = append(t.Events, ev)
+	}
+	return t, nil
+}
+
+// messageType returns the name of the type of msg, looking through authentication and push
+// envelopes.
+func messageType(msg kad.Message) string {
+	msg = unwrapMessage(msg)
+	if msg == nil {
+		return "nil"
+	}
+	return reflect.TypeOf(msg).String()
+}
+
+// summarize returns a short description of the content of msg.
+func summarize[K kad.Key[K], A kad.Address[A]](msg kad.Message) string {
+	if am, ok := msg.(*Authenticated); ok {
+		msg = am.Message
+	}
+	if pm, ok := msg.(*pushedMessage); ok {
+		return "push " + summarize[K, A](pm.Message)
+	}
+	switch msg := msg.(type) {
+	case *Message[K, A]:
+		// sim messages are both requests and responses, responses are told apart by their closer nodes
+		if msg.closerPeers != nil {
+			return fmt.Sprintf("closer=%d", len(msg.closerPeers))
+		}
+		return "target=" + key.FormatHex(msg.target)
+	case kad.Response[K, A]:
+		return fmt.Sprintf("closer=%d", len(msg.CloserNodes()))
+	case interface{ Target() K }:
+		return "target=" + key.FormatHex(msg.Target())
+	default:
+		return ""
+	}
+}
+
+// SetTrace makes the router record the messages it carries in t, a nil trace ending the
+// recording.
+func (r *Router[K, A]) SetTrace(t *Trace[K]) {
+	r.trace = t
+}
+
+// record adds an event to the trace of the router, if any.
+func (r *Router[K, A]) record(kind TraceEventKind, at time.Time, from kad.NodeID[K], to string, protoID address.ProtocolID,
+	sid endpoint.StreamID, msg kad.Message,
+) {
+	if r.trace == nil {
+		return
+	}
+	r.trace.add(TraceEvent[K]{
+		Kind:     kind,
+		Time:     at,
+		From:     from.String(),
+		To:       to,
+		Protocol: protoID,
+		Stream:   sid,
+		Type:     messageType(msg),
+		Summary:  summarize[K, A](msg),
+		FromID:   from,
+		Message:  msg,
+	})
+}
+
+// Replay switches the router to replay mode and re-delivers the messages delivered in t, at the
+// virtual times they were delivered. The deliveries all run on sched, in the order of the trace,
+// so that the nodes handle the messages in the recorded interleaving whatever the order in which
+// the simulator runs their schedulers. In replay mode, the messages the nodes send are recorded
+// if the router has a trace, but not delivered, their delivery being driven by t.
+//
+// The recipients must be in the router, and t must have been recorded in memory, since traces
+// read with ReadTrace lack the messages.
+func (r *Router[K, A]) Replay(ctx context.Context, sched event.Scheduler, t *Trace[K]) error {
+	evs := t.Delivered()
+	for _, ev := range evs {
+		if ev.Message == nil || ev.FromID == nil {
+			return fmt.Errorf("%w: event %d has no message", ErrIncompleteTrace, ev.Seq)
+		}
+		if _, _, ok := r.peerOf(ev.To); !ok {
+			return fmt.Errorf("%w: %s", endpoint.ErrUnknownPeer, ev.To)
+		}
+	}
+	r.replaying = true
+	r.replayFrom(ctx, sched, evs)
+	return nil
+}
+
+// replayFrom plans the delivery of the first of evs, which plans the delivery of the next once
+// done, so that deliveries due at the same time keep the order of the trace.
+func (r *Router[K, A]) replayFrom(ctx context.Context, sched event.Scheduler, evs []TraceEvent[K]) {
+	if len(evs) == 0 {
+		return
+	}
+	ev := evs[0]
+	sched.ScheduleAction(ctx, ev.Time, event.BasicAction(func(ctx context.Context) {
+		if peer, _, ok := r.peerOf(ev.To); ok {
+			r.record(TraceDelivered, sched.Clock().Now(), ev.FromID, ev.To, ev.Protocol, ev.Stream, ev.Message)
+			peer.HandleMessage(ctx, ev.FromID, ev.Protocol, ev.Stream, ev.Message)
+		}
+		r.replayFrom(ctx, sched, evs[1:])
+	}))
+}
+
+// EndReplay ends the replay mode of the router, which delivers the messages sent again.
+func (r *Router[K, A]) EndReplay() {
+	r.replaying = false
+}
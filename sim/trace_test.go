@@ -0,0 +1,98 @@
+package sim
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+func TestTraceReplay(t *testing.T) {
+	ctx := context.Background()
+
+	// run sets up a network of three nodes, in which the first sends a request to the others,
+	// and returns it with the responses received
+	run := func(setup func(n *Network[key.Key8, net.IP])) (*Network[key.Key8, net.IP], *[]string) {
+		clk := clock.NewMock()
+		infos := make([]kad.NodeInfo[key.Key8, net.IP], 3)
+		for i := range infos {
+			infos[i] = kadtest.NewInfo[key.Key8, net.IP](kadtest.NewID(key.Key8(i)), nil)
+		}
+		cfg := DefaultNetworkConfig[key.Key8]()
+		cfg.Topology = StarTopology()
+		n, err := NewNetwork(ctx, clk, infos, cfg)
+		require.NoError(t, err)
+		n.Router.SetLatencyModel(ConstantLatency[key.Key8](10 * time.Millisecond))
+		setup(n)
+
+		responses := new([]string)
+		for _, to := range infos[1:] {
+			to := to
+			err := n.Nodes[0].Endpoint.SendRequestHandleResponse(ctx, cfg.ProtocolID, to.ID(), NewRequest[key.Key8, net.IP](key.Key8(0x80)),
+				&Message[key.Key8, net.IP]{}, time.Second, func(ctx context.Context, resp kad.Response[key.Key8, net.IP], err error) {
+					require.NoError(t, err)
+					*responses = append(*responses, to.ID().String())
+				})
+			require.NoError(t, err)
+		}
+		return n, responses
+	}
+
+	trace := NewTrace[key.Key8]()
+	n, responses := run(func(n *Network[key.Key8, net.IP]) { n.Router.SetTrace(trace) })
+	n.Simulator.Run(ctx)
+	require.Len(t, *responses, 2)
+
+	// each request and response is sent and delivered
+	require.Len(t, trace.Events, 8)
+	require.Len(t, trace.Delivered(), 4)
+	first := trace.Events[0]
+	require.Equal(t, 1, first.Seq)
+	require.Equal(t, TraceSent, first.Kind)
+	require.Equal(t, n.Nodes[0].Info.ID().String(), first.From)
+	require.Equal(t, n.Nodes[1].Info.ID().String(), first.To)
+	require.Equal(t, "*sim.Message[github.com/plprobelab/go-kademlia/key.Key8,net.IP]", first.Type)
+	require.Equal(t, "target=0x80", first.Summary)
+	require.Equal(t, "closer=0", trace.Delivered()[2].Summary)
+
+	// the trace survives a round trip through JSON, without its messages
+	var buf bytes.Buffer
+	require.NoError(t, trace.WriteJSON(&buf))
+	decoded, err := ReadTrace[key.Key8](&buf)
+	require.NoError(t, err)
+	require.Len(t, decoded.Events, len(trace.Events))
+	require.Equal(t, first.Summary, decoded.Events[0].Summary)
+	require.True(t, first.Time.Equal(decoded.Events[0].Time))
+
+	// replaying the trace delivers the same messages at the same times, in the same order
+	replayed := NewTrace[key.Key8]()
+	var replaySched *event.SimpleScheduler
+	n, responses = run(func(n *Network[key.Key8, net.IP]) {
+		replaySched = event.NewSimpleScheduler(n.Clock)
+		require.ErrorIs(t, n.Router.Replay(ctx, replaySched, decoded), ErrIncompleteTrace)
+		require.NoError(t, n.Router.Replay(ctx, replaySched, trace))
+		n.Router.SetTrace(replayed)
+	})
+	n.Simulator.Add(replaySched)
+	n.Simulator.Run(ctx)
+	require.Len(t, *responses, 2)
+
+	want, got := trace.Delivered(), replayed.Delivered()
+	require.Len(t, got, len(want))
+	for i := range want {
+		require.Equal(t, want[i].From, got[i].From)
+		require.Equal(t, want[i].To, got[i].To)
+		require.Equal(t, want[i].Stream, got[i].Stream)
+		require.Equal(t, want[i].Summary, got[i].Summary)
+		require.Equal(t, want[i].Time, got[i].Time)
+	}
+}
@@ -0,0 +1,111 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Graph is the routing graph of a simulated Network, in which each node points to the nodes in its
+// routing table, together with the paths of the queries run in the network. It can be exported to
+// DOT, for Graphviz, or to JSON, to visualize the results of a simulation.
+type Graph struct {
+	Nodes   []string    `json:"nodes"`
+	Edges   []GraphEdge `json:"edges"`
+	Queries []QueryPath `json:"queries"`
+}
+
+// GraphEdge is an edge of a Graph, from a node to a node in its routing table.
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// QueryPath is the path of the nodes a query contacted, see QueryRecorder.Path.
+type QueryPath struct {
+	Name  string     `json:"name"`
+	Steps []PathStep `json:"steps"`
+}
+
+// Graph returns the current routing graph of the network, with the paths of the queries tracked
+// by c, which may be nil.
+func (n *Network[K, A]) Graph(c *Collector[K, A]) *Graph {
+	g := &Graph{
+		Nodes: make([]string, len(n.Nodes)),
+	}
+	for i, node := range n.Nodes {
+		from := node.Info.ID().String()
+		g.Nodes[i] = from
+		var known []string
+		for _, id := range node.routingTableNodes() {
+			known = append(known, id.String())
+		}
+		sort.Strings(known)
+		for _, to := range known {
+			g.Edges = append(g.Edges, GraphEdge{From: from, To: to})
+		}
+	}
+	if c != nil {
+		g.Queries = c.Paths()
+	}
+	return g
+}
+
+// WriteJSON writes the graph to w in JSON.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+// WriteDOT writes the graph to w in the DOT language. The routing graph is drawn in grey, and the
+// path of each query in a color of its own, each edge going from the node that returned a node
+// to the query to that node, labelled with the order in which the query handled the responses and
+// the time since the start of the query.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	ew := &errWriter{w: w}
+	ew.printf("digraph network {\n")
+	ew.printf("  node [shape=circle];\n")
+	for _, n := range g.Nodes {
+		ew.printf("  %q;\n", n)
+	}
+	for _, e := range g.Edges {
+		ew.printf("  %q -> %q [color=grey];\n", e.From, e.To)
+	}
+	for i, q := range g.Queries {
+		color := dotColors[i%len(dotColors)]
+		if len(q.Steps) == 0 {
+			continue
+		}
+		start := q.Steps[0].Time
+		// the nodes a query starts with are contacted by the query itself
+		origin := "query " + q.Name
+		ew.printf("  %q [shape=box, color=%s];\n", origin, color)
+		for j, s := range q.Steps {
+			from := s.Referrer
+			if from == "" {
+				from = origin
+			}
+			ew.printf("  %q -> %q [color=%s, label=\"%d @%s\"];\n", from, s.Node, color, j+1, s.Time.Sub(start))
+		}
+	}
+	ew.printf("}\n")
+	return ew.err
+}
+
+// dotColors are the colors of the query paths in DOT exports.
+var dotColors = []string{"red", "blue", "darkgreen", "orange", "purple", "brown", "magenta", "cyan"}
+
+// errWriter writes formatted text to w until a write fails, keeping the first error.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...any) {
+	if ew.err != nil {
+		return
+	}
+	_, ew.err = fmt.Fprintf(ew.w, format, args...)
+}
@@ -0,0 +1,103 @@
+package sim
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	sq "github.com/plprobelab/go-kademlia/query/simplequery"
+)
+
+func TestGraph(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+
+	infos := make([]kad.NodeInfo[key.Key8, net.IP], 4)
+	for i := range infos {
+		infos[i] = kadtest.NewInfo[key.Key8, net.IP](kadtest.NewID(key.Key8(i)), nil)
+	}
+	cfg := DefaultNetworkConfig[key.Key8]()
+	// the nodes form a chain, each knowing the previous and the next
+	cfg.Topology = func(_ *rand.Rand, n int) [][]int {
+		adj := make([][]int, n)
+		for i := 1; i < n; i++ {
+			connect(adj, i-1, i)
+		}
+		return adj
+	}
+	n, err := NewNetwork(ctx, clk, infos, cfg)
+	require.NoError(t, err)
+	n.Router.SetLatencyModel(ConstantLatency[key.Key8](10 * time.Millisecond))
+	c := NewCollector[key.Key8, net.IP](clk)
+
+	// the first node looks for the last one along the chain
+	rec := c.TrackQuery("last")
+	handle := func(ctx context.Context, id kad.NodeID[key.Key8], resp kad.Response[key.Key8, net.IP]) (bool, []kad.NodeID[key.Key8]) {
+		ids := make([]kad.NodeID[key.Key8], 0, len(resp.CloserNodes()))
+		for _, p := range resp.CloserNodes() {
+			if key.Equal(p.ID().Key(), key.Key8(3)) {
+				return true, nil
+			}
+			ids = append(ids, p.ID())
+		}
+		return false, ids
+	}
+	node := n.Nodes[0]
+	start := clk.Now()
+	_, err = sq.NewSimpleQuery[key.Key8, net.IP](ctx, node.Info.ID(), NewRequest[key.Key8, net.IP](key.Key8(3)),
+		sq.WithProtocolID[key.Key8, net.IP](cfg.ProtocolID),
+		sq.WithConcurrency[key.Key8, net.IP](1),
+		sq.WithHandleResultsFunc(sq.HandleResultFn[key.Key8, net.IP](rec.HandleResults(handle))),
+		sq.WithRoutingTable[key.Key8, net.IP](node.RoutingTable),
+		sq.WithEndpoint[key.Key8, net.IP](node.Endpoint),
+		sq.WithScheduler[key.Key8, net.IP](node.Scheduler))
+	require.NoError(t, err)
+	n.Simulator.Run(ctx)
+
+	require.Equal(t, []PathStep{
+		{Node: "1", Hop: 1, Time: start.Add(20 * time.Millisecond)},
+		{Node: "2", Referrer: "1", Hop: 2, Time: start.Add(40 * time.Millisecond)},
+	}, rec.Path())
+
+	g := n.Graph(c)
+	require.Equal(t, []string{"0", "1", "2", "3"}, g.Nodes)
+	// the first node learnt of the third during the query
+	require.Equal(t, []GraphEdge{
+		{From: "0", To: "1"}, {From: "0", To: "2"},
+		{From: "1", To: "0"}, {From: "1", To: "2"},
+		{From: "2", To: "1"}, {From: "2", To: "3"},
+		{From: "3", To: "2"},
+	}, g.Edges)
+	require.Equal(t, []QueryPath{{Name: "last", Steps: rec.Path()}}, g.Queries)
+
+	var buf bytes.Buffer
+	require.NoError(t, g.WriteDOT(&buf))
+	dot := buf.String()
+	require.True(t, strings.HasPrefix(dot, "digraph network {\n"))
+	require.Contains(t, dot, `"0" -> "1" [color=grey];`)
+	require.Contains(t, dot, `"query last" -> "1" [color=red, label="1 @0s"];`)
+	require.Contains(t, dot, `"1" -> "2" [color=red, label="2 @20ms"];`)
+
+	buf.Reset()
+	require.NoError(t, g.WriteJSON(&buf))
+	var decoded Graph
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	require.Equal(t, g.Nodes, decoded.Nodes)
+	require.Equal(t, g.Edges, decoded.Edges)
+	require.Len(t, decoded.Queries, 1)
+	require.Len(t, decoded.Queries[0].Steps, 2)
+
+	// the routing graph can be exported without queries
+	require.Empty(t, n.Graph(nil).Queries)
+}
@@ -0,0 +1,78 @@
+package udp
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/key"
+)
+
+// Addr is the UDP address of a node.
+type Addr netip.AddrPort
+
+var _ kad.Address[Addr] = Addr{}
+
+// ParseAddr parses s as an ip:port address.
+func ParseAddr(s string) (Addr, error) {
+	ap, err := netip.ParseAddrPort(s)
+	if err != nil {
+		return Addr{}, err
+	}
+	return Addr(ap), nil
+}
+
+// addrFromNet converts a net.Addr returned by a packet connection to an Addr.
+func addrFromNet(a net.Addr) (Addr, error) {
+	if ua, ok := a.(*net.UDPAddr); ok {
+		ap := ua.AddrPort()
+		return Addr(netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port())), nil
+	}
+	return ParseAddr(a.String())
+}
+
+func (a Addr) Equal(other Addr) bool {
+	return a == other
+}
+
+func (a Addr) String() string {
+	return netip.AddrPort(a).String()
+}
+
+// UDPAddr returns the address as a *net.UDPAddr
+func (a Addr) UDPAddr() *net.UDPAddr {
+	return net.UDPAddrFromAddrPort(netip.AddrPort(a))
+}
+
+// AddrInfo associates a node identifier with the UDP addresses it can be reached at.
+type AddrInfo[K kad.Key[K]] struct {
+	id    kad.NodeID[K]
+	addrs []Addr
+}
+
+var _ kad.NodeInfo[key.Key256, Addr] = (*AddrInfo[key.Key256])(nil)
+
+func NewAddrInfo[K kad.Key[K]](id kad.NodeID[K], addrs ...Addr) *AddrInfo[K] {
+	return &AddrInfo[K]{
+		id:    id,
+		addrs: addrs,
+	}
+}
+
+func (ai *AddrInfo[K]) ID() kad.NodeID[K] {
+	return ai.id
+}
+
+func (ai *AddrInfo[K]) Addresses() []Addr {
+	addrs := make([]Addr, len(ai.addrs))
+	copy(addrs, ai.addrs)
+	return addrs
+}
+
+func (ai *AddrInfo[K]) Key() K {
+	return ai.id.Key()
+}
+
+func (ai *AddrInfo[K]) String() string {
+	return ai.id.String()
+}
@@ -0,0 +1,97 @@
+package udp
+
+import (
+	"encoding/binary"
+
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/network/address"
+)
+
+// IDCodec encodes and decodes the node identifiers sent with every request, so that the
+// receiving node knows which node sent the request.
+type IDCodec[K kad.Key[K]] interface {
+	// MarshalID returns the encoding of the node identifier.
+	MarshalID(kad.NodeID[K]) ([]byte, error)
+
+	// UnmarshalID decodes a node identifier.
+	UnmarshalID([]byte) (kad.NodeID[K], error)
+}
+
+type packetType uint8
+
+const (
+	packetRequest packetType = iota + 1
+	packetResponse
+	packetError
+	packetThrottled
+)
+
+// packet is the unit exchanged over UDP. It is encoded as a type byte followed by the
+// uvarint request id and the length prefixed protocol id, sender id and payload.
+type packet struct {
+	typ     packetType
+	id      uint64
+	proto   address.ProtocolID
+	sender  []byte
+	payload []byte
+}
+
+func (p *packet) marshal() []byte {
+	size := 1 + 4*binary.MaxVarintLen64 + len(p.proto) + len(p.sender) + len(p.payload)
+	b := make([]byte, 0, size)
+	b = append(b, byte(p.typ))
+	b = binary.AppendUvarint(b, p.id)
+	b = appendField(b, []byte(p.proto))
+	b = appendField(b, p.sender)
+	b = appendField(b, p.payload)
+	return b
+}
+
+func appendField(b []byte, f []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(f)))
+	return append(b, f...)
+}
+
+func unmarshalPacket(b []byte) (*packet, error) {
+	if len(b) < 1 {
+		return nil, ErrInvalidPacket
+	}
+	p := &packet{typ: packetType(b[0])}
+	if p.typ < packetRequest || p.typ > packetThrottled {
+		return nil, ErrInvalidPacket
+	}
+	b = b[1:]
+
+	id, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, ErrInvalidPacket
+	}
+	p.id = id
+	b = b[n:]
+
+	var proto []byte
+	var err error
+	if proto, b, err = readField(b); err != nil {
+		return nil, err
+	}
+	p.proto = address.ProtocolID(proto)
+	if p.sender, b, err = readField(b); err != nil {
+		return nil, err
+	}
+	if p.payload, b, err = readField(b); err != nil {
+		return nil, err
+	}
+	if len(b) != 0 {
+		return nil, ErrInvalidPacket
+	}
+	return p, nil
+}
+
+func readField(b []byte) ([]byte, []byte, error) {
+	l, n := binary.Uvarint(b)
+	if n <= 0 || l > uint64(len(b)-n) {
+		return nil, nil, ErrInvalidPacket
+	}
+	b = b[n:]
+	return b[:l], b[l:], nil
+}
@@ -0,0 +1,49 @@
+package udp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPacketRoundTrip(t *testing.T) {
+	p := &packet{
+		typ:     packetRequest,
+		id:      1 << 40,
+		proto:   "/test/1.0.0",
+		sender:  []byte("sender"),
+		payload: []byte("payload"),
+	}
+	got, err := unmarshalPacket(p.marshal())
+	require.NoError(t, err)
+	require.Equal(t, p, got)
+
+	// empty fields
+	p = &packet{typ: packetResponse, id: 7, sender: []byte{}, payload: []byte{}}
+	got, err = unmarshalPacket(p.marshal())
+	require.NoError(t, err)
+	require.Equal(t, p, got)
+}
+
+func TestUnmarshalInvalidPacket(t *testing.T) {
+	valid := (&packet{typ: packetRequest, id: 1, proto: "p", payload: []byte("x")}).marshal()
+
+	_, err := unmarshalPacket(nil)
+	require.ErrorIs(t, err, ErrInvalidPacket)
+
+	// unknown packet type
+	b := append([]byte{}, valid...)
+	b[0] = 0
+	_, err = unmarshalPacket(b)
+	require.ErrorIs(t, err, ErrInvalidPacket)
+
+	// truncated
+	for i := 1; i < len(valid); i++ {
+		_, err = unmarshalPacket(valid[:i])
+		require.ErrorIs(t, err, ErrInvalidPacket)
+	}
+
+	// trailing bytes
+	_, err = unmarshalPacket(append(valid, 0))
+	require.ErrorIs(t, err, ErrInvalidPacket)
+}
@@ -0,0 +1,713 @@
+package udp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kaderr"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/codec"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+	"github.com/plprobelab/go-kademlia/network/peerstore"
+	"github.com/plprobelab/go-kademlia/util"
+)
+
+// maxUDPPayload is the largest payload that fits in a single UDP datagram.
+const maxUDPPayload = 65507
+
+// Config specifies optional configuration for an Endpoint
+type Config struct {
+	Codec              codec.Codec            // the codec used to encode and decode messages of protocols that have not been assigned one with SetCodec
+	MaxMessageSize     int                    // the maximum size in bytes of a packet sent or received
+	RetransmitInterval time.Duration          // the delay after which a request that has not been answered is sent again
+	MaxRetransmits     int                    // the maximum number of times a request is sent again, zero disables retransmission
+	ReplyCacheTTL      time.Duration          // the duration for which responses are kept to answer retransmitted requests without handling them again
+	PeerstoreTTL       time.Duration          // the duration for which the address of a node that sent a request is kept in the peerstore
+	RateLimiter        *endpoint.RateLimiter  // an optional limiter of inbound requests, requests it rejects are answered with endpoint.ErrThrottled
+	Limits             endpoint.MessageLimits // the limits on the size of encoded payloads and the number of closer nodes in responses
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *Config) Validate() error {
+	if cfg.Codec == nil {
+		return &kaderr.ConfigurationError{
+			Component: "UDPConfig",
+			Err:       fmt.Errorf("codec must not be nil"),
+		}
+	}
+	if cfg.MaxMessageSize < 1 || cfg.MaxMessageSize > maxUDPPayload {
+		return &kaderr.ConfigurationError{
+			Component: "UDPConfig",
+			Err:       fmt.Errorf("max message size must be between 1 and %d", maxUDPPayload),
+		}
+	}
+	if cfg.RetransmitInterval < 1 {
+		return &kaderr.ConfigurationError{
+			Component: "UDPConfig",
+			Err:       fmt.Errorf("retransmit interval must be greater than zero"),
+		}
+	}
+	if cfg.MaxRetransmits < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "UDPConfig",
+			Err:       fmt.Errorf("max retransmits must not be negative"),
+		}
+	}
+	if cfg.ReplyCacheTTL < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "UDPConfig",
+			Err:       fmt.Errorf("reply cache ttl must not be negative"),
+		}
+	}
+	if cfg.PeerstoreTTL < 0 {
+		return &kaderr.ConfigurationError{
+			Component: "UDPConfig",
+			Err:       fmt.Errorf("peerstore ttl must not be negative"),
+		}
+	}
+	if err := cfg.Limits.Validate(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DefaultConfig returns the default configuration options for an Endpoint.
+// Options may be overridden before passing to NewEndpoint
+func DefaultConfig() *Config {
+	return &Config{
+		Codec:              codec.JSON{},
+		MaxMessageSize:     maxUDPPayload,
+		RetransmitInterval: 500 * time.Millisecond,
+		MaxRetransmits:     3,
+		ReplyCacheTTL:      30 * time.Second,
+		PeerstoreTTL:       30 * time.Minute,
+		Limits:             endpoint.DefaultMessageLimits(maxUDPPayload),
+	}
+}
+
+// Endpoint is an endpoint exchanging Kademlia messages with other nodes over plain UDP.
+//
+// Every request carries an identifier that is echoed in its response. Requests that have
+// not been answered are retransmitted, and responses are cached for a while so that a
+// retransmitted request is answered again without running the request handler twice.
+// Requests and responses are handled on the scheduler, packets are read on a separate go routine.
+type Endpoint[K kad.Key[K]] struct {
+	ctx    context.Context
+	self   kad.NodeID[K]
+	selfID []byte
+	conn   net.PacketConn
+	sched  event.Scheduler
+	clk    clock.Clock
+	ids    IDCodec[K]
+	cfg    Config
+	codecs *codec.Registry
+
+	// counters counts the messages rejected by the configured limits
+	counters endpoint.MessageCounters
+	// metrics records the latency and reliability of the nodes requests are sent to
+	metrics *endpoint.PeerMetricsRecorder
+
+	mu        sync.Mutex // guards all fields below
+	nextID    uint64
+	peerstore peerstore.Peerstore[K, Addr]
+	sigs      *peerstore.SignaturePolicy[K, Addr]
+	handlers  map[address.ProtocolID]*requestHandler[K]
+	pending   map[uint64]*pendingRequest[K]
+	replies   map[replyKey]*reply
+	lastPrune time.Time
+	closed    bool
+
+	done chan struct{}
+}
+
+var (
+	_ endpoint.ServerEndpoint[key.Key256, Addr]  = (*Endpoint[key.Key256])(nil)
+	_ endpoint.MetricsEndpoint[key.Key256, Addr] = (*Endpoint[key.Key256])(nil)
+)
+
+type requestHandler[K kad.Key[K]] struct {
+	proto kad.Message
+	fn    endpoint.RequestHandlerFn[K]
+}
+
+type pendingRequest[K kad.Key[K]] struct {
+	addr       Addr
+	codec      codec.Codec
+	data       []byte
+	resp       kad.Message
+	handler    endpoint.ResponseHandlerFn[K, Addr]
+	retries    int
+	retransmit *clock.Timer
+	timeout    event.PlannedAction
+}
+
+type replyKey struct {
+	addr Addr
+	id   uint64
+}
+
+// reply is a cached response to a request. data is nil while the request is being handled.
+type reply struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewEndpoint creates an Endpoint sending and receiving packets on conn and starts reading
+// from conn. ids encodes the identifier of the local node, self, so that remote nodes know
+// which node sent a request. If cfg is nil, the default config is used.
+func NewEndpoint[K kad.Key[K]](ctx context.Context, self kad.NodeID[K], conn net.PacketConn,
+	sched event.Scheduler, ids IDCodec[K], cfg *Config,
+) (*Endpoint[K], error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	selfID, err := ids.MarshalID(self)
+	if err != nil {
+		return nil, fmt.Errorf("marshal self id: %w", err)
+	}
+
+	psCfg := peerstore.DefaultConfig()
+	psCfg.Clock = sched.Clock()
+	ps, err := peerstore.NewMemoryPeerstore[K, Addr](psCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Endpoint[K]{
+		ctx:       ctx,
+		self:      self,
+		selfID:    selfID,
+		conn:      conn,
+		sched:     sched,
+		clk:       sched.Clock(),
+		ids:       ids,
+		cfg:       *cfg,
+		codecs:    codec.NewRegistry(cfg.Codec),
+		metrics:   endpoint.NewPeerMetricsRecorder(sched.Clock()),
+		peerstore: ps,
+		handlers:  make(map[address.ProtocolID]*requestHandler[K]),
+		pending:   make(map[uint64]*pendingRequest[K]),
+		replies:   make(map[replyKey]*reply),
+		done:      make(chan struct{}),
+	}
+	go e.readLoop()
+	return e, nil
+}
+
+// LocalAddr returns the address the endpoint receives packets on.
+func (e *Endpoint[K]) LocalAddr() (Addr, error) {
+	return addrFromNet(e.conn.LocalAddr())
+}
+
+// Close stops the endpoint and closes the underlying connection. Requests that are still
+// waiting for a response are not answered.
+func (e *Endpoint[K]) Close() error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.closed = true
+	for id, p := range e.pending {
+		if p.retransmit != nil {
+			p.retransmit.Stop()
+		}
+		delete(e.pending, id)
+	}
+	e.mu.Unlock()
+
+	err := e.conn.Close()
+	<-e.done
+	return err
+}
+
+// MaybeAddToPeerstore adds the first address of the given node to the peerstore, where it
+// is kept for at least ttl.
+func (e *Endpoint[K]) MaybeAddToPeerstore(ctx context.Context, ni kad.NodeInfo[K, Addr], ttl time.Duration) error {
+	_, span := util.StartSpan(ctx, "udp.Endpoint.MaybeAddToPeerstore",
+		trace.WithAttributes(attribute.String("id", ni.ID().String())),
+	)
+	defer span.End()
+
+	if key.Equal(ni.ID().Key(), e.self.Key()) {
+		// don't add self to peerstore
+		return nil
+	}
+	addrs := ni.Addresses()
+	if len(addrs) == 0 {
+		span.RecordError(ErrNoAddress)
+		return ErrNoAddress
+	}
+	if p := e.signatures(); p != nil {
+		if err := p.Check(ni); err != nil {
+			span.RecordError(err)
+			return err
+		}
+	}
+
+	if err := e.peers().Add(ctx, NewAddrInfo(ni.ID(), addrs[0]), ttl); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return nil
+}
+
+// NetworkAddress returns the address of the given node if it is in the peerstore.
+func (e *Endpoint[K]) NetworkAddress(id kad.NodeID[K]) (kad.NodeInfo[K, Addr], error) {
+	if ai, err := e.peers().Get(context.Background(), id); err == nil {
+		return ai, nil
+	}
+	if ni, ok := id.(kad.NodeInfo[K, Addr]); ok {
+		return ni, nil
+	}
+	return nil, endpoint.ErrUnknownPeer
+}
+
+// Peerstore returns the address book of the endpoint.
+func (e *Endpoint[K]) Peerstore() peerstore.Peerstore[K, Addr] {
+	return e.peers()
+}
+
+// SetPeerstore replaces the address book of the endpoint, a MemoryPeerstore by default. The
+// entries of the previous peerstore are not copied.
+func (e *Endpoint[K]) SetPeerstore(ps peerstore.Peerstore[K, Addr]) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.peerstore = ps
+}
+
+func (e *Endpoint[K]) peers() peerstore.Peerstore[K, Addr] {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.peerstore
+}
+
+// SetSignaturePolicy sets the policy applied to the signatures of node infos. Signed node infos
+// passed to MaybeAddToPeerstore with an invalid signature are rejected, and responses with
+// closer nodes rejected by the policy are reported to the response handler with an error.
+// A nil policy accepts all node infos.
+func (e *Endpoint[K]) SetSignaturePolicy(p *peerstore.SignaturePolicy[K, Addr]) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sigs = p
+}
+
+func (e *Endpoint[K]) signatures() *peerstore.SignaturePolicy[K, Addr] {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.sigs
+}
+
+// MessageStats returns the numbers of messages the endpoint rejected because of its limits.
+func (e *Endpoint[K]) MessageStats() endpoint.MessageStats {
+	return e.counters.Stats()
+}
+
+// Metrics returns the latency and reliability recorded for the requests sent to n. The round
+// trip time of a request includes its retransmissions.
+func (e *Endpoint[K]) Metrics(n kad.NodeID[K]) (endpoint.PeerMetrics, bool) {
+	return e.metrics.Metrics(n.String())
+}
+
+// SetCodec sets the codec used to encode and decode the messages of a protocol.
+// A nil codec restores the default codec for the protocol.
+func (e *Endpoint[K]) SetCodec(protoID address.ProtocolID, c codec.Codec) {
+	e.codecs.Set(protoID, c)
+}
+
+// AddRequestHandler registers a handler for requests of the given protocol. Requests are
+// decoded into new messages of the same type as req.
+func (e *Endpoint[K]) AddRequestHandler(protoID address.ProtocolID, req kad.Message,
+	reqHandler endpoint.RequestHandlerFn[K],
+) error {
+	if reqHandler == nil {
+		return endpoint.ErrNilRequestHandler
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.handlers[protoID] = &requestHandler[K]{proto: req, fn: reqHandler}
+	return nil
+}
+
+// RemoveRequestHandler removes the handler for the given protocol.
+func (e *Endpoint[K]) RemoveRequestHandler(protoID address.ProtocolID) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.handlers, protoID)
+}
+
+// SendRequestHandleResponse sends a request to the given node and handles the response,
+// decoded into a new message of the same type as resp, with responseHandlerFn.
+func (e *Endpoint[K]) SendRequestHandleResponse(ctx context.Context,
+	protoID address.ProtocolID, n kad.NodeID[K], req kad.Message,
+	resp kad.Message, timeout time.Duration,
+	responseHandlerFn endpoint.ResponseHandlerFn[K, Addr],
+) error {
+	ctx, span := util.StartSpan(ctx, "udp.Endpoint.SendRequestHandleResponse",
+		trace.WithAttributes(attribute.Stringer("id", n)),
+	)
+	defer span.End()
+
+	if responseHandlerFn == nil {
+		span.RecordError(endpoint.ErrNilResponseHandler)
+		return endpoint.ErrNilResponseHandler
+	}
+
+	ni, err := e.NetworkAddress(n)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	addrs := ni.Addresses()
+	if len(addrs) == 0 {
+		span.RecordError(endpoint.ErrUnknownPeer)
+		return endpoint.ErrUnknownPeer
+	}
+
+	payload, err := e.codecs.Get(protoID).Marshal(req)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if err := endpoint.CheckSize(len(payload), e.cfg.Limits.MaxRequestSize); err != nil {
+		e.counters.CountOversizedRequest()
+		span.RecordError(err)
+		return err
+	}
+
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return ErrClosed
+	}
+	e.nextID++
+	id := e.nextID
+	e.mu.Unlock()
+
+	pkt := &packet{
+		typ:     packetRequest,
+		id:      id,
+		proto:   protoID,
+		sender:  e.selfID,
+		payload: payload,
+	}
+	data := pkt.marshal()
+	if len(data) > e.cfg.MaxMessageSize {
+		span.RecordError(ErrPacketTooLarge)
+		return ErrPacketTooLarge
+	}
+
+	p := &pendingRequest[K]{
+		addr:    addrs[0],
+		codec:   e.codecs.Get(protoID),
+		data:    data,
+		resp:    resp,
+		handler: endpoint.Track(e.metrics, n.String(), responseHandlerFn),
+	}
+
+	e.mu.Lock()
+	e.pending[id] = p
+	if e.cfg.MaxRetransmits > 0 {
+		p.retransmit = e.clk.AfterFunc(e.cfg.RetransmitInterval, func() { e.retransmit(id) })
+	}
+	if timeout != 0 {
+		p.timeout = event.ScheduleActionIn(ctx, e.sched, timeout,
+			event.BasicAction(func(ctx context.Context) {
+				if p := e.take(id); p != nil {
+					p.handler(ctx, nil, endpoint.ErrTimeout)
+				}
+			}))
+	}
+	e.mu.Unlock()
+
+	if _, err := e.conn.WriteTo(data, p.addr.UDPAddr()); err != nil {
+		span.RecordError(err)
+		if p := e.take(id); p != nil {
+			e.sched.EnqueueAction(ctx, event.BasicAction(func(ctx context.Context) {
+				p.handler(ctx, nil, err)
+			}))
+		}
+	}
+	return nil
+}
+
+// take removes the pending request with the given id, stopping its retransmission and timeout.
+// It returns nil if the request was already answered or timed out.
+func (e *Endpoint[K]) take(id uint64) *pendingRequest[K] {
+	e.mu.Lock()
+	p, ok := e.pending[id]
+	if ok {
+		delete(e.pending, id)
+		if p.retransmit != nil {
+			p.retransmit.Stop()
+		}
+	}
+	e.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	if p.timeout != nil {
+		e.sched.RemovePlannedAction(e.ctx, p.timeout)
+	}
+	return p
+}
+
+// retransmit sends a request that has not been answered again.
+func (e *Endpoint[K]) retransmit(id uint64) {
+	e.mu.Lock()
+	p, ok := e.pending[id]
+	if !ok || p.retries >= e.cfg.MaxRetransmits {
+		e.mu.Unlock()
+		return
+	}
+	p.retries++
+	if p.retries < e.cfg.MaxRetransmits {
+		p.retransmit = e.clk.AfterFunc(e.cfg.RetransmitInterval, func() { e.retransmit(id) })
+	}
+	e.mu.Unlock()
+
+	// a failed retransmission is not fatal, the request times out if no attempt succeeds
+	_, _ = e.conn.WriteTo(p.data, p.addr.UDPAddr())
+}
+
+func (e *Endpoint[K]) readLoop() {
+	defer close(e.done)
+
+	buf := make([]byte, e.cfg.MaxMessageSize)
+	for {
+		n, from, err := e.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			select {
+			case <-e.ctx.Done():
+				return
+			default:
+				continue
+			}
+		}
+
+		addr, err := addrFromNet(from)
+		if err != nil {
+			continue
+		}
+		pkt, err := unmarshalPacket(buf[:n])
+		if err != nil {
+			// ignore packets that are not part of the protocol
+			continue
+		}
+		// the packet fields reference buf, copy them before the next read
+		pkt.proto = address.ProtocolID([]byte(pkt.proto))
+		pkt.sender = append([]byte(nil), pkt.sender...)
+		pkt.payload = append([]byte(nil), pkt.payload...)
+
+		switch pkt.typ {
+		case packetRequest:
+			e.handleRequest(addr, pkt)
+		case packetResponse, packetError, packetThrottled:
+			e.handleResponse(addr, pkt)
+		}
+	}
+}
+
+func (e *Endpoint[K]) handleRequest(addr Addr, pkt *packet) {
+	rk := replyKey{addr: addr, id: pkt.id}
+
+	e.mu.Lock()
+	now := e.clk.Now()
+	e.pruneReplies(now)
+	if r, ok := e.replies[rk]; ok {
+		data := r.data
+		e.mu.Unlock()
+		if data != nil {
+			// answer a retransmitted request with the cached response
+			_, _ = e.conn.WriteTo(data, addr.UDPAddr())
+		}
+		return
+	}
+	h, ok := e.handlers[pkt.proto]
+	if ok {
+		e.replies[rk] = &reply{expires: now.Add(e.cfg.ReplyCacheTTL)}
+	}
+	e.mu.Unlock()
+
+	if !ok {
+		e.reply(rk, packetError, []byte(ErrUnknownProtocol.Error()))
+		return
+	}
+
+	requester, err := e.ids.UnmarshalID(pkt.sender)
+	if err != nil {
+		e.reply(rk, packetError, []byte(err.Error()))
+		return
+	}
+	if e.cfg.RateLimiter != nil && !e.cfg.RateLimiter.Allow(requester.String()) {
+		// the throttled reply is cached so that retransmissions don't take more tokens
+		e.reply(rk, packetThrottled, nil)
+		return
+	}
+	if e.cfg.PeerstoreTTL > 0 && !key.Equal(requester.Key(), e.self.Key()) {
+		e.peers().Add(e.ctx, NewAddrInfo(requester, addr), e.cfg.PeerstoreTTL)
+	}
+
+	if err := endpoint.CheckSize(len(pkt.payload), e.cfg.Limits.MaxRequestSize); err != nil {
+		e.counters.CountOversizedRequest()
+		e.reply(rk, packetError, []byte(err.Error()))
+		return
+	}
+	c := e.codecs.Get(pkt.proto)
+	req, err := c.Unmarshal(pkt.payload, h.proto)
+	if err != nil {
+		e.counters.CountMalformed()
+		e.reply(rk, packetError, []byte(err.Error()))
+		return
+	}
+
+	e.sched.EnqueueAction(e.ctx, event.BasicAction(func(ctx context.Context) {
+		ctx, span := util.StartSpan(ctx, "udp.Endpoint.handleRequest",
+			trace.WithAttributes(attribute.Stringer("id", requester)),
+		)
+		defer span.End()
+
+		resp, err := h.fn(ctx, requester, req)
+		if err != nil {
+			span.RecordError(err)
+			e.reply(rk, packetError, []byte(err.Error()))
+			return
+		}
+		payload, err := c.Marshal(resp)
+		if err != nil {
+			span.RecordError(err)
+			e.reply(rk, packetError, []byte(err.Error()))
+			return
+		}
+		if err := endpoint.CheckSize(len(payload), e.cfg.Limits.MaxResponseSize); err != nil {
+			e.counters.CountOversizedResponse()
+			span.RecordError(err)
+			e.reply(rk, packetError, []byte(err.Error()))
+			return
+		}
+		e.reply(rk, packetResponse, payload)
+	}))
+}
+
+// reply sends a response or error packet for the request identified by rk and caches it
+// to answer retransmissions of the request.
+func (e *Endpoint[K]) reply(rk replyKey, typ packetType, payload []byte) {
+	data := (&packet{typ: typ, id: rk.id, payload: payload}).marshal()
+	if len(data) > e.cfg.MaxMessageSize {
+		data = (&packet{typ: packetError, id: rk.id, payload: []byte(ErrPacketTooLarge.Error())}).marshal()
+	}
+
+	e.mu.Lock()
+	if r, ok := e.replies[rk]; ok {
+		r.data = data
+	}
+	e.mu.Unlock()
+
+	_, _ = e.conn.WriteTo(data, rk.addr.UDPAddr())
+}
+
+// pruneReplies removes expired responses from the cache, at most once per ReplyCacheTTL.
+// e.mu must be held.
+func (e *Endpoint[K]) pruneReplies(now time.Time) {
+	if now.Sub(e.lastPrune) < e.cfg.ReplyCacheTTL {
+		return
+	}
+	e.lastPrune = now
+	for rk, r := range e.replies {
+		if !now.Before(r.expires) {
+			delete(e.replies, rk)
+		}
+	}
+}
+
+func (e *Endpoint[K]) handleResponse(addr Addr, pkt *packet) {
+	e.mu.Lock()
+	p, ok := e.pending[pkt.id]
+	e.mu.Unlock()
+	if !ok || !p.addr.Equal(addr) {
+		// late, duplicate or spoofed response
+		return
+	}
+	if p = e.take(pkt.id); p == nil {
+		return
+	}
+
+	if pkt.typ == packetThrottled {
+		e.sched.EnqueueAction(e.ctx, event.BasicAction(func(ctx context.Context) {
+			p.handler(ctx, nil, endpoint.ErrThrottled)
+		}))
+		return
+	}
+	if pkt.typ == packetError {
+		err := fmt.Errorf("%w: %s", ErrRemote, pkt.payload)
+		if string(pkt.payload) == ErrUnknownProtocol.Error() {
+			// lets callers fall back to another protocol
+			err = fmt.Errorf("%w: %w", err, endpoint.ErrProtocolNotSupported)
+		}
+		e.sched.EnqueueAction(e.ctx, event.BasicAction(func(ctx context.Context) {
+			p.handler(ctx, nil, err)
+		}))
+		return
+	}
+
+	if err := endpoint.CheckSize(len(pkt.payload), e.cfg.Limits.MaxResponseSize); err != nil {
+		e.counters.CountOversizedResponse()
+		e.sched.EnqueueAction(e.ctx, event.BasicAction(func(ctx context.Context) {
+			p.handler(ctx, nil, err)
+		}))
+		return
+	}
+	msg, err := p.codec.Unmarshal(pkt.payload, p.resp)
+	if err != nil {
+		e.counters.CountMalformed()
+		err = fmt.Errorf("%w: %v", endpoint.ErrMalformedMessage, err)
+		e.sched.EnqueueAction(e.ctx, event.BasicAction(func(ctx context.Context) {
+			p.handler(ctx, nil, err)
+		}))
+		return
+	}
+	resp, ok := msg.(kad.Response[K, Addr])
+	if !ok {
+		e.counters.CountMalformed()
+		e.sched.EnqueueAction(e.ctx, event.BasicAction(func(ctx context.Context) {
+			p.handler(ctx, nil, ErrRequireKadResponse)
+		}))
+		return
+	}
+	if err := endpoint.CheckCloserNodes(e.cfg.Limits, resp); err != nil {
+		e.counters.CountTooManyCloserNodes()
+		e.sched.EnqueueAction(e.ctx, event.BasicAction(func(ctx context.Context) {
+			p.handler(ctx, nil, err)
+		}))
+		return
+	}
+	if sp := e.signatures(); sp != nil {
+		if err := sp.CheckCloserNodes(resp); err != nil {
+			e.sched.EnqueueAction(e.ctx, event.BasicAction(func(ctx context.Context) {
+				p.handler(ctx, nil, err)
+			}))
+			return
+		}
+	}
+	e.sched.EnqueueAction(e.ctx, event.BasicAction(func(ctx context.Context) {
+		p.handler(ctx, resp, nil)
+	}))
+}
@@ -0,0 +1,547 @@
+package udp
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/plprobelab/go-kademlia/event"
+	"github.com/plprobelab/go-kademlia/kad"
+	"github.com/plprobelab/go-kademlia/kadtest"
+	"github.com/plprobelab/go-kademlia/key"
+	"github.com/plprobelab/go-kademlia/network/address"
+	"github.com/plprobelab/go-kademlia/network/codec"
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+	"github.com/plprobelab/go-kademlia/network/peerstore"
+)
+
+var protoID = address.ProtocolID("/test/1.0.0")
+
+type testRequest struct {
+	Text string
+}
+
+type testResponse struct {
+	Text string
+}
+
+func (r *testResponse) CloserNodes() []kad.NodeInfo[key.Key256, Addr] {
+	return nil
+}
+
+type stringIDCodec struct{}
+
+func (stringIDCodec) MarshalID(id kad.NodeID[key.Key256]) ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+func (stringIDCodec) UnmarshalID(b []byte) (kad.NodeID[key.Key256], error) {
+	return kadtest.NewStringID(string(b)), nil
+}
+
+// lossyConn drops the given number of packets written before passing writes through.
+// If dup is set, every packet that is not dropped is written twice.
+type lossyConn struct {
+	net.PacketConn
+	drop atomic.Int32
+	dup  bool
+}
+
+func (c *lossyConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if c.drop.Add(-1) >= 0 {
+		return len(b), nil
+	}
+	if c.dup {
+		if _, err := c.PacketConn.WriteTo(b, addr); err != nil {
+			return 0, err
+		}
+	}
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+func listen(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	return conn
+}
+
+func newTestEndpoint(t *testing.T, name string, conn net.PacketConn, cfg *Config) (*Endpoint[key.Key256], *event.SimpleScheduler) {
+	ctx := context.Background()
+	sched := event.NewSimpleScheduler(clock.New())
+	e, err := NewEndpoint[key.Key256](ctx, kadtest.NewStringID(name), conn, sched, stringIDCodec{}, cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { e.Close() })
+	return e, sched
+}
+
+// connect adds the address of b to the peerstore of a.
+func connect(t *testing.T, a, b *Endpoint[key.Key256]) {
+	addr, err := b.LocalAddr()
+	require.NoError(t, err)
+	err = a.MaybeAddToPeerstore(context.Background(), NewAddrInfo(b.self, addr), time.Hour)
+	require.NoError(t, err)
+}
+
+// runUntil runs actions on the scheduler until done is closed.
+func runUntil(ctx context.Context, sched event.Scheduler, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if !sched.RunOne(ctx) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func echoHandler(calls *atomic.Int32) endpoint.RequestHandlerFn[key.Key256] {
+	return func(ctx context.Context, id kad.NodeID[key.Key256], req kad.Message) (kad.Message, error) {
+		if calls != nil {
+			calls.Add(1)
+		}
+		return &testResponse{Text: req.(*testRequest).Text}, nil
+	}
+}
+
+type result struct {
+	resp kad.Response[key.Key256, Addr]
+	err  error
+}
+
+// request sends a request from client to server while running both schedulers and returns the result.
+func request(t *testing.T, client *Endpoint[key.Key256], server *Endpoint[key.Key256],
+	csched, ssched event.Scheduler, text string, timeout time.Duration,
+) result {
+	ctx := context.Background()
+	done := make(chan struct{})
+	var res result
+	err := client.SendRequestHandleResponse(ctx, protoID, server.self, &testRequest{Text: text},
+		&testResponse{}, timeout, func(ctx context.Context, resp kad.Response[key.Key256, Addr], err error) {
+			res = result{resp: resp, err: err}
+			close(done)
+		})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runUntil(ctx, ssched, done)
+	}()
+	runUntil(ctx, csched, done)
+	wg.Wait()
+	return res
+}
+
+func TestConfigValidate(t *testing.T) {
+	t.Run("default is valid", func(t *testing.T) {
+		cfg := DefaultConfig()
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("codec is not nil", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Codec = nil
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("max message size in range", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.MaxMessageSize = 0
+		require.Error(t, cfg.Validate())
+		cfg.MaxMessageSize = maxUDPPayload + 1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("retransmit interval positive", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.RetransmitInterval = 0
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("max retransmits not negative", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.MaxRetransmits = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("reply cache ttl not negative", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.ReplyCacheTTL = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("peerstore ttl not negative", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.PeerstoreTTL = -1
+		require.Error(t, cfg.Validate())
+	})
+
+	t.Run("limits valid", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Limits.MaxCloserNodes = 0
+		require.Error(t, cfg.Validate())
+	})
+}
+
+func TestRequestResponse(t *testing.T) {
+	client, csched := newTestEndpoint(t, "client", listen(t), nil)
+	server, ssched := newTestEndpoint(t, "server", listen(t), nil)
+	connect(t, client, server)
+
+	var requester kad.NodeID[key.Key256]
+	err := server.AddRequestHandler(protoID, &testRequest{}, func(ctx context.Context,
+		id kad.NodeID[key.Key256], req kad.Message,
+	) (kad.Message, error) {
+		requester = id
+		return &testResponse{Text: req.(*testRequest).Text + " world"}, nil
+	})
+	require.NoError(t, err)
+
+	res := request(t, client, server, csched, ssched, "hello", time.Second)
+	require.NoError(t, res.err)
+	require.Equal(t, &testResponse{Text: "hello world"}, res.resp)
+	require.Equal(t, client.self.String(), requester.String())
+
+	// the server learnt the address of the client
+	ni, err := server.NetworkAddress(client.self)
+	require.NoError(t, err)
+	caddr, err := client.LocalAddr()
+	require.NoError(t, err)
+	require.Equal(t, []Addr{caddr}, ni.Addresses())
+
+	// nothing left to run, the timeout was removed
+	require.Equal(t, event.MaxTime, csched.NextActionTime(context.Background()))
+}
+
+func TestUnknownPeer(t *testing.T) {
+	client, _ := newTestEndpoint(t, "client", listen(t), nil)
+
+	err := client.SendRequestHandleResponse(context.Background(), protoID, kadtest.NewStringID("unknown"),
+		&testRequest{}, &testResponse{}, time.Second,
+		func(context.Context, kad.Response[key.Key256, Addr], error) {})
+	require.ErrorIs(t, err, endpoint.ErrUnknownPeer)
+
+	err = client.SendRequestHandleResponse(context.Background(), protoID, kadtest.NewStringID("unknown"),
+		&testRequest{}, &testResponse{}, time.Second, nil)
+	require.ErrorIs(t, err, endpoint.ErrNilResponseHandler)
+}
+
+func TestPeerstoreTTL(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewMock()
+	sched := event.NewSimpleScheduler(clk)
+	e, err := NewEndpoint[key.Key256](ctx, kadtest.NewStringID("self"), listen(t), sched, stringIDCodec{}, nil)
+	require.NoError(t, err)
+	defer e.Close()
+
+	id := kadtest.NewStringID("other")
+	addr, err := ParseAddr("127.0.0.1:4001")
+	require.NoError(t, err)
+
+	require.ErrorIs(t, e.MaybeAddToPeerstore(ctx, NewAddrInfo[key.Key256](id), time.Minute), ErrNoAddress)
+	require.NoError(t, e.MaybeAddToPeerstore(ctx, NewAddrInfo[key.Key256](id, addr), time.Minute))
+
+	ni, err := e.NetworkAddress(id)
+	require.NoError(t, err)
+	require.Equal(t, []Addr{addr}, ni.Addresses())
+
+	clk.Add(time.Minute)
+	_, err = e.NetworkAddress(id)
+	require.ErrorIs(t, err, endpoint.ErrUnknownPeer)
+}
+
+func TestSetPeerstore(t *testing.T) {
+	ctx := context.Background()
+	e, _ := newTestEndpoint(t, "self", listen(t), nil)
+
+	id := kadtest.NewStringID("other")
+	addr, err := ParseAddr("127.0.0.1:4001")
+	require.NoError(t, err)
+	require.NoError(t, e.MaybeAddToPeerstore(ctx, NewAddrInfo[key.Key256](id, addr), time.Minute))
+
+	ni, err := e.Peerstore().Get(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, []Addr{addr}, ni.Addresses())
+
+	// the entries of the previous peerstore are not copied
+	ps, err := peerstore.NewMemoryPeerstore[key.Key256, Addr](nil)
+	require.NoError(t, err)
+	e.SetPeerstore(ps)
+	_, err = e.NetworkAddress(id)
+	require.ErrorIs(t, err, endpoint.ErrUnknownPeer)
+
+	require.NoError(t, ps.Add(ctx, NewAddrInfo[key.Key256](id, addr), time.Minute))
+	ni, err = e.NetworkAddress(id)
+	require.NoError(t, err)
+	require.Equal(t, []Addr{addr}, ni.Addresses())
+}
+
+func TestSignaturePolicy(t *testing.T) {
+	ctx := context.Background()
+	e, _ := newTestEndpoint(t, "self", listen(t), nil)
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	e.SetSignaturePolicy(&peerstore.SignaturePolicy[key.Key256, Addr]{
+		Verifier: &peerstore.Ed25519Verifier[key.Key256, Addr]{
+			PublicKey: func(id kad.NodeID[key.Key256]) (ed25519.PublicKey, error) {
+				return pub, nil
+			},
+		},
+	})
+
+	id := kadtest.NewStringID("other")
+	addr, err := ParseAddr("127.0.0.1:4001")
+	require.NoError(t, err)
+	evil, err := ParseAddr("127.0.0.1:6666")
+	require.NoError(t, err)
+
+	signed := peerstore.SignEd25519[key.Key256, Addr](NewAddrInfo[key.Key256](id, addr), priv)
+	spoofed := peerstore.NewSignedInfo[key.Key256, Addr](NewAddrInfo[key.Key256](id, evil), signed.Signature())
+
+	require.ErrorIs(t, e.MaybeAddToPeerstore(ctx, spoofed, time.Minute), peerstore.ErrInvalidSignature)
+	_, err = e.NetworkAddress(id)
+	require.ErrorIs(t, err, endpoint.ErrUnknownPeer)
+
+	require.NoError(t, e.MaybeAddToPeerstore(ctx, signed, time.Minute))
+	ni, err := e.NetworkAddress(id)
+	require.NoError(t, err)
+	require.Equal(t, []Addr{addr}, ni.Addresses())
+}
+
+func TestHandlerError(t *testing.T) {
+	client, csched := newTestEndpoint(t, "client", listen(t), nil)
+	server, ssched := newTestEndpoint(t, "server", listen(t), nil)
+	connect(t, client, server)
+
+	err := server.AddRequestHandler(protoID, &testRequest{}, func(context.Context,
+		kad.NodeID[key.Key256], kad.Message,
+	) (kad.Message, error) {
+		return nil, errors.New("boom")
+	})
+	require.NoError(t, err)
+
+	res := request(t, client, server, csched, ssched, "hello", time.Second)
+	require.ErrorIs(t, res.err, ErrRemote)
+	require.Contains(t, res.err.Error(), "boom")
+
+	// unknown protocol
+	server.RemoveRequestHandler(protoID)
+	res = request(t, client, server, csched, ssched, "hello", time.Second)
+	require.ErrorIs(t, res.err, ErrRemote)
+	require.Contains(t, res.err.Error(), ErrUnknownProtocol.Error())
+	require.ErrorIs(t, res.err, endpoint.ErrProtocolNotSupported)
+}
+
+func TestMetrics(t *testing.T) {
+	client, csched := newTestEndpoint(t, "client", listen(t), nil)
+	server, ssched := newTestEndpoint(t, "server", listen(t), nil)
+	connect(t, client, server)
+
+	_, ok := client.Metrics(server.self)
+	require.False(t, ok)
+
+	fail := false
+	err := server.AddRequestHandler(protoID, &testRequest{}, func(ctx context.Context,
+		id kad.NodeID[key.Key256], req kad.Message,
+	) (kad.Message, error) {
+		if fail {
+			return nil, errors.New("boom")
+		}
+		return &testResponse{Text: "pong"}, nil
+	})
+	require.NoError(t, err)
+
+	res := request(t, client, server, csched, ssched, "ping", time.Second)
+	require.NoError(t, res.err)
+	fail = true
+	res = request(t, client, server, csched, ssched, "ping", time.Second)
+	require.Error(t, res.err)
+
+	m, ok := client.Metrics(server.self)
+	require.True(t, ok)
+	require.Equal(t, 1, m.Successes)
+	require.Equal(t, 1, m.Failures)
+	require.Equal(t, 0.5, m.Reliability())
+	require.False(t, m.LastSuccess.IsZero())
+}
+
+func TestTimeout(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RetransmitInterval = 10 * time.Millisecond
+
+	// all packets sent by the client are lost
+	conn := &lossyConn{PacketConn: listen(t)}
+	conn.drop.Store(1 << 30)
+	client, csched := newTestEndpoint(t, "client", conn, cfg)
+	server, ssched := newTestEndpoint(t, "server", listen(t), nil)
+	connect(t, client, server)
+	require.NoError(t, server.AddRequestHandler(protoID, &testRequest{}, echoHandler(nil)))
+
+	res := request(t, client, server, csched, ssched, "hello", 100*time.Millisecond)
+	require.ErrorIs(t, res.err, endpoint.ErrTimeout)
+}
+
+func TestRetransmit(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RetransmitInterval = 20 * time.Millisecond
+
+	conn := &lossyConn{PacketConn: listen(t)}
+	conn.drop.Store(2) // the request and its first retransmission are lost
+	client, csched := newTestEndpoint(t, "client", conn, cfg)
+	server, ssched := newTestEndpoint(t, "server", listen(t), nil)
+	connect(t, client, server)
+
+	var calls atomic.Int32
+	require.NoError(t, server.AddRequestHandler(protoID, &testRequest{}, echoHandler(&calls)))
+
+	res := request(t, client, server, csched, ssched, "hello", 5*time.Second)
+	require.NoError(t, res.err)
+	require.Equal(t, &testResponse{Text: "hello"}, res.resp)
+	require.Equal(t, int32(1), calls.Load())
+}
+
+func TestDuplicateRequestHandledOnce(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RetransmitInterval = 10 * time.Millisecond
+
+	// the server response is lost, the client retransmits and the duplicate requests
+	// are answered from the reply cache
+	sconn := &lossyConn{PacketConn: listen(t)}
+	sconn.drop.Store(1)
+	client, csched := newTestEndpoint(t, "client", &lossyConn{PacketConn: listen(t), dup: true}, cfg)
+	server, ssched := newTestEndpoint(t, "server", sconn, nil)
+	connect(t, client, server)
+
+	var calls atomic.Int32
+	require.NoError(t, server.AddRequestHandler(protoID, &testRequest{}, echoHandler(&calls)))
+
+	res := request(t, client, server, csched, ssched, "hello", 5*time.Second)
+	require.NoError(t, res.err)
+	require.Equal(t, &testResponse{Text: "hello"}, res.resp)
+	require.Equal(t, int32(1), calls.Load())
+}
+
+func TestResponseNotKadResponse(t *testing.T) {
+	client, csched := newTestEndpoint(t, "client", listen(t), nil)
+	server, ssched := newTestEndpoint(t, "server", listen(t), nil)
+	connect(t, client, server)
+	require.NoError(t, server.AddRequestHandler(protoID, &testRequest{}, echoHandler(nil)))
+
+	ctx := context.Background()
+	done := make(chan struct{})
+	var rerr error
+	err := client.SendRequestHandleResponse(ctx, protoID, server.self, &testRequest{Text: "hello"},
+		&testRequest{}, time.Second, func(ctx context.Context, resp kad.Response[key.Key256, Addr], err error) {
+			rerr = err
+			close(done)
+		})
+	require.NoError(t, err)
+	go runUntil(ctx, ssched, done)
+	runUntil(ctx, csched, done)
+	require.ErrorIs(t, rerr, ErrRequireKadResponse)
+}
+
+func TestSetCodec(t *testing.T) {
+	client, csched := newTestEndpoint(t, "client", listen(t), nil)
+	server, ssched := newTestEndpoint(t, "server", listen(t), nil)
+	connect(t, client, server)
+	require.NoError(t, server.AddRequestHandler(protoID, &testRequest{}, echoHandler(nil)))
+
+	client.SetCodec(protoID, codec.CBOR{})
+	server.SetCodec(protoID, codec.CBOR{})
+
+	res := request(t, client, server, csched, ssched, "hello", time.Second)
+	require.NoError(t, res.err)
+	require.Equal(t, &testResponse{Text: "hello"}, res.resp)
+
+	// the server cannot decode requests encoded with another codec
+	client.SetCodec(protoID, nil)
+	res = request(t, client, server, csched, ssched, "hello", time.Second)
+	require.ErrorIs(t, res.err, ErrRemote)
+}
+
+func TestRateLimit(t *testing.T) {
+	lcfg := endpoint.DefaultRateLimitConfig()
+	lcfg.PeerRate = 0.001
+	lcfg.PeerBurst = 1
+	limiter, err := endpoint.NewRateLimiter(lcfg)
+	require.NoError(t, err)
+
+	cfg := DefaultConfig()
+	cfg.RateLimiter = limiter
+
+	client, csched := newTestEndpoint(t, "client", listen(t), nil)
+	server, ssched := newTestEndpoint(t, "server", listen(t), cfg)
+	connect(t, client, server)
+
+	var calls atomic.Int32
+	require.NoError(t, server.AddRequestHandler(protoID, &testRequest{}, echoHandler(&calls)))
+
+	res := request(t, client, server, csched, ssched, "hello", time.Second)
+	require.NoError(t, res.err)
+
+	// the second request exceeds the rate limit of the client
+	res = request(t, client, server, csched, ssched, "hello", time.Second)
+	require.ErrorIs(t, res.err, endpoint.ErrThrottled)
+	require.Equal(t, int32(1), calls.Load())
+}
+
+func TestMessageLimits(t *testing.T) {
+	small := DefaultConfig()
+	small.Limits = endpoint.DefaultMessageLimits(8)
+
+	t.Run("request over client limit", func(t *testing.T) {
+		client, _ := newTestEndpoint(t, "client", listen(t), small)
+		server, _ := newTestEndpoint(t, "server", listen(t), nil)
+		connect(t, client, server)
+
+		err := client.SendRequestHandleResponse(context.Background(), protoID, server.self,
+			&testRequest{Text: "hello world"}, &testResponse{}, time.Second,
+			func(context.Context, kad.Response[key.Key256, Addr], error) {})
+		require.ErrorIs(t, err, endpoint.ErrMessageTooLarge)
+		require.Equal(t, int64(1), client.MessageStats().OversizedRequests)
+	})
+
+	t.Run("request over server limit", func(t *testing.T) {
+		client, csched := newTestEndpoint(t, "client", listen(t), nil)
+		server, ssched := newTestEndpoint(t, "server", listen(t), small)
+		connect(t, client, server)
+
+		var calls atomic.Int32
+		require.NoError(t, server.AddRequestHandler(protoID, &testRequest{}, echoHandler(&calls)))
+
+		res := request(t, client, server, csched, ssched, "hello world", time.Second)
+		require.ErrorIs(t, res.err, ErrRemote)
+		require.Contains(t, res.err.Error(), endpoint.ErrMessageTooLarge.Error())
+		require.Equal(t, int64(1), server.MessageStats().OversizedRequests)
+		require.Equal(t, int32(0), calls.Load())
+	})
+
+	t.Run("response over client limit", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Limits.MaxResponseSize = 8
+		client, csched := newTestEndpoint(t, "client", listen(t), cfg)
+		server, ssched := newTestEndpoint(t, "server", listen(t), nil)
+		connect(t, client, server)
+
+		var calls atomic.Int32
+		require.NoError(t, server.AddRequestHandler(protoID, &testRequest{}, echoHandler(&calls)))
+
+		res := request(t, client, server, csched, ssched, "hello world", time.Second)
+		require.ErrorIs(t, res.err, endpoint.ErrMessageTooLarge)
+		require.Equal(t, int64(1), client.MessageStats().OversizedResponses)
+		require.Equal(t, int32(1), calls.Load())
+	})
+}
@@ -0,0 +1,18 @@
+package udp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/plprobelab/go-kademlia/network/endpoint"
+)
+
+var (
+	ErrNoAddress          = errors.New("node info has no address")
+	ErrInvalidPacket      = errors.New("invalid packet")
+	ErrPacketTooLarge     = errors.New("packet exceeds maximum message size")
+	ErrRequireKadResponse = fmt.Errorf("%w: udp Endpoint requires kad.Response", endpoint.ErrGarbageResponse)
+	ErrRemote             = errors.New("remote peer failed to handle request")
+	ErrUnknownProtocol    = errors.New("unknown protocol")
+	ErrClosed             = errors.New("endpoint closed")
+)
@@ -0,0 +1,15 @@
+package util
+
+import (
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// Meter returns the meter of mp the instruments of the module are created on, or a meter
+// recording nothing if mp is nil.
+func Meter(mp metric.MeterProvider) metric.Meter {
+	if mp == nil {
+		mp = noop.NewMeterProvider()
+	}
+	return mp.Meter("go-kademlia")
+}
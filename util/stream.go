@@ -0,0 +1,169 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrStreamClosed is returned by Recv once the producer has closed the stream and all results
+	// have been received, and by Send after the stream was closed.
+	ErrStreamClosed = errors.New("stream closed")
+	// ErrStreamCancelled is returned by Send after the consumer cancelled the stream.
+	ErrStreamCancelled = errors.New("stream cancelled")
+)
+
+// Stream delivers results of type T from a producer, such as a query, to a consumer. It holds at
+// most a fixed number of undelivered results: once the buffer is full Send blocks and TrySend
+// fails, so a producer can pause further work until the consumer has caught up. The consumer
+// may cancel the stream at any time to signal the producer to stop.
+type Stream[T any] struct {
+	results chan T
+
+	// done is closed when the consumer cancels the stream
+	done       chan struct{}
+	cancelOnce sync.Once
+
+	// closed is closed when the producer closes the stream
+	closed    chan struct{}
+	closeOnce sync.Once
+	mu        sync.Mutex // guards err and sending
+	err       error
+	sending   sync.WaitGroup
+}
+
+// NewStream creates a stream that buffers up to capacity undelivered results.
+func NewStream[T any](capacity int) *Stream[T] {
+	return &Stream[T]{
+		results: make(chan T, capacity),
+		done:    make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+}
+
+// Send delivers a result to the consumer, blocking while the buffer is full. It returns
+// ErrStreamCancelled if the consumer cancelled the stream, ErrStreamClosed if the stream was
+// closed, including while Send was blocked, or the context's error if ctx is done before the
+// result could be buffered.
+func (s *Stream[T]) Send(ctx context.Context, v T) error {
+	if err := s.beginSend(); err != nil {
+		return err
+	}
+	defer s.sending.Done()
+
+	select {
+	case s.results <- v:
+		return nil
+	case <-s.done:
+		return ErrStreamCancelled
+	case <-s.closed:
+		// unblocks Close, which waits for in-progress sends
+		return ErrStreamClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TrySend delivers a result to the consumer without blocking. It returns false if the buffer is
+// full or the stream has been cancelled or closed.
+func (s *Stream[T]) TrySend(v T) bool {
+	if err := s.beginSend(); err != nil {
+		return false
+	}
+	defer s.sending.Done()
+
+	select {
+	case <-s.done:
+		return false
+	default:
+	}
+
+	select {
+	case s.results <- v:
+		return true
+	default:
+		return false
+	}
+}
+
+// Ready reports whether the stream can accept another result without blocking. A producer
+// may use it to decide whether to continue work that will produce more results.
+func (s *Stream[T]) Ready() bool {
+	select {
+	case <-s.done:
+		return false
+	case <-s.closed:
+		return false
+	default:
+		return len(s.results) < cap(s.results)
+	}
+}
+
+// Close is called by the producer to signal that no more results will be sent. Any error is
+// returned to the consumer by Recv once the buffered results have been received. Close waits for
+// concurrent calls to Send to complete, failing those blocked on a full buffer with
+// ErrStreamClosed. Only the first call to Close has an effect.
+func (s *Stream[T]) Close(err error) {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.err = err
+		close(s.closed)
+		s.mu.Unlock()
+
+		// wait for in-progress sends before closing the results channel
+		s.sending.Wait()
+		close(s.results)
+	})
+}
+
+// Recv returns the next result, blocking until one is available. Once the producer has closed the
+// stream and all results have been received, Recv returns the producer's error or ErrStreamClosed.
+func (s *Stream[T]) Recv(ctx context.Context) (T, error) {
+	select {
+	case v, ok := <-s.results:
+		if !ok {
+			var zero T
+			return zero, s.closeErr()
+		}
+		return v, nil
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Cancel is called by the consumer to signal that it is no longer interested in results.
+// Pending and future sends fail with ErrStreamCancelled.
+func (s *Stream[T]) Cancel() {
+	s.cancelOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// Done returns a channel that is closed when the consumer cancels the stream.
+func (s *Stream[T]) Done() <-chan struct{} {
+	return s.done
+}
+
+// beginSend registers an in-progress send, failing if the stream has already been closed.
+func (s *Stream[T]) beginSend() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.closed:
+		return ErrStreamClosed
+	default:
+	}
+	s.sending.Add(1)
+	return nil
+}
+
+func (s *Stream[T]) closeErr() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	return ErrStreamClosed
+}
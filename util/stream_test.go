@@ -0,0 +1,148 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamDeliversInOrder(t *testing.T) {
+	ctx := context.Background()
+	s := NewStream[int](4)
+
+	go func() {
+		for i := 0; i < 10; i++ {
+			if err := s.Send(ctx, i); err != nil {
+				s.Close(err)
+				return
+			}
+		}
+		s.Close(nil)
+	}()
+
+	for i := 0; i < 10; i++ {
+		v, err := s.Recv(ctx)
+		require.NoError(t, err)
+		require.Equal(t, i, v)
+	}
+
+	_, err := s.Recv(ctx)
+	require.ErrorIs(t, err, ErrStreamClosed)
+}
+
+func TestStreamBackpressure(t *testing.T) {
+	ctx := context.Background()
+	s := NewStream[int](2)
+
+	require.True(t, s.Ready())
+	require.True(t, s.TrySend(1))
+	require.True(t, s.TrySend(2))
+
+	// the buffer is full, the producer should pause
+	require.False(t, s.Ready())
+	require.False(t, s.TrySend(3))
+
+	sctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, s.Send(sctx, 3), context.DeadlineExceeded)
+
+	// receiving makes room again
+	v, err := s.Recv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, v)
+	require.True(t, s.Ready())
+	require.True(t, s.TrySend(3))
+}
+
+func TestStreamCancel(t *testing.T) {
+	ctx := context.Background()
+	s := NewStream[int](1)
+	require.True(t, s.TrySend(1))
+
+	sent := make(chan error)
+	go func() {
+		// blocks since the buffer is full
+		sent <- s.Send(ctx, 2)
+	}()
+
+	s.Cancel()
+	require.ErrorIs(t, <-sent, ErrStreamCancelled)
+
+	select {
+	case <-s.Done():
+	default:
+		t.Fatal("done channel not closed")
+	}
+
+	require.False(t, s.Ready())
+	require.False(t, s.TrySend(3))
+	require.ErrorIs(t, s.Send(ctx, 3), ErrStreamCancelled)
+
+	// cancelling twice is harmless
+	s.Cancel()
+}
+
+func TestStreamCloseWithError(t *testing.T) {
+	ctx := context.Background()
+	s := NewStream[int](2)
+	errQuery := errors.New("query failed")
+
+	require.NoError(t, s.Send(ctx, 1))
+	s.Close(errQuery)
+	s.Close(nil) // ignored
+
+	// buffered results are still delivered
+	v, err := s.Recv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, v)
+
+	_, err = s.Recv(ctx)
+	require.ErrorIs(t, err, errQuery)
+
+	require.ErrorIs(t, s.Send(ctx, 2), ErrStreamClosed)
+	require.False(t, s.TrySend(2))
+	require.False(t, s.Ready())
+}
+
+func TestStreamCloseUnblocksSend(t *testing.T) {
+	ctx := context.Background()
+	s := NewStream[int](1)
+	require.NoError(t, s.Send(ctx, 1))
+
+	// a producer blocked on the full buffer
+	sent := make(chan error)
+	go func() {
+		sent <- s.Send(ctx, 2)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// the stream is closed before the consumer drains it
+	closed := make(chan struct{})
+	go func() {
+		s.Close(nil)
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked on the pending Send")
+	}
+	require.ErrorIs(t, <-sent, ErrStreamClosed)
+
+	v, err := s.Recv(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, v)
+	_, err = s.Recv(ctx)
+	require.ErrorIs(t, err, ErrStreamClosed)
+}
+
+func TestStreamRecvContext(t *testing.T) {
+	s := NewStream[int](1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := s.Recv(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+}
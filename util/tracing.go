@@ -3,11 +3,197 @@ package util
 import (
 	"context"
 	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
+
+	"github.com/plprobelab/go-kademlia/kaderr"
 )
 
 func StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
 	return otel.Tracer("go-kademlia").Start(ctx, fmt.Sprintf("KademliaDHT.%s", name), opts...)
 }
+
+// TracingConfig tunes the cost of the spans started for each message sent, received or handled,
+// which dominate the tracing overhead of large simulations. Other spans are always started.
+type TracingConfig struct {
+	SampleRate          float64 // the fraction of message spans that are started, greater than 0 and at most 1 unless DisableMessageSpans is set
+	DisableMessageSpans bool    // skips all message spans, the work they cover is traced by the span of the caller, if any
+	AggregateEvents     bool    // replaces the events added to message spans by a count of each event, set as attributes when the span ends
+}
+
+// Validate checks the configuration options and returns an error if any have invalid values.
+func (cfg *TracingConfig) Validate() error {
+	if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+		return &kaderr.ConfigurationError{
+			Component: "TracingConfig",
+			Err:       fmt.Errorf("sample rate must be between 0 and 1"),
+		}
+	}
+	if cfg.SampleRate == 0 && !cfg.DisableMessageSpans {
+		// a config missing the rate would otherwise silently skip all message spans
+		return &kaderr.ConfigurationError{
+			Component: "TracingConfig",
+			Err:       fmt.Errorf("sample rate must be greater than zero unless message spans are disabled"),
+		}
+	}
+	return nil
+}
+
+// DefaultTracingConfig returns the default configuration options for tracing, starting every
+// message span. Options may be overridden before passing to SetTracingConfig
+func DefaultTracingConfig() *TracingConfig {
+	return &TracingConfig{
+		SampleRate: 1,
+	}
+}
+
+// tracing holds the configuration set by SetTracingConfig and the number of message spans
+// considered for sampling since.
+type tracing struct {
+	cfg      TracingConfig
+	messages atomic.Uint64
+}
+
+// The environment variables read by TracingConfigFromEnv.
+const (
+	EnvTracingSampleRate          = "KADEMLIA_TRACING_SAMPLE_RATE"
+	EnvTracingDisableMessageSpans = "KADEMLIA_TRACING_DISABLE_MESSAGE_SPANS"
+	EnvTracingAggregateEvents     = "KADEMLIA_TRACING_AGGREGATE_EVENTS"
+)
+
+// TracingConfigFromEnv returns the default configuration options for tracing, overridden by
+// the environment variables that are set: EnvTracingSampleRate holds a float, the others a
+// boolean as accepted by strconv.ParseBool.
+func TracingConfigFromEnv() (*TracingConfig, error) {
+	cfg := DefaultTracingConfig()
+	if v, ok := os.LookupEnv(EnvTracingSampleRate); ok {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", EnvTracingSampleRate, err)
+		}
+		cfg.SampleRate = rate
+	}
+	if v, ok := os.LookupEnv(EnvTracingDisableMessageSpans); ok {
+		disable, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", EnvTracingDisableMessageSpans, err)
+		}
+		cfg.DisableMessageSpans = disable
+	}
+	if v, ok := os.LookupEnv(EnvTracingAggregateEvents); ok {
+		aggregate, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", EnvTracingAggregateEvents, err)
+		}
+		cfg.AggregateEvents = aggregate
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+var currentTracing atomic.Pointer[tracing]
+
+// init applies the tracing configuration of the environment, so that the cost of tracing a
+// program can be tuned without changing it. An invalid environment is ignored in favour of the
+// default config.
+func init() {
+	cfg, err := TracingConfigFromEnv()
+	if err != nil {
+		cfg = DefaultTracingConfig()
+	}
+	currentTracing.Store(&tracing{cfg: *cfg})
+}
+
+// SetTracingConfig sets the configuration of the message spans started from then on by
+// StartMessageSpan. If cfg is nil, the default config is used.
+func SetTracingConfig(cfg *TracingConfig) error {
+	if cfg == nil {
+		cfg = DefaultTracingConfig()
+	} else if err := cfg.Validate(); err != nil {
+		return err
+	}
+	currentTracing.Store(&tracing{cfg: *cfg})
+	return nil
+}
+
+// CurrentTracingConfig returns a copy of the configuration set by SetTracingConfig.
+func CurrentTracingConfig() TracingConfig {
+	return currentTracing.Load().cfg
+}
+
+// StartMessageSpan starts a span like StartSpan for work done for a single message, such as
+// sending a request or handling a response, subject to the TracingConfig set by
+// SetTracingConfig. Message spans are sampled deterministically: with a sample rate of 0.1,
+// one in every ten calls starts a span. When no span is started, ctx is returned as is with a
+// span that records nothing, so that the spans started further down are not detached from the
+// trace of the caller. attrs, which may be nil, returns the attributes of the span: it is only
+// called for spans that are recording, so that unsampled messages do not pay for formatting them.
+func StartMessageSpan(ctx context.Context, name string, attrs func() []attribute.KeyValue, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	t := currentTracing.Load()
+	if !t.sample() {
+		return ctx, trace.SpanFromContext(context.Background())
+	}
+	ctx, span := StartSpan(ctx, name, opts...)
+	if attrs != nil && span.IsRecording() {
+		span.SetAttributes(attrs()...)
+	}
+	if t.cfg.AggregateEvents && span.IsRecording() {
+		agg := &aggregatingSpan{Span: span, counts: make(map[string]int)}
+		return trace.ContextWithSpan(ctx, agg), agg
+	}
+	return ctx, span
+}
+
+// sample reports whether the next message span should be started.
+func (t *tracing) sample() bool {
+	if t.cfg.DisableMessageSpans {
+		return false
+	}
+	if t.cfg.SampleRate >= 1 {
+		return true
+	}
+	// a span is started each time the number of messages times the rate reaches a new integer
+	n := t.messages.Add(1)
+	return uint64(float64(n)*t.cfg.SampleRate) != uint64(float64(n-1)*t.cfg.SampleRate)
+}
+
+// aggregatingSpan counts the events added to a span instead of recording each of them, and sets
+// the counts as attributes named after the events when the span ends.
+type aggregatingSpan struct {
+	trace.Span
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (s *aggregatingSpan) AddEvent(name string, _ ...trace.EventOption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts[name]++
+}
+
+func (s *aggregatingSpan) End(opts ...trace.SpanEndOption) {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.counts))
+	for name := range s.counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	attrs := make([]attribute.KeyValue, len(names))
+	for i, name := range names {
+		attrs[i] = attribute.Int("events."+name, s.counts[name])
+	}
+	s.mu.Unlock()
+
+	s.Span.SetAttributes(attrs...)
+	s.Span.End(opts...)
+}
@@ -0,0 +1,125 @@
+package util
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans records the spans ended while the test runs.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	sr := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prev)
+		require.NoError(t, SetTracingConfig(nil))
+	})
+	return sr
+}
+
+func TestTracingConfigValidate(t *testing.T) {
+	require.NoError(t, DefaultTracingConfig().Validate())
+	require.Error(t, (&TracingConfig{SampleRate: -0.1}).Validate())
+	require.Error(t, (&TracingConfig{SampleRate: 1.5}).Validate())
+	require.Error(t, SetTracingConfig(&TracingConfig{SampleRate: 2}))
+
+	// a zero rate is only valid if message spans are disabled anyway
+	require.Error(t, (&TracingConfig{}).Validate())
+	require.Error(t, (&TracingConfig{AggregateEvents: true}).Validate())
+	require.NoError(t, (&TracingConfig{DisableMessageSpans: true}).Validate())
+}
+
+func TestTracingConfigFromEnv(t *testing.T) {
+	cfg, err := TracingConfigFromEnv()
+	require.NoError(t, err)
+	require.Equal(t, DefaultTracingConfig(), cfg)
+
+	t.Setenv(EnvTracingSampleRate, "0.25")
+	t.Setenv(EnvTracingAggregateEvents, "true")
+	cfg, err = TracingConfigFromEnv()
+	require.NoError(t, err)
+	require.Equal(t, &TracingConfig{SampleRate: 0.25, AggregateEvents: true}, cfg)
+
+	t.Setenv(EnvTracingDisableMessageSpans, "maybe")
+	_, err = TracingConfigFromEnv()
+	require.Error(t, err)
+
+	t.Setenv(EnvTracingDisableMessageSpans, "1")
+	t.Setenv(EnvTracingSampleRate, "3")
+	_, err = TracingConfigFromEnv()
+	require.Error(t, err)
+}
+
+func TestStartMessageSpanSampling(t *testing.T) {
+	sr := recordSpans(t)
+	ctx := context.Background()
+
+	require.NoError(t, SetTracingConfig(&TracingConfig{SampleRate: 0.25}))
+	var built int
+	attrs := func() []attribute.KeyValue {
+		built++
+		return []attribute.KeyValue{attribute.Int("n", built)}
+	}
+	for i := 0; i < 100; i++ {
+		_, span := StartMessageSpan(ctx, "msg", attrs)
+		span.End()
+	}
+	require.Len(t, sr.Ended(), 25)
+
+	// the attributes are only built for the sampled spans
+	require.Equal(t, 25, built)
+	require.Equal(t, []attribute.KeyValue{attribute.Int("n", 1)}, sr.Ended()[0].Attributes())
+
+	// other spans are not sampled
+	_, span := StartSpan(ctx, "other")
+	span.End()
+	require.Len(t, sr.Ended(), 26)
+}
+
+func TestStartMessageSpanDisabled(t *testing.T) {
+	sr := recordSpans(t)
+	require.NoError(t, SetTracingConfig(&TracingConfig{SampleRate: 1, DisableMessageSpans: true}))
+
+	ctx, parent := StartSpan(context.Background(), "parent")
+	msgCtx, span := StartMessageSpan(ctx, "msg", func() []attribute.KeyValue {
+		t.Error("attributes built for a skipped span")
+		return nil
+	})
+	require.False(t, span.IsRecording())
+	span.End()
+
+	// spans started for the message remain children of the parent
+	_, child := StartSpan(msgCtx, "child")
+	child.End()
+	parent.End()
+
+	ended := sr.Ended()
+	require.Len(t, ended, 2)
+	require.Equal(t, "KademliaDHT.child", ended[0].Name())
+	require.Equal(t, parent.SpanContext().SpanID(), ended[0].Parent().SpanID())
+}
+
+func TestStartMessageSpanAggregateEvents(t *testing.T) {
+	sr := recordSpans(t)
+	require.NoError(t, SetTracingConfig(&TracingConfig{SampleRate: 1, AggregateEvents: true}))
+
+	_, span := StartMessageSpan(context.Background(), "msg", nil)
+	span.AddEvent("peer added")
+	span.AddEvent("peer added")
+	span.AddEvent("query over")
+	span.End()
+
+	ended := sr.Ended()
+	require.Len(t, ended, 1)
+	require.Empty(t, ended[0].Events())
+	require.ElementsMatch(t, []attribute.KeyValue{
+		attribute.Int("events.peer added", 2),
+		attribute.Int("events.query over", 1),
+	}, ended[0].Attributes())
+}